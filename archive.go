@@ -0,0 +1,365 @@
+package stow
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/aigotowork/stow/internal/blob"
+	"github.com/aigotowork/stow/internal/codec"
+	"github.com/aigotowork/stow/internal/core"
+)
+
+// archiveMagic starts every archive, followed by the index length
+// (big-endian uint64), the index and the data it locates.
+const archiveMagic = "STOWARC1\n"
+
+// archiveFormat is the version of the archive index.
+const archiveFormat = 1
+
+// archiveIndex locates the records and blobs of an archive. Offsets are
+// relative to the end of the index.
+//
+// Example:
+//
+//	{"format":1,"namespace":"tenant-42","created":"2025-01-15T10:30:00Z",
+//	 "keys":{"user:1":{"off":0,"size":412}},"pins":{"user:1":[2]},
+//	 "blobs":{"_blobs/avatar_abc123.jpg":{"off":412,"size":20480}}}
+type archiveIndex struct {
+	Format    int                     `json:"format"`
+	Namespace string                  `json:"namespace"`
+	Created   time.Time               `json:"created"`
+	Keys      map[string]archiveEntry `json:"keys"`
+	Pins      map[string][]int        `json:"pins,omitempty"`
+	Blobs     map[string]archiveEntry `json:"blobs"`
+}
+
+// archiveEntry is the location of a key's records or a blob's content.
+type archiveEntry struct {
+	Offset int64 `json:"off"`
+	Size   int64 `json:"size"`
+}
+
+// writeArchive writes every key of the namespace, with its full history,
+// pins and blobs, to w as an archive. Records and blobs are written
+// decrypted; each key is read under its lock.
+func (ns *namespace) writeArchive(w io.Writer) error {
+	ns.mu.RLock()
+	keys := ns.keyMapper.ListAll()
+	ns.mu.RUnlock()
+	sort.Strings(keys)
+
+	index := archiveIndex{
+		Format:    archiveFormat,
+		Namespace: ns.name,
+		Created:   time.Now().UTC(),
+		Keys:      make(map[string]archiveEntry, len(keys)),
+		Pins:      make(map[string][]int),
+		Blobs:     make(map[string]archiveEntry),
+	}
+
+	// Records are kept in memory to build the index, blobs are streamed
+	plain := core.NewEncoder()
+	var recordData bytes.Buffer
+	refs := make(map[string]*blob.Reference)
+
+	for _, key := range keys {
+		records, pins, err := ns.dumpSnapshot(key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("key %s: %w", key, err)
+		}
+
+		blobs, blobRefs, err := ns.dumpBlobs(records)
+		if err != nil {
+			return fmt.Errorf("key %s: %w", key, err)
+		}
+		for i, b := range blobs {
+			refs[b.Location] = blobRefs[i]
+		}
+
+		start := int64(recordData.Len())
+		for _, record := range records {
+			line, err := plain.Encode(record)
+			if err != nil {
+				return fmt.Errorf("failed to encode record: %w", err)
+			}
+			recordData.Write(line)
+		}
+		index.Keys[key] = archiveEntry{Offset: start, Size: int64(recordData.Len()) - start}
+		if len(pins) > 0 {
+			index.Pins[key] = pins
+		}
+	}
+
+	locations := make([]string, 0, len(refs))
+	for loc := range refs {
+		locations = append(locations, loc)
+	}
+	sort.Strings(locations)
+
+	offset := int64(recordData.Len())
+	for _, loc := range locations {
+		index.Blobs[loc] = archiveEntry{Offset: offset, Size: refs[loc].Size}
+		offset += refs[loc].Size
+	}
+
+	indexData, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+
+	var header bytes.Buffer
+	header.WriteString(archiveMagic)
+	binary.Write(&header, binary.BigEndian, uint64(len(indexData)))
+	header.Write(indexData)
+
+	if _, err := header.WriteTo(w); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if _, err := recordData.WriteTo(w); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	for _, loc := range locations {
+		fileData, err := ns.blobManager.Load(refs[loc])
+		if err != nil {
+			return fmt.Errorf("failed to open blob %s: %w", loc, err)
+		}
+		_, err = io.CopyN(w, fileData, refs[loc].Size)
+		fileData.Close()
+		if err != nil {
+			return fmt.Errorf("failed to write blob %s: %w", loc, err)
+		}
+	}
+
+	return nil
+}
+
+// Archive is a read-only namespace bundle written by Store.ArchiveNamespace.
+// Records and blobs are read from the bundle on demand, without extracting
+// it. An Archive is safe for concurrent use if its reader is.
+//
+// Example:
+//
+//	f, _ := os.Open("tenant-42.stow")
+//	archive, err := stow.OpenArchive(f)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	var user User
+//	archive.Get("user:1", &user)
+type Archive struct {
+	r           io.ReaderAt
+	base        int64
+	index       archiveIndex
+	unmarshaler *codec.Unmarshaler
+}
+
+// OpenArchive reads the index of an archive written by Store.ArchiveNamespace.
+func OpenArchive(r io.ReaderAt) (*Archive, error) {
+	header := make([]byte, len(archiveMagic)+8)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("%w: failed to read archive header: %v", ErrCorruptedData, err)
+	}
+	if string(header[:len(archiveMagic)]) != archiveMagic {
+		return nil, fmt.Errorf("%w: not a stow archive", ErrCorruptedData)
+	}
+
+	size := binary.BigEndian.Uint64(header[len(archiveMagic):])
+	if size > 1<<32 {
+		return nil, fmt.Errorf("%w: archive index too large", ErrCorruptedData)
+	}
+	indexData := make([]byte, size)
+	if _, err := r.ReadAt(indexData, int64(len(header))); err != nil {
+		return nil, fmt.Errorf("%w: failed to read archive index: %v", ErrCorruptedData, err)
+	}
+
+	a := &Archive{r: r, base: int64(len(header)) + int64(size)}
+	if err := json.Unmarshal(indexData, &a.index); err != nil {
+		return nil, fmt.Errorf("%w: archive index: %v", ErrCorruptedData, err)
+	}
+	if a.index.Format != archiveFormat {
+		return nil, fmt.Errorf("%w: unsupported archive format %d", ErrCorruptedData, a.index.Format)
+	}
+
+	a.unmarshaler = codec.NewUnmarshaler(archiveBlobs{a})
+	return a, nil
+}
+
+// Namespace returns the name of the archived namespace.
+func (a *Archive) Namespace() string {
+	return a.index.Namespace
+}
+
+// Created returns when the archive was written.
+func (a *Archive) Created() time.Time {
+	return a.index.Created
+}
+
+// Get retrieves the latest value of a key, like Namespace.Get at the time
+// the archive is read.
+func (a *Archive) Get(key string, target interface{}) error {
+	records, err := a.records(key)
+	if err != nil {
+		return err
+	}
+
+	record := latestVisible(records, time.Now())
+	if record == nil || record.Meta.IsDelete() {
+		return ErrNotFound
+	}
+	return a.unmarshal(record, target)
+}
+
+// List returns the keys that exist (aren't deleted) in ascending order.
+func (a *Archive) List() ([]string, error) {
+	now := time.Now()
+
+	var keys []string
+	for key := range a.index.Keys {
+		records, err := a.records(key)
+		if err != nil {
+			return nil, err
+		}
+		if record := latestVisible(records, now); record != nil && !record.Meta.IsDelete() {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// GetHistory returns all versions of a key, newest first.
+func (a *Archive) GetHistory(key string) ([]Version, error) {
+	records, err := a.records(key)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]Version, 0, len(records))
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		versions = append(versions, Version{
+			Version:   record.Meta.Version,
+			Timestamp: record.Meta.Timestamp,
+			Seq:       record.Meta.Seq,
+			Operation: record.Meta.Operation,
+			Size:      calculateRecordSize(record),
+			Reason:    record.Meta.Reason,
+			VisibleAt: record.Meta.VisibleAt,
+
+			RenamedFrom: record.Meta.RenamedFrom,
+			RenamedTo:   record.Meta.RenamedTo,
+			decode: func(target interface{}) error {
+				if record.Meta.IsDelete() {
+					return fmt.Errorf("version %d is a delete operation", record.Meta.Version)
+				}
+				return a.unmarshal(record, target)
+			},
+		})
+	}
+
+	return versions, nil
+}
+
+// GetVersion retrieves a specific version of a key.
+func (a *Archive) GetVersion(key string, version int, target interface{}) error {
+	records, err := a.records(key)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if record.Meta.Version != version {
+			continue
+		}
+		if record.Meta.IsDelete() {
+			return fmt.Errorf("version %d is a delete operation", version)
+		}
+		return a.unmarshal(record, target)
+	}
+
+	return fmt.Errorf("failed to read version: version %d not found", version)
+}
+
+// Pins returns the pinned versions of a key in ascending order.
+func (a *Archive) Pins(key string) ([]int, error) {
+	if _, ok := a.index.Keys[key]; !ok {
+		return nil, ErrNotFound
+	}
+	return append([]int(nil), a.index.Pins[key]...), nil
+}
+
+// records reads the history of a key.
+func (a *Archive) records(key string) ([]*core.Record, error) {
+	entry, ok := a.index.Keys[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	records, err := readDumpRecords(a.section(entry))
+	if err != nil {
+		return nil, fmt.Errorf("%w: key %s: %v", ErrCorruptedData, key, err)
+	}
+	return records, nil
+}
+
+// unmarshal decodes a put record into target, expanding spilled records.
+func (a *Archive) unmarshal(record *core.Record, target interface{}) error {
+	data, err := a.unmarshaler.ExpandRecord(record.Data)
+	if err != nil {
+		return err
+	}
+	return a.unmarshaler.Unmarshal(withoutDerived(data), target)
+}
+
+// section returns a reader for an entry of the archive.
+func (a *Archive) section(entry archiveEntry) *io.SectionReader {
+	return io.NewSectionReader(a.r, a.base+entry.Offset, entry.Size)
+}
+
+// latestVisible returns the last record visible at now, or nil.
+func latestVisible(records []*core.Record, now time.Time) *core.Record {
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Meta.IsVisible(now) {
+			return records[i]
+		}
+	}
+	return nil
+}
+
+// archiveBlobs loads blobs from the content stored in an archive.
+type archiveBlobs struct {
+	a *Archive
+}
+
+func (b archiveBlobs) Load(ref *blob.Reference) (*blob.FileData, error) {
+	if ref == nil || !ref.IsValid() {
+		return nil, fmt.Errorf("invalid blob reference")
+	}
+
+	entry, ok := b.a.index.Blobs[ref.Location]
+	if !ok {
+		return nil, fmt.Errorf("blob file not found: %s", ref.Location)
+	}
+	return blob.NewSectionFileData(b.a.section(entry), ref.Location, ref.Name, ref.Size, ref.MimeType, ref.Hash), nil
+}
+
+func (b archiveBlobs) LoadBytes(ref *blob.Reference) ([]byte, error) {
+	fileData, err := b.Load(ref)
+	if err != nil {
+		return nil, err
+	}
+	defer fileData.Close()
+
+	return io.ReadAll(fileData)
+}
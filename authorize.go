@@ -0,0 +1,584 @@
+package stow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Authorizer decides whether an API call may proceed. It is consulted on every
+// namespace and store operation when set with WithStoreAuthorizer; a non-nil
+// error denies the call and is returned wrapped in ErrPermissionDenied.
+//
+// ctx is the context bound with Store.WithContext or Namespace.WithContext
+// (context.Background() otherwise) and typically carries the caller's
+// principal, see ContextWithPrincipal. key is empty for calls not tied to a key.
+//
+// Example:
+//
+//	stow.WithStoreAuthorizer(func(ctx context.Context, op stow.Operation, namespace, key string) error {
+//		user, _ := stow.PrincipalFromContext(ctx)
+//		if namespace != "tenant-"+user {
+//			return fmt.Errorf("%s may not access %s", user, namespace)
+//		}
+//		return nil
+//	})
+type Authorizer func(ctx context.Context, op Operation, namespace, key string) error
+
+type principalKey struct{}
+
+// ContextWithPrincipal returns a copy of ctx carrying the calling principal
+// (user, tenant or role) for an Authorizer.
+func ContextWithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal set by ContextWithPrincipal.
+func PrincipalFromContext(ctx context.Context) (string, bool) {
+	principal, ok := ctx.Value(principalKey{}).(string)
+	return principal, ok
+}
+
+// authorize runs the authorizer, wrapping denials in ErrPermissionDenied.
+// A nil authorizer allows everything.
+func (fn Authorizer) authorize(ctx context.Context, op Operation, namespace, key string) error {
+	if fn == nil {
+		return nil
+	}
+
+	if err := fn(ctx, op, namespace, key); err != nil {
+		if errors.Is(err, ErrPermissionDenied) {
+			return err
+		}
+		return fmt.Errorf("%w: %w", ErrPermissionDenied, err)
+	}
+	return nil
+}
+
+// authorizedNamespace is a namespace handle bound to a context.
+// Every call is checked with the store's Authorizer before it reaches the namespace.
+type authorizedNamespace struct {
+	*namespace
+	ctx context.Context
+}
+
+// WithContext returns a handle whose calls are authorized with ctx.
+// Without an Authorizer it returns the namespace itself.
+func (ns *namespace) WithContext(ctx context.Context) Namespace {
+	if ns.authorizer == nil {
+		return ns
+	}
+	return &authorizedNamespace{namespace: ns, ctx: ctx}
+}
+
+func (a *authorizedNamespace) WithContext(ctx context.Context) Namespace {
+	return &authorizedNamespace{namespace: a.namespace, ctx: ctx}
+}
+
+func (a *authorizedNamespace) check(op Operation, key string) error {
+	return a.authorizer.authorize(a.ctx, op, a.name, key)
+}
+
+// ========== Basic KV Operations ==========
+
+func (a *authorizedNamespace) Put(key string, value interface{}, opts ...PutOption) error {
+	if err := a.check(OpWrite, key); err != nil {
+		return err
+	}
+	return a.namespace.Put(key, value, opts...)
+}
+
+func (a *authorizedNamespace) MustPut(key string, value interface{}, opts ...PutOption) {
+	if err := a.Put(key, value, opts...); err != nil {
+		panic(err)
+	}
+}
+
+func (a *authorizedNamespace) AppendVersions(key string, values []interface{}) error {
+	if err := a.check(OpWrite, key); err != nil {
+		return err
+	}
+	return a.namespace.AppendVersions(key, values)
+}
+
+func (a *authorizedNamespace) Txn(fn func(tx Txn) error) error {
+	return a.namespace.Txn(func(tx Txn) error {
+		return fn(&authorizedTxn{Txn: tx, a: a})
+	})
+}
+
+// authorizedTxn checks each staged write and read of a transaction.
+type authorizedTxn struct {
+	Txn
+	a *authorizedNamespace
+}
+
+func (t *authorizedTxn) Get(key string, target interface{}) error {
+	if err := t.a.check(OpRead, key); err != nil {
+		return err
+	}
+	return t.Txn.Get(key, target)
+}
+
+func (t *authorizedTxn) Put(key string, value interface{}) error {
+	if err := t.a.check(OpWrite, key); err != nil {
+		return err
+	}
+	return t.Txn.Put(key, value)
+}
+
+func (t *authorizedTxn) Delete(key string) error {
+	if err := t.a.check(OpDelete, key); err != nil {
+		return err
+	}
+	return t.Txn.Delete(key)
+}
+
+func (a *authorizedNamespace) PutAuto(value interface{}, opts ...PutOption) (string, error) {
+	if err := a.check(OpWrite, ""); err != nil {
+		return "", err
+	}
+	return a.namespace.PutAuto(value, opts...)
+}
+
+func (a *authorizedNamespace) PutWithUniqueKey(base string, value interface{}, opts ...PutOption) (string, error) {
+	if err := a.check(OpWrite, base); err != nil {
+		return "", err
+	}
+	return a.namespace.PutWithUniqueKey(base, value, opts...)
+}
+
+func (a *authorizedNamespace) Get(key string, target interface{}, opts ...GetOption) error {
+	if err := a.check(OpRead, key); err != nil {
+		return err
+	}
+	return a.namespace.Get(key, target, opts...)
+}
+
+func (a *authorizedNamespace) MustGet(key string, target interface{}) {
+	if err := a.Get(key, target); err != nil {
+		panic(err)
+	}
+}
+
+func (a *authorizedNamespace) GetRaw(key string) (RawItem, error) {
+	if err := a.check(OpRead, key); err != nil {
+		return nil, err
+	}
+	return a.namespace.GetRaw(key)
+}
+
+func (a *authorizedNamespace) GetDerived(key, field, name string) (IFileData, error) {
+	if err := a.check(OpRead, key); err != nil {
+		return nil, err
+	}
+	return a.namespace.GetDerived(key, field, name)
+}
+
+func (a *authorizedNamespace) StreamBlobs(keys []string, w io.Writer, format ArchiveFormat) error {
+	for _, key := range keys {
+		if err := a.check(OpRead, key); err != nil {
+			return err
+		}
+	}
+	return a.namespace.StreamBlobs(keys, w, format)
+}
+
+func (a *authorizedNamespace) GetCached(key string, target interface{}, opts ...GetOption) error {
+	if err := a.check(OpRead, key); err != nil {
+		return err
+	}
+
+	// A loader stores what it loads
+	options := getOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.loader != nil {
+		if err := a.check(OpWrite, key); err != nil {
+			return err
+		}
+	}
+	return a.namespace.GetCached(key, target, opts...)
+}
+
+func (a *authorizedNamespace) Delete(key string, opts ...DeleteOption) error {
+	if err := a.check(OpDelete, key); err != nil {
+		return err
+	}
+	return a.namespace.Delete(key, opts...)
+}
+
+func (a *authorizedNamespace) MustDelete(key string, opts ...DeleteOption) {
+	if err := a.Delete(key, opts...); err != nil {
+		panic(err)
+	}
+}
+
+func (a *authorizedNamespace) Rename(oldKey, newKey string) error {
+	if err := a.check(OpDelete, oldKey); err != nil {
+		return err
+	}
+	if err := a.check(OpWrite, newKey); err != nil {
+		return err
+	}
+	return a.namespace.Rename(oldKey, newKey)
+}
+
+// Exists reports false when the caller may not read the key.
+func (a *authorizedNamespace) Exists(key string) bool {
+	if err := a.check(OpRead, key); err != nil {
+		return false
+	}
+	return a.namespace.Exists(key)
+}
+
+func (a *authorizedNamespace) List() ([]string, error) {
+	if err := a.check(OpList, ""); err != nil {
+		return nil, err
+	}
+	return a.namespace.List()
+}
+
+func (a *authorizedNamespace) KeysWhere(conditions map[string]bool) ([]string, error) {
+	if err := a.check(OpList, ""); err != nil {
+		return nil, err
+	}
+	return a.namespace.KeysWhere(conditions)
+}
+
+func (a *authorizedNamespace) KeysByIndex(field string, value interface{}) ([]string, error) {
+	if err := a.check(OpList, ""); err != nil {
+		return nil, err
+	}
+	return a.namespace.KeysByIndex(field, value)
+}
+
+// ========== Path Operations ==========
+
+func (a *authorizedNamespace) AppendPath(key, path string, value interface{}) error {
+	if err := a.check(OpWrite, key); err != nil {
+		return err
+	}
+	return a.namespace.AppendPath(key, path, value)
+}
+
+func (a *authorizedNamespace) InsertPath(key, path string, value interface{}) error {
+	if err := a.check(OpWrite, key); err != nil {
+		return err
+	}
+	return a.namespace.InsertPath(key, path, value)
+}
+
+func (a *authorizedNamespace) RemovePath(key, path string) error {
+	if err := a.check(OpWrite, key); err != nil {
+		return err
+	}
+	return a.namespace.RemovePath(key, path)
+}
+
+// ========== Search ==========
+
+func (a *authorizedNamespace) SimilaritySearch(field string, query []float32, k int) ([]SimilarityResult, error) {
+	if err := a.check(OpRead, ""); err != nil {
+		return nil, err
+	}
+	return a.namespace.SimilaritySearch(field, query, k)
+}
+
+// Query leaves out the keys the caller may not read.
+func (a *authorizedNamespace) Query(filters ...Filter) ([]QueryResult, error) {
+	if err := a.check(OpList, ""); err != nil {
+		return nil, err
+	}
+	results, err := a.namespace.Query(filters...)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := results[:0]
+	for _, result := range results {
+		if a.check(OpRead, result.Key) == nil {
+			allowed = append(allowed, result)
+		}
+	}
+	return allowed, nil
+}
+
+// ========== Version History ==========
+
+func (a *authorizedNamespace) GetHistory(key string) ([]Version, error) {
+	if err := a.check(OpRead, key); err != nil {
+		return nil, err
+	}
+	return a.namespace.GetHistory(key)
+}
+
+func (a *authorizedNamespace) GetVersion(key string, version int, target interface{}) error {
+	if err := a.check(OpRead, key); err != nil {
+		return err
+	}
+	return a.namespace.GetVersion(key, version, target)
+}
+
+func (a *authorizedNamespace) PinVersion(key string, version int) error {
+	if err := a.check(OpWrite, key); err != nil {
+		return err
+	}
+	return a.namespace.PinVersion(key, version)
+}
+
+func (a *authorizedNamespace) UnpinVersion(key string, version int) error {
+	if err := a.check(OpWrite, key); err != nil {
+		return err
+	}
+	return a.namespace.UnpinVersion(key, version)
+}
+
+func (a *authorizedNamespace) Pins(key string) ([]int, error) {
+	if err := a.check(OpRead, key); err != nil {
+		return nil, err
+	}
+	return a.namespace.Pins(key)
+}
+
+// ========== Tags ==========
+
+func (a *authorizedNamespace) Tag(key string, tags ...string) error {
+	if err := a.check(OpWrite, key); err != nil {
+		return err
+	}
+	return a.namespace.Tag(key, tags...)
+}
+
+func (a *authorizedNamespace) Untag(key string, tags ...string) error {
+	if err := a.check(OpWrite, key); err != nil {
+		return err
+	}
+	return a.namespace.Untag(key, tags...)
+}
+
+func (a *authorizedNamespace) Tags(key string) ([]string, error) {
+	if err := a.check(OpRead, key); err != nil {
+		return nil, err
+	}
+	return a.namespace.Tags(key)
+}
+
+func (a *authorizedNamespace) ListByTag(tag string) ([]string, error) {
+	if err := a.check(OpList, ""); err != nil {
+		return nil, err
+	}
+	return a.namespace.ListByTag(tag)
+}
+
+func (a *authorizedNamespace) DumpKey(key string, w io.Writer) error {
+	if err := a.check(OpRead, key); err != nil {
+		return err
+	}
+	return a.namespace.DumpKey(key, w)
+}
+
+// LoadKey is authorized as a write of the key named in the dump, so the
+// dump is read before the check.
+func (a *authorizedNamespace) LoadKey(r io.Reader, opts ...LoadOption) (string, error) {
+	return a.namespace.loadKey(r, func(key string) error {
+		return a.check(OpWrite, key)
+	}, opts...)
+}
+
+func (a *authorizedNamespace) AdoptFile(key, path string, opts AdoptOptions) error {
+	if err := a.check(OpWrite, key); err != nil {
+		return err
+	}
+	return a.namespace.AdoptFile(key, path, opts)
+}
+
+// ========== Maintenance ==========
+
+func (a *authorizedNamespace) Compact(keys ...string) error {
+	if err := a.check(OpAdmin, ""); err != nil {
+		return err
+	}
+	return a.namespace.Compact(keys...)
+}
+
+// CompactAsync logs and skips the compaction when denied.
+func (a *authorizedNamespace) CompactAsync(keys ...string) {
+	if err := a.check(OpAdmin, ""); err != nil {
+		a.logger.Warn("compaction denied", Field{"namespace", a.name}, Field{"error", err})
+		return
+	}
+	a.namespace.CompactAsync(keys...)
+}
+
+func (a *authorizedNamespace) CompactAll() error {
+	if err := a.check(OpAdmin, ""); err != nil {
+		return err
+	}
+	return a.namespace.CompactAll()
+}
+
+// CompactAllAsync logs and skips the compaction when denied.
+func (a *authorizedNamespace) CompactAllAsync() {
+	if err := a.check(OpAdmin, ""); err != nil {
+		a.logger.Warn("compaction denied", Field{"namespace", a.name}, Field{"error", err})
+		return
+	}
+	a.namespace.CompactAllAsync()
+}
+
+func (a *authorizedNamespace) GC() (GCResult, error) {
+	if err := a.check(OpAdmin, ""); err != nil {
+		return GCResult{}, err
+	}
+	return a.namespace.GC()
+}
+
+func (a *authorizedNamespace) Prune(opts PruneOptions) (PruneResult, error) {
+	if err := a.check(OpAdmin, ""); err != nil {
+		return PruneResult{}, err
+	}
+	return a.namespace.Prune(opts)
+}
+
+func (a *authorizedNamespace) TakeOwnership() error {
+	if err := a.check(OpAdmin, ""); err != nil {
+		return err
+	}
+	return a.namespace.TakeOwnership()
+}
+
+func (a *authorizedNamespace) BlobManifest(w io.Writer) error {
+	if err := a.check(OpAdmin, ""); err != nil {
+		return err
+	}
+	return a.namespace.BlobManifest(w)
+}
+
+func (a *authorizedNamespace) BlobReferrers(hash string) ([]KeyVersionRef, error) {
+	if err := a.check(OpAdmin, ""); err != nil {
+		return nil, err
+	}
+	return a.namespace.BlobReferrers(hash)
+}
+
+func (a *authorizedNamespace) Sweep(fn SweepFunc) (SweepResult, error) {
+	if err := a.check(OpAdmin, ""); err != nil {
+		return SweepResult{}, err
+	}
+	return a.namespace.Sweep(fn)
+}
+
+func (a *authorizedNamespace) InferSchema(sample int) (*SchemaReport, error) {
+	if err := a.check(OpAdmin, ""); err != nil {
+		return nil, err
+	}
+	return a.namespace.InferSchema(sample)
+}
+
+func (a *authorizedNamespace) RelinkBlobs() (RelinkResult, error) {
+	if err := a.check(OpAdmin, ""); err != nil {
+		return RelinkResult{}, err
+	}
+	return a.namespace.RelinkBlobs()
+}
+
+func (a *authorizedNamespace) RepairCollisions() (RepairCollisionsResult, error) {
+	if err := a.check(OpAdmin, ""); err != nil {
+		return RepairCollisionsResult{}, err
+	}
+	return a.namespace.RepairCollisions()
+}
+
+func (a *authorizedNamespace) VerifyChain(key string) (string, error) {
+	if err := a.check(OpRead, key); err != nil {
+		return "", err
+	}
+	return a.namespace.VerifyChain(key)
+}
+
+func (a *authorizedNamespace) Verify() (VerifyResult, error) {
+	if err := a.check(OpList, ""); err != nil {
+		return VerifyResult{}, err
+	}
+	return a.namespace.Verify()
+}
+
+func (a *authorizedNamespace) Refresh(keys ...string) error {
+	if err := a.check(OpAdmin, ""); err != nil {
+		return err
+	}
+	return a.namespace.Refresh(keys...)
+}
+
+func (a *authorizedNamespace) RefreshAll() error {
+	if err := a.check(OpAdmin, ""); err != nil {
+		return err
+	}
+	return a.namespace.RefreshAll()
+}
+
+// Prime requires OpRead on every key.
+func (a *authorizedNamespace) Prime(keys ...string) error {
+	for _, key := range keys {
+		if err := a.check(OpRead, key); err != nil {
+			return err
+		}
+	}
+	return a.namespace.Prime(keys...)
+}
+
+// WatchExternalChanges requires OpList: events reveal key names.
+func (a *authorizedNamespace) WatchExternalChanges(opts ...WatchOption) (*ExternalWatcher, error) {
+	if err := a.check(OpList, ""); err != nil {
+		return nil, err
+	}
+	return a.namespace.WatchExternalChanges(opts...)
+}
+
+// ========== Configuration ==========
+
+func (a *authorizedNamespace) SetConfig(config NamespaceConfig) error {
+	if err := a.check(OpAdmin, ""); err != nil {
+		return err
+	}
+	return a.namespace.SetConfig(config)
+}
+
+// ========== Fluent API ==========
+
+func (a *authorizedNamespace) WithLogger(logger Logger) Namespace {
+	a.namespace.WithLogger(logger)
+	return a
+}
+
+// WithBlobThreshold logs and leaves the config unchanged when denied.
+func (a *authorizedNamespace) WithBlobThreshold(bytes int64) Namespace {
+	if err := a.check(OpAdmin, ""); err != nil {
+		a.logger.Warn("config change denied", Field{"namespace", a.name}, Field{"error", err})
+		return a
+	}
+	a.namespace.WithBlobThreshold(bytes)
+	return a
+}
+
+// WithMaxFileSize logs and leaves the config unchanged when denied.
+func (a *authorizedNamespace) WithMaxFileSize(bytes int64) Namespace {
+	if err := a.check(OpAdmin, ""); err != nil {
+		a.logger.Warn("config change denied", Field{"namespace", a.name}, Field{"error", err})
+		return a
+	}
+	a.namespace.WithMaxFileSize(bytes)
+	return a
+}
+
+// ========== Metadata ==========
+
+func (a *authorizedNamespace) Stats() (NamespaceStats, error) {
+	if err := a.check(OpList, ""); err != nil {
+		return NamespaceStats{}, err
+	}
+	return a.namespace.Stats()
+}
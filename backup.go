@@ -0,0 +1,191 @@
+package stow
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// backupMagic starts every backup, followed by the index length
+// (big-endian uint64), the index and the namespace archives it locates.
+const backupMagic = "STOWBAK1\n"
+
+// backupFormat is the version of the backup index.
+const backupFormat = 1
+
+// backupIndex locates the archive of each namespace in a backup. Offsets
+// are relative to the end of the index.
+//
+// Example:
+//
+//	{"format":1,"created":"2025-01-15T10:30:00Z",
+//	 "namespaces":{"orders":{"off":0,"size":8192},"users":{"off":8192,"size":512}}}
+type backupIndex struct {
+	Format     int                     `json:"format"`
+	Created    time.Time               `json:"created"`
+	Namespaces map[string]archiveEntry `json:"namespaces"`
+}
+
+// Backup writes every namespace of the store to w as one bundle that
+// OpenBackup serves read-only (see ArchiveNamespace).
+func (s *store) Backup(w io.Writer) error {
+	return s.backup(context.Background(), w)
+}
+
+func (s *store) backup(ctx context.Context, w io.Writer) error {
+	names, err := s.listNamespaces(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Archives are spooled to a temporary file: the index comes first
+	tmp, err := os.CreateTemp("", "stow-backup-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	index := backupIndex{
+		Format:     backupFormat,
+		Created:    time.Now().UTC(),
+		Namespaces: make(map[string]archiveEntry, len(names)),
+	}
+
+	var offset int64
+	for _, name := range names {
+		if err := s.archiveNamespace(ctx, name, tmp); err != nil {
+			return fmt.Errorf("namespace %s: %w", name, err)
+		}
+		end, err := tmp.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return fmt.Errorf("failed to write backup: %w", err)
+		}
+		index.Namespaces[name] = archiveEntry{Offset: offset, Size: end - offset}
+		offset = end
+	}
+
+	indexData, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+
+	var header bytes.Buffer
+	header.WriteString(backupMagic)
+	binary.Write(&header, binary.BigEndian, uint64(len(indexData)))
+	header.Write(indexData)
+
+	if _, err := header.WriteTo(w); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	if _, err := io.Copy(w, tmp); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	return nil
+}
+
+// Backup is a read-only store backup written by Store.Backup. Each
+// namespace is served as an Archive straight from the bundle, without
+// restoring it to disk. A Backup is safe for concurrent use if its
+// reader is.
+//
+// Example:
+//
+//	f, _ := os.Open("customer-1234.stowbak")
+//	backup, err := stow.OpenBackup(f)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	orders, _ := backup.Namespace("orders")
+//	keys, _ := orders.List()
+type Backup struct {
+	r     io.ReaderAt
+	base  int64
+	index backupIndex
+
+	mu       sync.Mutex
+	archives map[string]*Archive
+}
+
+// OpenBackup reads the index of a backup written by Store.Backup.
+func OpenBackup(r io.ReaderAt) (*Backup, error) {
+	header := make([]byte, len(backupMagic)+8)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("%w: failed to read backup header: %v", ErrCorruptedData, err)
+	}
+	if string(header[:len(backupMagic)]) != backupMagic {
+		return nil, fmt.Errorf("%w: not a stow backup", ErrCorruptedData)
+	}
+
+	size := binary.BigEndian.Uint64(header[len(backupMagic):])
+	if size > 1<<32 {
+		return nil, fmt.Errorf("%w: backup index too large", ErrCorruptedData)
+	}
+	indexData := make([]byte, size)
+	if _, err := r.ReadAt(indexData, int64(len(header))); err != nil {
+		return nil, fmt.Errorf("%w: failed to read backup index: %v", ErrCorruptedData, err)
+	}
+
+	b := &Backup{
+		r:        r,
+		base:     int64(len(header)) + int64(size),
+		archives: make(map[string]*Archive),
+	}
+	if err := json.Unmarshal(indexData, &b.index); err != nil {
+		return nil, fmt.Errorf("%w: backup index: %v", ErrCorruptedData, err)
+	}
+	if b.index.Format != backupFormat {
+		return nil, fmt.Errorf("%w: unsupported backup format %d", ErrCorruptedData, b.index.Format)
+	}
+
+	return b, nil
+}
+
+// Created returns when the backup was written.
+func (b *Backup) Created() time.Time {
+	return b.index.Created
+}
+
+// Namespaces returns the names of the backed up namespaces in ascending order.
+func (b *Backup) Namespaces() []string {
+	names := make([]string, 0, len(b.index.Namespaces))
+	for name := range b.index.Namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Namespace returns the archive of a backed up namespace, or
+// ErrNamespaceNotFound.
+func (b *Backup) Namespace(name string) (*Archive, error) {
+	entry, ok := b.index.Namespaces[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNamespaceNotFound, name)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if a, ok := b.archives[name]; ok {
+		return a, nil
+	}
+
+	a, err := OpenArchive(io.NewSectionReader(b.r, b.base+entry.Offset, entry.Size))
+	if err != nil {
+		return nil, fmt.Errorf("namespace %s: %w", name, err)
+	}
+	b.archives[name] = a
+	return a, nil
+}
@@ -0,0 +1,106 @@
+package stow
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sync"
+)
+
+// derivedKey is the record field holding derived artifacts:
+// field name → artifact name → blob reference.
+const derivedKey = "$derived"
+
+// BlobInput describes a stored blob passed to a BlobProcessor.
+type BlobInput struct {
+	// Field is the top-level record field holding the blob
+	Field string
+
+	// Name is the original file name, if any
+	Name string
+
+	// MimeType is the MIME type recorded for the blob
+	MimeType string
+
+	// Size is the blob size in bytes
+	Size int64
+
+	// Content streams the blob content
+	Content io.Reader
+}
+
+// DerivedBlob is an artifact produced by a BlobProcessor,
+// such as a thumbnail or extracted text.
+type DerivedBlob struct {
+	// Name identifies the artifact within the field (e.g. "thumbnail")
+	Name string
+
+	// Data is the artifact content
+	Data []byte
+
+	// MimeType is the artifact MIME type (e.g. "image/jpeg")
+	MimeType string
+}
+
+// BlobProcessor derives artifacts from a blob after it is stored.
+// Returned artifacts are stored as blobs and referenced from the record's
+// "$derived" field. A processor error is logged and the Put proceeds
+// without that processor's artifacts.
+type BlobProcessor func(input BlobInput) ([]DerivedBlob, error)
+
+type processorEntry struct {
+	pattern string
+	fn      BlobProcessor
+}
+
+// blobProcessors is the store-wide registry of blob processors.
+type blobProcessors struct {
+	mu      sync.RWMutex
+	entries []processorEntry
+}
+
+// register adds a processor for MIME types matching pattern (path.Match syntax).
+func (r *blobProcessors) register(pattern string, fn BlobProcessor) error {
+	if fn == nil {
+		return fmt.Errorf("blob processor for %q is nil", pattern)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid MIME pattern %q: %w", pattern, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(r.entries, processorEntry{pattern, fn})
+	return nil
+}
+
+// match returns the processors registered for a MIME type, in registration order.
+func (r *blobProcessors) match(mimeType string) []processorEntry {
+	if r == nil || mimeType == "" {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matched []processorEntry
+	for _, entry := range r.entries {
+		if ok, _ := path.Match(entry.pattern, mimeType); ok {
+			matched = append(matched, entry)
+		}
+	}
+	return matched
+}
+
+// empty reports whether no processors are registered.
+func (r *blobProcessors) empty() bool {
+	if r == nil {
+		return true
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return len(r.entries) == 0
+}
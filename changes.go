@@ -0,0 +1,438 @@
+package stow
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aigotowork/stow/internal/fsutil"
+)
+
+const (
+	// changeLogName is the store-level log of writes followed by read-only replicas.
+	changeLogName = "_changes.log"
+
+	// changeLogPrevious holds the entries of the changes log before its last
+	// rotation, so WatchChanges can resume from them.
+	changeLogPrevious = "_changes.log.1"
+
+	// writerLockName is held by the single read-write process of a store.
+	writerLockName = "_writer.lock"
+
+	// changeLogMaxSize triggers rotation of the changes log.
+	changeLogMaxSize = 4 << 20
+
+	// DefaultReplicaPollInterval is how often read-only stores check the changes log.
+	DefaultReplicaPollInterval = 100 * time.Millisecond
+
+	// NetworkFSLockWait is how long a store opened WithNetworkFSMode waits
+	// for the writer lock. The locks of a crashed NFSv4 client are only
+	// released when its lease expires (90 seconds by default).
+	NetworkFSLockWait = 90 * time.Second
+
+	// networkFSLockPoll is how often the writer lock is retried meanwhile.
+	networkFSLockPoll = time.Second
+)
+
+// lockWriter takes the writer lock of a store. On network filesystems it
+// is a server-arbitrated record lock, retried until NetworkFSLockWait.
+func lockWriter(path string, networkFS bool) (*fsutil.FileLock, error) {
+	if !networkFS {
+		return fsutil.LockFile(path)
+	}
+
+	deadline := time.Now().Add(NetworkFSLockWait)
+	for {
+		lock, err := fsutil.LockFileNetwork(path)
+		if !errors.Is(err, fsutil.ErrLocked) || time.Now().After(deadline) {
+			return lock, err
+		}
+		time.Sleep(networkFSLockPoll)
+	}
+}
+
+// Change operations recorded in the changes log.
+const (
+	changePut           = "put"
+	changeDelete        = "delete"
+	changeConfig        = "config"
+	changeDropNamespace = "drop_namespace"
+)
+
+// changeEntry is one line of _changes.log. Seq numbers every entry of the
+// store in order, across rotations.
+//
+// Example:
+//
+//	{"seq":42,"ns":"users","k":"user:alice","f":"user_alice.jsonl","op":"put","v":3}
+type changeEntry struct {
+	Seq       uint64 `json:"seq,omitempty"`
+	Namespace string `json:"ns"`
+	Key       string `json:"k,omitempty"`
+	File      string `json:"f,omitempty"`
+	Op        string `json:"op"`
+	Version   int    `json:"v,omitempty"`
+}
+
+// changeLog appends entries to _changes.log on behalf of the writer process.
+// All methods are nil-safe; a nil log records nothing.
+type changeLog struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	size int64
+	seq  uint64
+}
+
+// openChangeLog opens the changes log for appending, continuing the
+// sequence of the entries already logged.
+func openChangeLog(basePath string) (*changeLog, error) {
+	c := &changeLog{path: filepath.Join(basePath, changeLogName)}
+	if err := c.open(); err != nil {
+		return nil, err
+	}
+
+	c.seq = lastChangeSeq(c.path)
+	if c.seq == 0 {
+		c.seq = lastChangeSeq(filepath.Join(basePath, changeLogPrevious))
+	}
+	return c, nil
+}
+
+// lastChangeSeq returns the Seq of the last entry of a changes log file,
+// or 0 when it has none.
+func lastChangeSeq(path string) uint64 {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+
+	lines := bytes.Split(data, []byte{'\n'})
+	for i := len(lines) - 1; i >= 0; i-- {
+		var entry changeEntry
+		if json.Unmarshal(lines[i], &entry) == nil && entry.Seq > 0 {
+			return entry.Seq
+		}
+	}
+	return 0
+}
+
+func (c *changeLog) open() error {
+	f, err := os.OpenFile(c.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open changes log: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to stat changes log: %w", err)
+	}
+
+	c.file = f
+	c.size = info.Size()
+	return nil
+}
+
+// record appends an entry. The record file is already durable when this is
+// called, so a replica seeing the entry can always read the change.
+func (c *changeLog) record(entry changeEntry) error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return fmt.Errorf("changes log is closed")
+	}
+
+	// Numbered under mu, so entries are logged in Seq order
+	entry.Seq = c.seq + 1
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if c.size+int64(len(line)) > changeLogMaxSize {
+		if err := c.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := c.file.Write(line)
+	c.size += int64(n)
+	if n > 0 {
+		c.seq = entry.Seq
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write changes log: %w", err)
+	}
+	return nil
+}
+
+// rotate moves the log to _changes.log.1, replacing the one rotated before,
+// and starts an empty log (caller must hold mu). Replicas notice the new
+// file and invalidate everything they cached.
+func (c *changeLog) rotate() error {
+	c.file.Close()
+	c.file = nil
+
+	previous := filepath.Join(filepath.Dir(c.path), changeLogPrevious)
+	if err := os.Rename(c.path, previous); err != nil {
+		// Keep logging to the full file rather than losing entries
+		if openErr := c.open(); openErr != nil {
+			return openErr
+		}
+		return fmt.Errorf("failed to rotate changes log: %w", err)
+	}
+
+	return c.open()
+}
+
+// close closes the log file.
+func (c *changeLog) close() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file = nil
+	return err
+}
+
+// recordChange logs a write to the store's changes log. Failures only delay
+// replicas until their cache TTL expires, so they are logged, not returned.
+func (ns *namespace) recordChange(op, key, filePath string, version int) {
+	if key != "" {
+		ns.bools.touch(key)
+	}
+	if ns.changes == nil {
+		return
+	}
+
+	entry := changeEntry{Namespace: ns.name, Key: key, Op: op, Version: version}
+	if filePath != "" {
+		entry.File = filepath.Base(filePath)
+	}
+
+	if err := ns.changes.record(entry); err != nil {
+		ns.logger.Warn("failed to record change", Field{"key", key}, Field{"error", err})
+	}
+}
+
+// changeFollower polls _changes.log in a read-only store and invalidates
+// caches of open namespaces as the writer changes keys.
+type changeFollower struct {
+	store    *store
+	path     string
+	interval time.Duration
+
+	file    *os.File
+	offset  int64
+	partial []byte
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// startChangeFollower begins following from the current end of the log;
+// nothing is cached yet, so earlier entries don't matter.
+func startChangeFollower(s *store, interval time.Duration) *changeFollower {
+	f := &changeFollower{
+		store:    s,
+		path:     filepath.Join(s.basePath, changeLogName),
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	if file, err := os.Open(f.path); err == nil {
+		f.file = file
+		f.offset, _ = file.Seek(0, io.SeekEnd)
+	}
+
+	s.resources.start(f.run)
+	return f
+}
+
+func (f *changeFollower) run() {
+	defer close(f.done)
+
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			f.poll()
+		}
+	}
+}
+
+// poll applies entries appended since the last poll.
+func (f *changeFollower) poll() {
+	// A replaced log (rotation, or the writer creating it) may hide entries
+	// we never saw, so start over from a clean cache
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return
+	}
+	if f.file == nil || !sameFile(f.file, info) {
+		file, err := os.Open(f.path)
+		if err != nil {
+			return
+		}
+		if f.file != nil {
+			f.file.Close()
+		}
+		f.file = file
+		f.offset = 0
+		f.partial = nil
+		f.store.invalidateAll()
+	}
+
+	if info.Size() <= f.offset {
+		return
+	}
+
+	buf := make([]byte, info.Size()-f.offset)
+	n, err := f.file.ReadAt(buf, f.offset)
+	if err != nil && err != io.EOF {
+		return
+	}
+	f.offset += int64(n)
+
+	data := append(f.partial, buf[:n]...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+
+		var entry changeEntry
+		if err := json.Unmarshal(data[:i], &entry); err == nil {
+			f.store.applyChange(entry)
+		}
+		data = data[i+1:]
+	}
+	f.partial = append([]byte(nil), data...)
+}
+
+// close stops polling and waits for the follower goroutine.
+func (f *changeFollower) close() {
+	if f == nil {
+		return
+	}
+
+	close(f.stop)
+	<-f.done
+
+	if f.file != nil {
+		f.file.Close()
+	}
+}
+
+func sameFile(f *os.File, info os.FileInfo) bool {
+	current, err := f.Stat()
+	return err == nil && os.SameFile(current, info)
+}
+
+// applyChange invalidates what a replica cached for one change.
+func (s *store) applyChange(entry changeEntry) {
+	if entry.Op == changeDropNamespace {
+		s.mu.Lock()
+		ns := s.handles.remove(entry.Namespace)
+		s.mu.Unlock()
+		if ns != nil {
+			ns.close()
+		}
+		s.uncacheBlobs(entry.Namespace)
+		return
+	}
+
+	s.mu.RLock()
+	ns, ok := s.handles.peek(entry.Namespace)
+	s.mu.RUnlock()
+	if !ok {
+		// Not open here, so nothing is cached
+		return
+	}
+
+	switch entry.Op {
+	case changePut, changeDelete:
+		ns.followKey(entry.Key, entry.File)
+	case changeConfig:
+		ns.reloadConfig()
+	}
+}
+
+// invalidateAll drops every cache of a replica and rescans the open namespaces.
+func (s *store) invalidateAll() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, ns := range s.handles.live() {
+		ns.rescan()
+		ns.reloadConfig()
+	}
+}
+
+// followKey registers a key created by the writer and drops it from the cache.
+func (ns *namespace) followKey(key, fileName string) {
+	if fileName != "" && ns.validKey(key) && fsutil.IsSafeName(fileName) {
+		ns.mu.Lock()
+		if ns.keyMapper.FindExact(key) == "" {
+			ns.keyMapper.Add(key, fileName)
+		}
+		ns.mu.Unlock()
+	}
+
+	ns.generation.Add(1)
+	ns.cache.Delete(key)
+	ns.bools.touch(key)
+}
+
+// rescan rebuilds the key mapper from disk and clears the cache.
+func (ns *namespace) rescan() {
+	keyMapper, err := scanOptions{workers: ns.scanWorkers}.scanner(ns.name).ScanNamespace(ns.path)
+	if err != nil {
+		ns.logger.Warn("failed to rescan namespace", Field{"namespace", ns.name}, Field{"error", err})
+	} else {
+		ns.mu.Lock()
+		ns.keyMapper = keyMapper
+		ns.mu.Unlock()
+	}
+
+	ns.generation.Add(1)
+	ns.cache.Clear()
+	ns.bools.invalidate()
+}
+
+// reloadConfig re-reads _config.json after the writer changed it.
+func (ns *namespace) reloadConfig() {
+	if err := ns.loadConfig(); err != nil {
+		ns.logger.Warn("failed to reload config", Field{"namespace", ns.name}, Field{"error", err})
+		return
+	}
+	ns.applyBlobConfig()
+	if err := ns.applySigning(); err != nil {
+		ns.logger.Warn("failed to apply signing config", Field{"namespace", ns.name}, Field{"error", err})
+	}
+}
@@ -0,0 +1,180 @@
+// Command stow provides tools for working with stow stores on disk.
+//
+// Usage:
+//
+//	stow merge BASE OURS THEIRS
+//	stow compact --all STORE
+//	stow compact STORE NAMESPACE...
+//	stow relocate STORE NEWPATH
+//
+// merge is a git merge driver for JSONL key files. It merges the histories
+// in OURS and THEIRS (sharing BASE), writes the result to OURS and exits
+// with status 1 if versions conflict. To use it, add to .git/config:
+//
+//	[merge "stow"]
+//		name = stow JSONL history merge
+//		driver = stow merge %O %A %B
+//
+// and to .gitattributes:
+//
+//	*.jsonl merge=stow
+//
+// compact compacts and garbage-collects a store that no process has open,
+// either every namespace (--all) or the ones named. It fails if the store is
+// in use.
+//
+// relocate moves a store that no process has open to NEWPATH, possibly on
+// another filesystem, fixing up blob paths in namespace configs.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aigotowork/stow"
+	"github.com/aigotowork/stow/stowmerge"
+)
+
+const usage = `usage: stow <command> [arguments]
+
+commands:
+  merge BASE OURS THEIRS   merge JSONL histories into OURS (git merge driver)
+  compact --all STORE      compact and GC every namespace of a closed store
+  compact STORE NS...      compact and GC the named namespaces
+  relocate STORE NEWPATH   move a closed store, fixing up blob paths
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "merge":
+		os.Exit(runMerge(os.Args[2:]))
+	case "compact":
+		os.Exit(runCompact(os.Args[2:]))
+	case "relocate":
+		os.Exit(runRelocate(os.Args[2:]))
+	default:
+		fmt.Fprintf(os.Stderr, "stow: unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+}
+
+// runMerge merges and returns the exit status: 0 when clean, 1 with
+// conflicts, 2 on errors (OURS is left untouched).
+func runMerge(args []string) int {
+	if len(args) != 3 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	oursPath := args[1]
+
+	var files [3][]byte
+	for i, path := range args {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "stow merge: %v\n", err)
+			return 2
+		}
+		files[i] = data
+	}
+
+	merged, conflicts, err := stowmerge.Merge(
+		bytes.NewReader(files[0]), bytes.NewReader(files[1]), bytes.NewReader(files[2]))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "stow merge: %s: %v\n", oursPath, err)
+		return 2
+	}
+
+	if err := os.WriteFile(oursPath, merged, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "stow merge: %v\n", err)
+		return 2
+	}
+
+	if len(conflicts) > 0 {
+		for _, c := range conflicts {
+			fmt.Fprintf(os.Stderr, "stow merge: %s: version %d changed on both sides\n", oursPath, c.Version)
+		}
+		return 1
+	}
+
+	return 0
+}
+
+// runCompact compacts and returns the exit status: 0 on success, 2 on errors.
+func runCompact(args []string) int {
+	flags := flag.NewFlagSet("compact", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	all := flags.Bool("all", false, "compact every namespace")
+	if err := flags.Parse(args); err != nil || flags.NArg() < 1 || *all != (flags.NArg() == 1) {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	path := flags.Arg(0)
+
+	if *all {
+		result, err := stow.CompactStore(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "stow compact: %v\n", err)
+			return 2
+		}
+		fmt.Printf("compacted %d namespaces, removed %d blobs (%d bytes)\n",
+			result.Namespaces, result.GC.RemovedBlobs, result.GC.ReclaimedSize)
+		return 0
+	}
+
+	// Never create the store or namespaces that don't exist
+	for _, dir := range append([]string{""}, flags.Args()[1:]...) {
+		if info, err := os.Stat(filepath.Join(path, dir)); err != nil || !info.IsDir() {
+			fmt.Fprintf(os.Stderr, "stow compact: %s: not found\n", filepath.Join(path, dir))
+			return 2
+		}
+	}
+
+	store, err := stow.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "stow compact: %v\n", err)
+		return 2
+	}
+	defer store.Close()
+
+	for _, name := range flags.Args()[1:] {
+		ns, err := store.GetNamespace(name)
+		if err == nil {
+			err = ns.CompactAll()
+		}
+		var gc stow.GCResult
+		if err == nil {
+			gc, err = ns.GC()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "stow compact: %s: %v\n", name, err)
+			return 2
+		}
+		fmt.Printf("compacted %s, removed %d blobs (%d bytes)\n", name, gc.RemovedBlobs, gc.ReclaimedSize)
+	}
+	return 0
+}
+
+// runRelocate moves a store and returns the exit status: 0 on success, 2 on
+// errors.
+func runRelocate(args []string) int {
+	if len(args) != 2 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	if err := stow.RelocateStore(args[0], args[1]); err != nil {
+		fmt.Fprintf(os.Stderr, "stow relocate: %v\n", err)
+		return 2
+	}
+	fmt.Printf("relocated %s to %s\n", args[0], args[1])
+	return 0
+}
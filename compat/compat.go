@@ -0,0 +1,121 @@
+// Package compat ships golden stores written by earlier versions of stow,
+// one per record format version, and what they hold. Its tests open each
+// of them with the current version, so a change that stops stow reading
+// data it wrote before fails the build: this is stow's backward
+// compatibility contract. Applications can run the same check on their
+// own data with stow.VerifyCompatibility.
+//
+// Each golden store has a namespace per feature that shows in the record
+// format: plain and scalar values, deletes with reasons, renames, pins and
+// tags, blobs, raw JSON, hash chains, signatures and encryption. The store
+// of a new format version is written with gen.go by the release that
+// introduces it:
+//
+//	go run gen.go stores/v3
+//
+// Golden stores are never regenerated or edited afterwards.
+package compat
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed all:stores
+var stores embed.FS
+
+// Manifest describes the contents of a golden store.
+type Manifest struct {
+	// FormatVersion is the record format version the store was written
+	// with (see spec.Version)
+	FormatVersion int `json:"format_version"`
+
+	// NamespaceKeys are the keys of encrypted namespaces, by name
+	NamespaceKeys map[string][]byte `json:"namespace_keys"`
+
+	// Namespaces holds the keys of each namespace, deleted ones included
+	Namespaces map[string]map[string]KeyState `json:"namespaces"`
+}
+
+// KeyState is what a golden store holds for a key.
+type KeyState struct {
+	// Number of versions in the key's history
+	Versions int `json:"versions"`
+
+	// Deleted is set when the latest version is a delete
+	Deleted bool `json:"deleted,omitempty"`
+
+	// Latest is the latest value as Get decodes it into an interface{}
+	// (blobs as their references), when not deleted
+	Latest json.RawMessage `json:"latest,omitempty"`
+}
+
+// Stores returns the names of the golden stores, oldest format first (e.g.
+// "v1", "v2").
+func Stores() []string {
+	entries, _ := fs.ReadDir(stores, "stores")
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return formatVersion(names[i]) < formatVersion(names[j]) })
+	return names
+}
+
+func formatVersion(name string) int {
+	n, _ := strconv.Atoi(strings.TrimPrefix(name, "v"))
+	return n
+}
+
+// Expected returns the manifest of the golden store name.
+func Expected(name string) (*Manifest, error) {
+	data, err := stores.ReadFile(path.Join("stores", name, "expected.json"))
+	if err != nil {
+		return nil, fmt.Errorf("golden store %s: %w", name, err)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("golden store %s: invalid manifest: %w", name, err)
+	}
+	return &m, nil
+}
+
+// Extract copies the golden store name to dir, which must not exist, so it
+// can be opened without touching the shipped files.
+func Extract(name, dir string) error {
+	root := path.Join("stores", name, "store")
+	if _, err := fs.Stat(stores, root); err != nil {
+		return fmt.Errorf("golden store %s: %w", name, err)
+	}
+	if _, err := os.Lstat(dir); err == nil {
+		return fmt.Errorf("cannot extract golden store to %s: %w", dir, os.ErrExist)
+	}
+
+	return fs.WalkDir(stores, root, func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(p, root)))
+
+		if entry.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		data, err := stores.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0644)
+	})
+}
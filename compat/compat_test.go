@@ -0,0 +1,113 @@
+package compat
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestGoldenStores(t *testing.T) {
+	names := Stores()
+	if len(names) == 0 {
+		t.Fatal("no golden stores")
+	}
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			expected, err := Expected(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir := filepath.Join(t.TempDir(), name)
+			if err := Extract(name, dir); err != nil {
+				t.Fatal(err)
+			}
+
+			var opts []stow.StoreOption
+			for ns, key := range expected.NamespaceKeys {
+				opts = append(opts, stow.WithStoreNamespaceKey(ns, key))
+			}
+
+			report, err := stow.VerifyCompatibility(dir, opts...)
+			if err != nil {
+				t.Fatalf("VerifyCompatibility failed: %v", err)
+			}
+			if !report.OK() {
+				t.Fatalf("format %d store doesn't read: %+v", expected.FormatVersion, report.Problems)
+			}
+			if report.Namespaces != len(expected.Namespaces) {
+				t.Errorf("checked %d namespaces, want %d", report.Namespaces, len(expected.Namespaces))
+			}
+
+			store, err := stow.Open(dir, append(opts, stow.WithStoreReadOnly())...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+
+			for nsName, keys := range expected.Namespaces {
+				ns, err := store.GetNamespace(nsName)
+				if err != nil {
+					t.Fatalf("%s: %v", nsName, err)
+				}
+				for key, want := range keys {
+					checkKey(t, ns, key, want)
+				}
+			}
+		})
+	}
+}
+
+func checkKey(t *testing.T, ns stow.Namespace, key string, want KeyState) {
+	t.Helper()
+
+	history, err := ns.GetHistory(key)
+	if err != nil {
+		t.Errorf("%s/%s: GetHistory failed: %v", ns.Name(), key, err)
+		return
+	}
+	if len(history) != want.Versions {
+		t.Errorf("%s/%s: %d versions, want %d", ns.Name(), key, len(history), want.Versions)
+	}
+
+	var got interface{}
+	err = ns.Get(key, &got)
+	if want.Deleted {
+		if err == nil {
+			t.Errorf("%s/%s: deleted key reads", ns.Name(), key)
+		}
+		return
+	}
+	if err != nil {
+		t.Errorf("%s/%s: Get failed: %v", ns.Name(), key, err)
+		return
+	}
+
+	// Compare as JSON values, so encodings of the same number match
+	var wantValue interface{}
+	if err := json.Unmarshal(want.Latest, &wantValue); err != nil {
+		t.Fatal(err)
+	}
+	gotJSON, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotValue interface{}
+	json.Unmarshal(gotJSON, &gotValue)
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("%s/%s = %s, want %s", ns.Name(), key, gotJSON, want.Latest)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	dir := t.TempDir()
+	if err := Extract("v1", dir); err == nil {
+		t.Error("Extract into an existing directory succeeded")
+	}
+	if err := Extract("v0", filepath.Join(dir, "v0")); err == nil {
+		t.Error("Extract of an unknown store succeeded")
+	}
+}
@@ -0,0 +1,193 @@
+//go:build ignore
+
+// gen writes a golden store and its manifest with the stow version it is
+// built against:
+//
+//	go run gen.go stores/v3
+//
+// It only uses the public API, so it can be run against the release that
+// introduced a new record format version.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aigotowork/stow"
+	"github.com/aigotowork/stow/spec"
+)
+
+// Keys of the encrypted and signed namespaces. They only protect test data.
+var (
+	sealedKey  = []byte("compat-golden-store-sealed-key-!")
+	signingKey = []byte("compat-golden-store-signing-key!")
+)
+
+// manifest and keyState mirror compat.Manifest and compat.KeyState.
+type manifest struct {
+	FormatVersion int                            `json:"format_version"`
+	NamespaceKeys map[string][]byte              `json:"namespace_keys"`
+	Namespaces    map[string]map[string]keyState `json:"namespaces"`
+}
+
+type keyState struct {
+	Versions int             `json:"versions"`
+	Deleted  bool            `json:"deleted,omitempty"`
+	Latest   json.RawMessage `json:"latest,omitempty"`
+}
+
+func main() {
+	if len(os.Args) != 2 {
+		log.Fatal("usage: go run gen.go DIR")
+	}
+	dir := os.Args[1]
+	if _, err := os.Stat(dir); err == nil {
+		log.Fatalf("%s already exists", dir)
+	}
+
+	storeDir := filepath.Join(dir, "store")
+	if err := write(storeDir); err != nil {
+		log.Fatal(err)
+	}
+
+	m, err := describe(storeDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "expected.json"), append(data, '\n'), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// write fills a store with one namespace per feature that shows in the
+// record format.
+func write(storeDir string) error {
+	store, err := stow.Open(storeDir, stow.WithStoreNamespaceKey("sealed", sealedKey))
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	create := func(name string, config stow.NamespaceConfig) stow.Namespace {
+		ns, err := store.CreateNamespace(name, config)
+		if err != nil {
+			log.Fatalf("%s: %v", name, err)
+		}
+		return ns
+	}
+
+	users := create("users", stow.DefaultNamespaceConfig())
+	users.MustPut("user:1", map[string]interface{}{"name": "Alice", "age": 30})
+	users.MustPut("user:1", map[string]interface{}{"name": "Alice", "age": 31})
+	users.MustPut("user:1", map[string]interface{}{
+		"name":    "Alice",
+		"age":     31,
+		"address": map[string]interface{}{"city": "Lisbon", "zip": "1000-001"},
+		"roles":   []interface{}{"admin", "editor"},
+	})
+	users.MustPut("user:2", map[string]interface{}{"name": "Bob"})
+	users.MustDelete("user:2", stow.WithReason("account closed"))
+	users.MustPut("team/a:b", map[string]interface{}{"members": 2}) // sanitized file name
+	users.MustPut("counter", 42)
+	users.MustPut("list", []interface{}{1, "two", true, nil})
+	users.MustPut("old-name", map[string]interface{}{"renamed": true})
+	if err := users.Rename("old-name", "new-name"); err != nil {
+		return err
+	}
+	if err := users.Tag("user:1", "vip"); err != nil {
+		return err
+	}
+	if err := users.PinVersion("user:1", 1); err != nil {
+		return err
+	}
+
+	files := create("files", stow.DefaultNamespaceConfig())
+	files.MustPut("doc", map[string]interface{}{"title": "Draft", "body": []byte(strings.Repeat("draft ", 2048))})
+	files.MustPut("doc", map[string]interface{}{"title": "Final", "body": []byte(strings.Repeat("final ", 2048))})
+	files.MustPut("raw", map[string]interface{}{"payload": json.RawMessage(`{"b":2,"a":[1,2]}`)})
+
+	config := stow.DefaultNamespaceConfig()
+	config.HashChain = true
+	chained := create("chained", config)
+	chained.MustPut("entry", map[string]interface{}{"n": 1})
+	chained.MustPut("entry", map[string]interface{}{"n": 2})
+
+	config = stow.DefaultNamespaceConfig()
+	config.Signing = stow.SigningConfig{Algorithm: stow.SigningEd25519, Key: signingKey}
+	signed := create("signed", config)
+	signed.MustPut("contract", map[string]interface{}{"party": "ACME", "amount": 1200})
+
+	sealed := create("sealed", stow.DefaultNamespaceConfig().WithKey(sealedKey))
+	sealed.MustPut("secret", map[string]interface{}{"pin": "1234", "scan": []byte(strings.Repeat("s", 8192))})
+
+	return nil
+}
+
+// describe reads back the number of versions and the latest value of every
+// key.
+func describe(storeDir string) (*manifest, error) {
+	store, err := stow.Open(storeDir, stow.WithStoreReadOnly(), stow.WithStoreNamespaceKey("sealed", sealedKey))
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+
+	m := &manifest{
+		FormatVersion: spec.Version,
+		NamespaceKeys: map[string][]byte{"sealed": sealedKey},
+		Namespaces:    make(map[string]map[string]keyState),
+	}
+
+	names, err := store.ListNamespaces()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ns, err := store.GetNamespace(name)
+		if err != nil {
+			return nil, err
+		}
+		keys, err := ns.List()
+		if err != nil {
+			return nil, err
+		}
+		// Deleted keys have history but aren't listed
+		keys = append(keys, deletedKeys[name]...)
+
+		m.Namespaces[name] = make(map[string]keyState)
+		for _, key := range keys {
+			history, err := ns.GetHistory(key)
+			if err != nil {
+				return nil, fmt.Errorf("%s/%s: %w", name, key, err)
+			}
+			state := keyState{Versions: len(history)}
+			if history[0].Operation == "delete" { // newest first
+				state.Deleted = true
+			} else {
+				var value interface{}
+				if err := ns.Get(key, &value); err != nil {
+					return nil, fmt.Errorf("%s/%s: %w", name, key, err)
+				}
+				if state.Latest, err = json.Marshal(value); err != nil {
+					return nil, fmt.Errorf("%s/%s: %w", name, key, err)
+				}
+			}
+			m.Namespaces[name][key] = state
+		}
+	}
+
+	return m, nil
+}
+
+// deletedKeys are the deleted keys write leaves behind.
+var deletedKeys = map[string][]string{"users": {"user:2", "old-name"}}
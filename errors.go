@@ -1,7 +1,16 @@
 // Package stow provides an embedded transparent file-based KV storage engine.
 package stow
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aigotowork/stow/internal/blob"
+	"github.com/aigotowork/stow/internal/codec"
+	"github.com/aigotowork/stow/internal/core"
+	"github.com/aigotowork/stow/internal/fsutil"
+	"github.com/aigotowork/stow/internal/sign"
+)
 
 // Common errors returned by Stow operations.
 var (
@@ -11,27 +20,142 @@ var (
 	// ErrKeyConflict is returned when key sanitization results in a conflict.
 	ErrKeyConflict = errors.New("key conflict after sanitization")
 
+	// ErrKeyCollision is returned by writes to a key whose file already holds
+	// another key's records. RepairCollisions splits such files.
+	ErrKeyCollision = errors.New("key file belongs to another key")
+
 	// ErrFileTooLarge is returned when a file exceeds the MaxFileSize limit.
 	ErrFileTooLarge = errors.New("file exceeds MaxFileSize limit")
 
 	// ErrDiskFull is returned when there is insufficient disk space.
 	ErrDiskFull = errors.New("disk space insufficient")
 
-	// ErrPermissionDenied is returned when permission is denied for file operations.
+	// ErrReadOnly is returned by writes to a store opened with WithStoreReadOnly.
+	ErrReadOnly = errors.New("store is read-only")
+
+	// ErrNotOwner is returned by writes to a namespace with OwnerLease whose
+	// lease is held by another writer.
+	ErrNotOwner = errors.New("namespace is owned by another writer")
+
+	// ErrStoreLocked is returned when opening a store for writing while another
+	// process (or store handle) has it open for writing.
+	ErrStoreLocked = errors.New("store is locked by another writer")
+
+	// ErrStoreFull is returned when a write would exceed the store's hard cap.
+	ErrStoreFull = errors.New("store hard cap reached")
+
+	// ErrGoroutineLimit is returned when starting a watcher would exceed the
+	// store's goroutine cap (see WithStoreMaxGoroutines).
+	ErrGoroutineLimit = errors.New("store goroutine limit reached")
+
+	// ErrUnsafePath is returned when a key, namespace or blob name would resolve
+	// outside the store, or points at a symlink.
+	ErrUnsafePath = fsutil.ErrUnsafePath
+
+	// ErrPermissionDenied is returned when permission is denied for file operations
+	// or a call is rejected by the Authorizer.
 	ErrPermissionDenied = errors.New("permission denied")
 
 	// ErrInvalidConfig is returned when configuration validation fails.
 	ErrInvalidConfig = errors.New("invalid configuration")
 
+	// ErrNotIndexed is returned by KeysWhere for fields missing from
+	// NamespaceConfig.BoolIndexes.
+	ErrNotIndexed = errors.New("field not indexed")
+
+	// ErrInvalidFilter is returned by Query for filters it can't apply, like
+	// a range over a value that is neither a number nor a string.
+	ErrInvalidFilter = errors.New("invalid query filter")
+
 	// ErrNamespaceNotFound is returned when a namespace does not exist.
 	ErrNamespaceNotFound = errors.New("namespace not found")
 
 	// ErrNamespaceExists is returned when attempting to create an existing namespace.
 	ErrNamespaceExists = errors.New("namespace already exists")
 
+	// ErrClosed is returned by a namespace handle once CloseNamespace or
+	// DeleteNamespace evicted it; GetNamespace returns a new one.
+	ErrClosed = errors.New("namespace closed")
+
+	// ErrNamespaceReserved is returned when opening, creating or deleting a
+	// namespace the store uses internally (such as _stats).
+	ErrNamespaceReserved = errors.New("namespace name is reserved")
+
+	// ErrNamespaceUnavailable is returned when opening an encrypted namespace
+	// without its key, or with a different key.
+	ErrNamespaceUnavailable = errors.New("namespace unavailable")
+
+	// ErrSigningKeyRequired is returned by writes to a signed namespace opened
+	// without its signing key, and by Verify of HMAC records without it.
+	ErrSigningKeyRequired = sign.ErrNoKey
+
+	// ErrChainBroken is returned by VerifyChain when a record doesn't link to
+	// the record before it.
+	ErrChainBroken = errors.New("hash chain broken")
+
 	// ErrCorruptedData is returned when data is corrupted or cannot be parsed.
 	ErrCorruptedData = errors.New("data corrupted")
 
+	// ErrOperationTimeout is returned when an operation exceeds its limit in
+	// OperationTimeouts. The operation may still complete in the background.
+	ErrOperationTimeout = errors.New("operation timed out")
+
 	// ErrLockTimeout is returned when lock acquisition times out.
 	ErrLockTimeout = errors.New("lock acquisition timeout")
+
+	// ErrRecordTooLarge is returned when a record exceeds MaxInlineRecordSize.
+	ErrRecordTooLarge = errors.New("record exceeds MaxInlineRecordSize")
+
+	// ErrLimitExceeded is returned when reading a key file whose line or
+	// record data exceeds MaxLineBytes or MaxRecordDataBytes.
+	ErrLimitExceeded = core.ErrLimitExceeded
+
+	// ErrInvalidPath is returned when a field path is malformed or does not resolve.
+	ErrInvalidPath = codec.ErrInvalidPath
+
+	// ErrIndexOutOfRange is returned when a path index is outside the array bounds.
+	ErrIndexOutOfRange = codec.ErrIndexOutOfRange
+
+	// ErrBlobChecksum is returned when reading a blob written through
+	// BlobFilterCRC32 whose content no longer matches its checksum.
+	ErrBlobChecksum = blob.ErrChecksumMismatch
+
+	// ErrInvalidTag is returned by ValidateModel for stow tags that can't be honored.
+	ErrInvalidTag = codec.ErrInvalidTag
+
+	// ErrInvalidEnum is returned when an enum field holds a value outside
+	// its set, on Put or when decoding on Get.
+	ErrInvalidEnum = codec.ErrInvalidEnum
+
+	// ErrNonFiniteFloat is returned by Put for NaN or ±Inf floats unless
+	// NamespaceConfig.NonFiniteFloats is FloatSentinel.
+	ErrNonFiniteFloat = codec.ErrNonFiniteFloat
 )
+
+// ErrDegradedRead is matched (with errors.Is) by every DegradedReadError.
+var ErrDegradedRead = errors.New("degraded read")
+
+// DegradedReadError is returned by Get with WithFallbackToHistory when the
+// newest records of a key couldn't be read and target was filled from an
+// older version instead.
+type DegradedReadError struct {
+	// Key that was read
+	Key string
+
+	// Version that target holds
+	Version int
+
+	// Skipped is the number of unreadable records newer than Version
+	Skipped int
+
+	// Err is why the newest of them couldn't be read
+	Err error
+}
+
+func (e *DegradedReadError) Error() string {
+	return fmt.Sprintf("key %s: read version %d after skipping %d unreadable records: %v", e.Key, e.Version, e.Skipped, e.Err)
+}
+
+func (e *DegradedReadError) Unwrap() error {
+	return ErrDegradedRead
+}
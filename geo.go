@@ -0,0 +1,97 @@
+package stow
+
+import "math"
+
+// earthRadiusKm is the mean Earth radius used for distance calculations.
+const earthRadiusKm = 6371.0088
+
+// geohashAlphabet is the base32 alphabet used by geohash encoding.
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// GeoPoint is a geographic coordinate in decimal degrees.
+// It is stored as a plain JSON object, e.g. {"lat": 52.52, "lng": 13.405}.
+//
+// Example:
+//
+//	type Place struct {
+//	    Name     string         `json:"name"`
+//	    Location stow.GeoPoint  `json:"location"`
+//	}
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// Valid reports whether the point is within latitude/longitude bounds.
+func (p GeoPoint) Valid() bool {
+	return p.Lat >= -90 && p.Lat <= 90 && p.Lng >= -180 && p.Lng <= 180
+}
+
+// DistanceKm returns the great-circle distance to other in kilometers (haversine formula).
+func (p GeoPoint) DistanceKm(other GeoPoint) float64 {
+	lat1 := p.Lat * math.Pi / 180
+	lat2 := other.Lat * math.Pi / 180
+	dLat := (other.Lat - p.Lat) * math.Pi / 180
+	dLng := (other.Lng - p.Lng) * math.Pi / 180
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// WithinKm reports whether other lies within radius kilometers of p.
+func (p GeoPoint) WithinKm(other GeoPoint, radius float64) bool {
+	return p.DistanceKm(other) <= radius
+}
+
+// Geohash encodes the point as a geohash string of the given precision (1-12).
+// Points sharing a geohash prefix are close to each other, which makes the
+// hash usable as a coarse spatial bucket.
+func (p GeoPoint) Geohash(precision int) string {
+	if precision < 1 {
+		precision = 1
+	}
+	if precision > 12 {
+		precision = 12
+	}
+
+	latRange := [2]float64{-90, 90}
+	lngRange := [2]float64{-180, 180}
+
+	hash := make([]byte, 0, precision)
+	bit, ch := 0, 0
+	even := true // Geohash interleaves bits starting with longitude
+
+	for len(hash) < precision {
+		if even {
+			mid := (lngRange[0] + lngRange[1]) / 2
+			if p.Lng >= mid {
+				ch = ch<<1 | 1
+				lngRange[0] = mid
+			} else {
+				ch <<= 1
+				lngRange[1] = mid
+			}
+		} else {
+			mid := (latRange[0] + latRange[1]) / 2
+			if p.Lat >= mid {
+				ch = ch<<1 | 1
+				latRange[0] = mid
+			} else {
+				ch <<= 1
+				latRange[1] = mid
+			}
+		}
+
+		even = !even
+		bit++
+
+		if bit == 5 {
+			hash = append(hash, geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+
+	return string(hash)
+}
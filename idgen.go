@@ -0,0 +1,288 @@
+package stow
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// IDGenerator generates keys for PutAuto.
+// Implementations must be safe for concurrent use.
+type IDGenerator interface {
+	// NewID returns a new unique key.
+	NewID() (string, error)
+}
+
+// IDGeneratorFunc adapts a plain function to the IDGenerator interface.
+//
+// Example:
+//
+//	gen := stow.IDGeneratorFunc(func() (string, error) {
+//	    return "order-" + strconv.FormatInt(nextOrderID(), 10), nil
+//	})
+//	key, err := ns.PutAuto(order, stow.WithIDGenerator(gen))
+type IDGeneratorFunc func() (string, error)
+
+// NewID calls f().
+func (f IDGeneratorFunc) NewID() (string, error) {
+	return f()
+}
+
+// Built-in generators. All of them produce keys that sort by creation time.
+var (
+	// ULID generates 26-character Crockford base32 ULIDs (monotonic within a millisecond).
+	ULID IDGenerator = &ulidGenerator{}
+
+	// UUIDv7 generates RFC 9562 version 7 UUIDs in canonical hyphenated form.
+	UUIDv7 IDGenerator = &uuidV7Generator{}
+)
+
+// Snowflake returns a generator producing 64-bit snowflake IDs for the given node (0-1023),
+// formatted as zero-padded 19-digit decimals so they sort lexically.
+// Each process writing to the same namespace should use a distinct node ID.
+func Snowflake(node int64) IDGenerator {
+	return &snowflakeGenerator{node: node & snowflakeNodeMask}
+}
+
+// ========== ULID ==========
+
+// crockfordAlphabet is the base32 alphabet used by ULIDs.
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+type ulidGenerator struct {
+	mu       sync.Mutex
+	lastMs   uint64
+	lastRand [10]byte
+}
+
+func (g *ulidGenerator) NewID() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(time.Now().UnixMilli())
+
+	if ms == g.lastMs {
+		// Same millisecond: increment the random part to stay monotonic
+		for i := len(g.lastRand) - 1; i >= 0; i-- {
+			g.lastRand[i]++
+			if g.lastRand[i] != 0 {
+				break
+			}
+		}
+	} else {
+		if _, err := rand.Read(g.lastRand[:]); err != nil {
+			return "", fmt.Errorf("failed to read random bytes: %w", err)
+		}
+		g.lastMs = ms
+	}
+
+	var id [16]byte
+	id[0] = byte(ms >> 40)
+	id[1] = byte(ms >> 32)
+	id[2] = byte(ms >> 24)
+	id[3] = byte(ms >> 16)
+	id[4] = byte(ms >> 8)
+	id[5] = byte(ms)
+	copy(id[6:], g.lastRand[:])
+
+	return encodeCrockford(id), nil
+}
+
+// encodeCrockford encodes 128 bits as 26 base32 characters (the first carries 3 bits).
+func encodeCrockford(id [16]byte) string {
+	hi := binary.BigEndian.Uint64(id[:8])
+	lo := binary.BigEndian.Uint64(id[8:])
+
+	out := make([]byte, 26)
+	for i := 25; i >= 0; i-- {
+		out[i] = crockfordAlphabet[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out)
+}
+
+// ========== UUIDv7 ==========
+
+type uuidV7Generator struct{}
+
+func (g *uuidV7Generator) NewID() (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[6:]); err != nil {
+		return "", fmt.Errorf("failed to read random bytes: %w", err)
+	}
+
+	ms := uint64(time.Now().UnixMilli())
+	id[0] = byte(ms >> 40)
+	id[1] = byte(ms >> 32)
+	id[2] = byte(ms >> 24)
+	id[3] = byte(ms >> 16)
+	id[4] = byte(ms >> 8)
+	id[5] = byte(ms)
+
+	id[6] = id[6]&0x0f | 0x70 // Version 7
+	id[8] = id[8]&0x3f | 0x80 // RFC 4122 variant
+
+	h := hex.EncodeToString(id[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32], nil
+}
+
+// ========== Snowflake ==========
+
+const (
+	snowflakeNodeBits = 10
+	snowflakeSeqBits  = 12
+	snowflakeNodeMask = 1<<snowflakeNodeBits - 1
+	snowflakeSeqMask  = 1<<snowflakeSeqBits - 1
+)
+
+// snowflakeEpoch is the custom epoch (2024-01-01T00:00:00Z) in milliseconds.
+const snowflakeEpoch = 1704067200000
+
+type snowflakeGenerator struct {
+	mu     sync.Mutex
+	node   int64
+	lastMs int64
+	seq    int64
+}
+
+func (g *snowflakeGenerator) NewID() (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := time.Now().UnixMilli()
+	if ms < g.lastMs {
+		// Clock moved backwards; keep issuing from the last timestamp
+		ms = g.lastMs
+	}
+
+	if ms == g.lastMs {
+		g.seq = (g.seq + 1) & snowflakeSeqMask
+		if g.seq == 0 {
+			// Sequence exhausted for this millisecond, wait for the next one
+			for ms <= g.lastMs {
+				time.Sleep(100 * time.Microsecond)
+				ms = time.Now().UnixMilli()
+			}
+		}
+	} else {
+		g.seq = 0
+	}
+	g.lastMs = ms
+
+	id := (ms-snowflakeEpoch)<<(snowflakeNodeBits+snowflakeSeqBits) |
+		g.node<<snowflakeSeqBits |
+		g.seq
+
+	return fmt.Sprintf("%019d", id), nil
+}
+
+// ========== PutAuto ==========
+
+// maxAutoKeyAttempts bounds retries when a generated key already exists.
+const maxAutoKeyAttempts = 5
+
+// PutAuto stores a value under a generated key and returns the key.
+func (ns *namespace) PutAuto(value interface{}, opts ...PutOption) (string, error) {
+	options := ns.putOptions(value, opts)
+
+	// A registered model key replaces generated keys
+	if options.idGenerator == nil && options.keyFunc != nil {
+		key, err := options.keyFunc(value)
+		if err != nil {
+			return "", fmt.Errorf("failed to derive key: %w", err)
+		}
+		if err := ns.Put(key, value, opts...); err != nil {
+			return "", err
+		}
+		return key, nil
+	}
+
+	generator := options.idGenerator
+	if generator == nil {
+		generator = ULID
+	}
+
+	var key string
+	err := ns.timer.run(opNamePut, ns.name, "", func() error {
+		if err := ns.checkWritable(); err != nil {
+			return err
+		}
+
+		for attempt := 0; attempt < maxAutoKeyAttempts; attempt++ {
+			var err error
+			if key, err = generator.NewID(); err != nil {
+				return fmt.Errorf("failed to generate key: %w", err)
+			}
+			if !ns.validKey(key) {
+				return fmt.Errorf("invalid key: %s", key)
+			}
+
+			// A custom generator may hand out a key that is already taken;
+			// it is checked and written under its key lock, so concurrent
+			// calls never overwrite each other
+			written, err := ns.putUnused(key, value, opts)
+			if written || err != nil {
+				return err
+			}
+		}
+		return fmt.Errorf("%w: generator returned existing keys %d times", ErrKeyConflict, maxAutoKeyAttempts)
+	})
+	if err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// ========== PutWithUniqueKey ==========
+
+// PutWithUniqueKey stores a value under base, or the first of base-2,
+// base-3... that has never been written, and returns the key. Each
+// candidate is checked and written under its key lock, so concurrent calls
+// with the same base get distinct keys. Deleted keys keep their history and
+// are not reused.
+func (ns *namespace) PutWithUniqueKey(base string, value interface{}, opts ...PutOption) (key string, err error) {
+	err = ns.timer.run(opNamePut, ns.name, base, func() error {
+		if err := ns.checkWritable(); err != nil {
+			return err
+		}
+
+		for n := 1; ; n++ {
+			key = base
+			if n > 1 {
+				key = fmt.Sprintf("%s-%d", base, n)
+			}
+			if !ns.validKey(key) {
+				return fmt.Errorf("invalid key: %s", key)
+			}
+
+			written, err := ns.putUnused(key, value, opts)
+			if written || err != nil {
+				return err
+			}
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// putUnused writes value under key unless key has been written before.
+func (ns *namespace) putUnused(key string, value interface{}, opts []PutOption) (bool, error) {
+	keyLock := ns.getKeyLock(key)
+	keyLock.Lock()
+	defer keyLock.Unlock()
+
+	ns.mu.RLock()
+	taken := ns.keyMapper.FindExact(key) != ""
+	ns.mu.RUnlock()
+	if taken {
+		return false, nil
+	}
+
+	return true, ns.putLocked(key, value, opts...)
+}
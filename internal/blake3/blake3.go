@@ -0,0 +1,266 @@
+// Package blake3 implements the BLAKE3 cryptographic hash function (default
+// hashing mode, 256-bit output) as a hash.Hash.
+//
+// This is a portable implementation following the reference implementation in
+// the BLAKE3 specification. It favours simplicity over SIMD throughput.
+package blake3
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+const (
+	// Size is the size of a BLAKE3 checksum in bytes.
+	Size = 32
+
+	// BlockSize is the block size of BLAKE3 in bytes.
+	BlockSize = 64
+
+	chunkLen = 1024
+
+	flagChunkStart = 1 << 0
+	flagChunkEnd   = 1 << 1
+	flagParent     = 1 << 2
+	flagRoot       = 1 << 3
+)
+
+var iv = [8]uint32{
+	0x6A09E667, 0xBB67AE85, 0x3C6EF372, 0xA54FF53A,
+	0x510E527F, 0x9B05688C, 0x1F83D9AB, 0x5BE0CD19,
+}
+
+var msgPermutation = [16]int{2, 6, 3, 10, 7, 0, 4, 13, 1, 11, 12, 5, 9, 14, 15, 8}
+
+// schedule[r] lists the message word indices used by round r, i.e. the
+// message permutation applied r times.
+var schedule = func() (s [7][16]int) {
+	for i := range s[0] {
+		s[0][i] = i
+	}
+	for r := 1; r < 7; r++ {
+		for i := range s[r] {
+			s[r][i] = s[r-1][msgPermutation[i]]
+		}
+	}
+	return s
+}()
+
+// g is the quarter-round mixing function.
+func g(a, b, c, d, mx, my uint32) (uint32, uint32, uint32, uint32) {
+	a = a + b + mx
+	d = bits.RotateLeft32(d^a, -16)
+	c = c + d
+	b = bits.RotateLeft32(b^c, -12)
+	a = a + b + my
+	d = bits.RotateLeft32(d^a, -8)
+	c = c + d
+	b = bits.RotateLeft32(b^c, -7)
+	return a, b, c, d
+}
+
+// compress runs the BLAKE3 compression function and returns the full 16-word state.
+func compress(cv *[8]uint32, m *[16]uint32, counter uint64, blockLen, flags uint32) [16]uint32 {
+	s0, s1, s2, s3 := cv[0], cv[1], cv[2], cv[3]
+	s4, s5, s6, s7 := cv[4], cv[5], cv[6], cv[7]
+	s8, s9, s10, s11 := iv[0], iv[1], iv[2], iv[3]
+	s12, s13, s14, s15 := uint32(counter), uint32(counter>>32), blockLen, flags
+
+	for r := 0; r < 7; r++ {
+		w := &schedule[r]
+		// Mix the columns
+		s0, s4, s8, s12 = g(s0, s4, s8, s12, m[w[0]], m[w[1]])
+		s1, s5, s9, s13 = g(s1, s5, s9, s13, m[w[2]], m[w[3]])
+		s2, s6, s10, s14 = g(s2, s6, s10, s14, m[w[4]], m[w[5]])
+		s3, s7, s11, s15 = g(s3, s7, s11, s15, m[w[6]], m[w[7]])
+		// Mix the diagonals
+		s0, s5, s10, s15 = g(s0, s5, s10, s15, m[w[8]], m[w[9]])
+		s1, s6, s11, s12 = g(s1, s6, s11, s12, m[w[10]], m[w[11]])
+		s2, s7, s8, s13 = g(s2, s7, s8, s13, m[w[12]], m[w[13]])
+		s3, s4, s9, s14 = g(s3, s4, s9, s14, m[w[14]], m[w[15]])
+	}
+
+	return [16]uint32{
+		s0 ^ s8, s1 ^ s9, s2 ^ s10, s3 ^ s11,
+		s4 ^ s12, s5 ^ s13, s6 ^ s14, s7 ^ s15,
+		s8 ^ cv[0], s9 ^ cv[1], s10 ^ cv[2], s11 ^ cv[3],
+		s12 ^ cv[4], s13 ^ cv[5], s14 ^ cv[6], s15 ^ cv[7],
+	}
+}
+
+func first8(s [16]uint32) [8]uint32 {
+	var cv [8]uint32
+	copy(cv[:], s[:8])
+	return cv
+}
+
+func wordsFromBlock(block *[BlockSize]byte) [16]uint32 {
+	var words [16]uint32
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint32(block[i*4:])
+	}
+	return words
+}
+
+// output is the state needed to produce either a chaining value or root output.
+type output struct {
+	inputCV  [8]uint32
+	block    [16]uint32
+	counter  uint64
+	blockLen uint32
+	flags    uint32
+}
+
+func (o *output) chainingValue() [8]uint32 {
+	return first8(compress(&o.inputCV, &o.block, o.counter, o.blockLen, o.flags))
+}
+
+func (o *output) rootBytes() [Size]byte {
+	s := compress(&o.inputCV, &o.block, 0, o.blockLen, o.flags|flagRoot)
+
+	var out [Size]byte
+	for i := 0; i < 8; i++ {
+		binary.LittleEndian.PutUint32(out[i*4:], s[i])
+	}
+	return out
+}
+
+// chunkState accumulates the input of a single 1024-byte chunk.
+type chunkState struct {
+	cv               [8]uint32
+	counter          uint64
+	block            [BlockSize]byte
+	blockLen         int
+	blocksCompressed int
+}
+
+func newChunkState(counter uint64) chunkState {
+	return chunkState{cv: iv, counter: counter}
+}
+
+func (c *chunkState) len() int {
+	return BlockSize*c.blocksCompressed + c.blockLen
+}
+
+func (c *chunkState) startFlag() uint32 {
+	if c.blocksCompressed == 0 {
+		return flagChunkStart
+	}
+	return 0
+}
+
+func (c *chunkState) update(p []byte) {
+	for len(p) > 0 {
+		// Compress a full block only once more input arrives,
+		// since the last block needs the CHUNK_END flag
+		if c.blockLen == BlockSize {
+			words := wordsFromBlock(&c.block)
+			c.cv = first8(compress(&c.cv, &words, c.counter, BlockSize, c.startFlag()))
+			c.blocksCompressed++
+			c.block = [BlockSize]byte{}
+			c.blockLen = 0
+		}
+
+		n := copy(c.block[c.blockLen:], p)
+		c.blockLen += n
+		p = p[n:]
+	}
+}
+
+func (c *chunkState) output() output {
+	return output{
+		inputCV:  c.cv,
+		block:    wordsFromBlock(&c.block),
+		counter:  c.counter,
+		blockLen: uint32(c.blockLen),
+		flags:    c.startFlag() | flagChunkEnd,
+	}
+}
+
+func parentOutput(left, right [8]uint32) output {
+	var block [16]uint32
+	copy(block[:8], left[:])
+	copy(block[8:], right[:])
+	return output{inputCV: iv, block: block, blockLen: BlockSize, flags: flagParent}
+}
+
+// digest is an incremental BLAKE3 hasher.
+type digest struct {
+	chunk   chunkState
+	cvStack [][8]uint32
+}
+
+// New returns a new hash.Hash computing the BLAKE3 checksum.
+func New() hash.Hash {
+	d := &digest{}
+	d.Reset()
+	return d
+}
+
+// Sum256 returns the BLAKE3 checksum of data.
+func Sum256(data []byte) [Size]byte {
+	d := &digest{}
+	d.Reset()
+	d.Write(data)
+	return d.finalize()
+}
+
+func (d *digest) Size() int { return Size }
+
+func (d *digest) BlockSize() int { return BlockSize }
+
+func (d *digest) Reset() {
+	d.chunk = newChunkState(0)
+	d.cvStack = d.cvStack[:0]
+}
+
+// addChunkCV pushes a completed chunk's chaining value, merging completed
+// subtrees. totalChunks is the number of chunks hashed so far; each trailing
+// zero bit in it corresponds to a subtree that is now complete.
+func (d *digest) addChunkCV(cv [8]uint32, totalChunks uint64) {
+	for totalChunks&1 == 0 {
+		left := d.cvStack[len(d.cvStack)-1]
+		d.cvStack = d.cvStack[:len(d.cvStack)-1]
+		out := parentOutput(left, cv)
+		cv = out.chainingValue()
+		totalChunks >>= 1
+	}
+	d.cvStack = append(d.cvStack, cv)
+}
+
+func (d *digest) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		// Finalize a full chunk only once more input arrives,
+		// since the last chunk may be the root
+		if d.chunk.len() == chunkLen {
+			out := d.chunk.output()
+			totalChunks := d.chunk.counter + 1
+			d.addChunkCV(out.chainingValue(), totalChunks)
+			d.chunk = newChunkState(totalChunks)
+		}
+
+		take := chunkLen - d.chunk.len()
+		if take > len(p) {
+			take = len(p)
+		}
+		d.chunk.update(p[:take])
+		p = p[take:]
+	}
+	return n, nil
+}
+
+// finalize computes the root hash without modifying the hasher state.
+func (d *digest) finalize() [Size]byte {
+	out := d.chunk.output()
+	for i := len(d.cvStack) - 1; i >= 0; i-- {
+		out = parentOutput(d.cvStack[i], out.chainingValue())
+	}
+	return out.rootBytes()
+}
+
+func (d *digest) Sum(b []byte) []byte {
+	sum := d.finalize()
+	return append(b, sum[:]...)
+}
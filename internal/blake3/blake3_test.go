@@ -0,0 +1,96 @@
+package blake3
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// Official test vectors: input byte i is i % 251, default hash mode, 32-byte output.
+var testVectors = []struct {
+	inputLen int
+	hash     string
+}{
+	{0, "af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262"},
+	{1, "2d3adedff11b61f14c886e35afa036736dcd87a74d27b5c1510225d0f592e213"},
+	{63, "e9bc37a594daad83be9470df7f7b3798297c3d834ce80ba85d6e207627b7db7b"},
+	{64, "4eed7141ea4a5cd4b788606bd23f46e212af9cacebacdc7d1f4c6dc7f2511b98"},
+	{65, "de1e5fa0be70df6d2be8fffd0e99ceaa8eb6e8c93a63f2d8d1c30ecb6b263dee"},
+	{1023, "10108970eeda3eb932baac1428c7a2163b0e924c9a9e25b35bba72b28f70bd11"},
+	{1024, "42214739f095a406f3fc83deb889744ac00df831c10daa55189b5d121c855af7"},
+	{1025, "d00278ae47eb27b34faecf67b4fe263f82d5412916c1ffd97c8cb7fb814b8444"},
+	{2048, "e776b6028c7cd22a4d0ba182a8bf62205d2ef576467e838ed6f2529b85fba24a"},
+	{2049, "5f4d72f40d7a5f82b15ca2b2e44b1de3c2ef86c426c95c1af0b6879522563030"},
+	{3072, "b98cb0ff3623be03326b373de6b9095218513e64f1ee2edd2525c7ad1e5cffd2"},
+	{3073, "7124b49501012f81cc7f11ca069ec9226cecb8a2c850cfe644e327d22d3e1cd3"},
+	{4096, "015094013f57a5277b59d8475c0501042c0b642e531b0a1c8f58d2163229e969"},
+	{4097, "9b4052b38f1c5fc8b1f9ff7ac7b27cd242487b3d890d15c96a1c25b8aa0fb995"},
+	{5120, "9cadc15fed8b5d854562b26a9536d9707cadeda9b143978f319ab34230535833"},
+	{31744, "62b6960e1a44bcc1eb1a611a8d6235b6b4b78f32e7abc4fb4c6cdcce94895c47"},
+	{102400, "bc3e3d41a1146b069abffad3c0d44860cf664390afce4d9661f7902e7943e085"},
+}
+
+func testInput(n int) []byte {
+	input := make([]byte, n)
+	for i := range input {
+		input[i] = byte(i % 251)
+	}
+	return input
+}
+
+func TestSum256Vectors(t *testing.T) {
+	for _, tv := range testVectors {
+		sum := Sum256(testInput(tv.inputLen))
+		if got := hex.EncodeToString(sum[:]); got != tv.hash {
+			t.Errorf("Sum256(len=%d) = %s, want %s", tv.inputLen, got, tv.hash)
+		}
+	}
+}
+
+func TestIncrementalWrites(t *testing.T) {
+	for _, tv := range testVectors {
+		input := testInput(tv.inputLen)
+
+		// Odd write sizes straddle block and chunk boundaries
+		for _, step := range []int{1, 7, 64, 1000, 1024} {
+			h := New()
+			for i := 0; i < len(input); i += step {
+				end := i + step
+				if end > len(input) {
+					end = len(input)
+				}
+				h.Write(input[i:end])
+			}
+
+			if got := hex.EncodeToString(h.Sum(nil)); got != tv.hash {
+				t.Errorf("len=%d step=%d: got %s, want %s", tv.inputLen, step, got, tv.hash)
+			}
+		}
+	}
+}
+
+func TestSumDoesNotModifyState(t *testing.T) {
+	h := New()
+	h.Write(testInput(1500))
+	first := h.Sum(nil)
+	second := h.Sum(nil)
+	if hex.EncodeToString(first) != hex.EncodeToString(second) {
+		t.Error("Sum should not change the hash state")
+	}
+
+	h.Reset()
+	if got := hex.EncodeToString(h.Sum(nil)); got != testVectors[0].hash {
+		t.Errorf("Reset hasher = %s, want empty hash", got)
+	}
+
+	if h.Size() != Size || h.BlockSize() != BlockSize {
+		t.Errorf("Size/BlockSize = %d/%d", h.Size(), h.BlockSize())
+	}
+}
+
+func BenchmarkWrite1MB(b *testing.B) {
+	data := testInput(1 << 20)
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		Sum256(data)
+	}
+}
@@ -0,0 +1,323 @@
+package blob
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aigotowork/stow/internal/fsutil"
+)
+
+// cacheTmpPrefix marks copies being filled, removed when the cache opens.
+const cacheTmpPrefix = ".tmp_"
+
+// Cache keeps local copies of blob files read from a slow blob directory,
+// e.g. on a network mount, in a directory of at most maxBytes. The least
+// recently used copies are evicted first; copies being read are never
+// evicted. Several managers can share a cache, each under its own prefix.
+//
+// Copies are the blob files as stored (encrypted and filtered), and are
+// checked against the hash of their reference every time they are read, so
+// a copy damaged on disk is fetched again instead of served.
+type Cache struct {
+	dir      string
+	maxBytes int64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element // by name relative to dir
+	lru     *list.List               // of *cacheEntry, most recently used first
+	size    int64
+
+	stats CacheStats
+}
+
+// cacheEntry is a cached copy.
+type cacheEntry struct {
+	name    string
+	size    int64
+	readers int
+	removed bool // dropped while read, removed once the last reader is done
+}
+
+// CacheStats counts the reads served by a Cache.
+type CacheStats struct {
+	Hits      int64 // reads served from a verified copy
+	Misses    int64 // reads that copied the blob file
+	Corrupt   int64 // copies that failed verification and were dropped
+	Evictions int64 // copies evicted to make room
+	Bytes     int64 // size of the copies
+	MaxBytes  int64 // the cache's size limit
+}
+
+// NewCache opens the cache directory dir, creating it if needed. Copies
+// left by an earlier process are kept, in the order they were last used.
+func NewCache(dir string, maxBytes int64) (*Cache, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("blob cache size must be positive")
+	}
+	if err := fsutil.EnsureDir(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create blob cache directory: %w", err)
+	}
+
+	c := &Cache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+
+	type found struct {
+		name    string
+		size    int64
+		modTime time.Time
+	}
+	var files []found
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), cacheTmpPrefix) {
+			os.Remove(path)
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		name, _ := filepath.Rel(dir, path)
+		files = append(files, found{name: name, size: info.Size(), modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan blob cache: %w", err)
+	}
+
+	// Most recently used first
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.After(files[j].modTime)
+	})
+	for _, f := range files {
+		c.entries[f.name] = c.lru.PushBack(&cacheEntry{name: f.name, size: f.size})
+		c.size += f.size
+	}
+	c.mu.Lock()
+	c.evict(0)
+	c.mu.Unlock()
+
+	return c, nil
+}
+
+// Stats returns the cache's counters.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Bytes = c.size
+	stats.MaxBytes = c.maxBytes
+	return stats
+}
+
+// open returns the path of the copy of src cached as name, copying src
+// first on a miss, and a release func to call once the copy is read. A copy
+// is only used once verify accepts it. ok is false when src can't be cached
+// (it is larger than the cache, say) and must be read in place.
+func (c *Cache) open(name, src string, verify func(path string) error) (path string, release func(), ok bool) {
+	path = filepath.Join(c.dir, name)
+
+	if entry := c.acquire(name); entry != nil {
+		if err := verify(path); err == nil {
+			os.Chtimes(path, time.Now(), time.Now())
+			c.count(&c.stats.Hits)
+			return path, c.releaser(entry), true
+		}
+		c.count(&c.stats.Corrupt)
+		c.drop(entry)
+		c.releaser(entry)()
+	}
+
+	c.count(&c.stats.Misses)
+	entry, err := c.fill(name, src, verify)
+	if err != nil {
+		return "", nil, false
+	}
+	return path, c.releaser(entry), true
+}
+
+// acquire returns the entry cached as name with a reader added, or nil.
+func (c *Cache) acquire(name string) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[name]
+	if !ok {
+		return nil
+	}
+	c.lru.MoveToFront(elem)
+	entry := elem.Value.(*cacheEntry)
+	entry.readers++
+	return entry
+}
+
+// fill copies src into the cache as name and returns its entry with a
+// reader added.
+func (c *Cache) fill(name, src string, verify func(path string) error) (*cacheEntry, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > c.maxBytes {
+		return nil, fmt.Errorf("blob file larger than the cache")
+	}
+
+	path := filepath.Join(c.dir, name)
+	if err := fsutil.EnsureDir(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), cacheTmpPrefix+"*")
+	if err != nil {
+		return nil, err
+	}
+	tmpPath := tmp.Name()
+	size, err := copyFrom(tmp, src)
+	if err == nil {
+		err = verify(tmpPath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Filled meanwhile by another reader
+	if elem, ok := c.entries[name]; ok {
+		os.Remove(tmpPath)
+		entry := elem.Value.(*cacheEntry)
+		entry.readers++
+		return entry, nil
+	}
+
+	if !c.evict(size) {
+		os.Remove(tmpPath)
+		return nil, fmt.Errorf("blob cache full of copies being read")
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+
+	entry := &cacheEntry{name: name, size: size, readers: 1}
+	c.entries[name] = c.lru.PushFront(entry)
+	c.size += size
+	return entry, nil
+}
+
+// copyFrom copies the file at src to dst and closes dst.
+func copyFrom(dst *os.File, src string) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		dst.Close()
+		return 0, err
+	}
+	defer in.Close()
+
+	n, err := io.Copy(dst, in)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// evict removes the least recently used copies nobody reads until size
+// more bytes fit, and reports whether they do (caller must hold c.mu).
+func (c *Cache) evict(size int64) bool {
+	for elem := c.lru.Back(); elem != nil && c.size+size > c.maxBytes; {
+		prev := elem.Prev()
+		if entry := elem.Value.(*cacheEntry); entry.readers == 0 {
+			c.remove(entry)
+			c.stats.Evictions++
+		}
+		elem = prev
+	}
+	return c.size+size <= c.maxBytes
+}
+
+// remove forgets entry, removing its file unless it is being read (caller
+// must hold c.mu).
+func (c *Cache) remove(entry *cacheEntry) {
+	if elem, ok := c.entries[entry.name]; ok && elem.Value == entry {
+		c.lru.Remove(elem)
+		delete(c.entries, entry.name)
+		c.size -= entry.size
+	}
+	if entry.readers > 0 {
+		entry.removed = true
+		return
+	}
+	os.Remove(filepath.Join(c.dir, entry.name))
+}
+
+// drop forgets entry.
+func (c *Cache) drop(entry *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.remove(entry)
+}
+
+// releaser returns the func ending a read of entry.
+func (c *Cache) releaser(entry *cacheEntry) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+
+			// Unless a fresh copy took the file's place meanwhile
+			entry.readers--
+			if _, refilled := c.entries[entry.name]; entry.removed && entry.readers == 0 && !refilled {
+				os.Remove(filepath.Join(c.dir, entry.name))
+			}
+		})
+	}
+}
+
+// Remove drops the copy cached as name, e.g. after its blob file was
+// deleted.
+func (c *Cache) Remove(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[name]; ok {
+		c.remove(elem.Value.(*cacheEntry))
+	}
+}
+
+// RemovePrefix drops every copy cached under prefix, e.g. the copies of a
+// deleted namespace.
+func (c *Cache) RemovePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prefix += string(filepath.Separator)
+	for name, elem := range c.entries {
+		if strings.HasPrefix(name, prefix) {
+			c.remove(elem.Value.(*cacheEntry))
+		}
+	}
+}
+
+func (c *Cache) count(counter *int64) {
+	c.mu.Lock()
+	*counter++
+	c.mu.Unlock()
+}
@@ -0,0 +1,104 @@
+package blob
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readBlob(t *testing.T, m *Manager, ref *Reference) string {
+	t.Helper()
+
+	fileData, err := m.Load(ref)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	defer fileData.Close()
+
+	data, err := io.ReadAll(fileData)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	return string(data)
+}
+
+func TestManagerCache(t *testing.T) {
+	blobDir := filepath.Join(t.TempDir(), "_blobs")
+	manager, err := NewManager(blobDir, 1024*1024, 1024)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	cacheDir := t.TempDir()
+	cache, err := NewCache(cacheDir, 250)
+	if err != nil {
+		t.Fatalf("NewCache failed: %v", err)
+	}
+	manager.SetCache(cache, "files")
+
+	a, _ := manager.Store([]byte(strings.Repeat("a", 100)), "a.txt", "text/plain")
+	b, _ := manager.Store([]byte(strings.Repeat("b", 100)), "b.txt", "text/plain")
+	c, _ := manager.Store([]byte(strings.Repeat("c", 100)), "c.txt", "text/plain")
+	copyOf := func(ref *Reference) string {
+		return filepath.Join(cacheDir, "files", filepath.Base(ref.Location))
+	}
+
+	readBlob(t, manager, a)
+	readBlob(t, manager, a)
+	if stats := cache.Stats(); stats.Misses != 1 || stats.Hits != 1 || stats.Bytes != 100 {
+		t.Errorf("stats = %+v, want one miss and one hit", stats)
+	}
+
+	// Copies are served without the blob directory
+	os.Remove(filepath.Join(blobDir, filepath.Base(a.Location)))
+	if got := readBlob(t, manager, a); got != strings.Repeat("a", 100) {
+		t.Errorf("read %q from the cache", got)
+	}
+
+	// A damaged copy is fetched again
+	readBlob(t, manager, b)
+	os.WriteFile(copyOf(b), []byte(strings.Repeat("x", 100)), 0644)
+	if got := readBlob(t, manager, b); got != strings.Repeat("b", 100) {
+		t.Errorf("read %q for a damaged copy", got)
+	}
+	if stats := cache.Stats(); stats.Corrupt != 1 {
+		t.Errorf("stats = %+v, want one corrupt copy", stats)
+	}
+
+	// The least recently used copy makes room: a was read before b
+	readBlob(t, manager, c)
+	if _, err := os.Stat(copyOf(a)); !os.IsNotExist(err) {
+		t.Errorf("expected a's copy evicted, got %v", err)
+	}
+	if _, err := os.Stat(copyOf(b)); err != nil {
+		t.Errorf("expected b's copy kept: %v", err)
+	}
+
+	// Copies being read are never evicted
+	open, err := manager.Load(b)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	big, _ := manager.Store([]byte(strings.Repeat("d", 200)), "d.txt", "text/plain")
+	readBlob(t, manager, big)
+	if _, err := os.Stat(copyOf(big)); !os.IsNotExist(err) {
+		t.Errorf("expected no room for d's copy, got %v", err)
+	}
+	open.Close()
+
+	// Deleting a blob drops its copy
+	manager.Delete(c)
+	if _, err := os.Stat(copyOf(c)); !os.IsNotExist(err) {
+		t.Errorf("expected c's copy removed, got %v", err)
+	}
+
+	// Copies survive a restart
+	reopened, err := NewCache(cacheDir, 250)
+	if err != nil {
+		t.Fatalf("NewCache failed: %v", err)
+	}
+	if stats := reopened.Stats(); stats.Bytes != cache.Stats().Bytes {
+		t.Errorf("reopened cache holds %d bytes, want %d", stats.Bytes, cache.Stats().Bytes)
+	}
+}
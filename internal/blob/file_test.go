@@ -397,3 +397,28 @@ func TestFileDataLazyOpen(t *testing.T) {
 		// This demonstrates lazy opening behavior
 	}
 }
+
+func TestSectionFileData(t *testing.T) {
+	content := []byte("headerBLOB CONTENTtrailer")
+	section := io.NewSectionReader(bytes.NewReader(content), 6, 12)
+
+	fd := NewSectionFileData(section, "_blobs/doc_abc.txt", "doc.txt", 12, "text/plain", "abc")
+	if fd.Path() != "_blobs/doc_abc.txt" || fd.Name() != "doc.txt" || fd.Size() != 12 {
+		t.Errorf("Unexpected metadata: %s %s %d", fd.Path(), fd.Name(), fd.Size())
+	}
+
+	data, err := io.ReadAll(fd)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if string(data) != "BLOB CONTENT" {
+		t.Errorf("Expected section content, got %q", data)
+	}
+
+	// Reading again after Close starts over
+	fd.Close()
+	data, _ = io.ReadAll(fd)
+	if string(data) != "BLOB CONTENT" {
+		t.Errorf("Expected content after reopen, got %q", data)
+	}
+}
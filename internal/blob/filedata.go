@@ -1,8 +1,15 @@
 package blob
 
 import (
+	"encoding/hex"
 	"fmt"
+	"hash"
+	"io"
 	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/aigotowork/stow/internal/seal"
 )
 
 // FileData implements the IFileData interface for streaming blob file access.
@@ -14,6 +21,26 @@ type FileData struct {
 	mimeType string
 	hash     string
 	file     *os.File
+
+	// key decrypts the file content when set
+	key    *seal.Key
+	reader io.Reader
+
+	// filters decode the file content, see Reference.Filters
+	filters  []Filter
+	decoders []io.Closer
+
+	// verify checks the content read against hash when set, failing the
+	// read that reaches the end on a mismatch
+	verify Hasher
+
+	// section holds the content instead of the file at path when set
+	section *io.SectionReader
+
+	// release ends the read lease on the file taken by Manager.Load, and
+	// lastRead (Unix nanoseconds) keeps it from timing out
+	release  func()
+	lastRead atomic.Int64
 }
 
 // NewFileData creates a new FileData handle.
@@ -29,27 +56,95 @@ func NewFileData(path, name string, size int64, mimeType, hash string) *FileData
 	}
 }
 
+// NewSectionFileData creates a FileData handle reading its content from
+// section, e.g. a blob inside an archive. path only identifies the content.
+func NewSectionFileData(section *io.SectionReader, path, name string, size int64, mimeType, hash string) *FileData {
+	f := NewFileData(path, name, size, mimeType, hash)
+	f.section = section
+	return f
+}
+
 // Read implements io.Reader.
 // It lazily opens the file on the first Read() call.
 func (f *FileData) Read(p []byte) (int, error) {
-	if f.file == nil {
+	if f.section != nil && f.reader == nil {
+		f.reader = io.NewSectionReader(f.section, 0, f.section.Size())
+	}
+
+	if f.reader == nil {
 		file, err := os.Open(f.path)
 		if err != nil {
 			return 0, fmt.Errorf("failed to open blob file: %w", err)
 		}
 		f.file = file
+		f.reader = file
+
+		if f.key != nil {
+			reader, err := f.key.DecryptReader(file)
+			if err != nil {
+				f.Close()
+				return 0, fmt.Errorf("failed to decrypt blob file: %w", err)
+			}
+			f.reader = reader
+		}
+
+		if len(f.filters) > 0 {
+			reader, decoders, err := decodeChain(f.reader, f.filters)
+			if err != nil {
+				f.Close()
+				return 0, fmt.Errorf("failed to decode blob file: %w", err)
+			}
+			f.reader = reader
+			f.decoders = decoders
+		}
+
+		if f.verify != nil && f.hash != "" {
+			f.reader = &verifyingReader{r: f.reader, h: f.verify.New(), want: f.hash}
+		}
+	}
+
+	if f.release != nil {
+		f.lastRead.Store(time.Now().UnixNano())
 	}
 
-	n, err := f.file.Read(p)
+	n, err := f.reader.Read(p)
+	return n, err
+}
+
+// verifyingReader hashes everything read from r and turns the end of the
+// stream into an error unless the hash is want.
+type verifyingReader struct {
+	r    io.Reader
+	h    hash.Hash
+	want string
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	v.h.Write(p[:n])
+	if err == io.EOF {
+		if got := hex.EncodeToString(v.h.Sum(nil)); got != v.want {
+			return n, fmt.Errorf("blob hash mismatch: got %s, want %s", got, v.want)
+		}
+	}
 	return n, err
 }
 
 // Close implements io.Closer.
-// It closes the underlying file if it was opened.
+// It closes the underlying file if it was opened, and ends the read lease.
 func (f *FileData) Close() error {
+	if f.release != nil {
+		defer f.release()
+		f.release = nil
+	}
+
+	closeAll(f.decoders)
+	f.decoders = nil
+	f.reader = nil
 	if f.file != nil {
 		err := f.file.Close()
 		f.file = nil
+		f.reader = nil
 		return err
 	}
 	return nil
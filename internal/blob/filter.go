@@ -0,0 +1,259 @@
+package blob
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Built-in filter names.
+const (
+	FilterGzip  = "gzip"
+	FilterCRC32 = "crc32"
+)
+
+// ErrChecksumMismatch is returned when reading a blob whose crc32 filter
+// trailer doesn't match its content.
+var ErrChecksumMismatch = errors.New("blob checksum mismatch")
+
+// Filter transforms blob content on its way to and from disk, e.g. to
+// compress it. Filters are chained: the first filter of a chain sees the
+// original content, and its output feeds the next one. Encryption always
+// comes last, so filters see plaintext.
+type Filter interface {
+	// Name identifies the filter in references and configs. Names are
+	// lowercase letters and digits.
+	Name() string
+
+	// Encode returns a writer transforming what is written to it into w.
+	// Close flushes it without closing w.
+	Encode(w io.Writer) (io.WriteCloser, error)
+
+	// Decode returns a reader reversing Encode over r.
+	Decode(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	filtersMu sync.RWMutex
+	filters   = map[string]Filter{
+		FilterGzip:  gzipFilter{},
+		FilterCRC32: crc32Filter{},
+	}
+)
+
+// RegisterFilter makes a filter available by name, for configs and for
+// reading the blobs it wrote. Built-in filters can't be replaced.
+func RegisterFilter(f Filter) error {
+	name := f.Name()
+	if !ValidFilterName(name) {
+		return fmt.Errorf("invalid blob filter name %q", name)
+	}
+
+	filtersMu.Lock()
+	defer filtersMu.Unlock()
+
+	if name == FilterGzip || name == FilterCRC32 {
+		return fmt.Errorf("blob filter %q is built in", name)
+	}
+	filters[name] = f
+	return nil
+}
+
+// FilterByName returns the registered filter with the given name.
+func FilterByName(name string) (Filter, bool) {
+	filtersMu.RLock()
+	defer filtersMu.RUnlock()
+
+	f, ok := filters[name]
+	return f, ok
+}
+
+// FiltersByName resolves a chain of filter names.
+func FiltersByName(names []string) ([]Filter, error) {
+	chain := make([]Filter, 0, len(names))
+	for _, name := range names {
+		f, ok := FilterByName(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown blob filter: %s", name)
+		}
+		chain = append(chain, f)
+	}
+	return chain, nil
+}
+
+// ValidFilterName reports whether name is usable as a filter name: it is
+// embedded in blob file names, so only lowercase letters and digits.
+func ValidFilterName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// filterNames returns the names of a chain.
+func filterNames(chain []Filter) []string {
+	if len(chain) == 0 {
+		return nil
+	}
+	names := make([]string, len(chain))
+	for i, f := range chain {
+		names[i] = f.Name()
+	}
+	return names
+}
+
+// indexKey is the hash index key and file name hash of a blob: blobs with
+// the same content but different filter chains are different files.
+// Example: "abc123", or "abc123-gzip-crc32" with filters.
+func indexKey(hash string, filterNames []string) string {
+	shortHash := ShortHash(hash)
+	if len(filterNames) == 0 {
+		return shortHash
+	}
+	return shortHash + "-" + strings.Join(filterNames, "-")
+}
+
+// encodeChain wraps w so that writes pass through the chain, first filter
+// first. The returned closers must be closed in order to flush it.
+func encodeChain(w io.Writer, chain []Filter) (io.Writer, []io.Closer, error) {
+	closers := make([]io.Closer, len(chain))
+	for i := len(chain) - 1; i >= 0; i-- {
+		wc, err := chain[i].Encode(w)
+		if err != nil {
+			return nil, nil, fmt.Errorf("blob filter %s: %w", chain[i].Name(), err)
+		}
+		closers[i] = wc
+		w = wc
+	}
+	return w, closers, nil
+}
+
+// decodeChain wraps r so that reads reverse the chain, last filter first.
+// The returned closers release the decoders.
+func decodeChain(r io.Reader, chain []Filter) (io.Reader, []io.Closer, error) {
+	var closers []io.Closer
+	for i := len(chain) - 1; i >= 0; i-- {
+		rc, err := chain[i].Decode(r)
+		if err != nil {
+			closeAll(closers)
+			return nil, nil, fmt.Errorf("blob filter %s: %w", chain[i].Name(), err)
+		}
+		closers = append(closers, rc)
+		r = rc
+	}
+	return r, closers, nil
+}
+
+// closeAll closes closers in order and returns the first error.
+func closeAll(closers []io.Closer) error {
+	var first error
+	for _, c := range closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// gzipFilter compresses blobs with gzip.
+type gzipFilter struct{}
+
+func (gzipFilter) Name() string { return FilterGzip }
+
+func (gzipFilter) Encode(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+func (gzipFilter) Decode(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// crc32Filter appends a CRC-32 (Castagnoli) of the content and checks it
+// when the content has been read to the end.
+type crc32Filter struct{}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+func (crc32Filter) Name() string { return FilterCRC32 }
+
+func (crc32Filter) Encode(w io.Writer) (io.WriteCloser, error) {
+	return &crc32Writer{w: w, crc: crc32.New(castagnoli)}, nil
+}
+
+func (crc32Filter) Decode(r io.Reader) (io.ReadCloser, error) {
+	return &crc32Reader{r: r, crc: crc32.New(castagnoli)}, nil
+}
+
+type crc32Writer struct {
+	w   io.Writer
+	crc hash.Hash32
+}
+
+func (c *crc32Writer) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.crc.Write(p[:n])
+	return n, err
+}
+
+// Close writes the checksum trailer.
+func (c *crc32Writer) Close() error {
+	_, err := c.w.Write(binary.BigEndian.AppendUint32(nil, c.crc.Sum32()))
+	return err
+}
+
+// crc32Reader holds back the last 4 bytes read, which are the trailer once
+// the input ends.
+type crc32Reader struct {
+	r    io.Reader
+	crc  hash.Hash32
+	buf  []byte
+	tail []byte
+	eof  bool
+	err  error
+}
+
+func (c *crc32Reader) Read(p []byte) (int, error) {
+	for {
+		// Serve what is known not to be the trailer
+		if len(c.tail) > 4 {
+			n := copy(p, c.tail[:len(c.tail)-4])
+			c.crc.Write(p[:n])
+			c.tail = c.tail[n:]
+			return n, nil
+		}
+
+		if c.eof {
+			if len(c.tail) != 4 || binary.BigEndian.Uint32(c.tail) != c.crc.Sum32() {
+				return 0, ErrChecksumMismatch
+			}
+			return 0, io.EOF
+		}
+		if c.err != nil {
+			return 0, c.err
+		}
+
+		if c.buf == nil {
+			c.buf = make([]byte, 32*1024)
+		}
+		n, err := c.r.Read(c.buf)
+		c.tail = append(c.tail, c.buf[:n]...)
+		if err == io.EOF {
+			c.eof = true
+		} else if err != nil {
+			c.err = err
+		}
+	}
+}
+
+func (c *crc32Reader) Close() error { return nil }
@@ -0,0 +1,209 @@
+package blob
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow/internal/seal"
+)
+
+func newFilteredManager(t *testing.T, names ...string) *Manager {
+	t.Helper()
+	m, err := NewManager(filepath.Join(t.TempDir(), "_blobs"), 0, 1024)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	chain, err := FiltersByName(names)
+	if err != nil {
+		t.Fatalf("FiltersByName failed: %v", err)
+	}
+	m.SetFilters(chain)
+	return m
+}
+
+func TestFilterRoundTrip(t *testing.T) {
+	content := []byte(strings.Repeat("compressible content ", 1000))
+
+	for _, names := range [][]string{{FilterGzip}, {FilterCRC32}, {FilterGzip, FilterCRC32}, {FilterCRC32, FilterGzip}} {
+		t.Run(strings.Join(names, "+"), func(t *testing.T) {
+			m := newFilteredManager(t, names...)
+
+			ref, err := m.Store(content, "doc.txt", "text/plain")
+			if err != nil {
+				t.Fatalf("Store failed: %v", err)
+			}
+			if !reflect.DeepEqual(ref.Filters, names) {
+				t.Errorf("ref.Filters = %v, want %v", ref.Filters, names)
+			}
+			if ref.Size != int64(len(content)) {
+				t.Errorf("ref.Size = %d, want the original size %d", ref.Size, len(content))
+			}
+
+			got, err := m.LoadBytes(ref)
+			if err != nil {
+				t.Fatalf("LoadBytes failed: %v", err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Error("content changed in the round trip")
+			}
+			if err := m.Verify(ref); err != nil {
+				t.Errorf("Verify failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestFilterGzipShrinksFile(t *testing.T) {
+	m := newFilteredManager(t, FilterGzip)
+	content := bytes.Repeat([]byte("a"), 64*1024)
+
+	ref, err := m.Store(content, "", "")
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	path, _ := m.resolveRefPath(ref)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.Size() >= int64(len(content))/10 {
+		t.Errorf("gzipped file is %d bytes, want much less than %d", info.Size(), len(content))
+	}
+}
+
+func TestFilterCRC32DetectsCorruption(t *testing.T) {
+	m := newFilteredManager(t, FilterCRC32)
+
+	ref, err := m.Store([]byte("hello world"), "", "")
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	path, _ := m.resolveRefPath(ref)
+	data, _ := os.ReadFile(path)
+	data[0] ^= 0xff
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	if _, err := m.LoadBytes(ref); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("LoadBytes = %v, want ErrChecksumMismatch", err)
+	}
+}
+
+func TestFilterWithEncryption(t *testing.T) {
+	key, err := seal.NewKey(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatalf("NewKey failed: %v", err)
+	}
+	m := newFilteredManager(t, FilterGzip, FilterCRC32)
+	m.SetKey(key)
+
+	content := []byte(strings.Repeat("secret ", 500))
+	ref, err := m.Store(content, "", "")
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	got, err := m.LoadBytes(ref)
+	if err != nil {
+		t.Fatalf("LoadBytes failed: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Error("content changed in the round trip")
+	}
+}
+
+func TestFilterChainsDontShareFiles(t *testing.T) {
+	m := newFilteredManager(t)
+	content := []byte("same content")
+
+	plain, err := m.Store(content, "", "")
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	chain, _ := FiltersByName([]string{FilterGzip})
+	m.SetFilters(chain)
+	gzipped, err := m.Store(content, "", "")
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	if plain.Location == gzipped.Location {
+		t.Fatalf("both chains use %s", plain.Location)
+	}
+	for _, ref := range []*Reference{plain, gzipped} {
+		got, err := m.LoadBytes(ref)
+		if err != nil || !bytes.Equal(got, content) {
+			t.Errorf("LoadBytes(%s) = %q, %v", ref.Location, got, err)
+		}
+	}
+
+	// The index survives a restart
+	reopened, err := NewManager(m.blobDir, 0, 1024)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	reopened.SetFilters(chain)
+	again, err := reopened.Store(content, "", "")
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if again.Location != gzipped.Location {
+		t.Errorf("reopened manager stored %s, want to reuse %s", again.Location, gzipped.Location)
+	}
+}
+
+// upperFilter is a custom filter for tests: it upper-cases on write and
+// can't restore the case, which is enough to see it ran.
+type upperFilter struct{}
+
+func (upperFilter) Name() string { return "upper" }
+
+func (upperFilter) Encode(w io.Writer) (io.WriteCloser, error) {
+	return nopWriteCloser{writerFunc(func(p []byte) (int, error) {
+		return w.Write(bytes.ToUpper(p))
+	})}, nil
+}
+
+func (upperFilter) Decode(r io.Reader) (io.ReadCloser, error) {
+	return io.NopCloser(r), nil
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestRegisterFilter(t *testing.T) {
+	if err := RegisterFilter(upperFilter{}); err != nil {
+		t.Fatalf("RegisterFilter failed: %v", err)
+	}
+	if _, ok := FilterByName("upper"); !ok {
+		t.Fatal("registered filter not found")
+	}
+
+	m := newFilteredManager(t, "upper")
+	ref, err := m.Store([]byte("shout"), "", "")
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	got, _ := m.LoadBytes(ref)
+	if string(got) != "SHOUT" {
+		t.Errorf("LoadBytes = %q, want SHOUT", got)
+	}
+
+	if err := RegisterFilter(gzipFilter{}); err == nil {
+		t.Error("replacing a built-in filter succeeded")
+	}
+	if _, err := FiltersByName([]string{"nope"}); err == nil {
+		t.Error("FiltersByName accepted an unknown filter")
+	}
+}
@@ -0,0 +1,51 @@
+package blob
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// FuzzFromMap checks that FromMap never panics on decoded JSON, only accepts
+// valid references and that accepted references survive ToMap.
+//
+// Run with: go test ./internal/blob -fuzz FuzzFromMap
+func FuzzFromMap(f *testing.F) {
+	f.Add([]byte(`{"$blob":true,"loc":"_blobs/avatar_abc123.jpg","hash":"abc123","size":20480,"mime":"image/jpeg","name":"avatar.jpg"}`))
+	f.Add([]byte(`{"$blob":true,"loc":"_blobs/x.json","hash":"h","algo":"blake3","size":1,"kind":"json"}`))
+	f.Add([]byte(`{"$blob":true,"loc":"","hash":"h","size":-1}`))
+	f.Add([]byte(`{"$blob":"true","loc":1,"hash":null,"size":"big"}`))
+	f.Add([]byte(`{"$blob":true,"loc":"../../etc/passwd","hash":"h","size":1e300}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var m map[string]interface{}
+		if err := json.Unmarshal(data, &m); err != nil {
+			return
+		}
+
+		ref, ok := FromMap(m)
+		if !ok {
+			if ref != nil {
+				t.Fatalf("FromMap returned a reference it rejected: %+v", ref)
+			}
+			return
+		}
+		if !ref.IsValid() {
+			t.Fatalf("FromMap accepted an invalid reference: %+v", ref)
+		}
+
+		encoded, err := json.Marshal(ref.ToMap())
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		var decoded map[string]interface{}
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+
+		again, ok := FromMap(decoded)
+		if !ok || !reflect.DeepEqual(again, ref) {
+			t.Fatalf("Reference changed across ToMap: %+v != %+v", again, ref)
+		}
+	})
+}
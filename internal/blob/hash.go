@@ -4,9 +4,67 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
+
+	"github.com/aigotowork/stow/internal/blake3"
+)
+
+// Hash algorithm names recorded in blob references.
+const (
+	HashSHA256 = "sha256"
+	HashBLAKE3 = "blake3"
 )
 
+// Hasher is a content hash algorithm for blob files.
+type Hasher interface {
+	// Name returns the algorithm name recorded in references (e.g. "sha256").
+	Name() string
+
+	// New returns a new hash.Hash computing the checksum.
+	New() hash.Hash
+}
+
+type hasher struct {
+	name    string
+	newHash func() hash.Hash
+}
+
+func (h hasher) Name() string   { return h.name }
+func (h hasher) New() hash.Hash { return h.newHash() }
+
+var (
+	// SHA256 is the default blob hash.
+	SHA256 Hasher = hasher{HashSHA256, sha256.New}
+
+	// BLAKE3 hashes blobs with BLAKE3 (256-bit output).
+	BLAKE3 Hasher = hasher{HashBLAKE3, blake3.New}
+)
+
+// HasherByName returns the hasher for an algorithm name.
+// The empty name refers to SHA256, the algorithm of references that predate
+// the algorithm field.
+func HasherByName(name string) (Hasher, bool) {
+	switch name {
+	case "", HashSHA256:
+		return SHA256, true
+	case HashBLAKE3:
+		return BLAKE3, true
+	}
+	return nil, false
+}
+
+// ComputeHash computes the hex-encoded hash of data from a reader with the given hasher.
+func ComputeHash(h Hasher, r io.Reader) (string, error) {
+	digest := h.New()
+
+	if _, err := io.Copy(digest, r); err != nil {
+		return "", fmt.Errorf("failed to compute hash: %w", err)
+	}
+
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
+
 // ComputeSHA256 computes the SHA256 hash of data from a reader.
 // It reads the data in chunks and computes the hash incrementally,
 // so it doesn't load the entire file into memory.
@@ -0,0 +1,110 @@
+package blob
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultLeaseTimeout is how long an open FileData that isn't read keeps
+// its blob file from being deleted.
+const DefaultLeaseTimeout = 10 * time.Minute
+
+// leaseTable tracks the FileData handles reading each blob file, so
+// deletes wait for open streams instead of breaking them (on Windows the
+// delete fails, elsewhere a reader opening the file late finds it gone).
+type leaseTable struct {
+	mu      sync.Mutex
+	timeout time.Duration
+	readers map[string]map[*FileData]struct{} // by blob path
+	pending map[string]bool                   // deletes waiting for readers
+}
+
+func newLeaseTable() *leaseTable {
+	return &leaseTable{
+		timeout: DefaultLeaseTimeout,
+		readers: make(map[string]map[*FileData]struct{}),
+		pending: make(map[string]bool),
+	}
+}
+
+// acquire registers f as a reader of path.
+func (t *leaseTable) acquire(path string, f *FileData) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	readers := t.readers[path]
+	if readers == nil {
+		readers = make(map[*FileData]struct{})
+		t.readers[path] = readers
+	}
+
+	// Handles never closed stop counting once their lease timed out
+	cutoff := time.Now().Add(-t.timeout).UnixNano()
+	for reader := range readers {
+		if reader.lastRead.Load() <= cutoff {
+			delete(readers, reader)
+		}
+	}
+
+	readers[f] = struct{}{}
+	f.lastRead.Store(time.Now().UnixNano())
+}
+
+// release unregisters f and reports whether a delete of path waits for
+// no other reader.
+func (t *leaseTable) release(path string, f *FileData) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	readers := t.readers[path]
+	if _, ok := readers[f]; !ok {
+		return false
+	}
+	delete(readers, f)
+	if len(readers) > 0 {
+		return false
+	}
+	delete(t.readers, path)
+	return t.pending[path]
+}
+
+// hold reports whether path has readers whose lease hasn't timed out,
+// remembering the delete for when they are done if so.
+func (t *leaseTable) hold(path string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cutoff := time.Now().Add(-t.timeout).UnixNano()
+	for f := range t.readers[path] {
+		if f.lastRead.Load() > cutoff {
+			t.pending[path] = true
+			return true
+		}
+	}
+	delete(t.pending, path)
+	return false
+}
+
+// waiting reports whether a delete of path waits for its readers.
+func (t *leaseTable) waiting(path string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.pending[path]
+}
+
+// cancel forgets a waiting delete of path, whose content was stored again.
+func (t *leaseTable) cancel(path string) {
+	t.mu.Lock()
+	delete(t.pending, path)
+	t.mu.Unlock()
+}
+
+// setTimeout sets the lease timeout (0 selects DefaultLeaseTimeout).
+func (t *leaseTable) setTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultLeaseTimeout
+	}
+	t.mu.Lock()
+	t.timeout = timeout
+	t.mu.Unlock()
+}
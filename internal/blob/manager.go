@@ -8,8 +8,10 @@ import (
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/aigotowork/stow/internal/fsutil"
+	"github.com/aigotowork/stow/internal/seal"
 )
 
 // Manager manages blob file storage and retrieval.
@@ -27,6 +29,25 @@ type Manager struct {
 	// Example: "abc123..." -> "avatar_abc123.jpg"
 	hashIndex map[string]string
 
+	// hasher computes content hashes of new blobs
+	hasher Hasher
+
+	// writeConcurrency is the number of buffers in flight per blob write
+	writeConcurrency int
+
+	// key encrypts blob files when set
+	key *seal.Key
+
+	// filters transform new blob files, first filter first
+	filters []Filter
+
+	// leases defer deletes of files open for reading
+	leases *leaseTable
+
+	// cache keeps local copies of the files read, under cachePrefix
+	cache       *Cache
+	cachePrefix string
+
 	mu sync.RWMutex
 }
 
@@ -43,6 +64,10 @@ func NewManager(blobDir string, maxSize, chunkSize int64) (*Manager, error) {
 		chunkSize: chunkSize,
 		nameIndex: make(map[string][]string),
 		hashIndex: make(map[string]string),
+		hasher:    SHA256,
+		leases:    newLeaseTable(),
+
+		writeConcurrency: 1,
 	}
 
 	// Build initial index
@@ -80,10 +105,21 @@ func (m *Manager) Store(data interface{}, name, mimeType string) (*Reference, er
 	tmpPath := filepath.Join(m.blobDir, fmt.Sprintf("tmp_%d", os.Getpid()))
 
 	// Create writer
-	writer, err := NewWriter(tmpPath, m.maxSize, m.chunkSize)
+	writer, err := NewWriterWithHash(tmpPath, m.maxSize, m.chunkSize, m.hasher.New())
 	if err != nil {
 		return nil, err
 	}
+	writer.SetConcurrency(m.writeConcurrency)
+	if m.key != nil {
+		if err := writer.SetKey(m.key); err != nil {
+			writer.Abort()
+			return nil, err
+		}
+	}
+	if err := writer.SetFilters(m.filters); err != nil {
+		writer.Abort()
+		return nil, err
+	}
 
 	// Write data
 	if err := writer.WriteFrom(reader); err != nil {
@@ -99,7 +135,8 @@ func (m *Manager) Store(data interface{}, name, mimeType string) (*Reference, er
 	}
 
 	// Use short hash for indexing (consistent with filename extraction)
-	shortHash := ShortHash(hash)
+	filterNames := filterNames(m.filters)
+	shortHash := m.indexKey(hash, filterNames)
 
 	// Check if this content already exists (deduplication by content hash)
 	var finalPath string
@@ -114,11 +151,11 @@ func (m *Manager) Store(data interface{}, name, mimeType string) (*Reference, er
 		os.Remove(tmpPath)
 	} else {
 		// New content, generate final file name
-		fileName = m.generateFileName(name, hash)
+		fileName = m.generateFileName(name, shortHash)
 		finalPath = filepath.Join(m.blobDir, fileName)
 
 		// Rename temp file to final name
-		if err := fsutil.SafeRename(tmpPath, finalPath); err != nil {
+		if err := fsutil.AtomicReplace(tmpPath, finalPath); err != nil {
 			os.Remove(tmpPath)
 			return nil, fmt.Errorf("failed to rename blob file: %w", err)
 		}
@@ -127,8 +164,11 @@ func (m *Manager) Store(data interface{}, name, mimeType string) (*Reference, er
 		m.hashIndex[shortHash] = fileName
 	}
 
-	// Update name index
-	if name != "" {
+	// A delete waiting for readers no longer applies
+	m.leases.cancel(finalPath)
+
+	// Update name index; encrypted blob files don't carry names
+	if name != "" && m.key == nil {
 		cleanName := m.extractCleanName(name)
 		m.nameIndex[cleanName] = append(m.nameIndex[cleanName], fileName)
 	}
@@ -136,19 +176,144 @@ func (m *Manager) Store(data interface{}, name, mimeType string) (*Reference, er
 	// Create reference (with full hash)
 	location := filepath.Join("_blobs", fileName)
 	ref := NewReference(location, hash, size, mimeType, name)
+	if m.hasher.Name() != HashSHA256 {
+		ref.Algo = m.hasher.Name()
+	}
+	ref.Filters = filterNames
 
 	return ref, nil
 }
 
+// SetHasher sets the hash algorithm for blobs stored from now on.
+// Existing blobs are unaffected; their references record their own algorithm.
+func (m *Manager) SetHasher(h Hasher) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.hasher = h
+}
+
+// SetWriteConcurrency sets the number of buffers in flight while storing a blob.
+// Values above 1 hash and write each blob concurrently; 1 is sequential.
+func (m *Manager) SetWriteConcurrency(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.writeConcurrency = n
+}
+
+// SetKey encrypts blobs stored from now on with key and decrypts loaded blobs.
+// A namespace is either encrypted from creation or not at all, so all blob
+// files of a manager share the same key.
+func (m *Manager) SetKey(key *seal.Key) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.key = key
+}
+
+// SetFilters passes blobs stored from now on through chain, first filter
+// first. Existing blobs are unaffected; their references record their own
+// chain.
+func (m *Manager) SetFilters(chain []Filter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.filters = chain
+}
+
+// SetCache has Load read blob files through cache, which keeps copies
+// under prefix. A nil cache reads them in place.
+func (m *Manager) SetCache(cache *Cache, prefix string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cache = cache
+	m.cachePrefix = prefix
+}
+
+// Verify recomputes the hash of a blob file with the algorithm recorded in
+// its reference and reports a mismatch as an error. The file in the blob
+// directory is checked, never a cached copy.
+func (m *Manager) Verify(ref *Reference) error {
+	h, ok := HasherByName(ref.Algo)
+	if !ok {
+		return fmt.Errorf("unknown blob hash algorithm: %s", ref.Algo)
+	}
+
+	fileData, err := m.load(ref, false)
+	if err != nil {
+		return err
+	}
+	defer fileData.Close()
+
+	hash, err := ComputeHash(h, fileData)
+	if err != nil {
+		return err
+	}
+
+	if hash != ref.Hash {
+		return fmt.Errorf("blob hash mismatch for %s: got %s, want %s", ref.Location, hash, ref.Hash)
+	}
+
+	return nil
+}
+
 // Load loads a blob file from a reference.
 // Returns a FileData handle for streaming access.
 func (m *Manager) Load(ref *Reference) (*FileData, error) {
+	return m.load(ref, true)
+}
+
+// load loads a blob file, from the cache when cached is set.
+func (m *Manager) load(ref *Reference, cached bool) (*FileData, error) {
 	if ref == nil || !ref.IsValid() {
 		return nil, fmt.Errorf("invalid blob reference")
 	}
 
 	// Get absolute path
-	path := m.resolveRefPath(ref)
+	path, err := m.resolveRefPath(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	chain, err := FiltersByName(ref.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	// Encrypted blob files aren't authenticated, so reads are checked
+	// against the hash in the (authenticated) record
+	var verifier Hasher
+	if m.key != nil {
+		h, ok := HasherByName(ref.Algo)
+		if !ok {
+			return nil, fmt.Errorf("unknown blob hash algorithm: %s", ref.Algo)
+		}
+		verifier = h
+	}
+
+	// Served from a verified copy without touching the blob directory
+	m.mu.RLock()
+	cache, prefix := m.cache, m.cachePrefix
+	m.mu.RUnlock()
+	if cached && cache != nil {
+		verify := func(copyPath string) error {
+			hash, err := m.HashFile(copyPath, ref)
+			if err == nil && hash != ref.Hash {
+				err = fmt.Errorf("blob hash mismatch for %s", copyPath)
+			}
+			return err
+		}
+		if copyPath, release, ok := cache.open(filepath.Join(prefix, filepath.Base(path)), path, verify); ok {
+			fileData := NewFileData(copyPath, ref.Name, ref.Size, ref.MimeType, ref.Hash)
+			fileData.key = m.key
+			fileData.filters = chain
+			fileData.verify = verifier
+			fileData.release = release
+			return fileData, nil
+		}
+	}
 
 	// Check if file exists
 	if !fsutil.FileExists(path) {
@@ -157,6 +322,16 @@ func (m *Manager) Load(ref *Reference) (*FileData, error) {
 
 	// Create FileData handle
 	fileData := NewFileData(path, ref.Name, ref.Size, ref.MimeType, ref.Hash)
+	fileData.key = m.key
+	fileData.filters = chain
+	fileData.verify = verifier
+
+	m.leases.acquire(path, fileData)
+	fileData.release = func() {
+		if m.leases.release(path, fileData) {
+			m.finishDelete(path)
+		}
+	}
 	return fileData, nil
 }
 
@@ -178,7 +353,10 @@ func (m *Manager) Exists(ref *Reference) bool {
 		return false
 	}
 
-	path := m.resolveRefPath(ref)
+	path, err := m.resolveRefPath(ref)
+	if err != nil {
+		return false
+	}
 	return fsutil.FileExists(path)
 }
 
@@ -191,15 +369,20 @@ func (m *Manager) Delete(ref *Reference) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	path := m.resolveRefPath(ref)
+	path, err := m.resolveRefPath(ref)
+	if err != nil {
+		return err
+	}
 
 	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to delete blob: %w", err)
 	}
+	m.leases.cancel(path)
+	m.uncache(path)
 
 	// Update hash index (use short hash)
 	if ref.Hash != "" {
-		shortHash := ShortHash(ref.Hash)
+		shortHash := m.indexKey(ref.Hash, ref.Filters)
 		delete(m.hashIndex, shortHash)
 	}
 
@@ -213,6 +396,61 @@ func (m *Manager) Delete(ref *Reference) error {
 	return nil
 }
 
+// DeleteFile removes a blob file found by ListAll. While FileData handles
+// from Load are reading it, the file is removed once the last one closes
+// and deferred reports true; handles unread for the lease timeout (see
+// SetLeaseTimeout) no longer hold it.
+func (m *Manager) DeleteFile(path string) (deferred bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.leases.hold(path) {
+		return true, nil
+	}
+	return false, m.removeFile(path)
+}
+
+// SetLeaseTimeout sets how long an open FileData that isn't read keeps its
+// file from being deleted (0 selects DefaultLeaseTimeout).
+func (m *Manager) SetLeaseTimeout(timeout time.Duration) {
+	m.leases.setTimeout(timeout)
+}
+
+// finishDelete removes a file whose delete waited for its readers, unless
+// its content was stored again meanwhile.
+func (m *Manager) finishDelete(path string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.leases.waiting(path) && !m.leases.hold(path) {
+		m.removeFile(path)
+	}
+}
+
+// removeFile deletes a blob file and its index entries (caller holds mu).
+func (m *Manager) removeFile(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+	m.uncache(path)
+
+	fileName := filepath.Base(path)
+	for hash, name := range m.hashIndex {
+		if name == fileName {
+			delete(m.hashIndex, hash)
+		}
+	}
+	m.removeFromIndex(m.extractCleanNameFromFileName(fileName), fileName)
+	return nil
+}
+
+// uncache drops the cached copy of a deleted blob file (caller holds mu).
+func (m *Manager) uncache(path string) {
+	if m.cache != nil {
+		m.cache.Remove(filepath.Join(m.cachePrefix, filepath.Base(path)))
+	}
+}
+
 // ListAll returns all blob files in the directory.
 func (m *Manager) ListAll() ([]string, error) {
 	files, err := fsutil.ListFiles(m.blobDir)
@@ -280,12 +518,23 @@ func (m *Manager) buildIndex() error {
 	return nil
 }
 
-// generateFileName generates a file name for a blob.
-// Format: {name}_{hash}.{ext} or {hash}.bin
-func (m *Manager) generateFileName(name, hash string) string {
-	shortHash := ShortHash(hash)
+// indexKey returns the hash index key of content with hash stored through
+// filterNames. For encrypted blobs it is opaque (see seal.Key.Name), so
+// file names reveal neither the content hash nor whether two namespaces
+// hold the same content.
+func (m *Manager) indexKey(hash string, filterNames []string) string {
+	key := indexKey(hash, filterNames)
+	if m.key != nil {
+		return m.key.Name(key)
+	}
+	return key
+}
 
-	if name == "" {
+// generateFileName generates a file name for a blob from its index key.
+// Format: {name}_{hash}.{ext} or {hash}.bin. Encrypted blobs are always
+// named {hash}.bin, keeping the original name out of the blob directory.
+func (m *Manager) generateFileName(name, shortHash string) string {
+	if name == "" || m.key != nil {
 		// No name specified, use hash only
 		return shortHash + ".bin"
 	}
@@ -294,8 +543,9 @@ func (m *Manager) generateFileName(name, hash string) string {
 	ext := filepath.Ext(name)
 	baseName := strings.TrimSuffix(name, ext)
 
-	// Sanitize base name (remove path separators and special characters)
+	// Sanitize base name and extension (remove path separators and special characters)
 	baseName = sanitizeFileName(baseName)
+	ext = sanitizeFileName(ext)
 
 	// Generate file name: {name}_{hash}{.ext}
 	if ext != "" {
@@ -348,14 +598,17 @@ func (m *Manager) extractHashFromFileName(fileName string) string {
 }
 
 // resolveRefPath resolves a reference to an absolute file path.
-func (m *Manager) resolveRefPath(ref *Reference) string {
+// References are untrusted (records can be edited by hand), so the result is
+// guaranteed to be a non-symlink file directly inside the blob directory.
+func (m *Manager) resolveRefPath(ref *Reference) (string, error) {
 	// ref.Location is like "_blobs/file_abc123.jpg"
 	// We need to convert it to absolute path
 
-	// Extract just the file name
-	fileName := filepath.Base(ref.Location)
+	// Extract just the file name (accepting either separator)
+	location := strings.ReplaceAll(ref.Location, "\\", "/")
+	fileName := location[strings.LastIndex(location, "/")+1:]
 
-	return filepath.Join(m.blobDir, fileName)
+	return fsutil.SafeJoin(m.blobDir, fileName)
 }
 
 // removeFromIndex removes a file name from the name index.
@@ -390,5 +643,6 @@ func sanitizeFileName(name string) string {
 		result = strings.ReplaceAll(result, char, "_")
 	}
 
-	return result
+	// NUL is never valid in file names
+	return strings.ReplaceAll(result, "\x00", "_")
 }
@@ -4,7 +4,11 @@ import (
 	"bytes"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/aigotowork/stow/internal/fsutil"
+	"github.com/aigotowork/stow/internal/seal"
 )
 
 // TestManagerTotalSize tests the TotalSize method
@@ -547,3 +551,190 @@ func TestManagerConcurrentStore(t *testing.T) {
 		t.Errorf("Count = %d, want 10 after concurrent stores", count)
 	}
 }
+
+// TestManagerRejectsUnsafeReferences verifies that hand-edited references can't
+// reach files outside the blob directory
+func TestManagerRejectsUnsafeReferences(t *testing.T) {
+	tmpDir := t.TempDir()
+	blobDir := filepath.Join(tmpDir, "_blobs")
+
+	manager, err := NewManager(blobDir, 1024*1024, 1024)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	secret := filepath.Join(tmpDir, "secret.txt")
+	os.WriteFile(secret, []byte("secret"), 0644)
+
+	for _, location := range []string{"_blobs/..", `_blobs\..`, ".."} {
+		ref := NewReference(location, "abc123", 6, "", "")
+		if _, err := manager.Load(ref); err == nil {
+			t.Errorf("Load(%q) should fail", location)
+		}
+		if err := manager.Delete(ref); err == nil {
+			t.Errorf("Delete(%q) should fail", location)
+		}
+	}
+
+	// Traversal is reduced to a name inside the blob directory
+	ref := NewReference("_blobs/../secret.txt", "abc123", 6, "", "")
+	if manager.Exists(ref) {
+		t.Error("traversing reference should not resolve outside the blob directory")
+	}
+
+	// Symlinks inside the blob directory are not followed
+	if err := os.Symlink(secret, filepath.Join(blobDir, "link_abc123.txt")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	ref = NewReference("_blobs/link_abc123.txt", "abc123", 6, "", "")
+	if _, err := manager.Load(ref); err == nil {
+		t.Error("Load should refuse symlinked blobs")
+	}
+}
+
+func FuzzGenerateFileName(f *testing.F) {
+	for _, seed := range []string{"avatar.jpg", "..", "../../etc/passwd", "a/b.c/../../x.evil", `x.\..\..\y`, "a\x00b.bin", "."} {
+		f.Add(seed)
+	}
+
+	manager, err := NewManager(filepath.Join(f.TempDir(), "_blobs"), 1024, 1024)
+	if err != nil {
+		f.Fatalf("NewManager failed: %v", err)
+	}
+
+	f.Fuzz(func(t *testing.T, name string) {
+		fileName := manager.generateFileName(name, "abcdef0123456789")
+		if !fsutil.IsSafeName(fileName) {
+			t.Errorf("generateFileName(%q) = %q is not a plain file name", name, fileName)
+		}
+	})
+}
+
+// TestManagerMixedHashes verifies blobs hashed with different algorithms
+// coexist in one directory
+func TestManagerMixedHashes(t *testing.T) {
+	blobDir := filepath.Join(t.TempDir(), "_blobs")
+
+	manager, err := NewManager(blobDir, 1024*1024, 1024)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	shaRef, err := manager.Store([]byte("sha content"), "a.txt", "text/plain")
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if shaRef.Algo != "" || shaRef.Hash != ComputeSHA256FromBytes([]byte("sha content")) {
+		t.Errorf("unexpected SHA-256 reference: %+v", shaRef)
+	}
+
+	manager.SetHasher(BLAKE3)
+	b3Ref, err := manager.Store([]byte("blake3 content"), "b.txt", "text/plain")
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if b3Ref.Algo != HashBLAKE3 {
+		t.Errorf("Algo = %q, want %q", b3Ref.Algo, HashBLAKE3)
+	}
+
+	want, _ := ComputeHash(BLAKE3, bytes.NewReader([]byte("blake3 content")))
+	if b3Ref.Hash != want {
+		t.Errorf("Hash = %s, want %s", b3Ref.Hash, want)
+	}
+
+	// Both remain readable and verifiable after reopening
+	manager, err = NewManager(blobDir, 1024*1024, 1024)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	for _, ref := range []*Reference{shaRef, b3Ref} {
+		if _, err := manager.LoadBytes(ref); err != nil {
+			t.Errorf("LoadBytes(%s) failed: %v", ref.Location, err)
+		}
+		if err := manager.Verify(ref); err != nil {
+			t.Errorf("Verify(%s) failed: %v", ref.Location, err)
+		}
+	}
+
+	// Tampering is detected
+	os.WriteFile(filepath.Join(blobDir, filepath.Base(b3Ref.Location)), []byte("tampered"), 0644)
+	if err := manager.Verify(b3Ref); err == nil {
+		t.Error("Verify should detect modified content")
+	}
+}
+
+// TestManagerEncryptedBlobs verifies blob files are encrypted at rest
+// while references keep the plain content hash and size
+func TestManagerEncryptedBlobs(t *testing.T) {
+	blobDir := filepath.Join(t.TempDir(), "_blobs")
+
+	manager, err := NewManager(blobDir, 1024*1024, 1024)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	key, err := seal.NewKey(bytes.Repeat([]byte{7}, seal.KeySize))
+	if err != nil {
+		t.Fatalf("NewKey failed: %v", err)
+	}
+	manager.SetKey(key)
+
+	content := bytes.Repeat([]byte("secret blob "), 500)
+	for _, concurrency := range []int{1, 4} {
+		manager.SetWriteConcurrency(concurrency)
+
+		ref, err := manager.Store(append(content, byte(concurrency)), "doc.txt", "text/plain")
+		if err != nil {
+			t.Fatalf("Store failed: %v", err)
+		}
+		want := append(content, byte(concurrency))
+		if ref.Size != int64(len(want)) || ref.Hash != ComputeSHA256FromBytes(want) {
+			t.Errorf("reference should describe the plain content: size=%d hash=%s", ref.Size, ref.Hash)
+		}
+
+		raw, _ := os.ReadFile(filepath.Join(blobDir, filepath.Base(ref.Location)))
+		if bytes.Contains(raw, []byte("secret blob")) {
+			t.Error("blob file contains plaintext")
+		}
+
+		got, err := manager.LoadBytes(ref)
+		if err != nil {
+			t.Fatalf("LoadBytes failed: %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Error("decrypted content mismatch")
+		}
+		if err := manager.Verify(ref); err != nil {
+			t.Errorf("Verify failed: %v", err)
+		}
+
+		// File names reveal neither the name nor the content hash
+		fileName := filepath.Base(ref.Location)
+		if strings.Contains(fileName, "doc") || strings.Contains(fileName, ShortHash(ref.Hash)) {
+			t.Errorf("blob file name %q leaks the blob", fileName)
+		}
+
+		// Tampered ciphertext fails the read instead of returning garbage
+		path := filepath.Join(blobDir, fileName)
+		raw[len(raw)-1] ^= 1
+		os.WriteFile(path, raw, 0644)
+		if _, err := manager.LoadBytes(ref); err == nil {
+			t.Error("LoadBytes should fail for tampered content")
+		}
+		raw[len(raw)-1] ^= 1
+		os.WriteFile(path, raw, 0644)
+	}
+
+	// Content is still deduplicated after a restart
+	reopened, err := NewManager(blobDir, 1024*1024, 1024)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	reopened.SetKey(key)
+	before, _ := reopened.Count()
+	if _, err := reopened.Store(append(content, 1), "other.txt", "text/plain"); err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+	if after, _ := reopened.Count(); after != before {
+		t.Errorf("blob count %d -> %d, want the same content reused", before, after)
+	}
+}
@@ -0,0 +1,126 @@
+package blob
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// maxPipelineBuffer caps adaptive buffer growth in pipelined writes.
+const maxPipelineBuffer = 1024 * 1024
+
+// pipeChunk is a buffer in flight between the reader, the hasher and the file writer.
+type pipeChunk struct {
+	buf     []byte
+	n       int
+	pending int32
+}
+
+// writeFromPipelined streams r into the file while hashing on a separate goroutine.
+//
+// Reading, hashing and writing overlap: up to w.concurrency buffers are in flight,
+// and each buffer returns to the pool once both the hasher and the file writer
+// are done with it. Buffers start at the chunk size and double (up to 1MB) while
+// the reader keeps filling them, so large streams use fewer, larger writes.
+func (w *Writer) writeFromPipelined(r io.Reader) error {
+	free := make(chan []byte, w.concurrency)
+	hashCh := make(chan *pipeChunk, w.concurrency)
+	fileCh := make(chan *pipeChunk, w.concurrency)
+
+	release := func(c *pipeChunk) {
+		if atomic.AddInt32(&c.pending, -1) == 0 {
+			free <- c.buf
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		for c := range hashCh {
+			w.hash.Write(c.buf[:c.n])
+			release(c)
+		}
+	}()
+
+	var writeErr error
+	failed := make(chan struct{})
+	go func() {
+		defer wg.Done()
+		for c := range fileCh {
+			if writeErr == nil {
+				if _, err := w.out.Write(c.buf[:c.n]); err != nil {
+					writeErr = fmt.Errorf("failed to write to file: %w", err)
+					close(failed)
+				}
+			}
+			release(c)
+		}
+	}()
+
+	bufSize := int(w.chunkSize)
+	allocated := 0
+
+	readErr := func() error {
+		for {
+			select {
+			case <-failed:
+				return nil // reported via writeErr
+			default:
+			}
+
+			// Take a free buffer, allocating until the pool is full
+			var buf []byte
+			if allocated < w.concurrency {
+				allocated++
+			} else {
+				buf = <-free
+			}
+			if cap(buf) < bufSize {
+				buf = make([]byte, bufSize)
+			}
+			buf = buf[:bufSize]
+
+			n, err := io.ReadFull(r, buf)
+			if n > 0 {
+				if w.maxSize > 0 && w.written+int64(n) > w.maxSize {
+					return fmt.Errorf("file size exceeds limit of %d bytes", w.maxSize)
+				}
+				w.written += int64(n)
+
+				c := &pipeChunk{buf: buf, n: n, pending: 2}
+				hashCh <- c
+				fileCh <- c
+			} else {
+				free <- buf
+			}
+
+			if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read from source: %w", err)
+			}
+
+			// The reader keeps up, so grow the buffer
+			if bufSize < maxPipelineBuffer {
+				bufSize *= 2
+				if bufSize > maxPipelineBuffer {
+					bufSize = maxPipelineBuffer
+				}
+			}
+		}
+	}()
+
+	close(hashCh)
+	close(fileCh)
+	wg.Wait()
+
+	if readErr != nil {
+		return readErr
+	}
+	return writeErr
+}
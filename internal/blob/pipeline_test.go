@@ -0,0 +1,130 @@
+package blob
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+)
+
+// TestWriteFromPipelined checks that pipelined writes produce the same file and
+// hash as sequential ones across buffer boundaries
+func TestWriteFromPipelined(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	data := make([]byte, 3*1024*1024+17)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	sum := sha256.Sum256(data)
+	want := hex.EncodeToString(sum[:])
+
+	readers := map[string]func() io.Reader{
+		"bytes":    func() io.Reader { return bytes.NewReader(data) },
+		"one byte": func() io.Reader { return iotest.OneByteReader(bytes.NewReader(data[:5000])) },
+		"half":     func() io.Reader { return iotest.HalfReader(bytes.NewReader(data)) },
+	}
+
+	for name, newReader := range readers {
+		for _, concurrency := range []int{2, 4, 8} {
+			path := filepath.Join(tmpDir, name+".bin")
+
+			writer, err := NewWriter(path, 0, 1024)
+			if err != nil {
+				t.Fatalf("NewWriter failed: %v", err)
+			}
+			writer.SetConcurrency(concurrency)
+
+			if err := writer.WriteFrom(newReader()); err != nil {
+				t.Fatalf("%s/%d: WriteFrom failed: %v", name, concurrency, err)
+			}
+
+			hash, size, err := writer.Close()
+			if err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+
+			expected := data
+			if name == "one byte" {
+				expected = data[:5000]
+			}
+			content, _ := os.ReadFile(path)
+			if !bytes.Equal(content, expected) || size != int64(len(expected)) {
+				t.Errorf("%s/%d: content mismatch (size %d)", name, concurrency, size)
+			}
+
+			if name != "one byte" && hash != want {
+				t.Errorf("%s/%d: hash = %s, want %s", name, concurrency, hash, want)
+			}
+		}
+	}
+}
+
+func TestWriteFromPipelinedErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	t.Run("size limit", func(t *testing.T) {
+		writer, err := NewWriter(filepath.Join(tmpDir, "limit.bin"), 4096, 1024)
+		if err != nil {
+			t.Fatalf("NewWriter failed: %v", err)
+		}
+		defer writer.Abort()
+		writer.SetConcurrency(4)
+
+		if err := writer.WriteFrom(bytes.NewReader(make([]byte, 10000))); err == nil {
+			t.Error("WriteFrom should enforce the size limit")
+		}
+	})
+
+	t.Run("failing reader", func(t *testing.T) {
+		writer, err := NewWriter(filepath.Join(tmpDir, "fail.bin"), 0, 1024)
+		if err != nil {
+			t.Fatalf("NewWriter failed: %v", err)
+		}
+		defer writer.Abort()
+		writer.SetConcurrency(4)
+
+		reader := io.MultiReader(bytes.NewReader(make([]byte, 3000)), iotest.ErrReader(io.ErrClosedPipe))
+		if err := writer.WriteFrom(reader); err == nil {
+			t.Error("WriteFrom should fail with failing reader")
+		}
+		if writer.Written() != 3000 {
+			t.Errorf("Written() = %d, want 3000", writer.Written())
+		}
+	})
+
+	t.Run("failing file", func(t *testing.T) {
+		writer, err := NewWriter(filepath.Join(tmpDir, "closed.bin"), 0, 1024)
+		if err != nil {
+			t.Fatalf("NewWriter failed: %v", err)
+		}
+		writer.SetConcurrency(4)
+		writer.file.Close()
+
+		if err := writer.WriteFrom(bytes.NewReader(make([]byte, 100000))); err == nil {
+			t.Error("WriteFrom should report file write errors")
+		}
+	})
+}
+
+// BenchmarkWriterWriteFromPipelined benchmarks pipelined WriteFrom performance
+func BenchmarkWriterWriteFromPipelined(b *testing.B) {
+	tmpDir := b.TempDir()
+	testFile := filepath.Join(tmpDir, "bench_pipelined.bin")
+
+	data := bytes.Repeat([]byte("x"), 16*1024*1024) // 16MB
+	b.SetBytes(int64(len(data)))
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		writer, _ := NewWriter(testFile, 0, 64*1024)
+		writer.SetConcurrency(4)
+		writer.WriteFrom(bytes.NewReader(data))
+		writer.Close()
+	}
+}
@@ -22,9 +22,12 @@ type Reference struct {
 	// Location is the relative path to the blob file (e.g., "_blobs/file_abc123.jpg")
 	Location string `json:"loc"`
 
-	// Hash is the SHA256 hash of the file content
+	// Hash is the hex-encoded hash of the file content
 	Hash string `json:"hash"`
 
+	// Algo is the hash algorithm of Hash. Empty means HashSHA256.
+	Algo string `json:"algo,omitempty"`
+
 	// Size is the file size in bytes
 	Size int64 `json:"size"`
 
@@ -33,8 +36,23 @@ type Reference struct {
 
 	// Name is the original file name (e.g., "avatar.jpg")
 	Name string `json:"name,omitempty"`
+
+	// Filters is the filter chain the file was written with, first filter
+	// first (see Filter). Empty for unfiltered files.
+	Filters []string `json:"filters,omitempty"`
+
+	// Kind describes how the blob content maps back to a value.
+	// Empty for raw bytes, KindJSON for a spilled JSON subtree,
+	// KindRawJSON for a json.RawMessage kept byte for byte.
+	Kind string `json:"kind,omitempty"`
 }
 
+// KindJSON marks a blob holding the JSON encoding of a nested map or slice.
+const KindJSON = "json"
+
+// KindRawJSON marks a blob holding a json.RawMessage verbatim.
+const KindRawJSON = "rawjson"
+
 // NewReference creates a new blob reference.
 func NewReference(location, hash string, size int64, mimeType, name string) *Reference {
 	return &Reference{
@@ -77,7 +95,10 @@ func FromMap(data map[string]interface{}) (*Reference, bool) {
 		ref.Hash = hash
 	}
 
-	if size, ok := data["size"].(float64); ok {
+	switch size := data["size"].(type) {
+	case int64:
+		ref.Size = size
+	case float64:
 		ref.Size = int64(size)
 	}
 
@@ -89,6 +110,25 @@ func FromMap(data map[string]interface{}) (*Reference, bool) {
 		ref.Name = name
 	}
 
+	if kind, ok := data["kind"].(string); ok {
+		ref.Kind = kind
+	}
+
+	if algo, ok := data["algo"].(string); ok {
+		ref.Algo = algo
+	}
+
+	switch filters := data["filters"].(type) {
+	case []string:
+		ref.Filters = filters
+	case []interface{}:
+		for _, name := range filters {
+			if s, ok := name.(string); ok {
+				ref.Filters = append(ref.Filters, s)
+			}
+		}
+	}
+
 	if !ref.IsValid() {
 		return nil, false
 	}
@@ -113,5 +153,17 @@ func (r *Reference) ToMap() map[string]interface{} {
 		m["name"] = r.Name
 	}
 
+	if r.Kind != "" {
+		m["kind"] = r.Kind
+	}
+
+	if r.Algo != "" {
+		m["algo"] = r.Algo
+	}
+
+	if len(r.Filters) > 0 {
+		m["filters"] = r.Filters
+	}
+
 	return m
 }
@@ -602,3 +602,41 @@ func TestNewReference(t *testing.T) {
 		t.Error("NewReference should create valid reference")
 	}
 }
+
+func TestReferenceKindRoundTrip(t *testing.T) {
+	ref := NewReference("_blobs/meta_abc123.json", "abc123", 42, "application/json", "meta.json")
+	ref.Kind = KindJSON
+
+	restored, ok := FromMap(ref.ToMap())
+	if !ok {
+		t.Fatal("FromMap failed")
+	}
+	if restored.Kind != KindJSON {
+		t.Errorf("Kind = %q, want %q", restored.Kind, KindJSON)
+	}
+
+	// Plain references omit the kind field
+	plain := NewReference("_blobs/a.bin", "abc", 1, "", "")
+	if _, exists := plain.ToMap()["kind"]; exists {
+		t.Error("empty kind should be omitted")
+	}
+}
+
+func TestReferenceAlgoRoundTrip(t *testing.T) {
+	ref := NewReference("_blobs/a_abc123.bin", "abc123", 42, "", "")
+	ref.Algo = HashBLAKE3
+
+	restored, ok := FromMap(ref.ToMap())
+	if !ok {
+		t.Fatal("FromMap failed")
+	}
+	if restored.Algo != HashBLAKE3 {
+		t.Errorf("Algo = %q, want %q", restored.Algo, HashBLAKE3)
+	}
+
+	// SHA-256 references omit the algorithm, as before it was recorded
+	plain := NewReference("_blobs/a.bin", "abc", 1, "", "")
+	if _, exists := plain.ToMap()["algo"]; exists {
+		t.Error("empty algo should be omitted")
+	}
+}
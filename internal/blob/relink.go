@@ -0,0 +1,104 @@
+package blob
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aigotowork/stow/internal/fsutil"
+)
+
+// HashFile computes the content hash of a blob file anywhere on disk with
+// the algorithm of ref, decrypting and decoding it like ref's blob.
+func (m *Manager) HashFile(path string, ref *Reference) (string, error) {
+	h, ok := HasherByName(ref.Algo)
+	if !ok {
+		return "", fmt.Errorf("unknown blob hash algorithm: %s", ref.Algo)
+	}
+	chain, err := FiltersByName(ref.Filters)
+	if err != nil {
+		return "", err
+	}
+
+	fileData := NewFileData(path, "", 0, "", "")
+	fileData.key = m.key
+	fileData.filters = chain
+	defer fileData.Close()
+
+	return ComputeHash(h, fileData)
+}
+
+// Relink brings back a blob file that was moved out of place, found at path,
+// and returns ref pointing at it. The file must hash to ref.Hash. It is moved
+// into the blob directory if move is set and copied otherwise. The original
+// file name is kept when free, so the location often doesn't change.
+func (m *Manager) Relink(ref *Reference, path string, move bool) (*Reference, error) {
+	hash, err := m.HashFile(path, ref)
+	if err != nil {
+		return nil, err
+	}
+	if hash != ref.Hash {
+		return nil, fmt.Errorf("blob hash mismatch for %s: got %s, want %s", path, hash, ref.Hash)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	shortHash := m.indexKey(ref.Hash, ref.Filters)
+	fileName, exists := m.hashIndex[shortHash]
+	if !exists || !fsutil.FileExists(filepath.Join(m.blobDir, fileName)) {
+		fileName = filepath.Base(ref.Location)
+		if target, err := fsutil.SafeJoin(m.blobDir, fileName); err != nil || fsutil.FileExists(target) {
+			fileName = m.generateFileName(ref.Name, shortHash)
+		}
+
+		finalPath := filepath.Join(m.blobDir, fileName)
+		if move {
+			err = fsutil.AtomicReplace(path, finalPath)
+		} else {
+			err = copyBlobFile(path, finalPath)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to relink blob: %w", err)
+		}
+
+		m.hashIndex[shortHash] = fileName
+		if ref.Name != "" && m.key == nil {
+			cleanName := m.extractCleanName(ref.Name)
+			m.nameIndex[cleanName] = append(m.nameIndex[cleanName], fileName)
+		}
+	}
+
+	relinked := *ref
+	relinked.Location = filepath.Join("_blobs", fileName)
+	return &relinked, nil
+}
+
+// copyBlobFile copies a blob file as is (still sealed, if encrypted).
+func copyBlobFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	// Temporary files are skipped by the blob index and listing
+	tmpPath := filepath.Join(filepath.Dir(dst), "tmp_"+filepath.Base(dst))
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return fsutil.AtomicReplace(tmpPath, dst)
+}
@@ -0,0 +1,48 @@
+package blob
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestManagerRelink(t *testing.T) {
+	blobDir := filepath.Join(t.TempDir(), "_blobs")
+	manager, err := NewManager(blobDir, 1024*1024, 1024)
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+
+	ref, err := manager.Store([]byte("avatar bytes"), "avatar.png", "image/png")
+	if err != nil {
+		t.Fatalf("Store failed: %v", err)
+	}
+
+	// Move the file into a subdirectory
+	archived := filepath.Join(blobDir, "archive", filepath.Base(ref.Location))
+	os.MkdirAll(filepath.Dir(archived), 0755)
+	if err := os.Rename(filepath.Join(blobDir, filepath.Base(ref.Location)), archived); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if manager.Exists(ref) {
+		t.Fatal("moved blob should not resolve")
+	}
+
+	relinked, err := manager.Relink(ref, archived, true)
+	if err != nil {
+		t.Fatalf("Relink failed: %v", err)
+	}
+	if relinked.Location != ref.Location || !manager.Exists(relinked) {
+		t.Errorf("relinked location = %s, want %s", relinked.Location, ref.Location)
+	}
+	if _, err := os.Stat(archived); !os.IsNotExist(err) {
+		t.Error("moved file should have left the subdirectory")
+	}
+
+	// Wrong content is refused
+	other := filepath.Join(t.TempDir(), "other.png")
+	os.WriteFile(other, []byte("something else"), 0644)
+	if _, err := manager.Relink(ref, other, false); err == nil {
+		t.Error("Relink should refuse a file with another hash")
+	}
+}
@@ -6,16 +6,25 @@ import (
 	"hash"
 	"io"
 	"os"
+
+	"github.com/aigotowork/stow/internal/seal"
 )
 
 // Writer is a chunked writer that writes data in chunks and computes hash simultaneously.
 // It also enforces a maximum file size limit.
 type Writer struct {
 	file      *os.File
+	out       io.Writer // file, or filters and encryption over it
 	hash      hash.Hash
 	written   int64
 	maxSize   int64
 	chunkSize int64
+
+	// concurrency is the number of buffers in flight in WriteFrom (1 = sequential)
+	concurrency int
+
+	// filters flush the filter chain set by SetFilters, first filter first
+	filters []io.Closer
 }
 
 // NewWriter creates a new chunked writer.
@@ -25,17 +34,24 @@ type Writer struct {
 //   - maxSize: maximum file size in bytes (0 for unlimited)
 //   - chunkSize: chunk size for writing (typically 64KB)
 func NewWriter(path string, maxSize, chunkSize int64) (*Writer, error) {
+	return NewWriterWithHash(path, maxSize, chunkSize, sha256.New())
+}
+
+// NewWriterWithHash creates a new chunked writer computing the given hash.
+func NewWriterWithHash(path string, maxSize, chunkSize int64, h hash.Hash) (*Writer, error) {
 	f, err := os.Create(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create file: %w", err)
 	}
 
 	return &Writer{
-		file:      f,
-		hash:      sha256.New(),
-		written:   0,
-		maxSize:   maxSize,
-		chunkSize: chunkSize,
+		file:        f,
+		out:         f,
+		hash:        h,
+		written:     0,
+		maxSize:     maxSize,
+		chunkSize:   chunkSize,
+		concurrency: 1,
 	}, nil
 }
 
@@ -48,7 +64,7 @@ func (w *Writer) Write(p []byte) (int, error) {
 	}
 
 	// Write to file
-	n, err := w.file.Write(p)
+	n, err := w.out.Write(p)
 	if err != nil {
 		return n, fmt.Errorf("failed to write to file: %w", err)
 	}
@@ -60,9 +76,47 @@ func (w *Writer) Write(p []byte) (int, error) {
 	return n, nil
 }
 
+// SetKey encrypts everything written from now on with key.
+// Must be called before the first write; the hash and size limit
+// still apply to the plain content.
+func (w *Writer) SetKey(key *seal.Key) error {
+	out, err := key.EncryptWriter(w.file)
+	if err != nil {
+		return fmt.Errorf("failed to start encryption: %w", err)
+	}
+	w.out = out
+	return nil
+}
+
+// SetFilters passes everything written from now on through chain, first
+// filter first. Must be called before the first write and after SetKey;
+// the hash and size limit still apply to the original content.
+func (w *Writer) SetFilters(chain []Filter) error {
+	out, closers, err := encodeChain(w.out, chain)
+	if err != nil {
+		return err
+	}
+	w.out = out
+	w.filters = closers
+	return nil
+}
+
+// SetConcurrency sets the number of buffers WriteFrom keeps in flight.
+// Values above 1 hash and write concurrently; 1 (the default) is sequential.
+func (w *Writer) SetConcurrency(n int) {
+	if n < 1 {
+		n = 1
+	}
+	w.concurrency = n
+}
+
 // WriteFrom reads from a reader and writes to the file in chunks.
 // This is more efficient than using io.Copy for large files.
 func (w *Writer) WriteFrom(r io.Reader) error {
+	if w.concurrency > 1 {
+		return w.writeFromPipelined(r)
+	}
+
 	buf := make([]byte, w.chunkSize)
 
 	for {
@@ -88,6 +142,12 @@ func (w *Writer) WriteFrom(r io.Reader) error {
 // Close closes the writer and returns the final hash.
 // It syncs the file to disk before closing.
 func (w *Writer) Close() (string, int64, error) {
+	// Flush the filters
+	if err := closeAll(w.filters); err != nil {
+		w.file.Close()
+		return "", 0, fmt.Errorf("failed to flush blob filters: %w", err)
+	}
+
 	// Sync to disk
 	if err := w.file.Sync(); err != nil {
 		w.file.Close()
@@ -0,0 +1,23 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// CanonicalJSON encodes value in a canonical JSON form so that data produced by
+// the marshaler and data decoded from disk compare equal when they hold the same
+// values (e.g. int vs float64, structs vs maps). Map keys are sorted.
+func CanonicalJSON(value interface{}) ([]byte, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode value: %w", err)
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(encoded, &generic); err != nil {
+		return nil, fmt.Errorf("failed to decode value: %w", err)
+	}
+
+	return json.Marshal(generic)
+}
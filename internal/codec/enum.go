@@ -0,0 +1,96 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidEnum is returned when an enum field holds a value outside its set.
+var ErrInvalidEnum = errors.New("invalid enum value")
+
+// Enum is implemented by string types with a fixed set of values. Fields of
+// such types are checked on write and read like fields tagged
+// `stow:"enum:a|b|c"`; a tag on the field takes precedence.
+type Enum interface {
+	EnumValues() []string
+}
+
+var enumType = reflect.TypeOf((*Enum)(nil)).Elem()
+
+// enumValues returns the allowed values of a struct field: those of its
+// enum tag option, or the EnumValues of its string type.
+func enumValues(field reflect.StructField, info TagInfo) ([]string, bool) {
+	if len(info.Enum) > 0 {
+		return info.Enum, true
+	}
+
+	t := field.Type
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.String {
+		return nil, false
+	}
+	if t.Implements(enumType) {
+		return reflect.Zero(t).Interface().(Enum).EnumValues(), true
+	}
+	if reflect.PointerTo(t).Implements(enumType) {
+		return reflect.New(t).Interface().(Enum).EnumValues(), true
+	}
+	return nil, false
+}
+
+// checkEnum checks the string held by v against values. Nil pointers are
+// valid: the field is unset.
+func checkEnum(name string, v reflect.Value, values []string) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.String {
+		return fmt.Errorf("%w: field %s is %s, not a string", ErrInvalidEnum, name, v.Type())
+	}
+	if !slices.Contains(values, v.String()) {
+		return fmt.Errorf("%w: field %s is %q, want one of %s", ErrInvalidEnum, name, v.String(), strings.Join(values, ", "))
+	}
+	return nil
+}
+
+// ValidateEnums checks the enum fields of a struct value (or pointer to
+// one). Other values have no enum fields.
+func ValidateEnums(value interface{}) error {
+	val := reflect.ValueOf(value)
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+	if val.Kind() != reflect.Struct {
+		return nil
+	}
+
+	typ := val.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		fieldType := typ.Field(i)
+		if !fieldType.IsExported() {
+			continue
+		}
+
+		values, ok := enumValues(fieldType, ParseStowTag(fieldType.Tag.Get("stow")))
+		if !ok {
+			continue
+		}
+		if err := checkEnum(getFieldName(fieldType), val.Field(i), values); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
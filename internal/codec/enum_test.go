@@ -0,0 +1,96 @@
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/aigotowork/stow/internal/blob"
+)
+
+type status string
+
+func (status) EnumValues() []string {
+	return []string{"draft", "published"}
+}
+
+type article struct {
+	Status   status  `json:"status"`
+	Previous *status `json:"previous"`
+	Priority string  `json:"priority" stow:"enum:low|high"`
+}
+
+func TestParseStowTagEnum(t *testing.T) {
+	info := ParseStowTag("enum:draft|published|archived")
+	if len(info.Enum) != 3 || info.Enum[2] != "archived" {
+		t.Errorf("Enum = %v, want [draft published archived]", info.Enum)
+	}
+	if info.IsEmpty() {
+		t.Error("IsEmpty() = true for an enum tag")
+	}
+}
+
+func TestValidateEnums(t *testing.T) {
+	bad := status("deleted")
+	tests := []struct {
+		name  string
+		value article
+		ok    bool
+	}{
+		{"valid", article{Status: "draft", Priority: "low"}, true},
+		{"nil pointer", article{Status: "published", Priority: "high"}, true},
+		{"bad interface value", article{Status: "deleted", Priority: "low"}, false},
+		{"bad pointer value", article{Status: "draft", Previous: &bad, Priority: "low"}, false},
+		{"bad tag value", article{Status: "draft", Priority: "urgent"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateEnums(&tt.value)
+			if tt.ok && err != nil {
+				t.Errorf("ValidateEnums() = %v, want nil", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidEnum) {
+				t.Errorf("ValidateEnums() = %v, want ErrInvalidEnum", err)
+			}
+		})
+	}
+}
+
+func TestEnumRoundTrip(t *testing.T) {
+	bm, err := blob.NewManager(filepath.Join(t.TempDir(), "_blobs"), 1024*1024, 1024)
+	if err != nil {
+		t.Fatalf("failed to create blob manager: %v", err)
+	}
+	marshaler := NewMarshaler(bm)
+	unmarshaler := NewUnmarshaler(bm)
+
+	if _, _, err := marshaler.Marshal(article{Status: "gone", Priority: "low"}, MarshalOptions{}); !errors.Is(err, ErrInvalidEnum) {
+		t.Fatalf("Marshal() = %v, want ErrInvalidEnum", err)
+	}
+
+	marshaled, _, err := marshaler.Marshal(article{Status: "published", Priority: "high"}, MarshalOptions{})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	// Records are read back from JSON
+	encoded, _ := json.Marshal(marshaled)
+	var data map[string]interface{}
+	if err := json.Unmarshal(encoded, &data); err != nil {
+		t.Fatal(err)
+	}
+	var out article
+	if err := unmarshaler.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if out.Status != "published" || out.Priority != "high" {
+		t.Errorf("got %+v", out)
+	}
+
+	data["priority"] = "urgent"
+	if err := unmarshaler.Unmarshal(data, &out); !errors.Is(err, ErrInvalidEnum) {
+		t.Errorf("Unmarshal() = %v, want ErrInvalidEnum", err)
+	}
+}
@@ -0,0 +1,195 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// ErrNonFiniteFloat is returned when a value holds NaN or ±Inf and the
+// namespace doesn't store them as sentinels.
+var ErrNonFiniteFloat = errors.New("non-finite float")
+
+// floatValueKey wraps a NaN or ±Inf value, which JSON can't represent:
+//
+//	{"reading": {"$float": "NaN"}, "peak": {"$float": "+Inf"}}
+const floatValueKey = "$float"
+
+// wrapFloat returns the sentinel form of a non-finite float.
+func wrapFloat(f float64) map[string]interface{} {
+	return map[string]interface{}{floatValueKey: strconv.FormatFloat(f, 'g', -1, 64)}
+}
+
+// unwrapFloat returns the float held by a sentinel.
+func unwrapFloat(value interface{}) (float64, bool) {
+	m, ok := value.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return 0, false
+	}
+	s, ok := m[floatValueKey].(string)
+	if !ok {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || !isNonFinite(f) {
+		return 0, false
+	}
+	return f, true
+}
+
+func isNonFinite(f float64) bool {
+	return math.IsNaN(f) || math.IsInf(f, 0)
+}
+
+// encodeNonFinite finds NaN and ±Inf floats anywhere in value. With
+// sentinels they are replaced by their sentinel form (containers holding
+// one are copied, never modified), otherwise the first one found is
+// returned as ErrNonFiniteFloat. The bool reports whether value changed.
+func encodeNonFinite(path string, value interface{}, sentinels bool) (interface{}, bool, error) {
+	if value == nil {
+		return nil, false, nil
+	}
+
+	val := reflect.ValueOf(value)
+	switch val.Kind() {
+	case reflect.Float32, reflect.Float64:
+		f := val.Float()
+		if !isNonFinite(f) {
+			return value, false, nil
+		}
+		if !sentinels {
+			return nil, false, fmt.Errorf("%w: %s is %v", ErrNonFiniteFloat, path, f)
+		}
+		return wrapFloat(f), true, nil
+
+	case reflect.Ptr, reflect.Interface:
+		if val.IsNil() {
+			return value, false, nil
+		}
+		return encodeNonFinite(path, val.Elem().Interface(), sentinels)
+
+	case reflect.Slice, reflect.Array:
+		if val.Type().Elem().Kind() == reflect.Uint8 {
+			return value, false, nil
+		}
+		var out []interface{}
+		for i := 0; i < val.Len(); i++ {
+			elem := val.Index(i).Interface()
+			encoded, changed, err := encodeNonFinite(fmt.Sprintf("%s[%d]", path, i), elem, sentinels)
+			if err != nil {
+				return nil, false, err
+			}
+			if changed && out == nil {
+				out = make([]interface{}, val.Len())
+				for j := 0; j < i; j++ {
+					out[j] = val.Index(j).Interface()
+				}
+			}
+			if out != nil {
+				out[i] = encoded
+			}
+		}
+		if out == nil {
+			return value, false, nil
+		}
+		return out, true, nil
+
+	case reflect.Map:
+		if val.Type().Key().Kind() != reflect.String {
+			return value, false, nil
+		}
+		var out map[string]interface{}
+		iter := val.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			encoded, changed, err := encodeNonFinite(path+"."+key, iter.Value().Interface(), sentinels)
+			if err != nil {
+				return nil, false, err
+			}
+			if changed && out == nil {
+				out = make(map[string]interface{}, val.Len())
+				for _, k := range val.MapKeys() {
+					out[k.String()] = val.MapIndex(k).Interface()
+				}
+			}
+			if out != nil {
+				out[key] = encoded
+			}
+		}
+		if out == nil {
+			return value, false, nil
+		}
+		return out, true, nil
+
+	case reflect.Struct:
+		if isTimeType(value) {
+			return value, false, nil
+		}
+		fields, err := ToMap(value)
+		if err != nil {
+			return value, false, nil
+		}
+		encoded, changed, err := encodeNonFinite(path, fields, sentinels)
+		if err != nil || !changed {
+			return value, false, err
+		}
+		return encoded, true, nil
+	}
+
+	return value, false, nil
+}
+
+// restoreFloats replaces the sentinels in decoded JSON by the floats they
+// hold. Containers holding one are copied, never modified, since decoded
+// records may be cached.
+func restoreFloats(value interface{}) interface{} {
+	restored, _ := restoreNonFinite(value)
+	return restored
+}
+
+func restoreNonFinite(value interface{}) (interface{}, bool) {
+	if f, ok := unwrapFloat(value); ok {
+		return f, true
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		var out map[string]interface{}
+		for key, elem := range v {
+			restored, changed := restoreNonFinite(elem)
+			if !changed {
+				continue
+			}
+			if out == nil {
+				out = make(map[string]interface{}, len(v))
+				for k, e := range v {
+					out[k] = e
+				}
+			}
+			out[key] = restored
+		}
+		if out != nil {
+			return out, true
+		}
+
+	case []interface{}:
+		var out []interface{}
+		for i, elem := range v {
+			restored, changed := restoreNonFinite(elem)
+			if !changed {
+				continue
+			}
+			if out == nil {
+				out = append([]interface{}(nil), v...)
+			}
+			out[i] = restored
+		}
+		if out != nil {
+			return out, true
+		}
+	}
+
+	return value, false
+}
@@ -0,0 +1,94 @@
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/aigotowork/stow/internal/blob"
+)
+
+type measurement struct {
+	Value    float64            `json:"value"`
+	Peak     *float32           `json:"peak"`
+	Series   []float64          `json:"series"`
+	Extra    interface{}        `json:"extra"`
+	Readings map[string]float64 `json:"readings"`
+}
+
+func TestMarshalNonFinite(t *testing.T) {
+	bm, err := blob.NewManager(filepath.Join(t.TempDir(), "_blobs"), 1024*1024, 1024)
+	if err != nil {
+		t.Fatalf("failed to create blob manager: %v", err)
+	}
+	marshaler := NewMarshaler(bm)
+	unmarshaler := NewUnmarshaler(bm)
+
+	peak := float32(math.Inf(1))
+	series := []float64{1.5, math.NaN(), math.Inf(-1)}
+	in := measurement{
+		Value:    math.NaN(),
+		Peak:     &peak,
+		Series:   series,
+		Extra:    map[string]interface{}{"low": math.Inf(-1)},
+		Readings: map[string]float64{"a": 2, "b": math.NaN()},
+	}
+
+	_, _, err = marshaler.Marshal(in, MarshalOptions{BlobThreshold: 1024})
+	if !errors.Is(err, ErrNonFiniteFloat) {
+		t.Fatalf("Marshal() = %v, want ErrNonFiniteFloat", err)
+	}
+
+	marshaled, _, err := marshaler.Marshal(in, MarshalOptions{BlobThreshold: 1024, NonFiniteSentinels: true})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !math.IsNaN(series[1]) {
+		t.Error("Marshal modified the input slice")
+	}
+
+	encoded, err := json.Marshal(marshaled)
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(encoded, &data); err != nil {
+		t.Fatal(err)
+	}
+
+	var out measurement
+	if err := unmarshaler.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !math.IsNaN(out.Value) || out.Peak == nil || !math.IsInf(float64(*out.Peak), 1) {
+		t.Errorf("Value = %v, Peak = %v", out.Value, out.Peak)
+	}
+	if len(out.Series) != 3 || out.Series[0] != 1.5 || !math.IsNaN(out.Series[1]) || !math.IsInf(out.Series[2], -1) {
+		t.Errorf("Series = %v", out.Series)
+	}
+	if extra, ok := out.Extra.(map[string]interface{}); !ok || !math.IsInf(extra["low"].(float64), -1) {
+		t.Errorf("Extra = %v", out.Extra)
+	}
+	if !math.IsNaN(out.Readings["b"]) {
+		t.Errorf("Readings = %v", out.Readings)
+	}
+
+	var generic map[string]interface{}
+	if err := unmarshaler.Unmarshal(data, &generic); err != nil {
+		t.Fatalf("Unmarshal into map failed: %v", err)
+	}
+	if v, ok := generic["value"].(float64); !ok || !math.IsNaN(v) {
+		t.Errorf("value = %v", generic["value"])
+	}
+	if _, ok := data["value"].(map[string]interface{}); !ok {
+		t.Error("Unmarshal modified the decoded data")
+	}
+}
+
+func TestUnwrapFloatIgnoresFinite(t *testing.T) {
+	if _, ok := unwrapFloat(map[string]interface{}{floatValueKey: "1.5"}); ok {
+		t.Error("unwrapFloat accepted a finite value")
+	}
+}
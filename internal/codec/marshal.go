@@ -2,8 +2,10 @@ package codec
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
+	"reflect"
 
 	"github.com/aigotowork/stow/internal/blob"
 )
@@ -15,6 +17,14 @@ type MarshalOptions struct {
 	ForceInline   bool
 	FileName      string
 	MimeType      string
+
+	// NestedBlobThreshold spills top-level map/slice fields whose JSON encoding
+	// exceeds this many bytes into JSON blobs. 0 disables spilling.
+	NestedBlobThreshold int64
+
+	// NonFiniteSentinels stores NaN and ±Inf floats as {"$float": "NaN"}
+	// sentinels instead of failing with ErrNonFiniteFloat.
+	NonFiniteSentinels bool
 }
 
 // Marshaler handles serialization of values to map[string]interface{}.
@@ -37,6 +47,10 @@ func NewMarshaler(blobManager *blob.Manager) *Marshaler {
 //   - blobRefs: list of blob references created
 //   - error: any error that occurred
 func (m *Marshaler) Marshal(value interface{}, opts MarshalOptions) (map[string]interface{}, []*blob.Reference, error) {
+	if err := ValidateEnums(value); err != nil {
+		return nil, nil, err
+	}
+
 	// Convert value to map
 	data, err := ToMap(value)
 	if err != nil {
@@ -45,16 +59,97 @@ func (m *Marshaler) Marshal(value interface{}, opts MarshalOptions) (map[string]
 
 	var blobRefs []*blob.Reference
 
+	// Vector fields are validated and routed by their encoded size
+	for key, tagInfo := range vectorFields(value) {
+		vec, ok := ToVector(data[key])
+		if !ok {
+			if data[key] == nil {
+				continue
+			}
+			return nil, nil, fmt.Errorf("field %s is tagged as vector but is %T", key, data[key])
+		}
+
+		if len(vec) == 0 {
+			continue
+		}
+
+		if tagInfo.Dim > 0 && len(vec) != tagInfo.Dim {
+			return nil, nil, fmt.Errorf("vector field %s has dimension %d, expected %d", key, len(vec), tagInfo.Dim)
+		}
+
+		encoded := EncodeVector(vec)
+		if opts.ForceInline || int64(len(encoded)) <= opts.BlobThreshold {
+			continue
+		}
+
+		ref, err := m.blobManager.Store(encoded, "", VectorMimeType)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to store vector blob for field %s: %w", key, err)
+		}
+
+		data[key] = ref.ToMap()
+		blobRefs = append(blobRefs, ref)
+	}
+
+	// Raw JSON is kept byte for byte, inline or as a blob
+	for key, fieldValue := range data {
+		raw, ok := fieldValue.(json.RawMessage)
+		if !ok {
+			continue
+		}
+
+		stored, ref, err := m.marshalRaw(key, raw, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		data[key] = stored
+		if ref != nil {
+			blobRefs = append(blobRefs, ref)
+		}
+	}
+
+	// JSON has no NaN or ±Inf
+	for key, fieldValue := range data {
+		encoded, changed, err := encodeNonFinite(key, fieldValue, opts.NonFiniteSentinels)
+		if err != nil {
+			return nil, nil, err
+		}
+		if changed {
+			data[key] = encoded
+		}
+	}
+
+	// Fields tagged `stow:"file"` always go to blobs, named by their tag
+	fileFields := taggedFields(value, func(info TagInfo) bool { return info.IsFile })
+
 	// Process each field to detect blobs
 	for key, fieldValue := range data {
+		fieldOpts := opts
+		if tagInfo, ok := fileFields[key]; ok {
+			// Empty files stay inline
+			if b, isBytes := fieldValue.([]byte); !isBytes || len(b) > 0 {
+				fieldOpts = fileFieldOptions(value, tagInfo, opts)
+			}
+		}
+
 		// Check if this field should be stored as a blob
-		shouldStore, blobData := m.shouldStoreAsBlob(fieldValue, opts)
+		shouldStore, blobData := m.shouldStoreAsBlob(fieldValue, fieldOpts)
 		if !shouldStore {
+			// Large nested maps/slices may be spilled as JSON blobs instead
+			ref, err := m.spillNested(key, fieldValue, opts)
+			if err != nil {
+				return nil, nil, err
+			}
+			if ref != nil {
+				data[key] = ref.ToMap()
+				blobRefs = append(blobRefs, ref)
+			}
 			continue
 		}
 
 		// Store as blob
-		ref, err := m.storeBlob(blobData, opts)
+		ref, err := m.storeBlob(blobData, fieldOpts)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to store blob for field %s: %w", key, err)
 		}
@@ -67,6 +162,26 @@ func (m *Marshaler) Marshal(value interface{}, opts MarshalOptions) (map[string]
 	return data, blobRefs, nil
 }
 
+// fileFieldOptions returns the options for a field tagged `stow:"file"`.
+// Put options win over the tag's name and MIME type.
+func fileFieldOptions(value interface{}, tagInfo TagInfo, opts MarshalOptions) MarshalOptions {
+	opts.ForceFile = true
+
+	if opts.FileName == "" {
+		opts.FileName = tagInfo.Name
+		if tagInfo.NameField != "" {
+			if name, err := ResolveNameField(value, tagInfo.NameField); err == nil {
+				opts.FileName = name
+			}
+		}
+	}
+	if opts.MimeType == "" {
+		opts.MimeType = tagInfo.MimeType
+	}
+
+	return opts
+}
+
 // shouldStoreAsBlob determines if a field value should be stored as a blob.
 func (m *Marshaler) shouldStoreAsBlob(value interface{}, opts MarshalOptions) (bool, interface{}) {
 	if value == nil {
@@ -100,6 +215,52 @@ func (m *Marshaler) shouldStoreAsBlob(value interface{}, opts MarshalOptions) (b
 	return false, nil
 }
 
+// spillNested stores a large map or slice field as a JSON blob.
+// Returns nil if the field is not spilled.
+func (m *Marshaler) spillNested(key string, value interface{}, opts MarshalOptions) (*blob.Reference, error) {
+	if opts.NestedBlobThreshold <= 0 || opts.ForceInline || value == nil {
+		return nil, nil
+	}
+
+	val := reflect.ValueOf(value)
+	switch val.Kind() {
+	case reflect.Map:
+	case reflect.Slice, reflect.Array:
+		// Byte slices and vectors have their own blob routing
+		elem := val.Type().Elem().Kind()
+		if elem == reflect.Uint8 || elem == reflect.Float32 {
+			return nil, nil
+		}
+	default:
+		return nil, nil
+	}
+
+	// Existing blob references and raw messages are left alone
+	if mv, ok := value.(map[string]interface{}); ok && blob.IsBlobReference(mv) {
+		return nil, nil
+	}
+	if _, ok := unwrapRaw(value); ok {
+		return nil, nil
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode field %s: %w", key, err)
+	}
+
+	if int64(len(encoded)) <= opts.NestedBlobThreshold {
+		return nil, nil
+	}
+
+	ref, err := m.blobManager.Store(encoded, key+".json", "application/json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to spill field %s: %w", key, err)
+	}
+
+	ref.Kind = blob.KindJSON
+	return ref, nil
+}
+
 // storeBlob stores data as a blob file.
 func (m *Marshaler) storeBlob(data interface{}, opts MarshalOptions) (*blob.Reference, error) {
 	return m.blobManager.Store(data, opts.FileName, opts.MimeType)
@@ -291,3 +291,47 @@ func TestShouldStoreAsBlobNil(t *testing.T) {
 		t.Error("Nil value should not be stored as blob")
 	}
 }
+
+func TestMarshalFileTags(t *testing.T) {
+	bm, _ := blob.NewManager(filepath.Join(t.TempDir(), "_blobs"), 1024*1024, 1024)
+	marshaler := NewMarshaler(bm)
+
+	type document struct {
+		FileName string
+		Body     []byte `stow:"file,name_field:FileName,mime:text/plain"`
+		Cover    []byte `stow:"file,name:cover.jpg"`
+		Empty    []byte `stow:"file"`
+	}
+	doc := document{FileName: "notes.txt", Body: []byte("small"), Cover: []byte("jpg")}
+
+	data, refs, err := marshaler.Marshal(doc, MarshalOptions{BlobThreshold: 1024})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if len(refs) != 2 {
+		t.Fatalf("expected 2 blobs, got %d", len(refs))
+	}
+
+	bodyMap, _ := data["Body"].(map[string]interface{})
+	body, ok := blob.FromMap(bodyMap)
+	if !ok || body.Name != "notes.txt" || body.MimeType != "text/plain" {
+		t.Errorf("Body = %v, want a notes.txt text/plain blob", data["Body"])
+	}
+	coverMap, _ := data["Cover"].(map[string]interface{})
+	if cover, ok := blob.FromMap(coverMap); !ok || cover.Name != "cover.jpg" {
+		t.Errorf("Cover = %v, want a cover.jpg blob", data["Cover"])
+	}
+	if _, ok := data["Empty"].([]byte); !ok {
+		t.Errorf("Empty = %v, want it inline", data["Empty"])
+	}
+
+	// Put options win over the tag
+	data, _, err = marshaler.Marshal(doc, MarshalOptions{BlobThreshold: 1024, FileName: "override.bin"})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	coverMap, _ = data["Cover"].(map[string]interface{})
+	if cover, ok := blob.FromMap(coverMap); !ok || cover.Name != "override.bin" {
+		t.Errorf("Cover = %v, want the WithFileName name", data["Cover"])
+	}
+}
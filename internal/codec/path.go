@@ -0,0 +1,286 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidPath is returned when a field path cannot be parsed or resolved.
+	ErrInvalidPath = errors.New("invalid field path")
+
+	// ErrIndexOutOfRange is returned when a path index is outside the array bounds.
+	ErrIndexOutOfRange = errors.New("array index out of range")
+)
+
+// PathSegment is a single step in a field path.
+// It addresses either a map field (Key) or an array element (Index).
+type PathSegment struct {
+	Key     string
+	Index   int
+	IsIndex bool
+}
+
+// ParsePath parses a field path into segments.
+//
+// Supported syntax:
+//   - "comments"         -> field "comments"
+//   - "post.comments"    -> field "post", then field "comments"
+//   - "comments[2]"      -> field "comments", then element 2
+//   - "a.b[0][1].c"      -> nested fields and indexes
+func ParsePath(path string) ([]PathSegment, error) {
+	if path == "" {
+		return nil, fmt.Errorf("%w: empty path", ErrInvalidPath)
+	}
+
+	var segments []PathSegment
+
+	for _, part := range strings.Split(path, ".") {
+		if part == "" {
+			return nil, fmt.Errorf("%w: empty segment in %q", ErrInvalidPath, path)
+		}
+
+		// Field name is everything before the first '['
+		name := part
+		rest := ""
+		if i := strings.IndexByte(part, '['); i >= 0 {
+			name = part[:i]
+			rest = part[i:]
+		}
+
+		if name != "" {
+			segments = append(segments, PathSegment{Key: name})
+		} else if len(segments) == 0 {
+			return nil, fmt.Errorf("%w: path must start with a field name: %q", ErrInvalidPath, path)
+		}
+
+		// Parse trailing [n] indexes
+		for rest != "" {
+			end := strings.IndexByte(rest, ']')
+			if rest[0] != '[' || end < 0 {
+				return nil, fmt.Errorf("%w: malformed index in %q", ErrInvalidPath, path)
+			}
+
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("%w: bad index %q in %q", ErrInvalidPath, rest[1:end], path)
+			}
+
+			segments = append(segments, PathSegment{Index: index, IsIndex: true})
+			rest = rest[end+1:]
+		}
+	}
+
+	return segments, nil
+}
+
+// GetPath returns the value at path within data.
+func GetPath(data map[string]interface{}, path string) (interface{}, error) {
+	segments, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var current interface{} = data
+	for _, seg := range segments {
+		current, err = stepInto(current, seg, path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return current, nil
+}
+
+// AppendAtPath appends value to the array at path.
+// A missing or null field is treated as an empty array.
+func AppendAtPath(data map[string]interface{}, path string, value interface{}) error {
+	segments, err := ParsePath(path)
+	if err != nil {
+		return err
+	}
+
+	return updatePath(data, segments, path, func(current interface{}, exists bool) (interface{}, error) {
+		arr, err := asArray(current, exists, path)
+		if err != nil {
+			return nil, err
+		}
+		return append(arr, value), nil
+	})
+}
+
+// InsertAtPath inserts value at the array position addressed by path.
+// The path must end with an index (e.g., "comments[2]"); the index may equal
+// the array length, in which case the value is appended.
+func InsertAtPath(data map[string]interface{}, path string, value interface{}) error {
+	segments, err := ParsePath(path)
+	if err != nil {
+		return err
+	}
+
+	last := segments[len(segments)-1]
+	if !last.IsIndex {
+		return fmt.Errorf("%w: insert path must end with an index: %q", ErrInvalidPath, path)
+	}
+
+	return updatePath(data, segments[:len(segments)-1], path, func(current interface{}, exists bool) (interface{}, error) {
+		arr, err := asArray(current, exists, path)
+		if err != nil {
+			return nil, err
+		}
+
+		if last.Index > len(arr) {
+			return nil, fmt.Errorf("%w: index %d, length %d", ErrIndexOutOfRange, last.Index, len(arr))
+		}
+
+		result := make([]interface{}, 0, len(arr)+1)
+		result = append(result, arr[:last.Index]...)
+		result = append(result, value)
+		result = append(result, arr[last.Index:]...)
+		return result, nil
+	})
+}
+
+// RemoveAtPath removes the element or field addressed by path.
+// If the path ends with an index, the array element is removed and later
+// elements shift down. Otherwise the map field is deleted.
+func RemoveAtPath(data map[string]interface{}, path string) error {
+	segments, err := ParsePath(path)
+	if err != nil {
+		return err
+	}
+
+	last := segments[len(segments)-1]
+
+	if !last.IsIndex {
+		parent, err := resolveParent(data, segments, path)
+		if err != nil {
+			return err
+		}
+
+		m, ok := parent.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%w: %q is not an object", ErrInvalidPath, path)
+		}
+		if _, exists := m[last.Key]; !exists {
+			return fmt.Errorf("%w: field %q not found", ErrInvalidPath, last.Key)
+		}
+
+		delete(m, last.Key)
+		return nil
+	}
+
+	return updatePath(data, segments[:len(segments)-1], path, func(current interface{}, exists bool) (interface{}, error) {
+		arr, ok := current.([]interface{})
+		if !exists || !ok {
+			return nil, fmt.Errorf("%w: %q is not an array", ErrInvalidPath, path)
+		}
+
+		if last.Index >= len(arr) {
+			return nil, fmt.Errorf("%w: index %d, length %d", ErrIndexOutOfRange, last.Index, len(arr))
+		}
+
+		result := make([]interface{}, 0, len(arr)-1)
+		result = append(result, arr[:last.Index]...)
+		result = append(result, arr[last.Index+1:]...)
+		return result, nil
+	})
+}
+
+// updatePath walks segments and replaces the addressed value with the result of fn.
+// Arrays are rebuilt on the way back up since appends may reallocate them.
+func updatePath(container interface{}, segments []PathSegment, path string, fn func(current interface{}, exists bool) (interface{}, error)) error {
+	_, err := updateValue(container, true, segments, path, fn)
+	return err
+}
+
+func updateValue(current interface{}, exists bool, segments []PathSegment, path string, fn func(interface{}, bool) (interface{}, error)) (interface{}, error) {
+	if len(segments) == 0 {
+		return fn(current, exists)
+	}
+
+	seg := segments[0]
+
+	if seg.IsIndex {
+		arr, ok := current.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: %q is not an array", ErrInvalidPath, path)
+		}
+		if seg.Index >= len(arr) {
+			return nil, fmt.Errorf("%w: index %d, length %d", ErrIndexOutOfRange, seg.Index, len(arr))
+		}
+
+		updated, err := updateValue(arr[seg.Index], true, segments[1:], path, fn)
+		if err != nil {
+			return nil, err
+		}
+		arr[seg.Index] = updated
+		return arr, nil
+	}
+
+	m, ok := current.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: %q is not an object", ErrInvalidPath, path)
+	}
+
+	child, childExists := m[seg.Key]
+	updated, err := updateValue(child, childExists, segments[1:], path, fn)
+	if err != nil {
+		return nil, err
+	}
+	m[seg.Key] = updated
+	return m, nil
+}
+
+// resolveParent returns the container holding the last segment of path.
+func resolveParent(data map[string]interface{}, segments []PathSegment, path string) (interface{}, error) {
+	var current interface{} = data
+	var err error
+	for _, seg := range segments[:len(segments)-1] {
+		current, err = stepInto(current, seg, path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return current, nil
+}
+
+// stepInto moves one segment deeper into current.
+func stepInto(current interface{}, seg PathSegment, path string) (interface{}, error) {
+	if seg.IsIndex {
+		arr, ok := current.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: %q is not an array", ErrInvalidPath, path)
+		}
+		if seg.Index >= len(arr) {
+			return nil, fmt.Errorf("%w: index %d, length %d", ErrIndexOutOfRange, seg.Index, len(arr))
+		}
+		return arr[seg.Index], nil
+	}
+
+	m, ok := current.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: %q is not an object", ErrInvalidPath, path)
+	}
+
+	value, exists := m[seg.Key]
+	if !exists {
+		return nil, fmt.Errorf("%w: field %q not found", ErrInvalidPath, seg.Key)
+	}
+	return value, nil
+}
+
+// asArray converts a path target to an array, treating missing/null as empty.
+func asArray(current interface{}, exists bool, path string) ([]interface{}, error) {
+	if !exists || current == nil {
+		return []interface{}{}, nil
+	}
+
+	arr, ok := current.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: %q is not an array", ErrInvalidPath, path)
+	}
+	return arr, nil
+}
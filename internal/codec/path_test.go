@@ -0,0 +1,165 @@
+package codec
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// ========== ParsePath Tests ==========
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected []PathSegment
+	}{
+		{"comments", []PathSegment{{Key: "comments"}}},
+		{"post.comments", []PathSegment{{Key: "post"}, {Key: "comments"}}},
+		{"comments[2]", []PathSegment{{Key: "comments"}, {Index: 2, IsIndex: true}}},
+		{"a.b[0][1].c", []PathSegment{
+			{Key: "a"}, {Key: "b"},
+			{Index: 0, IsIndex: true}, {Index: 1, IsIndex: true},
+			{Key: "c"},
+		}},
+	}
+
+	for _, tt := range tests {
+		segments, err := ParsePath(tt.path)
+		if err != nil {
+			t.Errorf("ParsePath(%q) error: %v", tt.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(segments, tt.expected) {
+			t.Errorf("ParsePath(%q) = %+v, want %+v", tt.path, segments, tt.expected)
+		}
+	}
+}
+
+func TestParsePathInvalid(t *testing.T) {
+	invalid := []string{"", "a..b", "[0]", "a[", "a[x]", "a[-1]", "a[1]b"}
+
+	for _, path := range invalid {
+		if _, err := ParsePath(path); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("ParsePath(%q) should return ErrInvalidPath, got %v", path, err)
+		}
+	}
+}
+
+// ========== Array Operation Tests ==========
+
+func TestAppendAtPath(t *testing.T) {
+	data := map[string]interface{}{
+		"comments": []interface{}{"first"},
+	}
+
+	if err := AppendAtPath(data, "comments", "second"); err != nil {
+		t.Fatalf("AppendAtPath failed: %v", err)
+	}
+
+	expected := []interface{}{"first", "second"}
+	if !reflect.DeepEqual(data["comments"], expected) {
+		t.Errorf("comments = %v, want %v", data["comments"], expected)
+	}
+}
+
+func TestAppendAtPathCreatesArray(t *testing.T) {
+	data := map[string]interface{}{
+		"post": map[string]interface{}{},
+	}
+
+	if err := AppendAtPath(data, "post.tags", "go"); err != nil {
+		t.Fatalf("AppendAtPath failed: %v", err)
+	}
+
+	tags, err := GetPath(data, "post.tags")
+	if err != nil {
+		t.Fatalf("GetPath failed: %v", err)
+	}
+	if !reflect.DeepEqual(tags, []interface{}{"go"}) {
+		t.Errorf("tags = %v, want [go]", tags)
+	}
+}
+
+func TestAppendAtPathNotArray(t *testing.T) {
+	data := map[string]interface{}{"title": "hello"}
+
+	if err := AppendAtPath(data, "title", "x"); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("expected ErrInvalidPath, got %v", err)
+	}
+}
+
+func TestInsertAtPath(t *testing.T) {
+	data := map[string]interface{}{
+		"items": []interface{}{"a", "c"},
+	}
+
+	if err := InsertAtPath(data, "items[1]", "b"); err != nil {
+		t.Fatalf("InsertAtPath failed: %v", err)
+	}
+	if err := InsertAtPath(data, "items[3]", "d"); err != nil {
+		t.Fatalf("InsertAtPath at end failed: %v", err)
+	}
+
+	expected := []interface{}{"a", "b", "c", "d"}
+	if !reflect.DeepEqual(data["items"], expected) {
+		t.Errorf("items = %v, want %v", data["items"], expected)
+	}
+}
+
+func TestInsertAtPathOutOfRange(t *testing.T) {
+	data := map[string]interface{}{
+		"items": []interface{}{"a"},
+	}
+
+	if err := InsertAtPath(data, "items[5]", "x"); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Errorf("expected ErrIndexOutOfRange, got %v", err)
+	}
+	if err := InsertAtPath(data, "items", "x"); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("expected ErrInvalidPath for path without index, got %v", err)
+	}
+}
+
+func TestRemoveAtPath(t *testing.T) {
+	data := map[string]interface{}{
+		"items": []interface{}{"a", "b", "c"},
+		"extra": "value",
+	}
+
+	if err := RemoveAtPath(data, "items[1]"); err != nil {
+		t.Fatalf("RemoveAtPath failed: %v", err)
+	}
+	if !reflect.DeepEqual(data["items"], []interface{}{"a", "c"}) {
+		t.Errorf("items = %v, want [a c]", data["items"])
+	}
+
+	if err := RemoveAtPath(data, "extra"); err != nil {
+		t.Fatalf("RemoveAtPath field failed: %v", err)
+	}
+	if _, exists := data["extra"]; exists {
+		t.Error("field 'extra' should be removed")
+	}
+
+	if err := RemoveAtPath(data, "items[2]"); !errors.Is(err, ErrIndexOutOfRange) {
+		t.Errorf("expected ErrIndexOutOfRange, got %v", err)
+	}
+}
+
+func TestNestedArrayPath(t *testing.T) {
+	data := map[string]interface{}{
+		"threads": []interface{}{
+			map[string]interface{}{"replies": []interface{}{"r1"}},
+		},
+	}
+
+	if err := AppendAtPath(data, "threads[0].replies", "r2"); err != nil {
+		t.Fatalf("AppendAtPath nested failed: %v", err)
+	}
+
+	replies, err := GetPath(data, "threads[0].replies")
+	if err != nil {
+		t.Fatalf("GetPath failed: %v", err)
+	}
+	if !reflect.DeepEqual(replies, []interface{}{"r1", "r2"}) {
+		t.Errorf("replies = %v, want [r1 r2]", replies)
+	}
+}
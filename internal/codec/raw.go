@@ -0,0 +1,90 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/aigotowork/stow/internal/blob"
+)
+
+// rawValueKey wraps a json.RawMessage stored inline. The message is kept as
+// a JSON string so its bytes survive decoding untouched:
+//
+//	{"payload": {"$raw": "{\"b\":1,\"a\":2.50}"}}
+const rawValueKey = "$raw"
+
+// RawMimeType is the MIME type of blobs holding a json.RawMessage.
+const RawMimeType = "application/json"
+
+// RestoreValue returns what a raw message or non-finite float sentinel in
+// stored data stands for (a json.RawMessage or a float64). Other values
+// are returned as is.
+func RestoreValue(value interface{}) interface{} {
+	if raw, ok := unwrapRaw(value); ok {
+		return raw
+	}
+	if f, ok := unwrapFloat(value); ok {
+		return f
+	}
+	return value
+}
+
+// wrapRaw returns the inline form of a raw message.
+func wrapRaw(raw json.RawMessage) map[string]interface{} {
+	return map[string]interface{}{rawValueKey: string(raw)}
+}
+
+// unwrapRaw returns the message held by an inline raw wrapper.
+func unwrapRaw(value interface{}) (json.RawMessage, bool) {
+	m, ok := value.(map[string]interface{})
+	if !ok || len(m) != 1 {
+		return nil, false
+	}
+	s, ok := m[rawValueKey].(string)
+	if !ok {
+		return nil, false
+	}
+	return json.RawMessage(s), true
+}
+
+// marshalRaw validates a raw message field and returns its stored form:
+// a raw blob reference above the blob threshold, an inline wrapper otherwise.
+func (m *Marshaler) marshalRaw(key string, raw json.RawMessage, opts MarshalOptions) (interface{}, *blob.Reference, error) {
+	if raw == nil {
+		return nil, nil, nil
+	}
+	if !json.Valid(raw) {
+		return nil, nil, fmt.Errorf("field %s holds invalid JSON", key)
+	}
+
+	if opts.ForceInline || (!opts.ForceFile && int64(len(raw)) <= opts.BlobThreshold) {
+		return wrapRaw(raw), nil, nil
+	}
+
+	ref, err := m.blobManager.Store([]byte(raw), key+".json", RawMimeType)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to store raw blob for field %s: %w", key, err)
+	}
+
+	ref.Kind = blob.KindRawJSON
+	return ref.ToMap(), ref, nil
+}
+
+// setRawField assigns a raw message to a field. Byte slice fields such as
+// json.RawMessage get the exact bytes, other fields are decoded from it.
+func setRawField(field reflect.Value, raw json.RawMessage) error {
+	fieldType := field.Type()
+
+	if fieldType.Kind() == reflect.Slice && fieldType.Elem().Kind() == reflect.Uint8 {
+		field.SetBytes(append([]byte(nil), raw...))
+		return nil
+	}
+
+	if fieldType.Kind() == reflect.Interface {
+		field.Set(reflect.ValueOf(raw))
+		return nil
+	}
+
+	return json.Unmarshal(raw, field.Addr().Interface())
+}
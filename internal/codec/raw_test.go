@@ -0,0 +1,68 @@
+package codec
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/aigotowork/stow/internal/blob"
+)
+
+func TestMarshalRawMessage(t *testing.T) {
+	blobDir := filepath.Join(t.TempDir(), "_blobs")
+	bm, err := blob.NewManager(blobDir, 1024*1024, 1024)
+	if err != nil {
+		t.Fatalf("failed to create blob manager: %v", err)
+	}
+
+	marshaler := NewMarshaler(bm)
+	unmarshaler := NewUnmarshaler(bm)
+
+	type event struct {
+		Inline json.RawMessage `json:"inline"`
+		Blob   json.RawMessage `json:"blob"`
+		Empty  json.RawMessage `json:"empty"`
+	}
+	in := event{
+		Inline: json.RawMessage(`{"b": 1, "a": 1.50}`),
+		Blob:   json.RawMessage(`[1,  2,   3]`),
+	}
+
+	data, refs, err := marshaler.Marshal(in, MarshalOptions{BlobThreshold: 10})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if len(refs) != 2 || refs[0].Kind != blob.KindRawJSON || data["empty"] != nil {
+		t.Fatalf("expected two raw JSON blobs, got %d", len(refs))
+	}
+
+	data, refs, err = marshaler.Marshal(in, MarshalOptions{BlobThreshold: 1024})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if len(refs) != 0 {
+		t.Fatalf("expected no blobs, got %d", len(refs))
+	}
+
+	// Round trip through the record encoding
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		t.Fatalf("json.Marshal failed: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal failed: %v", err)
+	}
+
+	var out event
+	if err := unmarshaler.Unmarshal(decoded, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if string(out.Inline) != string(in.Inline) || string(out.Blob) != string(in.Blob) || out.Empty != nil {
+		t.Errorf("round trip = %+v", out)
+	}
+
+	if _, _, err := marshaler.Marshal(event{Inline: json.RawMessage("{")}, MarshalOptions{}); err == nil {
+		t.Error("invalid raw JSON should be rejected")
+	}
+}
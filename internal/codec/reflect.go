@@ -36,6 +36,19 @@ func getFieldName(fieldType reflect.StructField) string {
 	return fieldName
 }
 
+// FieldByName returns the settable field of a struct value stored under
+// name, matching JSON tags like decoding does.
+func FieldByName(structValue reflect.Value, name string) (reflect.Value, bool) {
+	typ := structValue.Type()
+	for i := 0; i < structValue.NumField(); i++ {
+		field := structValue.Field(i)
+		if field.CanSet() && getFieldName(typ.Field(i)) == name {
+			return field, true
+		}
+	}
+	return reflect.Value{}, false
+}
+
 // isTimeType checks if a value is time.Time or *time.Time.
 func isTimeType(value interface{}) bool {
 	switch value.(type) {
@@ -256,7 +269,7 @@ func FromMap(data map[string]interface{}, target interface{}) error {
 
 			// If target is interface{}, just assign directly
 			if val.Kind() == reflect.Interface {
-				val.Set(reflect.ValueOf(scalarValue))
+				val.Set(reflect.ValueOf(restoreFloats(scalarValue)))
 				return nil
 			}
 
@@ -276,7 +289,7 @@ func FromMap(data map[string]interface{}, target interface{}) error {
 		}
 
 		for key, value := range data {
-			val.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(value))
+			val.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(restoreFloats(value)))
 		}
 		return nil
 	}
@@ -321,6 +334,18 @@ func setFieldValue(field reflect.Value, value interface{}) error {
 		return nil
 	}
 
+	// Raw messages keep their exact bytes
+	if raw, ok := unwrapRaw(value); ok {
+		return setRawField(field, raw)
+	}
+
+	// NaN and ±Inf are stored as sentinels
+	if f, ok := unwrapFloat(value); ok {
+		value = f
+	} else if field.Kind() == reflect.Interface {
+		value = restoreFloats(value)
+	}
+
 	// Handle different field kinds
 	switch field.Kind() {
 	case reflect.Struct:
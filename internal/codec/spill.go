@@ -0,0 +1,72 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/aigotowork/stow/internal/blob"
+)
+
+const (
+	// spilledRecordKey marks a stub record whose payload lives in a blob.
+	//
+	// Example stub data:
+	//
+	//	{"$record": {"$blob": true, "loc": "_blobs/record_abc123.json", ...}}
+	spilledRecordKey = "$record"
+
+	// spilledRecordName is the file name hint used for spilled payload blobs.
+	spilledRecordName = "record.json"
+)
+
+// SpillRecord stores the whole data payload as a JSON blob and returns a stub
+// that references it. Blob references already inside data are preserved in the
+// spilled JSON.
+func (m *Marshaler) SpillRecord(data map[string]interface{}) (map[string]interface{}, *blob.Reference, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode record payload: %w", err)
+	}
+
+	ref, err := m.blobManager.Store(payload, spilledRecordName, "application/json")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to store record payload: %w", err)
+	}
+
+	return map[string]interface{}{spilledRecordKey: ref.ToMap()}, ref, nil
+}
+
+// SpilledRecordRef returns the payload reference if data is a spilled-record stub.
+func SpilledRecordRef(data map[string]interface{}) (*blob.Reference, bool) {
+	if len(data) != 1 {
+		return nil, false
+	}
+
+	m, ok := data[spilledRecordKey].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	return blob.FromMap(m)
+}
+
+// ExpandRecord returns the full payload for a spilled-record stub.
+// Data that is not a stub is returned unchanged.
+func (u *Unmarshaler) ExpandRecord(data map[string]interface{}) (map[string]interface{}, error) {
+	ref, ok := SpilledRecordRef(data)
+	if !ok {
+		return data, nil
+	}
+
+	payload, err := u.loadBlobAsBytes(ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load spilled record: %w", err)
+	}
+
+	var expanded map[string]interface{}
+	if err := json.Unmarshal(payload, &expanded); err != nil {
+		return nil, fmt.Errorf("failed to decode spilled record: %w", err)
+	}
+
+	return expanded, nil
+}
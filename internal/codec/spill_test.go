@@ -0,0 +1,125 @@
+package codec
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/aigotowork/stow/internal/blob"
+)
+
+func TestSpillAndExpandRecord(t *testing.T) {
+	blobDir := filepath.Join(t.TempDir(), "_blobs")
+	bm, err := blob.NewManager(blobDir, 1024*1024, 1024)
+	if err != nil {
+		t.Fatalf("failed to create blob manager: %v", err)
+	}
+
+	marshaler := NewMarshaler(bm)
+	unmarshaler := NewUnmarshaler(bm)
+
+	data := map[string]interface{}{
+		"title": "spilled",
+		"count": 3.0,
+	}
+
+	stub, ref, err := marshaler.SpillRecord(data)
+	if err != nil {
+		t.Fatalf("SpillRecord failed: %v", err)
+	}
+	if ref == nil || ref.MimeType != "application/json" {
+		t.Fatalf("unexpected payload reference: %+v", ref)
+	}
+
+	if _, ok := SpilledRecordRef(stub); !ok {
+		t.Fatal("stub should be detected as a spilled record")
+	}
+	if _, ok := SpilledRecordRef(data); ok {
+		t.Error("regular data should not be detected as spilled")
+	}
+
+	expanded, err := unmarshaler.ExpandRecord(stub)
+	if err != nil {
+		t.Fatalf("ExpandRecord failed: %v", err)
+	}
+	if expanded["title"] != "spilled" || expanded["count"] != 3.0 {
+		t.Errorf("unexpected expanded data: %v", expanded)
+	}
+
+	// Non-stub data passes through unchanged
+	same, err := unmarshaler.ExpandRecord(data)
+	if err != nil || same["title"] != "spilled" {
+		t.Errorf("ExpandRecord should pass through regular data, got %v, %v", same, err)
+	}
+}
+
+func TestMarshalSpillsLargeNestedFields(t *testing.T) {
+	blobDir := filepath.Join(t.TempDir(), "_blobs")
+	bm, err := blob.NewManager(blobDir, 1024*1024, 1024)
+	if err != nil {
+		t.Fatalf("failed to create blob manager: %v", err)
+	}
+
+	marshaler := NewMarshaler(bm)
+	unmarshaler := NewUnmarshaler(bm)
+
+	type Doc struct {
+		Title    string                 `json:"title"`
+		Metadata map[string]interface{} `json:"metadata"`
+		Tags     []string               `json:"tags"`
+	}
+
+	metadata := make(map[string]interface{})
+	for i := 0; i < 50; i++ {
+		metadata[string(rune('a'+i%26))+string(rune('a'+i/26))] = "some metadata value"
+	}
+
+	doc := Doc{Title: "t", Metadata: metadata, Tags: []string{"small"}}
+	data, refs, err := marshaler.Marshal(doc, MarshalOptions{BlobThreshold: 4096, NestedBlobThreshold: 256})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	if len(refs) != 1 {
+		t.Fatalf("expected 1 spilled field, got %d", len(refs))
+	}
+	if refs[0].Kind != blob.KindJSON {
+		t.Errorf("spilled reference kind = %q, want %q", refs[0].Kind, blob.KindJSON)
+	}
+	if _, ok := data["tags"].([]string); !ok {
+		t.Error("small slices should stay inline")
+	}
+
+	var result Doc
+	if err := unmarshaler.Unmarshal(data, &result); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if len(result.Metadata) != 50 || result.Metadata["aa"] != "some metadata value" {
+		t.Errorf("metadata not rehydrated: %v", result.Metadata)
+	}
+
+	expanded, err := unmarshaler.ExpandJSONBlobs(data)
+	if err != nil {
+		t.Fatalf("ExpandJSONBlobs failed: %v", err)
+	}
+	if m, ok := expanded["metadata"].(map[string]interface{}); !ok || len(m) != 50 {
+		t.Errorf("ExpandJSONBlobs did not decode metadata: %v", expanded["metadata"])
+	}
+}
+
+func TestMarshalNestedSpillDisabled(t *testing.T) {
+	blobDir := filepath.Join(t.TempDir(), "_blobs")
+	bm, _ := blob.NewManager(blobDir, 1024*1024, 1024)
+	marshaler := NewMarshaler(bm)
+
+	data := map[string]interface{}{
+		"list": []interface{}{"a", "b", "c"},
+	}
+
+	_, refs, err := marshaler.Marshal(data, MarshalOptions{BlobThreshold: 4096})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if len(refs) != 0 {
+		t.Errorf("nested spilling should be disabled by default, got %d refs", len(refs))
+	}
+}
@@ -2,6 +2,9 @@
 package codec
 
 import (
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -15,6 +18,11 @@ import (
 //   - name:xxx: specify custom file name
 //   - name_field:FieldName: use another field's value as file name
 //   - mime:xxx: specify MIME type
+//   - vector: mark this []float32 field as an embedding vector
+//   - dim:N: expected vector dimension (validated on write)
+//   - noversion: changes to this field alone don't create a new version
+//   - index: the namespace keeps a persistent index of this field's values
+//   - enum:a|b|c: allowed values of this string field (validated on write and read)
 type TagInfo struct {
 	// IsFile indicates if this field should be stored as a blob file
 	IsFile bool
@@ -27,6 +35,21 @@ type TagInfo struct {
 
 	// MimeType is the MIME type (e.g., "image/jpeg")
 	MimeType string
+
+	// IsVector indicates this field holds a float32 embedding vector
+	IsVector bool
+
+	// Dim is the expected vector dimension (0 means unchecked)
+	Dim int
+
+	// NoVersion excludes this field from versioning
+	NoVersion bool
+
+	// Index makes the namespace index this field's values
+	Index bool
+
+	// Enum lists the allowed values of a string field (nil means unchecked)
+	Enum []string
 }
 
 // ParseStowTag parses a stow struct tag.
@@ -36,6 +59,8 @@ type TagInfo struct {
 //   - `stow:"file,name:avatar.jpg"` -> IsFile=true, Name="avatar.jpg"
 //   - `stow:"file,name_field:FileName"` -> IsFile=true, NameField="FileName"
 //   - `stow:"file,mime:image/jpeg"` -> IsFile=true, MimeType="image/jpeg"
+//   - `stow:"vector,dim:768"` -> IsVector=true, Dim=768
+//   - `stow:"enum:draft|published"` -> Enum=["draft", "published"]
 func ParseStowTag(tag string) TagInfo {
 	info := TagInfo{}
 
@@ -54,6 +79,21 @@ func ParseStowTag(tag string) TagInfo {
 			continue
 		}
 
+		if part == "vector" {
+			info.IsVector = true
+			continue
+		}
+
+		if part == "noversion" {
+			info.NoVersion = true
+			continue
+		}
+
+		if part == "index" {
+			info.Index = true
+			continue
+		}
+
 		// Check for key:value pairs
 		if strings.Contains(part, ":") {
 			kv := strings.SplitN(part, ":", 2)
@@ -71,6 +111,14 @@ func ParseStowTag(tag string) TagInfo {
 				info.NameField = value
 			case "mime":
 				info.MimeType = value
+			case "dim":
+				if dim, err := strconv.Atoi(value); err == nil && dim > 0 {
+					info.Dim = dim
+				}
+			case "enum":
+				if value != "" {
+					info.Enum = strings.Split(value, "|")
+				}
 			}
 		}
 	}
@@ -85,7 +133,7 @@ func HasStowTag(tag string) bool {
 
 // IsEmpty checks if the tag info is empty (no options set).
 func (t *TagInfo) IsEmpty() bool {
-	return !t.IsFile && t.Name == "" && t.NameField == "" && t.MimeType == ""
+	return !t.IsFile && t.Name == "" && t.NameField == "" && t.MimeType == "" && !t.IsVector && t.Dim == 0 && !t.NoVersion && !t.Index && len(t.Enum) == 0
 }
 
 // ShouldStoreAsBlob determines if a field should be stored as a blob based on tag info.
@@ -93,3 +141,63 @@ func (t *TagInfo) IsEmpty() bool {
 func (t *TagInfo) ShouldStoreAsBlob() bool {
 	return t.IsFile
 }
+
+// NoVersionFields returns the serialized names of top-level struct fields tagged
+// `stow:"noversion"`. Returns nil for non-struct values.
+func NoVersionFields(value interface{}) []string {
+	var names []string
+	for name := range taggedFields(value, func(info TagInfo) bool { return info.NoVersion }) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IndexFields returns the serialized names of top-level struct fields tagged
+// `stow:"index"`. Returns nil for non-struct values.
+func IndexFields(value interface{}) []string {
+	var names []string
+	for name := range taggedFields(value, func(info TagInfo) bool { return info.Index }) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// taggedFields returns the tag info of top-level struct fields matching fn,
+// keyed by their serialized field name. Returns nil for non-struct values.
+func taggedFields(value interface{}, fn func(TagInfo) bool) map[string]TagInfo {
+	val := reflect.ValueOf(value)
+	if val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return nil
+		}
+		val = val.Elem()
+	}
+
+	if val.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fields map[string]TagInfo
+	typ := val.Type()
+
+	for i := 0; i < typ.NumField(); i++ {
+		fieldType := typ.Field(i)
+		if !fieldType.IsExported() {
+			continue
+		}
+
+		tagInfo := ParseStowTag(fieldType.Tag.Get("stow"))
+		if !fn(tagInfo) {
+			continue
+		}
+
+		if fields == nil {
+			fields = make(map[string]TagInfo)
+		}
+		fields[getFieldName(fieldType)] = tagInfo
+	}
+
+	return fields
+}
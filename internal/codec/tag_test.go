@@ -458,3 +458,62 @@ func TestParseStowTagAndMethods(t *testing.T) {
 		})
 	}
 }
+
+func TestParseStowTagVector(t *testing.T) {
+	info := ParseStowTag("vector,dim:768")
+	if !info.IsVector {
+		t.Error("IsVector should be true")
+	}
+	if info.Dim != 768 {
+		t.Errorf("Dim = %d, want 768", info.Dim)
+	}
+	if info.IsEmpty() {
+		t.Error("vector tag should not be empty")
+	}
+
+	// Invalid dimensions are ignored
+	info = ParseStowTag("vector,dim:abc")
+	if !info.IsVector || info.Dim != 0 {
+		t.Errorf("unexpected tag info: %+v", info)
+	}
+}
+
+func TestNoVersionFields(t *testing.T) {
+	type Article struct {
+		Title     string `json:"title"`
+		Views     int    `json:"view_count" stow:"noversion"`
+		LastRead  string `stow:"noversion"`
+		Body      string
+		unexposed int `stow:"noversion"`
+	}
+
+	fields := NoVersionFields(&Article{})
+	if len(fields) != 2 || fields[0] != "LastRead" || fields[1] != "view_count" {
+		t.Errorf("NoVersionFields = %v, want [LastRead view_count]", fields)
+	}
+
+	if NoVersionFields(map[string]interface{}{"a": 1}) != nil {
+		t.Error("non-struct values should have no noversion fields")
+	}
+
+	if info := ParseStowTag("noversion"); info.IsEmpty() {
+		t.Error("noversion tag should not be empty")
+	}
+}
+
+func TestIndexFields(t *testing.T) {
+	type User struct {
+		Name  string `json:"name"`
+		Email string `json:"email" stow:"index"`
+		Age   *int   `stow:"index"`
+	}
+
+	fields := IndexFields(User{})
+	if len(fields) != 2 || fields[0] != "Age" || fields[1] != "email" {
+		t.Errorf("IndexFields = %v, want [Age email]", fields)
+	}
+
+	if IndexFields(map[string]interface{}{"email": "a"}) != nil {
+		t.Error("non-struct values should have no index fields")
+	}
+}
@@ -1,6 +1,7 @@
 package codec
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"reflect"
@@ -12,17 +13,24 @@ import (
 // Unmarshaler handles deserialization from map[string]interface{} to target types.
 // It detects blob references and loads them appropriately based on target type.
 type Unmarshaler struct {
-	blobManager *blob.Manager
+	blobManager BlobLoader
 	logger      Logger // Optional logger for warnings
 }
 
+// BlobLoader loads the blobs that references point to. *blob.Manager loads
+// them from a namespace's _blobs directory.
+type BlobLoader interface {
+	Load(ref *blob.Reference) (*blob.FileData, error)
+	LoadBytes(ref *blob.Reference) ([]byte, error)
+}
+
 // Logger interface for logging warnings (e.g., blob file not found).
 type Logger interface {
 	Warn(msg string, fields ...interface{})
 }
 
 // NewUnmarshaler creates a new unmarshaler.
-func NewUnmarshaler(blobManager *blob.Manager) *Unmarshaler {
+func NewUnmarshaler(blobManager BlobLoader) *Unmarshaler {
 	return &Unmarshaler{
 		blobManager: blobManager,
 	}
@@ -65,7 +73,11 @@ func (u *Unmarshaler) Unmarshal(data map[string]interface{}, target interface{})
 
 	// Handle interface{} target - just assign the map
 	if val.Kind() == reflect.Interface {
-		val.Set(reflect.ValueOf(data))
+		expanded, err := u.ExpandJSONBlobs(data)
+		if err != nil {
+			return err
+		}
+		val.Set(reflect.ValueOf(restoreFloats(expanded)))
 		return nil
 	}
 
@@ -92,8 +104,16 @@ func (u *Unmarshaler) unmarshalToMap(data map[string]interface{}, target reflect
 		// Check if value is a blob reference
 		if m, ok := value.(map[string]interface{}); ok {
 			if ref, isBlobRef := blob.FromMap(m); isBlobRef {
-				// Load blob based on map value type (always []byte for maps)
-				blobValue, err := u.loadBlobAsBytes(ref)
+				// Spilled JSON subtrees are decoded, other blobs load as []byte
+				var blobValue interface{}
+				var err error
+				if ref.Kind == blob.KindJSON {
+					blobValue, err = u.loadJSONBlob(ref)
+				} else if ref.Kind == blob.KindRawJSON {
+					blobValue, err = u.loadRawBlob(ref)
+				} else {
+					blobValue, err = u.loadBlobAsBytes(ref)
+				}
 				if err != nil {
 					u.logWarn(fmt.Sprintf("failed to load blob for key %s", key), err)
 					continue
@@ -101,6 +121,11 @@ func (u *Unmarshaler) unmarshalToMap(data map[string]interface{}, target reflect
 				value = blobValue
 			}
 		}
+		if raw, ok := unwrapRaw(value); ok {
+			value = raw
+		} else {
+			value = restoreFloats(value)
+		}
 
 		target.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(value))
 	}
@@ -133,6 +158,20 @@ func (u *Unmarshaler) unmarshalToStruct(data map[string]interface{}, target refl
 		// Check if value is a blob reference
 		if m, ok := value.(map[string]interface{}); ok {
 			if ref, isBlobRef := blob.FromMap(m); isBlobRef {
+				// Spilled JSON subtrees are decoded like inline values
+				if ref.Kind == blob.KindJSON {
+					decoded, err := u.loadJSONBlob(ref)
+					if err != nil {
+						u.logWarn(fmt.Sprintf("failed to load blob for field %s", fieldName), err)
+						field.Set(reflect.Zero(field.Type()))
+						continue
+					}
+					if err := setFieldValue(field, decoded); err != nil {
+						return fmt.Errorf("failed to set field %s: %w", fieldName, err)
+					}
+					continue
+				}
+
 				// Load blob according to field type
 				if err := u.loadBlobIntoField(ref, field); err != nil {
 					u.logWarn(fmt.Sprintf("failed to load blob for field %s", fieldName), err)
@@ -143,10 +182,34 @@ func (u *Unmarshaler) unmarshalToStruct(data map[string]interface{}, target refl
 			}
 		}
 
+		// Raw messages from a blob keep their exact bytes
+		// (inline ones are unwrapped by setFieldValue)
+		if m, ok := value.(map[string]interface{}); ok {
+			if ref, isBlobRef := blob.FromMap(m); isBlobRef && ref.Kind == blob.KindRawJSON {
+				raw, err := u.loadRawBlob(ref)
+				if err != nil {
+					u.logWarn(fmt.Sprintf("failed to load blob for field %s", fieldName), err)
+					field.Set(reflect.Zero(field.Type()))
+					continue
+				}
+				if err := setRawField(field, raw); err != nil {
+					return fmt.Errorf("failed to set field %s: %w", fieldName, err)
+				}
+				continue
+			}
+		}
+
 		// Regular field - set value
 		if err := setFieldValue(field, value); err != nil {
 			return fmt.Errorf("failed to set field %s: %w", fieldName, err)
 		}
+
+		// Enum fields only decode to one of their values
+		if values, ok := enumValues(fieldType, ParseStowTag(fieldType.Tag.Get("stow"))); ok {
+			if err := checkEnum(fieldName, field, values); err != nil {
+				return err
+			}
+		}
 	}
 
 	return nil
@@ -167,6 +230,20 @@ func (u *Unmarshaler) loadBlobIntoField(ref *blob.Reference, field reflect.Value
 		return nil
 	}
 
+	// If field is []float32, decode an encoded vector blob
+	if fieldType.Kind() == reflect.Slice && fieldType.Elem().Kind() == reflect.Float32 {
+		data, err := u.loadBlobAsBytes(ref)
+		if err != nil {
+			return err
+		}
+		vec, err := DecodeVector(data)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(vec).Convert(fieldType))
+		return nil
+	}
+
 	// If field is IFileData interface, return file handle
 	// Check if field type implements IFileData
 	// For now, we'll check if it's an interface and assume it's IFileData
@@ -187,6 +264,88 @@ func (u *Unmarshaler) loadBlobAsBytes(ref *blob.Reference) ([]byte, error) {
 	return u.blobManager.LoadBytes(ref)
 }
 
+// loadJSONBlob loads and decodes a spilled JSON subtree.
+func (u *Unmarshaler) loadJSONBlob(ref *blob.Reference) (interface{}, error) {
+	data, err := u.loadBlobAsBytes(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("failed to decode JSON blob: %w", err)
+	}
+	return value, nil
+}
+
+// loadRawBlob loads a json.RawMessage kept as a blob.
+func (u *Unmarshaler) loadRawBlob(ref *blob.Reference) (json.RawMessage, error) {
+	data, err := u.loadBlobAsBytes(ref)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(data), nil
+}
+
+// ExpandJSONBlobs returns a copy of data with top-level spilled JSON subtrees
+// replaced by their decoded values and raw messages replaced by
+// json.RawMessage. Other blob references are kept as-is.
+func (u *Unmarshaler) ExpandJSONBlobs(data map[string]interface{}) (map[string]interface{}, error) {
+	var expanded map[string]interface{}
+
+	for key, value := range data {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		decoded, ok, err := u.expandValue(m)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand field %s: %w", key, err)
+		}
+		if !ok {
+			continue
+		}
+
+		if expanded == nil {
+			expanded = make(map[string]interface{}, len(data))
+			for k, v := range data {
+				expanded[k] = v
+			}
+		}
+		expanded[key] = decoded
+	}
+
+	if expanded == nil {
+		return data, nil
+	}
+	return expanded, nil
+}
+
+// expandValue decodes a raw message or a spilled JSON / raw JSON blob reference.
+// Returns false for any other value.
+func (u *Unmarshaler) expandValue(m map[string]interface{}) (interface{}, bool, error) {
+	if raw, ok := unwrapRaw(m); ok {
+		return raw, true, nil
+	}
+
+	ref, isBlobRef := blob.FromMap(m)
+	if !isBlobRef {
+		return nil, false, nil
+	}
+
+	switch ref.Kind {
+	case blob.KindJSON:
+		decoded, err := u.loadJSONBlob(ref)
+		return decoded, true, err
+	case blob.KindRawJSON:
+		raw, err := u.loadRawBlob(ref)
+		return raw, true, err
+	default:
+		return nil, false, nil
+	}
+}
+
 // loadBlobAsFileData loads a blob as a file handle (IFileData).
 func (u *Unmarshaler) loadBlobAsFileData(ref *blob.Reference) (io.ReadCloser, error) {
 	return u.blobManager.Load(ref)
@@ -0,0 +1,165 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidTag is returned when a stow struct tag can't be honored.
+var ErrInvalidTag = errors.New("invalid stow tag")
+
+var readerType = reflect.TypeOf((*io.Reader)(nil)).Elem()
+
+// ValidateTags checks the stow tags of the top-level fields of a struct
+// type (or pointer to one): unknown options, options that need `file` or
+// `vector`, conflicting options, field types that can't hold a blob, a
+// vector or an enum, and name_field references to missing or non-string fields.
+// All problems are returned together, each wrapping ErrInvalidTag.
+func ValidateTags(typ reflect.Type) error {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return fmt.Errorf("%w: %s is not a struct", ErrInvalidTag, typ)
+	}
+
+	var errs []error
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag, ok := field.Tag.Lookup("stow")
+		if !ok {
+			continue
+		}
+		if !field.IsExported() {
+			errs = append(errs, fmt.Errorf("%w: %s.%s: unexported fields are not stored", ErrInvalidTag, typ.Name(), field.Name))
+			continue
+		}
+
+		for _, problem := range validateField(typ, field, tag) {
+			errs = append(errs, fmt.Errorf("%w: %s.%s: %s", ErrInvalidTag, typ.Name(), field.Name, problem))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateField returns the problems of one field's stow tag.
+func validateField(typ reflect.Type, field reflect.StructField, tag string) []string {
+	var problems []string
+
+	inline := false
+	for _, part := range strings.Split(tag, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(part, ":")
+		switch key {
+		case "file", "vector", "noversion", "index", "name", "name_field", "mime":
+		case "inline":
+			inline = true
+		case "dim":
+			if dim, err := strconv.Atoi(value); err != nil || dim <= 0 {
+				problems = append(problems, fmt.Sprintf("dim %q is not a positive integer", value))
+			}
+		case "enum":
+			if value == "" || strings.Contains("|"+value+"|", "||") {
+				problems = append(problems, fmt.Sprintf("enum %q has an empty value", value))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("unknown option %q", part))
+		}
+	}
+
+	info := ParseStowTag(tag)
+
+	if info.IsFile && inline {
+		problems = append(problems, "file and inline are exclusive")
+	}
+	if info.IsFile && info.IsVector {
+		problems = append(problems, "file and vector are exclusive")
+	}
+	if info.IsFile && !canHoldBlob(field.Type) {
+		problems = append(problems, fmt.Sprintf("file needs []byte or an io.Reader, not %s", field.Type))
+	}
+	if !info.IsFile && (info.Name != "" || info.NameField != "" || info.MimeType != "") {
+		problems = append(problems, "name, name_field and mime need file")
+	}
+	if info.Name != "" && info.NameField != "" {
+		problems = append(problems, "name and name_field are exclusive")
+	}
+	if info.NameField != "" {
+		ref, ok := typ.FieldByName(info.NameField)
+		switch {
+		case !ok || !ref.IsExported():
+			problems = append(problems, fmt.Sprintf("name_field %s is not an exported field", info.NameField))
+		case ref.Type.Kind() != reflect.String:
+			problems = append(problems, fmt.Sprintf("name_field %s is %s, not a string", info.NameField, ref.Type))
+		}
+	}
+
+	if info.IsVector && !isVectorType(field.Type) {
+		problems = append(problems, fmt.Sprintf("vector needs []float32 or []float64, not %s", field.Type))
+	}
+	if !info.IsVector && info.Dim > 0 {
+		problems = append(problems, "dim needs vector")
+	}
+
+	if info.Index && !isScalarType(field.Type) {
+		problems = append(problems, fmt.Sprintf("index needs a string, number or bool field, not %s", field.Type))
+	}
+
+	if len(info.Enum) > 0 && !isStringType(field.Type) {
+		problems = append(problems, fmt.Sprintf("enum needs a string field, not %s", field.Type))
+	}
+
+	return problems
+}
+
+// canHoldBlob reports whether values of t can be stored as and read back
+// from a blob file.
+func canHoldBlob(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		if t.Implements(readerType) {
+			return true
+		}
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+		return true
+	}
+	return t.Implements(readerType) || reflect.PointerTo(t).Implements(readerType)
+}
+
+// isStringType reports whether t is a string type or a pointer to one.
+func isStringType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.String
+}
+
+// isScalarType reports whether t is a string, number or bool type, or a
+// pointer to one.
+func isScalarType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// isVectorType reports whether t is a vector slice type.
+func isVectorType(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && (t.Elem().Kind() == reflect.Float32 || t.Elem().Kind() == reflect.Float64)
+}
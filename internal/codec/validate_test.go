@@ -0,0 +1,112 @@
+package codec
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type validModel struct {
+	Title     string
+	FileName  string
+	Content   []byte    `stow:"file,name_field:FileName,mime:text/plain"`
+	Avatar    []byte    `stow:"file,name:avatar.jpg"`
+	Stream    io.Reader `stow:"file"`
+	Thumbnail *[]byte   `stow:"inline"`
+	Embedding []float32 `stow:"vector,dim:3"`
+	Counter   int       `stow:"noversion"`
+	State     *string   `stow:"enum:open|closed"`
+	Email     string    `stow:"index"`
+}
+
+func TestValidateTagsValid(t *testing.T) {
+	if err := ValidateTags(reflect.TypeOf(validModel{})); err != nil {
+		t.Fatalf("ValidateTags() = %v, want nil", err)
+	}
+	if err := ValidateTags(reflect.TypeOf(&validModel{})); err != nil {
+		t.Fatalf("ValidateTags(pointer) = %v, want nil", err)
+	}
+}
+
+func TestValidateTagsInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		model interface{}
+		want  string
+	}{
+		{"file on string", struct {
+			Body string `stow:"file"`
+		}{}, "file needs []byte"},
+		{"file and inline", struct {
+			Body []byte `stow:"file,inline"`
+		}{}, "file and inline are exclusive"},
+		{"file and vector", struct {
+			Body []float32 `stow:"file,vector"`
+		}{}, "file and vector are exclusive"},
+		{"missing name_field", struct {
+			Body []byte `stow:"file,name_field:Missing"`
+		}{}, "name_field Missing is not an exported field"},
+		{"non-string name_field", struct {
+			Size int
+			Body []byte `stow:"file,name_field:Size"`
+		}{}, "name_field Size is int, not a string"},
+		{"name without file", struct {
+			Body []byte `stow:"name:a.bin"`
+		}{}, "need file"},
+		{"unknown option", struct {
+			Body []byte `stow:"fiel"`
+		}{}, `unknown option "fiel"`},
+		{"bad dim", struct {
+			Vec []float32 `stow:"vector,dim:x"`
+		}{}, "not a positive integer"},
+		{"vector on string", struct {
+			Vec string `stow:"vector"`
+		}{}, "vector needs []float32 or []float64"},
+		{"enum on int", struct {
+			State int `stow:"enum:a|b"`
+		}{}, "enum needs a string field"},
+		{"empty enum value", struct {
+			State string `stow:"enum:a||b"`
+		}{}, "has an empty value"},
+		{"index on slice", struct {
+			Tags []string `stow:"index"`
+		}{}, "index needs a string, number or bool field"},
+		{"unexported", struct {
+			body []byte `stow:"file"`
+		}{}, "unexported fields are not stored"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTags(reflect.TypeOf(tt.model))
+			if !errors.Is(err, ErrInvalidTag) {
+				t.Fatalf("ValidateTags() = %v, want ErrInvalidTag", err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ValidateTags() = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateTagsReportsAll(t *testing.T) {
+	type model struct {
+		A string `stow:"file"`
+		B []byte `stow:"bogus"`
+	}
+	err := ValidateTags(reflect.TypeOf(model{}))
+	if err == nil {
+		t.Fatal("ValidateTags() = nil, want errors")
+	}
+	if lines := strings.Split(err.Error(), "\n"); len(lines) != 2 {
+		t.Errorf("got %d problems, want 2: %v", len(lines), err)
+	}
+}
+
+func TestValidateTagsNotStruct(t *testing.T) {
+	if err := ValidateTags(reflect.TypeOf(0)); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("ValidateTags(int) = %v, want ErrInvalidTag", err)
+	}
+}
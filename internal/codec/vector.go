@@ -0,0 +1,85 @@
+package codec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// VectorMimeType is the MIME type recorded on blobs holding encoded vectors.
+// Vectors are stored as little-endian float32 values.
+const VectorMimeType = "application/x-stow-vector"
+
+// EncodeVector encodes a float32 vector as little-endian bytes.
+func EncodeVector(vec []float32) []byte {
+	buf := make([]byte, 4*len(vec))
+	for i, v := range vec {
+		binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(v))
+	}
+	return buf
+}
+
+// DecodeVector decodes little-endian bytes produced by EncodeVector.
+func DecodeVector(data []byte) ([]float32, error) {
+	if len(data)%4 != 0 {
+		return nil, fmt.Errorf("vector data length %d is not a multiple of 4", len(data))
+	}
+
+	vec := make([]float32, len(data)/4)
+	for i := range vec {
+		vec[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+	}
+	return vec, nil
+}
+
+// ToVector converts an inline vector value to []float32.
+// Accepts []float32, []float64 and JSON-decoded []interface{} of numbers.
+func ToVector(value interface{}) ([]float32, bool) {
+	switch v := value.(type) {
+	case []float32:
+		return v, true
+	case []float64:
+		vec := make([]float32, len(v))
+		for i, f := range v {
+			vec[i] = float32(f)
+		}
+		return vec, true
+	case []interface{}:
+		vec := make([]float32, len(v))
+		for i, elem := range v {
+			f, ok := elem.(float64)
+			if !ok {
+				return nil, false
+			}
+			vec[i] = float32(f)
+		}
+		return vec, true
+	}
+	return nil, false
+}
+
+// CosineSimilarity returns the cosine similarity of two vectors.
+// Returns 0 if the lengths differ or either vector has zero magnitude.
+func CosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// vectorFields returns the tag info of top-level struct fields tagged `stow:"vector"`,
+// keyed by their serialized field name. Returns nil for non-struct values.
+func vectorFields(value interface{}) map[string]TagInfo {
+	return taggedFields(value, func(info TagInfo) bool { return info.IsVector })
+}
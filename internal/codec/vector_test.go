@@ -0,0 +1,92 @@
+package codec
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeDecodeVector(t *testing.T) {
+	original := []float32{0, 1.5, -2.25, float32(math.Pi)}
+
+	encoded := EncodeVector(original)
+	if len(encoded) != 16 {
+		t.Fatalf("encoded length = %d, want 16", len(encoded))
+	}
+
+	decoded, err := DecodeVector(encoded)
+	if err != nil {
+		t.Fatalf("DecodeVector failed: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, original) {
+		t.Errorf("decoded = %v, want %v", decoded, original)
+	}
+
+	if _, err := DecodeVector([]byte{1, 2, 3}); err == nil {
+		t.Error("DecodeVector should reject truncated data")
+	}
+}
+
+func TestToVector(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		ok    bool
+	}{
+		{"float32 slice", []float32{1, 2}, true},
+		{"float64 slice", []float64{1, 2}, true},
+		{"json array", []interface{}{1.0, 2.0}, true},
+		{"mixed json array", []interface{}{1.0, "x"}, false},
+		{"string", "not a vector", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vec, ok := ToVector(tt.value)
+			if ok != tt.ok {
+				t.Fatalf("ToVector ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && !reflect.DeepEqual(vec, []float32{1, 2}) {
+				t.Errorf("vec = %v, want [1 2]", vec)
+			}
+		})
+	}
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	a := []float32{1, 0}
+	b := []float32{0, 1}
+
+	if got := CosineSimilarity(a, a); math.Abs(got-1) > 1e-9 {
+		t.Errorf("similarity with self = %v, want 1", got)
+	}
+	if got := CosineSimilarity(a, b); math.Abs(got) > 1e-9 {
+		t.Errorf("orthogonal similarity = %v, want 0", got)
+	}
+	if got := CosineSimilarity(a, []float32{1, 0, 0}); got != 0 {
+		t.Errorf("mismatched length similarity = %v, want 0", got)
+	}
+	if got := CosineSimilarity(a, []float32{0, 0}); got != 0 {
+		t.Errorf("zero vector similarity = %v, want 0", got)
+	}
+}
+
+func TestVectorFields(t *testing.T) {
+	type Doc struct {
+		Title     string    `json:"title"`
+		Embedding []float32 `json:"embedding" stow:"vector,dim:3"`
+		Other     []float32
+	}
+
+	fields := vectorFields(&Doc{})
+	if len(fields) != 1 {
+		t.Fatalf("expected 1 vector field, got %d", len(fields))
+	}
+	if fields["embedding"].Dim != 3 {
+		t.Errorf("embedding dim = %d, want 3", fields["embedding"].Dim)
+	}
+
+	if vectorFields(map[string]interface{}{}) != nil {
+		t.Error("non-struct values should have no vector fields")
+	}
+}
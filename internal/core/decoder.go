@@ -3,20 +3,80 @@ package core
 import (
 	"bufio"
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"strings"
+	"time"
+
+	"github.com/aigotowork/stow/internal/fsutil"
+	"github.com/aigotowork/stow/internal/seal"
 )
 
+// DefaultMaxLineBytes is the longest line a Decoder reads when
+// MaxLineBytes is not set.
+const DefaultMaxLineBytes = 64 << 20 // 64MB
+
+// StopStream can be returned by a Stream callback to stop reading early
+// without an error.
+var StopStream = errors.New("stop stream")
+
+// ErrLimitExceeded is matched (with errors.Is) by every LimitError.
+var ErrLimitExceeded = errors.New("decoder limit exceeded")
+
+// LimitError reports a line or record data over a Decoder limit.
+type LimitError struct {
+	// What is over the limit: "line" or "record data"
+	What string
+
+	// Size in bytes; for lines it is the size read when the limit was hit
+	Size int
+
+	// Max is the limit that was exceeded
+	Max int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s of %d bytes exceeds limit of %d bytes", e.What, e.Size, e.Max)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
 // Decoder decodes JSONL format to Records.
-type Decoder struct{}
+// Limits must be set before the decoder is used.
+type Decoder struct {
+	// key decrypts sealed record data when set
+	key *seal.Key
+
+	// MaxLineBytes is the longest line (newline excluded) the decoder reads.
+	// 0 means DefaultMaxLineBytes.
+	MaxLineBytes int
+
+	// MaxRecordDataBytes is the largest encoded data of a record (after
+	// decryption) the decoder unmarshals. 0 means no limit.
+	MaxRecordDataBytes int
+
+	// Files provides the handles of StreamFile and ReadLastVisible. nil
+	// opens and closes a file per read.
+	Files *fsutil.FilePool
+}
 
 // NewDecoder creates a new Decoder.
 func NewDecoder() *Decoder {
 	return &Decoder{}
 }
 
+// SetKey makes the decoder decrypt sealed record data with key.
+// Without a key, sealed records decode with their SealedField data as is.
+func (d *Decoder) SetKey(key *seal.Key) {
+	d.key = key
+}
+
 // Decode decodes a single line of JSON to a Record.
 // Returns an error if the line is not valid JSON or doesn't match the Record structure.
 func (d *Decoder) Decode(line []byte) (*Record, error) {
@@ -25,8 +85,16 @@ func (d *Decoder) Decode(line []byte) (*Record, error) {
 		return nil, fmt.Errorf("empty line")
 	}
 
+	if maxLine := d.maxLineBytes(); len(line) > maxLine {
+		return nil, &LimitError{What: "line", Size: len(line), Max: maxLine}
+	}
+
 	var record Record
-	if err := json.Unmarshal(line, &record); err != nil {
+	if d.MaxRecordDataBytes > 0 {
+		if err := d.decodeLimited(line, &record); err != nil {
+			return nil, err
+		}
+	} else if err := json.Unmarshal(line, &record); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal record: %w", err)
 	}
 
@@ -34,44 +102,175 @@ func (d *Decoder) Decode(line []byte) (*Record, error) {
 		return nil, fmt.Errorf("invalid record structure")
 	}
 
+	if d.key != nil {
+		if err := d.openData(&record); err != nil {
+			return nil, err
+		}
+	}
+
 	return &record, nil
 }
 
-// DecodeString decodes a JSON string to a Record.
-func (d *Decoder) DecodeString(line string) (*Record, error) {
-	return d.Decode([]byte(line))
+// decodeLimited decodes line into record, checking the size of the encoded
+// data before it is unmarshaled.
+func (d *Decoder) decodeLimited(line []byte, record *Record) error {
+	var raw struct {
+		Meta *Meta           `json:"_meta"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(line, &raw); err != nil {
+		return fmt.Errorf("failed to unmarshal record: %w", err)
+	}
+	if err := d.checkDataSize(raw.Data); err != nil {
+		return err
+	}
+
+	record.Meta = raw.Meta
+	if len(raw.Data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw.Data, &record.Data); err != nil {
+		return fmt.Errorf("failed to unmarshal record: %w", err)
+	}
+	return nil
 }
 
-// ReadAll reads all records from a file.
-// Returns all successfully decoded records.
-// Skips lines that can't be decoded (logs them but doesn't fail).
-func (d *Decoder) ReadAll(filePath string) ([]*Record, error) {
-	f, err := os.Open(filePath)
+// checkDataSize returns a LimitError if data is over MaxRecordDataBytes.
+func (d *Decoder) checkDataSize(data []byte) error {
+	if d.MaxRecordDataBytes > 0 && len(data) > d.MaxRecordDataBytes {
+		return &LimitError{What: "record data", Size: len(data), Max: d.MaxRecordDataBytes}
+	}
+	return nil
+}
+
+// maxLineBytes returns MaxLineBytes or its default.
+func (d *Decoder) maxLineBytes() int {
+	if d.MaxLineBytes > 0 {
+		return d.MaxLineBytes
+	}
+	return DefaultMaxLineBytes
+}
+
+// openData replaces sealed record data with its decrypted contents.
+// Plain records are left untouched.
+func (d *Decoder) openData(record *Record) error {
+	encoded, ok := record.Data[SealedField].(string)
+	if !ok || len(record.Data) != 1 {
+		return nil
+	}
+
+	plaintext, err := d.open(record.Meta, encoded)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %w", err)
+		return err
+	}
+	if err := d.checkDataSize(plaintext); err != nil {
+		return err
 	}
-	defer f.Close()
 
-	var records []*Record
-	scanner := bufio.NewScanner(f)
+	var data map[string]interface{}
+	if err := json.Unmarshal(plaintext, &data); err != nil {
+		return fmt.Errorf("failed to unmarshal sealed data: %w", err)
+	}
 
-	lineNum := 0
-	for scanner.Scan() {
-		lineNum++
-		line := scanner.Bytes()
+	record.Data = data
+	return nil
+}
 
-		record, err := d.Decode(line)
-		if err != nil {
-			// Skip invalid lines but continue reading
-			// In production, this should log a warning
+// open decrypts the encoded SealedField of a record with meta. Data sealed
+// before records were bound to their key and version opens unbound.
+func (d *Decoder) open(meta *Meta, encoded string) ([]byte, error) {
+	var aad []byte
+	if rest, ok := strings.CutPrefix(encoded, boundPrefix); ok {
+		encoded, aad = rest, sealAAD(meta)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sealed data: %w", err)
+	}
+	return d.key.Open(sealed, aad)
+}
+
+// Stream decodes the records read from r and calls fn for each in order.
+// Only the current line is held in memory, so files of any size can be read.
+// Lines that can't be decoded are skipped, but a line or record data over
+// the decoder's limits stops the stream with a *LimitError: skipping it
+// would silently expose an older version as the latest.
+// Stream stops at the first error returned by fn and returns it, except for
+// StopStream, which ends the stream early with a nil error.
+func (d *Decoder) Stream(r io.Reader, fn func(*Record) error) error {
+	maxLine := d.maxLineBytes()
+
+	reader := bufio.NewReader(r)
+	var line []byte
+
+	for {
+		chunk, err := reader.ReadSlice('\n')
+
+		// Fail before buffering more than the limit
+		line = append(line, chunk...)
+		if len(bytes.TrimSuffix(line, []byte{'\n'})) > maxLine {
+			return &LimitError{What: "line", Size: len(line), Max: maxLine}
+		}
+
+		if err == bufio.ErrBufferFull {
 			continue
 		}
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("error reading file: %w", err)
+		}
 
-		records = append(records, record)
+		if len(bytes.TrimSpace(line)) > 0 {
+			record, decodeErr := d.Decode(line)
+			if errors.Is(decodeErr, ErrLimitExceeded) {
+				return decodeErr
+			}
+			// Skip invalid lines but continue reading
+			if decodeErr == nil {
+				if fnErr := fn(record); fnErr != nil {
+					if fnErr == StopStream {
+						return nil
+					}
+					return fnErr
+				}
+			}
+		}
+
+		line = line[:0]
+
+		if err == io.EOF {
+			return nil
+		}
 	}
+}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading file: %w", err)
+// StreamFile is Stream over the records of a file.
+func (d *Decoder) StreamFile(filePath string, fn func(*Record) error) error {
+	f, err := d.Files.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer d.Files.Release(filePath, f)
+
+	return d.Stream(f, fn)
+}
+
+// DecodeString decodes a JSON string to a Record.
+func (d *Decoder) DecodeString(line string) (*Record, error) {
+	return d.Decode([]byte(line))
+}
+
+// ReadAll reads all records from a file.
+// Returns all successfully decoded records.
+// Skips lines that can't be decoded and fails on limits (see Stream).
+func (d *Decoder) ReadAll(filePath string) ([]*Record, error) {
+	var records []*Record
+	err := d.StreamFile(filePath, func(record *Record) error {
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return records, nil
@@ -80,6 +279,7 @@ func (d *Decoder) ReadAll(filePath string) ([]*Record, error) {
 // ReadLastValid reads the file from the end and returns the last valid "put" record.
 // This is used by Get() to find the most recent value.
 // Returns nil if no valid "put" record is found or if the key is deleted.
+// Records scheduled after the current time are skipped.
 //
 // Algorithm:
 // 1. Read file in 4KB chunks from the end
@@ -94,27 +294,75 @@ func (d *Decoder) ReadLastValid(filePath string) (*Record, error) {
 // ReadLastValidReverse implements efficient reverse file reading using 4KB chunks.
 // This minimizes memory usage for large files.
 func (d *Decoder) ReadLastValidReverse(filePath string) (*Record, error) {
-	f, err := os.Open(filePath)
+	record, _, err := d.ReadLastVisible(filePath, time.Now())
+	return record, err
+}
+
+// ReadLastVisible is ReadLastValidReverse for records visible at now.
+// pending reports whether newer records scheduled after now were skipped.
+func (d *Decoder) ReadLastVisible(filePath string, now time.Time) (record *Record, pending bool, err error) {
+	readErr := d.ReadReverse(filePath, func(r *Record, decodeErr error) bool {
+		if errors.Is(decodeErr, ErrLimitExceeded) {
+			err = decodeErr
+			return false
+		}
+		if decodeErr != nil {
+			// Skip invalid lines
+			return true
+		}
+
+		// Scheduled records don't exist for readers yet
+		if !r.Meta.IsVisible(now) {
+			pending = true
+			return true
+		}
+
+		// If it's a delete operation, key is deleted
+		if r.Meta.IsDelete() {
+			return false
+		}
+
+		// If it's a put operation, return it
+		if r.Meta.IsPut() {
+			record = r
+			return false
+		}
+		return true
+	})
+	if readErr != nil {
+		return nil, false, readErr
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %w", err)
+		return nil, pending, err
 	}
-	defer f.Close()
 
-	// Get file size
-	stat, err := f.Stat()
+	return record, pending, nil
+}
+
+// ReadReverse calls fn for every line of a file, last line first, until fn
+// returns false. Lines that can't be decoded are passed with a nil record
+// and their error; a line over MaxLineBytes gets a *LimitError as soon as
+// the limit is hit, and is skipped without being buffered if fn continues.
+// The file is read in 4KB chunks from the end.
+func (d *Decoder) ReadReverse(filePath string, fn func(record *Record, err error) bool) error {
+	f, err := d.Files.Open(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to stat file: %w", err)
+		return fmt.Errorf("failed to open file: %w", err)
 	}
-	fileSize := stat.Size()
+	defer d.Files.Release(filePath, f)
 
-	if fileSize == 0 {
-		return nil, nil
+	// Get file size
+	stat, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
 	}
 
 	const chunkSize = 4096 // 4KB chunks
+	maxLine := d.maxLineBytes()
 	buffer := make([]byte, chunkSize)
 	var remainder []byte // Incomplete line from previous chunk
-	pos := fileSize
+	oversized := false   // Inside a skipped line over the limit
+	pos := stat.Size()
 
 	for pos > 0 {
 		// Determine how much to read
@@ -128,13 +376,21 @@ func (d *Decoder) ReadLastValidReverse(filePath string) (*Record, error) {
 
 		// Read chunk
 		if _, err := f.ReadAt(buffer[:readSize], pos); err != nil && err != io.EOF {
-			return nil, fmt.Errorf("failed to read chunk: %w", err)
+			return fmt.Errorf("failed to read chunk: %w", err)
 		}
 
 		chunk := buffer[:readSize]
 
-		// Combine with remainder from previous iteration
-		if len(remainder) > 0 {
+		if oversized {
+			// Drop the start of the skipped line
+			end := bytes.LastIndexByte(chunk, '\n')
+			if end < 0 {
+				continue
+			}
+			chunk = chunk[:end]
+			oversized = false
+		} else if len(remainder) > 0 {
+			// Combine with remainder from previous iteration
 			chunk = append(chunk, remainder...)
 		}
 
@@ -143,8 +399,9 @@ func (d *Decoder) ReadLastValidReverse(filePath string) (*Record, error) {
 
 		// The last element is either empty (if chunk ended with \n) or incomplete
 		if pos > 0 {
-			// Save the incomplete first line for next iteration
-			remainder = lines[0]
+			// Save the incomplete first line for next iteration; copied
+			// because the next read reuses buffer
+			remainder = append([]byte(nil), lines[0]...)
 			lines = lines[1:]
 		} else {
 			// At beginning of file, include the first line if not empty
@@ -160,43 +417,98 @@ func (d *Decoder) ReadLastValidReverse(filePath string) (*Record, error) {
 				continue // Skip empty lines
 			}
 
-			record, err := d.Decode(line)
-			if err != nil {
-				// Skip invalid lines
-				continue
+			if !fn(d.Decode(line)) {
+				return nil
 			}
+		}
 
-			// If it's a delete operation, key is deleted
-			if record.Meta.IsDelete() {
-				return nil, nil
+		if len(remainder) > maxLine {
+			limitErr := &LimitError{What: "line", Size: len(remainder), Max: maxLine}
+			remainder = nil
+			oversized = true
+			if !fn(nil, limitErr) {
+				return nil
 			}
+		}
+	}
 
-			// If it's a put operation, return it
-			if record.Meta.IsPut() {
-				return record, nil
-			}
+	return nil
+}
+
+// ReadTail reads the last line of a file without reading the rest, for
+// replacing the file's latest record in place. It returns the line without
+// its newline, the offset it starts at and the record it decodes to, or the
+// decode error.
+func (d *Decoder) ReadTail(filePath string) (record *Record, line []byte, offset int64, err error) {
+	f, err := d.Files.Open(filePath)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer d.Files.Release(filePath, f)
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	const chunkSize = 4096 // 4KB chunks
+	maxLine := d.maxLineBytes()
+	var tail []byte
+	pos := stat.Size()
+	for {
+		if pos == 0 {
+			line = bytes.TrimRight(tail, "\r\n\t ")
+			break
+		}
+
+		readSize := int64(min(chunkSize, pos))
+		pos -= readSize
+		chunk := make([]byte, readSize)
+		if _, err := f.ReadAt(chunk, pos); err != nil && err != io.EOF {
+			return nil, nil, 0, fmt.Errorf("failed to read chunk: %w", err)
+		}
+		tail = append(chunk, tail...)
+
+		trimmed := bytes.TrimRight(tail, "\r\n\t ")
+		if i := bytes.LastIndexByte(trimmed, '\n'); i >= 0 {
+			pos += int64(i + 1)
+			line = trimmed[i+1:]
+			break
+		}
+		if len(trimmed) > maxLine {
+			return nil, nil, 0, &LimitError{What: "line", Size: len(trimmed), Max: maxLine}
 		}
 	}
+	if len(line) == 0 {
+		return nil, nil, 0, fmt.Errorf("file has no records")
+	}
 
-	// No valid record found
-	return nil, nil
+	record, err = d.Decode(line)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return record, line, pos, nil
 }
 
 // ReadVersion reads a specific version from a file.
 // Returns the record with the specified version number.
 func (d *Decoder) ReadVersion(filePath string, version int) (*Record, error) {
-	records, err := d.ReadAll(filePath)
+	var found *Record
+	err := d.StreamFile(filePath, func(record *Record) error {
+		if record.Meta.Version == version {
+			found = record
+			return StopStream
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	for _, record := range records {
-		if record.Meta.Version == version {
-			return record, nil
-		}
+	if found == nil {
+		return nil, fmt.Errorf("version %d not found", version)
 	}
-
-	return nil, fmt.Errorf("version %d not found", version)
+	return found, nil
 }
 
 // CountLines counts the number of lines in a file.
@@ -208,14 +520,27 @@ func CountLines(filePath string) (int, error) {
 	}
 	defer f.Close()
 
+	// Count newlines in chunks: lines may be longer than any scanner buffer
 	count := 0
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		count++
+	last := byte('\n')
+	buffer := make([]byte, 32*1024)
+	for {
+		n, err := f.Read(buffer)
+		if n > 0 {
+			count += bytes.Count(buffer[:n], []byte{'\n'})
+			last = buffer[n-1]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("error reading file: %w", err)
+		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		return 0, fmt.Errorf("error reading file: %w", err)
+	// A last line without newline counts too
+	if last != '\n' {
+		count++
 	}
 
 	return count, nil
@@ -229,21 +554,14 @@ func (d *Decoder) GetLatestVersion(filePath string) (int, error) {
 		return 0, nil
 	}
 
-	records, err := d.ReadAll(filePath)
-	if err != nil {
-		return 0, err
-	}
-
-	if len(records) == 0 {
-		return 0, nil
-	}
-
 	// Find the maximum version
 	maxVersion := 0
-	for _, record := range records {
-		if record.Meta.Version > maxVersion {
-			maxVersion = record.Meta.Version
-		}
+	err := d.StreamFile(filePath, func(record *Record) error {
+		maxVersion = max(maxVersion, record.Meta.Version)
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
 
 	return maxVersion, nil
@@ -251,11 +569,29 @@ func (d *Decoder) GetLatestVersion(filePath string) (int, error) {
 
 // AppendRecord appends a record to a file (JSONL append-only mode).
 func AppendRecord(filePath string, record *Record) error {
-	// Encode the record
-	encoder := NewEncoder()
-	data, err := encoder.Encode(record)
-	if err != nil {
-		return fmt.Errorf("failed to encode record: %w", err)
+	return NewEncoder().Append(filePath, record)
+}
+
+// Append encodes a record and appends it to a file (JSONL append-only mode).
+func (e *Encoder) Append(filePath string, record *Record) error {
+	return e.AppendAll(filePath, []*Record{record})
+}
+
+// AppendAll appends records in order with a single write and sync.
+func (e *Encoder) AppendAll(filePath string, records []*Record) error {
+	// Encode the records
+	var data []byte
+	for _, record := range records {
+		line, err := e.Encode(record)
+		if err != nil {
+			return fmt.Errorf("failed to encode record: %w", err)
+		}
+		data = append(data, line...)
+	}
+
+	// O_APPEND isn't atomic across NFS clients
+	if e.networkFS {
+		return fsutil.AppendLocked(filePath, data, 0644)
 	}
 
 	// Open file in append mode
@@ -278,20 +614,55 @@ func AppendRecord(filePath string, record *Record) error {
 	return nil
 }
 
+// RewriteRecords atomically replaces the contents of a file with the given records.
+func RewriteRecords(filePath string, records []*Record) error {
+	return NewEncoder().Rewrite(filePath, records)
+}
+
+// Rewrite atomically replaces the contents of a file with the encoded records.
+func (e *Encoder) Rewrite(filePath string, records []*Record) error {
+	var buf bytes.Buffer
+	for _, record := range records {
+		data, err := e.Encode(record)
+		if err != nil {
+			return fmt.Errorf("failed to encode record: %w", err)
+		}
+		buf.Write(data)
+	}
+
+	write := fsutil.AtomicWriteFile
+	if e.networkFS {
+		write = fsutil.NetworkWriteFile
+	}
+	if err := write(filePath, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
+
 // ReadLastNRecords reads the last N records from a file.
 // Used for compaction to keep recent history.
 func (d *Decoder) ReadLastNRecords(filePath string, n int) ([]*Record, error) {
-	records, err := d.ReadAll(filePath)
-	if err != nil {
-		return nil, err
+	if n <= 0 {
+		return nil, nil
 	}
 
-	if len(records) <= n {
-		return records, nil
+	// Keep a window of the last n records while streaming
+	var records []*Record
+	err := d.StreamFile(filePath, func(record *Record) error {
+		if len(records) == n {
+			copy(records, records[1:])
+			records = records[:n-1]
+		}
+		records = append(records, record)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Return last N records
-	return records[len(records)-n:], nil
+	return records, nil
 }
 
 // ReadLines reads all lines from a reader.
@@ -2,10 +2,12 @@ package core
 
 import (
 	"bytes"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 // TestDecodeString tests the DecodeString function with various inputs
@@ -505,6 +507,36 @@ func TestReadLastValidReverseLargeFile(t *testing.T) {
 	}
 }
 
+// TestReadTail tests reading the last line of a file and its offset
+func TestReadTail(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "tail.jsonl")
+
+	encoder := NewEncoder()
+	var content []byte
+	for i := 1; i <= 200; i++ {
+		data, _ := encoder.Encode(NewPutRecord("key", i, map[string]interface{}{"value": i}))
+		content = append(content, data...)
+	}
+	os.WriteFile(testFile, append(content, '\n'), 0644)
+
+	record, line, offset, err := NewDecoder().ReadTail(testFile)
+	if err != nil {
+		t.Fatalf("ReadTail() error = %v", err)
+	}
+	if record.Meta.Version != 200 {
+		t.Errorf("Expected version 200, got %d", record.Meta.Version)
+	}
+	if want := bytes.TrimSpace(content[offset:]); !bytes.Equal(line, want) {
+		t.Errorf("Offset %d doesn't start the last line %q", offset, line)
+	}
+
+	// A single line starts the file
+	os.WriteFile(testFile, content[:bytes.IndexByte(content, '\n')+1], 0644)
+	if record, _, offset, err := NewDecoder().ReadTail(testFile); err != nil || offset != 0 || record.Meta.Version != 1 {
+		t.Errorf("ReadTail() = %v, %d, %v; want version 1 at 0", record, offset, err)
+	}
+}
+
 // TestReadVersionNotFound tests reading a version that doesn't exist
 func TestReadVersionNotFound(t *testing.T) {
 	tmpDir := t.TempDir()
@@ -559,3 +591,242 @@ func TestAppendRecordNilRecord(t *testing.T) {
 		t.Error("Expected error for nil record")
 	}
 }
+
+// TestReadLastVisible tests that scheduled records are skipped until their time
+func TestReadLastVisible(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "scheduled.jsonl")
+
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	scheduled := NewPutRecord("key", 2, map[string]interface{}{"value": "new"})
+	scheduled.Meta.VisibleAt = now.Add(time.Hour)
+
+	encoder := NewEncoder()
+	f, _ := os.Create(testFile)
+	for _, record := range []*Record{
+		NewPutRecord("key", 1, map[string]interface{}{"value": "old"}),
+		scheduled,
+	} {
+		data, _ := encoder.Encode(record)
+		f.Write(data)
+	}
+	f.Close()
+
+	decoder := NewDecoder()
+
+	record, pending, err := decoder.ReadLastVisible(testFile, now)
+	if err != nil {
+		t.Fatalf("ReadLastVisible() error = %v", err)
+	}
+	if record == nil || record.Meta.Version != 1 || !pending {
+		t.Errorf("before activation: record = %+v, pending = %v", record, pending)
+	}
+
+	record, pending, err = decoder.ReadLastVisible(testFile, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("ReadLastVisible() error = %v", err)
+	}
+	if record == nil || record.Meta.Version != 2 || pending {
+		t.Errorf("after activation: record = %+v, pending = %v", record, pending)
+	}
+	if !record.Meta.VisibleAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("VisibleAt = %v after round trip", record.Meta.VisibleAt)
+	}
+}
+
+// TestReadReverse tests reading lines newest first, past invalid and long lines
+func TestReadReverse(t *testing.T) {
+	tmpDir := t.TempDir()
+	testFile := filepath.Join(tmpDir, "reverse.jsonl")
+
+	long := strings.Repeat("x", 20*1024)
+	input := strings.Join([]string{
+		`{"_meta":{"k":"a","v":1,"op":"put","ts":"2025-12-14T18:09:00Z"},"data":{"n":1}}`,
+		`{"_meta":{"k":"a","v":2,"op":"put","ts":"2025-12-14T18:09:00Z"},"data":{"long":"` + long + `"}}`,
+		`not json`,
+		``,
+	}, "\n")
+	if err := os.WriteFile(testFile, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	decoder := NewDecoder()
+	decoder.MaxLineBytes = 1024
+
+	var versions []int
+	var errs []error
+	err := decoder.ReadReverse(testFile, func(r *Record, err error) bool {
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			versions = append(versions, r.Meta.Version)
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatalf("ReadReverse failed: %v", err)
+	}
+	if len(versions) != 1 || versions[0] != 1 {
+		t.Errorf("Expected versions [1], got %v", versions)
+	}
+	if len(errs) != 2 || errors.Is(errs[0], ErrLimitExceeded) || !errors.Is(errs[1], ErrLimitExceeded) {
+		t.Errorf("Expected a decode error then a LimitError, got %v", errs)
+	}
+
+	// The long line still fails ReadLastVisible
+	if _, _, err := decoder.ReadLastVisible(testFile, time.Now()); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Expected ReadLastVisible to fail with ErrLimitExceeded, got %v", err)
+	}
+}
+
+// TestStream tests streaming records from a reader
+func TestStream(t *testing.T) {
+	long := strings.Repeat("x", 200*1024)
+	input := strings.Join([]string{
+		`{"_meta":{"k":"a","v":1,"op":"put","ts":"2025-12-14T18:09:00Z"},"data":{"n":1}}`,
+		`not json`,
+		``,
+		`{"_meta":{"k":"a","v":2,"op":"put","ts":"2025-12-14T18:09:00Z"},"data":{"long":"` + long + `"}}`,
+		`{"_meta":{"k":"a","v":3,"op":"delete","ts":"2025-12-14T18:09:00Z"},"data":null}`,
+	}, "\n")
+
+	decoder := NewDecoder()
+
+	var versions []int
+	err := decoder.Stream(strings.NewReader(input), func(r *Record) error {
+		versions = append(versions, r.Meta.Version)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream failed: %v", err)
+	}
+	if len(versions) != 3 || versions[1] != 2 {
+		t.Errorf("Expected versions [1 2 3] (long line included), got %v", versions)
+	}
+
+	// A line over the limit stops the stream
+	decoder.MaxLineBytes = 1024
+	versions = nil
+	err = decoder.Stream(strings.NewReader(input), func(r *Record) error {
+		versions = append(versions, r.Meta.Version)
+		return nil
+	})
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.What != "line" || limitErr.Max != 1024 {
+		t.Errorf("Expected line LimitError with a 1KB limit, got %v", err)
+	}
+	if len(versions) != 1 || versions[0] != 1 {
+		t.Errorf("Expected versions [1] before the long line, got %v", versions)
+	}
+
+	// StopStream ends early without error
+	count := 0
+	err = decoder.Stream(strings.NewReader(input), func(r *Record) error {
+		count++
+		return StopStream
+	})
+	if err != nil || count != 1 {
+		t.Errorf("StopStream: count=%d err=%v", count, err)
+	}
+
+	// Other errors are returned
+	errStop := errors.New("boom")
+	err = decoder.Stream(strings.NewReader(input), func(r *Record) error {
+		return errStop
+	})
+	if !errors.Is(err, errStop) {
+		t.Errorf("Expected callback error, got %v", err)
+	}
+}
+
+// TestDecoderLimits tests MaxLineBytes and MaxRecordDataBytes
+func TestDecoderLimits(t *testing.T) {
+	line := []byte(`{"_meta":{"k":"a","v":1,"op":"put","ts":"2025-12-14T18:09:00Z"},"data":{"s":"` + strings.Repeat("z", 500) + `"}}`)
+
+	decoder := NewDecoder()
+	if _, err := decoder.Decode(line); err != nil {
+		t.Fatalf("Decode without limits failed: %v", err)
+	}
+
+	decoder.MaxRecordDataBytes = 100
+	_, err := decoder.Decode(line)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.What != "record data" || limitErr.Size != 508 {
+		t.Errorf("Expected record data LimitError, got %v", err)
+	}
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("LimitError should match ErrLimitExceeded")
+	}
+
+	decoder.MaxRecordDataBytes = 1000
+	record, err := decoder.Decode(line)
+	if err != nil || len(record.Data["s"].(string)) != 500 {
+		t.Errorf("Decode under the data limit: %v", err)
+	}
+
+	decoder.MaxLineBytes = 100
+	if _, err := decoder.Decode(line); !errors.As(err, &limitErr) || limitErr.What != "line" {
+		t.Errorf("Expected line LimitError, got %v", err)
+	}
+
+	// The reverse reader fails on the oversized latest record instead of
+	// falling back to an older version
+	path := filepath.Join(t.TempDir(), "limits.jsonl")
+	small := `{"_meta":{"k":"a","v":1,"op":"put","ts":"2025-12-14T18:09:00Z"},"data":{"n":1}}`
+	big := `{"_meta":{"k":"a","v":2,"op":"put","ts":"2025-12-14T18:09:00Z"},"data":{"s":"` + strings.Repeat("z", 10000) + `"}}`
+	if err := os.WriteFile(path, []byte(small+"\n"+big+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	decoder = NewDecoder()
+	decoder.MaxLineBytes = 5000
+	if _, err := decoder.ReadLastValid(path); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("ReadLastValid: expected ErrLimitExceeded, got %v", err)
+	}
+	if _, err := decoder.ReadAll(path); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("ReadAll: expected ErrLimitExceeded, got %v", err)
+	}
+
+	decoder.MaxLineBytes = 0
+	record, err = decoder.ReadLastValid(path)
+	if err != nil || record == nil || record.Meta.Version != 2 {
+		t.Errorf("ReadLastValid with default limit: record=%v err=%v", record, err)
+	}
+}
+
+// TestReadAllLongLines tests that files with lines longer than a scanner
+// buffer are read and counted
+func TestReadAllLongLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "long.jsonl")
+
+	encoder := NewEncoder()
+	var content []byte
+	for v := 1; v <= 3; v++ {
+		line, err := encoder.Encode(NewPutRecord("big", v, map[string]interface{}{
+			"blob": strings.Repeat("y", 100*1024),
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		content = append(content, line...)
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	decoder := NewDecoder()
+	records, err := decoder.ReadAll(path)
+	if err != nil || len(records) != 3 {
+		t.Fatalf("ReadAll: %d records, %v", len(records), err)
+	}
+
+	last, err := decoder.ReadLastNRecords(path, 2)
+	if err != nil || len(last) != 2 || last[0].Meta.Version != 2 || last[1].Meta.Version != 3 {
+		t.Errorf("ReadLastNRecords: %v, %v", last, err)
+	}
+
+	if n, err := CountLines(path); err != nil || n != 3 {
+		t.Errorf("CountLines = %d, %v; want 3", n, err)
+	}
+}
@@ -1,18 +1,63 @@
 package core
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/aigotowork/stow/internal/seal"
+	"github.com/aigotowork/stow/internal/sign"
 )
 
+// SealedField is the only data field of a record in an encrypted namespace.
+// It holds the base64 encoding of the sealed JSON data.
+//
+// Example output:
+//
+//	{"_meta":{"k":"key","v":1,"op":"put","ts":"2025-12-14T18:09:00Z"},"data":{"$sealed":"q2x..."}}
+const SealedField = "$sealed"
+
+// boundPrefix marks sealed data bound to its record's key and version (see
+// sealAAD). Data sealed before binding has no prefix and opens without.
+const boundPrefix = "b:"
+
+// sealAAD returns the associated data sealed record data is bound to, so
+// it can't be moved to another key or version.
+func sealAAD(meta *Meta) []byte {
+	return []byte(strconv.Itoa(meta.Version) + ":" + meta.Key)
+}
+
 // Encoder encodes Records to JSONL format.
-type Encoder struct{}
+type Encoder struct {
+	// key encrypts record data when set
+	key *seal.Key
+
+	// signer signs new and resigned records when set
+	signer atomic.Pointer[sign.Key]
+
+	// networkFS switches to writes safe on network filesystems
+	networkFS bool
+}
 
 // NewEncoder creates a new Encoder.
 func NewEncoder() *Encoder {
 	return &Encoder{}
 }
 
+// SetKey makes the encoder encrypt record data with key (nil disables encryption).
+// Metadata stays in plain text so keys and versions can be scanned without the key.
+func (e *Encoder) SetKey(key *seal.Key) {
+	e.key = key
+}
+
+// SetNetworkFS makes Append and Rewrite use fsutil.AppendLocked and
+// fsutil.NetworkWriteFile, for files on NFS or SMB volumes.
+func (e *Encoder) SetNetworkFS(on bool) {
+	e.networkFS = on
+}
+
 // Encode encodes a Record to a single line of JSON.
 // Returns the JSON bytes with a newline appended.
 //
@@ -28,6 +73,20 @@ func (e *Encoder) Encode(record *Record) ([]byte, error) {
 		return nil, fmt.Errorf("invalid record")
 	}
 
+	if signer := e.signer.Load(); signer != nil && record.sign {
+		if err := signRecord(record, signer); err != nil {
+			return nil, err
+		}
+	}
+
+	if e.key != nil && record.Data != nil {
+		sealed, err := e.sealData(record.Meta, record.Data)
+		if err != nil {
+			return nil, err
+		}
+		record = &Record{Meta: record.Meta, Data: sealed}
+	}
+
 	// Marshal to JSON
 	data, err := json.Marshal(record)
 	if err != nil {
@@ -48,3 +107,20 @@ func (e *Encoder) EncodeToString(record *Record) (string, error) {
 	}
 	return string(data), nil
 }
+
+// sealData encrypts record data into a single SealedField, bound to meta.
+func (e *Encoder) sealData(meta *Meta, data map[string]interface{}) (map[string]interface{}, error) {
+	plaintext, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal data: %w", err)
+	}
+
+	sealed, err := e.key.Seal(plaintext, sealAAD(meta))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt data: %w", err)
+	}
+
+	return map[string]interface{}{
+		SealedField: boundPrefix + base64.StdEncoding.EncodeToString(sealed),
+	}, nil
+}
@@ -1,9 +1,14 @@
 package core
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"strings"
 	"testing"
+
+	"github.com/aigotowork/stow/internal/seal"
 )
 
 // TestEncodeToString tests the EncodeToString function
@@ -458,3 +463,66 @@ func TestEncodeNilData(t *testing.T) {
 		t.Error("Encode() should fail for put record with nil data")
 	}
 }
+
+func TestEncodeSealed(t *testing.T) {
+	key, err := seal.NewKey(bytes.Repeat([]byte{3}, seal.KeySize))
+	if err != nil {
+		t.Fatalf("NewKey failed: %v", err)
+	}
+
+	encoder := NewEncoder()
+	encoder.SetKey(key)
+
+	record := NewPutRecord("user:alice", 1, map[string]interface{}{"name": "Alice"})
+	line, err := encoder.Encode(record)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if strings.Contains(string(line), "Alice") {
+		t.Errorf("sealed record contains plaintext: %s", line)
+	}
+	if !strings.Contains(string(line), `"k":"user:alice"`) {
+		t.Errorf("metadata should stay readable: %s", line)
+	}
+	if record.Data["name"] != "Alice" {
+		t.Error("Encode should not modify the record")
+	}
+
+	// A decoder without the key keeps the sealed data
+	plain, err := NewDecoder().Decode(line)
+	if err != nil {
+		t.Fatalf("Decode without key failed: %v", err)
+	}
+	if _, ok := plain.Data[SealedField]; !ok {
+		t.Errorf("expected sealed data, got %v", plain.Data)
+	}
+
+	decoder := NewDecoder()
+	decoder.SetKey(key)
+	decoded, err := decoder.Decode(line)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if decoded.Data["name"] != "Alice" || decoded.Meta.Key != "user:alice" {
+		t.Errorf("decoded record mismatch: %+v", decoded)
+	}
+	// Sealed data moved to another key or version doesn't open
+	for _, moved := range []string{
+		strings.Replace(string(line), `"k":"user:alice"`, `"k":"user:bob"`, 1),
+		strings.Replace(string(line), `"v":1`, `"v":2`, 1),
+	} {
+		if _, err := decoder.Decode([]byte(moved)); !errors.Is(err, seal.ErrDecrypt) {
+			t.Errorf("expected ErrDecrypt for moved data, got %v", err)
+		}
+	}
+
+	// Data sealed before binding still opens
+	sealed, err := key.Seal([]byte(`{"name":"Alice"}`), nil)
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	legacy := `{"_meta":{"k":"user:alice","v":1,"op":"put","ts":"2025-12-14T18:09:00Z"},"data":{"$sealed":"` + base64.StdEncoding.EncodeToString(sealed) + `"}}`
+	if decoded, err := decoder.Decode([]byte(legacy)); err != nil || decoded.Data["name"] != "Alice" {
+		t.Errorf("legacy record: got %v, %v", decoded, err)
+	}
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// FuzzDecodeRecord checks that no input line makes the decoder panic, that
+// decoded records survive re-encoding, and that a bad line never hides the
+// valid records after it.
+//
+// Run with: go test ./internal/core -fuzz FuzzDecodeRecord
+func FuzzDecodeRecord(f *testing.F) {
+	f.Add([]byte(`{"_meta":{"k":"user:1","v":1,"op":"put","ts":"2025-12-14T18:09:00Z"},"data":{"name":"Alice"}}`))
+	f.Add([]byte(`{"_meta":{"k":"user:1","v":2,"op":"delete","ts":"2025-12-14T18:09:00Z","reason":"gdpr"},"data":null}`))
+	f.Add([]byte(`{"_meta":{"k":"a","v":3,"op":"put","ts":"2025-12-14T18:09:00+02:00","visible_at":"2026-01-01T00:00:00Z","prev":"abc","sig":"xyz"},"data":{"$sealed":"AAAA"}}`))
+	f.Add([]byte(`{"_meta":{"k":"doc","v":1,"op":"put","ts":"2025-12-14T18:09:00Z"},"data":{"f":{"$blob":true,"loc":"_blobs/x","hash":"h","size":1}}}`))
+	f.Add([]byte(`{"_meta":{"k":"","v":-1,"op":"drop"}}`))
+	f.Add([]byte(`{"_meta":`))
+	f.Add([]byte("\x00\xff\n{}"))
+
+	valid := []byte(`{"_meta":{"k":"sentinel","v":7,"op":"put","ts":"2025-12-14T18:09:00Z"},"data":{"ok":true}}`)
+
+	f.Fuzz(func(t *testing.T, line []byte) {
+		decoder := NewDecoder()
+
+		record, err := decoder.Decode(line)
+		if err == nil {
+			if record == nil || !record.IsValid() {
+				t.Fatalf("Decode returned an invalid record without error: %+v", record)
+			}
+
+			encoded, err := NewEncoder().Encode(record)
+			if err != nil {
+				t.Fatalf("Encode of a decoded record failed: %v", err)
+			}
+			again, err := decoder.Decode(encoded)
+			if err != nil {
+				t.Fatalf("Re-encoded record doesn't decode: %v\n%s", err, encoded)
+			}
+			if again.Meta.Key != record.Meta.Key || again.Meta.Version != record.Meta.Version ||
+				again.Meta.Operation != record.Meta.Operation || !again.Meta.Timestamp.Equal(record.Meta.Timestamp) {
+				t.Fatalf("Meta changed across re-encoding: %+v != %+v", again.Meta, record.Meta)
+			}
+		}
+
+		if bytes.Contains(line, []byte("sentinel")) {
+			return
+		}
+
+		path := filepath.Join(t.TempDir(), "key.jsonl")
+		content := append(append(append([]byte{}, line...), '\n'), valid...)
+		if err := os.WriteFile(path, append(content, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		records, err := decoder.ReadAll(path)
+		if err != nil {
+			t.Fatalf("ReadAll failed: %v", err)
+		}
+		if len(records) == 0 || records[len(records)-1].Meta.Key != "sentinel" {
+			t.Fatalf("Valid record after the fuzzed line was lost")
+		}
+	})
+}
@@ -17,6 +17,31 @@ type Meta struct {
 
 	// Timestamp is when this record was created
 	Timestamp time.Time `json:"ts"`
+
+	// Seq is the namespace's write sequence number: it grows with every
+	// record written, even when the wall clock steps back. 0 for records
+	// written before sequence numbers existed.
+	Seq uint64 `json:"seq,omitempty"`
+
+	// Reason optionally explains a delete (e.g. "user requested erasure")
+	Reason string `json:"reason,omitempty"`
+
+	// VisibleAt schedules a put: readers ignore the record until this time.
+	// Zero means visible immediately.
+	VisibleAt time.Time `json:"visible_at,omitzero"`
+
+	// RenamedFrom is set on the first record written by Rename under the
+	// new key, to the key it was renamed from
+	RenamedFrom string `json:"renamed_from,omitempty"`
+
+	// RenamedTo is set on the delete closing a renamed key, to its new key
+	RenamedTo string `json:"renamed_to,omitempty"`
+
+	// Prev is the digest of the key's previous record in hash-chained namespaces
+	Prev string `json:"prev,omitempty"`
+
+	// Sig is the base64 signature of the record in signed namespaces
+	Sig string `json:"sig,omitempty"`
 }
 
 // Operation types
@@ -35,11 +60,25 @@ func NewMeta(key string, version int, operation string) *Meta {
 	}
 }
 
+// Canonicalize puts the timestamps in UTC, so re-encoding a record written
+// with another offset (e.g. edited by hand) always yields the same line.
+func (m *Meta) Canonicalize() {
+	m.Timestamp = m.Timestamp.UTC()
+	if !m.VisibleAt.IsZero() {
+		m.VisibleAt = m.VisibleAt.UTC()
+	}
+}
+
 // IsPut returns true if this is a put operation.
 func (m *Meta) IsPut() bool {
 	return m.Operation == OpPut
 }
 
+// IsVisible reports whether the record is visible to readers at now.
+func (m *Meta) IsVisible(now time.Time) bool {
+	return m.VisibleAt.IsZero() || !now.Before(m.VisibleAt)
+}
+
 // IsDelete returns true if this is a delete operation.
 func (m *Meta) IsDelete() bool {
 	return m.Operation == OpDelete
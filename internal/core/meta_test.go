@@ -136,6 +136,31 @@ func TestMetaTimestamp(t *testing.T) {
 	})
 }
 
+// TestMetaCanonicalize tests that timestamps are normalized to UTC
+func TestMetaCanonicalize(t *testing.T) {
+	zone := time.FixedZone("CEST", 2*60*60)
+	ts := time.Date(2025, 6, 1, 14, 0, 0, 0, zone)
+
+	meta := &Meta{Key: "key", Version: 1, Operation: OpPut, Timestamp: ts}
+	meta.Canonicalize()
+
+	if meta.Timestamp.Location() != time.UTC {
+		t.Errorf("Timestamp should be in UTC, got %v", meta.Timestamp.Location())
+	}
+	if !meta.Timestamp.Equal(ts) {
+		t.Errorf("Timestamp changed: got %v, want %v", meta.Timestamp, ts)
+	}
+	if !meta.VisibleAt.IsZero() {
+		t.Errorf("Zero VisibleAt should stay zero, got %v", meta.VisibleAt)
+	}
+
+	meta.VisibleAt = ts.Add(time.Hour)
+	meta.Canonicalize()
+	if meta.VisibleAt.Location() != time.UTC {
+		t.Errorf("VisibleAt should be in UTC, got %v", meta.VisibleAt.Location())
+	}
+}
+
 // TestMetaOperationChecksMethods tests IsPut and IsDelete methods comprehensively
 func TestMetaOperationChecksMethods(t *testing.T) {
 	tests := []struct {
@@ -17,6 +17,11 @@ type Record struct {
 	// For "put" operations, this is the data
 	// For "delete" operations, this is nil
 	Data map[string]interface{} `json:"data"`
+
+	// sign marks records the encoder signs: new records, and records stow
+	// changed that were validly signed before. Records read from disk keep
+	// the signature they carry, or lack.
+	sign bool
 }
 
 // NewRecord creates a new Record with the given metadata and data.
@@ -32,6 +37,7 @@ func NewPutRecord(key string, version int, data map[string]interface{}) *Record
 	return &Record{
 		Meta: NewMeta(key, version, OpPut),
 		Data: data,
+		sign: true,
 	}
 }
 
@@ -40,9 +46,17 @@ func NewDeleteRecord(key string, version int) *Record {
 	return &Record{
 		Meta: NewMeta(key, version, OpDelete),
 		Data: nil,
+		sign: true,
 	}
 }
 
+// Resign drops the record's signature and has the encoder sign it anew,
+// after its metadata or data changed.
+func (r *Record) Resign() {
+	r.Meta.Sig = ""
+	r.sign = true
+}
+
 // IsValid checks if the record is valid.
 func (r *Record) IsValid() bool {
 	if r.Meta == nil {
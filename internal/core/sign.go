@@ -0,0 +1,187 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aigotowork/stow/internal/sign"
+)
+
+// ErrInvalidSignature is returned when a record doesn't match its signature.
+var ErrInvalidSignature = errors.New("invalid record signature")
+
+// SetSigner makes the encoder sign new records and records marked with
+// Resign. Records rewritten as read (e.g. by compaction) are written as they
+// are, so a record without a valid signature never gains one. A key that
+// can't sign makes such writes fail with sign.ErrNoKey; nil disables signing.
+func (e *Encoder) SetSigner(key *sign.Key) {
+	e.signer.Store(key)
+}
+
+// signRecord sets the signature of a record, computed over its plain data.
+func signRecord(record *Record, key *sign.Key) error {
+	data, err := json.Marshal(record.Data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal data: %w", err)
+	}
+
+	payload, err := recordPayload(record.Meta, data)
+	if err != nil {
+		return err
+	}
+
+	sig, err := key.Sign(payload)
+	if err != nil {
+		return err
+	}
+
+	record.Meta.Sig = base64.StdEncoding.EncodeToString(sig)
+	return nil
+}
+
+// Signed reports whether record carries a valid signature of the encoder's
+// signer. Stow resigns records it changes only if they are, so changing a
+// tampered record doesn't make it pass verification.
+func (e *Encoder) Signed(record *Record) bool {
+	key := e.signer.Load()
+	if key == nil || record.Meta.Sig == "" {
+		return false
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(record.Meta.Sig)
+	if err != nil {
+		return false
+	}
+	data, err := json.Marshal(record.Data)
+	if err != nil {
+		return false
+	}
+	payload, err := recordPayload(record.Meta, data)
+	if err != nil {
+		return false
+	}
+
+	ok, err := key.Verify(payload, sig)
+	return err == nil && ok
+}
+
+// VerifyLine checks the signature of an encoded record, decrypting its data
+// first if the decoder has a key. Returns the record's metadata, whether it
+// is signed at all, and ErrInvalidSignature if it doesn't match.
+func (d *Decoder) VerifyLine(line []byte, key *sign.Key) (meta *Meta, signed bool, err error) {
+	var record struct {
+		Meta *Meta           `json:"_meta"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(line), &record); err != nil || record.Meta == nil {
+		return nil, false, fmt.Errorf("invalid record structure")
+	}
+	meta = record.Meta
+
+	if meta.Sig == "" {
+		return meta, false, nil
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(meta.Sig)
+	if err != nil {
+		return meta, true, ErrInvalidSignature
+	}
+
+	data := []byte(record.Data)
+	if len(data) == 0 {
+		data = []byte("null")
+	}
+	if d.key != nil {
+		if data, err = d.openRaw(meta, data); err != nil {
+			return meta, true, err
+		}
+	}
+
+	payload, err := recordPayload(meta, data)
+	if err != nil {
+		// Data that no longer parses was tampered with
+		return meta, true, ErrInvalidSignature
+	}
+
+	ok, err := key.Verify(payload, sig)
+	if err != nil {
+		return meta, true, err
+	}
+	if !ok {
+		return meta, true, ErrInvalidSignature
+	}
+	return meta, true, nil
+}
+
+// openRaw decrypts raw sealed data of a record with meta, leaving plain
+// data untouched.
+func (d *Decoder) openRaw(meta *Meta, data []byte) ([]byte, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil || len(fields) != 1 {
+		return data, nil
+	}
+	encoded, ok := fields[SealedField].(string)
+	if !ok {
+		return data, nil
+	}
+	return d.open(meta, encoded)
+}
+
+// Digest returns the hex SHA-256 of the record's canonical form, which
+// hash-chained records link to. Like signatures, it covers the plain data,
+// so it doesn't change when a record is re-encoded or re-encrypted.
+func (r *Record) Digest() (string, error) {
+	data, err := json.Marshal(r.Data)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal data: %w", err)
+	}
+
+	payload, err := recordPayload(r.Meta, data)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// recordPayload returns the bytes record signatures and digests cover: its
+// metadata without the signature and with UTC timestamps, then its plain
+// data, both as canonical JSON. Numbers keep their literal form, so the
+// payload is the same whether data is signed before encoding or verified
+// from disk.
+func recordPayload(meta *Meta, data []byte) ([]byte, error) {
+	m := *meta
+	m.Sig = ""
+	m.Canonicalize()
+
+	metaJSON, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	dataJSON, err := canonicalJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := append(metaJSON, '\n')
+	return append(payload, dataJSON...), nil
+}
+
+// canonicalJSON re-encodes JSON with sorted object keys and no whitespace.
+func canonicalJSON(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/aigotowork/stow/internal/sign"
+)
+
+func TestSignedRecordVerifies(t *testing.T) {
+	key, err := sign.NewKey(sign.HMACSHA256, []byte("secret"), nil)
+	if err != nil {
+		t.Fatalf("NewKey failed: %v", err)
+	}
+
+	encoder := NewEncoder()
+	encoder.SetSigner(key)
+	decoder := NewDecoder()
+
+	type point struct {
+		Y int `json:"y"`
+		X int `json:"x"`
+	}
+	record := NewPutRecord("key", 1, map[string]interface{}{
+		"point": point{Y: 2, X: 1},
+		"big":   int64(1234567890123456789),
+	})
+
+	line, err := encoder.Encode(record)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if record.Meta.Sig == "" {
+		t.Fatal("record was not signed")
+	}
+
+	if _, signed, err := decoder.VerifyLine(line, key); err != nil || !signed {
+		t.Errorf("VerifyLine = %v, %v; want signed and valid", signed, err)
+	}
+
+	// Re-encoding a decoded record keeps its signature (as long as decoding
+	// keeps numbers exact)
+	record = NewPutRecord("key", 2, map[string]interface{}{"point": point{Y: 2, X: 1}})
+	line, err = encoder.Encode(record)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	decoded, err := decoder.Decode(line)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	decoded.Meta.Canonicalize()
+	again, err := encoder.Encode(decoded)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if _, _, err := decoder.VerifyLine(again, key); err != nil {
+		t.Errorf("re-encoded record should verify: %v", err)
+	}
+
+	tampered := bytes.Replace(line, []byte(`"x":1`), []byte(`"x":5`), 1)
+	if _, _, err := decoder.VerifyLine(tampered, key); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("tampered record: got %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestSignWithoutKeyFails(t *testing.T) {
+	key, _ := sign.NewKey(sign.HMACSHA256, nil, nil)
+
+	encoder := NewEncoder()
+	encoder.SetSigner(key)
+
+	_, err := encoder.Encode(NewPutRecord("key", 1, map[string]interface{}{"a": 1}))
+	if !errors.Is(err, sign.ErrNoKey) {
+		t.Errorf("Encode = %v, want ErrNoKey", err)
+	}
+}
+
+func TestRecordDigestStable(t *testing.T) {
+	record := NewPutRecord("key", 1, map[string]interface{}{"b": 2, "a": "x"})
+	digest, err := record.Digest()
+	if err != nil {
+		t.Fatalf("Digest failed: %v", err)
+	}
+
+	line, err := NewEncoder().Encode(record)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	decoded, err := NewDecoder().Decode(line)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	// Decoded records digest the same, signature or not
+	decoded.Meta.Sig = "c2ln"
+	if again, _ := decoded.Digest(); again != digest {
+		t.Errorf("Digest changed after decoding: %s != %s", again, digest)
+	}
+
+	decoded.Data["b"] = 3
+	if changed, _ := decoded.Digest(); changed == digest {
+		t.Error("Digest should change with the data")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // AtomicWriteFile writes data to a file atomically.
@@ -52,26 +53,64 @@ func AtomicWriteFile(path string, data []byte, perm os.FileMode) error {
 		return fmt.Errorf("failed to close temp file: %w", err)
 	}
 
-	// Rename to target path (atomic operation)
-	if err := SafeRename(tmpPath, path); err != nil {
+	// Replace target path (atomic operation, parent directory synced)
+	if err := AtomicReplace(tmpPath, path); err != nil {
 		os.Remove(tmpPath) // Clean up temp file
 		return fmt.Errorf("failed to rename temp file: %w", err)
 	}
 
-	// Sync parent directory to ensure rename is persisted
-	if err := syncDir(dir); err != nil {
-		// Log warning but don't fail - the file is already renamed
-		// This is a best-effort operation
-		return nil
+	return nil
+}
+
+// Retry schedule for renames that fail transiently (Windows sharing violations).
+// The default waits up to about 10 seconds in total.
+var (
+	replaceRetries = 10
+	replaceBackoff = 10 * time.Millisecond
+)
+
+// AtomicReplace atomically replaces newPath with oldPath.
+//
+// The rename uses the platform's atomic replace (rename(2) on Unix, MoveFileEx
+// with MOVEFILE_REPLACE_EXISTING|MOVEFILE_WRITE_THROUGH on Windows). Renames that
+// fail because another process briefly holds the file open are retried with
+// backoff. On Unix the parent directory is synced afterwards so the rename
+// survives a crash; a failed directory sync is not reported since the
+// replace itself has already happened.
+func AtomicReplace(oldPath, newPath string) error {
+	err := retryTransient(func() error {
+		return replaceFile(oldPath, newPath)
+	}, isTransientRenameError)
+	if err != nil {
+		return err
 	}
 
+	// Best effort: the file is already renamed
+	_ = syncParentDir(filepath.Dir(newPath))
+
 	return nil
 }
 
+// retryTransient runs op, retrying with exponential backoff while it fails
+// with an error that transient reports as retryable.
+func retryTransient(op func() error, transient func(error) bool) error {
+	backoff := replaceBackoff
+
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if err == nil || attempt >= replaceRetries || !transient(err) {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
 // SafeRename renames a file safely.
-// On Unix systems, os.Rename is atomic if src and dst are on the same filesystem.
+//
+// Deprecated: use AtomicReplace.
 func SafeRename(oldPath, newPath string) error {
-	return os.Rename(oldPath, newPath)
+	return AtomicReplace(oldPath, newPath)
 }
 
 // syncDir syncs a directory to disk.
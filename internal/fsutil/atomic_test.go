@@ -1,11 +1,13 @@
 package fsutil
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 // ========== AtomicWriteFile Tests ==========
@@ -290,6 +292,91 @@ func TestSafeRenameNonExistent(t *testing.T) {
 	}
 }
 
+// ========== AtomicReplace Tests ==========
+
+func TestAtomicReplace(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	srcFile := filepath.Join(tmpDir, "source.txt")
+	dstFile := filepath.Join(tmpDir, "dest.txt")
+	os.WriteFile(srcFile, []byte("new content"), 0644)
+	os.WriteFile(dstFile, []byte("old content"), 0644)
+
+	if err := AtomicReplace(srcFile, dstFile); err != nil {
+		t.Fatalf("AtomicReplace failed: %v", err)
+	}
+
+	if FileExists(srcFile) {
+		t.Error("Source file should not exist after replace")
+	}
+
+	content, _ := os.ReadFile(dstFile)
+	if string(content) != "new content" {
+		t.Errorf("Content after replace: got %q, want %q", string(content), "new content")
+	}
+}
+
+func TestAtomicReplaceNonExistent(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	start := time.Now()
+	err := AtomicReplace(filepath.Join(tmpDir, "missing.txt"), filepath.Join(tmpDir, "dest.txt"))
+	if err == nil {
+		t.Fatal("AtomicReplace should fail with non-existent source")
+	}
+	if !os.IsNotExist(err) {
+		t.Errorf("expected not-exist error, got %v", err)
+	}
+
+	// Permanent errors are not retried
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("permanent error took %v, should fail fast", elapsed)
+	}
+}
+
+func TestRetryTransient(t *testing.T) {
+	oldBackoff := replaceBackoff
+	replaceBackoff = time.Microsecond
+	defer func() { replaceBackoff = oldBackoff }()
+
+	errBusy := errors.New("sharing violation")
+	isBusy := func(err error) bool { return err == errBusy }
+
+	// Succeeds once the transient condition clears
+	calls := 0
+	err := retryTransient(func() error {
+		calls++
+		if calls < 3 {
+			return errBusy
+		}
+		return nil
+	}, isBusy)
+	if err != nil || calls != 3 {
+		t.Errorf("retryTransient = %v after %d calls, want success after 3", err, calls)
+	}
+
+	// Gives up after replaceRetries retries
+	calls = 0
+	err = retryTransient(func() error {
+		calls++
+		return errBusy
+	}, isBusy)
+	if err != errBusy || calls != replaceRetries+1 {
+		t.Errorf("retryTransient = %v after %d calls, want errBusy after %d", err, calls, replaceRetries+1)
+	}
+
+	// Permanent errors return immediately
+	calls = 0
+	errPermanent := errors.New("permanent")
+	err = retryTransient(func() error {
+		calls++
+		return errPermanent
+	}, isBusy)
+	if err != errPermanent || calls != 1 {
+		t.Errorf("retryTransient = %v after %d calls, want errPermanent after 1", err, calls)
+	}
+}
+
 // ========== syncDir Tests ==========
 
 func TestSyncDir(t *testing.T) {
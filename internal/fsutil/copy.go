@@ -0,0 +1,102 @@
+package fsutil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// CopyTree copies the directory tree at src to dst, which must not exist,
+// for moves across filesystems. Regular files keep their permissions, are
+// synced and read back to check they match; symlinks are recreated as they
+// are. Other file types are an error. On error, dst may be left partially
+// copied.
+func CopyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case entry.IsDir():
+			return os.Mkdir(target, info.Mode().Perm())
+		case entry.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case entry.Type().IsRegular():
+			return copyFile(path, target, info.Mode().Perm())
+		default:
+			return fmt.Errorf("cannot copy %s: unsupported file type %s", path, entry.Type())
+		}
+	})
+}
+
+// copyFile copies the regular file src to the new file dst, syncs it and
+// checks that it reads back the same.
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(out, io.TeeReader(in, h)); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to sync %s: %w", dst, err)
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	copied, err := hashFile(dst, sha256.New())
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(copied, h.Sum(nil)) {
+		return fmt.Errorf("copy of %s doesn't match the original", src)
+	}
+	return nil
+}
+
+// hashFile returns the hash of the contents of path.
+func hashFile(path string, h hash.Hash) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,43 @@
+package fsutil
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCopyTree(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	os.MkdirAll(filepath.Join(src, "sub", "empty"), 0755)
+	os.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(src, "sub", "b.bin"), []byte{0, 1, 2}, 0600)
+	if runtime.GOOS != "windows" {
+		os.Symlink("a.txt", filepath.Join(src, "link"))
+	}
+
+	dst := filepath.Join(t.TempDir(), "dst")
+	if err := CopyTree(src, dst); err != nil {
+		t.Fatalf("CopyTree failed: %v", err)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(dst, "sub", "b.bin")); string(data) != "\x00\x01\x02" {
+		t.Errorf("sub/b.bin = %q", data)
+	}
+	if info, err := os.Stat(filepath.Join(dst, "sub", "b.bin")); err != nil || (runtime.GOOS != "windows" && info.Mode().Perm() != 0600) {
+		t.Errorf("sub/b.bin: %v %v", info, err)
+	}
+	if !DirExists(filepath.Join(dst, "sub", "empty")) {
+		t.Error("empty directory not copied")
+	}
+	if runtime.GOOS != "windows" {
+		if link, err := os.Readlink(filepath.Join(dst, "link")); err != nil || link != "a.txt" {
+			t.Errorf("link = %q, %v", link, err)
+		}
+	}
+
+	// The destination must not exist
+	if err := CopyTree(src, dst); err == nil {
+		t.Error("CopyTree over an existing directory succeeded")
+	}
+}
@@ -0,0 +1,69 @@
+package fsutil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrLocked is returned when a lock file is held by another process
+// (or another handle in this process).
+var ErrLocked = errors.New("file is locked")
+
+// FileLock is an exclusive advisory lock on a file.
+type FileLock struct {
+	file *os.File
+
+	// record is set for record locks taken by LockFileNetwork
+	record bool
+}
+
+// LockFile creates path if needed and takes an exclusive lock on it without
+// blocking. Returns ErrLocked if the lock is held elsewhere. The lock is
+// released by Unlock or when the process exits.
+func LockFile(path string) (*FileLock, error) {
+	return openLocked(path, lockFile, false)
+}
+
+// LockFileNetwork is LockFile for files on network filesystems. flock(2)
+// may only be enforced on the local client, so a record lock is taken
+// instead, which NFS and SMB servers arbitrate between clients.
+func LockFileNetwork(path string) (*FileLock, error) {
+	return openLocked(path, func(f *os.File) error {
+		return lockRange(f, false)
+	}, true)
+}
+
+// openLocked creates and opens path and locks it with lock.
+func openLocked(path string, lock func(*os.File) error, record bool) (*FileLock, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := lock(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &FileLock{file: f, record: record}, nil
+}
+
+// Unlock releases the lock. The lock file itself is left in place.
+func (l *FileLock) Unlock() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+
+	unlock := unlockFile
+	if l.record {
+		unlock = unlockRange
+	}
+
+	err := unlock(l.file)
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	l.file = nil
+	return err
+}
@@ -0,0 +1,9 @@
+package fsutil
+
+// Open file description locks: like POSIX record locks, which NFS forwards
+// to the server, but owned by the open file rather than the process, so
+// closing another descriptor of the same file doesn't release them.
+const (
+	fcntlSetLock     = 37 // F_OFD_SETLK
+	fcntlSetLockWait = 38 // F_OFD_SETLKW
+)
@@ -0,0 +1,11 @@
+//go:build !linux && !windows
+
+package fsutil
+
+import "syscall"
+
+// POSIX record locks, which NFS forwards to the server.
+const (
+	fcntlSetLock     = syscall.F_SETLK
+	fcntlSetLockWait = syscall.F_SETLKW
+)
@@ -0,0 +1,33 @@
+package fsutil
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "writer.lock")
+
+	lock, err := LockFile(path)
+	if err != nil {
+		t.Fatalf("LockFile failed: %v", err)
+	}
+
+	if _, err := LockFile(path); !errors.Is(err, ErrLocked) {
+		t.Errorf("second LockFile: expected ErrLocked, got %v", err)
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	if err := lock.Unlock(); err != nil {
+		t.Errorf("second Unlock should be a no-op, got %v", err)
+	}
+
+	relock, err := LockFile(path)
+	if err != nil {
+		t.Fatalf("LockFile after Unlock failed: %v", err)
+	}
+	relock.Unlock()
+}
@@ -0,0 +1,51 @@
+//go:build !windows
+
+package fsutil
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+)
+
+// lockFile takes a non-blocking exclusive flock(2) on f.
+func lockFile(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return ErrLocked
+	}
+	return err
+}
+
+// unlockFile releases the flock on f.
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
+
+// lockRange takes an exclusive fcntl(2) record lock on all of f, waiting for
+// it if wait is set.
+func lockRange(f *os.File, wait bool) error {
+	cmd := fcntlSetLock
+	if wait {
+		cmd = fcntlSetLockWait
+	}
+
+	lock := syscall.Flock_t{Type: syscall.F_WRLCK, Whence: io.SeekStart}
+	for {
+		err := syscall.FcntlFlock(f.Fd(), cmd, &lock)
+		if errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EACCES) {
+			return ErrLocked
+		}
+		return err
+	}
+}
+
+// unlockRange releases the lock taken by lockRange.
+func unlockRange(f *os.File) error {
+	lock := syscall.Flock_t{Type: syscall.F_UNLCK, Whence: io.SeekStart}
+	return syscall.FcntlFlock(f.Fd(), fcntlSetLock, &lock)
+}
@@ -0,0 +1,63 @@
+//go:build windows
+
+package fsutil
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+)
+
+var (
+	procLockFileEx   = syscall.NewLazyDLL("kernel32.dll").NewProc("LockFileEx")
+	procUnlockFileEx = syscall.NewLazyDLL("kernel32.dll").NewProc("UnlockFileEx")
+)
+
+// lockFile takes a non-blocking exclusive LockFileEx lock on the first byte of f.
+func lockFile(f *os.File) error {
+	return lockRange(f, false)
+}
+
+// lockRange takes an exclusive LockFileEx lock on the first byte of f,
+// waiting for it if wait is set. SMB servers arbitrate these locks.
+func lockRange(f *os.File, wait bool) error {
+	flags := uintptr(lockfileExclusiveLock)
+	if !wait {
+		flags |= lockfileFailImmediately
+	}
+
+	var overlapped syscall.Overlapped
+	r, _, e := procLockFileEx.Call(
+		f.Fd(),
+		flags,
+		0, 1, 0,
+		uintptr(unsafe.Pointer(&overlapped)),
+	)
+	if r == 0 {
+		if e == errorLockViolation {
+			return ErrLocked
+		}
+		return e
+	}
+	return nil
+}
+
+// unlockRange releases the lock taken by lockRange.
+func unlockRange(f *os.File) error {
+	return unlockFile(f)
+}
+
+// unlockFile releases the lock taken by lockFile.
+func unlockFile(f *os.File) error {
+	var overlapped syscall.Overlapped
+	r, _, e := procUnlockFileEx.Call(f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+	if r == 0 {
+		return e
+	}
+	return nil
+}
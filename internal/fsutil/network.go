@@ -0,0 +1,103 @@
+package fsutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// AppendLocked appends data to path, creating it with perm if needed, for
+// files on network filesystems. NFS doesn't make O_APPEND atomic across
+// clients, so the file is locked with a record lock (which NFS and SMB
+// servers arbitrate) and data is written at the end of the file as seen
+// once the lock is held. Blocks until the lock is granted.
+func AppendLocked(path string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, perm)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	if err := lockRange(f, true); err != nil {
+		return fmt.Errorf("failed to lock file: %w", err)
+	}
+	defer unlockRange(f)
+
+	// Taking the lock revalidates the client's cached attributes
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	if _, err := f.WriteAt(data, info.Size()); err != nil {
+		return fmt.Errorf("failed to write to file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+
+	return nil
+}
+
+// NetworkWriteFile is AtomicWriteFile for network filesystems:
+//   - the temporary file has a unique name, so writers on different clients
+//     never share one
+//   - the parent directory isn't synced (NFS and SMB commit renames on the
+//     server, and some clients fail directory fsync)
+//   - the size of the replaced file is checked afterwards, so a rename lost
+//     to a stale client cache is reported instead of assumed
+func NetworkWriteFile(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := EnsureDir(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create parent directory: %w", err)
+	}
+
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := f.Name()
+
+	if err := writeAndClose(f, data, perm); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	err = retryTransient(func() error {
+		return replaceFile(tmpPath, path)
+	}, isTransientRenameError)
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to rename temp file: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat replaced file: %w", err)
+	}
+	if info.Size() != int64(len(data)) {
+		return fmt.Errorf("replaced file has %d bytes, wrote %d", info.Size(), len(data))
+	}
+
+	return nil
+}
+
+// writeAndClose writes data to f, syncs and closes it.
+func writeAndClose(f *os.File, data []byte, perm os.FileMode) error {
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to set temp file mode: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write to temp file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync temp file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package fsutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestAppendLocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key.jsonl")
+
+	const writers, lines = 8, 50
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < lines; i++ {
+				line := fmt.Sprintf("writer %d line %d %s\n", w, i, bytes.Repeat([]byte("x"), 100))
+				if err := AppendLocked(path, []byte(line), 0644); err != nil {
+					t.Errorf("AppendLocked failed: %v", err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := bytes.Split(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+	if len(got) != writers*lines {
+		t.Fatalf("Expected %d lines, got %d", writers*lines, len(got))
+	}
+	for _, line := range got {
+		if !bytes.HasSuffix(line, bytes.Repeat([]byte("x"), 100)) {
+			t.Fatalf("Interleaved line: %q", line)
+		}
+	}
+}
+
+func TestNetworkWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "_config.json")
+
+	for _, content := range []string{"first", "second, longer"} {
+		if err := NetworkWriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("NetworkWriteFile failed: %v", err)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil || string(got) != content {
+			t.Errorf("Expected %q, got %q (%v)", content, got, err)
+		}
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected no temp files left, got %d entries", len(entries))
+	}
+	if runtime.GOOS != "windows" {
+		if info, _ := os.Stat(path); info.Mode().Perm() != 0644 {
+			t.Errorf("Expected mode 0644, got %v", info.Mode().Perm())
+		}
+	}
+}
+
+func TestLockFileNetwork(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "writer.lock")
+
+	lock, err := LockFileNetwork(path)
+	if err != nil {
+		t.Fatalf("LockFileNetwork failed: %v", err)
+	}
+
+	// POSIX record locks are per process outside Linux and Windows
+	if runtime.GOOS == "linux" || runtime.GOOS == "windows" {
+		if _, err := LockFileNetwork(path); !errors.Is(err, ErrLocked) {
+			t.Errorf("second LockFileNetwork: expected ErrLocked, got %v", err)
+		}
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+
+	relock, err := LockFileNetwork(path)
+	if err != nil {
+		t.Fatalf("LockFileNetwork after Unlock failed: %v", err)
+	}
+	relock.Unlock()
+}
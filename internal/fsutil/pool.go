@@ -0,0 +1,215 @@
+package fsutil
+
+import (
+	"container/list"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// reuseHandles is false on Windows, where an open handle blocks renaming or
+// deleting the file, so idle handles would break atomic writes.
+var reuseHandles = runtime.GOOS != "windows"
+
+// FilePool counts the read-only file handles opened through it, caps them,
+// and keeps a few idle ones per path for reuse.
+//
+// Handles are checked out exclusively: Open returns a handle positioned at
+// the start of the file and Release hands it back. An idle handle is only
+// reused while it still refers to the file at its path, so files replaced by
+// atomic writes are reopened. A nil *FilePool opens and closes plain files.
+type FilePool struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	maxOpen int // 0 means no limit
+	maxIdle int
+
+	open  int        // handles checked out plus idle ones
+	idle  *list.List // *idleFile, most recently released first
+	paths map[string][]*list.Element
+}
+
+type idleFile struct {
+	path string
+	file *os.File
+}
+
+// NewFilePool creates a pool holding at most maxOpen handles (0 means no
+// limit), of which at most maxIdle are kept idle.
+func NewFilePool(maxOpen, maxIdle int) *FilePool {
+	if maxOpen > 0 && maxIdle > maxOpen {
+		maxIdle = maxOpen
+	}
+	p := &FilePool{
+		maxOpen: maxOpen,
+		maxIdle: maxIdle,
+		idle:    list.New(),
+		paths:   make(map[string][]*list.Element),
+	}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// Open returns a handle for path, reusing an idle one when possible. At the
+// limit it closes the least recently used idle handle, or waits for a
+// handle to be released.
+func (p *FilePool) Open(path string) (*os.File, error) {
+	if p == nil {
+		return os.Open(path)
+	}
+
+	if f := p.reuse(path); f != nil {
+		return f, nil
+	}
+
+	p.mu.Lock()
+	for p.maxOpen > 0 && p.open >= p.maxOpen {
+		if elem := p.idle.Back(); elem != nil {
+			p.closeIdle(elem)
+			continue
+		}
+		p.cond.Wait()
+	}
+	p.open++
+	p.mu.Unlock()
+
+	f, err := os.Open(path)
+	if err != nil {
+		p.mu.Lock()
+		p.open--
+		p.cond.Signal()
+		p.mu.Unlock()
+		return nil, err
+	}
+	return f, nil
+}
+
+// reuse checks out an idle handle of path that still refers to the file
+// there, closing stale ones.
+func (p *FilePool) reuse(path string) *os.File {
+	p.mu.Lock()
+	elems := p.paths[path]
+	if len(elems) == 0 {
+		p.mu.Unlock()
+		return nil
+	}
+	elem := elems[len(elems)-1]
+	f := p.take(elem)
+	p.mu.Unlock()
+
+	current, err := os.Stat(path)
+	if err == nil {
+		var held os.FileInfo
+		held, err = f.Stat()
+		if err == nil && os.SameFile(current, held) {
+			if _, err = f.Seek(0, io.SeekStart); err == nil {
+				return f
+			}
+		}
+	}
+
+	p.discard(f)
+	return nil
+}
+
+// Release hands a handle from Open back to the pool, which keeps it idle
+// or closes it.
+func (p *FilePool) Release(path string, f *os.File) {
+	if p == nil {
+		f.Close()
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !reuseHandles || p.maxIdle <= 0 {
+		f.Close()
+		p.open--
+		p.cond.Signal()
+		return
+	}
+
+	elem := p.idle.PushFront(&idleFile{path: path, file: f})
+	p.paths[path] = append(p.paths[path], elem)
+	for p.idle.Len() > p.maxIdle {
+		p.closeIdle(p.idle.Back())
+	}
+
+	// A waiting Open can close the idle handle to make room
+	p.cond.Signal()
+}
+
+// discard closes a checked out handle that can't be reused.
+func (p *FilePool) discard(f *os.File) {
+	f.Close()
+
+	p.mu.Lock()
+	p.open--
+	p.cond.Signal()
+	p.mu.Unlock()
+}
+
+// take removes an idle handle from the pool without closing it (caller
+// holds mu). The handle stays counted as open.
+func (p *FilePool) take(elem *list.Element) *os.File {
+	entry := p.idle.Remove(elem).(*idleFile)
+
+	elems := p.paths[entry.path]
+	for i, e := range elems {
+		if e == elem {
+			elems = append(elems[:i], elems[i+1:]...)
+			break
+		}
+	}
+	if len(elems) == 0 {
+		delete(p.paths, entry.path)
+	} else {
+		p.paths[entry.path] = elems
+	}
+
+	return entry.file
+}
+
+// closeIdle closes an idle handle (caller holds mu).
+func (p *FilePool) closeIdle(elem *list.Element) {
+	p.take(elem).Close()
+	p.open--
+	p.cond.Signal()
+}
+
+// CloseIdle closes the idle handles of files under dir, or all of them when
+// dir is empty.
+func (p *FilePool) CloseIdle(dir string) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	prefix := filepath.Clean(dir) + string(filepath.Separator)
+	for elem := p.idle.Front(); elem != nil; {
+		next := elem.Next()
+		if dir == "" || strings.HasPrefix(elem.Value.(*idleFile).path, prefix) {
+			p.closeIdle(elem)
+		}
+		elem = next
+	}
+}
+
+// Stats returns the number of open handles, checked out or idle, and how
+// many of them are idle.
+func (p *FilePool) Stats() (open, idle int) {
+	if p == nil {
+		return 0, 0
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.open, p.idle.Len()
+}
@@ -0,0 +1,115 @@
+package fsutil
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFilePoolReuse(t *testing.T) {
+	if !reuseHandles {
+		t.Skip("handles are not reused on this platform")
+	}
+
+	path := filepath.Join(t.TempDir(), "a.jsonl")
+	os.WriteFile(path, []byte("one\n"), 0644)
+
+	p := NewFilePool(0, 4)
+	f, err := p.Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	io.ReadAll(f)
+	p.Release(path, f)
+
+	again, err := p.Open(path)
+	if err != nil {
+		t.Fatalf("second Open failed: %v", err)
+	}
+	if again != f {
+		t.Error("expected the idle handle to be reused")
+	}
+	data, _ := io.ReadAll(again)
+	if string(data) != "one\n" {
+		t.Errorf("reused handle read %q, want it rewound", data)
+	}
+	p.Release(path, again)
+
+	// A replaced file is reopened
+	tmp := path + ".tmp"
+	os.WriteFile(tmp, []byte("two\n"), 0644)
+	os.Rename(tmp, path)
+
+	fresh, err := p.Open(path)
+	if err != nil {
+		t.Fatalf("Open after replace failed: %v", err)
+	}
+	data, _ = io.ReadAll(fresh)
+	if string(data) != "two\n" {
+		t.Errorf("read %q after replace, want %q", data, "two\n")
+	}
+	p.Release(path, fresh)
+
+	if open, idle := p.Stats(); open != 1 || idle != 1 {
+		t.Errorf("Stats = %d open, %d idle; want 1, 1", open, idle)
+	}
+
+	p.CloseIdle(filepath.Dir(path))
+	if open, idle := p.Stats(); open != 0 || idle != 0 {
+		t.Errorf("Stats after CloseIdle = %d open, %d idle; want 0, 0", open, idle)
+	}
+}
+
+func TestFilePoolLimit(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a")
+	b := filepath.Join(dir, "b")
+	os.WriteFile(a, nil, 0644)
+	os.WriteFile(b, nil, 0644)
+
+	p := NewFilePool(1, 1)
+	fa, err := p.Open(a)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	opened := make(chan *os.File)
+	go func() {
+		fb, _ := p.Open(b)
+		opened <- fb
+	}()
+
+	select {
+	case <-opened:
+		t.Fatal("Open over the limit should wait for a release")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	p.Release(a, fa)
+	fb := <-opened
+	if fb == nil {
+		t.Fatal("Open after release failed")
+	}
+	if open, _ := p.Stats(); open != 1 {
+		t.Errorf("open = %d, want 1 (idle handle closed at the limit)", open)
+	}
+	p.Release(b, fb)
+}
+
+func TestFilePoolNil(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a")
+	os.WriteFile(path, nil, 0644)
+
+	var p *FilePool
+	f, err := p.Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	p.Release(path, f)
+
+	if _, err := p.Open(filepath.Join(filepath.Dir(path), "missing")); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+
+package fsutil
+
+import "os"
+
+// replaceFile renames src over dst. rename(2) is atomic on the same filesystem.
+func replaceFile(src, dst string) error {
+	return os.Rename(src, dst)
+}
+
+// isTransientRenameError reports whether a failed rename is worth retrying.
+// POSIX renames don't fail transiently.
+func isTransientRenameError(err error) bool {
+	return false
+}
+
+// syncParentDir fsyncs the directory so the rename itself is durable.
+func syncParentDir(dir string) error {
+	return syncDir(dir)
+}
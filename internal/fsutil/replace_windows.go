@@ -0,0 +1,61 @@
+//go:build windows
+
+package fsutil
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	movefileReplaceExisting = 0x1
+	movefileWriteThrough    = 0x8
+
+	errorAccessDenied     syscall.Errno = 5
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+)
+
+var procMoveFileExW = syscall.NewLazyDLL("kernel32.dll").NewProc("MoveFileExW")
+
+// replaceFile renames src over dst with MoveFileEx. MOVEFILE_WRITE_THROUGH makes
+// the call return only once the move has been flushed to disk.
+func replaceFile(src, dst string) error {
+	from, err := syscall.UTF16PtrFromString(src)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: src, New: dst, Err: err}
+	}
+	to, err := syscall.UTF16PtrFromString(dst)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: src, New: dst, Err: err}
+	}
+
+	r, _, e := procMoveFileExW.Call(
+		uintptr(unsafe.Pointer(from)),
+		uintptr(unsafe.Pointer(to)),
+		movefileReplaceExisting|movefileWriteThrough,
+	)
+	if r == 0 {
+		return &os.LinkError{Op: "rename", Old: src, New: dst, Err: e}
+	}
+	return nil
+}
+
+// isTransientRenameError reports whether a failed rename is worth retrying.
+// Virus scanners, indexers and concurrent readers briefly hold files open
+// without FILE_SHARE_DELETE, which surfaces as access denied or sharing violations.
+func isTransientRenameError(err error) bool {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return false
+	}
+	return errno == errorAccessDenied || errno == errorSharingViolation || errno == errorLockViolation
+}
+
+// syncParentDir is a no-op: Windows can't fsync directories, and
+// MOVEFILE_WRITE_THROUGH already makes the rename durable.
+func syncParentDir(dir string) error {
+	return nil
+}
@@ -1,11 +1,17 @@
 package fsutil
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
+// ErrUnsafePath is returned when a path would escape its root directory
+// or points at a symlink.
+var ErrUnsafePath = errors.New("unsafe path")
+
 // EnsureDir ensures that a directory exists. Creates it if it doesn't exist.
 // Creates parent directories as needed (like mkdir -p).
 func EnsureDir(path string, perm os.FileMode) error {
@@ -119,3 +125,37 @@ func AbsPath(path string) (string, error) {
 	}
 	return absPath, nil
 }
+
+// IsSafeName reports whether name is a single plain path element: non-empty,
+// not "." or "..", and free of path separators, drive colons and NUL bytes.
+func IsSafeName(name string) bool {
+	if name == "" || name == "." || name == ".." {
+		return false
+	}
+
+	return !strings.ContainsAny(name, "/\\:\x00")
+}
+
+// IsSymlink checks if a path is a symbolic link (without following it).
+func IsSymlink(path string) bool {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeSymlink != 0
+}
+
+// SafeJoin joins root and a single file name. The result is guaranteed to be a
+// direct child of root that is not a symlink; otherwise ErrUnsafePath is returned.
+func SafeJoin(root, name string) (string, error) {
+	if !IsSafeName(name) {
+		return "", fmt.Errorf("%w: %q", ErrUnsafePath, name)
+	}
+
+	path := filepath.Join(root, name)
+	if IsSymlink(path) {
+		return "", fmt.Errorf("%w: %s is a symlink", ErrUnsafePath, path)
+	}
+
+	return path, nil
+}
@@ -1,6 +1,7 @@
 package fsutil
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -443,3 +444,66 @@ func TestListDirsNonExistent(t *testing.T) {
 		t.Error("ListDirs should fail for non-existent directory")
 	}
 }
+
+// ========== SafeJoin Tests ==========
+
+func TestIsSafeName(t *testing.T) {
+	tests := []struct {
+		name string
+		safe bool
+	}{
+		{"key.jsonl", true},
+		{"..jsonl", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"a/b", false},
+		{`a\b`, false},
+		{"C:x", false},
+		{"a\x00b", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsSafeName(tt.name); got != tt.safe {
+			t.Errorf("IsSafeName(%q) = %v, want %v", tt.name, got, tt.safe)
+		}
+	}
+}
+
+func TestSafeJoinRejectsSymlink(t *testing.T) {
+	tmpDir := t.TempDir()
+	outside := filepath.Join(t.TempDir(), "secret.txt")
+	os.WriteFile(outside, []byte("secret"), 0644)
+
+	link := filepath.Join(tmpDir, "link.jsonl")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	if _, err := SafeJoin(tmpDir, "link.jsonl"); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("SafeJoin should refuse symlinks, got %v", err)
+	}
+
+	path, err := SafeJoin(tmpDir, "plain.jsonl")
+	if err != nil || path != filepath.Join(tmpDir, "plain.jsonl") {
+		t.Errorf("SafeJoin(plain.jsonl) = %q, %v", path, err)
+	}
+}
+
+func FuzzSafeJoin(f *testing.F) {
+	for _, seed := range []string{"key.jsonl", "..", "../etc/passwd", "/abs", `..\..\x`, "a\x00b", "C:\\x", "."} {
+		f.Add(seed)
+	}
+
+	root := f.TempDir()
+	f.Fuzz(func(t *testing.T, name string) {
+		path, err := SafeJoin(root, name)
+		if err != nil {
+			return
+		}
+
+		if filepath.Dir(path) != root {
+			t.Errorf("SafeJoin(%q) = %q escapes %q", name, path, root)
+		}
+	})
+}
@@ -0,0 +1,38 @@
+package fsutil
+
+import (
+	"fmt"
+	"os"
+)
+
+// ReplaceTail replaces the end of the file at path, from offset on, with
+// data and syncs it. Unlike AtomicWriteFile it writes only data, but a crash
+// midway leaves the file cut at offset, so callers log what they replace
+// first. The file must be at least offset bytes long.
+func ReplaceTail(path string, offset int64, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+	if info.Size() < offset {
+		return fmt.Errorf("file is %d bytes, shorter than offset %d", info.Size(), offset)
+	}
+
+	if err := f.Truncate(offset); err != nil {
+		return fmt.Errorf("failed to truncate file: %w", err)
+	}
+	if _, err := f.WriteAt(data, offset); err != nil {
+		return fmt.Errorf("failed to write to file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+
+	return f.Close()
+}
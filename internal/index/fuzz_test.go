@@ -0,0 +1,48 @@
+package index
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// FuzzKeySanitize checks that every key maps to a single plain file name
+// inside the namespace directory, and that sanitizing is stable.
+//
+// Run with: go test ./internal/index -fuzz FuzzKeySanitize
+func FuzzKeySanitize(f *testing.F) {
+	for _, key := range []string{
+		"user:1", "user/data:v1", "a//b::c", "../../etc/passwd", `C:\Windows`,
+		`\\server\share`, "..", ".", "", " _ ", "nul\x00byte", "ünïcödé/键", "\xff\xfe",
+	} {
+		f.Add(key)
+	}
+
+	f.Fuzz(func(t *testing.T, key string) {
+		sanitized := SanitizeKey(key)
+		if sanitized == "" {
+			t.Fatalf("SanitizeKey(%q) is empty", key)
+		}
+		if strings.ContainsAny(sanitized, "/\\:*?\"<>|\x00") {
+			t.Fatalf("SanitizeKey(%q) = %q keeps invalid characters", key, sanitized)
+		}
+		if SanitizeKey(sanitized) != sanitized {
+			t.Fatalf("SanitizeKey is not stable for %q: %q", key, sanitized)
+		}
+
+		for _, addHash := range []bool{false, true} {
+			name := GenerateFileName(key, addHash)
+			if !strings.HasSuffix(name, ".jsonl") || filepath.Base(name) != name {
+				t.Fatalf("GenerateFileName(%q, %v) = %q is not a plain file name", key, addHash, name)
+			}
+			if name == "." || name == ".." {
+				t.Fatalf("GenerateFileName(%q, %v) = %q", key, addHash, name)
+			}
+		}
+
+		// Valid keys are never path-like
+		if IsValidKey(key) && (strings.ContainsRune(key, 0) || strings.HasPrefix(key, "/")) {
+			t.Fatalf("IsValidKey accepted %q", key)
+		}
+	})
+}
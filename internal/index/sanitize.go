@@ -33,6 +33,9 @@ func SanitizeKey(key string) string {
 		result = strings.ReplaceAll(result, char, "_")
 	}
 
+	// NUL is never valid in file names
+	result = strings.ReplaceAll(result, "\x00", "_")
+
 	// Compress consecutive underscores to a single underscore
 	result = consecutiveUnderscores.ReplaceAllString(result, "_")
 
@@ -65,6 +68,22 @@ func GenerateFileName(key string, addHash bool) string {
 	return fmt.Sprintf("%s_%s.jsonl", sanitized, hash)
 }
 
+// HashedFileName generates the file name of a key from its SHA-256 digest,
+// for namespaces storing keys that don't sanitize to distinct names. It
+// has no relation to the key's text, so any two keys get different files.
+//
+// Format: {sha256 hex}.jsonl
+func HashedFileName(key string) string {
+	return fmt.Sprintf("%x.jsonl", sha256.Sum256([]byte(key)))
+}
+
+// IsKeyFileName reports whether fileName names the records file of a key.
+// Sanitized keys never start with an underscore, which is left to the
+// namespace's own files (e.g. "_keys.jsonl").
+func IsKeyFileName(fileName string) bool {
+	return strings.HasSuffix(fileName, ".jsonl") && !strings.HasPrefix(fileName, "_")
+}
+
 // hashString generates a short hash of a string.
 // Uses first 6 characters of SHA256 hash.
 func hashString(s string) string {
@@ -128,7 +147,13 @@ func NeedsHashSuffix(key string) bool {
 	return sanitized != cleaned
 }
 
-// IsValidKey checks if a key is valid (not empty and not too long).
+// IsValidKey checks if a key is valid: not empty, not too long, and not path-like.
+//
+// Keys are treated as untrusted input. Sanitization already maps every key to a
+// plain file name, but keys that look like path traversal are rejected outright:
+//   - NUL bytes
+//   - absolute paths ("/etc/passwd", `\\server\share`, `C:\Windows`)
+//   - ".." path segments ("../x", "a/../b")
 func IsValidKey(key string) bool {
 	if key == "" {
 		return false
@@ -140,9 +165,37 @@ func IsValidKey(key string) bool {
 		return false
 	}
 
+	if strings.ContainsRune(key, 0) {
+		return false
+	}
+
+	if strings.HasPrefix(key, "/") || strings.HasPrefix(key, "\\") || isDrivePath(key) {
+		return false
+	}
+
+	for _, segment := range strings.FieldsFunc(key, isPathSeparator) {
+		if segment == ".." {
+			return false
+		}
+	}
+
 	return true
 }
 
+// isDrivePath checks if a key starts with an absolute Windows drive path ("C:\\", "C:/").
+func isDrivePath(key string) bool {
+	if len(key) < 3 || key[1] != ':' || !isPathSeparator(rune(key[2])) {
+		return false
+	}
+	c := key[0]
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isPathSeparator reports whether r separates path segments on any platform.
+func isPathSeparator(r rune) bool {
+	return r == '/' || r == '\\'
+}
+
 // CleanPath cleans a file path and returns just the base name.
 func CleanPath(path string) string {
 	return filepath.Base(path)
@@ -1,7 +1,11 @@
 package index
 
 import (
+	"path/filepath"
+	"strings"
 	"testing"
+
+	"github.com/aigotowork/stow/internal/fsutil"
 )
 
 // ========== SanitizeKey Tests ==========
@@ -222,6 +226,26 @@ func TestGenerateFileNameDifferentKeys(t *testing.T) {
 	}
 }
 
+func TestHashedFileName(t *testing.T) {
+	// Keys that sanitize alike get different files
+	keys := []string{"a/b", "a:b", "a_b", "../x", strings.Repeat("k", 1000), "nul\x00key"}
+	seen := make(map[string]string)
+	for _, key := range keys {
+		name := HashedFileName(key)
+		if len(name) != 64+len(".jsonl") || !IsKeyFileName(name) || !fsutil.IsSafeName(name) {
+			t.Errorf("HashedFileName(%q) = %q, want a safe key file name", key, name)
+		}
+		if other, ok := seen[name]; ok {
+			t.Errorf("Keys %q and %q share %s", key, other, name)
+		}
+		seen[name] = key
+
+		if HashedFileName(key) != name {
+			t.Error("Hash should be deterministic")
+		}
+	}
+}
+
 // ========== ExtractKeyFromFileName Tests ==========
 
 func TestExtractKeyFromFileName(t *testing.T) {
@@ -405,6 +429,16 @@ func TestIsValidKey(t *testing.T) {
 		{"user/data:v1", true},
 		{"", false}, // Empty not valid
 		{"a", true}, // Single char valid
+		{"u:123", true},
+		{"a..b", true},
+		{"../etc/passwd", false},
+		{"a/../b", false},
+		{`a\..\b`, false},
+		{"/etc/passwd", false},
+		{`\\server\share`, false},
+		{`C:\Windows`, false},
+		{"C:/Windows", false},
+		{"nul\x00byte", false},
 	}
 
 	for _, tt := range tests {
@@ -506,3 +540,28 @@ func TestCollisionDetection(t *testing.T) {
 		t.Error("File names should be different for conflicting keys")
 	}
 }
+
+// ========== Fuzz Tests ==========
+
+func FuzzGenerateFileName(f *testing.F) {
+	for _, seed := range []string{"user/data:v1", "..", "../../etc/passwd", "/abs", `..\..\x`, "a\x00b", ".", "C:\\x"} {
+		f.Add(seed)
+	}
+
+	root := f.TempDir()
+	f.Fuzz(func(t *testing.T, key string) {
+		for _, addHash := range []bool{false, true} {
+			name := GenerateFileName(key, addHash)
+
+			if !fsutil.IsSafeName(name) {
+				t.Fatalf("GenerateFileName(%q, %v) = %q is not a plain file name", key, addHash, name)
+			}
+			if !strings.HasSuffix(name, ".jsonl") {
+				t.Fatalf("GenerateFileName(%q, %v) = %q lacks .jsonl suffix", key, addHash, name)
+			}
+			if filepath.Dir(filepath.Join(root, name)) != root {
+				t.Fatalf("GenerateFileName(%q, %v) = %q escapes the namespace", key, addHash, name)
+			}
+		}
+	})
+}
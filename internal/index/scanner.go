@@ -2,16 +2,30 @@ package index
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/aigotowork/stow/internal/core"
 	"github.com/aigotowork/stow/internal/fsutil"
 )
 
+// DefaultScanWorkers is the number of key files a Scanner reads at once
+// when Workers is 0.
+const DefaultScanWorkers = 8
+
 // Scanner scans a namespace directory and builds a KeyMapper.
 type Scanner struct {
 	decoder *core.Decoder
+
+	// Workers is the number of key files read at once. 0 means
+	// DefaultScanWorkers.
+	Workers int
+
+	// Progress, when set, is called after each key file is read with the
+	// number of files read so far and the total. Calls never overlap.
+	Progress func(done, total int)
 }
 
 // NewScanner creates a new Scanner.
@@ -22,7 +36,9 @@ func NewScanner() *Scanner {
 }
 
 // ScanNamespace scans a namespace directory and returns a KeyMapper.
-// It reads the first line of each .jsonl file to get the original key.
+// It reads the first line of each .jsonl file to get the original key,
+// Workers files at a time. Keys are added in file name order, so the
+// mapper doesn't depend on which reads finish first.
 //
 // Directory structure:
 //
@@ -33,49 +49,111 @@ func NewScanner() *Scanner {
 func (s *Scanner) ScanNamespace(namespacePath string) (*KeyMapper, error) {
 	mapper := NewKeyMapper()
 
-	// Find all .jsonl files
-	files, err := fsutil.FindFiles(namespacePath, "*.jsonl")
+	files, err := keyFiles(namespacePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to scan namespace: %w", err)
 	}
 
-	// Process each file
-	for _, filePath := range files {
-		// Skip files in _blobs directory
-		if strings.Contains(filePath, "_blobs") {
-			continue
+	// Read the original key from the first record of each file; files that
+	// can't be read or are invalid are skipped
+	keys := make([]string, len(files))
+	s.forEach(files, func(i int) {
+		keys[i], _ = s.readKeyFromFile(files[i])
+	})
+
+	for i, filePath := range files {
+		if keys[i] != "" {
+			mapper.Add(keys[i], filepath.Base(filePath))
 		}
+	}
+
+	return mapper, nil
+}
 
-		// Read the original key from the first record
-		originalKey, err := s.readKeyFromFile(filePath)
-		if err != nil {
-			// Skip files that can't be read or are invalid
-			// In production, this should log a warning
-			continue
+// forEach calls fn with the index of every file, from Workers goroutines,
+// and reports progress as calls return.
+func (s *Scanner) forEach(files []string, fn func(i int)) {
+	workers := s.Workers
+	if workers <= 0 {
+		workers = DefaultScanWorkers
+	}
+	workers = min(workers, len(files))
+
+	jobs := make(chan int)
+	finished := make(chan struct{})
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+				finished <- struct{}{}
+			}
+		}()
+	}
+
+	go func() {
+		for i := range files {
+			jobs <- i
 		}
+		close(jobs)
+		wg.Wait()
+		close(finished)
+	}()
+
+	done := 0
+	for range finished {
+		done++
+		if s.Progress != nil {
+			s.Progress(done, len(files))
+		}
+	}
+}
 
-		// Add to mapper
-		fileName := filepath.Base(filePath)
-		mapper.Add(originalKey, fileName)
+// keyFiles returns the key files directly in namespacePath, in name order.
+// Symlinks are never followed out of the namespace, and a path that isn't a
+// directory has no key files.
+func keyFiles(namespacePath string) ([]string, error) {
+	info, err := os.Stat(namespacePath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, nil
 	}
 
-	return mapper, nil
+	entries, err := os.ReadDir(namespacePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && IsKeyFileName(entry.Name()) {
+			files = append(files, filepath.Join(namespacePath, entry.Name()))
+		}
+	}
+	return files, nil
 }
 
 // readKeyFromFile reads the first record from a .jsonl file and returns the original key.
 func (s *Scanner) readKeyFromFile(filePath string) (string, error) {
-	// Read all records (we only need the first one, but ReadAll is simpler)
-	records, err := s.decoder.ReadAll(filePath)
+	// Stop at the first record, files can be large
+	key := ""
+	err := s.decoder.StreamFile(filePath, func(record *core.Record) error {
+		key = record.Meta.Key
+		return core.StopStream
+	})
 	if err != nil {
 		return "", err
 	}
 
-	if len(records) == 0 {
+	if key == "" {
 		return "", fmt.Errorf("file is empty: %s", filePath)
 	}
 
-	// Get the key from the first record
-	return records[0].Meta.Key, nil
+	return key, nil
 }
 
 // ScanAndValidate scans a namespace and validates the index.
@@ -112,7 +190,7 @@ func CountFiles(namespacePath string) (int, error) {
 
 	count := 0
 	for _, file := range files {
-		// Skip files in _blobs directory
+		// Skip files in _blobs directory and the namespace's own files
 		if !strings.Contains(file, "_blobs") {
 			count++
 		}
@@ -124,26 +202,23 @@ func CountFiles(namespacePath string) (int, error) {
 // ListKeys returns all keys in a namespace without building a full mapper.
 // This is faster than ScanNamespace if you only need the key list.
 func ListKeys(namespacePath string) ([]string, error) {
-	scanner := NewScanner()
-	files, err := fsutil.FindFiles(namespacePath, "*.jsonl")
+	files, err := keyFiles(namespacePath)
 	if err != nil {
 		return nil, err
 	}
 
-	var keys []string
-	for _, filePath := range files {
-		// Skip files in _blobs directory
-		if strings.Contains(filePath, "_blobs") {
-			continue
-		}
+	scanner := NewScanner()
+	found := make([]string, len(files))
+	scanner.forEach(files, func(i int) {
+		// Invalid files are skipped
+		found[i], _ = scanner.readKeyFromFile(files[i])
+	})
 
-		key, err := scanner.readKeyFromFile(filePath)
-		if err != nil {
-			// Skip invalid files
-			continue
+	var keys []string
+	for _, key := range found {
+		if key != "" {
+			keys = append(keys, key)
 		}
-
-		keys = append(keys, key)
 	}
 
 	return keys, nil
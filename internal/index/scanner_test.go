@@ -1,8 +1,10 @@
 package index
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -323,6 +325,48 @@ func TestScannerListKeysSkipsBlobDir(t *testing.T) {
 	}
 }
 
+func TestScannerWorkersAndProgress(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	const numFiles = 300
+	for i := 0; i < numFiles; i++ {
+		key := fmt.Sprintf("key:%03d", i)
+		content := `{"_meta":{"k":"` + key + `","v":1,"op":"put","ts":"2024-01-01T00:00:00Z"},"data":{}}` + "\n"
+		os.WriteFile(filepath.Join(tmpDir, GenerateFileName(key, false)), []byte(content), 0644)
+	}
+
+	var calls []int
+	scanner := NewScanner()
+	scanner.Workers = 32
+	scanner.Progress = func(done, total int) {
+		if total != numFiles {
+			t.Errorf("Progress total = %d, want %d", total, numFiles)
+		}
+		calls = append(calls, done)
+	}
+
+	mapper, err := scanner.ScanNamespace(tmpDir)
+	if err != nil {
+		t.Fatalf("ScanNamespace failed: %v", err)
+	}
+
+	if mapper.Count() != numFiles {
+		t.Errorf("Count = %d, want %d", mapper.Count(), numFiles)
+	}
+	if len(calls) != numFiles || calls[len(calls)-1] != numFiles {
+		t.Errorf("Progress called %d times, want %d", len(calls), numFiles)
+	}
+
+	// Keys are added in file order whatever order reads finish in
+	sequential, err := NewScanner().ScanNamespace(tmpDir)
+	if err != nil {
+		t.Fatalf("ScanNamespace failed: %v", err)
+	}
+	if !reflect.DeepEqual(mapper.index, sequential.index) {
+		t.Error("Parallel scan differs from sequential scan")
+	}
+}
+
 // ========== Error Handling Tests ==========
 
 func TestScannerNonExistentDirectory(t *testing.T) {
@@ -0,0 +1,159 @@
+// Package seal provides the encryption used for namespace-level keys:
+// AES-256-GCM for records and AES-256-CTR streams for blob files.
+//
+// Record and blob keys are derived from the namespace key with HKDF, so a
+// single 32-byte key protects both and destroying it shreds both.
+// Sealed records are bound to associated data naming them, and blob files
+// are named by a keyed hash, so neither can be passed off as another.
+package seal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// KeySize is the required namespace key length in bytes.
+const KeySize = 32
+
+// Overhead is the number of bytes EncryptWriter adds to a stream.
+const Overhead = aes.BlockSize
+
+// Algorithm names the cipher suite, recorded next to the key check.
+const Algorithm = "aes-256-gcm"
+
+// ErrDecrypt is returned when ciphertext cannot be authenticated,
+// usually because it was encrypted with a different key.
+var ErrDecrypt = errors.New("decryption failed")
+
+// Key holds the ciphers derived from a namespace key.
+type Key struct {
+	records cipher.AEAD
+	blobs   cipher.Block
+	names   []byte
+	check   string
+}
+
+// NewKey derives record and blob ciphers from a 32-byte key.
+func NewKey(key []byte) (*Key, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	recordKey, err := derive(key, "stow records")
+	if err != nil {
+		return nil, err
+	}
+	blobKey, err := derive(key, "stow blobs")
+	if err != nil {
+		return nil, err
+	}
+	nameKey, err := derive(key, "stow blob names")
+	if err != nil {
+		return nil, err
+	}
+	checkKey, err := derive(key, "stow key check")
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(recordKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	blobs, err := aes.NewCipher(blobKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{
+		records: aead,
+		blobs:   blobs,
+		names:   nameKey,
+		check:   hex.EncodeToString(checkKey[:8]),
+	}, nil
+}
+
+// derive expands a subkey for one purpose from the namespace key.
+func derive(key []byte, info string) ([]byte, error) {
+	return hkdf.Key(sha256.New, key, nil, info, KeySize)
+}
+
+// Check returns a fingerprint identifying the key without revealing it.
+// It is persisted so that opening a namespace with the wrong key fails fast.
+func (k *Key) Check() string {
+	return k.check
+}
+
+// Name returns an opaque file name component for id, e.g. a blob's content
+// hash, that reveals nothing about id without the key.
+func (k *Key) Name(id string) string {
+	mac := hmac.New(sha256.New, k.names)
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// Seal encrypts plaintext and returns nonce || ciphertext. The ciphertext
+// is bound to aad, which Open must be given again: sealed data moved to
+// another context fails to open.
+func (k *Key) Seal(plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, k.records.NonceSize(), k.records.NonceSize()+len(plaintext)+k.records.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return k.records.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// Open authenticates and decrypts the output of Seal for the same aad.
+func (k *Key) Open(sealed, aad []byte) ([]byte, error) {
+	nonceSize := k.records.NonceSize()
+	if len(sealed) < nonceSize+k.records.Overhead() {
+		return nil, ErrDecrypt
+	}
+
+	plaintext, err := k.records.Open(nil, sealed[:nonceSize], sealed[nonceSize:], aad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// EncryptWriter writes a random IV to w and returns a writer that encrypts
+// everything written to it. The stream is not authenticated: readers must
+// check the content hash kept in the (authenticated) record, as blob loads
+// do.
+func (k *Key) EncryptWriter(w io.Writer) (io.Writer, error) {
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, fmt.Errorf("failed to generate IV: %w", err)
+	}
+	if _, err := w.Write(iv); err != nil {
+		return nil, err
+	}
+
+	return cipher.StreamWriter{S: cipher.NewCTR(k.blobs, iv), W: w}, nil
+}
+
+// DecryptReader reads the IV written by EncryptWriter from r and returns
+// a reader of the decrypted stream.
+func (k *Key) DecryptReader(r io.Reader) (io.Reader, error) {
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(r, iv); err != nil {
+		return nil, fmt.Errorf("failed to read IV: %w", err)
+	}
+
+	return cipher.StreamReader{S: cipher.NewCTR(k.blobs, iv), R: r}, nil
+}
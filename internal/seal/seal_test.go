@@ -0,0 +1,126 @@
+package seal
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func testKey(t *testing.T, b byte) *Key {
+	t.Helper()
+	k, err := NewKey(bytes.Repeat([]byte{b}, KeySize))
+	if err != nil {
+		t.Fatalf("NewKey failed: %v", err)
+	}
+	return k
+}
+
+func TestNewKeyLength(t *testing.T) {
+	if _, err := NewKey(make([]byte, 16)); err == nil {
+		t.Error("expected error for short key")
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	k := testKey(t, 1)
+	plaintext := []byte(`{"name":"alice"}`)
+	aad := []byte("1:user:alice")
+
+	sealed, err := k.Seal(plaintext, aad)
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if bytes.Contains(sealed, plaintext) {
+		t.Error("sealed output contains plaintext")
+	}
+
+	again, _ := k.Seal(plaintext, aad)
+	if bytes.Equal(sealed, again) {
+		t.Error("sealing twice should use different nonces")
+	}
+
+	opened, err := k.Open(sealed, aad)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if !bytes.Equal(opened, plaintext) {
+		t.Errorf("Open = %q, want %q", opened, plaintext)
+	}
+
+	// Wrong key and tampering are both rejected
+	if _, err := testKey(t, 2).Open(sealed, aad); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("wrong key: expected ErrDecrypt, got %v", err)
+	}
+	if _, err := k.Open(sealed, []byte("1:user:bob")); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("other aad: expected ErrDecrypt, got %v", err)
+	}
+	sealed[len(sealed)-1] ^= 1
+	if _, err := k.Open(sealed, aad); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("tampered: expected ErrDecrypt, got %v", err)
+	}
+	if _, err := k.Open([]byte("short"), aad); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("short input: expected ErrDecrypt, got %v", err)
+	}
+}
+
+func TestName(t *testing.T) {
+	k := testKey(t, 1)
+	name := k.Name("3f9a")
+	if name != k.Name("3f9a") {
+		t.Error("Name should be deterministic")
+	}
+	if name == k.Name("3f9b") || name == testKey(t, 2).Name("3f9a") {
+		t.Error("Name should depend on id and key")
+	}
+	if strings.Contains(name, "3f9a") || len(name) != 32 {
+		t.Errorf("Name = %q, want 32 opaque hex digits", name)
+	}
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	k := testKey(t, 1)
+	plaintext := bytes.Repeat([]byte("blob content "), 1000)
+
+	var buf bytes.Buffer
+	w, err := k.EncryptWriter(&buf)
+	if err != nil {
+		t.Fatalf("EncryptWriter failed: %v", err)
+	}
+	// Write in uneven pieces, as the chunked blob writer does
+	for i := 0; i < len(plaintext); i += 777 {
+		end := min(i+777, len(plaintext))
+		if _, err := w.Write(plaintext[i:end]); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	if buf.Len() != len(plaintext)+Overhead {
+		t.Errorf("ciphertext length = %d, want %d", buf.Len(), len(plaintext)+Overhead)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("blob content")) {
+		t.Error("ciphertext contains plaintext")
+	}
+
+	r, err := k.DecryptReader(&buf)
+	if err != nil {
+		t.Fatalf("DecryptReader failed: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Error("decrypted stream mismatch")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	if testKey(t, 1).Check() != testKey(t, 1).Check() {
+		t.Error("check should be deterministic")
+	}
+	if testKey(t, 1).Check() == testKey(t, 2).Check() {
+		t.Error("different keys should have different checks")
+	}
+}
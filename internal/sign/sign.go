@@ -0,0 +1,112 @@
+// Package sign provides the record signatures of tamper-evident namespaces:
+// HMAC-SHA256 with a shared secret, or Ed25519 where only the holder of the
+// private key can sign and anyone with the public key can verify.
+package sign
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// Signature algorithms.
+const (
+	HMACSHA256 = "hmac-sha256"
+	Ed25519    = "ed25519"
+)
+
+// ErrNoKey is returned when signing or verifying without the key it needs.
+var ErrNoKey = errors.New("signing key required")
+
+// Key signs and verifies payloads with one algorithm. A key built from an
+// Ed25519 public key (or without a secret) only verifies, or does nothing.
+type Key struct {
+	algo    string
+	secret  []byte
+	private ed25519.PrivateKey
+	public  ed25519.PublicKey
+}
+
+// NewKey builds a key for algo. For HMACSHA256, key is the secret; for
+// Ed25519 it is a 32-byte seed or a 64-byte private key, and public an
+// optional public key used when key is nil. Both may be nil for a key that
+// can neither sign nor verify.
+func NewKey(algo string, key, public []byte) (*Key, error) {
+	k := &Key{algo: algo}
+
+	switch algo {
+	case HMACSHA256:
+		if key != nil && len(key) == 0 {
+			return nil, fmt.Errorf("hmac key must not be empty")
+		}
+		k.secret = key
+
+	case Ed25519:
+		switch len(key) {
+		case 0:
+		case ed25519.SeedSize:
+			k.private = ed25519.NewKeyFromSeed(key)
+		case ed25519.PrivateKeySize:
+			k.private = ed25519.PrivateKey(key)
+		default:
+			return nil, fmt.Errorf("ed25519 key must be %d or %d bytes, got %d",
+				ed25519.SeedSize, ed25519.PrivateKeySize, len(key))
+		}
+
+		if k.private != nil {
+			k.public = k.private.Public().(ed25519.PublicKey)
+		} else if public != nil {
+			if len(public) != ed25519.PublicKeySize {
+				return nil, fmt.Errorf("ed25519 public key must be %d bytes, got %d",
+					ed25519.PublicKeySize, len(public))
+			}
+			k.public = ed25519.PublicKey(public)
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown signing algorithm: %s", algo)
+	}
+
+	return k, nil
+}
+
+// Algorithm returns the algorithm name.
+func (k *Key) Algorithm() string {
+	return k.algo
+}
+
+// PublicKey returns the Ed25519 public key, or nil.
+func (k *Key) PublicKey() []byte {
+	return k.public
+}
+
+// Sign returns the signature of payload.
+func (k *Key) Sign(payload []byte) ([]byte, error) {
+	switch {
+	case k.secret != nil:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(payload)
+		return mac.Sum(nil), nil
+	case k.private != nil:
+		return ed25519.Sign(k.private, payload), nil
+	default:
+		return nil, ErrNoKey
+	}
+}
+
+// Verify reports whether sig is a valid signature of payload.
+// Returns ErrNoKey if the key can't verify.
+func (k *Key) Verify(payload, sig []byte) (bool, error) {
+	switch {
+	case k.secret != nil:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(payload)
+		return hmac.Equal(mac.Sum(nil), sig), nil
+	case k.public != nil:
+		return ed25519.Verify(k.public, payload, sig), nil
+	default:
+		return false, ErrNoKey
+	}
+}
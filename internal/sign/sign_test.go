@@ -0,0 +1,83 @@
+package sign
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestHMACSignVerify(t *testing.T) {
+	k, err := NewKey(HMACSHA256, []byte("secret"), nil)
+	if err != nil {
+		t.Fatalf("NewKey failed: %v", err)
+	}
+
+	sig, err := k.Sign([]byte("payload"))
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	if ok, err := k.Verify([]byte("payload"), sig); err != nil || !ok {
+		t.Errorf("Verify = %v, %v; want true", ok, err)
+	}
+	if ok, _ := k.Verify([]byte("payload!"), sig); ok {
+		t.Error("changed payload should not verify")
+	}
+
+	other, _ := NewKey(HMACSHA256, []byte("other"), nil)
+	if ok, _ := other.Verify([]byte("payload"), sig); ok {
+		t.Error("another secret should not verify")
+	}
+}
+
+func TestEd25519PublicKeyOnly(t *testing.T) {
+	seed := bytes.Repeat([]byte{7}, 32)
+	k, err := NewKey(Ed25519, seed, nil)
+	if err != nil {
+		t.Fatalf("NewKey failed: %v", err)
+	}
+
+	sig, err := k.Sign([]byte("payload"))
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	verifier, err := NewKey(Ed25519, nil, k.PublicKey())
+	if err != nil {
+		t.Fatalf("NewKey failed: %v", err)
+	}
+	if ok, err := verifier.Verify([]byte("payload"), sig); err != nil || !ok {
+		t.Errorf("Verify = %v, %v; want true", ok, err)
+	}
+	if _, err := verifier.Sign([]byte("payload")); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Sign without private key = %v, want ErrNoKey", err)
+	}
+}
+
+func TestNoKey(t *testing.T) {
+	k, err := NewKey(HMACSHA256, nil, nil)
+	if err != nil {
+		t.Fatalf("NewKey failed: %v", err)
+	}
+	if _, err := k.Sign([]byte("payload")); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Sign = %v, want ErrNoKey", err)
+	}
+	if _, err := k.Verify([]byte("payload"), nil); !errors.Is(err, ErrNoKey) {
+		t.Errorf("Verify = %v, want ErrNoKey", err)
+	}
+}
+
+func TestNewKeyErrors(t *testing.T) {
+	if _, err := NewKey("rsa", []byte("key"), nil); err == nil {
+		t.Error("expected error for unknown algorithm")
+	}
+	if _, err := NewKey(Ed25519, make([]byte, 16), nil); err == nil {
+		t.Error("expected error for short ed25519 key")
+	}
+	if _, err := NewKey(Ed25519, nil, make([]byte, 16)); err == nil {
+		t.Error("expected error for short ed25519 public key")
+	}
+	if _, err := NewKey(HMACSHA256, []byte{}, nil); err == nil {
+		t.Error("expected error for empty hmac key")
+	}
+}
@@ -0,0 +1,262 @@
+package stow
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/aigotowork/stow/internal/codec"
+)
+
+// joinConcurrency caps the Gets a join runs at once.
+const joinConcurrency = 8
+
+// KeyFunc returns the keys a join fetches, given the data of the record
+// being joined. No keys leave the field unset.
+type KeyFunc func(data map[string]interface{}) ([]string, error)
+
+// KeyFromField returns a KeyFunc reading the key from a field path of the
+// record (see AppendPath for the syntax). Numbers are formatted without
+// exponent, and arrays yield one key per element. A missing or null field
+// yields no keys.
+func KeyFromField(path string) KeyFunc {
+	return func(data map[string]interface{}) ([]string, error) {
+		if _, err := codec.ParsePath(path); err != nil {
+			return nil, err
+		}
+
+		value, err := codec.GetPath(data, path)
+		if err != nil {
+			return nil, nil
+		}
+
+		values, isArray := value.([]interface{})
+		if !isArray {
+			values = []interface{}{value}
+		}
+
+		var keys []string
+		for _, v := range values {
+			switch v := v.(type) {
+			case nil:
+			case string:
+				keys = append(keys, v)
+			case float64:
+				keys = append(keys, strconv.FormatFloat(v, 'f', -1, 64))
+			case bool:
+				keys = append(keys, strconv.FormatBool(v))
+			default:
+				return nil, fmt.Errorf("%w: %s is not a key", ErrInvalidPath, path)
+			}
+		}
+		return keys, nil
+	}
+}
+
+// JoinQuery fetches a record together with the records its fields refer to,
+// possibly in other namespaces. Build one with Join.
+type JoinQuery struct {
+	ns    Namespace
+	key   string
+	joins []join
+}
+
+// join fills one field of the result from another namespace.
+type join struct {
+	field string
+	ns    Namespace
+	keys  KeyFunc
+}
+
+// Join starts a query for key in ns. Each With adds a field filled from the
+// records the key's own fields refer to; Decode fetches them in parallel.
+//
+// Example:
+//
+//	var post struct {
+//		Title    string `json:"title"`
+//		AuthorID string `json:"author_id"`
+//		Author   User   `json:"author"`
+//		TagIDs   []int  `json:"tag_ids"`
+//		Tags     []Tag  `json:"tags"`
+//	}
+//	err := stow.Join(posts, "post:1").
+//		With("author", users, stow.KeyFromField("author_id")).
+//		With("tags", tags, stow.KeyFromField("tag_ids")).
+//		Decode(&post)
+func Join(ns Namespace, key string) *JoinQuery {
+	return &JoinQuery{ns: ns, key: key}
+}
+
+// With fills field of the result (its JSON name) with the records of ns at
+// the keys returned by keys. A slice field receives every record in key
+// order; any other field takes a single record.
+func (q *JoinQuery) With(field string, ns Namespace, keys KeyFunc) *JoinQuery {
+	q.joins = append(q.joins, join{field: field, ns: ns, keys: keys})
+	return q
+}
+
+// Decode gets the record into target, a pointer to a struct or a
+// map[string]interface{}, then fetches and decodes the joined records into
+// their fields. A referenced key that doesn't exist fails the join with
+// ErrNotFound.
+func (q *JoinQuery) Decode(target interface{}) error {
+	targetValue := reflect.ValueOf(target)
+	if targetValue.Kind() != reflect.Ptr || targetValue.IsNil() {
+		return fmt.Errorf("join target must be a non-nil pointer")
+	}
+	targetValue = targetValue.Elem()
+
+	item, err := q.ns.GetRaw(q.key)
+	if err != nil {
+		return err
+	}
+	if err := item.DecodeInto(target); err != nil {
+		return err
+	}
+	data := item.RawData()
+
+	var fetches []*joinFetch
+	var assign []func()
+
+	for _, j := range q.joins {
+		keys, err := j.keys(data)
+		if err != nil {
+			return fmt.Errorf("join %s: %w", j.field, err)
+		}
+
+		switch targetValue.Kind() {
+		case reflect.Struct:
+			field, ok := codec.FieldByName(targetValue, j.field)
+			if !ok {
+				return fmt.Errorf("join %s: no such field in %s", j.field, targetValue.Type())
+			}
+			f, err := structFetches(j, field, keys)
+			if err != nil {
+				return err
+			}
+			fetches = append(fetches, f...)
+
+		case reflect.Map:
+			m, ok := target.(*map[string]interface{})
+			if !ok {
+				return fmt.Errorf("join target must be a struct or map[string]interface{}")
+			}
+
+			// Maps can't be written concurrently, so results are set afterwards
+			values := make([]map[string]interface{}, len(keys))
+			for i, key := range keys {
+				fetches = append(fetches, &joinFetch{join: j, key: key, into: &values[i]})
+			}
+			field := j.field
+			assign = append(assign, func() {
+				if *m == nil {
+					*m = make(map[string]interface{})
+				}
+				switch len(values) {
+				case 0:
+				case 1:
+					(*m)[field] = values[0]
+				default:
+					list := make([]interface{}, len(values))
+					for i, v := range values {
+						list[i] = v
+					}
+					(*m)[field] = list
+				}
+			})
+
+		default:
+			return fmt.Errorf("join target must be a struct or map[string]interface{}")
+		}
+	}
+
+	if err := runFetches(fetches); err != nil {
+		return err
+	}
+	for _, fn := range assign {
+		fn()
+	}
+	return nil
+}
+
+// joinFetch is one Get of a join.
+type joinFetch struct {
+	join join
+	key  string
+	into interface{}
+
+	// set stores the result, for pointer fields allocated on demand
+	set func()
+}
+
+// structFetches plans the Gets filling a struct field.
+func structFetches(j join, field reflect.Value, keys []string) ([]*joinFetch, error) {
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+		field.Set(reflect.MakeSlice(field.Type(), len(keys), len(keys)))
+
+		fetches := make([]*joinFetch, len(keys))
+		for i, key := range keys {
+			fetches[i] = newJoinFetch(j, key, field.Index(i))
+		}
+		return fetches, nil
+	}
+
+	switch len(keys) {
+	case 0:
+		return nil, nil
+	case 1:
+		return []*joinFetch{newJoinFetch(j, keys[0], field)}, nil
+	default:
+		return nil, fmt.Errorf("join %s: %d keys for a single value field", j.field, len(keys))
+	}
+}
+
+// newJoinFetch decodes into dest, allocating pointer destinations.
+func newJoinFetch(j join, key string, dest reflect.Value) *joinFetch {
+	if dest.Kind() != reflect.Ptr {
+		return &joinFetch{join: j, key: key, into: dest.Addr().Interface()}
+	}
+
+	value := reflect.New(dest.Type().Elem())
+	return &joinFetch{
+		join: j,
+		key:  key,
+		into: value.Interface(),
+		set:  func() { dest.Set(value) },
+	}
+}
+
+// runFetches runs the Gets of a join in parallel and reports every failure.
+func runFetches(fetches []*joinFetch) error {
+	errs := make([]error, len(fetches))
+	sem := make(chan struct{}, joinConcurrency)
+
+	var wg sync.WaitGroup
+	for i, f := range fetches {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, f *joinFetch) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := f.join.ns.Get(f.key, f.into); err != nil {
+				errs[i] = fmt.Errorf("join %s: key %q: %w", f.join.field, f.key, err)
+			}
+		}(i, f)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	for _, f := range fetches {
+		if f.set != nil {
+			f.set()
+		}
+	}
+	return nil
+}
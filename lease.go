@@ -0,0 +1,304 @@
+package stow
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aigotowork/stow/internal/fsutil"
+)
+
+// ownerLeaseName is the lease file of a namespace with NamespaceConfig.OwnerLease.
+const ownerLeaseName = "_owner.json"
+
+// leaseFile is the content of _owner.json.
+//
+// Example:
+//
+//	{"owner":"web-1:4242:9f2c01ab","host":"web-1","pid":4242,"renewed_at":"...","expires_at":"..."}
+type leaseFile struct {
+	Owner     string    `json:"owner"`
+	Host      string    `json:"host,omitempty"`
+	PID       int       `json:"pid,omitempty"`
+	RenewedAt time.Time `json:"renewed_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// newOwnerID returns an owner ID unique to this process and store:
+// host, pid and a random suffix.
+func newOwnerID() string {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+// leaseKeeper holds the owner leases a writer store took on its namespaces
+// and renews each from its own goroutine until the store closes. All
+// methods are nil-safe; a nil keeper holds no leases and rejects nothing.
+type leaseKeeper struct {
+	owner     string
+	networkFS bool
+	resources *resources
+	logger    Logger
+
+	mu     sync.Mutex
+	leases map[string]*ownerLease // by namespace directory
+}
+
+func newLeaseKeeper(owner string, networkFS bool, resources *resources, logger Logger) *leaseKeeper {
+	if owner == "" {
+		owner = newOwnerID()
+	}
+	return &leaseKeeper{
+		owner:     owner,
+		networkFS: networkFS,
+		resources: resources,
+		logger:    logger,
+		leases:    make(map[string]*ownerLease),
+	}
+}
+
+// ownerLease is a store's claim on one namespace.
+type ownerLease struct {
+	keeper *leaseKeeper
+	path   string
+
+	mu      sync.Mutex
+	ttl     time.Duration
+	held    bool
+	expires time.Time // of our lease while held
+	holder  leaseFile // the other owner while not held
+	err     error     // of the last acquire
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// claim returns the lease of the namespace in dir, trying to acquire it
+// and starting its renewal the first time.
+func (k *leaseKeeper) claim(dir string, ttl time.Duration) *ownerLease {
+	if k == nil {
+		return nil
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	l, ok := k.leases[dir]
+	if !ok {
+		l = &ownerLease{
+			keeper: k,
+			path:   filepath.Join(dir, ownerLeaseName),
+			ttl:    ttl,
+			stop:   make(chan struct{}),
+			done:   make(chan struct{}),
+		}
+		l.mu.Lock()
+		l.acquire(false)
+		l.mu.Unlock()
+		k.leases[dir] = l
+		k.resources.start(l.run)
+	}
+
+	l.mu.Lock()
+	l.ttl = ttl
+	l.mu.Unlock()
+	return l
+}
+
+// drop stops renewing the lease of the namespace in dir. With release, the
+// lease file is removed if it still names this store, so another writer
+// can take over at once.
+func (k *leaseKeeper) drop(dir string, release bool) {
+	if k == nil {
+		return
+	}
+
+	k.mu.Lock()
+	l, ok := k.leases[dir]
+	delete(k.leases, dir)
+	k.mu.Unlock()
+
+	if ok {
+		l.close(release)
+	}
+}
+
+// close releases every lease.
+func (k *leaseKeeper) close() {
+	if k == nil {
+		return
+	}
+
+	k.mu.Lock()
+	leases := k.leases
+	k.leases = make(map[string]*ownerLease)
+	k.mu.Unlock()
+
+	for _, l := range leases {
+		l.close(true)
+	}
+}
+
+// check returns nil while the store holds the lease, and ErrNotOwner
+// while another writer does.
+func (l *ownerLease) check() error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// The other owner may be gone, or renewal may have fallen behind
+	if !l.held || !time.Now().Before(l.expires) {
+		l.acquire(false)
+	}
+
+	switch {
+	case l.held:
+		return nil
+	case l.err != nil:
+		return fmt.Errorf("%w: %v", ErrNotOwner, l.err)
+	default:
+		return fmt.Errorf("%w: held by %s until %s", ErrNotOwner, l.holder.Owner, l.holder.ExpiresAt.Format(time.RFC3339))
+	}
+}
+
+// take acquires the lease whoever holds it.
+func (l *ownerLease) take() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.acquire(true)
+	return l.err
+}
+
+// acquire writes the lease file for this store unless another owner's
+// lease is still valid, or whatever it holds with force (caller holds mu).
+func (l *ownerLease) acquire(force bool) {
+	now := time.Now()
+
+	if !force {
+		current, err := readLeaseFile(l.path)
+		if err != nil && !os.IsNotExist(err) {
+			l.keeper.logger.Warn("ignoring unreadable lease file", Field{"path", l.path}, Field{"error", err})
+		}
+		if current.Owner != "" && current.Owner != l.keeper.owner && now.Before(current.ExpiresAt) {
+			l.held = false
+			l.holder = current
+			l.err = nil
+			return
+		}
+	}
+
+	host, _ := os.Hostname()
+	lease := leaseFile{
+		Owner:     l.keeper.owner,
+		Host:      host,
+		PID:       os.Getpid(),
+		RenewedAt: now.UTC(),
+		ExpiresAt: now.Add(l.ttl).UTC(),
+	}
+	data, err := json.MarshalIndent(lease, "", "  ")
+	if err == nil {
+		if l.keeper.networkFS {
+			err = fsutil.NetworkWriteFile(l.path, data, 0644)
+		} else {
+			err = fsutil.AtomicWriteFile(l.path, data, 0644)
+		}
+	}
+	if err != nil {
+		// A lease still held stays valid until it expires
+		l.err = fmt.Errorf("failed to write lease: %w", err)
+		l.held = l.held && now.Before(l.expires)
+		return
+	}
+
+	l.held = true
+	l.expires = lease.ExpiresAt
+	l.err = nil
+}
+
+// run renews the lease every third of its duration.
+func (l *ownerLease) run() {
+	defer close(l.done)
+
+	for {
+		l.mu.Lock()
+		interval := max(l.ttl/3, time.Millisecond)
+		l.mu.Unlock()
+
+		select {
+		case <-l.stop:
+			return
+		case <-time.After(interval):
+		}
+
+		l.mu.Lock()
+		wasHeld := l.held
+		l.acquire(false)
+		if wasHeld && !l.held {
+			l.keeper.logger.Warn("lost namespace ownership", Field{"path", l.path}, Field{"owner", l.holder.Owner}, Field{"error", l.err})
+		}
+		l.mu.Unlock()
+	}
+}
+
+// close stops renewal and, with release, removes the lease file if it
+// still names this store.
+func (l *ownerLease) close(release bool) {
+	close(l.stop)
+	<-l.done
+
+	if !release {
+		return
+	}
+	if current, err := readLeaseFile(l.path); err == nil && current.Owner == l.keeper.owner {
+		os.Remove(l.path)
+	}
+}
+
+// TakeOwnership makes this store the writer of a namespace with OwnerLease,
+// whoever holds its lease, e.g. after replacing a deployment whose old
+// instance is still running. The previous owner's writes fail with
+// ErrNotOwner once it next renews.
+//
+// Example:
+//
+//	if err := ns.Put("job", job); errors.Is(err, stow.ErrNotOwner) {
+//		// the operator confirmed the other instance is being shut down
+//		err = ns.TakeOwnership()
+//	}
+func (ns *namespace) TakeOwnership() error {
+	if ns.readOnly {
+		return ErrReadOnly
+	}
+	ttl := ns.cfg().OwnerLease
+	if ttl <= 0 {
+		return fmt.Errorf("%w: OwnerLease is not set", ErrInvalidConfig)
+	}
+	if ns.leases == nil {
+		return nil
+	}
+	return ns.leases.claim(ns.path, ttl).take()
+}
+
+// readLeaseFile reads a lease file.
+func readLeaseFile(path string) (leaseFile, error) {
+	var lease leaseFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return lease, err
+	}
+	if err := json.Unmarshal(data, &lease); err != nil {
+		return leaseFile{}, fmt.Errorf("failed to parse lease: %w", err)
+	}
+	return lease, nil
+}
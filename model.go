@@ -0,0 +1,110 @@
+package stow
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/aigotowork/stow/internal/codec"
+)
+
+// ValidateModel checks the stow struct tags of T and reports every tag
+// that Put would reject or silently ignore: unknown options, `file` on a
+// field that can't hold a blob, `file` combined with `inline` or `vector`,
+// and name_field references to missing or non-string fields. Call it at
+// startup so a bad tag fails fast instead of at the first Put:
+//
+//	if err := stow.ValidateModel[Document](); err != nil {
+//	    log.Fatal(err)
+//	}
+//
+// The returned error wraps ErrInvalidTag once per problem.
+func ValidateModel[T any]() error {
+	return codec.ValidateTags(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+// Enum is implemented by string types with a fixed set of values:
+//
+//	type Status string
+//
+//	func (Status) EnumValues() []string {
+//	    return []string{"draft", "published", "archived"}
+//	}
+//
+// Put rejects a struct whose Status field holds any other value, and so
+// does Get when decoding one, both with ErrInvalidEnum. A field tagged
+// `stow:"enum:draft|published|archived"` is checked the same way, and the
+// tag wins over the type's values.
+type Enum = codec.Enum
+
+// ModelOptions are per-type defaults registered with Store.RegisterModel.
+// They apply to every Put and PutAuto of a value of the type (or a pointer
+// to it), in all namespaces of the store.
+type ModelOptions struct {
+	// Key derives the key PutAuto stores a value under, instead of
+	// generating one. An explicit WithIDGenerator takes precedence.
+	Key func(value interface{}) (string, error)
+
+	// BlobThreshold overrides the namespace BlobThreshold for values of the
+	// type. Zero keeps the namespace setting.
+	BlobThreshold int64
+
+	// PutOptions are applied before the options passed to Put, which win
+	// on conflict.
+	PutOptions []PutOption
+}
+
+// modelRegistry is the store-wide registry of model options, by type.
+type modelRegistry struct {
+	mu     sync.RWMutex
+	models map[reflect.Type]ModelOptions
+}
+
+// register validates the stow tags of model's type and records its options,
+// replacing earlier ones.
+func (r *modelRegistry) register(model interface{}, opts ModelOptions) error {
+	typ := modelType(model)
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return fmt.Errorf("model must be a struct, got %T", model)
+	}
+	if opts.BlobThreshold < 0 {
+		return fmt.Errorf("model %s: BlobThreshold must be non-negative", typ)
+	}
+	if err := codec.ValidateTags(typ); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.models == nil {
+		r.models = make(map[reflect.Type]ModelOptions)
+	}
+	r.models[typ] = opts
+	return nil
+}
+
+// lookup returns the options registered for the type of value.
+func (r *modelRegistry) lookup(value interface{}) (ModelOptions, bool) {
+	if r == nil {
+		return ModelOptions{}, false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.models) == 0 {
+		return ModelOptions{}, false
+	}
+	opts, ok := r.models[modelType(value)]
+	return opts, ok
+}
+
+// modelType returns the type of value with pointers removed.
+func modelType(value interface{}) reflect.Type {
+	typ := reflect.TypeOf(value)
+	for typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ
+}
@@ -5,7 +5,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 
 	"github.com/aigotowork/stow/internal/blob"
 	"github.com/aigotowork/stow/internal/codec"
@@ -16,10 +20,11 @@ import (
 
 // namespace implements the Namespace interface.
 type namespace struct {
-	name   string
-	path   string
-	config NamespaceConfig
-	logger Logger
+	name    string
+	path    string
+	blobDir string // _blobs/ or NamespaceConfig.BlobDir
+	config  NamespaceConfig
+	logger  Logger
 
 	// Core components
 	blobManager *blob.Manager
@@ -33,33 +38,132 @@ type namespace struct {
 	// Concurrency control
 	mu       sync.RWMutex    // For metadata operations (keyMapper, config, etc.)
 	keyLocks sync.Map        // Per-key locks: key → *sync.Mutex
+	pinsMu   sync.Mutex      // Guards _pins.json
+	tagsMu   sync.Mutex      // Guards _tags.json
+	configMu sync.RWMutex    // Guards config
+
+	// Store-wide disk usage tracking (nil when disabled)
+	disk *diskMonitor
+
+	// Store-wide blob processors (nil when none are registered)
+	processors *blobProcessors
+
+	// Store-wide model options (nil outside a store)
+	models *modelRegistry
+
+	// Store-wide file handle and goroutine accounting (nil outside a store)
+	resources *resources
+
+	// Store-wide access control (nil allows everything)
+	authorizer Authorizer
+
+	// Store-wide owner leases (nil outside a writer store)
+	leases *leaseKeeper
+
+	// Write-ahead log of multi-file operations (nil in replicas)
+	intents *intentLog
+
+	// Store-wide operation timeouts and slow-op logging (nil when disabled)
+	timer *opTimer
+
+	// Multi-process access: the writer records changes for replicas (nil in
+	// replicas); replicas reject writes
+	changes  *changeLog
+	readOnly bool
+
+	// networkFS selects writes safe on NFS and SMB volumes
+	networkFS bool
+
+	// scanWorkers is the number of key files read at once by rescans
+	scanWorkers int
+
+	// encrypted is set once a key is applied
+	encrypted bool
+
+	// closed is set once the store evicted the handle (see close)
+	closed atomic.Bool
+
+	// generation is bumped when a replica invalidates keys, so reads that
+	// raced with the invalidation don't re-cache stale data
+	generation atomic.Uint64
+
+	// Cache-miss loads in flight (see GetCached)
+	loadMu  sync.Mutex
+	loading map[loadKey]*loadCall
+
+	// External change watchers (see WatchExternalChanges)
+	watchMu  sync.Mutex
+	watchers []*ExternalWatcher
+
+	// Boolean field indexes (see KeysWhere)
+	bools boolIndex
+
+	// Indexes of fields tagged `stow:"index"` (see KeysByIndex)
+	indexes fieldIndexes
+
+	// Record sequence numbers
+	seq writeSeq
+
+	// _keys.jsonl entries by file name (see NamespaceConfig.KeyManifest),
+	// nil until the manifest is built
+	manifestMu sync.Mutex
+	manifest   map[string]keyManifestEntry
 
 	// Statistics
 	stats NamespaceStats
+
+	// File IO by operation since the namespace was opened
+	io ioTotals
 }
 
 // openNamespace opens or creates a namespace.
-func openNamespace(path, name string, config NamespaceConfig, logger Logger) (*namespace, error) {
+// Read-only namespaces never write to disk; networkFS selects writes safe on
+// network filesystems (see WithNetworkFSMode); scan sets how the key index
+// is built.
+func openNamespace(path, name string, config NamespaceConfig, logger Logger, readOnly, networkFS bool, scan scanOptions) (*namespace, error) {
+	// The persisted blob directory wins, like the rest of the persisted config
+	if persisted, err := readNamespaceConfig(path); err == nil {
+		config.BlobDir = persisted.BlobDir
+		config.HashedKeys = persisted.HashedKeys
+	}
+	blobDir, err := resolveBlobDir(path, config.BlobDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Never follow a symlinked namespace or blob directory out of the store
+	if fsutil.IsSymlink(path) || fsutil.IsSymlink(blobDir) {
+		return nil, fmt.Errorf("%w: %s", ErrUnsafePath, path)
+	}
+
 	// Ensure namespace directory exists
 	if err := fsutil.EnsureDir(path, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create namespace directory: %w", err)
 	}
 
-	// Ensure _blobs directory exists
-	blobDir := filepath.Join(path, "_blobs")
+	// Ensure blob directory exists
 	if err := fsutil.EnsureDir(blobDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create blobs directory: %w", err)
 	}
 
-	// Create blob manager
-	blobManager, err := blob.NewManager(blobDir, config.MaxFileSize, config.BlobChunkSize)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create blob manager: %w", err)
-	}
+	// Build the blob index while the key files are scanned: both list
+	// directories that can hold hundreds of thousands of files
+	var (
+		blobManager *blob.Manager
+		blobErr     error
+		blobDone    = make(chan struct{})
+	)
+	go func() {
+		defer close(blobDone)
+		blobManager, blobErr = blob.NewManager(blobDir, config.MaxFileSize, config.BlobChunkSize)
+	}()
 
 	// Scan directory and build key mapper
-	scanner := index.NewScanner()
-	keyMapper, err := scanner.ScanNamespace(path)
+	keyMapper, err := scan.scanner(name).ScanNamespace(path)
+	<-blobDone
+	if blobErr != nil {
+		return nil, fmt.Errorf("failed to create blob manager: %w", blobErr)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to scan namespace: %w", err)
 	}
@@ -74,6 +178,7 @@ func openNamespace(path, name string, config NamespaceConfig, logger Logger) (*n
 	ns := &namespace{
 		name:        name,
 		path:        path,
+		blobDir:     blobDir,
 		config:      config,
 		logger:      logger,
 		blobManager: blobManager,
@@ -83,16 +188,41 @@ func openNamespace(path, name string, config NamespaceConfig, logger Logger) (*n
 		unmarshaler: unmarshaler,
 		decoder:     core.NewDecoder(),
 		encoder:     core.NewEncoder(),
+		readOnly:    readOnly,
+		networkFS:   networkFS,
+		scanWorkers: scan.workers,
+	}
+	ns.encoder.SetNetworkFS(networkFS)
+	if !readOnly {
+		ns.intents = newIntentLog(path)
 	}
 
 	// Try to load config from file
-	if err := ns.loadConfig(); err != nil {
+	if err := ns.loadConfig(); err != nil && !readOnly {
 		// Config doesn't exist, save default config
 		if err := ns.saveConfig(); err != nil {
 			logger.Warn("failed to save default config", Field{"error", err})
 		}
 	}
 
+	// Set up encryption (the key itself is never kept in the config)
+	ns.config.key = nil
+	if err := ns.applyKey(config.key); err != nil {
+		return nil, err
+	}
+
+	// Set up signing (after loading, so the persisted algorithm wins)
+	if err := ns.applySigning(); err != nil {
+		return nil, err
+	}
+
+	// Apply blob settings (after loading, so the persisted config wins)
+	ns.applyBlobConfig()
+
+	// Decoder limits are fixed for the life of the handle
+	ns.decoder.MaxLineBytes = int(ns.config.MaxLineBytes)
+	ns.decoder.MaxRecordDataBytes = int(ns.config.MaxRecordDataBytes)
+
 	return ns, nil
 }
 
@@ -117,8 +247,18 @@ func (ns *namespace) getKeyLock(key string) *sync.Mutex {
 
 // Put stores a key-value pair.
 func (ns *namespace) Put(key string, value interface{}, opts ...PutOption) error {
+	return ns.timer.run(opNamePut, ns.name, key, func() error {
+		return ns.put(key, value, opts...)
+	})
+}
+
+func (ns *namespace) put(key string, value interface{}, opts ...PutOption) error {
+	if err := ns.checkWritable(); err != nil {
+		return err
+	}
+
 	// Validate key
-	if !index.IsValidKey(key) {
+	if !ns.validKey(key) {
 		return fmt.Errorf("invalid key: %s", key)
 	}
 
@@ -127,26 +267,137 @@ func (ns *namespace) Put(key string, value interface{}, opts ...PutOption) error
 	keyLock.Lock()
 	defer keyLock.Unlock()
 
+	return ns.putLocked(key, value, opts...)
+}
+
+// putLocked writes a value for key. Caller must hold the key lock.
+func (ns *namespace) putLocked(key string, value interface{}, opts ...PutOption) (err error) {
 	// Apply options
+	options := ns.putOptions(value, opts)
+	var iostats IOStats
+	defer func() { ns.io.add(&ns.io.put, &iostats, options.ioStats...) }()
+	if options.writtenVersion != nil {
+		defer func() {
+			if err == nil {
+				*options.writtenVersion = ns.latestVersion(key)
+			}
+		}()
+	}
+
+	// Marshal value
+	data, blobRefs, err := ns.marshaler.Marshal(value, ns.marshalOptions(options))
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %w", err)
+	}
+	iostats.wroteBlobs(blobRefs...)
+	if err := ns.normalize(data); err != nil {
+		return err
+	}
+
+	// Indexes declared by the value's type are built before its first write
+	if fields := codec.IndexFields(value); len(fields) > 0 {
+		if err := ns.ensureIndexes(fields); err != nil {
+			return err
+		}
+	}
+
+	// Changes limited to noversion fields update the latest record in place
+	// (scheduled writes always append)
+	if fields := codec.NoVersionFields(value); len(fields) > 0 && options.visibleAt.IsZero() {
+		// Its blobs are mostly those of the latest record, so they are left
+		// to GC on errors
+		updated, err := ns.updateUnversioned(key, data, fields, blobRefs, &iostats)
+		if err != nil {
+			return err
+		}
+		if updated {
+			return nil
+		}
+	}
+
+	// Values equal to the latest version append nothing (its blobs are the
+	// same deduplicated files)
+	if (options.skipUnchanged || ns.cfg().SkipUnchanged) && options.visibleAt.IsZero() {
+		unchanged, err := ns.isUnchanged(key, data)
+		if err != nil {
+			return err
+		}
+		if unchanged {
+			return nil
+		}
+	}
+
+	// Run blob processors for derived artifacts
+	derived := ns.deriveBlobs(data)
+	iostats.wroteBlobs(derived...)
+	blobRefs = append(blobRefs, derived...)
+
+	// Bursts of writes replace the latest record (scheduled writes always append)
+	if window := ns.cfg().CoalesceWindow; window > 0 && options.visibleAt.IsZero() {
+		coalesced, err := ns.coalescePut(key, data, blobRefs, window, &iostats)
+		if err != nil {
+			for _, ref := range blobRefs {
+				ns.blobManager.Delete(ref)
+			}
+			return err
+		}
+		if coalesced {
+			return nil
+		}
+	}
+
+	return ns.appendPut(key, data, blobRefs, options.visibleAt, &iostats)
+}
+
+// putOptions applies the options registered for the type of value, then opts.
+func (ns *namespace) putOptions(value interface{}, opts []PutOption) *putOptions {
 	options := &putOptions{}
+	if model, ok := ns.models.lookup(value); ok {
+		options.keyFunc = model.Key
+		options.blobThreshold = model.BlobThreshold
+		for _, opt := range model.PutOptions {
+			opt(options)
+		}
+	}
 	for _, opt := range opts {
 		opt(options)
 	}
+	return options
+}
 
-	// Marshal value
-	marshalOpts := codec.MarshalOptions{
-		BlobThreshold: ns.config.BlobThreshold,
-		ForceFile:     options.forceFile,
-		ForceInline:   options.forceInline,
-		FileName:      options.fileName,
-		MimeType:      options.mimeType,
+// marshalOptions builds codec options from the namespace config and put options.
+func (ns *namespace) marshalOptions(options *putOptions) codec.MarshalOptions {
+	blobThreshold := ns.cfg().BlobThreshold
+	if options.blobThreshold > 0 {
+		blobThreshold = options.blobThreshold
 	}
 
-	data, blobRefs, err := ns.marshaler.Marshal(value, marshalOpts)
-	if err != nil {
-		return fmt.Errorf("failed to marshal value: %w", err)
+	return codec.MarshalOptions{
+		BlobThreshold:       blobThreshold,
+		NestedBlobThreshold: ns.cfg().NestedBlobThreshold,
+		NonFiniteSentinels:  ns.cfg().NonFiniteFloats == FloatSentinel,
+		ForceFile:           options.forceFile,
+		ForceInline:         options.forceInline,
+		FileName:            options.fileName,
+		MimeType:            options.mimeType,
 	}
+}
 
+// normalize runs the configured normalizers over data.
+func (ns *namespace) normalize(data map[string]interface{}) error {
+	for _, fn := range ns.cfg().Normalizers {
+		if err := fn(data); err != nil {
+			return fmt.Errorf("normalizer: %w", err)
+		}
+	}
+	return nil
+}
+
+// appendPut appends a put record with already-marshaled data (caller must hold key lock).
+// Blobs in blobRefs are removed if the record cannot be written.
+// A non-zero visibleAt schedules the record (see WithVisibleAt). The IO is
+// counted in iostats.
+func (ns *namespace) appendPut(key string, data map[string]interface{}, blobRefs []*blob.Reference, visibleAt time.Time, iostats *IOStats) error {
 	// Get file path (need read lock for keyMapper)
 	ns.mu.RLock()
 	filePath, err := ns.getFilePath(key, true)
@@ -155,20 +406,90 @@ func (ns *namespace) Put(key string, value interface{}, opts ...PutOption) error
 		return err
 	}
 
-	// Get current version
+	// Get current version (reads the whole file)
+	sizeBefore := fsutil.FileSize(filePath)
+	iostats.read(sizeBefore)
 	version := ns.getNextVersion(filePath)
 
 	// Create record
 	record := core.NewPutRecord(key, version, data)
+	record.Meta.VisibleAt = visibleAt
+	ns.stamp(record.Meta)
+	if err := ns.linkRecord(filePath, record); err != nil {
+		for _, ref := range blobRefs {
+			ns.blobManager.Delete(ref)
+		}
+		return err
+	}
+
+	// Enforce record size limit
+	spilled, sizeWarning, err := ns.limitRecordSize(record)
+	if err != nil {
+		for _, ref := range blobRefs {
+			ns.blobManager.Delete(ref)
+		}
+		return err
+	}
+	if spilled != nil {
+		iostats.wroteBlobs(spilled)
+		blobRefs = append(blobRefs, spilled)
+	}
+
+	// Enforce the store's hard cap
+	var writeSize int64
+	if ns.disk != nil {
+		line, err := ns.encoder.Encode(record)
+		if err != nil {
+			for _, ref := range blobRefs {
+				ns.blobManager.Delete(ref)
+			}
+			return fmt.Errorf("failed to encode record: %w", err)
+		}
+		writeSize = int64(len(line))
+		for _, ref := range blobRefs {
+			writeSize += ref.Size
+		}
+
+		if err := ns.disk.check(writeSize); err != nil {
+			for _, ref := range blobRefs {
+				ns.blobManager.Delete(ref)
+			}
+			return err
+		}
+	}
+
+	// Log the blobs the record will reference, so a crash before they
+	// are both on disk is rolled back on the next open
+	var intent int64
+	if len(blobRefs) > 0 {
+		intent, err = ns.intents.begin(intentEntry{
+			Op:      intentPut,
+			Key:     key,
+			File:    filepath.Base(filePath),
+			Version: version,
+			Blobs:   ns.intentBlobs(blobRefs),
+		})
+		if err != nil {
+			for _, ref := range blobRefs {
+				ns.blobManager.Delete(ref)
+			}
+			return err
+		}
+	}
 
 	// Append to file
-	if err := core.AppendRecord(filePath, record); err != nil {
+	err = ns.encoder.Append(filePath, record)
+	ns.intents.done(intent)
+	if err != nil {
 		// Clean up blobs on failure
 		for _, ref := range blobRefs {
 			ns.blobManager.Delete(ref)
 		}
 		return fmt.Errorf("failed to append record: %w", err)
 	}
+	appended := fsutil.FileSize(filePath) - sizeBefore
+	iostats.wrote(appended)
+	iostats.LogicalBytes += appended
 
 	// Update key mapper (need write lock for metadata)
 	ns.mu.Lock()
@@ -176,15 +497,26 @@ func (ns *namespace) Put(key string, value interface{}, opts ...PutOption) error
 	ns.keyMapper.Add(key, fileName)
 	ns.mu.Unlock()
 
+	ns.disk.add(writeSize)
+	ns.afterWrite(changePut, key, filePath, version)
+
 	// Update cache (no lock needed, cache is thread-safe)
-	ns.cache.Set(key, data)
+	// Spilled records are cached in their expanded form
+	if !record.Meta.IsVisible(time.Now()) {
+		ns.cache.Delete(key)
+	} else if spilled != nil {
+		ns.cache.Set(key, data)
+	} else {
+		ns.cache.Set(key, record.Data)
+	}
 
 	// Auto compact if enabled
-	if ns.config.AutoCompact {
-		go ns.compactIfNeeded(key, filePath)
+	if ns.cfg().AutoCompact {
+		// Skipped over the goroutine cap; a later write checks again
+		ns.resources.spawn(func() { ns.compactIfNeeded(key, filePath) })
 	}
 
-	return nil
+	return sizeWarning
 }
 
 // MustPut is like Put but panics on error.
@@ -195,47 +527,91 @@ func (ns *namespace) MustPut(key string, value interface{}, opts ...PutOption) {
 }
 
 // Get retrieves a value by key.
-func (ns *namespace) Get(key string, target interface{}) error {
+func (ns *namespace) Get(key string, target interface{}, opts ...GetOption) error {
+	options := getOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	// Only the read is timed: target is never touched after a timeout
+	var data map[string]interface{}
+	var degraded *DegradedReadError
+	err := ns.timer.run(opNameGet, ns.name, key, func() error {
+		var err error
+		if options.fallback {
+			data, degraded, err = ns.fallbackData(key)
+		} else {
+			data, err = ns.latestData(key)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Unmarshal into target (derived artifacts are read via GetDerived)
+	if err := ns.unmarshaler.Unmarshal(withoutDerived(data), target); err != nil {
+		return err
+	}
+	if degraded != nil {
+		return degraded
+	}
+	return nil
+}
+
+// latestData returns the data of the latest put record for a key,
+// consulting the cache first and populating it on a miss.
+func (ns *namespace) latestData(key string) (map[string]interface{}, error) {
+	if ns.closed.Load() {
+		return nil, ErrClosed
+	}
+
 	// Check cache first (no lock needed, cache is thread-safe)
-	if !ns.config.DisableCache {
+	if !ns.cfg().DisableCache {
 		if cached, ok := ns.cache.Get(key); ok {
 			data, ok := cached.(map[string]interface{})
 			if ok {
-				return ns.unmarshaler.Unmarshal(data, target)
+				return data, nil
 			}
 		}
 	}
 
+	generation := ns.generation.Load()
+
 	// Get file path (need read lock for keyMapper)
 	ns.mu.RLock()
 	filePath, err := ns.getFilePath(key, false)
 	ns.mu.RUnlock()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Check if file exists
 	if !fsutil.FileExists(filePath) {
-		return ErrNotFound
+		return nil, ErrNotFound
 	}
 
-	// Read last valid record (no lock needed, file reads are safe)
-	record, err := ns.decoder.ReadLastValid(filePath)
+	// Read last visible record (no lock needed, file reads are safe)
+	record, pending, err := ns.decoder.ReadLastVisible(filePath, time.Now())
 	if err != nil {
-		return fmt.Errorf("failed to read record: %w", err)
+		return nil, fmt.Errorf("failed to read record: %w", err)
 	}
 
 	if record == nil || record.Meta.IsDelete() {
-		return ErrNotFound
+		return nil, ErrNotFound
 	}
 
-	// Update cache
-	if !ns.config.DisableCache {
+	if err := ns.expandRecord(record); err != nil {
+		return nil, err
+	}
+
+	// Update cache, unless a replica invalidated keys while we were reading.
+	// Keys with a scheduled record aren't cached, so it shows up on time
+	if !ns.cfg().DisableCache && !pending && ns.generation.Load() == generation {
 		ns.cache.Set(key, record.Data)
 	}
 
-	// Unmarshal into target
-	return ns.unmarshaler.Unmarshal(record.Data, target)
+	return record.Data, nil
 }
 
 // MustGet is like Get but panics on error.
@@ -265,16 +641,36 @@ func (ns *namespace) GetRaw(key string) (RawItem, error) {
 		return nil, ErrNotFound
 	}
 
+	if err := ns.expandRecord(record); err != nil {
+		return nil, err
+	}
+
 	return &rawItem{record: record, unmarshaler: ns.unmarshaler}, nil
 }
 
 // Delete marks a key as deleted.
-func (ns *namespace) Delete(key string) error {
+func (ns *namespace) Delete(key string, opts ...DeleteOption) error {
+	if err := ns.checkWritable(); err != nil {
+		return err
+	}
+
 	// Acquire key-level lock
 	keyLock := ns.getKeyLock(key)
 	keyLock.Lock()
 	defer keyLock.Unlock()
 
+	// Apply options
+	options := &deleteOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	return ns.appendDelete(key, options.reason, "")
+}
+
+// appendDelete appends a delete record (caller must hold key lock).
+// renamedTo is set when the key was renamed (see Rename).
+func (ns *namespace) appendDelete(key, reason, renamedTo string) error {
 	// Get file path (need read lock for keyMapper)
 	ns.mu.RLock()
 	filePath, err := ns.getFilePath(key, false)
@@ -288,11 +684,18 @@ func (ns *namespace) Delete(key string) error {
 
 	// Create delete record
 	record := core.NewDeleteRecord(key, version)
+	record.Meta.Reason = reason
+	record.Meta.RenamedTo = renamedTo
+	ns.stamp(record.Meta)
+	if err := ns.linkRecord(filePath, record); err != nil {
+		return err
+	}
 
 	// Append to file
-	if err := core.AppendRecord(filePath, record); err != nil {
+	if err := ns.encoder.Append(filePath, record); err != nil {
 		return fmt.Errorf("failed to append delete record: %w", err)
 	}
+	ns.afterWrite(changeDelete, key, filePath, version)
 
 	// Clear cache (no lock needed, cache is thread-safe)
 	ns.cache.Delete(key)
@@ -301,8 +704,8 @@ func (ns *namespace) Delete(key string) error {
 }
 
 // MustDelete is like Delete but panics on error.
-func (ns *namespace) MustDelete(key string) {
-	if err := ns.Delete(key); err != nil {
+func (ns *namespace) MustDelete(key string, opts ...DeleteOption) {
+	if err := ns.Delete(key, opts...); err != nil {
 		panic(err)
 	}
 }
@@ -335,10 +738,15 @@ func (ns *namespace) List() ([]string, error) {
 
 // getFilePath gets the file path for a key.
 func (ns *namespace) getFilePath(key string, create bool) (string, error) {
+	if ns.closed.Load() {
+		return "", ErrClosed
+	}
+
 	// Try to find existing file
 	exactFile := ns.keyMapper.FindExact(key)
 	if exactFile != "" {
-		return filepath.Join(ns.path, exactFile), nil
+		// Refuses names escaping the namespace and symlinked files
+		return fsutil.SafeJoin(ns.path, exactFile)
 	}
 
 	if !create {
@@ -346,11 +754,76 @@ func (ns *namespace) getFilePath(key string, create bool) (string, error) {
 	}
 
 	// Need to create new file
-	// Check if sanitized key would conflict
-	needsHash := index.NeedsHashSuffix(key) || ns.keyMapper.HasConflict(key)
-	fileName := index.GenerateFileName(key, needsHash)
+	var fileName string
+	if ns.cfg().HashedKeys {
+		fileName = index.HashedFileName(key)
+	} else {
+		// Check if sanitized key would conflict
+		needsHash := index.NeedsHashSuffix(key) || ns.keyMapper.HasConflict(key)
+		fileName = index.GenerateFileName(key, needsHash)
+	}
+
+	filePath, err := fsutil.SafeJoin(ns.path, fileName)
+	if err != nil {
+		return "", err
+	}
+
+	// The name may still be taken: on case-insensitive filesystems, by a
+	// short hash collision, or by a file the key index doesn't know yet
+	if owner := ns.fileOwner(filePath); owner != "" && owner != key {
+		return "", fmt.Errorf("%w: %q and %q share %s", ErrKeyCollision, key, owner, fileName)
+	}
+
+	return filePath, nil
+}
+
+// fileOwner returns the key of the first record of a key file, or "" when
+// the file doesn't exist or can't be read.
+func (ns *namespace) fileOwner(filePath string) string {
+	owner := ""
+	ns.decoder.StreamFile(filePath, func(record *core.Record) error {
+		owner = record.Meta.Key
+		return core.StopStream
+	})
+	return owner
+}
+
+// validKey reports whether key can be written to the namespace: any
+// non-empty UTF-8 string with HashedKeys, otherwise a key index.IsValidKey
+// accepts. Keys that aren't valid UTF-8 wouldn't survive the JSON meta.
+func (ns *namespace) validKey(key string) bool {
+	if ns.cfg().HashedKeys {
+		return key != "" && utf8.ValidString(key)
+	}
+	return index.IsValidKey(key)
+}
+
+// isKeyFilePath reports whether a file found walking the namespace
+// directory is a key file, not a blob, an index or one of the namespace's
+// own files.
+func isKeyFilePath(filePath string) bool {
+	if strings.Contains(filePath, "_blobs") || filepath.Base(filepath.Dir(filePath)) == indexDirName {
+		return false
+	}
+	return index.IsKeyFileName(filepath.Base(filePath))
+}
+
+// afterWrite brings everything kept alongside the key file filePath up to
+// date after a write of version to it: the watchers, the derived files (see
+// syncDerived) and the changes log. Every write changing a key's records
+// ends with it.
+func (ns *namespace) afterWrite(op, key, filePath string, version int) {
+	ns.noteWrite(filePath)
+	ns.syncDerived(filePath)
+	ns.recordChange(op, key, filePath, version)
+}
 
-	return filepath.Join(ns.path, fileName), nil
+// syncDerived updates the files derived from the key file filePath: its
+// pretty file, key manifest entry and index entries.
+func (ns *namespace) syncDerived(filePath string) {
+	ns.syncPrettyFile(filePath)
+	ns.syncKeyManifest(filePath)
+	ns.syncIndexes(filePath)
 }
 
 // getNextVersion gets the next version number for a key.
@@ -367,16 +840,16 @@ func (ns *namespace) compactIfNeeded(key, filePath string) {
 	// Check if compaction is needed based on strategy
 	needsCompact := false
 
-	switch ns.config.CompactStrategy {
+	switch ns.cfg().CompactStrategy {
 	case CompactStrategyLineCount:
 		lineCount, err := core.CountLines(filePath)
-		if err == nil && lineCount > ns.config.CompactThreshold {
+		if err == nil && lineCount > ns.cfg().CompactThreshold {
 			needsCompact = true
 		}
 
 	case CompactStrategyFileSize:
 		size := fsutil.FileSize(filePath)
-		if size > int64(ns.config.CompactThreshold) {
+		if size > int64(ns.cfg().CompactThreshold) {
 			needsCompact = true
 		}
 	}
@@ -388,36 +861,93 @@ func (ns *namespace) compactIfNeeded(key, filePath string) {
 
 // loadConfig loads configuration from _config.json.
 func (ns *namespace) loadConfig() error {
-	configPath := filepath.Join(ns.path, "_config.json")
+	config, err := readNamespaceConfig(ns.path)
+	if err != nil {
+		return err
+	}
+
+	ns.configMu.Lock()
+	// The signing key and normalizers are never persisted
+	config.Signing.Key = ns.config.Signing.Key
+	config.Normalizers = ns.config.Normalizers
+	ns.config = config
+	ns.configMu.Unlock()
+	return nil
+}
+
+// readNamespaceConfig reads the _config.json of the namespace at path.
+func readNamespaceConfig(path string) (NamespaceConfig, error) {
+	configPath := filepath.Join(path, "_config.json")
 
 	if !fsutil.FileExists(configPath) {
-		return fmt.Errorf("config file not found")
+		return NamespaceConfig{}, fmt.Errorf("config file not found")
 	}
 
 	data, err := os.ReadFile(configPath)
 	if err != nil {
-		return err
+		return NamespaceConfig{}, err
 	}
 
 	var config NamespaceConfig
 	if err := json.Unmarshal(data, &config); err != nil {
-		return err
+		return NamespaceConfig{}, err
 	}
+	return config, nil
+}
 
-	ns.config = config
-	return nil
+// resolveBlobDir returns the blob directory of the namespace at path for a
+// NamespaceConfig.BlobDir: _blobs/ when empty, otherwise a directory outside
+// the namespace directory, so blob files are never taken for key files.
+func resolveBlobDir(path, dir string) (string, error) {
+	if dir == "" {
+		return filepath.Join(path, "_blobs"), nil
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(path, dir)
+	}
+
+	rel, err := filepath.Rel(path, dir)
+	if err != nil || !outsideDir(rel) {
+		return "", fmt.Errorf("%w: blob directory %s is inside the namespace directory", ErrInvalidConfig, dir)
+	}
+	return filepath.Clean(dir), nil
+}
+
+// namespaceBlobDir returns the blob directory of a namespace that may not be
+// open, falling back to _blobs/ when its config can't be read.
+func namespaceBlobDir(path string) string {
+	config, _ := readNamespaceConfig(path)
+	dir, err := resolveBlobDir(path, config.BlobDir)
+	if err != nil {
+		return filepath.Join(path, "_blobs")
+	}
+	return dir
+}
+
+// outsideDir reports whether a relative path leads out of its base directory.
+func outsideDir(rel string) bool {
+	rel = filepath.Clean(rel)
+	return rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
 }
 
 // saveConfig saves configuration to _config.json.
 func (ns *namespace) saveConfig() error {
 	configPath := filepath.Join(ns.path, "_config.json")
 
-	data, err := json.MarshalIndent(ns.config, "", "  ")
+	data, err := json.MarshalIndent(ns.cfg(), "", "  ")
 	if err != nil {
 		return err
 	}
 
-	return fsutil.AtomicWriteFile(configPath, data, 0644)
+	return ns.writeFile(configPath, data)
+}
+
+// writeFile atomically replaces a namespace metadata file.
+func (ns *namespace) writeFile(path string, data []byte) error {
+	if ns.networkFS {
+		return fsutil.NetworkWriteFile(path, data, 0644)
+	}
+	return fsutil.AtomicWriteFile(path, data, 0644)
 }
 
 // rawItem implements RawItem interface.
@@ -432,6 +962,9 @@ func (r *rawItem) Meta() MetaInfo {
 		Version:   r.record.Meta.Version,
 		Operation: r.record.Meta.Operation,
 		Timestamp: r.record.Meta.Timestamp,
+		Seq:       r.record.Meta.Seq,
+		Reason:    r.record.Meta.Reason,
+		VisibleAt: r.record.Meta.VisibleAt,
 	}
 }
 
@@ -451,12 +984,16 @@ func (ns *namespace) WithLogger(logger Logger) Namespace {
 }
 
 func (ns *namespace) WithBlobThreshold(bytes int64) Namespace {
+	ns.configMu.Lock()
 	ns.config.BlobThreshold = bytes
+	ns.configMu.Unlock()
 	return ns
 }
 
 func (ns *namespace) WithMaxFileSize(bytes int64) Namespace {
+	ns.configMu.Lock()
 	ns.config.MaxFileSize = bytes
+	ns.configMu.Unlock()
 	return ns
 }
 
@@ -471,14 +1008,108 @@ func (ns *namespace) Path() string {
 }
 
 func (ns *namespace) GetConfig() NamespaceConfig {
+	return ns.cfg()
+}
+
+// cfg returns a snapshot of the config, safe against concurrent SetConfig
+// and replica reloads.
+func (ns *namespace) cfg() NamespaceConfig {
+	ns.configMu.RLock()
+	defer ns.configMu.RUnlock()
+
 	return ns.config
 }
 
 func (ns *namespace) SetConfig(config NamespaceConfig) error {
+	if err := ns.checkWritable(); err != nil {
+		return err
+	}
 	if err := config.Validate(); err != nil {
 		return err
 	}
 
+	ns.configMu.Lock()
+	if config.BlobDir != ns.config.BlobDir {
+		ns.configMu.Unlock()
+		return fmt.Errorf("%w: BlobDir can't change while the namespace is open", ErrInvalidConfig)
+	}
+	if config.HashedKeys != ns.config.HashedKeys {
+		ns.configMu.Unlock()
+		return fmt.Errorf("%w: HashedKeys can't change", ErrInvalidConfig)
+	}
+	layoutChanged := ns.config.Layout != config.Layout
+	manifestChanged := ns.config.KeyManifest != config.KeyManifest
+	if config.Signing.Key != nil {
+		// A new key brings its own public key
+		config.Signing.PublicKey = nil
+	} else if config.Signing.Algorithm == ns.config.Signing.Algorithm {
+		config.Signing.Key = ns.config.Signing.Key
+		config.Signing.PublicKey = ns.config.Signing.PublicKey
+	}
+	if config.Normalizers == nil {
+		config.Normalizers = ns.config.Normalizers
+	}
 	ns.config = config
-	return ns.saveConfig()
+	ns.configMu.Unlock()
+	ns.applyBlobConfig()
+	if err := ns.applySigning(); err != nil {
+		return err
+	}
+
+	if err := ns.saveConfig(); err != nil {
+		return err
+	}
+	if layoutChanged {
+		ns.syncPrettyFiles()
+	}
+	if manifestChanged {
+		ns.rebuildKeyManifest()
+	}
+	if config.OwnerLease == 0 {
+		ns.leases.drop(ns.path, true)
+	}
+	ns.recordChange(changeConfig, "", "", 0)
+	return nil
+}
+
+// checkWritable rejects writes on read-only replicas, and on namespaces
+// whose owner lease another writer holds.
+func (ns *namespace) checkWritable() error {
+	if ns.closed.Load() {
+		return ErrClosed
+	}
+	if ns.readOnly {
+		return ErrReadOnly
+	}
+	if ttl := ns.cfg().OwnerLease; ttl > 0 {
+		return ns.leases.claim(ns.path, ttl).check()
+	}
+	return nil
+}
+
+// close marks a handle the store evicted as closed, so reads and writes
+// through it fail with ErrClosed, and stops its watchers.
+func (ns *namespace) close() {
+	ns.closed.Store(true)
+	ns.closeWatchers()
+}
+
+// applyBlobConfig pushes blob-related config to the blob manager.
+func (ns *namespace) applyBlobConfig() {
+	if hasher, ok := blob.HasherByName(string(ns.cfg().BlobHash)); ok {
+		ns.blobManager.SetHasher(hasher)
+	}
+
+	if chain, err := blob.FiltersByName(ns.cfg().BlobFilters); err == nil {
+		ns.blobManager.SetFilters(chain)
+	} else {
+		ns.logger.Warn("ignoring blob filters", Field{"namespace", ns.name}, Field{"error", err})
+	}
+
+	concurrency := ns.cfg().BlobWriteConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	ns.blobManager.SetWriteConcurrency(concurrency)
+	ns.blobManager.SetLeaseTimeout(ns.cfg().BlobReadLease)
 }
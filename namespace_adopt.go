@@ -0,0 +1,137 @@
+package stow
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aigotowork/stow/internal/blob"
+	"github.com/aigotowork/stow/internal/core"
+	"github.com/aigotowork/stow/internal/fsutil"
+)
+
+// AdoptFile takes ownership of a JSONL history file written outside stow,
+// e.g. by a pipeline emitting the record format directly, and registers it
+// as the history of key. The file is moved into the namespace directory as
+// is, without rewriting its records, so path must be on the same volume.
+//
+// key must not exist yet (ErrKeyConflict otherwise). With opts.Validate,
+// every line must decode as a record of key, versions must ascend, and the
+// blobs the records reference must be in the blob directory; the first
+// problem is reported as ErrCorruptedData and the file is left in place.
+// Records are not signed or chained by AdoptFile: Verify and VerifyChain
+// report adopted files that don't carry valid signatures or links.
+//
+// Example:
+//
+//	err := ns.AdoptFile("order:42", "/import/order-42.jsonl", stow.AdoptOptions{Validate: true})
+func (ns *namespace) AdoptFile(key, path string, opts AdoptOptions) error {
+	if err := ns.checkWritable(); err != nil {
+		return err
+	}
+	if !ns.validKey(key) {
+		return fmt.Errorf("invalid key: %s", key)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", path)
+	}
+
+	if opts.Validate {
+		if err := ns.validateAdopted(key, path); err != nil {
+			return err
+		}
+	}
+
+	keyLock := ns.getKeyLock(key)
+	keyLock.Lock()
+	defer keyLock.Unlock()
+
+	ns.mu.RLock()
+	_, err = ns.getFilePath(key, false)
+	if err == nil {
+		ns.mu.RUnlock()
+		return fmt.Errorf("%w: key %q already exists", ErrKeyConflict, key)
+	}
+	filePath, err := ns.getFilePath(key, true)
+	ns.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	if err := ns.disk.check(info.Size()); err != nil {
+		return err
+	}
+
+	if err := fsutil.AtomicReplace(path, filePath); err != nil {
+		return fmt.Errorf("failed to adopt %s: %w", path, err)
+	}
+
+	ns.mu.Lock()
+	ns.keyMapper.Add(key, filepath.Base(filePath))
+	ns.mu.Unlock()
+
+	ns.cache.Delete(key)
+	ns.disk.add(info.Size())
+	ns.afterWrite(changePut, key, filePath, ns.getNextVersion(filePath)-1)
+
+	return nil
+}
+
+// validateAdopted checks that every line of the file at path is a record of
+// key, with ascending versions and blobs present in the blob directory.
+func (ns *namespace) validateAdopted(key, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, max(ns.decoder.MaxLineBytes, core.DefaultMaxLineBytes)+1)
+
+	records, prev := 0, 0
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		record, err := ns.decoder.Decode(scanner.Bytes())
+		if err != nil {
+			return fmt.Errorf("%w: %s line %d: %v", ErrCorruptedData, path, line, err)
+		}
+		if record.Meta.Key != key {
+			return fmt.Errorf("%w: %s line %d: record of key %q", ErrCorruptedData, path, line, record.Meta.Key)
+		}
+		if record.Meta.Version <= prev {
+			return fmt.Errorf("%w: %s line %d: version %d after %d", ErrCorruptedData, path, line, record.Meta.Version, prev)
+		}
+
+		err = walkBlobRefs(record.Data, func(ref *blob.Reference, _ bool) error {
+			if !ns.blobManager.Exists(ref) {
+				return fmt.Errorf("%w: %s line %d: missing blob %s", ErrCorruptedData, path, line, ref.Location)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		prev = record.Meta.Version
+		records++
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrCorruptedData, path, err)
+	}
+	if records == 0 {
+		return fmt.Errorf("%w: %s has no records", ErrCorruptedData, path)
+	}
+
+	return nil
+}
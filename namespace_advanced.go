@@ -7,10 +7,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/aigotowork/stow/internal/blob"
+	"github.com/aigotowork/stow/internal/codec"
 	"github.com/aigotowork/stow/internal/core"
 	"github.com/aigotowork/stow/internal/fsutil"
 )
@@ -26,21 +26,51 @@ func (ns *namespace) GetHistory(key string) ([]Version, error) {
 		return nil, err
 	}
 
-	// Read all records
-	records, err := ns.decoder.ReadAll(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read records: %w", err)
-	}
-
-	// Convert to Version list
+	// Stream the records, so only the versions are held in memory
 	var versions []Version
-	for _, record := range records {
+	addVersion := func(record *core.Record) error {
+		v := record.Meta.Version
 		versions = append(versions, Version{
-			Version:   record.Meta.Version,
+			Version:   v,
 			Timestamp: record.Meta.Timestamp,
+			Seq:       record.Meta.Seq,
 			Operation: record.Meta.Operation,
 			Size:      calculateRecordSize(record),
+			Reason:    record.Meta.Reason,
+			VisibleAt: record.Meta.VisibleAt,
+
+			RenamedFrom: record.Meta.RenamedFrom,
+			RenamedTo:   record.Meta.RenamedTo,
+			decode: func(target interface{}) error {
+				return ns.GetVersion(key, v, target)
+			},
 		})
+		return nil
+	}
+
+	// Versions compaction moved to the history archive come first
+	archived, err := ns.readArchivedHistory(filePath, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(archived) > 0 {
+		inFile := make(map[int]bool)
+		err = ns.decoder.StreamFile(filePath, func(record *core.Record) error {
+			inFile[record.Meta.Version] = true
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read records: %w", err)
+		}
+		for _, record := range archived {
+			if !inFile[record.Meta.Version] {
+				addVersion(record)
+			}
+		}
+	}
+
+	if err := ns.decoder.StreamFile(filePath, addVersion); err != nil {
+		return nil, fmt.Errorf("failed to read records: %w", err)
 	}
 
 	// Reverse to get newest first
@@ -62,22 +92,39 @@ func (ns *namespace) GetVersion(key string, version int, target interface{}) err
 		return err
 	}
 
-	// Read specific version
+	// Read specific version, falling back to the history archive
 	record, err := ns.decoder.ReadVersion(filePath, version)
 	if err != nil {
-		return fmt.Errorf("failed to read version: %w", err)
+		archived, archiveErr := ns.readArchivedVersion(filePath, version)
+		if archiveErr != nil || archived == nil {
+			return fmt.Errorf("failed to read version: %w", err)
+		}
+		record = archived
 	}
 
 	if record.Meta.IsDelete() {
 		return fmt.Errorf("version %d is a delete operation", version)
 	}
 
+	if err := ns.expandRecord(record); err != nil {
+		return err
+	}
+
 	// Unmarshal into target
-	return ns.unmarshaler.Unmarshal(record.Data, target)
+	return ns.unmarshaler.Unmarshal(withoutDerived(record.Data), target)
 }
 
 // Compact compresses specified keys.
 func (ns *namespace) Compact(keys ...string) error {
+	return ns.timer.run(opNameCompact, ns.name, "", func() error {
+		return ns.compact(keys...)
+	})
+}
+
+func (ns *namespace) compact(keys ...string) error {
+	if err := ns.checkWritable(); err != nil {
+		return err
+	}
 	if len(keys) == 0 {
 		return nil
 	}
@@ -92,6 +139,8 @@ func (ns *namespace) Compact(keys ...string) error {
 		}
 	}
 
+	ns.disk.rescan()
+
 	return nil
 }
 
@@ -99,21 +148,25 @@ func (ns *namespace) Compact(keys ...string) error {
 // This method returns immediately and does not block.
 // Use this for large-scale compaction operations that don't need to complete immediately.
 func (ns *namespace) CompactAsync(keys ...string) {
-	if len(keys) == 0 {
+	if len(keys) == 0 || ns.readOnly {
 		return
 	}
 
-	go func() {
+	ns.resources.start(func() {
 		for _, key := range keys {
 			ns.compactKeySafe(key)
 		}
-	}()
+	})
 }
 
 // CompactAllAsync asynchronously compacts all keys in the namespace.
 // This method returns immediately and does not block.
 func (ns *namespace) CompactAllAsync() {
-	go func() {
+	if ns.readOnly {
+		return
+	}
+
+	ns.resources.start(func() {
 		ns.mu.RLock()
 		allKeys := ns.keyMapper.ListAll()
 		ns.mu.RUnlock()
@@ -121,11 +174,15 @@ func (ns *namespace) CompactAllAsync() {
 		for _, key := range allKeys {
 			ns.compactKeySafe(key)
 		}
-	}()
+	})
 }
 
 // CompactAll compacts all keys in the namespace.
 func (ns *namespace) CompactAll() error {
+	if err := ns.checkWritable(); err != nil {
+		return err
+	}
+
 	ns.mu.Lock()
 	defer ns.mu.Unlock()
 
@@ -138,6 +195,8 @@ func (ns *namespace) CompactAll() error {
 		}
 	}
 
+	ns.disk.rescan()
+
 	return nil
 }
 
@@ -149,8 +208,12 @@ func (ns *namespace) compactKey(key string) error {
 		return err
 	}
 
-	// Read last N records
-	records, err := ns.decoder.ReadLastNRecords(filePath, ns.config.CompactKeepRecords)
+	var iostats IOStats
+	defer ns.io.add(&ns.io.compact, &iostats)
+
+	// Read last N records plus pinned versions
+	iostats.read(fsutil.FileSize(filePath))
+	records, dropped, err := ns.compactionRecords(key, filePath)
 	if err != nil {
 		return fmt.Errorf("failed to read records: %w", err)
 	}
@@ -159,6 +222,33 @@ func (ns *namespace) compactKey(key string) error {
 		return nil
 	}
 
+	if ns.cfg().ArchiveHistory && len(dropped) > 0 {
+		if err := ns.archiveHistory(filePath, dropped, &iostats); err != nil {
+			return err
+		}
+	}
+
+	if err := ns.rewriteKeyFile(filePath, records); err != nil {
+		return err
+	}
+	iostats.wrote(fsutil.FileSize(filePath))
+
+	// Clear cache for this key
+	ns.cache.Delete(key)
+
+	return nil
+}
+
+// rewriteKeyFile atomically replaces a key file with records (caller must
+// hold the key's lock).
+func (ns *namespace) rewriteKeyFile(filePath string, records []*core.Record) error {
+	// A crash before the swap leaves the temporary file for recovery
+	intent, err := ns.intents.begin(intentEntry{Op: intentCompact, File: filepath.Base(filePath)})
+	if err != nil {
+		return err
+	}
+	defer ns.intents.done(intent)
+
 	// Write to temporary file
 	tmpPath := filePath + ".tmp"
 
@@ -197,12 +287,10 @@ func (ns *namespace) compactKey(key string) error {
 	}
 
 	// Atomic rename
-	if err := fsutil.SafeRename(tmpPath, filePath); err != nil {
+	if err := fsutil.AtomicReplace(tmpPath, filePath); err != nil {
 		return fmt.Errorf("failed to rename temp file: %w", err)
 	}
-
-	// Clear cache for this key
-	ns.cache.Delete(key)
+	ns.noteWrite(filePath)
 
 	return nil
 }
@@ -223,8 +311,12 @@ func (ns *namespace) compactKeySafe(key string) {
 		return
 	}
 
-	// Read last N records
-	records, err := ns.decoder.ReadLastNRecords(filePath, ns.config.CompactKeepRecords)
+	var iostats IOStats
+	defer ns.io.add(&ns.io.compact, &iostats)
+
+	// Read last N records plus pinned versions
+	iostats.read(fsutil.FileSize(filePath))
+	records, dropped, err := ns.compactionRecords(key, filePath)
 	if err != nil {
 		ns.logger.Error("failed to read records for compact", Field{"key", key}, Field{"error", err})
 		return
@@ -234,6 +326,13 @@ func (ns *namespace) compactKeySafe(key string) {
 		return
 	}
 
+	if ns.cfg().ArchiveHistory && len(dropped) > 0 {
+		if err := ns.archiveHistory(filePath, dropped, &iostats); err != nil {
+			ns.logger.Error("failed to archive history for compact", Field{"key", key}, Field{"error", err})
+			return
+		}
+	}
+
 	// Write to temporary file
 	tmpPath := filePath + ".tmp"
 
@@ -277,23 +376,43 @@ func (ns *namespace) compactKeySafe(key string) {
 	}
 
 	// Atomic rename
-	if err := fsutil.SafeRename(tmpPath, filePath); err != nil {
+	if err := fsutil.AtomicReplace(tmpPath, filePath); err != nil {
 		ns.logger.Error("failed to rename temp file for compact", Field{"key", key}, Field{"error", err})
 		return
 	}
+	ns.noteWrite(filePath)
+	iostats.wrote(fsutil.FileSize(filePath))
 
 	// Clear cache for this key
 	ns.cache.Delete(key)
 
 	ns.logger.Info("key compacted successfully", Field{"key", key}, Field{"records_kept", len(records)})
+
+	ns.disk.rescan()
 }
 
 // GC performs garbage collection on blob files using streaming to minimize memory usage.
 func (ns *namespace) GC() (GCResult, error) {
+	var result GCResult
+	err := ns.timer.run(opNameGC, ns.name, "", func() error {
+		var err error
+		result, err = ns.gc()
+		return err
+	})
+	return result, err
+}
+
+func (ns *namespace) gc() (GCResult, error) {
+	if err := ns.checkWritable(); err != nil {
+		return GCResult{}, err
+	}
+
 	ns.mu.Lock()
 	defer ns.mu.Unlock()
 
 	startTime := time.Now()
+	var iostats IOStats
+	defer ns.io.add(&ns.io.gc, &iostats)
 
 	// Collect all blob references from JSONL files (streaming mode)
 	referencedBlobs := make(map[string]bool)
@@ -304,15 +423,31 @@ func (ns *namespace) GC() (GCResult, error) {
 	}
 
 	for _, filePath := range files {
-		// Skip files in _blobs directory
-		if strings.Contains(filePath, "_blobs") {
+		// Skip blobs, indexes and the namespace's own files
+		if !isKeyFilePath(filePath) {
 			continue
 		}
 
 		// Stream through the file line by line
+		iostats.read(fsutil.FileSize(filePath))
 		if err := ns.streamBlobRefs(filePath, referencedBlobs); err != nil {
 			continue // Skip files that can't be read
 		}
+
+		// Archived versions are still readable with GetVersion
+		archived, err := ns.readArchivedHistory(filePath, nil)
+		if err != nil {
+			return GCResult{}, fmt.Errorf("failed to read history archive of %s: %w", filepath.Base(filePath), err)
+		}
+		iostats.read(fsutil.FileSize(historyArchivePath(filePath)))
+		for _, record := range archived {
+			ns.collectRecordBlobRefs(record, referencedBlobs)
+		}
+	}
+
+	// Compaction keeps pinned versions, so GC keeps their blobs
+	if err := ns.pinnedBlobRefs(referencedBlobs); err != nil {
+		return GCResult{}, err
 	}
 
 	// Find all blob files
@@ -322,37 +457,66 @@ func (ns *namespace) GC() (GCResult, error) {
 	}
 
 	// Find unreferenced blobs
-	var removed int
-	var reclaimedSize int64
-
+	var unreferenced []string
 	for _, blobPath := range allBlobs {
 		blobName := filepath.Base(blobPath)
 		relativePath := filepath.Join("_blobs", blobName)
 
 		if !referencedBlobs[relativePath] {
-			// This blob is not referenced, delete it
-			size := fsutil.FileSize(blobPath)
-			if err := os.Remove(blobPath); err != nil {
-				ns.logger.Warn("failed to remove blob", Field{"path", blobPath}, Field{"error", err})
-				continue
-			}
+			unreferenced = append(unreferenced, blobPath)
+		}
+	}
 
-			removed++
-			reclaimedSize += size
+	// Log the deletes, so an interrupted GC is finished on the next open
+	var intent int64
+	if len(unreferenced) > 0 {
+		blobs := make([]intentBlob, len(unreferenced))
+		for i, blobPath := range unreferenced {
+			blobs[i] = intentBlob{Name: filepath.Base(blobPath), Size: fsutil.FileSize(blobPath)}
+		}
+		intent, err = ns.intents.begin(intentEntry{Op: intentGC, Blobs: blobs})
+		if err != nil {
+			return GCResult{}, err
 		}
 	}
+	defer ns.intents.done(intent)
+
+	var removed, deferred int
+	var reclaimedSize int64
+
+	for _, blobPath := range unreferenced {
+		size := fsutil.FileSize(blobPath)
+		wait, err := ns.blobManager.DeleteFile(blobPath)
+		if err != nil {
+			ns.logger.Warn("failed to remove blob", Field{"path", blobPath}, Field{"error", err})
+			continue
+		}
+		if wait {
+			// Removed once the streams reading it are closed
+			deferred++
+			continue
+		}
+
+		removed++
+		reclaimedSize += size
+	}
 
 	duration := time.Since(startTime)
 
+	ns.disk.rescan()
+
 	return GCResult{
 		RemovedBlobs:  removed,
+		DeferredBlobs: deferred,
 		ReclaimedSize: reclaimedSize,
 		Duration:      duration,
+		IO:            iostats,
 	}, nil
 }
 
 // streamBlobRefs streams through a JSONL file and extracts blob references without loading all data.
-// Only collects references from the MOST RECENT non-deleted record for each key.
+// Only collects references from the records readers can still get for each key: the MOST RECENT
+// record visible now, unless deleted, and every scheduled record newer than it.
 func (ns *namespace) streamBlobRefs(filePath string, refs map[string]bool) error {
 	f, err := os.Open(filePath)
 	if err != nil {
@@ -360,8 +524,12 @@ func (ns *namespace) streamBlobRefs(filePath string, refs map[string]bool) error
 	}
 	defer f.Close()
 
-	// Map to store the latest record for each key
+	now := time.Now()
+
+	// Map to store the latest visible record for each key, and its
+	// scheduled records
 	latestRecords := make(map[string]*core.Record)
+	pendingRecords := make(map[string][]*core.Record)
 
 	// Use bufio.Scanner for line-by-line JSONL reading
 	scanner := bufio.NewScanner(f)
@@ -371,39 +539,69 @@ func (ns *namespace) streamBlobRefs(filePath string, refs map[string]bool) error
 			continue // Skip empty lines
 		}
 
-		// Decode one record at a time
-		var record core.Record
-		if err := json.Unmarshal(line, &record); err != nil {
+		// Decode one record at a time (decrypting sealed data)
+		record, err := ns.decoder.Decode(line)
+		if err != nil {
 			// Skip invalid lines but continue
 			continue
 		}
 
-		// Store the latest record for this key
 		key := record.Meta.Key
+		if !record.Meta.IsVisible(now) {
+			pendingRecords[key] = append(pendingRecords[key], record)
+			continue
+		}
+
+		// Store the latest visible record for this key
 		if existing, ok := latestRecords[key]; !ok || record.Meta.Version > existing.Meta.Version {
-			latestRecords[key] = &record
+			latestRecords[key] = record
 		}
 	}
 
-	// Now collect blob refs only from the latest non-deleted records
+	// Now collect blob refs only from the latest non-deleted records and
+	// the scheduled records that will replace them
 	for _, record := range latestRecords {
 		if !record.Meta.IsDelete() {
-			collectBlobRefs(record.Data, refs)
+			ns.collectRecordBlobRefs(record, refs)
+		}
+	}
+	for key, pending := range pendingRecords {
+		for _, record := range pending {
+			if latest, ok := latestRecords[key]; !ok || record.Meta.Version > latest.Meta.Version {
+				ns.collectRecordBlobRefs(record, refs)
+			}
 		}
 	}
 
 	return scanner.Err()
 }
 
+// collectRecordBlobRefs adds the blob references of a record to refs,
+// including those of its spilled payload.
+func (ns *namespace) collectRecordBlobRefs(record *core.Record, refs map[string]bool) {
+	collectBlobRefs(record.Data, refs)
+
+	// Spilled payloads may hold further blob references
+	if _, spilled := codec.SpilledRecordRef(record.Data); spilled {
+		if expanded, err := ns.unmarshaler.ExpandRecord(record.Data); err == nil {
+			collectBlobRefs(expanded, refs)
+		}
+	}
+}
+
 // Refresh invalidates cache for specified keys.
 func (ns *namespace) Refresh(keys ...string) error {
 	ns.cache.DeleteMultiple(keys)
+	for _, key := range keys {
+		ns.bools.touch(key)
+	}
 	return nil
 }
 
 // RefreshAll invalidates cache for all keys.
 func (ns *namespace) RefreshAll() error {
 	ns.cache.Clear()
+	ns.bools.invalidate()
 	return nil
 }
 
@@ -436,6 +634,8 @@ func (ns *namespace) Stats() (NamespaceStats, error) {
 		stats.BlobSize = blobSize
 	}
 
+	stats.IO = ns.io.snapshot()
+
 	return stats, nil
 }
 
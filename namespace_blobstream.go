@@ -0,0 +1,150 @@
+package stow
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aigotowork/stow/internal/blob"
+	"github.com/aigotowork/stow/internal/codec"
+)
+
+// ArchiveFormat selects the container StreamBlobs writes.
+type ArchiveFormat string
+
+const (
+	// ArchiveZip writes a zip file with deflated entries
+	ArchiveZip ArchiveFormat = "zip"
+
+	// ArchiveTar writes an uncompressed tar stream
+	ArchiveTar ArchiveFormat = "tar"
+)
+
+// streamedBlob is one file of a StreamBlobs archive.
+type streamedBlob struct {
+	name string
+	ref  *blob.Reference
+}
+
+// StreamBlobs writes the blob files of the latest version of each key to w
+// as a zip or tar archive, one directory per key, named after the files'
+// original names (the name and name_field tags, or WithFileName). Spilled
+// JSON, vectors and derived artifacts are left out. Every key is resolved
+// before anything is written, so a missing key fails with ErrNotFound and
+// an empty w.
+//
+// Example:
+//
+//	w.Header().Set("Content-Type", "application/zip")
+//	err := ns.StreamBlobs([]string{"ticket:17", "ticket:18"}, w, stow.ArchiveZip)
+//	// ticket_17/screenshot.png, ticket_17/log.txt, ticket_18/invoice.pdf
+func (ns *namespace) StreamBlobs(keys []string, w io.Writer, format ArchiveFormat) error {
+	if format != ArchiveZip && format != ArchiveTar {
+		return fmt.Errorf("%w: unknown archive format %q", ErrInvalidConfig, format)
+	}
+
+	var files []streamedBlob
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		data, err := ns.latestData(key)
+		if err != nil {
+			return fmt.Errorf("key %s: %w", key, err)
+		}
+
+		var refs []*blob.Reference
+		walkBlobRefs(withoutDerived(data), func(ref *blob.Reference, spilled bool) error {
+			if !spilled && ref.Kind == "" && ref.MimeType != codec.VectorMimeType {
+				refs = append(refs, ref)
+			}
+			return nil
+		})
+		sort.Slice(refs, func(i, j int) bool { return streamName(refs[i]) < streamName(refs[j]) })
+
+		dir := streamDir(key)
+		for _, ref := range refs {
+			name := uniqueName(dir+"/"+streamName(ref), seen)
+			files = append(files, streamedBlob{name: name, ref: ref})
+		}
+	}
+
+	now := time.Now()
+	switch format {
+	case ArchiveTar:
+		tw := tar.NewWriter(w)
+		for _, file := range files {
+			header := &tar.Header{Name: file.name, Mode: 0644, Size: file.ref.Size, ModTime: now}
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			if err := ns.copyBlob(tw, file.ref); err != nil {
+				return fmt.Errorf("%s: %w", file.name, err)
+			}
+		}
+		return tw.Close()
+
+	default:
+		zw := zip.NewWriter(w)
+		for _, file := range files {
+			header := &zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: now}
+			entry, err := zw.CreateHeader(header)
+			if err != nil {
+				return err
+			}
+			if err := ns.copyBlob(entry, file.ref); err != nil {
+				return fmt.Errorf("%s: %w", file.name, err)
+			}
+		}
+		return zw.Close()
+	}
+}
+
+// copyBlob copies the content of a blob to w.
+func (ns *namespace) copyBlob(w io.Writer, ref *blob.Reference) error {
+	fileData, err := ns.blobManager.Load(ref)
+	if err != nil {
+		return err
+	}
+	defer fileData.Close()
+
+	_, err = io.Copy(w, fileData)
+	return err
+}
+
+// streamDir returns the archive directory of a key: the key with path
+// separators replaced, so keys like "user:1/avatar" stay one directory.
+func streamDir(key string) string {
+	dir := strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(key)
+	if dir == "." || dir == ".." {
+		dir = "_" + dir
+	}
+	return dir
+}
+
+// streamName returns the file name of a blob in an archive: its original
+// name, or the name of its blob file.
+func streamName(ref *blob.Reference) string {
+	name := path.Base(strings.ReplaceAll(ref.Name, "\\", "/"))
+	if ref.Name == "" || name == "." || name == ".." || name == "/" {
+		return blobFileName(ref)
+	}
+	return name
+}
+
+// uniqueName returns name, or name with a " (2)", " (3)"... suffix before
+// its extension when taken.
+func uniqueName(name string, taken map[string]bool) string {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	candidate := name
+	for i := 2; taken[candidate]; i++ {
+		candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+	taken[candidate] = true
+	return candidate
+}
@@ -0,0 +1,275 @@
+package stow
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aigotowork/stow/internal/codec"
+)
+
+// bitmap is a set of key ids.
+type bitmap []uint64
+
+func (b *bitmap) set(id int, on bool) {
+	word := id / 64
+	if !on {
+		if word < len(*b) {
+			(*b)[word] &^= 1 << (id % 64)
+		}
+		return
+	}
+	for len(*b) <= word {
+		*b = append(*b, 0)
+	}
+	(*b)[word] |= 1 << (id % 64)
+}
+
+// and keeps the ids also in other.
+func (b bitmap) and(other bitmap) bitmap {
+	for i := range b {
+		if i < len(other) {
+			b[i] &= other[i]
+		} else {
+			b[i] = 0
+		}
+	}
+	return b
+}
+
+// boolIndex maps the values of boolean fields (NamespaceConfig.BoolIndexes)
+// to bitmaps of the keys holding true and false. It is built in memory by
+// the first query and kept current by re-reading the keys written since the
+// previous one.
+type boolIndex struct {
+	// dirtyMu guards dirty and stale only, so writers never wait for a query
+	dirtyMu sync.Mutex
+	dirty   map[string]bool
+	stale   bool
+
+	mu      sync.Mutex
+	fields  []string // nil until built
+	ids     map[string]int
+	keys    []string // by id, "" for free ids
+	free    []int
+	values  map[string][2]bitmap // field -> keys holding false, true
+	pending map[string]bool      // keys with scheduled records
+}
+
+// touch marks a key written, to be re-read by the next query.
+func (b *boolIndex) touch(key string) {
+	b.dirtyMu.Lock()
+	defer b.dirtyMu.Unlock()
+
+	if b.dirty == nil {
+		b.dirty = make(map[string]bool)
+	}
+	b.dirty[key] = true
+}
+
+// invalidate makes the next query rebuild the index.
+func (b *boolIndex) invalidate() {
+	b.dirtyMu.Lock()
+	defer b.dirtyMu.Unlock()
+
+	b.stale = true
+	b.dirty = nil
+}
+
+// KeysWhere returns, in ascending order, the keys whose latest value holds
+// each of the given boolean fields with the given value, e.g.
+// {"published": true, "archived": false}. Every field must be listed in
+// NamespaceConfig.BoolIndexes; keys where a field is missing or not a bool
+// match neither true nor false.
+//
+// Example:
+//
+//	config.BoolIndexes = []string{"published", "archived"}
+//	...
+//	keys, err := ns.KeysWhere(map[string]bool{"published": true, "archived": false})
+func (ns *namespace) KeysWhere(conditions map[string]bool) ([]string, error) {
+	fields := ns.cfg().BoolIndexes
+	for field := range conditions {
+		if !slices.Contains(fields, field) {
+			return nil, fmt.Errorf("%w: %s", ErrNotIndexed, field)
+		}
+	}
+
+	b := &ns.bools
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := ns.refreshBools(fields); err != nil {
+		return nil, err
+	}
+
+	var match bitmap
+	first := true
+	for field, value := range conditions {
+		set := b.values[field][boolSlot(value)]
+		if first {
+			match = append(bitmap(nil), set...)
+			first = false
+			continue
+		}
+		match = match.and(set)
+	}
+	if first {
+		// No conditions: every indexed key
+		match = make(bitmap, (len(b.keys)+63)/64)
+		for id, key := range b.keys {
+			if key != "" {
+				match.set(id, true)
+			}
+		}
+	}
+
+	var keys []string
+	for word, w := range match {
+		for w != 0 {
+			id := word*64 + bits.TrailingZeros64(w)
+			keys = append(keys, b.keys[id])
+			w &= w - 1
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// refreshBools brings the index up to date (caller holds bools.mu).
+func (ns *namespace) refreshBools(fields []string) error {
+	b := &ns.bools
+
+	b.dirtyMu.Lock()
+	dirty, stale := b.dirty, b.stale
+	b.dirty, b.stale = nil, false
+	b.dirtyMu.Unlock()
+
+	if stale || b.fields == nil || !slices.Equal(b.fields, fields) {
+		b.fields = slices.Clone(fields)
+		b.ids = make(map[string]int)
+		b.keys = nil
+		b.free = nil
+		b.values = make(map[string][2]bitmap, len(fields))
+		b.pending = make(map[string]bool)
+
+		ns.mu.RLock()
+		dirty = make(map[string]bool)
+		for _, key := range ns.keyMapper.ListAll() {
+			dirty[key] = true
+		}
+		ns.mu.RUnlock()
+	}
+
+	// Scheduled records may have become visible since
+	if dirty == nil {
+		dirty = make(map[string]bool, len(b.pending))
+	}
+	for key := range b.pending {
+		dirty[key] = true
+	}
+
+	for key := range dirty {
+		if err := ns.indexBools(key); err != nil {
+			// Put the rest back for the next query
+			for key := range dirty {
+				b.touch(key)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// indexBools re-reads the latest visible value of a key into the index
+// (caller holds bools.mu).
+func (ns *namespace) indexBools(key string) error {
+	b := &ns.bools
+
+	data, pending, err := ns.visibleData(key)
+	if errors.Is(err, ErrNotFound) {
+		data = nil
+	} else if err != nil {
+		return fmt.Errorf("key %s: %w", key, err)
+	}
+
+	if pending {
+		b.pending[key] = true
+	} else {
+		delete(b.pending, key)
+	}
+
+	id, ok := b.ids[key]
+	if data == nil {
+		if ok {
+			for field, sets := range b.values {
+				sets[0].set(id, false)
+				sets[1].set(id, false)
+				b.values[field] = sets
+			}
+			delete(b.ids, key)
+			b.keys[id] = ""
+			b.free = append(b.free, id)
+		}
+		return nil
+	}
+
+	if !ok {
+		if n := len(b.free); n > 0 {
+			id = b.free[n-1]
+			b.free = b.free[:n-1]
+			b.keys[id] = key
+		} else {
+			id = len(b.keys)
+			b.keys = append(b.keys, key)
+		}
+		b.ids[key] = id
+	}
+
+	for _, field := range b.fields {
+		sets := b.values[field]
+		value, err := codec.GetPath(data, field)
+		flag, isBool := value.(bool)
+		sets[0].set(id, err == nil && isBool && !flag)
+		sets[1].set(id, err == nil && isBool && flag)
+		b.values[field] = sets
+	}
+
+	return nil
+}
+
+// visibleData returns the data of a key's latest visible record, and whether
+// a scheduled record follows it. Returns ErrNotFound for deleted keys.
+func (ns *namespace) visibleData(key string) (map[string]interface{}, bool, error) {
+	ns.mu.RLock()
+	filePath, err := ns.getFilePath(key, false)
+	ns.mu.RUnlock()
+	if err != nil {
+		return nil, false, err
+	}
+
+	record, pending, err := ns.decoder.ReadLastVisible(filePath, time.Now())
+	if err != nil {
+		return nil, false, err
+	}
+	if record == nil || record.Meta.IsDelete() {
+		return nil, pending, ErrNotFound
+	}
+
+	if err := ns.expandRecord(record); err != nil {
+		return nil, false, err
+	}
+	return record.Data, pending, nil
+}
+
+func boolSlot(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,104 @@
+package stow
+
+import (
+	"fmt"
+
+	"github.com/aigotowork/stow/internal/core"
+	"github.com/aigotowork/stow/internal/fsutil"
+)
+
+// linkRecord points a new record at the key's latest record in hash-chained
+// namespaces (caller must hold key lock). The first record of a key links
+// to nothing.
+func (ns *namespace) linkRecord(filePath string, record *core.Record) error {
+	if !ns.cfg().HashChain || !fsutil.FileExists(filePath) {
+		return nil
+	}
+
+	records, err := ns.decoder.ReadLastNRecords(filePath, 1)
+	if err != nil {
+		return fmt.Errorf("failed to read records: %w", err)
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	prev, err := records[0].Digest()
+	if err != nil {
+		return err
+	}
+	record.Meta.Prev = prev
+	return nil
+}
+
+// rechain links records[from:] to their predecessors again after stow changed
+// records before them, and resigns validly signed records whose link
+// changed. The first record is never relinked, as the record
+// it links to may have been compacted away.
+func (ns *namespace) rechain(records []*core.Record, from int) error {
+	if !ns.cfg().HashChain {
+		return nil
+	}
+
+	for i := max(from, 1); i < len(records); i++ {
+		prev, err := records[i-1].Digest()
+		if err != nil {
+			return err
+		}
+		if records[i].Meta.Prev != prev {
+			signed := ns.encoder.Signed(records[i])
+			records[i].Meta.Prev = prev
+			if signed {
+				records[i].Resign()
+			}
+		}
+	}
+	return nil
+}
+
+// VerifyChain checks that every record of key links to the record before it
+// and returns the digest of the latest record. Anchoring that digest outside
+// the store (e.g. in a commit message) also protects the latest record, which
+// nothing links to yet.
+//
+// The chain starts at the first record carrying a link: records written
+// before HashChain was enabled are not covered, and neither is the oldest
+// record, since compaction removes records from the start of the history.
+func (ns *namespace) VerifyChain(key string) (string, error) {
+	if !ns.cfg().HashChain {
+		return "", fmt.Errorf("%w: namespace %q is not hash-chained", ErrInvalidConfig, ns.name)
+	}
+
+	ns.mu.RLock()
+	filePath, err := ns.getFilePath(key, false)
+	ns.mu.RUnlock()
+	if err != nil {
+		return "", err
+	}
+
+	chained := false
+	prev := ""
+	err = ns.decoder.StreamFile(filePath, func(record *core.Record) error {
+		if record.Meta.Prev != "" {
+			chained = true
+		}
+		if chained && prev != "" && record.Meta.Prev != prev {
+			return fmt.Errorf("%w: key %q version %d", ErrChainBroken, key, record.Meta.Version)
+		}
+
+		digest, err := record.Digest()
+		if err != nil {
+			return err
+		}
+		prev = digest
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if prev == "" {
+		return "", ErrNotFound
+	}
+
+	return prev, nil
+}
@@ -0,0 +1,135 @@
+package stow
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/aigotowork/stow/internal/blob"
+	"github.com/aigotowork/stow/internal/core"
+	"github.com/aigotowork/stow/internal/fsutil"
+)
+
+// maxReplaceIntentBytes caps the two lines an in-place replace logs in its
+// intent; larger records are appended instead.
+const maxReplaceIntentBytes = 4 << 20
+
+// coalescePut replaces the latest record of key with data when that record
+// was first written less than window ago, instead of appending a new
+// version. The window is measured from the start of the burst, so writers
+// saving more often than window still get a version per window. It
+// reports whether the record was replaced; false means the caller should
+// append as usual. Blobs only the replaced record used are left for GC.
+// The IO is counted in iostats. Caller must hold the key lock.
+func (ns *namespace) coalescePut(key string, data map[string]interface{}, blobRefs []*blob.Reference, window time.Duration, iostats *IOStats) (bool, error) {
+	ns.mu.RLock()
+	filePath, err := ns.getFilePath(key, false)
+	ns.mu.RUnlock()
+	if err != nil || !fsutil.FileExists(filePath) {
+		// New key, nothing to coalesce with
+		return false, nil
+	}
+
+	// Only the latest record is read; one that doesn't decode is appended to
+	latest, replaced, offset, err := ns.decoder.ReadTail(filePath)
+	if err != nil {
+		return false, nil
+	}
+	iostats.read(int64(len(replaced)))
+
+	now := time.Now()
+	// A latest record from the future means the clock stepped back: its
+	// age is unknown, so it isn't replaced
+	age := now.Sub(latest.Meta.Timestamp)
+	if latest.Meta.IsDelete() || !latest.Meta.IsVisible(now) || age < 0 || age >= window {
+		return false, nil
+	}
+
+	// Pinned versions are kept as they are
+	ns.pinsMu.Lock()
+	pins, err := ns.loadPins()
+	ns.pinsMu.Unlock()
+	if err != nil {
+		return false, err
+	}
+	if slices.Contains(pins[key], latest.Meta.Version) {
+		return false, nil
+	}
+
+	// Keep the version number, chain link and timestamp of the record being
+	// replaced, so the window doesn't slide; the new data is signed anew.
+	// The clock is checked with the time of this write.
+	meta := *latest.Meta
+	meta.Timestamp = now.UTC()
+	ns.stamp(&meta)
+	meta.Timestamp = latest.Meta.Timestamp
+	record := core.NewRecord(&meta, data)
+	record.Resign()
+
+	// Records over the inline limit go through the regular write path
+	line, err := ns.encoder.Encode(record)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode record: %w", err)
+	}
+	if limit := ns.cfg().MaxInlineRecordSize; limit > 0 && int64(len(line)) > limit {
+		return false, nil
+	}
+
+	if ok, err := ns.replaceLatest(key, filePath, meta.Version, offset, replaced, line, blobRefs, iostats); !ok || err != nil {
+		return false, err
+	}
+	ns.afterWrite(changePut, key, filePath, meta.Version)
+
+	ns.cache.Set(key, data)
+	return true, nil
+}
+
+// replaceLatest writes line, an encoded record of key, over replaced, the
+// latest record of filePath at offset, checking the store's hard cap and
+// counting the size change like appendPut. The replace is logged as an
+// intent first, so a crash midway is finished or undone on the next open.
+// It reports false, writing nothing, for records too large to log. The IO
+// is counted in iostats. Caller must hold the key lock.
+func (ns *namespace) replaceLatest(key, filePath string, version int, offset int64, replaced, line []byte, blobRefs []*blob.Reference, iostats *IOStats) (bool, error) {
+	if len(replaced)+len(line) > maxReplaceIntentBytes {
+		return false, nil
+	}
+
+	// Enforce the store's hard cap
+	writeSize := int64(len(line)) - int64(len(replaced)) - 1
+	for _, ref := range blobRefs {
+		writeSize += ref.Size
+	}
+	if err := ns.disk.check(writeSize); err != nil {
+		return false, err
+	}
+
+	intent, err := ns.intents.begin(intentEntry{
+		Op:       intentReplace,
+		Key:      key,
+		File:     filepath.Base(filePath),
+		Version:  version,
+		Blobs:    ns.intentBlobs(blobRefs),
+		Offset:   offset,
+		Record:   line[:len(line)-1],
+		Replaced: replaced,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if err := fsutil.ReplaceTail(filePath, offset, line); err != nil {
+		// Put the replaced record back; if that fails too, the next open does
+		if fsutil.ReplaceTail(filePath, offset, append(replaced, '\n')) == nil {
+			ns.intents.done(intent)
+		}
+		return false, fmt.Errorf("failed to replace record: %w", err)
+	}
+	ns.intents.done(intent)
+
+	ns.disk.add(writeSize)
+	iostats.wrote(int64(len(line)))
+	iostats.LogicalBytes += int64(len(line))
+	return true, nil
+}
@@ -0,0 +1,264 @@
+package stow
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/aigotowork/stow/internal/core"
+	"github.com/aigotowork/stow/internal/fsutil"
+	"github.com/aigotowork/stow/internal/index"
+)
+
+// RepairCollisions splits key files holding the records of more than one
+// key, as left by writes on a case-insensitive filesystem or by files
+// merged by hand. The key of a file's first record keeps the file; every
+// other key moves to a file of its own with its records in file order.
+// Records of a key that already has its own file are merged into it by
+// timestamp, and renumbered if their versions clash.
+//
+// Writes to such keys fail with ErrKeyCollision until the file is split.
+// History archives are left as they are.
+func (ns *namespace) RepairCollisions() (RepairCollisionsResult, error) {
+	result := RepairCollisionsResult{}
+	if err := ns.checkWritable(); err != nil {
+		return result, err
+	}
+
+	files, err := fsutil.FindFiles(ns.path, "*.jsonl")
+	if err != nil {
+		return result, err
+	}
+
+	for _, filePath := range files {
+		// Skip blobs, indexes and the namespace's own files
+		if !isKeyFilePath(filePath) {
+			continue
+		}
+		if err := ns.splitSharedFile(filePath, &result); err != nil {
+			return result, err
+		}
+	}
+
+	ns.disk.rescan()
+
+	return result, nil
+}
+
+// splitSharedFile moves every key but the first out of filePath, under the
+// locks of all keys the file holds.
+func (ns *namespace) splitSharedFile(filePath string, result *RepairCollisionsResult) error {
+	var locked []string
+	unlock := func() {}
+	defer func() { unlock() }()
+
+	// Records by key, keys in order of appearance. A write may add a key
+	// before the locks are taken; the file is then read again under the
+	// locks of all its keys.
+	var byKey map[string][]*core.Record
+	var keys []string
+	for {
+		records, err := ns.decoder.ReadAll(filePath)
+		if err != nil || len(records) == 0 {
+			return nil
+		}
+
+		byKey = make(map[string][]*core.Record)
+		keys = keys[:0]
+		for _, record := range records {
+			key := record.Meta.Key
+			if _, ok := byKey[key]; !ok {
+				keys = append(keys, key)
+			}
+			byKey[key] = append(byKey[key], record)
+		}
+		if len(keys) == 1 {
+			return nil
+		}
+
+		missing := false
+		for _, key := range keys {
+			if !slices.Contains(locked, key) {
+				missing = true
+			}
+		}
+		if !missing {
+			break
+		}
+		unlock()
+		locked = slices.Clone(keys)
+		unlock = ns.lockKeys(locked)
+	}
+
+	// Moved keys are written before the shared file loses their records,
+	// so a crash in between duplicates records rather than losing them
+	for _, key := range keys[1:] {
+		movedPath, err := ns.moveCollidingKey(key, filePath, byKey[key])
+		if err != nil {
+			return fmt.Errorf("key %s: %w", key, err)
+		}
+		if result.Moved == nil {
+			result.Moved = make(map[string]string)
+		}
+		result.Moved[key] = filepath.Base(movedPath)
+	}
+
+	owner := keys[0]
+	kept := byKey[owner]
+	if err := ns.rechain(kept, 1); err != nil {
+		return fmt.Errorf("key %s: %w", owner, err)
+	}
+	if err := ns.rewriteKeyFile(filePath, kept); err != nil {
+		return fmt.Errorf("key %s: %w", owner, err)
+	}
+	ns.mu.Lock()
+	if ns.keyMapper.FindExact(owner) == "" {
+		ns.keyMapper.Add(owner, filepath.Base(filePath))
+	}
+	ns.mu.Unlock()
+	ns.cache.Delete(owner)
+	ns.afterWrite(changePut, owner, filePath, kept[len(kept)-1].Meta.Version)
+
+	result.Files++
+	return nil
+}
+
+// lockKeys takes the locks of keys in ascending key order, like Txn and
+// Rename, and returns a func releasing them.
+func (ns *namespace) lockKeys(keys []string) func() {
+	sorted := slices.Clone(keys)
+	sort.Strings(sorted)
+
+	locks := make([]*sync.Mutex, len(sorted))
+	for i, key := range sorted {
+		locks[i] = ns.getKeyLock(key)
+		locks[i].Lock()
+	}
+	return func() {
+		for _, lock := range locks {
+			lock.Unlock()
+		}
+	}
+}
+
+// moveCollidingKey writes the records of key found in sharedPath to the
+// key's own file and returns its path (caller must hold the key's lock).
+func (ns *namespace) moveCollidingKey(key, sharedPath string, records []*core.Record) (string, error) {
+	ns.mu.RLock()
+	targetPath := ns.ownFile(key, sharedPath)
+	ns.mu.RUnlock()
+	if targetPath != "" {
+		own, err := ns.decoder.ReadAll(targetPath)
+		if err != nil {
+			return "", err
+		}
+		records = ns.mergeRecords(own, records)
+	} else {
+		fileName, err := ns.splitFileName(key)
+		if err != nil {
+			return "", err
+		}
+		if targetPath, err = fsutil.SafeJoin(ns.path, fileName); err != nil {
+			return "", err
+		}
+	}
+
+	if err := ns.rechain(records, 1); err != nil {
+		return "", err
+	}
+	if err := ns.encoder.Rewrite(targetPath, records); err != nil {
+		return "", err
+	}
+
+	ns.mu.Lock()
+	ns.keyMapper.Add(key, filepath.Base(targetPath))
+	ns.mu.Unlock()
+	ns.cache.Delete(key)
+	ns.afterWrite(changePut, key, targetPath, records[len(records)-1].Meta.Version)
+
+	return targetPath, nil
+}
+
+// ownFile returns the path of the file the key index maps key to, unless
+// it is missing or is sharedPath under another name (as on case-insensitive
+// filesystems).
+func (ns *namespace) ownFile(key, sharedPath string) string {
+	exactFile := ns.keyMapper.FindExact(key)
+	if exactFile == "" {
+		return ""
+	}
+	filePath, err := fsutil.SafeJoin(ns.path, exactFile)
+	if err != nil {
+		return ""
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return ""
+	}
+	shared, err := os.Stat(sharedPath)
+	if err != nil || os.SameFile(info, shared) {
+		return ""
+	}
+	return filePath
+}
+
+// splitFileName returns a free file name for a key moved out of a shared
+// file: its sanitized name with the hash suffix, or else the name of its
+// digest.
+func (ns *namespace) splitFileName(key string) (string, error) {
+	candidates := []string{index.HashedFileName(key)}
+	if !ns.cfg().HashedKeys {
+		candidates = append([]string{index.GenerateFileName(key, true)}, candidates...)
+	}
+
+	for _, fileName := range candidates {
+		if _, err := os.Stat(filepath.Join(ns.path, fileName)); os.IsNotExist(err) {
+			return fileName, nil
+		}
+	}
+	return "", fmt.Errorf("%w: no free file name for %q", ErrKeyCollision, key)
+}
+
+// mergeRecords merges two histories of a key by timestamp, dropping
+// records present in both. Versions are renumbered from 1 when they
+// don't increase in the merged order; renumbered records are resigned if
+// they were validly signed.
+func (ns *namespace) mergeRecords(a, b []*core.Record) []*core.Record {
+	merged := make([]*core.Record, 0, len(a)+len(b))
+	seen := make(map[string]bool)
+	for _, record := range append(append([]*core.Record(nil), a...), b...) {
+		if digest, err := record.Digest(); err == nil {
+			if seen[digest] {
+				continue
+			}
+			seen[digest] = true
+		}
+		merged = append(merged, record)
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Meta.Timestamp.Before(merged[j].Meta.Timestamp)
+	})
+
+	for i := 1; i < len(merged); i++ {
+		if merged[i].Meta.Version > merged[i-1].Meta.Version {
+			continue
+		}
+		for v, record := range merged {
+			if record.Meta.Version != v+1 {
+				signed := ns.encoder.Signed(record)
+				record.Meta.Version = v + 1
+				if signed {
+					record.Resign()
+				}
+			}
+		}
+		break
+	}
+
+	return merged
+}
@@ -1,6 +1,13 @@
 package stow
 
-import "time"
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/aigotowork/stow/internal/blob"
+	"github.com/aigotowork/stow/internal/seal"
+	"github.com/aigotowork/stow/internal/sign"
+)
 
 // NamespaceConfig holds configuration for a namespace.
 type NamespaceConfig struct {
@@ -8,6 +15,12 @@ type NamespaceConfig struct {
 	// Default: 4KB
 	BlobThreshold int64 `json:"blob_threshold"`
 
+	// NestedBlobThreshold is the size threshold (in bytes) above which a top-level
+	// map or slice field is stored as a JSON blob and rehydrated on Get.
+	// 0 disables spilling of nested values.
+	// Default: 0 (disabled)
+	NestedBlobThreshold int64 `json:"nested_blob_threshold"`
+
 	// MaxFileSize is the maximum size (in bytes) for a single blob file.
 	// Default: 100MB
 	MaxFileSize int64 `json:"max_file_size"`
@@ -16,6 +29,46 @@ type NamespaceConfig struct {
 	// Default: 64KB
 	BlobChunkSize int64 `json:"blob_chunk_size"`
 
+	// BlobWriteConcurrency is the number of buffers in flight while storing a blob.
+	// Above 1, reading, hashing and writing overlap and buffers grow adaptively
+	// up to 1MB; 0 or 1 writes sequentially.
+	// Default: 4
+	BlobWriteConcurrency int `json:"blob_write_concurrency"`
+
+	// BlobReadLease is how long an open blob stream (IFileData) keeps GC
+	// from deleting its file without being read. GC defers deleting files
+	// being streamed until they are closed, so long downloads don't break;
+	// handles left open and idle past the lease no longer hold the file.
+	// Default: 10 minutes
+	BlobReadLease time.Duration `json:"blob_read_lease,omitempty"`
+
+	// BlobHash is the content hash algorithm for new blob files.
+	// Existing blobs keep the algorithm recorded in their reference.
+	// Default: BlobHashSHA256
+	BlobHash BlobHash `json:"blob_hash"`
+
+	// BlobFilters is the filter chain new blob files are written through,
+	// first filter first: BlobFilterGzip, BlobFilterCRC32 or filters added
+	// with RegisterBlobFilter. Existing blobs keep the chain recorded in
+	// their reference.
+	// Default: none
+	BlobFilters []string `json:"blob_filters,omitempty"`
+
+	// BlobDir is the directory blob files are stored in, for example on a
+	// larger, slower volume than the JSONL files. Relative paths are
+	// resolved against the namespace directory and must lead out of it. The
+	// directory must not be shared with other namespaces. Blob references
+	// don't record it, so blobs can be moved by moving the files and
+	// editing blob_dir in _config.json while the namespace is closed;
+	// SetConfig can't change it.
+	// Default: "" (_blobs/ in the namespace directory)
+	BlobDir string `json:"blob_dir,omitempty"`
+
+	// BlobSearchPaths are extra directories RelinkBlobs searches (recursively)
+	// for blob files missing from _blobs/. Found files are copied back.
+	// Default: none (only subdirectories of _blobs/ are searched)
+	BlobSearchPaths []string `json:"blob_search_paths,omitempty"`
+
 	// CacheTTL is the time-to-live for cached data.
 	// Default: 5 minutes
 	CacheTTL time.Duration `json:"cache_ttl"`
@@ -31,6 +84,13 @@ type NamespaceConfig struct {
 	// Default: false
 	DisableCache bool `json:"disable_cache"`
 
+	// Preload lists keys read into the cache when the namespace is opened,
+	// so the first requests for known-hot keys (e.g. configuration) don't
+	// wait on disk. Missing keys are skipped. Preloaded values expire after
+	// CacheTTL like any other.
+	// Default: none
+	Preload []string `json:"preload,omitempty"`
+
 	// CompactStrategy determines when to trigger compaction.
 	// Default: CompactStrategyLineCount
 	CompactStrategy CompactStrategy `json:"compact_strategy"`
@@ -45,6 +105,15 @@ type NamespaceConfig struct {
 	// Default: 3
 	CompactKeepRecords int `json:"compact_keep_records"`
 
+	// ArchiveHistory makes compaction move the records it drops into a
+	// gzip-compressed sidecar next to the key file (user_1.history.gz for
+	// user_1.jsonl) instead of discarding them. GetHistory and GetVersion
+	// read archived versions back, so the audit trail survives while the
+	// key file stays small. GC keeps the blobs archived versions
+	// reference; Prune doesn't look at archives.
+	// Default: false
+	ArchiveHistory bool `json:"archive_history"`
+
 	// AutoCompact enables automatic compaction after Put operations.
 	// Default: true
 	AutoCompact bool `json:"auto_compact"`
@@ -52,22 +121,183 @@ type NamespaceConfig struct {
 	// LockTimeout is the timeout for acquiring locks.
 	// Default: 30 seconds
 	LockTimeout time.Duration `json:"lock_timeout"`
+
+	// MaxInlineRecordSize caps the size (in bytes) of a single JSONL record line.
+	// 0 disables the limit.
+	// Default: 0 (unlimited)
+	MaxInlineRecordSize int64 `json:"max_inline_record_size"`
+
+	// OversizePolicy determines how records above MaxInlineRecordSize are handled.
+	// Default: OversizeReject
+	OversizePolicy OversizePolicy `json:"oversize_policy"`
+
+	// MaxLineBytes is the longest JSONL line (in bytes) read from key files.
+	// Reading a key with a longer line fails with ErrLimitExceeded instead of
+	// buffering it, so a hand-edited file can't exhaust memory.
+	// Read when the namespace is opened.
+	// Default: 0 (64MB)
+	MaxLineBytes int64 `json:"max_line_bytes"`
+
+	// MaxRecordDataBytes is the largest encoded data (in bytes, after
+	// decryption) of a record read from key files; reading a key with a
+	// larger record fails with ErrLimitExceeded before it is unmarshaled.
+	// Keep MaxInlineRecordSize below it, or new records can't be read back.
+	// Read when the namespace is opened.
+	// Default: 0 (unlimited)
+	MaxRecordDataBytes int64 `json:"max_record_data_bytes"`
+
+	// CoalesceWindow collapses bursts of Puts to a key: a Put less than
+	// CoalesceWindow after the key's latest record was first written
+	// replaces that record (keeping its version number and timestamp)
+	// instead of appending a new version, so chatty writers like autosave
+	// editors keep one version per window. Pinned and scheduled records are
+	// never replaced. 0 disables coalescing.
+	// Default: 0 (disabled)
+	CoalesceWindow time.Duration `json:"coalesce_window"`
+
+	// SkipUnchanged makes every Put behave as with WithSkipIfUnchanged: a
+	// value equal to the key's latest version appends nothing, so sync jobs
+	// rewriting unchanged records don't grow the history.
+	// Default: false
+	SkipUnchanged bool `json:"skip_unchanged"`
+
+	// BoolIndexes lists boolean fields (paths like "published" or
+	// "flags.archived") indexed in memory for Namespace.KeysWhere, so filters
+	// like "only published posts" don't read every key. The index is built
+	// by the first query and updated by the next ones with the keys written
+	// in between.
+	// Default: none
+	BoolIndexes []string `json:"bool_indexes,omitempty"`
+
+	// NonFiniteFloats determines how NaN and ±Inf floats anywhere in a value
+	// are written. Other floats are always written in Go's shortest
+	// round-trip form with a '.' decimal separator, whatever the locale.
+	// Default: FloatReject
+	NonFiniteFloats FloatPolicy `json:"non_finite_floats,omitempty"`
+
+	// OwnerLease makes the namespace single-writer: the first store to write
+	// records its owner ID (see WithStoreOwnerID) in _owner.json and renews
+	// it every third of OwnerLease. Writes from other stores fail with
+	// ErrNotOwner until the lease expires unrenewed or they call
+	// Namespace.TakeOwnership; the previous owner's writes then fail once it
+	// notices, within a third of OwnerLease. This guards against two
+	// deployments writing to one volume where the store lock isn't enforced.
+	// Default: 0 (disabled)
+	OwnerLease time.Duration `json:"owner_lease,omitempty"`
+
+	// HashChain stores the digest of each key's previous record in the _meta
+	// of every new record ("prev"), so editing or removing a record outside
+	// stow breaks the chain. See Namespace.VerifyChain.
+	// Default: false
+	HashChain bool `json:"hash_chain"`
+
+	// Layout determines the files kept per key. LayoutPrettyFiles writes the
+	// latest record of each key to key.json alongside key.jsonl on every
+	// write (scheduled records included), for stores versioned in git.
+	// Encrypted namespaces never write pretty files.
+	// Default: LayoutJSONL
+	Layout Layout `json:"layout"`
+
+	// KeyManifest maintains _keys.jsonl in the namespace directory: one line
+	// per key, sorted by key, with its records file, latest version and the
+	// blobs that version references, so scripts in other languages (or jq)
+	// can find a key's file without reimplementing key sanitization or
+	// reading files from the end. It is rebuilt when the namespace is opened
+	// and rewritten on every write (and external change reported by
+	// WatchExternalChanges), which costs a write of the whole manifest per
+	// Put in namespaces with many keys. Encrypted namespaces list no blobs.
+	// Default: false
+	KeyManifest bool `json:"key_manifest"`
+
+	// HashedKeys names key files after the SHA-256 digest of the key
+	// instead of its sanitized text, so keys may hold any characters
+	// (slashes, "..", NUL, anything valid UTF-8) at any length, and keys
+	// that sanitize alike never share a file. The key itself is stored in
+	// each record's meta as usual, but file names no longer show it: use
+	// KeyManifest to find a key's file from outside stow. Only honoured by
+	// CreateNamespace; it can't change afterwards.
+	// Default: false
+	HashedKeys bool `json:"hashed_keys,omitempty"`
+
+	// Signing signs every new record so edits made outside stow show up in
+	// Namespace.Verify. See SigningConfig.
+	// Default: disabled
+	Signing SigningConfig `json:"signing,omitzero"`
+
+	// Normalizers run in order over the fields of every value written
+	// (Put, PutAuto, UpdatePath...) before it is encoded, e.g. to trim
+	// strings or lowercase emails in one place instead of at every call
+	// site. Blob fields already hold their blob reference. A normalizer
+	// error fails the write. They are never persisted; reopening the
+	// namespace takes them from WithStoreNormalizers. SetConfig keeps the
+	// current ones when Normalizers is nil.
+	// Default: none
+	Normalizers []func(map[string]interface{}) error `json:"-"`
+
+	// key is the namespace encryption key set by WithKey. It is never persisted.
+	key []byte
+}
+
+// SigningConfig enables tamper-evident records. Each record written is
+// signed over its metadata and plain data, and the signature is stored in
+// its _meta ("sig"). Compaction keeps signatures; records whose data stow
+// itself changes (noversion updates, RelinkBlobs, LoadKey) are signed anew.
+type SigningConfig struct {
+	// Algorithm is SigningHMACSHA256 or SigningEd25519. Empty disables signing.
+	Algorithm SigningAlgorithm `json:"algorithm,omitempty"`
+
+	// Key signs records: the HMAC secret, or an Ed25519 seed (32 bytes) or
+	// private key (64 bytes). It is never persisted; reopening the namespace
+	// takes it from WithStoreSigningKey. Without it writes fail with
+	// ErrSigningKeyRequired, while Ed25519 records can still be verified.
+	Key []byte `json:"-"`
+
+	// PublicKey is the Ed25519 public key. It is derived from Key and
+	// persisted, so Verify works without the private key.
+	PublicKey []byte `json:"public_key,omitempty"`
+}
+
+// WithKey returns a copy of the config that encrypts the namespace with key
+// (32 bytes, AES-256). Only honoured by CreateNamespace; reopening an encrypted
+// namespace takes its key from WithStoreNamespaceKey.
+//
+// Example:
+//
+//	ns, err := store.CreateNamespace("tenant-42", stow.DefaultNamespaceConfig().WithKey(key))
+func (c NamespaceConfig) WithKey(key []byte) NamespaceConfig {
+	c.key = key
+	return c
+}
+
+// WithCoalesce returns a copy of the config that coalesces Puts to a key
+// arriving within window of the first of them (see CoalesceWindow).
+//
+// Example:
+//
+//	ns, err := store.CreateNamespace("drafts", stow.DefaultNamespaceConfig().WithCoalesce(2*time.Second))
+func (c NamespaceConfig) WithCoalesce(window time.Duration) NamespaceConfig {
+	c.CoalesceWindow = window
+	return c
 }
 
 // DefaultNamespaceConfig returns the default configuration for a namespace.
 func DefaultNamespaceConfig() NamespaceConfig {
 	return NamespaceConfig{
-		BlobThreshold:      4 * 1024,         // 4KB
-		MaxFileSize:        100 * 1024 * 1024, // 100MB
-		BlobChunkSize:      64 * 1024,        // 64KB
-		CacheTTL:           5 * time.Minute,
-		CacheTTLJitter:     0.2,
-		DisableCache:       false,
-		CompactStrategy:    CompactStrategyLineCount,
-		CompactThreshold:   20,
-		CompactKeepRecords: 3,
-		AutoCompact:        true,
-		LockTimeout:        30 * time.Second,
+		BlobThreshold:        4 * 1024,          // 4KB
+		MaxFileSize:          100 * 1024 * 1024, // 100MB
+		BlobChunkSize:        64 * 1024,         // 64KB
+		BlobHash:             BlobHashSHA256,
+		BlobWriteConcurrency: 4,
+		BlobReadLease:        blob.DefaultLeaseTimeout,
+		CacheTTL:             5 * time.Minute,
+		CacheTTLJitter:       0.2,
+		DisableCache:         false,
+		CompactStrategy:      CompactStrategyLineCount,
+		CompactThreshold:     20,
+		CompactKeepRecords:   3,
+		AutoCompact:          true,
+		LockTimeout:          30 * time.Second,
+		OversizePolicy:       OversizeReject,
 	}
 }
 
@@ -76,12 +306,30 @@ func (c *NamespaceConfig) Validate() error {
 	if c.BlobThreshold < 0 {
 		return ErrInvalidConfig
 	}
+	if c.NestedBlobThreshold < 0 {
+		return ErrInvalidConfig
+	}
 	if c.MaxFileSize <= 0 {
 		return ErrInvalidConfig
 	}
 	if c.BlobChunkSize <= 0 {
 		return ErrInvalidConfig
 	}
+	if c.BlobWriteConcurrency < 0 {
+		return ErrInvalidConfig
+	}
+	if c.BlobReadLease < 0 {
+		return ErrInvalidConfig
+	}
+	if _, ok := blob.HasherByName(string(c.BlobHash)); !ok {
+		return ErrInvalidConfig
+	}
+	if _, err := blob.FiltersByName(c.BlobFilters); err != nil {
+		return ErrInvalidConfig
+	}
+	if c.BlobDir != "" && !filepath.IsAbs(c.BlobDir) && !outsideDir(c.BlobDir) {
+		return ErrInvalidConfig
+	}
 	if c.CacheTTL < 0 {
 		return ErrInvalidConfig
 	}
@@ -97,5 +345,51 @@ func (c *NamespaceConfig) Validate() error {
 	if c.LockTimeout <= 0 {
 		return ErrInvalidConfig
 	}
+	if c.CoalesceWindow < 0 || c.OwnerLease < 0 {
+		return ErrInvalidConfig
+	}
+	if c.MaxInlineRecordSize < 0 {
+		return ErrInvalidConfig
+	}
+	if c.MaxLineBytes < 0 || c.MaxRecordDataBytes < 0 {
+		return ErrInvalidConfig
+	}
+	if c.key != nil && len(c.key) != seal.KeySize {
+		return ErrInvalidConfig
+	}
+	switch c.OversizePolicy {
+	case "", OversizeReject, OversizeAutoBlob, OversizeTruncate:
+	default:
+		return ErrInvalidConfig
+	}
+	for _, field := range c.BoolIndexes {
+		if field == "" {
+			return ErrInvalidConfig
+		}
+	}
+	for _, key := range c.Preload {
+		if key == "" {
+			return ErrInvalidConfig
+		}
+	}
+	switch c.NonFiniteFloats {
+	case "", FloatReject, FloatSentinel:
+	default:
+		return ErrInvalidConfig
+	}
+	switch c.Signing.Algorithm {
+	case "":
+	case SigningHMACSHA256, SigningEd25519:
+		if _, err := sign.NewKey(string(c.Signing.Algorithm), c.Signing.Key, c.Signing.PublicKey); err != nil {
+			return ErrInvalidConfig
+		}
+	default:
+		return ErrInvalidConfig
+	}
+	switch c.Layout {
+	case "", LayoutJSONL, LayoutPrettyFiles:
+	default:
+		return ErrInvalidConfig
+	}
 	return nil
 }
@@ -0,0 +1,113 @@
+package stow
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aigotowork/stow/internal/blob"
+)
+
+// GetDerived opens an artifact derived from a blob field of the latest record.
+// The caller must Close the returned handle.
+func (ns *namespace) GetDerived(key, field, name string) (IFileData, error) {
+	data, err := ns.latestData(key)
+	if err != nil {
+		return nil, err
+	}
+
+	derived, _ := data[derivedKey].(map[string]interface{})
+	artifacts, _ := derived[field].(map[string]interface{})
+	refMap, _ := artifacts[name].(map[string]interface{})
+
+	ref, ok := blob.FromMap(refMap)
+	if !ok {
+		return nil, fmt.Errorf("%w: no derived artifact %q for field %q", ErrNotFound, name, field)
+	}
+
+	return ns.blobManager.Load(ref)
+}
+
+// deriveBlobs runs the registered blob processors over the top-level blob fields
+// of data and records their artifacts under "$derived". The stored artifact
+// blobs are returned so the caller can clean them up if the write fails.
+func (ns *namespace) deriveBlobs(data map[string]interface{}) []*blob.Reference {
+	if ns.processors.empty() {
+		return nil
+	}
+
+	// Deterministic order keeps artifact storage reproducible
+	fields := make([]string, 0, len(data))
+	for field := range data {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	derived := make(map[string]interface{})
+	var refs []*blob.Reference
+
+	for _, field := range fields {
+		refMap, ok := data[field].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		ref, ok := blob.FromMap(refMap)
+		if !ok || ref.Kind != "" {
+			continue
+		}
+
+		artifacts := make(map[string]interface{})
+		for _, entry := range ns.processors.match(ref.MimeType) {
+			produced, err := ns.runProcessor(entry, field, ref)
+			if err != nil {
+				ns.logger.Warn("blob processor failed",
+					Field{"field", field}, Field{"pattern", entry.pattern}, Field{"error", err})
+				continue
+			}
+
+			for _, artifact := range produced {
+				artifactRef, err := ns.blobManager.Store(artifact.Data, field+"_"+artifact.Name, artifact.MimeType)
+				if err != nil {
+					ns.logger.Warn("failed to store derived blob",
+						Field{"field", field}, Field{"name", artifact.Name}, Field{"error", err})
+					continue
+				}
+				artifacts[artifact.Name] = artifactRef.ToMap()
+				refs = append(refs, artifactRef)
+			}
+		}
+
+		if len(artifacts) > 0 {
+			derived[field] = artifacts
+		}
+	}
+
+	if len(derived) > 0 {
+		data[derivedKey] = derived
+	}
+	return refs
+}
+
+// runProcessor streams a blob into a single processor.
+func (ns *namespace) runProcessor(entry processorEntry, field string, ref *blob.Reference) ([]DerivedBlob, error) {
+	content, err := ns.blobManager.Load(ref)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	return entry.fn(BlobInput{
+		Field:    field,
+		Name:     ref.Name,
+		MimeType: ref.MimeType,
+		Size:     ref.Size,
+		Content:  content,
+	})
+}
+
+// withoutDerived returns data without the "$derived" field, copying only if needed.
+func withoutDerived(data map[string]interface{}) map[string]interface{} {
+	if _, ok := data[derivedKey]; !ok {
+		return data
+	}
+	return withoutFields(data, []string{derivedKey})
+}
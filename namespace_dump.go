@@ -0,0 +1,622 @@
+package stow
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/aigotowork/stow/internal/blob"
+	"github.com/aigotowork/stow/internal/codec"
+	"github.com/aigotowork/stow/internal/core"
+)
+
+// dumpFormat is the version of the DumpKey stream layout.
+const dumpFormat = 1
+
+// Entries of a key dump, in stream order; blob files follow under their
+// original location (e.g. "_blobs/avatar_abc123.jpg").
+const (
+	dumpManifestName = "manifest.json"
+	dumpRecordsName  = "records.jsonl"
+)
+
+// dumpManifest describes a key dump.
+//
+// Example:
+//
+//	{"format":1,"namespace":"tickets","key":"ticket:42","records":3,"pins":[1],
+//	 "blobs":[{"loc":"_blobs/screenshot_abc123.png","name":"screenshot.png","mime":"image/png","size":20480}]}
+type dumpManifest struct {
+	Format    int        `json:"format"`
+	Namespace string     `json:"namespace"`
+	Key       string     `json:"key"`
+	Records   int        `json:"records"`
+	Pins      []int      `json:"pins,omitempty"`
+	Blobs     []dumpBlob `json:"blobs"`
+}
+
+// dumpBlob describes one blob file of a key dump.
+type dumpBlob struct {
+	Location string `json:"loc"`
+	Name     string `json:"name,omitempty"`
+	MimeType string `json:"mime,omitempty"`
+	Size     int64  `json:"size"`
+	Kind     string `json:"kind,omitempty"`
+
+	// JSON marks payloads (spilled records and subtrees) that may hold
+	// further blob references
+	JSON bool `json:"json,omitempty"`
+}
+
+// LoadOption configures LoadKey.
+type LoadOption func(*loadOptions)
+
+type loadOptions struct {
+	key     string
+	replace bool
+}
+
+// WithLoadAs loads the dump under a different key.
+func WithLoadAs(key string) LoadOption {
+	return func(o *loadOptions) {
+		o.key = key
+	}
+}
+
+// WithLoadReplace replaces the history of an existing key instead of failing
+// with ErrKeyConflict. Blobs only the old history used are left for GC.
+func WithLoadReplace() LoadOption {
+	return func(o *loadOptions) {
+		o.replace = true
+	}
+}
+
+// DumpKey writes the complete history of key, archived versions included,
+// its pins and every blob it references to w as a tar stream that LoadKey
+// reads back, in this or another store. Records and blobs are written
+// decrypted.
+func (ns *namespace) DumpKey(key string, w io.Writer) error {
+	return ns.dumpKey(key, w, false)
+}
+
+// dumpKey implements DumpKey; latestOnly dumps only the current value.
+func (ns *namespace) dumpKey(key string, w io.Writer, latestOnly bool) error {
+	records, pins, err := ns.dumpSnapshot(key)
+	if err != nil {
+		return err
+	}
+
+	if latestOnly {
+		current := latestVisible(records, time.Now())
+		if current == nil || current.Meta.IsDelete() {
+			return ErrNotFound
+		}
+		records, pins = []*core.Record{current}, nil
+	}
+
+	blobs, refs, err := ns.dumpBlobs(records)
+	if err != nil {
+		return err
+	}
+
+	manifest := dumpManifest{
+		Format:    dumpFormat,
+		Namespace: ns.name,
+		Key:       key,
+		Records:   len(records),
+		Pins:      pins,
+		Blobs:     blobs,
+	}
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Records are written unsealed so the dump loads into any namespace
+	plain := core.NewEncoder()
+	var recordData bytes.Buffer
+	for _, record := range records {
+		line, err := plain.Encode(record)
+		if err != nil {
+			return fmt.Errorf("failed to encode record: %w", err)
+		}
+		recordData.Write(line)
+	}
+
+	tw := tar.NewWriter(w)
+	if err := writeDumpEntry(tw, dumpManifestName, int64(len(manifestData)), bytes.NewReader(manifestData)); err != nil {
+		return err
+	}
+	if err := writeDumpEntry(tw, dumpRecordsName, int64(recordData.Len()), &recordData); err != nil {
+		return err
+	}
+
+	for i, b := range blobs {
+		fileData, err := ns.blobManager.Load(refs[i])
+		if err != nil {
+			return fmt.Errorf("failed to open blob %s: %w", b.Location, err)
+		}
+		err = writeDumpEntry(tw, b.Location, b.Size, fileData)
+		fileData.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return tw.Close()
+}
+
+// dumpSnapshot reads the records and pins of a key under its lock.
+func (ns *namespace) dumpSnapshot(key string) ([]*core.Record, []int, error) {
+	keyLock := ns.getKeyLock(key)
+	keyLock.Lock()
+	defer keyLock.Unlock()
+
+	ns.mu.RLock()
+	filePath, err := ns.getFilePath(key, false)
+	ns.mu.RUnlock()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	records, err := ns.decoder.ReadAll(filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read records: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil, ErrNotFound
+	}
+	if records, err = ns.withArchivedHistory(filePath, records); err != nil {
+		return nil, nil, err
+	}
+
+	pins, err := ns.Pins(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return records, pins, nil
+}
+
+// dumpBlobs lists every blob referenced by records, including references
+// inside spilled JSON payloads, sorted by location.
+func (ns *namespace) dumpBlobs(records []*core.Record) ([]dumpBlob, []*blob.Reference, error) {
+	found := make(map[string]dumpBlob)
+	refs := make(map[string]*blob.Reference)
+
+	var visit func(value interface{}) error
+	visit = func(value interface{}) error {
+		return walkBlobRefs(value, func(ref *blob.Reference, spilled bool) error {
+			if _, seen := found[ref.Location]; seen {
+				return nil
+			}
+
+			isJSON := spilled || ref.Kind == blob.KindJSON
+			found[ref.Location] = dumpBlob{
+				Location: ref.Location,
+				Name:     ref.Name,
+				MimeType: ref.MimeType,
+				Size:     ref.Size,
+				Kind:     ref.Kind,
+				JSON:     isJSON,
+			}
+			refs[ref.Location] = ref
+
+			if !isJSON {
+				return nil
+			}
+			data, err := ns.blobManager.LoadBytes(ref)
+			if err != nil {
+				return fmt.Errorf("failed to read blob %s: %w", ref.Location, err)
+			}
+			var nested interface{}
+			if err := json.Unmarshal(data, &nested); err != nil {
+				return fmt.Errorf("%w: blob %s: %v", ErrCorruptedData, ref.Location, err)
+			}
+			return visit(nested)
+		})
+	}
+
+	for _, record := range records {
+		if record.Meta.IsDelete() {
+			continue
+		}
+		if err := visit(record.Data); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	locations := make([]string, 0, len(found))
+	for loc := range found {
+		locations = append(locations, loc)
+	}
+	sort.Strings(locations)
+
+	blobs := make([]dumpBlob, len(locations))
+	ordered := make([]*blob.Reference, len(locations))
+	for i, loc := range locations {
+		blobs[i] = found[loc]
+		ordered[i] = refs[loc]
+	}
+
+	return blobs, ordered, nil
+}
+
+func writeDumpEntry(tw *tar.Writer, name string, size int64, r io.Reader) error {
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     size,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	if _, err := io.CopyN(tw, r, size); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+// LoadKey reads a stream written by DumpKey and restores the key's history,
+// pins and blobs into this namespace. It returns the key that was loaded.
+//
+// An existing key is only replaced with WithLoadReplace; otherwise LoadKey
+// fails with ErrKeyConflict. Blobs are stored (and encrypted) like new writes,
+// so their locations may differ from the source store.
+func (ns *namespace) LoadKey(r io.Reader, opts ...LoadOption) (string, error) {
+	return ns.loadKey(r, nil, opts...)
+}
+
+// loadKey implements LoadKey; authorize (if set) is called with the target
+// key before anything is stored.
+func (ns *namespace) loadKey(r io.Reader, authorize func(key string) error, opts ...LoadOption) (string, error) {
+	if err := ns.checkWritable(); err != nil {
+		return "", err
+	}
+
+	options := &loadOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	tr := tar.NewReader(r)
+
+	var manifest dumpManifest
+	if err := readDumpEntry(tr, dumpManifestName, func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(&manifest)
+	}); err != nil {
+		return "", err
+	}
+	if manifest.Format != dumpFormat {
+		return "", fmt.Errorf("%w: unsupported dump format %d", ErrCorruptedData, manifest.Format)
+	}
+
+	key := manifest.Key
+	if options.key != "" {
+		key = options.key
+	}
+	if !ns.validKey(key) {
+		return "", fmt.Errorf("invalid key: %s", key)
+	}
+	if authorize != nil {
+		if err := authorize(key); err != nil {
+			return "", err
+		}
+	}
+
+	var records []*core.Record
+	if err := readDumpEntry(tr, dumpRecordsName, func(r io.Reader) error {
+		var err error
+		records, err = readDumpRecords(r)
+		return err
+	}); err != nil {
+		return "", err
+	}
+	if len(records) != manifest.Records {
+		return "", fmt.Errorf("%w: dump has %d records, manifest lists %d", ErrCorruptedData, len(records), manifest.Records)
+	}
+
+	size := int64(0)
+	for _, b := range manifest.Blobs {
+		size += b.Size
+	}
+	if err := ns.disk.check(size); err != nil {
+		return "", err
+	}
+
+	keyLock := ns.getKeyLock(key)
+	keyLock.Lock()
+	defer keyLock.Unlock()
+
+	ns.mu.RLock()
+	_, err := ns.getFilePath(key, false)
+	ns.mu.RUnlock()
+	if err == nil && !options.replace {
+		return "", fmt.Errorf("%w: key %q already exists", ErrKeyConflict, key)
+	}
+
+	// Blobs stored by a load that fails later are left for GC: deduplicated
+	// content may be shared with existing keys
+	loader := &blobLoader{ns: ns, declared: make(map[string]dumpBlob)}
+	for _, b := range manifest.Blobs {
+		loader.declared[b.Location] = b
+	}
+
+	if err := loader.readAll(tr); err != nil {
+		return "", err
+	}
+
+	// Point records at the blobs as stored here
+	for _, record := range records {
+		record.Meta.Key = key
+		// Signed anew by this namespace, if it signs records
+		record.Resign()
+		if record.Data == nil {
+			continue
+		}
+		data, err := loader.rewrite(record.Data)
+		if err != nil {
+			return "", err
+		}
+		record.Data = data.(map[string]interface{})
+	}
+
+	// Renamed records get new digests to link to
+	if err := ns.rechain(records, 1); err != nil {
+		return "", err
+	}
+
+	if err := ns.writeLoaded(key, records, manifest.Pins); err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+// writeLoaded replaces the key's file with loaded records (caller must hold key lock).
+func (ns *namespace) writeLoaded(key string, records []*core.Record, pins []int) error {
+	ns.mu.RLock()
+	filePath, err := ns.getFilePath(key, true)
+	ns.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	if err := ns.encoder.Rewrite(filePath, records); err != nil {
+		return fmt.Errorf("failed to write records: %w", err)
+	}
+
+	ns.mu.Lock()
+	ns.keyMapper.Add(key, filepath.Base(filePath))
+	ns.mu.Unlock()
+
+	ns.cache.Delete(key)
+	ns.disk.rescan()
+	ns.afterWrite(changePut, key, filePath, records[len(records)-1].Meta.Version)
+
+	// Only pins of loaded versions carry over
+	versions := make(map[int]bool, len(records))
+	for _, record := range records {
+		versions[record.Meta.Version] = true
+	}
+
+	ns.pinsMu.Lock()
+	defer ns.pinsMu.Unlock()
+
+	allPins, err := ns.loadPins()
+	if err != nil {
+		return err
+	}
+	delete(allPins, key)
+	for _, v := range pins {
+		if versions[v] {
+			allPins[key] = append(allPins[key], v)
+		}
+	}
+	sort.Ints(allPins[key])
+
+	return ns.savePins(allPins)
+}
+
+// readDumpEntry reads the next tar entry, which must be name.
+func readDumpEntry(tr *tar.Reader, name string, read func(io.Reader) error) error {
+	header, err := tr.Next()
+	if err != nil {
+		return fmt.Errorf("%w: missing %s: %v", ErrCorruptedData, name, err)
+	}
+	if header.Name != name {
+		return fmt.Errorf("%w: expected %s, found %s", ErrCorruptedData, name, header.Name)
+	}
+	if err := read(tr); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrCorruptedData, name, err)
+	}
+	return nil
+}
+
+// readDumpRecords decodes the unsealed records of a dump.
+func readDumpRecords(r io.Reader) ([]*core.Record, error) {
+	decoder := core.NewDecoder()
+
+	var records []*core.Record
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		record, err := decoder.Decode(scanner.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("no records")
+	}
+
+	return records, nil
+}
+
+// blobLoader stores the blobs of a dump and maps their dump locations to
+// references in this namespace.
+type blobLoader struct {
+	ns       *namespace
+	declared map[string]dumpBlob
+
+	// payloads holds JSON blobs until their own references are rewritten
+	payloads map[string][]byte
+
+	// stored maps dump locations to blobs stored in this namespace
+	stored map[string]*blob.Reference
+}
+
+// readAll stores the raw blobs of the stream and keeps the JSON payloads.
+func (l *blobLoader) readAll(tr *tar.Reader) error {
+	l.payloads = make(map[string][]byte)
+	l.stored = make(map[string]*blob.Reference)
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrCorruptedData, err)
+		}
+
+		b, ok := l.declared[header.Name]
+		if !ok || header.Size != b.Size {
+			return fmt.Errorf("%w: unexpected blob %s", ErrCorruptedData, header.Name)
+		}
+
+		if b.JSON {
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return fmt.Errorf("failed to read blob %s: %w", b.Location, err)
+			}
+			l.payloads[b.Location] = data
+			continue
+		}
+
+		ref, err := l.ns.blobManager.Store(io.LimitReader(tr, b.Size), b.Name, b.MimeType)
+		if err != nil {
+			return fmt.Errorf("failed to store blob %s: %w", b.Location, err)
+		}
+		ref.Kind = b.Kind
+		l.stored[b.Location] = ref
+	}
+
+	for loc := range l.declared {
+		if _, ok := l.stored[loc]; !ok {
+			if _, ok := l.payloads[loc]; !ok {
+				return fmt.Errorf("%w: missing blob %s", ErrCorruptedData, loc)
+			}
+		}
+	}
+	return nil
+}
+
+// rewrite returns value with every blob reference pointing at the stored blob.
+// JSON payloads are stored on first use, after their own references are rewritten.
+func (l *blobLoader) rewrite(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if ref, ok := blob.FromMap(v); ok {
+			stored, err := l.resolve(ref.Location)
+			if err != nil {
+				return nil, err
+			}
+			return stored.ToMap(), nil
+		}
+		for k, item := range v {
+			rewritten, err := l.rewrite(item)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = rewritten
+		}
+		return v, nil
+	case []interface{}:
+		for i, item := range v {
+			rewritten, err := l.rewrite(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = rewritten
+		}
+		return v, nil
+	}
+	return value, nil
+}
+
+func (l *blobLoader) resolve(loc string) (*blob.Reference, error) {
+	if ref, ok := l.stored[loc]; ok {
+		return ref, nil
+	}
+
+	payload, ok := l.payloads[loc]
+	if !ok {
+		return nil, fmt.Errorf("%w: reference to blob %s missing from dump", ErrCorruptedData, loc)
+	}
+	// Drop it first so a self-referencing payload can't recurse forever
+	delete(l.payloads, loc)
+
+	var value interface{}
+	if err := json.Unmarshal(payload, &value); err != nil {
+		return nil, fmt.Errorf("%w: blob %s: %v", ErrCorruptedData, loc, err)
+	}
+	value, err := l.rewrite(value)
+	if err != nil {
+		return nil, err
+	}
+	payload, err = json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	b := l.declared[loc]
+	ref, err := l.ns.blobManager.Store(payload, b.Name, b.MimeType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store blob %s: %w", loc, err)
+	}
+	ref.Kind = b.Kind
+	l.stored[loc] = ref
+	return ref, nil
+}
+
+// walkBlobRefs calls fn for every blob reference in value. spilled is set for
+// the payload of a spilled-record stub.
+func walkBlobRefs(value interface{}, fn func(ref *blob.Reference, spilled bool) error) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if ref, ok := blob.FromMap(v); ok {
+			return fn(ref, false)
+		}
+		if ref, ok := codec.SpilledRecordRef(v); ok {
+			return fn(ref, true)
+		}
+		for _, item := range v {
+			if err := walkBlobRefs(item, fn); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := walkBlobRefs(item, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package stow
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/aigotowork/stow/internal/seal"
+)
+
+// encryptionInfo is persisted in _encryption.json. It identifies the key
+// without revealing it, so opening with a wrong key fails instead of
+// returning garbage.
+type encryptionInfo struct {
+	Algorithm string `json:"algorithm"`
+	KeyCheck  string `json:"key_check"`
+}
+
+// applyKey sets up encryption for the namespace.
+//
+// An encrypted namespace opens only with its key; without it (or with a
+// different one) ErrNamespaceUnavailable is returned. A key given for a new
+// namespace turns encryption on; existing plain namespaces can't be encrypted
+// in place.
+func (ns *namespace) applyKey(key []byte) error {
+	info, err := ns.loadEncryptionInfo()
+	if err != nil {
+		return err
+	}
+
+	if info == nil {
+		if key == nil {
+			return nil
+		}
+		if ns.keyMapper.Count() > 0 {
+			return fmt.Errorf("%w: namespace %q already holds unencrypted data", ErrInvalidConfig, ns.name)
+		}
+	} else if key == nil {
+		return fmt.Errorf("%w: namespace %q is encrypted and no key was provided", ErrNamespaceUnavailable, ns.name)
+	}
+
+	sealKey, err := seal.NewKey(key)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+
+	if info == nil {
+		info = &encryptionInfo{Algorithm: seal.Algorithm, KeyCheck: sealKey.Check()}
+		if err := ns.saveEncryptionInfo(info); err != nil {
+			return err
+		}
+	} else if info.KeyCheck != sealKey.Check() {
+		return fmt.Errorf("%w: wrong key for namespace %q", ErrNamespaceUnavailable, ns.name)
+	}
+
+	ns.encoder.SetKey(sealKey)
+	ns.decoder.SetKey(sealKey)
+	ns.blobManager.SetKey(sealKey)
+	ns.encrypted = true
+
+	return nil
+}
+
+// loadEncryptionInfo reads _encryption.json.
+// Returns nil if the namespace is not encrypted.
+func (ns *namespace) loadEncryptionInfo() (*encryptionInfo, error) {
+	infoPath := filepath.Join(ns.path, "_encryption.json")
+
+	data, err := os.ReadFile(infoPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption info: %w", err)
+	}
+
+	var info encryptionInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse encryption info: %w", err)
+	}
+	if info.Algorithm != seal.Algorithm {
+		return nil, fmt.Errorf("%w: unsupported encryption algorithm %q", ErrNamespaceUnavailable, info.Algorithm)
+	}
+
+	return &info, nil
+}
+
+// saveEncryptionInfo writes _encryption.json.
+func (ns *namespace) saveEncryptionInfo(info *encryptionInfo) error {
+	infoPath := filepath.Join(ns.path, "_encryption.json")
+
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ns.writeFile(infoPath, data)
+}
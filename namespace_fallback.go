@@ -0,0 +1,70 @@
+package stow
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aigotowork/stow/internal/core"
+	"github.com/aigotowork/stow/internal/fsutil"
+)
+
+// fallbackData is latestData for WithFallbackToHistory: records that can't
+// be decoded or expanded are skipped, newest first, until a readable version
+// is found. Skipping any returns a DegradedReadError along with the data.
+// The cache is neither read nor filled.
+func (ns *namespace) fallbackData(key string) (map[string]interface{}, *DegradedReadError, error) {
+	ns.mu.RLock()
+	filePath, err := ns.getFilePath(key, false)
+	ns.mu.RUnlock()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !fsutil.FileExists(filePath) {
+		return nil, nil, ErrNotFound
+	}
+
+	now := time.Now()
+	var found *core.Record
+	var cause error
+	skipped := 0
+
+	err = ns.decoder.ReadReverse(filePath, func(record *core.Record, err error) bool {
+		if err == nil {
+			switch {
+			case !record.Meta.IsVisible(now):
+				return true
+			case record.Meta.IsDelete():
+				return false
+			case !record.Meta.IsPut():
+				return true
+			}
+
+			if err = ns.expandRecord(record); err == nil {
+				found = record
+				return false
+			}
+		}
+
+		if cause == nil {
+			cause = err
+		}
+		skipped++
+		return true
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read record: %w", err)
+	}
+
+	if found == nil {
+		if cause != nil {
+			return nil, nil, fmt.Errorf("%w: no readable version of %s: %v", ErrCorruptedData, key, cause)
+		}
+		return nil, nil, ErrNotFound
+	}
+
+	if skipped == 0 {
+		return found.Data, nil, nil
+	}
+	return found.Data, &DegradedReadError{Key: key, Version: found.Meta.Version, Skipped: skipped, Err: cause}, nil
+}
@@ -0,0 +1,148 @@
+package stow
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/aigotowork/stow/internal/core"
+)
+
+// historyArchiveSuffix replaces ".jsonl" in the name of a key's history
+// archive, e.g. user_1.history.gz next to user_1.jsonl.
+const historyArchiveSuffix = ".history.gz"
+
+// historyArchivePath returns the history archive of the key file filePath.
+func historyArchivePath(filePath string) string {
+	return strings.TrimSuffix(filePath, ".jsonl") + historyArchiveSuffix
+}
+
+// archiveHistory appends records dropped by compaction to the key's history
+// archive, as one gzip member of JSONL lines. The archive is synced before
+// the key file is rewritten; a crash in between leaves the records in both,
+// which readers skip. The IO is counted in iostats. Caller must hold the
+// key lock.
+func (ns *namespace) archiveHistory(filePath string, records []*core.Record, iostats *IOStats) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	for _, record := range records {
+		line, err := ns.encoder.Encode(record)
+		if err != nil {
+			return fmt.Errorf("failed to encode record: %w", err)
+		}
+		if _, err := zw.Write(line); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	path := historyArchivePath(filePath)
+	if err := ns.disk.check(int64(buf.Len())); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open history archive: %w", err)
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write history archive: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to sync history archive: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	ns.disk.add(int64(buf.Len()))
+	iostats.wrote(int64(buf.Len()))
+	return nil
+}
+
+// readArchivedHistory returns the records in the history archive of the key
+// file filePath, oldest first, skipping versions listed in skip and repeated
+// ones. A missing archive has no records. A member cut short by a crash
+// mid-append ends the archive.
+func (ns *namespace) readArchivedHistory(filePath string, skip map[int]bool) ([]*core.Record, error) {
+	f, err := os.Open(historyArchivePath(filePath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history archive: %w", err)
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(bufio.NewReader(f))
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: history archive: %v", ErrCorruptedData, err)
+	}
+	defer zr.Close()
+
+	seen := make(map[int]bool)
+	var records []*core.Record
+
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(nil, max(ns.decoder.MaxLineBytes, core.DefaultMaxLineBytes)+1)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		record, err := ns.decoder.Decode(scanner.Bytes())
+		if err != nil {
+			// Skip invalid lines, like readers of key files
+			continue
+		}
+		if v := record.Meta.Version; !skip[v] && !seen[v] {
+			seen[v] = true
+			records = append(records, record)
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("%w: history archive: %v", ErrCorruptedData, err)
+	}
+
+	return records, nil
+}
+
+// withArchivedHistory returns the records of the key file filePath preceded
+// by the versions only its history archive still holds, for operations that
+// carry a key's complete history elsewhere.
+func (ns *namespace) withArchivedHistory(filePath string, records []*core.Record) ([]*core.Record, error) {
+	skip := make(map[int]bool, len(records))
+	for _, record := range records {
+		skip[record.Meta.Version] = true
+	}
+	archived, err := ns.readArchivedHistory(filePath, skip)
+	if err != nil {
+		return nil, err
+	}
+	return append(archived, records...), nil
+}
+
+// readArchivedVersion returns version of the key file filePath from its
+// history archive, or nil when it isn't there.
+func (ns *namespace) readArchivedVersion(filePath string, version int) (*core.Record, error) {
+	records, err := ns.readArchivedHistory(filePath, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if record.Meta.Version == version {
+			return record, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,565 @@
+package stow
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aigotowork/stow/internal/core"
+	"github.com/aigotowork/stow/internal/fsutil"
+	"github.com/aigotowork/stow/internal/index"
+)
+
+// indexDirName is the directory holding the field indexes of a namespace,
+// one JSONL file per field.
+const indexDirName = "_indexes"
+
+// indexPendingName lists, in indexDirName, the key files whose scheduled
+// records are yet to be indexed. It isn't a .jsonl file, so it is never
+// read as an index.
+const indexPendingName = "_pending.json"
+
+// indexEntry is one line of an index file, setting the value a key holds in
+// the field or removing the key. Later lines override earlier ones.
+//
+// Example:
+//
+//	{"key":"alice","file":"alice.jsonl","value":"alice@example.com"}
+//	{"key":"bob","file":"bob.jsonl","removed":true}
+type indexEntry struct {
+	Key     string      `json:"key"`
+	File    string      `json:"file"`
+	Value   interface{} `json:"value,omitempty"`
+	Removed bool        `json:"removed,omitempty"`
+}
+
+// fieldIndexes holds the indexes of fields tagged `stow:"index"`, loaded from
+// _indexes/ by the first write or lookup. Each write of a key re-reads its
+// latest visible record and appends the changes to the index files; keys
+// with scheduled records are re-read by lookups until they are visible.
+type fieldIndexes struct {
+	mu      sync.Mutex
+	fields  map[string]*fieldIndex // nil until loaded
+	files   map[string]string      // key file name -> key
+	pending map[string]bool        // key file names with scheduled records
+}
+
+// fieldIndex maps the values of one field to the keys holding them. Values
+// are compared in their JSON encoding.
+type fieldIndex struct {
+	path   string
+	values map[string]string          // key -> value
+	keys   map[string]map[string]bool // value -> keys
+	lines  int                        // lines in the file, for compaction
+}
+
+// KeysByIndex returns, in ascending order, the keys whose latest value holds
+// value in field, a top-level field tagged `stow:"index"`. Values compare as
+// stored in JSON, so numbers match whatever their Go type. Returns
+// ErrNotIndexed for fields without an index.
+//
+// Example:
+//
+//	type User struct {
+//		Email string `json:"email" stow:"index"`
+//	}
+//	...
+//	keys, err := ns.KeysByIndex("email", "alice@example.com")
+func (ns *namespace) KeysByIndex(field string, value interface{}) ([]string, error) {
+	want, err := indexValue(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidFilter, field, err)
+	}
+
+	x := &ns.indexes
+	x.mu.Lock()
+	if err := ns.loadIndexes(); err != nil {
+		x.mu.Unlock()
+		return nil, err
+	}
+	ns.indexVisible()
+	idx, ok := x.fields[field]
+	var candidates []string
+	if ok {
+		for key := range idx.keys[want] {
+			candidates = append(candidates, key)
+		}
+	}
+	x.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotIndexed, field)
+	}
+
+	// Writes that bypass the index (e.g. a crash before its line was
+	// written) leave stale entries, so every candidate is checked
+	keys := []string{}
+	for _, key := range candidates {
+		_, document, err := ns.latestDocument(key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", key, err)
+		}
+		if got, err := indexValue(document[field]); err == nil && got == want {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// ensureIndexes creates the indexes of fields that don't have one yet,
+// from the latest value of every key.
+func (ns *namespace) ensureIndexes(fields []string) error {
+	if ns.encrypted {
+		return nil
+	}
+
+	x := &ns.indexes
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if err := ns.loadIndexes(); err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, field := range fields {
+		if _, ok := x.fields[field]; ok {
+			continue
+		}
+		if !index.IsValidKey(field) || index.NeedsHashSuffix(field) {
+			return fmt.Errorf("%w: field %q can't name an index file", ErrInvalidTag, field)
+		}
+		missing = append(missing, field)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	ns.mu.RLock()
+	files := make(map[string]string)
+	for _, key := range ns.keyMapper.ListAll() {
+		if file := ns.keyMapper.FindExact(key); file != "" {
+			files[file] = key
+		}
+	}
+	ns.mu.RUnlock()
+
+	built := make(map[string]*fieldIndex, len(missing))
+	for _, field := range missing {
+		built[field] = newFieldIndex(filepath.Join(ns.path, indexDirName, field+".jsonl"))
+	}
+	pending := maps.Clone(x.pending)
+	for file, key := range files {
+		_, data, scheduled, err := ns.indexedData(filepath.Join(ns.path, file))
+		if err != nil {
+			return fmt.Errorf("key %s: %w", key, err)
+		}
+		x.files[file] = key
+		if scheduled {
+			pending[file] = true
+		}
+		for field, idx := range built {
+			if value, ok := indexable(data, field); ok {
+				idx.set(key, value)
+			}
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Join(ns.path, indexDirName), 0755); err != nil {
+		return err
+	}
+	for field, idx := range built {
+		if err := ns.saveIndex(idx); err != nil {
+			return err
+		}
+		x.fields[field] = idx
+	}
+	return ns.savePendingIndexes(pending)
+}
+
+// syncIndexes updates every index with the latest visible record in filePath,
+// removing its key when the record is a delete or the file is gone. Like
+// the key manifest, failures only leave the index stale (lookups check
+// every key they return), so they are logged.
+func (ns *namespace) syncIndexes(filePath string) {
+	if ns.encrypted || ns.readOnly {
+		return
+	}
+
+	x := &ns.indexes
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if err := ns.loadIndexes(); err != nil {
+		ns.logger.Warn("failed to load indexes", Field{"error", err})
+		return
+	}
+	if len(x.fields) == 0 {
+		return
+	}
+
+	ns.indexFile(filepath.Base(filePath))
+}
+
+// indexVisible re-indexes the keys whose scheduled records may have become
+// visible (caller must hold indexes.mu, with the indexes loaded). Read-only
+// handles leave indexes as they are.
+func (ns *namespace) indexVisible() {
+	if ns.encrypted || ns.readOnly {
+		return
+	}
+	for file := range ns.indexes.pending {
+		ns.indexFile(file)
+	}
+}
+
+// indexFile updates every index with the latest visible record of the key
+// file named file (caller must hold indexes.mu, with the indexes loaded).
+func (ns *namespace) indexFile(file string) {
+	x := &ns.indexes
+
+	key, data, scheduled, err := ns.indexedData(filepath.Join(ns.path, file))
+	if err != nil {
+		ns.logger.Warn("failed to read record for indexes", Field{"file", file}, Field{"error", err})
+		return
+	}
+	if scheduled != x.pending[file] {
+		pending := maps.Clone(x.pending)
+		if scheduled {
+			pending[file] = true
+		} else {
+			delete(pending, file)
+		}
+		if err := ns.savePendingIndexes(pending); err != nil {
+			ns.logger.Warn("failed to update index", Field{"file", file}, Field{"error", err})
+		}
+	}
+	if key == "" {
+		// The file is gone
+		if key = x.files[file]; key == "" {
+			return
+		}
+	}
+	x.files[file] = key
+
+	for field, idx := range x.fields {
+		value, ok := indexable(data, field)
+		var entry indexEntry
+		switch {
+		case ok && idx.values[key] != value:
+			idx.set(key, value)
+			entry = indexEntry{Key: key, File: file, Value: json.RawMessage(value)}
+		case !ok && idx.has(key):
+			idx.remove(key)
+			entry = indexEntry{Key: key, File: file, Removed: true}
+		default:
+			continue
+		}
+
+		if err := ns.appendIndex(idx, entry); err != nil {
+			ns.logger.Warn("failed to update index", Field{"field", field}, Field{"key", key}, Field{"error", err})
+		}
+	}
+}
+
+// indexedData returns the key and data of the latest visible record in
+// filePath, with nil data when the record is a delete, and no key when the
+// file holds no record. scheduled reports whether records scheduled after
+// it follow; their key is returned even when none is visible yet.
+func (ns *namespace) indexedData(filePath string) (key string, data map[string]interface{}, scheduled bool, err error) {
+	now := time.Now()
+	var record *core.Record
+	err = ns.decoder.ReadReverse(filePath, func(r *core.Record, _ error) bool {
+		if r == nil {
+			return true
+		}
+		if !r.Meta.IsVisible(now) {
+			scheduled = true
+			key = r.Meta.Key
+			return true
+		}
+		record = r
+		return false
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil, false, nil
+	}
+	if err != nil {
+		return "", nil, false, err
+	}
+	if record == nil {
+		return key, nil, scheduled, nil
+	}
+	if record.Meta.IsDelete() {
+		return record.Meta.Key, nil, scheduled, nil
+	}
+
+	if err := ns.expandRecord(record); err != nil {
+		return "", nil, false, err
+	}
+	return record.Meta.Key, record.Data, scheduled, nil
+}
+
+// indexable returns the JSON encoding of a scalar field of data.
+func indexable(data map[string]interface{}, field string) (string, bool) {
+	value, ok := data[field]
+	if !ok || value == nil {
+		return "", false
+	}
+	encoded, err := indexValue(value)
+	return encoded, err == nil
+}
+
+// indexValue returns the JSON encoding of a string, number or bool, the
+// form index files hold.
+func indexValue(value interface{}) (string, error) {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+	default:
+		return "", fmt.Errorf("%T is not a string, number or bool", value)
+	}
+
+	// Numbers of any type encode as the float64 JSON decodes them to
+	normalized, err := jsonForm(v.Interface())
+	if err != nil {
+		return "", err
+	}
+	encoded, err := json.Marshal(normalized)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// loadIndexes reads every index file in _indexes/ (caller must hold
+// indexes.mu). Files are loaded once; later changes go through the
+// in-memory indexes.
+func (ns *namespace) loadIndexes() error {
+	x := &ns.indexes
+	if x.fields != nil {
+		return nil
+	}
+
+	fields := make(map[string]*fieldIndex)
+	files := make(map[string]string)
+
+	dir := filepath.Join(ns.path, indexDirName)
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !strings.HasSuffix(name, ".jsonl") {
+			continue
+		}
+		idx, err := readIndex(filepath.Join(dir, name), files)
+		if err != nil {
+			return fmt.Errorf("%w: index %s: %v", ErrCorruptedData, name, err)
+		}
+		fields[strings.TrimSuffix(name, ".jsonl")] = idx
+	}
+
+	pending := make(map[string]bool)
+	data, err := os.ReadFile(filepath.Join(dir, indexPendingName))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		var names []string
+		if err := json.Unmarshal(data, &names); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrCorruptedData, indexPendingName, err)
+		}
+		for _, name := range names {
+			pending[name] = true
+		}
+	}
+
+	x.fields = fields
+	x.files = files
+	x.pending = pending
+	return nil
+}
+
+// savePendingIndexes replaces the list of key files with scheduled records
+// (caller must hold indexes.mu).
+func (ns *namespace) savePendingIndexes(pending map[string]bool) error {
+	x := &ns.indexes
+	if maps.Equal(pending, x.pending) {
+		return nil
+	}
+
+	path := filepath.Join(ns.path, indexDirName, indexPendingName)
+	if len(pending) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		x.pending = pending
+		return nil
+	}
+
+	names := slices.Sorted(maps.Keys(pending))
+	data, err := json.Marshal(names)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := fsutil.AtomicWriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	x.pending = pending
+	return nil
+}
+
+// readIndex replays an index file, noting the key of every file it names.
+func readIndex(path string, files map[string]string) (*fieldIndex, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	idx := newFieldIndex(path)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var entry indexEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			// A torn last line from a crash is dropped
+			continue
+		}
+		idx.lines++
+		if entry.File != "" {
+			files[entry.File] = entry.Key
+		}
+		if entry.Removed {
+			idx.remove(entry.Key)
+			continue
+		}
+		if value, err := indexValue(entry.Value); err == nil {
+			idx.set(entry.Key, value)
+		}
+	}
+	return idx, scanner.Err()
+}
+
+// appendIndex appends an entry to an index file, compacting the file once
+// most of its lines are overridden (caller must hold indexes.mu).
+func (ns *namespace) appendIndex(idx *fieldIndex, entry indexEntry) error {
+	if idx.lines > 2*len(idx.values)+1000 {
+		return ns.saveIndex(idx)
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(idx.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	idx.lines++
+	return f.Close()
+}
+
+// saveIndex rewrites an index file with one line per key, sorted by key
+// (caller must hold indexes.mu).
+func (ns *namespace) saveIndex(idx *fieldIndex) error {
+	x := &ns.indexes
+
+	files := make(map[string]string, len(idx.values))
+	for file, key := range x.files {
+		files[key] = file
+	}
+	keys := make([]string, 0, len(idx.values))
+	for key := range idx.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, key := range keys {
+		line, err := json.Marshal(indexEntry{Key: key, File: files[key], Value: json.RawMessage(idx.values[key])})
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	if err := ns.writeFile(idx.path, buf.Bytes()); err != nil {
+		return err
+	}
+	idx.lines = len(keys)
+	return nil
+}
+
+func newFieldIndex(path string) *fieldIndex {
+	return &fieldIndex{
+		path:   path,
+		values: make(map[string]string),
+		keys:   make(map[string]map[string]bool),
+	}
+}
+
+func (idx *fieldIndex) has(key string) bool {
+	_, ok := idx.values[key]
+	return ok
+}
+
+func (idx *fieldIndex) set(key, value string) {
+	idx.remove(key)
+	idx.values[key] = value
+	if idx.keys[value] == nil {
+		idx.keys[value] = make(map[string]bool)
+	}
+	idx.keys[value][key] = true
+}
+
+func (idx *fieldIndex) remove(key string) {
+	value, ok := idx.values[key]
+	if !ok {
+		return
+	}
+	delete(idx.values, key)
+	delete(idx.keys[value], key)
+	if len(idx.keys[value]) == 0 {
+		delete(idx.keys, value)
+	}
+}
@@ -0,0 +1,443 @@
+package stow
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/aigotowork/stow/internal/blob"
+	"github.com/aigotowork/stow/internal/core"
+	"github.com/aigotowork/stow/internal/fsutil"
+)
+
+// intentLogName is the write-ahead intent log of a namespace. It isn't a
+// .jsonl file, so key scans never pick it up.
+const intentLogName = "_intents.log"
+
+// Intent operations
+const (
+	intentPut     = "put"     // record append referencing new blobs
+	intentCompact = "compact" // key file swap
+	intentGC      = "gc"      // blob deletes
+	intentTxn     = "txn"     // record appends to several key files
+	intentReplace = "replace" // latest record replaced in place
+	intentRename  = "rename"  // key file written for a new key, old key closed
+)
+
+// intentEntry is one line of _intents.log: an operation about to touch
+// several files, or the completion of one.
+//
+// Example:
+//
+//	{"id":7,"op":"put","key":"user:1","file":"user_1.jsonl","version":3,"blobs":[{"name":"avatar_3f9a.jpg","size":102400}]}
+//	{"id":7,"done":true}
+//	{"id":8,"op":"txn","writes":[{"key":"post:42","file":"post_42.jsonl","version":1},{"key":"category:go","file":"category_go.jsonl","version":7}]}
+//	{"id":10,"op":"rename","key":"draft:1","file":"draft_1.jsonl","writes":[{"key":"doc:1","file":"doc_1.jsonl","version":5}]}
+//	{"id":9,"op":"replace","key":"doc:1","file":"doc_1.jsonl","version":4,"offset":512,"record":{...},"replaced":{...}}
+type intentEntry struct {
+	ID      int64         `json:"id"`
+	Op      string        `json:"op,omitempty"`
+	Key     string        `json:"key,omitempty"`
+	File    string        `json:"file,omitempty"`
+	Version int           `json:"version,omitempty"`
+	Count   int           `json:"count,omitempty"` // versions from Version, if more than one
+	Blobs   []intentBlob  `json:"blobs,omitempty"`
+	Writes  []intentWrite `json:"writes,omitempty"` // records of a transaction, or a rename's new key
+	Done    bool          `json:"done,omitempty"`
+
+	// A record replaced in place: the line at Offset, the file's last,
+	// is Replaced before and Record after
+	Offset   int64           `json:"offset,omitempty"`
+	Record   json.RawMessage `json:"record,omitempty"`
+	Replaced json.RawMessage `json:"replaced,omitempty"`
+}
+
+// intentWrite is a record a transaction appends to a key file.
+type intentWrite struct {
+	Key     string `json:"key"`
+	File    string `json:"file"`
+	Version int    `json:"version"`
+}
+
+// intentBlob is a blob file an intent touches, with its size on disk.
+type intentBlob struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// intentLog appends intents before multi-file operations and marks them
+// done after, so a crash in between is repaired on the next open (see
+// recoverIntents). The file is removed whenever no operation is in flight,
+// so it stays a few lines long. All methods are nil-safe; replicas have no
+// log.
+type intentLog struct {
+	path string
+
+	mu     sync.Mutex
+	nextID int64
+	open   int // intents begun but not done
+}
+
+func newIntentLog(dir string) *intentLog {
+	return &intentLog{path: filepath.Join(dir, intentLogName)}
+}
+
+// begin durably records entry and returns its ID for done.
+func (l *intentLog) begin(entry intentEntry) (int64, error) {
+	if l == nil {
+		return 0, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.nextID++
+	entry.ID = l.nextID
+	if err := l.append(entry, true); err != nil {
+		return 0, fmt.Errorf("failed to log intent: %w", err)
+	}
+	l.open++
+	return entry.ID, nil
+}
+
+// done marks an intent complete. Losing the mark only makes the next open
+// check an operation that finished.
+func (l *intentLog) done(id int64) {
+	if l == nil || id == 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.open--
+	if l.open == 0 {
+		os.Remove(l.path)
+		return
+	}
+	l.append(intentEntry{ID: id, Done: true}, false)
+}
+
+// append writes one line, synced with sync (caller holds mu).
+func (l *intentLog) append(entry intentEntry, sync bool) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if sync {
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// pending returns the intents in the log that were never marked done.
+// A torn last line is an intent whose operation never started.
+func (l *intentLog) pending() ([]intentEntry, error) {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []intentEntry
+	done := make(map[int64]bool)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		var entry intentEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		l.nextID = max(l.nextID, entry.ID)
+		if entry.Done {
+			done[entry.ID] = true
+		} else {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var open []intentEntry
+	for _, entry := range entries {
+		if !done[entry.ID] {
+			open = append(open, entry)
+		}
+	}
+	return open, nil
+}
+
+// intentBlobs describes the blob files of refs as they are on disk.
+func (ns *namespace) intentBlobs(refs []*blob.Reference) []intentBlob {
+	blobs := make([]intentBlob, 0, len(refs))
+	for _, ref := range refs {
+		name := blobFileName(ref)
+		blobs = append(blobs, intentBlob{Name: name, Size: fsutil.FileSize(filepath.Join(ns.blobDir, name))})
+	}
+	return blobs
+}
+
+// recoverIntents repairs the operations a crashed writer left unfinished:
+//
+//   - put: a record whose blobs are missing or truncated is removed again,
+//     then the intent's blobs are deleted unless a record references them
+//   - compact: a leftover temporary key file is removed (the swap itself
+//     is atomic)
+//   - gc: blobs still unreferenced by any version are deleted; blobs only
+//     history references are left to the next GC
+//   - txn: unless every key file holds its record, the records written are
+//     removed, then the intent's blobs are deleted like a put's
+//   - replace: the key file ends with the new record if its blobs are
+//     intact, or else with the replaced one again, then the intent's blobs
+//     are deleted like a put's
+//   - rename: once the new key's file holds the rename marker, the old key
+//     is closed and its tags and pins moved; without that file, the new
+//     key's history archive is removed
+//
+// It runs when a writer opens the namespace, before any operation. Intents
+// that can't be repaired, e.g. while another writer owns the namespace,
+// stay in the log for the next open.
+func (ns *namespace) recoverIntents() {
+	if ns.intents == nil {
+		return
+	}
+
+	pending, err := ns.intents.pending()
+	if err != nil {
+		ns.logger.Warn("failed to read intent log", Field{"namespace", ns.name}, Field{"error", err})
+		return
+	}
+	if len(pending) == 0 {
+		os.Remove(ns.intents.path)
+		return
+	}
+	if err := ns.checkWritable(); err != nil {
+		ns.logger.Warn("leaving intent log for the namespace owner", Field{"namespace", ns.name}, Field{"error", err})
+		return
+	}
+
+	// Roll back records first, so their blobs count as unreferenced
+	var unresolved, blobs []intentEntry
+	for _, entry := range pending {
+		var err error
+		switch entry.Op {
+		case intentPut:
+			err = ns.rollbackPut(entry)
+		case intentCompact:
+			err = ns.recoverCompact(entry)
+		case intentTxn:
+			err = ns.rollbackTxn(entry)
+		case intentReplace:
+			err = ns.recoverReplace(entry)
+		case intentRename:
+			err = ns.recoverRename(entry)
+		}
+		if err != nil {
+			ns.logger.Warn("failed to recover intent", Field{"namespace", ns.name}, Field{"op", entry.Op}, Field{"key", entry.Key}, Field{"error", err})
+			unresolved = append(unresolved, entry)
+		} else if entry.Op != intentCompact && entry.Op != intentRename {
+			blobs = append(blobs, entry)
+		}
+	}
+
+	if len(blobs) > 0 {
+		referenced, err := ns.referencedBlobNames()
+		if err != nil {
+			ns.logger.Warn("failed to recover intent", Field{"namespace", ns.name}, Field{"error", err})
+			unresolved = append(unresolved, blobs...)
+		} else {
+			for _, entry := range blobs {
+				for _, b := range entry.Blobs {
+					if !referenced[b.Name] {
+						os.Remove(filepath.Join(ns.blobDir, b.Name))
+					}
+				}
+			}
+		}
+	}
+
+	os.Remove(ns.intents.path)
+	for _, entry := range unresolved {
+		ns.intents.append(entry, true)
+	}
+}
+
+// rollbackPut removes the records of an unfinished put unless every blob they
+// reference made it to disk intact.
+func (ns *namespace) rollbackPut(entry intentEntry) error {
+	filePath, err := fsutil.SafeJoin(ns.path, entry.File)
+	if err != nil {
+		return err
+	}
+	if !fsutil.FileExists(filePath) {
+		return nil
+	}
+
+	records, err := ns.decoder.ReadAll(filePath)
+	if err != nil {
+		return err
+	}
+
+	last := entry.Version + max(entry.Count, 1) - 1
+	var kept []*core.Record
+	for _, record := range records {
+		v := record.Meta.Version
+		if record.Meta.Key == entry.Key && v >= entry.Version && v <= last && !record.Meta.IsDelete() {
+			continue
+		}
+		kept = append(kept, record)
+	}
+	if len(kept) == len(records) {
+		return nil
+	}
+
+	if ns.intentBlobsIntact(entry.Blobs) {
+		return nil
+	}
+
+	if len(kept) == 0 {
+		if err := os.Remove(filePath); err != nil {
+			return err
+		}
+		os.Remove(historyArchivePath(filePath))
+		ns.keyMapper.Remove(entry.Key)
+	} else if err := ns.rewriteKeyFile(filePath, kept); err != nil {
+		return err
+	}
+	ns.cache.Delete(entry.Key)
+	ns.logger.Warn("rolled back record with incomplete blobs", Field{"namespace", ns.name}, Field{"key", entry.Key}, Field{"version", entry.Version})
+	return nil
+}
+
+// intentBlobsIntact reports whether every blob file of an intent is on disk with
+// its full size.
+func (ns *namespace) intentBlobsIntact(blobs []intentBlob) bool {
+	for _, b := range blobs {
+		info, err := os.Stat(filepath.Join(ns.blobDir, b.Name))
+		if err != nil || info.Size() != b.Size {
+			return false
+		}
+	}
+	return true
+}
+
+// recoverReplace finishes an unfinished in-place replace of a key file's
+// latest record, or undoes it when the new record's blobs are incomplete.
+func (ns *namespace) recoverReplace(entry intentEntry) error {
+	filePath, err := fsutil.SafeJoin(ns.path, entry.File)
+	if err != nil {
+		return err
+	}
+
+	line := entry.Record
+	if !ns.intentBlobsIntact(entry.Blobs) {
+		line = entry.Replaced
+		ns.logger.Warn("rolled back record with incomplete blobs", Field{"namespace", ns.name}, Field{"key", entry.Key}, Field{"version", entry.Version})
+	}
+	if err := fsutil.ReplaceTail(filePath, entry.Offset, append(line, '\n')); err != nil {
+		return err
+	}
+	ns.cache.Delete(entry.Key)
+	return nil
+}
+
+// recoverRename finishes an unfinished rename whose new key file was
+// written, or removes the history archive it left for the new key otherwise.
+func (ns *namespace) recoverRename(entry intentEntry) error {
+	if len(entry.Writes) != 1 {
+		return fmt.Errorf("rename intent without its new key")
+	}
+	to := entry.Writes[0]
+	newPath, err := fsutil.SafeJoin(ns.path, to.File)
+	if err != nil {
+		return err
+	}
+	if !fsutil.FileExists(newPath) {
+		if err := os.Remove(historyArchivePath(newPath)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	// The file is written in one atomic swap, so it holds the marker unless
+	// another key owns it
+	records, err := ns.decoder.ReadAll(newPath)
+	if err != nil {
+		return err
+	}
+	renamed := false
+	for _, record := range records {
+		meta := record.Meta
+		if meta.Key == to.Key && meta.Version == to.Version && meta.RenamedFrom == entry.Key {
+			renamed = true
+		}
+	}
+	if !renamed {
+		return nil
+	}
+
+	if err := ns.finishRename(entry.Key, to.Key); err != nil {
+		return err
+	}
+	ns.cache.Delete(to.Key)
+	ns.logger.Warn("finished interrupted rename", Field{"namespace", ns.name}, Field{"key", entry.Key}, Field{"to", to.Key})
+	return nil
+}
+
+// recoverCompact removes the temporary file of an unfinished key file swap.
+func (ns *namespace) recoverCompact(entry intentEntry) error {
+	filePath, err := fsutil.SafeJoin(ns.path, entry.File)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filePath + ".tmp"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// referencedBlobNames returns the blob files referenced by any version of
+// any key, archived versions included.
+func (ns *namespace) referencedBlobNames() (map[string]bool, error) {
+	names := make(map[string]bool)
+	for _, key := range ns.keyMapper.ListAll() {
+		filePath, err := ns.getFilePath(key, false)
+		if err != nil {
+			continue
+		}
+		records, err := ns.decoder.ReadAll(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", key, err)
+		}
+		archived, err := ns.readArchivedHistory(filePath, nil)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", key, err)
+		}
+		for _, record := range append(archived, records...) {
+			// A spilled payload that can't be expanded references nothing more
+			ns.recordBlobs(record, func(ref *blob.Reference) {
+				names[blobFileName(ref)] = true
+			})
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,61 @@
+package stow
+
+import (
+	"sync"
+
+	"github.com/aigotowork/stow/internal/blob"
+)
+
+// ioTotals accumulates the file IO of a namespace's operations since it
+// was opened (see NamespaceStats.IO).
+type ioTotals struct {
+	mu      sync.Mutex
+	put     IOStats
+	compact IOStats
+	gc      IOStats
+}
+
+// add adds the IO of one operation to its total, and to the caller's
+// collectors (see WithIOStats).
+func (t *ioTotals) add(total *IOStats, op *IOStats, collectors ...*IOStats) {
+	t.mu.Lock()
+	total.add(*op)
+	t.mu.Unlock()
+
+	for _, collector := range collectors {
+		collector.add(*op)
+	}
+}
+
+// snapshot returns the totals by operation.
+func (t *ioTotals) snapshot() NamespaceIO {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return NamespaceIO{Put: t.put, Compact: t.compact, GC: t.gc}
+}
+
+func (s *IOStats) add(other IOStats) {
+	s.BytesRead += other.BytesRead
+	s.BytesWritten += other.BytesWritten
+	s.Syncs += other.Syncs
+	s.LogicalBytes += other.LogicalBytes
+}
+
+// read counts a key file of size bytes read in full.
+func (s *IOStats) read(size int64) {
+	s.BytesRead += size
+}
+
+// wrote counts n bytes written to a file that was then synced.
+func (s *IOStats) wrote(n int64) {
+	s.BytesWritten += n
+	s.Syncs++
+}
+
+// wroteBlobs counts blob files written on the caller's behalf.
+func (s *IOStats) wroteBlobs(refs ...*blob.Reference) {
+	for _, ref := range refs {
+		s.wrote(ref.Size)
+		s.LogicalBytes += ref.Size
+	}
+}
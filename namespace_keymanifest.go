@@ -0,0 +1,177 @@
+package stow
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/aigotowork/stow/internal/blob"
+	"github.com/aigotowork/stow/internal/core"
+	"github.com/aigotowork/stow/internal/fsutil"
+)
+
+// keyManifestName is the file NamespaceConfig.KeyManifest maintains.
+const keyManifestName = "_keys.jsonl"
+
+// keyManifestEntry is one line of _keys.jsonl, describing the latest record
+// of a key.
+//
+// Example:
+//
+//	{"key":"user:1","file":"user_1_abc3a4.jsonl","version":3,"blobs":[{"$blob":true,"loc":"_blobs/avatar_3f9a2c01.jpg",...}]}
+//	{"key":"user:2","file":"user_2_9d0e11.jsonl","version":2,"deleted":true}
+type keyManifestEntry struct {
+	Key     string `json:"key"`
+	File    string `json:"file"`
+	Version int    `json:"version"`
+
+	// Deleted is set when the latest record is a delete
+	Deleted bool `json:"deleted,omitempty"`
+
+	// VisibleAt is set when the latest record is scheduled
+	VisibleAt *time.Time `json:"visible_at,omitempty"`
+
+	// Blobs are the blob references of the latest record
+	Blobs []*blob.Reference `json:"blobs,omitempty"`
+}
+
+// syncKeyManifest updates the manifest entry of the key stored in filePath,
+// dropping it if the file is gone, and rewrites _keys.jsonl. Like pretty
+// files, failures only leave the manifest stale, so they are logged.
+func (ns *namespace) syncKeyManifest(filePath string) {
+	if !ns.cfg().KeyManifest || ns.readOnly {
+		return
+	}
+
+	ns.manifestMu.Lock()
+	defer ns.manifestMu.Unlock()
+
+	// The key may not be in the key mapper yet
+	if ns.manifest == nil {
+		ns.manifest = ns.scanKeyManifest()
+	}
+	if entry, ok := ns.keyManifestEntry(filePath); ok {
+		ns.manifest[entry.File] = entry
+	} else {
+		delete(ns.manifest, filepath.Base(filePath))
+	}
+
+	ns.saveKeyManifest()
+}
+
+// rebuildKeyManifest rebuilds _keys.jsonl from every key file when the
+// namespace is opened or KeyManifest is turned on, and removes it when
+// KeyManifest is off.
+func (ns *namespace) rebuildKeyManifest() {
+	if ns.readOnly {
+		return
+	}
+
+	ns.manifestMu.Lock()
+	defer ns.manifestMu.Unlock()
+
+	if !ns.cfg().KeyManifest {
+		ns.manifest = nil
+		path := filepath.Join(ns.path, keyManifestName)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			ns.logger.Warn("failed to remove key manifest", Field{"path", path}, Field{"error", err})
+		}
+		return
+	}
+
+	ns.manifest = ns.scanKeyManifest()
+	ns.saveKeyManifest()
+}
+
+// scanKeyManifest returns the manifest entries of every key file (caller
+// must hold manifestMu).
+func (ns *namespace) scanKeyManifest() map[string]keyManifestEntry {
+	ns.mu.RLock()
+	var files []string
+	for _, key := range ns.keyMapper.ListAll() {
+		if file := ns.keyMapper.FindExact(key); file != "" {
+			files = append(files, file)
+		}
+	}
+	ns.mu.RUnlock()
+
+	entries := make(map[string]keyManifestEntry, len(files))
+	for _, file := range files {
+		filePath, err := fsutil.SafeJoin(ns.path, file)
+		if err != nil {
+			continue
+		}
+		if entry, ok := ns.keyManifestEntry(filePath); ok {
+			entries[entry.File] = entry
+		}
+	}
+	return entries
+}
+
+// keyManifestEntry describes the latest record in filePath. It returns
+// false if the file holds no readable record.
+func (ns *namespace) keyManifestEntry(filePath string) (keyManifestEntry, bool) {
+	var record *core.Record
+	err := ns.decoder.ReadReverse(filePath, func(r *core.Record, _ error) bool {
+		record = r
+		return r == nil
+	})
+	if err != nil || record == nil {
+		return keyManifestEntry{}, false
+	}
+
+	entry := keyManifestEntry{
+		Key:     record.Meta.Key,
+		File:    filepath.Base(filePath),
+		Version: record.Meta.Version,
+		Deleted: record.Meta.IsDelete(),
+	}
+	if !record.Meta.VisibleAt.IsZero() {
+		visibleAt := record.Meta.VisibleAt.UTC()
+		entry.VisibleAt = &visibleAt
+	}
+
+	// Blob references would reveal what sealed records hide
+	if !ns.encrypted {
+		walkBlobRefs(record.Data, func(ref *blob.Reference, _ bool) error {
+			entry.Blobs = append(entry.Blobs, ref)
+			return nil
+		})
+		sort.Slice(entry.Blobs, func(i, j int) bool {
+			return entry.Blobs[i].Location < entry.Blobs[j].Location
+		})
+	}
+
+	return entry, true
+}
+
+// saveKeyManifest writes _keys.jsonl sorted by key (caller must hold manifestMu).
+func (ns *namespace) saveKeyManifest() {
+	entries := make([]keyManifestEntry, 0, len(ns.manifest))
+	for _, entry := range ns.manifest {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Key != entries[j].Key {
+			return entries[i].Key < entries[j].Key
+		}
+		return entries[i].File < entries[j].File
+	})
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			ns.logger.Warn("failed to encode key manifest", Field{"key", entry.Key}, Field{"error", err})
+			return
+		}
+	}
+
+	path := filepath.Join(ns.path, keyManifestName)
+	if err := ns.writeFile(path, buf.Bytes()); err != nil {
+		ns.logger.Warn("failed to write key manifest", Field{"path", path}, Field{"error", err})
+	}
+}
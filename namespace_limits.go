@@ -0,0 +1,117 @@
+package stow
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aigotowork/stow/internal/blob"
+	"github.com/aigotowork/stow/internal/codec"
+	"github.com/aigotowork/stow/internal/core"
+)
+
+// limitRecordSize applies the OversizePolicy to a record exceeding MaxInlineRecordSize.
+// It may replace record.Data. For OversizeAutoBlob the payload blob is returned so the
+// caller can clean it up on failure. For OversizeTruncate the record is still written
+// and the returned warning should be passed back to the caller.
+func (ns *namespace) limitRecordSize(record *core.Record) (spilled *blob.Reference, warning error, err error) {
+	limit := ns.cfg().MaxInlineRecordSize
+	if limit <= 0 {
+		return nil, nil, nil
+	}
+
+	line, err := ns.encoder.Encode(record)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode record: %w", err)
+	}
+
+	size := int64(len(line))
+	if size <= limit {
+		return nil, nil, nil
+	}
+
+	switch ns.cfg().OversizePolicy {
+	case OversizeAutoBlob:
+		stub, ref, err := ns.marshaler.SpillRecord(record.Data)
+		if err != nil {
+			return nil, nil, err
+		}
+		record.Data = stub
+		return ref, nil, nil
+
+	case OversizeTruncate:
+		dropped, err := ns.truncateRecord(record, size-limit)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, fmt.Errorf("%w: dropped fields %s", ErrRecordTooLarge, strings.Join(dropped, ", ")), nil
+
+	default:
+		return nil, nil, fmt.Errorf("%w: %d bytes (limit %d)", ErrRecordTooLarge, size, limit)
+	}
+}
+
+// truncateRecord removes the largest top-level fields from record.Data until at least
+// excess bytes are freed. The original data map is left untouched.
+func (ns *namespace) truncateRecord(record *core.Record, excess int64) ([]string, error) {
+	type fieldSize struct {
+		name string
+		size int64
+	}
+
+	var fields []fieldSize
+	for name, value := range record.Data {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode field %s: %w", name, err)
+		}
+		// Account for the quoted name, colon and separator as well
+		fields = append(fields, fieldSize{name, int64(len(encoded) + len(name) + 4)})
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		if fields[i].size != fields[j].size {
+			return fields[i].size > fields[j].size
+		}
+		return fields[i].name < fields[j].name
+	})
+
+	truncated := make(map[string]interface{}, len(record.Data))
+	for name, value := range record.Data {
+		truncated[name] = value
+	}
+
+	var dropped []string
+	var freed int64
+	for _, f := range fields {
+		if freed >= excess {
+			break
+		}
+		delete(truncated, f.name)
+		dropped = append(dropped, f.name)
+		freed += f.size
+	}
+
+	if freed < excess {
+		return nil, fmt.Errorf("%w: record cannot be truncated to fit", ErrRecordTooLarge)
+	}
+
+	record.Data = truncated
+	return dropped, nil
+}
+
+// expandRecord replaces a spilled-record stub with its full payload.
+func (ns *namespace) expandRecord(record *core.Record) error {
+	if _, spilled := codec.SpilledRecordRef(record.Data); !spilled {
+		return nil
+	}
+
+	data, err := ns.unmarshaler.ExpandRecord(record.Data)
+	if err != nil {
+		return err
+	}
+
+	record.Data = data
+	return nil
+}
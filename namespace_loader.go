@@ -0,0 +1,117 @@
+package stow
+
+import (
+	"errors"
+	"fmt"
+)
+
+// loadKey identifies a cache-miss load: loads with a loader may store the
+// key, so callers without one never join them, and the other way round.
+type loadKey struct {
+	key    string
+	loader bool
+}
+
+// loadCall is a cache-miss load in flight. data and err are set before done is closed.
+type loadCall struct {
+	done chan struct{}
+	data map[string]interface{}
+	err  error
+}
+
+// GetCached is like Get, but concurrent cache misses of a key wait for a
+// single read of its file instead of each reading it, so a hot key expiring
+// from the cache doesn't send every reader to disk.
+//
+// With WithLoader, a key that doesn't exist is loaded by the loader and Put,
+// once for all concurrent callers; errors of the loader or the Put, and a
+// panic of the loader, are returned to each of them. Callers without a
+// loader never wait for one.
+func (ns *namespace) GetCached(key string, target interface{}, opts ...GetOption) error {
+	options := getOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	data, err := ns.sharedData(key, options.loader)
+	if err != nil {
+		return err
+	}
+
+	return ns.unmarshaler.Unmarshal(withoutDerived(data), target)
+}
+
+// sharedData returns the latest data of key from the cache, or joins the
+// load of key in flight.
+func (ns *namespace) sharedData(key string, loader func() (interface{}, error)) (map[string]interface{}, error) {
+	if ns.closed.Load() {
+		return nil, ErrClosed
+	}
+
+	if !ns.cfg().DisableCache {
+		if cached, ok := ns.cache.Get(key); ok {
+			if data, ok := cached.(map[string]interface{}); ok {
+				return data, nil
+			}
+		}
+	}
+
+	id := loadKey{key: key, loader: loader != nil}
+	ns.loadMu.Lock()
+	if call, ok := ns.loading[id]; ok {
+		ns.loadMu.Unlock()
+		<-call.done
+		return call.data, call.err
+	}
+
+	call := &loadCall{done: make(chan struct{})}
+	if ns.loading == nil {
+		ns.loading = make(map[loadKey]*loadCall)
+	}
+	ns.loading[id] = call
+	ns.loadMu.Unlock()
+
+	// Waiters are released however the load ends; if it panics, with
+	// this error
+	call.err = fmt.Errorf("load of %s did not finish", key)
+	defer func() {
+		ns.loadMu.Lock()
+		delete(ns.loading, id)
+		ns.loadMu.Unlock()
+		close(call.done)
+	}()
+
+	call.data, call.err = ns.loadData(key, loader)
+	return call.data, call.err
+}
+
+// loadData reads the latest data of key, loading and storing it with loader
+// if the key doesn't exist.
+func (ns *namespace) loadData(key string, loader func() (interface{}, error)) (map[string]interface{}, error) {
+	data, err := ns.latestData(key)
+	if loader == nil || !errors.Is(err, ErrNotFound) {
+		return data, err
+	}
+
+	value, err := callLoader(loader)
+	if err != nil {
+		return nil, err
+	}
+	if err := ns.Put(key, value); err != nil {
+		return nil, err
+	}
+
+	// Read back the stored form, with blobs as file references
+	return ns.latestData(key)
+}
+
+// callLoader calls loader, returning a panic in it as an error: the load is
+// shared, so the panic would otherwise only reach one of its callers.
+func callLoader(loader func() (interface{}, error)) (value interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("loader panicked: %v", r)
+		}
+	}()
+	return loader()
+}
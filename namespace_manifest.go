@@ -0,0 +1,148 @@
+package stow
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/aigotowork/stow/internal/blob"
+)
+
+// BlobManifest writes one JSON line (a BlobManifestEntry) for every blob file
+// referenced by any version of any key, in location order, so backups and
+// audits can check off-site copies without reading key files. Spilled JSON
+// payloads and derived artifacts are listed like any other blob.
+//
+// Example:
+//
+//	f, _ := os.Create("blobs.ndjson")
+//	err := ns.BlobManifest(f)
+//	// {"location":"_blobs/avatar_3f9a.jpg","hash":"3f9a...","algo":"sha256","size":102400,"referenced_by":["user:1","user:7"]}
+func (ns *namespace) BlobManifest(w io.Writer) error {
+	entries, err := ns.blobManifest()
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blobManifest collects the manifest entries, sorted by location.
+func (ns *namespace) blobManifest() ([]*BlobManifestEntry, error) {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+
+	files := make(map[string]*BlobManifestEntry)
+	referencedBy := make(map[string]map[string]bool)
+
+	for _, key := range ns.keyMapper.ListAll() {
+		filePath, err := ns.getFilePath(key, false)
+		if err != nil {
+			continue
+		}
+
+		records, err := ns.decoder.ReadAll(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", key, err)
+		}
+
+		for _, record := range records {
+			err := ns.recordBlobs(record, func(ref *blob.Reference) {
+				name := blobFileName(ref)
+				if files[name] == nil {
+					algo := ref.Algo
+					if algo == "" {
+						algo = blob.HashSHA256
+					}
+					files[name] = &BlobManifestEntry{
+						Location: ref.Location,
+						Hash:     ref.Hash,
+						Algo:     algo,
+						Size:     ref.Size,
+						Filters:  ref.Filters,
+					}
+					referencedBy[name] = make(map[string]bool)
+				}
+				referencedBy[name][key] = true
+			})
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", key, err)
+			}
+		}
+	}
+
+	entries := make([]*BlobManifestEntry, 0, len(files))
+	for name, entry := range files {
+		for key := range referencedBy[name] {
+			entry.ReferencedBy = append(entry.ReferencedBy, key)
+		}
+		sort.Strings(entry.ReferencedBy)
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Location < entries[j].Location })
+
+	return entries, nil
+}
+
+// BlobReferrers returns every version of every key referencing a blob whose
+// content hash is hash, or starts with it (like the short hash in blob file
+// names), sorted by key and version. Deleted keys' history is included.
+//
+// Example:
+//
+//	refs, err := ns.BlobReferrers("3f9a2c01")
+//	// [{Key:"user:1" Version:2 Location:"_blobs/avatar_3f9a2c01.jpg"} ...]
+func (ns *namespace) BlobReferrers(hash string) ([]KeyVersionRef, error) {
+	hash = strings.ToLower(strings.TrimSpace(hash))
+	if hash == "" {
+		return nil, fmt.Errorf("%w: empty blob hash", ErrInvalidConfig)
+	}
+
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+
+	var refs []KeyVersionRef
+	for _, key := range ns.keyMapper.ListAll() {
+		filePath, err := ns.getFilePath(key, false)
+		if err != nil {
+			continue
+		}
+
+		records, err := ns.decoder.ReadAll(filePath)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", key, err)
+		}
+
+		for _, record := range records {
+			seen := make(map[string]bool)
+			err := ns.recordBlobs(record, func(ref *blob.Reference) {
+				if strings.HasPrefix(ref.Hash, hash) && !seen[ref.Location] {
+					seen[ref.Location] = true
+					refs = append(refs, KeyVersionRef{Key: key, Version: record.Meta.Version, Location: ref.Location})
+				}
+			})
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", key, err)
+			}
+		}
+	}
+
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Key != refs[j].Key {
+			return refs[i].Key < refs[j].Key
+		}
+		if refs[i].Version != refs[j].Version {
+			return refs[i].Version < refs[j].Version
+		}
+		return refs[i].Location < refs[j].Location
+	})
+	return refs, nil
+}
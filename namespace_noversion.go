@@ -0,0 +1,140 @@
+package stow
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/aigotowork/stow/internal/blob"
+	"github.com/aigotowork/stow/internal/codec"
+	"github.com/aigotowork/stow/internal/core"
+	"github.com/aigotowork/stow/internal/fsutil"
+)
+
+// updateUnversioned replaces the latest record in place when data differs from it
+// only in fields tagged `stow:"noversion"`. It reports whether the update was applied;
+// false means the caller should append a new version as usual. blobRefs are
+// the blobs data references. The IO is counted in iostats. Caller must hold
+// the key lock.
+func (ns *namespace) updateUnversioned(key string, data map[string]interface{}, fields []string, blobRefs []*blob.Reference, iostats *IOStats) (bool, error) {
+	ns.mu.RLock()
+	filePath, err := ns.getFilePath(key, false)
+	ns.mu.RUnlock()
+	if err != nil || !fsutil.FileExists(filePath) {
+		// New key, nothing to update in place
+		return false, nil
+	}
+
+	// Only the latest record is read; one that doesn't decode is appended to
+	latest, replaced, offset, err := ns.decoder.ReadTail(filePath)
+	if err != nil {
+		return false, nil
+	}
+	iostats.read(int64(len(replaced)))
+
+	if latest.Meta.IsDelete() || !latest.Meta.IsVisible(time.Now()) {
+		return false, nil
+	}
+
+	// Spilled records are always rewritten as new versions
+	if _, spilled := codec.SpilledRecordRef(latest.Data); spilled {
+		return false, nil
+	}
+
+	// Unchanged blobs keep their derived artifacts
+	same, err := equalExcept(latest.Data, data, append(fields, derivedKey))
+	if err != nil || !same {
+		return false, err
+	}
+	if derived, ok := latest.Data[derivedKey]; ok {
+		data[derivedKey] = derived
+	}
+
+	// Keep the version number and timestamp of the record being replaced
+	// (the changed data is signed anew)
+	record := core.NewRecord(latest.Meta, data)
+	record.Resign()
+
+	// Records over the inline limit go through the regular write path
+	line, err := ns.encoder.Encode(record)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode record: %w", err)
+	}
+	if limit := ns.cfg().MaxInlineRecordSize; limit > 0 && int64(len(line)) > limit {
+		return false, nil
+	}
+
+	if ok, err := ns.replaceLatest(key, filePath, record.Meta.Version, offset, replaced, line, blobRefs, iostats); !ok || err != nil {
+		return false, err
+	}
+	ns.afterWrite(changePut, key, filePath, record.Meta.Version)
+
+	ns.cache.Set(key, data)
+	return true, nil
+}
+
+// isUnchanged reports whether data equals the latest visible version of key,
+// derived artifacts aside. Keys with scheduled records pending are never
+// unchanged, as the Put would supersede them. Caller must hold the key lock.
+func (ns *namespace) isUnchanged(key string, data map[string]interface{}) (bool, error) {
+	ns.mu.RLock()
+	filePath, err := ns.getFilePath(key, false)
+	ns.mu.RUnlock()
+	if err != nil || !fsutil.FileExists(filePath) {
+		return false, nil
+	}
+
+	latest, pending, err := ns.decoder.ReadLastVisible(filePath, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to read latest record: %w", err)
+	}
+	if latest == nil || pending {
+		return false, nil
+	}
+
+	return equalExcept(latest.Data, data, []string{derivedKey})
+}
+
+// latestVersion returns the highest version recorded for key, or 0.
+func (ns *namespace) latestVersion(key string) int {
+	ns.mu.RLock()
+	filePath, err := ns.getFilePath(key, false)
+	ns.mu.RUnlock()
+	if err != nil {
+		return 0
+	}
+
+	version, err := ns.decoder.GetLatestVersion(filePath)
+	if err != nil {
+		return 0
+	}
+	return version
+}
+
+// equalExcept reports whether a and b hold the same values once the given
+// top-level fields are ignored.
+func equalExcept(a, b map[string]interface{}, ignore []string) (bool, error) {
+	encodedA, err := codec.CanonicalJSON(withoutFields(a, ignore))
+	if err != nil {
+		return false, err
+	}
+
+	encodedB, err := codec.CanonicalJSON(withoutFields(b, ignore))
+	if err != nil {
+		return false, err
+	}
+
+	return bytes.Equal(encodedA, encodedB), nil
+}
+
+// withoutFields returns a shallow copy of data without the given top-level fields.
+func withoutFields(data map[string]interface{}, fields []string) map[string]interface{} {
+	result := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		result[k] = v
+	}
+	for _, f := range fields {
+		delete(result, f)
+	}
+	return result
+}
@@ -0,0 +1,118 @@
+package stow
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aigotowork/stow/internal/codec"
+)
+
+// AppendPath appends value to the array field at path.
+func (ns *namespace) AppendPath(key, path string, value interface{}) error {
+	element, err := toPathValue(value)
+	if err != nil {
+		return err
+	}
+
+	return ns.modifyPath(key, func(data map[string]interface{}) error {
+		return codec.AppendAtPath(data, path, element)
+	})
+}
+
+// InsertPath inserts value at the array position addressed by path.
+func (ns *namespace) InsertPath(key, path string, value interface{}) error {
+	element, err := toPathValue(value)
+	if err != nil {
+		return err
+	}
+
+	return ns.modifyPath(key, func(data map[string]interface{}) error {
+		return codec.InsertAtPath(data, path, element)
+	})
+}
+
+// RemovePath removes the array element or field addressed by path.
+func (ns *namespace) RemovePath(key, path string) error {
+	return ns.modifyPath(key, func(data map[string]interface{}) error {
+		return codec.RemoveAtPath(data, path)
+	})
+}
+
+// modifyPath applies fn to the latest data of a key and appends the result as a new version.
+// The whole read-modify-write cycle runs under the key lock, so concurrent path
+// operations on the same key never lose updates.
+func (ns *namespace) modifyPath(key string, fn func(data map[string]interface{}) error) error {
+	if err := ns.checkWritable(); err != nil {
+		return err
+	}
+
+	// Acquire key-level lock
+	keyLock := ns.getKeyLock(key)
+	keyLock.Lock()
+	defer keyLock.Unlock()
+
+	// Get file path (need read lock for keyMapper)
+	ns.mu.RLock()
+	filePath, err := ns.getFilePath(key, false)
+	ns.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	// Always start from disk so the cache can't hand us a stale base
+	record, err := ns.decoder.ReadLastValid(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read record: %w", err)
+	}
+
+	if record == nil || record.Meta.IsDelete() {
+		return ErrNotFound
+	}
+
+	if err := ns.expandRecord(record); err != nil {
+		return err
+	}
+
+	// Spilled JSON subtrees are edited in their decoded form
+	data, err := ns.unmarshaler.ExpandJSONBlobs(record.Data)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(data); err != nil {
+		return err
+	}
+	if err := ns.normalize(data); err != nil {
+		return err
+	}
+
+	// Re-marshal so blob routing applies to the modified data
+	data, blobRefs, err := ns.marshaler.Marshal(data, ns.marshalOptions(&putOptions{}))
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %w", err)
+	}
+
+	var iostats IOStats
+	defer ns.io.add(&ns.io.put, &iostats)
+	iostats.wroteBlobs(blobRefs...)
+
+	return ns.appendPut(key, data, blobRefs, time.Time{}, &iostats)
+}
+
+// toPathValue converts a value to the generic form stored inside records.
+// Structs become maps; scalars, maps and slices are stored as-is.
+func toPathValue(value interface{}) (interface{}, error) {
+	data, err := codec.ToMap(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert value: %w", err)
+	}
+
+	// Unwrap scalars wrapped by ToMap
+	if len(data) == 1 {
+		if scalar, ok := data["$value"]; ok {
+			return scalar, nil
+		}
+	}
+
+	return data, nil
+}
@@ -0,0 +1,214 @@
+package stow
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/aigotowork/stow/internal/core"
+)
+
+// PinVersion protects a version of a key from being removed by compaction.
+func (ns *namespace) PinVersion(key string, version int) error {
+	if err := ns.checkWritable(); err != nil {
+		return err
+	}
+
+	ns.mu.RLock()
+	filePath, err := ns.getFilePath(key, false)
+	ns.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	// Only existing versions can be pinned
+	if _, err := ns.decoder.ReadVersion(filePath, version); err != nil {
+		return fmt.Errorf("failed to read version: %w", err)
+	}
+
+	ns.pinsMu.Lock()
+	defer ns.pinsMu.Unlock()
+
+	pins, err := ns.loadPins()
+	if err != nil {
+		return err
+	}
+
+	for _, v := range pins[key] {
+		if v == version {
+			return nil
+		}
+	}
+
+	pins[key] = append(pins[key], version)
+	sort.Ints(pins[key])
+
+	return ns.savePins(pins)
+}
+
+// UnpinVersion removes the pin from a version of a key.
+// Unpinning a version that isn't pinned is a no-op.
+func (ns *namespace) UnpinVersion(key string, version int) error {
+	if err := ns.checkWritable(); err != nil {
+		return err
+	}
+
+	ns.pinsMu.Lock()
+	defer ns.pinsMu.Unlock()
+
+	pins, err := ns.loadPins()
+	if err != nil {
+		return err
+	}
+
+	versions := pins[key]
+	for i, v := range versions {
+		if v == version {
+			versions = append(versions[:i], versions[i+1:]...)
+			if len(versions) == 0 {
+				delete(pins, key)
+			} else {
+				pins[key] = versions
+			}
+			return ns.savePins(pins)
+		}
+	}
+
+	return nil
+}
+
+// Pins returns the pinned versions of a key in ascending order.
+func (ns *namespace) Pins(key string) ([]int, error) {
+	ns.pinsMu.Lock()
+	defer ns.pinsMu.Unlock()
+
+	pins, err := ns.loadPins()
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]int(nil), pins[key]...), nil
+}
+
+// pinnedBlobRefs adds the blob references of every pinned version still in
+// a key file to refs (caller must hold ns.mu). Archived versions are
+// collected with the rest of the archive.
+func (ns *namespace) pinnedBlobRefs(refs map[string]bool) error {
+	ns.pinsMu.Lock()
+	pins, err := ns.loadPins()
+	ns.pinsMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for key, versions := range pins {
+		filePath, err := ns.getFilePath(key, false)
+		if err != nil {
+			continue
+		}
+		for _, version := range versions {
+			record, err := ns.decoder.ReadVersion(filePath, version)
+			if err != nil {
+				continue
+			}
+			ns.collectRecordBlobRefs(record, refs)
+		}
+	}
+	return nil
+}
+
+// compactionRecords returns the records of a key that survive compaction:
+// the last CompactKeepRecords records, every pinned version and the record
+// readers see while newer ones are scheduled, in file order. The records
+// compaction drops are returned too, for the history archive.
+//
+// Records come back canonical, so compacted files are byte-stable: data
+// decoded from disk re-encodes with sorted keys (blob references included)
+// and timestamps are normalized to UTC.
+func (ns *namespace) compactionRecords(key, filePath string) (kept, dropped []*core.Record, err error) {
+	records, err := ns.decoder.ReadAll(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ns.pinsMu.Lock()
+	pins, err := ns.loadPins()
+	ns.pinsMu.Unlock()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pinned := make(map[int]bool, len(pins[key]))
+	for _, v := range pins[key] {
+		pinned[v] = true
+	}
+
+	// The newest visible record, still current while later ones are pending
+	now := time.Now()
+	current := -1
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Meta.IsVisible(now) {
+			current = i
+			break
+		}
+	}
+
+	keepFrom := len(records) - ns.cfg().CompactKeepRecords
+
+	// Hash chains may only lose their start, never records in between
+	if ns.cfg().HashChain {
+		for i, record := range records {
+			if i == current || pinned[record.Meta.Version] {
+				keepFrom = min(keepFrom, i)
+				break
+			}
+		}
+	}
+
+	for i, record := range records {
+		record.Meta.Canonicalize()
+		if i >= keepFrom || i == current || pinned[record.Meta.Version] {
+			kept = append(kept, record)
+		} else {
+			dropped = append(dropped, record)
+		}
+	}
+
+	return kept, dropped, nil
+}
+
+// loadPins reads _pins.json (caller must hold pinsMu).
+// A missing file means no pins.
+func (ns *namespace) loadPins() (map[string][]int, error) {
+	pinsPath := filepath.Join(ns.path, "_pins.json")
+
+	data, err := os.ReadFile(pinsPath)
+	if os.IsNotExist(err) {
+		return make(map[string][]int), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pins: %w", err)
+	}
+
+	pins := make(map[string][]int)
+	if err := json.Unmarshal(data, &pins); err != nil {
+		return nil, fmt.Errorf("failed to parse pins: %w", err)
+	}
+
+	return pins, nil
+}
+
+// savePins writes _pins.json (caller must hold pinsMu).
+func (ns *namespace) savePins(pins map[string][]int) error {
+	pinsPath := filepath.Join(ns.path, "_pins.json")
+
+	data, err := json.MarshalIndent(pins, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ns.writeFile(pinsPath, data)
+}
@@ -0,0 +1,38 @@
+package stow
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Prime reads keys into the cache ahead of their first Get, e.g. after a
+// deploy or once a request names the keys it will need next. Missing keys
+// are skipped; it does nothing with DisableCache.
+//
+// Example:
+//
+//	err := ns.Prime("config:features", "config:limits")
+func (ns *namespace) Prime(keys ...string) error {
+	if ns.cfg().DisableCache {
+		return nil
+	}
+
+	var errs []error
+	for _, key := range keys {
+		if _, err := ns.latestData(key); err != nil && !errors.Is(err, ErrNotFound) {
+			errs = append(errs, fmt.Errorf("key %s: %w", key, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// preload primes NamespaceConfig.Preload when the namespace is opened.
+func (ns *namespace) preload() {
+	keys := ns.cfg().Preload
+	if len(keys) == 0 {
+		return
+	}
+	if err := ns.Prime(keys...); err != nil {
+		ns.logger.Warn("failed to preload keys", Field{"namespace", ns.name}, Field{"error", err})
+	}
+}
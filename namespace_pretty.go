@@ -0,0 +1,76 @@
+package stow
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/aigotowork/stow/internal/fsutil"
+)
+
+// prettyFilePath returns the path of the materialized copy of a key's latest
+// value: its records file with a .json extension. Key file names never start
+// with an underscore, so they can't clash with the namespace's own files.
+func prettyFilePath(filePath string) string {
+	return strings.TrimSuffix(filePath, ".jsonl") + ".json"
+}
+
+// syncPrettyFile writes the latest record of the key stored in filePath to
+// its .json file, indented with sorted keys, or removes the file once the key
+// is deleted. Only namespaces with LayoutPrettyFiles materialize values, and
+// never encrypted ones, as the copy would be plaintext. Failures only leave
+// the copy stale, so they are logged, not returned.
+func (ns *namespace) syncPrettyFile(filePath string) {
+	if ns.cfg().Layout != LayoutPrettyFiles || ns.encrypted {
+		return
+	}
+
+	prettyPath := prettyFilePath(filePath)
+
+	record, err := ns.decoder.ReadLastValid(filePath)
+	if err != nil || record == nil || record.Meta.IsDelete() {
+		if err := os.Remove(prettyPath); err != nil && !os.IsNotExist(err) {
+			ns.logger.Warn("failed to remove pretty file", Field{"path", prettyPath}, Field{"error", err})
+		}
+		return
+	}
+
+	data, err := json.MarshalIndent(record.Data, "", "  ")
+	if err != nil {
+		ns.logger.Warn("failed to encode pretty file", Field{"path", prettyPath}, Field{"error", err})
+		return
+	}
+	data = append(data, '\n')
+
+	// Unchanged values keep their file untouched
+	if existing, err := os.ReadFile(prettyPath); err == nil && string(existing) == string(data) {
+		return
+	}
+
+	if err := ns.writeFile(prettyPath, data); err != nil {
+		ns.logger.Warn("failed to write pretty file", Field{"path", prettyPath}, Field{"error", err})
+	}
+}
+
+// syncPrettyFiles materializes every key after the layout changed, or
+// removes the copies when pretty files are turned off.
+func (ns *namespace) syncPrettyFiles() {
+	files, err := fsutil.FindFiles(ns.path, "*.jsonl")
+	if err != nil {
+		ns.logger.Warn("failed to list records for pretty files", Field{"error", err})
+		return
+	}
+
+	for _, filePath := range files {
+		// Skip blobs, indexes and the namespace's own files
+		if !isKeyFilePath(filePath) {
+			continue
+		}
+
+		if ns.cfg().Layout == LayoutPrettyFiles {
+			ns.syncPrettyFile(filePath)
+		} else {
+			os.Remove(prettyFilePath(filePath))
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package stow
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aigotowork/stow/internal/blob"
+	"github.com/aigotowork/stow/internal/core"
+	"github.com/aigotowork/stow/internal/fsutil"
+)
+
+// Prune removes old versions of every key in one pass and deletes the blob
+// files only they referenced.
+//
+// Example:
+//
+//	// Keep a month of history, and at least the last 5 versions of each key
+//	result, err := ns.Prune(stow.PruneOptions{OlderThan: 30 * 24 * time.Hour, KeepAtLeast: 5})
+//	fmt.Printf("reclaimed %d bytes\n", result.ReclaimedSize)
+func (ns *namespace) Prune(opts PruneOptions) (PruneResult, error) {
+	var result PruneResult
+	err := ns.timer.run(opNameCompact, ns.name, "", func() error {
+		var err error
+		result, err = ns.prune(opts)
+		return err
+	})
+	return result, err
+}
+
+func (ns *namespace) prune(opts PruneOptions) (PruneResult, error) {
+	if err := ns.checkWritable(); err != nil {
+		return PruneResult{}, err
+	}
+	if opts.OlderThan < 0 || opts.KeepAtLeast < 0 {
+		return PruneResult{}, fmt.Errorf("%w: OlderThan and KeepAtLeast must be non-negative", ErrInvalidConfig)
+	}
+
+	startTime := time.Now()
+	cutoff := startTime.Add(-opts.OlderThan)
+
+	var result PruneResult
+	blobs := &prunedBlobs{
+		kept:     make(map[string]bool),
+		released: make(map[string]*blob.Reference),
+	}
+
+	ns.mu.RLock()
+	keys := ns.keyMapper.ListAll()
+	ns.mu.RUnlock()
+
+	for _, key := range keys {
+		if err := ns.pruneKey(key, cutoff, opts.KeepAtLeast, blobs, &result); err != nil {
+			return result, fmt.Errorf("key %s: %w", key, err)
+		}
+	}
+
+	// Blob deletes don't overlap GC
+	ns.mu.Lock()
+	if !blobs.keepAll {
+		for name, ref := range blobs.released {
+			if blobs.kept[name] {
+				continue
+			}
+			size := fsutil.FileSize(filepath.Join(ns.blobDir, name))
+			if err := ns.blobManager.Delete(ref); err != nil {
+				ns.logger.Warn("failed to remove blob", Field{"blob", name}, Field{"error", err})
+				continue
+			}
+			result.RemovedBlobs++
+			result.ReclaimedSize += size
+		}
+	}
+	ns.mu.Unlock()
+
+	result.Duration = time.Since(startTime)
+
+	ns.disk.rescan()
+
+	return result, nil
+}
+
+// prunedBlobs collects the blob files of a prune pass.
+type prunedBlobs struct {
+	kept     map[string]bool            // still referenced
+	released map[string]*blob.Reference // referenced by pruned versions
+	keepAll  bool                       // a reference couldn't be read
+}
+
+// pruneKey removes the old versions of key under its lock, noting the blobs
+// its surviving and archived versions reference in blobs and the pruned
+// versions' blobs as released.
+func (ns *namespace) pruneKey(key string, cutoff time.Time, keepAtLeast int, blobs *prunedBlobs, result *PruneResult) error {
+	keyLock := ns.getKeyLock(key)
+	keyLock.Lock()
+	defer keyLock.Unlock()
+
+	ns.mu.RLock()
+	filePath, err := ns.getFilePath(key, false)
+	ns.mu.RUnlock()
+	if err != nil {
+		return nil
+	}
+
+	records, err := ns.decoder.ReadAll(filePath)
+	if err != nil {
+		return err
+	}
+
+	survivors, err := ns.pruneRecords(key, records, cutoff, keepAtLeast)
+	if err != nil {
+		return err
+	}
+
+	keep := func(ref *blob.Reference) { blobs.kept[blobFileName(ref)] = true }
+	for _, record := range survivors {
+		if err := ns.recordBlobs(record, keep); err != nil {
+			ns.logger.Warn("failed to read blob references, keeping blobs", Field{"key", key}, Field{"error", err})
+			blobs.keepAll = true
+		}
+	}
+
+	// Archived versions are still readable with GetVersion
+	archived, err := ns.readArchivedHistory(filePath, nil)
+	if err != nil {
+		ns.logger.Warn("failed to read history archive, keeping blobs", Field{"key", key}, Field{"error", err})
+		blobs.keepAll = true
+	}
+	for _, record := range archived {
+		if err := ns.recordBlobs(record, keep); err != nil {
+			ns.logger.Warn("failed to read blob references, keeping blobs", Field{"key", key}, Field{"error", err})
+			blobs.keepAll = true
+		}
+	}
+
+	if len(survivors) == len(records) {
+		return nil
+	}
+
+	survived := make(map[*core.Record]bool, len(survivors))
+	for _, record := range survivors {
+		survived[record] = true
+	}
+	for _, record := range records {
+		if !survived[record] {
+			ns.recordBlobs(record, func(ref *blob.Reference) { blobs.released[blobFileName(ref)] = ref })
+		}
+	}
+
+	sizeBefore := fsutil.FileSize(filePath)
+	if err := ns.rewriteKeyFile(filePath, survivors); err != nil {
+		return err
+	}
+	ns.cache.Delete(key)
+
+	result.Keys++
+	result.RemovedVersions += len(records) - len(survivors)
+	result.ReclaimedSize += sizeBefore - fsutil.FileSize(filePath)
+	return nil
+}
+
+// pruneRecords returns the records of a key that survive a prune, in file
+// order: those written after cutoff and every version after them, the last
+// keepAtLeast, pinned versions, and the record readers currently see along
+// with any scheduled after it.
+func (ns *namespace) pruneRecords(key string, records []*core.Record, cutoff time.Time, keepAtLeast int) ([]*core.Record, error) {
+	ns.pinsMu.Lock()
+	pins, err := ns.loadPins()
+	ns.pinsMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	pinned := make(map[int]bool, len(pins[key]))
+	for _, v := range pins[key] {
+		pinned[v] = true
+	}
+
+	now := time.Now()
+	current := len(records)
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Meta.IsVisible(now) {
+			current = i
+			break
+		}
+	}
+
+	// A record is as recent as the newest timestamp up to it, so a clock
+	// that stepped back never prunes a record while keeping older versions
+	recentFrom := len(records)
+	var newest time.Time
+	for i, record := range records {
+		if record.Meta.Timestamp.After(newest) {
+			newest = record.Meta.Timestamp
+		}
+		if !newest.Before(cutoff) {
+			recentFrom = i
+			break
+		}
+	}
+
+	keepFrom := min(current, len(records)-keepAtLeast, recentFrom)
+	keep := func(i int) bool {
+		return i >= keepFrom || pinned[records[i].Meta.Version]
+	}
+
+	// Hash chains may only lose their start, never records in between
+	if ns.cfg().HashChain {
+		for i := range records {
+			if keep(i) {
+				keepFrom = i
+				break
+			}
+		}
+	}
+
+	var survivors []*core.Record
+	for i, record := range records {
+		if keep(i) {
+			record.Meta.Canonicalize()
+			survivors = append(survivors, record)
+		}
+	}
+
+	return survivors, nil
+}
+
+// recordBlobs calls fn for every blob a record references, including those
+// inside a spilled record's payload.
+func (ns *namespace) recordBlobs(record *core.Record, fn func(ref *blob.Reference)) error {
+	spilled := false
+	walkBlobRefs(record.Data, func(ref *blob.Reference, isSpilled bool) error {
+		fn(ref)
+		spilled = spilled || isSpilled
+		return nil
+	})
+	if !spilled {
+		return nil
+	}
+
+	payload, err := ns.unmarshaler.ExpandRecord(record.Data)
+	if err != nil {
+		return err
+	}
+	return walkBlobRefs(payload, func(ref *blob.Reference, _ bool) error {
+		fn(ref)
+		return nil
+	})
+}
+
+// blobFileName returns the name of the file a reference points to in the
+// blob directory.
+func blobFileName(ref *blob.Reference) string {
+	return path.Base(strings.ReplaceAll(ref.Location, "\\", "/"))
+}
@@ -0,0 +1,320 @@
+package stow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/aigotowork/stow/internal/codec"
+)
+
+// FilterOp is the comparison a Filter applies.
+type FilterOp string
+
+// Filter operations.
+const (
+	FilterEq       FilterOp = "eq"
+	FilterNe       FilterOp = "ne"
+	FilterLt       FilterOp = "lt"
+	FilterLte      FilterOp = "lte"
+	FilterGt       FilterOp = "gt"
+	FilterGte      FilterOp = "gte"
+	FilterContains FilterOp = "contains"
+	FilterHasField FilterOp = "has_field"
+)
+
+// Filter is a predicate on a field of a key's latest value, for
+// Namespace.Query. Build one with Eq, Ne, Lt, Lte, Gt, Gte, Contains or
+// HasField.
+//
+// Path addresses the field like AppendPath does ("status",
+// "address.city", "tags[0]"). Values compare as they are stored in JSON:
+// numbers of any Go type compare numerically, times as their RFC 3339
+// strings, structs as objects. Ranges compare two numbers or two strings
+// (lexicographically); any other pair, and missing fields, never match.
+type Filter struct {
+	Path  string
+	Op    FilterOp
+	Value interface{}
+}
+
+// Eq matches values whose field at path equals value.
+func Eq(path string, value interface{}) Filter {
+	return Filter{Path: path, Op: FilterEq, Value: value}
+}
+
+// Ne matches values whose field at path is missing or differs from value.
+func Ne(path string, value interface{}) Filter {
+	return Filter{Path: path, Op: FilterNe, Value: value}
+}
+
+// Lt matches values whose field at path is less than value.
+func Lt(path string, value interface{}) Filter {
+	return Filter{Path: path, Op: FilterLt, Value: value}
+}
+
+// Lte matches values whose field at path is less than or equal to value.
+func Lte(path string, value interface{}) Filter {
+	return Filter{Path: path, Op: FilterLte, Value: value}
+}
+
+// Gt matches values whose field at path is greater than value.
+func Gt(path string, value interface{}) Filter {
+	return Filter{Path: path, Op: FilterGt, Value: value}
+}
+
+// Gte matches values whose field at path is greater than or equal to value.
+func Gte(path string, value interface{}) Filter {
+	return Filter{Path: path, Op: FilterGte, Value: value}
+}
+
+// Contains matches values whose field at path is an array holding an
+// element equal to value, or a string containing value (a string).
+func Contains(path string, value interface{}) Filter {
+	return Filter{Path: path, Op: FilterContains, Value: value}
+}
+
+// HasField matches values that have a field at path, null included.
+func HasField(path string) Filter {
+	return Filter{Path: path, Op: FilterHasField}
+}
+
+// QueryResult is a key matched by Namespace.Query.
+type QueryResult struct {
+	// Key of the matching value
+	Key string `json:"key"`
+
+	// data is the matched value, decoded on demand
+	data   map[string]interface{}
+	decode func(data map[string]interface{}, target interface{}) error
+}
+
+// Decode unmarshals the matched value into target, like Get.
+func (r QueryResult) Decode(target interface{}) error {
+	if r.decode == nil {
+		return fmt.Errorf("query result %s has no source to decode from", r.Key)
+	}
+	return r.decode(r.data, target)
+}
+
+// Query returns the keys whose latest value matches every filter, in
+// ascending key order; no filters match every key. It reads every key
+// (from the cache when it can), so it suits namespaces of thousands of
+// keys rather than millions.
+//
+// Example:
+//
+//	results, err := ns.Query(stow.Eq("status", "active"), stow.Gte("age", 18), stow.Contains("roles", "admin"))
+//	for _, r := range results {
+//		var user User
+//		if err := r.Decode(&user); err != nil {
+//			return err
+//		}
+//	}
+func (ns *namespace) Query(filters ...Filter) ([]QueryResult, error) {
+	compiled := make([]compiledFilter, len(filters))
+	for i, filter := range filters {
+		c, err := compileFilter(filter)
+		if err != nil {
+			return nil, err
+		}
+		compiled[i] = c
+	}
+
+	ns.mu.RLock()
+	keys := ns.keyMapper.ListAll()
+	ns.mu.RUnlock()
+	sort.Strings(keys)
+
+	decode := func(data map[string]interface{}, target interface{}) error {
+		return ns.unmarshaler.Unmarshal(withoutDerived(data), target)
+	}
+
+	var results []QueryResult
+	for _, key := range keys {
+		data, document, err := ns.latestDocument(key)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				continue // Deleted key
+			}
+			return nil, fmt.Errorf("key %s: %w", key, err)
+		}
+
+		matched := true
+		for _, c := range compiled {
+			if !c.match(document) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			results = append(results, QueryResult{Key: key, data: data, decode: decode})
+		}
+	}
+
+	return results, nil
+}
+
+// latestDocument returns the latest value of a key, and the same value in
+// JSON form for matching: spilled subtrees expanded and cached Go values
+// converted.
+func (ns *namespace) latestDocument(key string) (map[string]interface{}, map[string]interface{}, error) {
+	data, err := ns.latestData(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fields, err := ns.unmarshaler.ExpandJSONBlobs(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	document, err := jsonForm(fields)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, document.(map[string]interface{}), nil // JSON of a map
+}
+
+// compiledFilter is a validated Filter with its value in JSON form.
+type compiledFilter struct {
+	Filter
+	value interface{}
+}
+
+func compileFilter(filter Filter) (compiledFilter, error) {
+	if _, err := codec.ParsePath(filter.Path); err != nil {
+		return compiledFilter{}, err
+	}
+	value, err := jsonForm(filter.Value)
+	if err != nil {
+		return compiledFilter{}, fmt.Errorf("%w: %s: %v", ErrInvalidFilter, filter.Path, err)
+	}
+
+	switch filter.Op {
+	case FilterEq, FilterNe, FilterHasField:
+	case FilterLt, FilterLte, FilterGt, FilterGte:
+		switch value.(type) {
+		case float64, string:
+		default:
+			return compiledFilter{}, fmt.Errorf("%w: %s %s needs a number or a string, got %T", ErrInvalidFilter, filter.Path, filter.Op, filter.Value)
+		}
+	case FilterContains:
+		if value == nil {
+			return compiledFilter{}, fmt.Errorf("%w: %s contains needs a value", ErrInvalidFilter, filter.Path)
+		}
+	default:
+		return compiledFilter{}, fmt.Errorf("%w: unknown operation %q", ErrInvalidFilter, filter.Op)
+	}
+
+	return compiledFilter{Filter: filter, value: value}, nil
+}
+
+// match reports whether document satisfies the filter.
+func (c compiledFilter) match(document map[string]interface{}) bool {
+	field, err := codec.GetPath(document, c.Path)
+	found := err == nil
+
+	switch c.Op {
+	case FilterHasField:
+		return found
+	case FilterEq:
+		return found && reflect.DeepEqual(field, c.value)
+	case FilterNe:
+		return !found || !reflect.DeepEqual(field, c.value)
+	case FilterContains:
+		switch field := field.(type) {
+		case []interface{}:
+			for _, element := range field {
+				if reflect.DeepEqual(element, c.value) {
+					return true
+				}
+			}
+		case string:
+			s, ok := c.value.(string)
+			return ok && strings.Contains(field, s)
+		}
+		return false
+	}
+
+	// Ranges
+	order, ok := compareJSON(field, c.value)
+	if !found || !ok {
+		return false
+	}
+	switch c.Op {
+	case FilterLt:
+		return order < 0
+	case FilterLte:
+		return order <= 0
+	case FilterGt:
+		return order > 0
+	default:
+		return order >= 0
+	}
+}
+
+// compareJSON orders two numbers or two strings.
+func compareJSON(a, b interface{}) (int, bool) {
+	switch a := a.(type) {
+	case float64:
+		b, ok := b.(float64)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case a < b:
+			return -1, true
+		case a > b:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		b, ok := b.(string)
+		return strings.Compare(a, b), ok
+	}
+	return 0, false
+}
+
+// jsonForm returns value as encoding/json decodes it into an interface{}
+// (float64 numbers, string times, maps for structs). Values already in
+// that form are returned as they are, without a round trip.
+func jsonForm(value interface{}) (interface{}, error) {
+	if isJSONForm(value) {
+		return value, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+func isJSONForm(value interface{}) bool {
+	switch v := value.(type) {
+	case nil, bool, float64, string:
+		return true
+	case []interface{}:
+		for _, element := range v {
+			if !isJSONForm(element) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		for _, element := range v {
+			if !isJSONForm(element) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
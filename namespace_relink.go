@@ -0,0 +1,263 @@
+package stow
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aigotowork/stow/internal/blob"
+	"github.com/aigotowork/stow/internal/fsutil"
+)
+
+// RelinkBlobs repairs blob references whose files are missing from the blob
+// directory, e.g. after blob files were reorganized by hand. Missing blobs are
+// searched for by content hash in its subdirectories (moved back) and in
+// BlobSearchPaths (copied back), and every version referencing them is
+// rewritten to the found location. Blobs that can't be found are reported.
+//
+// References inside spilled JSON blobs are not searched.
+func (ns *namespace) RelinkBlobs() (RelinkResult, error) {
+	result := RelinkResult{}
+	if err := ns.checkWritable(); err != nil {
+		return result, err
+	}
+
+	files, err := fsutil.FindFiles(ns.path, "*.jsonl")
+	if err != nil {
+		return result, err
+	}
+
+	finder := &blobFinder{
+		ns:     ns,
+		hashes: make(map[string]string),
+		found:  make(map[string]string),
+	}
+	relinked := make(map[string]*blob.Reference) // by missing location
+	unresolved := make(map[string]map[string]bool)
+
+	for _, filePath := range files {
+		// Skip blobs, indexes and the namespace's own files
+		if !isKeyFilePath(filePath) {
+			continue
+		}
+		if err := ns.relinkFile(filePath, finder, relinked, unresolved, &result); err != nil {
+			return result, err
+		}
+	}
+
+	for key, locations := range unresolved {
+		if result.Unresolved == nil {
+			result.Unresolved = make(map[string][]string)
+		}
+		for location := range locations {
+			result.Unresolved[key] = append(result.Unresolved[key], location)
+		}
+		sort.Strings(result.Unresolved[key])
+	}
+
+	ns.disk.rescan()
+
+	return result, nil
+}
+
+// relinkFile relinks the missing blobs referenced by the key file filePath
+// under its key's lock, noting relinked and unresolved locations.
+func (ns *namespace) relinkFile(filePath string, finder *blobFinder, relinked map[string]*blob.Reference, unresolved map[string]map[string]bool, result *RelinkResult) error {
+	records, err := ns.decoder.ReadAll(filePath)
+	if err != nil || len(records) == 0 {
+		return nil
+	}
+	key := records[0].Meta.Key
+
+	keyLock := ns.getKeyLock(key)
+	keyLock.Lock()
+	defer keyLock.Unlock()
+
+	// Blobs brought back stay out of reach of GC until referenced
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	// Read again, as a write may have landed before the lock was taken
+	records, err = ns.decoder.ReadAll(filePath)
+	if err != nil || len(records) == 0 || records[0].Meta.Key != key {
+		return nil
+	}
+
+	changed := false
+	firstChanged := -1
+	for i, record := range records {
+		recordChanged := false
+		signed := ns.encoder.Signed(record)
+		rewriteBlobRefs(record.Data, func(ref *blob.Reference) *blob.Reference {
+			if ns.blobManager.Exists(ref) {
+				return nil
+			}
+
+			found, ok := relinked[ref.Location]
+			if !ok {
+				found = finder.relink(ref)
+				relinked[ref.Location] = found
+				if found != nil {
+					result.Relinked++
+				}
+			}
+			if found == nil {
+				if unresolved[key] == nil {
+					unresolved[key] = make(map[string]bool)
+				}
+				unresolved[key][ref.Location] = true
+				return nil
+			}
+
+			// The file often comes back under its old name
+			if found.Location == ref.Location {
+				return nil
+			}
+			recordChanged = true
+			return found
+		})
+
+		// Records pointing elsewhere are signed anew, if they were
+		// validly signed
+		if recordChanged {
+			if signed {
+				record.Resign()
+			}
+			if !changed {
+				firstChanged = i
+			}
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	// Later records link to the rewritten ones
+	if err := ns.rechain(records, firstChanged+1); err != nil {
+		return err
+	}
+
+	if err := ns.encoder.Rewrite(filePath, records); err != nil {
+		return err
+	}
+	ns.cache.Delete(key)
+	ns.afterWrite(changePut, key, filePath, records[len(records)-1].Meta.Version)
+	return nil
+}
+
+// rewriteBlobRefs walks data and replaces each blob reference fn returns a
+// replacement for.
+func rewriteBlobRefs(data interface{}, fn func(*blob.Reference) *blob.Reference) {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if m, ok := value.(map[string]interface{}); ok {
+				if ref, isRef := blob.FromMap(m); isRef {
+					if replacement := fn(ref); replacement != nil {
+						v[key] = replacement.ToMap()
+					}
+					continue
+				}
+			}
+			rewriteBlobRefs(value, fn)
+		}
+	case []interface{}:
+		for _, value := range v {
+			rewriteBlobRefs(value, fn)
+		}
+	}
+}
+
+// blobFinder locates missing blob files by content hash.
+type blobFinder struct {
+	ns         *namespace
+	candidates []string
+	scanned    bool
+
+	// Computed hashes by algorithm and path
+	hashes map[string]string
+
+	// Relinked locations by content hash
+	found map[string]string
+}
+
+// relink finds the file of a missing blob and brings it back.
+// Returns nil if no file matches.
+func (f *blobFinder) relink(ref *blob.Reference) *blob.Reference {
+	// Another reference may have brought the same content back
+	if location, ok := f.found[ref.Hash]; ok {
+		relinked := *ref
+		relinked.Location = location
+		return &relinked
+	}
+
+	f.scan()
+
+	// Files keeping their name are the likely match, so try them first
+	name := filepath.Base(ref.Location)
+	for _, pass := range []bool{true, false} {
+		for _, path := range f.candidates {
+			if (filepath.Base(path) == name) != pass || !f.matches(ref, path) {
+				continue
+			}
+
+			move := strings.HasPrefix(path, f.blobDir()+string(filepath.Separator))
+			relinked, err := f.ns.blobManager.Relink(ref, path, move)
+			if err != nil {
+				f.ns.logger.Warn("failed to relink blob", Field{"path", path}, Field{"error", err})
+				continue
+			}
+			f.found[ref.Hash] = relinked.Location
+			return relinked
+		}
+	}
+
+	return nil
+}
+
+// matches reports whether the file at path has ref's content hash.
+func (f *blobFinder) matches(ref *blob.Reference, path string) bool {
+	cacheKey := ref.Algo + ":" + strings.Join(ref.Filters, "-") + ":" + path
+	hash, ok := f.hashes[cacheKey]
+	if !ok {
+		hash, _ = f.ns.blobManager.HashFile(path, ref)
+		f.hashes[cacheKey] = hash
+	}
+	return hash != "" && hash == ref.Hash
+}
+
+func (f *blobFinder) blobDir() string {
+	return f.ns.blobDir
+}
+
+// scan lists the files in subdirectories of the blob directory and in
+// BlobSearchPaths, never following symlinks.
+func (f *blobFinder) scan() {
+	if f.scanned {
+		return
+	}
+	f.scanned = true
+
+	blobDir := f.blobDir()
+	roots := append([]string{blobDir}, f.ns.cfg().BlobSearchPaths...)
+
+	for _, root := range roots {
+		filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if !entry.Type().IsRegular() {
+				return nil
+			}
+			// Files directly in the blob directory already resolve
+			if filepath.Dir(path) == blobDir || strings.Contains(entry.Name(), "tmp_") {
+				return nil
+			}
+			f.candidates = append(f.candidates, path)
+			return nil
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package stow
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aigotowork/stow/internal/core"
+	"github.com/aigotowork/stow/internal/fsutil"
+)
+
+// renameReason is the delete reason of the record closing a renamed key.
+const renameReason = "rename"
+
+// Rename moves oldKey to newKey with its full history, blob references,
+// pins and tags. newKey receives every record of oldKey (same versions and
+// timestamps) and a new version holding the current value, marked with
+// RenamedFrom; archived versions go to newKey's history archive. oldKey
+// keeps its history and ends with a delete marked with RenamedTo, so both
+// keys' logs show the rename.
+//
+// newKey must not exist yet (ErrKeyConflict otherwise), and oldKey must
+// exist. Pending scheduled writes of oldKey are superseded, as by any write.
+// The rename is logged as an intent first: a crash after newKey was written
+// is finished on the next open, one before leaves oldKey as it was.
+func (ns *namespace) Rename(oldKey, newKey string) error {
+	if err := ns.checkWritable(); err != nil {
+		return err
+	}
+	if !ns.validKey(newKey) {
+		return fmt.Errorf("invalid key: %s", newKey)
+	}
+	if oldKey == newKey {
+		return fmt.Errorf("%w: cannot rename %q to itself", ErrKeyConflict, oldKey)
+	}
+
+	// Key locks are taken in ascending key order, so renames in opposite
+	// directions can't deadlock
+	first, second := ns.getKeyLock(oldKey), ns.getKeyLock(newKey)
+	if newKey < oldKey {
+		first, second = second, first
+	}
+	first.Lock()
+	defer first.Unlock()
+	second.Lock()
+	defer second.Unlock()
+
+	ns.mu.RLock()
+	filePath, err := ns.getFilePath(oldKey, false)
+	_, newErr := ns.getFilePath(newKey, false)
+	newPath, pathErr := ns.getFilePath(newKey, true)
+	ns.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	if newErr == nil {
+		return fmt.Errorf("%w: key %q already exists", ErrKeyConflict, newKey)
+	}
+	if pathErr != nil {
+		return pathErr
+	}
+
+	records, err := ns.decoder.ReadAll(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read records: %w", err)
+	}
+	current := latestVisible(records, time.Now())
+	if current == nil || current.Meta.IsDelete() {
+		return ErrNotFound
+	}
+
+	// Versions only the history archive holds stay archived under newKey
+	all, err := ns.withArchivedHistory(filePath, records)
+	if err != nil {
+		return err
+	}
+	archived := len(all) - len(records)
+
+	marker := core.NewPutRecord(newKey, all[len(all)-1].Meta.Version+1, current.Data)
+	marker.Meta.RenamedFrom = oldKey
+	ns.stamp(marker.Meta)
+
+	for _, record := range all {
+		// Signed anew if validly signed, so tampered records stay invalid
+		signed := ns.encoder.Signed(record)
+		record.Meta.Key = newKey
+		if signed {
+			record.Resign()
+		}
+	}
+	all = append(all, marker)
+
+	// Renamed records get new digests to link to
+	if err := ns.rechain(all, 1); err != nil {
+		return err
+	}
+
+	intent, err := ns.intents.begin(intentEntry{
+		Op:     intentRename,
+		Key:    oldKey,
+		File:   filepath.Base(filePath),
+		Writes: []intentWrite{{Key: newKey, File: filepath.Base(newPath), Version: marker.Meta.Version}},
+	})
+	if err != nil {
+		return err
+	}
+
+	if archived > 0 {
+		if err := ns.archiveHistory(newPath, all[:archived], &IOStats{}); err != nil {
+			os.Remove(historyArchivePath(newPath))
+			ns.intents.done(intent)
+			return err
+		}
+	}
+	if err := ns.writeLoaded(newKey, all[archived:], nil); err != nil {
+		// Undone on the next open if newKey was left without its file
+		if !fsutil.FileExists(newPath) {
+			os.Remove(historyArchivePath(newPath))
+			ns.intents.done(intent)
+		}
+		return err
+	}
+
+	if err := ns.finishRename(oldKey, newKey); err != nil {
+		return err
+	}
+	ns.intents.done(intent)
+	return nil
+}
+
+// finishRename closes oldKey once newKey holds its records: it appends the
+// delete marked with RenamedTo, unless oldKey already ends with it, and
+// moves the tags and pins. Caller must hold both key locks.
+func (ns *namespace) finishRename(oldKey, newKey string) error {
+	ns.mu.RLock()
+	filePath, err := ns.getFilePath(oldKey, false)
+	ns.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	latest, _, _, err := ns.decoder.ReadTail(filePath)
+	if err != nil || !latest.Meta.IsDelete() || latest.Meta.RenamedTo != newKey {
+		if err := ns.appendDelete(oldKey, renameReason, newKey); err != nil {
+			return err
+		}
+	}
+	if err := ns.moveTags(oldKey, newKey); err != nil {
+		return err
+	}
+	return ns.movePins(oldKey, newKey)
+}
+
+// moveTags gives newKey the tags of oldKey.
+func (ns *namespace) moveTags(oldKey, newKey string) error {
+	ns.tagsMu.Lock()
+	defer ns.tagsMu.Unlock()
+
+	tagged, err := ns.loadTags()
+	if err != nil {
+		return err
+	}
+	tags, ok := tagged[oldKey]
+	if !ok {
+		return nil
+	}
+
+	delete(tagged, oldKey)
+	tagged[newKey] = tags
+	return ns.saveTags(tagged)
+}
+
+// movePins gives newKey the pins of oldKey.
+func (ns *namespace) movePins(oldKey, newKey string) error {
+	ns.pinsMu.Lock()
+	defer ns.pinsMu.Unlock()
+
+	pins, err := ns.loadPins()
+	if err != nil {
+		return err
+	}
+	versions, ok := pins[oldKey]
+	if !ok {
+		return nil
+	}
+
+	delete(pins, oldKey)
+	pins[newKey] = versions
+	return ns.savePins(pins)
+}
@@ -0,0 +1,44 @@
+package stow
+
+import (
+	"github.com/aigotowork/stow/internal/index"
+)
+
+// DefaultScanWorkers is how many key files a namespace reads at once while
+// building its key index.
+const DefaultScanWorkers = index.DefaultScanWorkers
+
+// scanOptions configures how a namespace builds its key index.
+type scanOptions struct {
+	// workers is the number of key files read at once (0 means
+	// DefaultScanWorkers)
+	workers int
+
+	// progress is called as key files are read on open (nil for none)
+	progress func(OpenProgress)
+}
+
+// scanOptions returns the scan options namespaces of the store open with.
+func (s *store) scanOptions() scanOptions {
+	workers := s.scanWorkers
+	if workers <= 0 {
+		workers = DefaultScanWorkers
+	}
+	// Scanning doesn't go through the file pool, so keep it under its cap
+	if limit := s.resources.maxFiles; limit > 0 {
+		workers = min(workers, limit)
+	}
+	return scanOptions{workers: workers, progress: s.openProgress}
+}
+
+// scanner returns a scanner for the namespace name reporting its progress.
+func (o scanOptions) scanner(name string) *index.Scanner {
+	scanner := index.NewScanner()
+	scanner.Workers = o.workers
+	if o.progress != nil {
+		scanner.Progress = func(done, total int) {
+			o.progress(OpenProgress{Namespace: name, Files: done, Total: total})
+		}
+	}
+	return scanner
+}
@@ -0,0 +1,187 @@
+package stow
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aigotowork/stow/internal/blob"
+	"github.com/aigotowork/stow/internal/codec"
+)
+
+// InferSchema reads the latest visible record of up to sample keys, spread
+// evenly over the namespace in key order, and reports the fields found.
+// sample <= 0 reads every key. Blobs are reported by reference, without
+// being read; spilled JSON subtrees are read and described like inline
+// data.
+//
+// Example:
+//
+//	report, err := ns.InferSchema(1000)
+//	for _, field := range report.Inconsistent() {
+//		fmt.Println(field.Path, field.Types) // e.g. price map[number:950 string:50]
+//	}
+func (ns *namespace) InferSchema(sample int) (*SchemaReport, error) {
+	ns.mu.RLock()
+	keys := ns.keyMapper.ListAll()
+	ns.mu.RUnlock()
+	sort.Strings(keys)
+
+	report := &SchemaReport{Keys: len(keys)}
+	inferrer := newSchemaInferrer()
+	for _, key := range sampleKeys(keys, sample) {
+		data, err := ns.schemaData(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", key, err)
+		}
+		if data == nil {
+			continue
+		}
+
+		report.Sampled++
+		if value, ok := data["$value"]; ok && len(data) == 1 {
+			// Keys holding a scalar or a list
+			inferrer.value("", value)
+		} else {
+			inferrer.object("", data)
+		}
+	}
+
+	report.Fields = inferrer.fields()
+	return report, nil
+}
+
+// sampleKeys returns n keys spread evenly over keys (all of them for n <= 0).
+func sampleKeys(keys []string, n int) []string {
+	if n <= 0 || n >= len(keys) {
+		return keys
+	}
+
+	sampled := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		sampled = append(sampled, keys[i*len(keys)/n])
+	}
+	return sampled
+}
+
+// schemaData returns the latest visible data of key, with spilled parts
+// read back, or nil when the key was deleted.
+func (ns *namespace) schemaData(key string) (map[string]interface{}, error) {
+	ns.mu.RLock()
+	filePath, err := ns.getFilePath(key, false)
+	ns.mu.RUnlock()
+	if err != nil {
+		// Removed since the keys were listed
+		return nil, nil
+	}
+
+	record, err := ns.decoder.ReadLastValid(filePath)
+	if err != nil || record == nil || record.Meta.IsDelete() {
+		return nil, err
+	}
+	if err := ns.expandRecord(record); err != nil {
+		return nil, err
+	}
+
+	return ns.unmarshaler.ExpandJSONBlobs(withoutDerived(record.Data))
+}
+
+// schemaInferrer accumulates the fields of the records passed to it.
+type schemaInferrer struct {
+	fieldsByPath map[string]*FieldSchema
+
+	// objects counts the objects found at each path, to tell optional
+	// fields from ones every object holds
+	objects map[string]int
+}
+
+func newSchemaInferrer() *schemaInferrer {
+	return &schemaInferrer{
+		fieldsByPath: make(map[string]*FieldSchema),
+		objects:      make(map[string]int),
+	}
+}
+
+// object records the fields of an object found at path.
+func (s *schemaInferrer) object(path string, m map[string]interface{}) {
+	s.objects[path]++
+	for name, value := range m {
+		s.value(joinSchemaPath(path, name), value)
+	}
+}
+
+// value records a value found at path, and what it holds.
+func (s *schemaInferrer) value(path string, value interface{}) {
+	value = codec.RestoreValue(value)
+	if raw, ok := value.(json.RawMessage); ok {
+		if err := json.Unmarshal(raw, &value); err != nil {
+			value = string(raw)
+		}
+	}
+
+	field := s.fieldsByPath[path]
+	if field == nil {
+		field = &FieldSchema{Path: path, Types: make(map[string]int)}
+		s.fieldsByPath[path] = field
+	}
+	field.Count++
+
+	switch v := value.(type) {
+	case nil:
+		field.Types[SchemaNull]++
+	case string:
+		field.Types[SchemaString]++
+	case bool:
+		field.Types[SchemaBool]++
+	case float64, json.Number:
+		field.Types[SchemaNumber]++
+	case []interface{}:
+		field.Types[SchemaArray]++
+		for _, elem := range v {
+			s.value(path+"[]", elem)
+		}
+	case map[string]interface{}:
+		if _, isBlob := blob.FromMap(v); isBlob {
+			field.Types[SchemaBlob]++
+			return
+		}
+		field.Types[SchemaObject]++
+		s.object(path, v)
+	default:
+		field.Types[fmt.Sprintf("%T", v)]++
+	}
+}
+
+// fields returns the fields found, ordered by path.
+func (s *schemaInferrer) fields() []FieldSchema {
+	fields := make([]FieldSchema, 0, len(s.fieldsByPath))
+	for path, field := range s.fieldsByPath {
+		if parent, ok := parentSchemaPath(path); ok {
+			field.Optional = field.Count < s.objects[parent]
+		}
+		fields = append(fields, *field)
+	}
+
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Path < fields[j].Path })
+	return fields
+}
+
+func joinSchemaPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// parentSchemaPath returns the path of the object holding the field at
+// path. Array elements and the root value have none.
+func parentSchemaPath(path string) (string, bool) {
+	if path == "" || strings.HasSuffix(path, "[]") {
+		return "", false
+	}
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[:i], true
+	}
+	return "", true
+}
@@ -0,0 +1,93 @@
+package stow
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aigotowork/stow/internal/core"
+	"github.com/aigotowork/stow/internal/fsutil"
+)
+
+// clockSkewTolerance is how far a record's timestamp may fall behind the
+// newest one in the namespace before a clock skew warning is logged. Clock
+// slewing by NTP stays well below it; stepped clocks don't.
+const clockSkewTolerance = time.Second
+
+// writeSeq hands out the sequence numbers of a namespace's records and
+// watches their timestamps for a clock stepping back.
+type writeSeq struct {
+	mu     sync.Mutex
+	loaded bool
+	last   uint64
+
+	// newest is the newest timestamp written; skewed is set while writes
+	// are behind it, so the warning is logged once per step back
+	newest time.Time
+	skewed bool
+}
+
+// stamp gives a new record the namespace's next sequence number and warns
+// when its timestamp is behind records already written. Caller must hold
+// the record's key lock, but not ns.mu.
+func (ns *namespace) stamp(meta *core.Meta) {
+	s := &ns.seq
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.loaded {
+		s.last, s.newest = ns.scanSeq()
+		s.loaded = true
+	}
+
+	s.last++
+	meta.Seq = s.last
+
+	switch {
+	case meta.Timestamp.Before(s.newest.Add(-clockSkewTolerance)):
+		if !s.skewed {
+			ns.logger.Warn("clock skew detected: record timestamp is behind records already written",
+				Field{"namespace", ns.name},
+				Field{"key", meta.Key},
+				Field{"timestamp", meta.Timestamp},
+				Field{"newest", s.newest})
+			s.skewed = true
+		}
+	case meta.Timestamp.After(s.newest):
+		s.newest = meta.Timestamp
+		s.skewed = false
+	}
+}
+
+// scanSeq returns the highest sequence number and newest timestamp among
+// the latest records of all keys, read once before the first write.
+func (ns *namespace) scanSeq() (uint64, time.Time) {
+	ns.mu.RLock()
+	var files []string
+	for _, key := range ns.keyMapper.ListAll() {
+		if file := ns.keyMapper.FindExact(key); file != "" {
+			files = append(files, file)
+		}
+	}
+	ns.mu.RUnlock()
+
+	var last uint64
+	var newest time.Time
+	for _, file := range files {
+		filePath, err := fsutil.SafeJoin(ns.path, file)
+		if err != nil {
+			continue
+		}
+		ns.decoder.ReadReverse(filePath, func(record *core.Record, _ error) bool {
+			if record == nil {
+				return true
+			}
+			last = max(last, record.Meta.Seq)
+			if record.Meta.Timestamp.After(newest) {
+				newest = record.Meta.Timestamp
+			}
+			return false
+		})
+	}
+
+	return last, newest
+}
@@ -0,0 +1,167 @@
+package stow
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/aigotowork/stow/internal/blob"
+	"github.com/aigotowork/stow/internal/core"
+	"github.com/aigotowork/stow/internal/fsutil"
+	"github.com/aigotowork/stow/internal/sign"
+)
+
+// applySigning makes the encoder sign new records as the config says. The
+// Ed25519 public key is derived from the signing key and persisted the first
+// time; opening with a key that doesn't match it fails.
+func (ns *namespace) applySigning() error {
+	signing := ns.cfg().Signing
+	if signing.Algorithm == "" {
+		ns.encoder.SetSigner(nil)
+		return nil
+	}
+
+	key, err := ns.signingKey(signing)
+	if err != nil {
+		return err
+	}
+
+	derived := key.PublicKey()
+	if signing.PublicKey != nil && signing.Key != nil && !bytes.Equal(signing.PublicKey, derived) {
+		return fmt.Errorf("%w: signing key doesn't match the public key of namespace %q", ErrInvalidConfig, ns.name)
+	}
+
+	if signing.PublicKey == nil && derived != nil {
+		ns.configMu.Lock()
+		ns.config.Signing.PublicKey = derived
+		ns.configMu.Unlock()
+
+		if !ns.readOnly {
+			if err := ns.saveConfig(); err != nil {
+				return err
+			}
+		}
+	}
+
+	ns.encoder.SetSigner(key)
+	return nil
+}
+
+// signingKey builds the key of a signing config.
+func (ns *namespace) signingKey(signing SigningConfig) (*sign.Key, error) {
+	key, err := sign.NewKey(string(signing.Algorithm), signing.Key, signing.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	return key, nil
+}
+
+// Verify checks the signature of every record in the namespace and reports
+// records that were changed since they were signed, records without a
+// signature and lines that aren't records, and checks the blob files of the
+// latest version of every key. Records removed entirely can't be detected,
+// as compaction removes old versions too.
+func (ns *namespace) Verify() (VerifyResult, error) {
+	result := VerifyResult{}
+
+	signing := ns.cfg().Signing
+	if signing.Algorithm == "" {
+		return result, fmt.Errorf("%w: namespace %q is not signed", ErrInvalidConfig, ns.name)
+	}
+	key, err := ns.signingKey(signing)
+	if err != nil {
+		return result, err
+	}
+
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+
+	files, err := fsutil.FindFiles(ns.path, "*.jsonl")
+	if err != nil {
+		return result, err
+	}
+
+	for _, filePath := range files {
+		// Skip blobs, indexes and the namespace's own files
+		if !isKeyFilePath(filePath) {
+			continue
+		}
+
+		if err := ns.verifyFile(filePath, key, &result); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// verifyFile checks the records of one file into result.
+func (ns *namespace) verifyFile(filePath string, key *sign.Key, result *VerifyResult) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	fileName := filepath.Base(filePath)
+	reader := bufio.NewReader(f)
+
+	// Blobs of older versions may have been collected by GC
+	var latest *core.Record
+
+	for lineNum := 1; ; lineNum++ {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return fmt.Errorf("error reading file: %w", readErr)
+		}
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			meta, signed, err := ns.decoder.VerifyLine(line, key)
+			switch {
+			case meta == nil:
+				result.Unreadable = appendVersion(result.Unreadable, fileName, lineNum)
+			case errors.Is(err, sign.ErrNoKey):
+				return ErrSigningKeyRequired
+			case err != nil:
+				// Data that can't be decrypted was changed too
+				result.Invalid = appendVersion(result.Invalid, meta.Key, meta.Version)
+			case !signed:
+				result.Unsigned = appendVersion(result.Unsigned, meta.Key, meta.Version)
+			default:
+				result.Verified++
+			}
+			if err == nil && meta != nil {
+				if record, decodeErr := ns.decoder.Decode(line); decodeErr == nil {
+					latest = record
+				}
+			}
+		}
+
+		if readErr == io.EOF {
+			if latest != nil && !latest.Meta.IsDelete() && !ns.blobsIntact(latest) {
+				result.Blobs = appendVersion(result.Blobs, latest.Meta.Key, latest.Meta.Version)
+			}
+			return nil
+		}
+	}
+}
+
+// blobsIntact reports whether every blob a record references is in the blob
+// directory with the content its reference hashes.
+func (ns *namespace) blobsIntact(record *core.Record) bool {
+	return walkBlobRefs(record.Data, func(ref *blob.Reference, _ bool) error {
+		return ns.blobManager.Verify(ref)
+	}) == nil
+}
+
+func appendVersion(m map[string][]int, key string, version int) map[string][]int {
+	if m == nil {
+		m = make(map[string][]int)
+	}
+	m[key] = append(m[key], version)
+	return m
+}
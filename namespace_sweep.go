@@ -0,0 +1,135 @@
+package stow
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/aigotowork/stow/internal/core"
+)
+
+// sweepReason is the delete reason of keys removed by Sweep.
+const sweepReason = "sweep"
+
+// Sweep calls fn for every existing key in ascending order and applies the
+// action it returns. fn runs without locks held, so it may call back into
+// the namespace; a key written after it was read is not deleted (counted in
+// SweepResult.Skipped).
+//
+// Example:
+//
+//	cutoff := time.Now().AddDate(0, 0, -90)
+//	ns.Sweep(func(key string, meta stow.KeyMeta, peek func(interface{}) error) stow.SweepAction {
+//		if strings.HasPrefix(key, "draft:") && meta.Updated.Before(cutoff) {
+//			return stow.SweepDelete
+//		}
+//		return stow.SweepKeep
+//	})
+func (ns *namespace) Sweep(fn SweepFunc) (SweepResult, error) {
+	result := SweepResult{}
+	if err := ns.checkWritable(); err != nil {
+		return result, err
+	}
+
+	ns.mu.RLock()
+	keys := ns.keyMapper.ListAll()
+	ns.mu.RUnlock()
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		latest, meta, err := ns.sweepMeta(key)
+		if err != nil {
+			return result, err
+		}
+		if latest == nil {
+			continue
+		}
+
+		peek := func(target interface{}) error {
+			if err := ns.expandRecord(latest); err != nil {
+				return err
+			}
+			return ns.unmarshaler.Unmarshal(withoutDerived(latest.Data), target)
+		}
+
+		result.Scanned++
+		switch fn(key, meta, peek) {
+		case SweepDelete:
+			deleted, err := ns.sweepDelete(key, meta.Version)
+			if err != nil {
+				return result, fmt.Errorf("key %s: %w", key, err)
+			}
+			if deleted {
+				result.Deleted++
+			} else {
+				result.Skipped++
+			}
+
+		case SweepCompact:
+			ns.mu.Lock()
+			err := ns.compactKey(key)
+			ns.mu.Unlock()
+			if err != nil {
+				return result, fmt.Errorf("key %s: %w", key, err)
+			}
+			result.Compacted++
+		}
+	}
+
+	ns.disk.rescan()
+	return result, nil
+}
+
+// sweepMeta reads the latest visible record of a key and describes it.
+// Returns a nil record for deleted keys.
+func (ns *namespace) sweepMeta(key string) (*core.Record, KeyMeta, error) {
+	ns.mu.RLock()
+	filePath, err := ns.getFilePath(key, false)
+	ns.mu.RUnlock()
+	if err != nil {
+		return nil, KeyMeta{}, nil
+	}
+
+	records, err := ns.decoder.ReadAll(filePath)
+	if err != nil {
+		return nil, KeyMeta{}, fmt.Errorf("failed to read records: %w", err)
+	}
+
+	latest := latestVisible(records, time.Now())
+	if latest == nil || latest.Meta.IsDelete() {
+		return nil, KeyMeta{}, nil
+	}
+
+	meta := KeyMeta{
+		Version:  latest.Meta.Version,
+		Versions: len(records),
+		Created:  records[0].Meta.Timestamp,
+		Updated:  latest.Meta.Timestamp,
+	}
+	if info, err := os.Stat(filePath); err == nil {
+		meta.FileSize = info.Size()
+	}
+
+	return latest, meta, nil
+}
+
+// sweepDelete deletes a key unless a record was written after version.
+func (ns *namespace) sweepDelete(key string, version int) (bool, error) {
+	keyLock := ns.getKeyLock(key)
+	keyLock.Lock()
+	defer keyLock.Unlock()
+
+	ns.mu.RLock()
+	filePath, err := ns.getFilePath(key, false)
+	ns.mu.RUnlock()
+	if err != nil {
+		return false, nil
+	}
+
+	if ns.getNextVersion(filePath) != version+1 {
+		return false, nil
+	}
+
+	return true, ns.appendDelete(key, sweepReason, "")
+}
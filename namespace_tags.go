@@ -0,0 +1,166 @@
+package stow
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Tag adds labels to a key. Tags live in the namespace's _tags.json, apart
+// from the key's records, so tagging never creates a version. They are kept
+// when the key is deleted; remove them with Untag.
+func (ns *namespace) Tag(key string, tags ...string) error {
+	if err := ns.checkWritable(); err != nil {
+		return err
+	}
+	if err := validateTags(tags); err != nil {
+		return err
+	}
+
+	// Only existing keys can be tagged
+	ns.mu.RLock()
+	_, err := ns.getFilePath(key, false)
+	ns.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	ns.tagsMu.Lock()
+	defer ns.tagsMu.Unlock()
+
+	tagged, err := ns.loadTags()
+	if err != nil {
+		return err
+	}
+
+	current := tagged[key]
+	changed := false
+	for _, tag := range tags {
+		if !slices.Contains(current, tag) {
+			current = append(current, tag)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	sort.Strings(current)
+	tagged[key] = current
+
+	return ns.saveTags(tagged)
+}
+
+// Untag removes labels from a key. Removing a tag the key doesn't have is a no-op.
+func (ns *namespace) Untag(key string, tags ...string) error {
+	if err := ns.checkWritable(); err != nil {
+		return err
+	}
+
+	ns.tagsMu.Lock()
+	defer ns.tagsMu.Unlock()
+
+	tagged, err := ns.loadTags()
+	if err != nil {
+		return err
+	}
+
+	var kept []string
+	for _, tag := range tagged[key] {
+		if !slices.Contains(tags, tag) {
+			kept = append(kept, tag)
+		}
+	}
+	if len(kept) == len(tagged[key]) {
+		return nil
+	}
+
+	if len(kept) == 0 {
+		delete(tagged, key)
+	} else {
+		tagged[key] = kept
+	}
+
+	return ns.saveTags(tagged)
+}
+
+// Tags returns the tags of a key in ascending order.
+func (ns *namespace) Tags(key string) ([]string, error) {
+	ns.tagsMu.Lock()
+	defer ns.tagsMu.Unlock()
+
+	tagged, err := ns.loadTags()
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]string(nil), tagged[key]...), nil
+}
+
+// ListByTag returns the keys carrying tag in ascending order.
+func (ns *namespace) ListByTag(tag string) ([]string, error) {
+	ns.tagsMu.Lock()
+	defer ns.tagsMu.Unlock()
+
+	tagged, err := ns.loadTags()
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for key, tags := range tagged {
+		if slices.Contains(tags, tag) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// validateTags rejects empty tags and tags with surrounding whitespace.
+func validateTags(tags []string) error {
+	for _, tag := range tags {
+		if tag == "" || strings.TrimSpace(tag) != tag {
+			return fmt.Errorf("invalid tag: %q", tag)
+		}
+	}
+	return nil
+}
+
+// loadTags reads _tags.json (caller must hold tagsMu).
+// A missing file means no tags.
+func (ns *namespace) loadTags() (map[string][]string, error) {
+	tagsPath := filepath.Join(ns.path, "_tags.json")
+
+	data, err := os.ReadFile(tagsPath)
+	if os.IsNotExist(err) {
+		return make(map[string][]string), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tags: %w", err)
+	}
+
+	tagged := make(map[string][]string)
+	if err := json.Unmarshal(data, &tagged); err != nil {
+		return nil, fmt.Errorf("failed to parse tags: %w", err)
+	}
+
+	return tagged, nil
+}
+
+// saveTags writes _tags.json (caller must hold tagsMu).
+func (ns *namespace) saveTags(tagged map[string][]string) error {
+	tagsPath := filepath.Join(ns.path, "_tags.json")
+
+	data, err := json.MarshalIndent(tagged, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ns.writeFile(tagsPath, data)
+}
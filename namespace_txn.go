@@ -0,0 +1,372 @@
+package stow
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/aigotowork/stow/internal/blob"
+	"github.com/aigotowork/stow/internal/codec"
+	"github.com/aigotowork/stow/internal/core"
+	"github.com/aigotowork/stow/internal/fsutil"
+)
+
+// Txn stages writes to several keys of a namespace, which Namespace.Txn
+// commits together.
+type Txn interface {
+	// Get reads a key as the transaction sees it: its staged value if the
+	// transaction wrote it, ErrNotFound if it deleted it, otherwise the
+	// key's latest value.
+	Get(key string, target interface{}) error
+
+	// Put stages a new version of key. The value is marshaled right away,
+	// so changing it afterwards has no effect.
+	Put(key string, value interface{}) error
+
+	// Delete stages the deletion of key, which must exist when the
+	// transaction commits.
+	Delete(key string) error
+}
+
+// txnWrite is the staged write of one key.
+type txnWrite struct {
+	data   map[string]interface{} // nil for a delete
+	delete bool
+}
+
+// txn is the Txn passed to the function of Namespace.Txn.
+type txn struct {
+	ns     *namespace
+	writes map[string]*txnWrite
+	blobs  []*blob.Reference // stored while staging, removed on rollback
+	done   bool
+}
+
+func (tx *txn) Get(key string, target interface{}) error {
+	write, ok := tx.writes[key]
+	if !ok {
+		return tx.ns.Get(key, target)
+	}
+	if write.delete {
+		return ErrNotFound
+	}
+	return tx.ns.unmarshaler.Unmarshal(withoutDerived(write.data), target)
+}
+
+func (tx *txn) Put(key string, value interface{}) error {
+	if tx.done {
+		return errors.New("transaction already finished")
+	}
+	if !tx.ns.validKey(key) {
+		return fmt.Errorf("invalid key: %s", key)
+	}
+
+	ns := tx.ns
+	options := ns.putOptions(value, nil)
+	data, refs, err := ns.marshaler.Marshal(value, ns.marshalOptions(options))
+	tx.blobs = append(tx.blobs, refs...)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %w", err)
+	}
+	if err := ns.normalize(data); err != nil {
+		return err
+	}
+	if fields := codec.IndexFields(value); len(fields) > 0 {
+		if err := ns.ensureIndexes(fields); err != nil {
+			return err
+		}
+	}
+	tx.blobs = append(tx.blobs, ns.deriveBlobs(data)...)
+
+	tx.writes[key] = &txnWrite{data: data}
+	return nil
+}
+
+func (tx *txn) Delete(key string) error {
+	if tx.done {
+		return errors.New("transaction already finished")
+	}
+	if !tx.ns.validKey(key) {
+		return fmt.Errorf("invalid key: %s", key)
+	}
+
+	tx.writes[key] = &txnWrite{delete: true}
+	return nil
+}
+
+// Txn runs fn and commits the writes it stages as one: either every key
+// gets its new record or none does, even across a crash. If fn returns an
+// error, nothing is written and the error is returned.
+//
+// Keys are locked only while the transaction commits, so values read
+// through tx may change before then, and writes made through the namespace
+// while fn runs are not part of the transaction. Each Put appends a
+// version: SkipUnchanged, CoalesceWindow and noversion fields don't apply.
+// Like Put, it returns ErrRecordTooLarge warnings for truncated values.
+//
+// Example:
+//
+//	err := ns.Txn(func(tx stow.Txn) error {
+//		var category Category
+//		if err := tx.Get("category:go", &category); err != nil {
+//			return err
+//		}
+//		category.Posts++
+//		if err := tx.Put("category:go", category); err != nil {
+//			return err
+//		}
+//		return tx.Put("post:42", post)
+//	})
+func (ns *namespace) Txn(fn func(tx Txn) error) error {
+	if err := ns.checkWritable(); err != nil {
+		return err
+	}
+
+	tx := &txn{ns: ns, writes: make(map[string]*txnWrite)}
+	err := fn(tx)
+	tx.done = true
+	if err == nil {
+		err = ns.commitTxn(tx)
+	}
+	if err != nil {
+		for _, ref := range tx.blobs {
+			ns.blobManager.Delete(ref)
+		}
+		return err
+	}
+	return nil
+}
+
+// txnRecord is a record of a transaction with the file it is appended to.
+type txnRecord struct {
+	key        string
+	filePath   string
+	sizeBefore int64
+	existed    bool
+	record     *core.Record
+	cached     map[string]interface{} // the value readers get
+}
+
+// commitTxn appends the records of a transaction under a single intent,
+// removing the appended ones again if any append fails.
+func (ns *namespace) commitTxn(tx *txn) error {
+	if len(tx.writes) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(tx.writes))
+	for key := range tx.writes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Key locks are taken in ascending key order, like Rename's
+	for _, key := range keys {
+		lock := ns.getKeyLock(key)
+		lock.Lock()
+		defer lock.Unlock()
+	}
+
+	var iostats IOStats
+	defer ns.io.add(&ns.io.put, &iostats)
+
+	var warnings []error
+	records := make([]*txnRecord, 0, len(keys))
+	for _, key := range keys {
+		write := tx.writes[key]
+
+		ns.mu.RLock()
+		filePath, err := ns.getFilePath(key, !write.delete)
+		ns.mu.RUnlock()
+		if err != nil {
+			return fmt.Errorf("key %s: %w", key, err)
+		}
+
+		existed := fsutil.FileExists(filePath)
+		sizeBefore := fsutil.FileSize(filePath)
+		iostats.read(sizeBefore)
+		version := ns.getNextVersion(filePath)
+
+		var record *core.Record
+		if write.delete {
+			record = core.NewDeleteRecord(key, version)
+		} else {
+			record = core.NewPutRecord(key, version, write.data)
+		}
+		ns.stamp(record.Meta)
+		if err := ns.linkRecord(filePath, record); err != nil {
+			return fmt.Errorf("key %s: %w", key, err)
+		}
+		// Spilled records are cached in their expanded form
+		cached := write.data
+		if !write.delete {
+			spilled, warning, err := ns.limitRecordSize(record)
+			if err != nil {
+				return fmt.Errorf("key %s: %w", key, err)
+			}
+			if warning != nil {
+				warnings = append(warnings, fmt.Errorf("key %s: %w", key, warning))
+			}
+			if spilled != nil {
+				tx.blobs = append(tx.blobs, spilled)
+			} else {
+				cached = record.Data
+			}
+		}
+
+		records = append(records, &txnRecord{
+			key:        key,
+			filePath:   filePath,
+			sizeBefore: sizeBefore,
+			existed:    existed,
+			record:     record,
+			cached:     cached,
+		})
+	}
+
+	// Enforce the store's hard cap
+	var writeSize int64
+	if ns.disk != nil {
+		for _, r := range records {
+			line, err := ns.encoder.Encode(r.record)
+			if err != nil {
+				return fmt.Errorf("failed to encode record: %w", err)
+			}
+			writeSize += int64(len(line))
+		}
+		for _, ref := range tx.blobs {
+			writeSize += ref.Size
+		}
+		if err := ns.disk.check(writeSize); err != nil {
+			return err
+		}
+	}
+
+	// One intent covers every record, so a crash midway is rolled back on
+	// the next open
+	writes := make([]intentWrite, len(records))
+	for i, r := range records {
+		writes[i] = intentWrite{Key: r.key, File: filepath.Base(r.filePath), Version: r.record.Meta.Version}
+	}
+	intent, err := ns.intents.begin(intentEntry{
+		Op:     intentTxn,
+		Writes: writes,
+		Blobs:  ns.intentBlobs(tx.blobs),
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, r := range records {
+		if err := ns.encoder.Append(r.filePath, r.record); err != nil {
+			// Includes the record that may have been written in part
+			for _, written := range records[:i+1] {
+				ns.undoTxnAppend(written)
+			}
+			ns.intents.done(intent)
+			return fmt.Errorf("key %s: failed to append record: %w", r.key, err)
+		}
+	}
+	ns.intents.done(intent)
+
+	iostats.wroteBlobs(tx.blobs...)
+	for _, r := range records {
+		appended := fsutil.FileSize(r.filePath) - r.sizeBefore
+		iostats.wrote(appended)
+		iostats.LogicalBytes += appended
+	}
+	ns.disk.add(writeSize)
+
+	ns.mu.Lock()
+	for _, r := range records {
+		ns.keyMapper.Add(r.key, filepath.Base(r.filePath))
+	}
+	ns.mu.Unlock()
+
+	for _, r := range records {
+		op := changePut
+		if r.record.Meta.IsDelete() {
+			op = changeDelete
+		}
+		ns.afterWrite(op, r.key, r.filePath, r.record.Meta.Version)
+
+		if r.record.Meta.IsDelete() {
+			ns.cache.Delete(r.key)
+		} else {
+			ns.cache.Set(r.key, r.cached)
+		}
+
+		if ns.cfg().AutoCompact {
+			key, filePath := r.key, r.filePath
+			ns.resources.spawn(func() { ns.compactIfNeeded(key, filePath) })
+		}
+	}
+
+	return errors.Join(warnings...)
+}
+
+// undoTxnAppend cuts a key file back to its size before the transaction,
+// removing it if the transaction created it.
+func (ns *namespace) undoTxnAppend(r *txnRecord) {
+	var err error
+	if r.existed {
+		err = os.Truncate(r.filePath, r.sizeBefore)
+	} else {
+		err = os.Remove(r.filePath)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		ns.logger.Warn("failed to roll back transaction", Field{"key", r.key}, Field{"error", err})
+	}
+}
+
+// rollbackTxn undoes an unfinished transaction: unless every key file holds
+// its record, the records that were written are removed again.
+func (ns *namespace) rollbackTxn(entry intentEntry) error {
+	type found struct {
+		write    intentWrite
+		filePath string
+		records  []*core.Record
+		index    int
+	}
+
+	var written []found
+	for _, write := range entry.Writes {
+		filePath, err := fsutil.SafeJoin(ns.path, write.File)
+		if err != nil {
+			return err
+		}
+		if !fsutil.FileExists(filePath) {
+			continue
+		}
+		records, err := ns.decoder.ReadAll(filePath)
+		if err != nil {
+			return err
+		}
+		for i, record := range records {
+			if record.Meta.Key == write.Key && record.Meta.Version == write.Version {
+				written = append(written, found{write: write, filePath: filePath, records: records, index: i})
+				break
+			}
+		}
+	}
+	if len(written) == len(entry.Writes) {
+		return nil // Committed
+	}
+
+	for _, f := range written {
+		kept := append(f.records[:f.index:f.index], f.records[f.index+1:]...)
+		if len(kept) == 0 {
+			if err := os.Remove(f.filePath); err != nil {
+				return err
+			}
+			ns.keyMapper.Remove(f.write.Key)
+		} else if err := ns.rewriteKeyFile(f.filePath, kept); err != nil {
+			return err
+		}
+		ns.cache.Delete(f.write.Key)
+	}
+	ns.logger.Warn("rolled back unfinished transaction", Field{"namespace", ns.name}, Field{"keys", len(entry.Writes)})
+	return nil
+}
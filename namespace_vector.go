@@ -0,0 +1,90 @@
+package stow
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/aigotowork/stow/internal/blob"
+	"github.com/aigotowork/stow/internal/codec"
+)
+
+// SimilaritySearch performs a brute-force nearest-neighbor search over a vector field.
+// Every live key is visited once; vectors stored as blobs are decoded on demand.
+func (ns *namespace) SimilaritySearch(field string, query []float32, k int) ([]SimilarityResult, error) {
+	if len(query) == 0 {
+		return nil, fmt.Errorf("query vector is empty")
+	}
+	if k <= 0 {
+		return nil, nil
+	}
+
+	ns.mu.RLock()
+	keys := ns.keyMapper.ListAll()
+	ns.mu.RUnlock()
+
+	var results []SimilarityResult
+	for _, key := range keys {
+		data, err := ns.latestData(key)
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				continue // Deleted key
+			}
+			return nil, err
+		}
+
+		value, err := codec.GetPath(data, field)
+		if err != nil {
+			continue // Field not present in this record
+		}
+
+		vec, ok := ns.vectorValue(key, value)
+		if !ok || len(vec) != len(query) {
+			continue
+		}
+
+		results = append(results, SimilarityResult{
+			Key:   key,
+			Score: codec.CosineSimilarity(query, vec),
+		})
+	}
+
+	// Highest score first, key order for ties
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Key < results[j].Key
+	})
+
+	if len(results) > k {
+		results = results[:k]
+	}
+
+	return results, nil
+}
+
+// vectorValue extracts a vector from an inline array or an encoded vector blob.
+func (ns *namespace) vectorValue(key string, value interface{}) ([]float32, bool) {
+	if m, ok := value.(map[string]interface{}); ok {
+		ref, isBlobRef := blob.FromMap(m)
+		if !isBlobRef || ref.MimeType != codec.VectorMimeType {
+			return nil, false
+		}
+
+		data, err := ns.blobManager.LoadBytes(ref)
+		if err != nil {
+			ns.logger.Warn("failed to load vector blob", Field{"key", key}, Field{"error", err})
+			return nil, false
+		}
+
+		vec, err := codec.DecodeVector(data)
+		if err != nil {
+			ns.logger.Warn("failed to decode vector blob", Field{"key", key}, Field{"error", err})
+			return nil, false
+		}
+		return vec, true
+	}
+
+	return codec.ToVector(value)
+}
@@ -0,0 +1,173 @@
+package stow
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/aigotowork/stow/internal/blob"
+	"github.com/aigotowork/stow/internal/core"
+	"github.com/aigotowork/stow/internal/fsutil"
+)
+
+// AppendVersions appends values to the history of key as consecutive
+// versions, oldest first, with a single file write and sync, e.g. to import
+// the audit history of a record instead of replaying it Put by Put. The
+// last value becomes the latest version.
+//
+// Every value appends a version: SkipUnchanged, CoalesceWindow and
+// noversion fields don't apply. Either all versions are written or none;
+// like Put, it returns ErrRecordTooLarge warnings for truncated values.
+//
+// Example:
+//
+//	err := ns.AppendVersions("order:42", []interface{}{created, paid, shipped})
+func (ns *namespace) AppendVersions(key string, values []interface{}) error {
+	return ns.timer.run(opNamePut, ns.name, key, func() error {
+		return ns.appendVersions(key, values)
+	})
+}
+
+func (ns *namespace) appendVersions(key string, values []interface{}) error {
+	if err := ns.checkWritable(); err != nil {
+		return err
+	}
+	if !ns.validKey(key) {
+		return fmt.Errorf("invalid key: %s", key)
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	keyLock := ns.getKeyLock(key)
+	keyLock.Lock()
+	defer keyLock.Unlock()
+
+	ns.mu.RLock()
+	filePath, err := ns.getFilePath(key, true)
+	ns.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	// Blobs are removed again unless every record is written
+	var blobRefs []*blob.Reference
+	written := false
+	defer func() {
+		if !written {
+			for _, ref := range blobRefs {
+				ns.blobManager.Delete(ref)
+			}
+		}
+	}()
+
+	var iostats IOStats
+	defer ns.io.add(&ns.io.put, &iostats)
+
+	var warnings []error
+	sizeBefore := fsutil.FileSize(filePath)
+	iostats.read(sizeBefore)
+	version := ns.getNextVersion(filePath)
+	records := make([]*core.Record, len(values))
+	datas := make([]map[string]interface{}, len(values))
+	for i, value := range values {
+		options := ns.putOptions(value, nil)
+		data, refs, err := ns.marshaler.Marshal(value, ns.marshalOptions(options))
+		blobRefs = append(blobRefs, refs...)
+		if err != nil {
+			return fmt.Errorf("value %d: failed to marshal: %w", i, err)
+		}
+		if err := ns.normalize(data); err != nil {
+			return fmt.Errorf("value %d: %w", i, err)
+		}
+		blobRefs = append(blobRefs, ns.deriveBlobs(data)...)
+
+		record := core.NewPutRecord(key, version+i, data)
+		ns.stamp(record.Meta)
+		spilled, warning, err := ns.limitRecordSize(record)
+		if err != nil {
+			return fmt.Errorf("value %d: %w", i, err)
+		}
+		if warning != nil {
+			warnings = append(warnings, fmt.Errorf("value %d: %w", i, warning))
+		}
+		if spilled != nil {
+			blobRefs = append(blobRefs, spilled)
+		}
+		records[i] = record
+		datas[i] = data
+	}
+
+	// Chain the first record to the file, the others to each other
+	if err := ns.linkRecord(filePath, records[0]); err != nil {
+		return err
+	}
+	if err := ns.rechain(records, 1); err != nil {
+		return err
+	}
+
+	// Enforce the store's hard cap
+	var writeSize int64
+	if ns.disk != nil {
+		for _, record := range records {
+			line, err := ns.encoder.Encode(record)
+			if err != nil {
+				return fmt.Errorf("failed to encode record: %w", err)
+			}
+			writeSize += int64(len(line))
+		}
+		for _, ref := range blobRefs {
+			writeSize += ref.Size
+		}
+		if err := ns.disk.check(writeSize); err != nil {
+			return err
+		}
+	}
+
+	var intent int64
+	if len(blobRefs) > 0 {
+		intent, err = ns.intents.begin(intentEntry{
+			Op:      intentPut,
+			Key:     key,
+			File:    filepath.Base(filePath),
+			Version: version,
+			Count:   len(records),
+			Blobs:   ns.intentBlobs(blobRefs),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = ns.encoder.AppendAll(filePath, records)
+	ns.intents.done(intent)
+	if err != nil {
+		return fmt.Errorf("failed to append records: %w", err)
+	}
+	written = true
+	appended := fsutil.FileSize(filePath) - sizeBefore
+	iostats.wroteBlobs(blobRefs...)
+	iostats.wrote(appended)
+	iostats.LogicalBytes += appended
+
+	ns.mu.Lock()
+	ns.keyMapper.Add(key, filepath.Base(filePath))
+	ns.mu.Unlock()
+
+	last := len(records) - 1
+	ns.disk.add(writeSize)
+	ns.afterWrite(changePut, key, filePath, version+last)
+
+	if records[last].Meta.IsVisible(time.Now()) {
+		ns.cache.Set(key, datas[last])
+	} else {
+		ns.cache.Delete(key)
+	}
+
+	if ns.cfg().AutoCompact {
+		ns.resources.spawn(func() { ns.compactIfNeeded(key, filePath) })
+	}
+
+	return errors.Join(warnings...)
+}
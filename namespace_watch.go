@@ -0,0 +1,437 @@
+package stow
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/aigotowork/stow/internal/index"
+)
+
+// DefaultWatchInterval is how often an ExternalWatcher checks the namespace directory.
+const DefaultWatchInterval = 500 * time.Millisecond
+
+// DefaultWatchBuffer is the number of undelivered events a watcher keeps.
+const DefaultWatchBuffer = 64
+
+// ExternalChange describes a data file changed by another process or by hand.
+type ExternalChange struct {
+	// Key is the key stored in the file ("" when no record could be decoded)
+	Key string
+
+	// File is the data file name within the namespace
+	File string
+
+	// Type is what happened to the file
+	Type ExternalChangeType
+
+	// Err is set when the file no longer decodes cleanly (wraps ErrCorruptedData).
+	// Valid records are still served; invalid lines are skipped as usual.
+	Err error
+}
+
+// WatchOption configures WatchExternalChanges and WatchChanges.
+type WatchOption func(*watchOptions)
+
+type watchOptions struct {
+	interval time.Duration
+	buffer   int
+}
+
+func newWatchOptions(opts []WatchOption) *watchOptions {
+	options := &watchOptions{interval: DefaultWatchInterval, buffer: DefaultWatchBuffer}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+func (o *watchOptions) validate() error {
+	if o.interval <= 0 {
+		return fmt.Errorf("%w: watch interval must be positive", ErrInvalidConfig)
+	}
+	if o.buffer < 0 {
+		return fmt.Errorf("%w: watch buffer must be non-negative", ErrInvalidConfig)
+	}
+	return nil
+}
+
+// WithWatchInterval sets how often the namespace directory or the changes
+// log is checked. Defaults to DefaultWatchInterval.
+func WithWatchInterval(interval time.Duration) WatchOption {
+	return func(o *watchOptions) {
+		o.interval = interval
+	}
+}
+
+// WithWatchBuffer sets how many undelivered events the Events channel
+// holds. Defaults to DefaultWatchBuffer.
+func WithWatchBuffer(n int) WatchOption {
+	return func(o *watchOptions) {
+		o.buffer = n
+	}
+}
+
+// fileStamp identifies one state of a data file.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// ExternalWatcher detects data files edited outside this namespace handle,
+// invalidates what the namespace cached for them and reports them on Events.
+type ExternalWatcher struct {
+	ns       *namespace
+	interval time.Duration
+	events   chan ExternalChange
+
+	mu    sync.Mutex
+	files map[string]fileStamp
+
+	// held are changed files that didn't decode, with the change to report:
+	// editors saving in place truncate a file before writing it, so such
+	// a change is only reported once the file stayed the same for an
+	// interval
+	held map[string]ExternalChangeType
+
+	// Events were dropped since the last ExternalOverflow was sent
+	overflowed bool
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// WatchExternalChanges starts watching the namespace's JSONL files for
+// changes made by people or other tools while the store is open.
+//
+// Changed files are re-read: new keys become visible, removed files disappear,
+// cached values are dropped and every line is revalidated. Writes made through
+// this namespace are not reported. The watcher polls the directory, so a
+// change is seen within one interval; close it with Close (Store.Close closes
+// all watchers). A file that doesn't decode is only reported once it stayed
+// the same for an interval, so an editor caught saving in place, between
+// truncating the file and writing it, isn't reported as corrupting it.
+func (ns *namespace) WatchExternalChanges(opts ...WatchOption) (*ExternalWatcher, error) {
+	options := newWatchOptions(opts)
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+
+	files, err := ns.stampDataFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan namespace: %w", err)
+	}
+
+	w := &ExternalWatcher{
+		ns:       ns,
+		interval: options.interval,
+		events:   make(chan ExternalChange, options.buffer),
+		files:    files,
+		held:     make(map[string]ExternalChangeType),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	ns.watchMu.Lock()
+	ns.watchers = append(ns.watchers, w)
+	ns.watchMu.Unlock()
+
+	if !ns.resources.spawn(w.run) {
+		close(w.done)
+		w.Close()
+		return nil, ErrGoroutineLimit
+	}
+	return w, nil
+}
+
+// Events returns the channel of detected changes. It is closed by Close.
+// Events are dropped (and logged) while the channel is full; the next event
+// delivered is then an ExternalOverflow.
+func (w *ExternalWatcher) Events() <-chan ExternalChange {
+	return w.events
+}
+
+// Close stops watching and closes the Events channel.
+func (w *ExternalWatcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.stop)
+		<-w.done
+		close(w.events)
+
+		w.ns.watchMu.Lock()
+		for i, other := range w.ns.watchers {
+			if other == w {
+				w.ns.watchers = append(w.ns.watchers[:i], w.ns.watchers[i+1:]...)
+				break
+			}
+		}
+		w.ns.watchMu.Unlock()
+	})
+	return nil
+}
+
+func (w *ExternalWatcher) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.poll()
+		}
+	}
+}
+
+// poll compares the data files with the last known state and handles the differences.
+func (w *ExternalWatcher) poll() {
+	w.flushOverflow()
+
+	current, err := w.ns.stampDataFiles()
+	if err != nil {
+		return
+	}
+
+	// Diff under mu, handle outside it: handling takes namespace locks
+	// that writers hold while calling note
+	var changes []ExternalChange
+	settled := make(map[string]bool)
+	w.mu.Lock()
+	for name, stamp := range current {
+		previous, ok := w.files[name]
+		held, isHeld := w.held[name]
+		switch {
+		case !ok:
+			changes = append(changes, ExternalChange{File: name, Type: ExternalCreated})
+		case previous != stamp && isHeld:
+			changes = append(changes, ExternalChange{File: name, Type: held})
+		case previous != stamp:
+			changes = append(changes, ExternalChange{File: name, Type: ExternalModified})
+		case isHeld:
+			// Unchanged for an interval: reported as it is
+			settled[name] = true
+			changes = append(changes, ExternalChange{File: name, Type: held})
+		}
+	}
+	for name := range w.files {
+		if _, ok := current[name]; !ok {
+			held, isHeld := w.held[name]
+			delete(w.held, name)
+			if isHeld && held == ExternalCreated {
+				// Never reported
+				continue
+			}
+			changes = append(changes, ExternalChange{File: name, Type: ExternalRemoved})
+		}
+	}
+	w.files = current
+	w.mu.Unlock()
+
+	for _, change := range changes {
+		if change.Type == ExternalRemoved {
+			change.Key = w.ns.forgetFile(change.File)
+		} else {
+			change.Key, change.Err = w.ns.revalidateFile(change.File)
+		}
+		w.ns.syncDerived(filepath.Join(w.ns.path, change.File))
+
+		w.mu.Lock()
+		if change.Err != nil && change.Type != ExternalRemoved && !settled[change.File] {
+			// Possibly caught mid-save; checked again next interval
+			w.held[change.File] = change.Type
+			w.mu.Unlock()
+			continue
+		}
+		delete(w.held, change.File)
+		w.mu.Unlock()
+
+		if change.Err != nil {
+			w.ns.logger.Warn("externally edited file is invalid",
+				Field{"file", change.File}, Field{"error", change.Err})
+		}
+		w.emit(change)
+	}
+}
+
+func (w *ExternalWatcher) emit(change ExternalChange) {
+	if w.flushOverflow() {
+		select {
+		case w.events <- change:
+			return
+		default:
+		}
+	}
+
+	w.overflowed = true
+	w.ns.logger.Warn("dropping external change event",
+		Field{"namespace", w.ns.name}, Field{"file", change.File})
+}
+
+// flushOverflow sends a pending ExternalOverflow, so it precedes any event
+// after the loss. Reports whether none is pending anymore.
+func (w *ExternalWatcher) flushOverflow() bool {
+	if !w.overflowed {
+		return true
+	}
+
+	select {
+	case w.events <- ExternalChange{Type: ExternalOverflow}:
+		w.overflowed = false
+		return true
+	default:
+		return false
+	}
+}
+
+// note records the state of a file this namespace just wrote.
+func (w *ExternalWatcher) note(name string, stamp fileStamp, exists bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if exists {
+		w.files[name] = stamp
+	} else {
+		delete(w.files, name)
+	}
+	// This namespace's write replaces whatever was held
+	delete(w.held, name)
+}
+
+// noteWrite tells the namespace's watchers that filePath was written by this
+// namespace, so they don't report it as an external change.
+func (ns *namespace) noteWrite(filePath string) {
+	ns.watchMu.Lock()
+	defer ns.watchMu.Unlock()
+
+	if len(ns.watchers) == 0 {
+		return
+	}
+
+	var stamp fileStamp
+	info, err := os.Stat(filePath)
+	if err == nil {
+		stamp = fileStamp{size: info.Size(), modTime: info.ModTime()}
+	}
+
+	name := filepath.Base(filePath)
+	for _, w := range ns.watchers {
+		w.note(name, stamp, err == nil)
+	}
+}
+
+// closeWatchers stops every watcher of the namespace.
+func (ns *namespace) closeWatchers() {
+	ns.watchMu.Lock()
+	watchers := append([]*ExternalWatcher(nil), ns.watchers...)
+	ns.watchMu.Unlock()
+
+	for _, w := range watchers {
+		w.Close()
+	}
+}
+
+// stampDataFiles returns the current state of every data file in the namespace.
+func (ns *namespace) stampDataFiles() (map[string]fileStamp, error) {
+	entries, err := os.ReadDir(ns.path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]fileStamp)
+	for _, entry := range entries {
+		// Never follow symlinks out of the namespace
+		if !entry.Type().IsRegular() || !index.IsKeyFileName(entry.Name()) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files[entry.Name()] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+	}
+
+	return files, nil
+}
+
+// revalidateFile re-reads an externally changed file, registers its key and
+// drops the cached value. The error reports lines that no longer decode.
+func (ns *namespace) revalidateFile(name string) (string, error) {
+	f, err := os.Open(filepath.Join(ns.path, name))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var (
+		key     string
+		invalid []int
+	)
+
+	reader := bufio.NewReader(f)
+	for lineNum := 1; ; lineNum++ {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			record, decodeErr := ns.decoder.Decode(line)
+			switch {
+			case decodeErr != nil:
+				invalid = append(invalid, lineNum)
+			case key == "":
+				key = record.Meta.Key
+			case record.Meta.Key != key:
+				// A record of another key can't be served from this file
+				invalid = append(invalid, lineNum)
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return key, err
+		}
+	}
+
+	if key != "" {
+		ns.followKey(key, name)
+	}
+
+	switch {
+	case key == "":
+		return "", fmt.Errorf("%w: %s has no valid records", ErrCorruptedData, name)
+	case len(invalid) > 0:
+		return key, fmt.Errorf("%w: %s has invalid lines %v", ErrCorruptedData, name, invalid)
+	}
+	return key, nil
+}
+
+// forgetFile drops a removed file from the key mapper and cache and returns its key.
+func (ns *namespace) forgetFile(name string) string {
+	ns.mu.Lock()
+	var key string
+	for _, candidate := range ns.keyMapper.ListAll() {
+		if ns.keyMapper.FindExact(candidate) == name {
+			key = candidate
+			break
+		}
+	}
+	ns.keyMapper.RemoveByFileName(name)
+	ns.mu.Unlock()
+
+	ns.generation.Add(1)
+	if key != "" {
+		ns.cache.Delete(key)
+		ns.bools.touch(key)
+	}
+	return key
+}
@@ -0,0 +1,101 @@
+package stow
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Operation names used in slow and timed-out operation logs.
+const (
+	opNamePut     = "put"
+	opNameGet     = "get"
+	opNameCompact = "compact"
+	opNameGC      = "gc"
+)
+
+// opTimer enforces OperationTimeouts and logs slow operations for every
+// namespace of a store. A nil opTimer runs operations as they are.
+type opTimer struct {
+	timeouts OperationTimeouts
+	slow     time.Duration
+	logger   Logger
+}
+
+// newOpTimer returns nil when neither timeouts nor a slow threshold are set.
+func newOpTimer(options *storeOptions, logger Logger) *opTimer {
+	if options.slowOpThreshold <= 0 && options.opTimeouts == (OperationTimeouts{}) {
+		return nil
+	}
+	return &opTimer{
+		timeouts: options.opTimeouts,
+		slow:     options.slowOpThreshold,
+		logger:   logger,
+	}
+}
+
+// timeout returns the limit of an operation, 0 when there is none.
+func (t *opTimer) timeout(op string) time.Duration {
+	switch op {
+	case opNamePut:
+		return t.timeouts.Put
+	case opNameGet:
+		return t.timeouts.Get
+	case opNameCompact:
+		return t.timeouts.Compact
+	case opNameGC:
+		return t.timeouts.GC
+	}
+	return 0
+}
+
+// run calls fn, returning ErrOperationTimeout if it takes longer than the
+// timeout of op. File I/O can't be interrupted, so a timed out fn keeps
+// running in the background and holds its locks until it returns; it is
+// logged once it does.
+func (t *opTimer) run(op, namespace, key string, fn func() error) error {
+	if t == nil {
+		return fn()
+	}
+
+	start := time.Now()
+	timeout := t.timeout(op)
+	if timeout <= 0 {
+		err := fn()
+		t.logSlow(op, namespace, key, time.Since(start))
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		err := fn()
+		t.logSlow(op, namespace, key, time.Since(start))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		t.logger.Error("operation timed out", t.fields(op, namespace, key, timeout)...)
+		return fmt.Errorf("%w: %s %s after %v", ErrOperationTimeout, op, namespace, timeout)
+	}
+}
+
+// logSlow warns about operations that took longer than the slow threshold.
+func (t *opTimer) logSlow(op, namespace, key string, elapsed time.Duration) {
+	if t.slow > 0 && elapsed >= t.slow {
+		t.logger.Warn("slow operation", t.fields(op, namespace, key, elapsed)...)
+	}
+}
+
+func (t *opTimer) fields(op, namespace, key string, elapsed time.Duration) []Field {
+	fields := []Field{{"op", op}, {"namespace", namespace}, {"duration", elapsed}}
+	if key != "" {
+		fields = append(fields, Field{"key", key})
+	}
+	return fields
+}
@@ -1,11 +1,49 @@
 package stow
 
+import "time"
+
 // StoreOption is a function that configures a Store.
 type StoreOption func(*storeOptions)
 
 // storeOptions holds configuration options for opening a store.
 type storeOptions struct {
 	logger Logger
+
+	diskBudget     int64
+	diskWatermarks []float64
+	diskHardCap    int64
+	onDiskUsage    func(DiskUsage)
+
+	namespaceKeys map[string][]byte
+	signingKeys   map[string][]byte
+	normalizers   map[string][]func(map[string]interface{}) error
+
+	authorizer Authorizer
+
+	readOnly    bool
+	replicaPoll time.Duration
+
+	maxOpenNamespaces int
+	maxOpenFiles      int
+	maxGoroutines     int
+
+	statsHistory   bool
+	statsInterval  time.Duration
+	statsRetention time.Duration
+
+	slowOpThreshold time.Duration
+	opTimeouts      OperationTimeouts
+
+	networkFS bool
+	ownerID   string
+
+	openReport *OpenReport
+
+	scanWorkers  int
+	openProgress func(OpenProgress)
+
+	blobCacheDir      string
+	blobCacheMaxBytes int64
 }
 
 // WithStoreLogger sets a custom logger for the store.
@@ -15,6 +53,269 @@ func WithStoreLogger(logger Logger) StoreOption {
 	}
 }
 
+// WithStoreDiskBudget sets a byte budget for the whole store. OnDiskUsage
+// callbacks fire when the store size crosses each watermark, given as fractions
+// of the budget. Defaults to DefaultDiskWatermarks (80% and 95%).
+//
+// Example:
+//
+//	stow.Open(path,
+//		stow.WithStoreDiskBudget(512<<20, 0.8, 0.95),
+//		stow.WithStoreOnDiskUsage(func(u stow.DiskUsage) { alert(u) }))
+func WithStoreDiskBudget(budget int64, watermarks ...float64) StoreOption {
+	return func(o *storeOptions) {
+		o.diskBudget = budget
+		o.diskWatermarks = watermarks
+	}
+}
+
+// WithStoreOnDiskUsage sets the callback fired when the store size crosses a
+// watermark of the disk budget. Each watermark fires once per upward crossing.
+func WithStoreOnDiskUsage(fn func(DiskUsage)) StoreOption {
+	return func(o *storeOptions) {
+		o.onDiskUsage = fn
+	}
+}
+
+// WithStoreHardCap refuses writes with ErrStoreFull once they would grow the
+// store beyond capBytes. Deletes, compaction and GC are still allowed.
+func WithStoreHardCap(capBytes int64) StoreOption {
+	return func(o *storeOptions) {
+		o.diskHardCap = capBytes
+	}
+}
+
+// WithStoreNamespaceKey provides the encryption key of a namespace created with
+// NamespaceConfig.WithKey. Encrypted namespaces without a key are unavailable:
+// opening them returns ErrNamespaceUnavailable while the rest of the store works.
+//
+// Example:
+//
+//	stow.Open(path,
+//		stow.WithStoreNamespaceKey("tenant-a", keyA),
+//		stow.WithStoreNamespaceKey("tenant-b", keyB))
+func WithStoreNamespaceKey(name string, key []byte) StoreOption {
+	return func(o *storeOptions) {
+		if o.namespaceKeys == nil {
+			o.namespaceKeys = make(map[string][]byte)
+		}
+		o.namespaceKeys[name] = key
+	}
+}
+
+// WithStoreSigningKey provides the signing key of a namespace created with
+// NamespaceConfig.Signing. Without it the namespace opens, but writes fail
+// with ErrSigningKeyRequired.
+//
+// Example:
+//
+//	stow.Open(path, stow.WithStoreSigningKey("audit", seed))
+func WithStoreSigningKey(name string, key []byte) StoreOption {
+	return func(o *storeOptions) {
+		if o.signingKeys == nil {
+			o.signingKeys = make(map[string][]byte)
+		}
+		o.signingKeys[name] = key
+	}
+}
+
+// WithStoreNormalizers provides the normalizers of a namespace (see
+// NamespaceConfig.Normalizers), which aren't persisted with its config.
+//
+// Example:
+//
+//	stow.Open(path, stow.WithStoreNormalizers("users", func(data map[string]interface{}) error {
+//		if email, ok := data["email"].(string); ok {
+//			data["email"] = strings.ToLower(strings.TrimSpace(email))
+//		}
+//		return nil
+//	}))
+func WithStoreNormalizers(name string, fns ...func(map[string]interface{}) error) StoreOption {
+	return func(o *storeOptions) {
+		if o.normalizers == nil {
+			o.normalizers = make(map[string][]func(map[string]interface{}) error)
+		}
+		o.normalizers[name] = append(o.normalizers[name], fns...)
+	}
+}
+
+// WithStoreAuthorizer consults fn on every store and namespace call.
+// Bind the caller's context with Store.WithContext or Namespace.WithContext;
+// handles without one are authorized with context.Background().
+func WithStoreAuthorizer(fn Authorizer) StoreOption {
+	return func(o *storeOptions) {
+		o.authorizer = fn
+	}
+}
+
+// WithStoreReadOnly opens the store as a read-only replica of a store written
+// by another process. Writes fail with ErrReadOnly, and caches are invalidated
+// by following the writer's changes log. See the README for the consistency model.
+func WithStoreReadOnly() StoreOption {
+	return func(o *storeOptions) {
+		o.readOnly = true
+	}
+}
+
+// WithStoreOwnerID sets the ID this store writes to the lease files of
+// namespaces with NamespaceConfig.OwnerLease, e.g. a pod or instance name.
+// Stores opened with the same ID share ownership, so it must be unique to
+// the process. Defaults to the host name, process ID and a random suffix.
+func WithStoreOwnerID(id string) StoreOption {
+	return func(o *storeOptions) {
+		o.ownerID = id
+	}
+}
+
+// WithStoreReplicaPollInterval sets how often a read-only store checks the
+// writer's changes log. Defaults to DefaultReplicaPollInterval.
+func WithStoreReplicaPollInterval(interval time.Duration) StoreOption {
+	return func(o *storeOptions) {
+		o.replicaPoll = interval
+	}
+}
+
+// WithStoreMaxOpenNamespaces caps the namespace handles the store keeps open.
+// Beyond it the least recently used handles are evicted and their caches and
+// index released; the next GetNamespace reopens them. Handles still held by
+// callers stay valid and are reused. 0 (the default) means no cap.
+func WithStoreMaxOpenNamespaces(n int) StoreOption {
+	return func(o *storeOptions) {
+		o.maxOpenNamespaces = n
+	}
+}
+
+// WithStoreMaxOpenFiles caps the key file handles the store holds for reads.
+// At the cap idle handles are closed first, then reads wait for a handle to
+// be released. 0 (the default) means no cap.
+func WithStoreMaxOpenFiles(n int) StoreOption {
+	return func(o *storeOptions) {
+		o.maxOpenFiles = n
+	}
+}
+
+// WithStoreMaxGoroutines caps the store's background goroutines. At the cap
+// auto compaction is skipped (it runs with a later write) and new watchers
+// fail with ErrGoroutineLimit. The replica follower and stats recorder always
+// run but count toward the cap. 0 (the default) means no cap.
+func WithStoreMaxGoroutines(n int) StoreOption {
+	return func(o *storeOptions) {
+		o.maxGoroutines = n
+	}
+}
+
+// WithStoreStatsHistory records a snapshot of every namespace's stats every
+// interval into the reserved _stats namespace, keeping about retention worth
+// of them. Read them back with Store.StatsHistory. Zero values default to
+// DefaultStatsInterval and DefaultStatsRetention. Ignored by read-only stores.
+func WithStoreStatsHistory(interval, retention time.Duration) StoreOption {
+	return func(o *storeOptions) {
+		o.statsHistory = true
+		o.statsInterval = interval
+		o.statsRetention = retention
+	}
+}
+
+// WithStoreSlowOpThreshold logs a warning for every Put, Get, Compact and GC
+// that takes threshold or longer, with the namespace, key and duration.
+func WithStoreSlowOpThreshold(threshold time.Duration) StoreOption {
+	return func(o *storeOptions) {
+		o.slowOpThreshold = threshold
+	}
+}
+
+// WithStoreOperationTimeouts bounds how long namespace operations may block
+// their callers; see OperationTimeouts.
+//
+// Example:
+//
+//	stow.Open(path, stow.WithStoreOperationTimeouts(stow.OperationTimeouts{
+//		Put: 5 * time.Second,
+//		Get: time.Second,
+//	}))
+func WithStoreOperationTimeouts(timeouts OperationTimeouts) StoreOption {
+	return func(o *storeOptions) {
+		o.opTimeouts = timeouts
+	}
+}
+
+// WithNetworkFSMode opens a store that lives on an NFS or SMB volume:
+// records are appended under a server-arbitrated lock instead of with
+// O_APPEND, metadata files are replaced without relying on directory fsync,
+// and the writer lock outlives a crashed client's lease (opening waits up to
+// NetworkFSLockWait for it). See the README for the guarantees.
+func WithNetworkFSMode() StoreOption {
+	return func(o *storeOptions) {
+		o.networkFS = true
+	}
+}
+
+// WithStoreOpenReport checks every namespace at Open and fills report with
+// what was found: keys, corrupt lines, unreadable key files, torn tails
+// left by a crash mid-write (truncated by writers) and the time taken to
+// rebuild each key index. Namespaces are opened as part of the check, so
+// Open takes longer on large stores.
+//
+// Example:
+//
+//	var report stow.OpenReport
+//	store, err := stow.Open(path, stow.WithStoreOpenReport(&report))
+//	if report.Degraded() {
+//		alert(report)
+//	}
+func WithStoreOpenReport(report *OpenReport) StoreOption {
+	return func(o *storeOptions) {
+		o.openReport = report
+	}
+}
+
+// WithStoreScanWorkers sets how many key files are read at once while a
+// namespace builds its key index on open. Defaults to DefaultScanWorkers;
+// lowered to WithStoreMaxOpenFiles when that is smaller.
+func WithStoreScanWorkers(n int) StoreOption {
+	return func(o *storeOptions) {
+		o.scanWorkers = n
+	}
+}
+
+// WithStoreOpenProgress calls fn as a namespace being opened reads its key
+// files, so opening one with hundreds of thousands of keys can show
+// progress. Calls for one namespace never overlap.
+//
+// Example:
+//
+//	stow.Open(path, stow.WithStoreOpenProgress(func(p stow.OpenProgress) {
+//		if p.Files%10000 == 0 || p.Files == p.Total {
+//			log.Printf("%s: %d/%d key files", p.Namespace, p.Files, p.Total)
+//		}
+//	}))
+func WithStoreOpenProgress(fn func(OpenProgress)) StoreOption {
+	return func(o *storeOptions) {
+		o.openProgress = fn
+	}
+}
+
+// WithStoreBlobCache keeps local copies of the blob files read in dir, at
+// most maxBytes of them, for stores whose blobs live on a slow or remote
+// filesystem: a BlobDir on a network mount, or a replica opened with
+// WithNetworkFSMode. Reads of a blob copy it on first use and are served
+// from the copy afterwards; the least recently used copies are evicted
+// first. Copies are checked against the blob's hash on every read, and a
+// damaged one is fetched again. The directory should be local to the
+// machine and not shared with another store; it holds blob files as
+// stored, so encrypted blobs stay encrypted.
+//
+// Example:
+//
+//	stow.Open("/mnt/nfs/stow", stow.WithStoreReadOnly(), stow.WithNetworkFSMode(),
+//		stow.WithStoreBlobCache("/var/cache/stow-blobs", 10<<30))
+func WithStoreBlobCache(dir string, maxBytes int64) StoreOption {
+	return func(o *storeOptions) {
+		o.blobCacheDir = dir
+		o.blobCacheMaxBytes = maxBytes
+	}
+}
+
 // PutOption is a function that configures a Put operation.
 type PutOption func(*putOptions)
 
@@ -24,6 +325,16 @@ type putOptions struct {
 	forceInline bool
 	fileName    string
 	mimeType    string
+	idGenerator IDGenerator
+	visibleAt   time.Time
+
+	skipUnchanged  bool
+	writtenVersion *int
+	ioStats        []*IOStats
+
+	// Set from a registered model (see Store.RegisterModel)
+	keyFunc       func(value interface{}) (string, error)
+	blobThreshold int64
 }
 
 // WithForceFile forces the data to be stored as a file, even if it's small.
@@ -75,3 +386,136 @@ func WithMimeType(mime string) PutOption {
 		o.mimeType = mime
 	}
 }
+
+// WithIDGenerator sets the key generator used by PutAuto.
+// Ignored by Put. Defaults to ULID.
+//
+// Example:
+//
+//	key, err := ns.PutAuto(event, stow.WithIDGenerator(stow.UUIDv7))
+func WithIDGenerator(gen IDGenerator) PutOption {
+	return func(o *putOptions) {
+		o.idGenerator = gen
+	}
+}
+
+// WithVisibleAt schedules the write: the record is stored now, but Get, List
+// and the other readers keep returning the previous value until t. Later
+// writes to the key supersede a pending scheduled record.
+//
+// Example:
+//
+//	ns.Put("pricing", newPrices, stow.WithVisibleAt(launch))
+func WithVisibleAt(t time.Time) PutOption {
+	return func(o *putOptions) {
+		o.visibleAt = t.UTC()
+	}
+}
+
+// WithSkipIfUnchanged skips the Put when the value equals the key's latest
+// version (compared as canonical JSON, so decoded ints and float64s match),
+// leaving the history as it is. Scheduled Puts always append.
+// NamespaceConfig.SkipUnchanged makes it the default.
+//
+// Example:
+//
+//	var version int
+//	ns.Put("user:1", user, stow.WithSkipIfUnchanged(), stow.WithWrittenVersion(&version))
+func WithSkipIfUnchanged() PutOption {
+	return func(o *putOptions) {
+		o.skipUnchanged = true
+	}
+}
+
+// WithWrittenVersion stores the key's latest version after a successful Put
+// in v: the version written, or the one kept when the Put was skipped or
+// coalesced.
+func WithWrittenVersion(v *int) PutOption {
+	return func(o *putOptions) {
+		o.writtenVersion = v
+	}
+}
+
+// WithIOStats adds the file IO of the Put to stats: bytes read and written,
+// files synced and the bytes of the value itself, so callers can measure
+// the write amplification of their writes. stats isn't reset first, so one
+// collector can sum several calls; it must not be shared by concurrent
+// calls. Given more than once, the IO is added to each stats. Namespace
+// totals are in NamespaceStats.IO.
+//
+// Example:
+//
+//	var stats stow.IOStats
+//	ns.Put("doc", doc, stow.WithIOStats(&stats))
+//	log.Printf("wrote %d bytes for %d (%.1fx)", stats.BytesWritten, stats.LogicalBytes, stats.WriteAmplification())
+func WithIOStats(stats *IOStats) PutOption {
+	return func(o *putOptions) {
+		o.ioStats = append(o.ioStats, stats)
+	}
+}
+
+// DeleteOption is a function that configures a Delete operation.
+type DeleteOption func(*deleteOptions)
+
+// deleteOptions holds options for Delete operations.
+type deleteOptions struct {
+	reason string
+}
+
+// WithReason records why a key was deleted. The reason is stored in the
+// delete record and reported by GetHistory, so audits can tell apart
+// user-requested, administrative and automated deletions.
+//
+// Example:
+//
+//	ns.Delete("user:42", stow.WithReason("user requested erasure"))
+func WithReason(reason string) DeleteOption {
+	return func(o *deleteOptions) {
+		o.reason = reason
+	}
+}
+
+// GetOption is a function that configures a Get or GetCached operation.
+type GetOption func(*getOptions)
+
+// getOptions holds options for Get and GetCached operations.
+type getOptions struct {
+	loader   func() (interface{}, error)
+	fallback bool
+}
+
+// WithLoader makes GetCached read through: when the key doesn't exist, fn
+// loads the value (e.g. from a slower backing service), which is Put and
+// returned. Concurrent misses of a key share one call of fn.
+//
+// Example:
+//
+//	ns.GetCached("user:42", &user, stow.WithLoader(func() (interface{}, error) {
+//		return api.FetchUser(42)
+//	}))
+func WithLoader(fn func() (interface{}, error)) GetOption {
+	return func(o *getOptions) {
+		o.loader = fn
+	}
+}
+
+// WithFallbackToHistory makes Get serve the most recent readable version
+// when the newest records of a key are corrupt (unparseable, over the read
+// limits, or with spilled data that can't be loaded). target is filled and
+// a *DegradedReadError (matching ErrDegradedRead) is returned, so callers
+// that value availability over strictness can still detect the repair
+// they need. These reads bypass the cache. Ignored by GetCached.
+//
+// Example:
+//
+//	err := ns.Get("config", &cfg, stow.WithFallbackToHistory())
+//	if errors.Is(err, stow.ErrDegradedRead) {
+//		log.Printf("serving an older config: %v", err)
+//	} else if err != nil {
+//		return err
+//	}
+func WithFallbackToHistory() GetOption {
+	return func(o *getOptions) {
+		o.fallback = true
+	}
+}
@@ -0,0 +1,150 @@
+package stow
+
+import (
+	"sync/atomic"
+
+	"github.com/aigotowork/stow/internal/fsutil"
+)
+
+// DefaultIdleFiles is how many idle key file handles a store keeps open for
+// reuse by later reads.
+const DefaultIdleFiles = 32
+
+// ResourceUsage reports the file handles and background goroutines of a
+// store against their ceilings. A ceiling of 0 means no limit.
+type ResourceUsage struct {
+	// OpenFiles is the number of key file handles held for reads, including
+	// idle ones kept for reuse
+	OpenFiles int
+
+	// IdleFiles is the part of OpenFiles not in use by any read
+	IdleFiles int
+
+	// MaxOpenFiles is the ceiling set with WithStoreMaxOpenFiles
+	MaxOpenFiles int
+
+	// Goroutines is the number of running background goroutines: watchers,
+	// auto compactions, the stats recorder and the replica follower
+	Goroutines int
+
+	// MaxGoroutines is the ceiling set with WithStoreMaxGoroutines
+	MaxGoroutines int
+
+	// BlobCache counts the reads of the cache set with WithStoreBlobCache
+	BlobCache BlobCacheStats
+}
+
+// BlobCacheStats counts the blob reads of the cache set with
+// WithStoreBlobCache.
+type BlobCacheStats struct {
+	// Hits is the number of reads served from a local copy
+	Hits int64
+
+	// Misses is the number of reads that copied the blob file
+	Misses int64
+
+	// Corrupt is the number of copies that failed their hash check and
+	// were fetched again
+	Corrupt int64
+
+	// Evictions is the number of copies evicted to make room
+	Evictions int64
+
+	// Bytes is the size of the copies
+	Bytes int64
+
+	// MaxBytes is the ceiling set with WithStoreBlobCache
+	MaxBytes int64
+}
+
+// resources accounts for the file handles and background goroutines of a
+// store. A nil *resources opens plain files and never refuses a goroutine.
+type resources struct {
+	files         *fsutil.FilePool
+	maxFiles      int
+	maxGoroutines int
+	goroutines    atomic.Int64
+}
+
+func newResources(options *storeOptions) *resources {
+	return &resources{
+		files:         fsutil.NewFilePool(options.maxOpenFiles, DefaultIdleFiles),
+		maxFiles:      options.maxOpenFiles,
+		maxGoroutines: options.maxGoroutines,
+	}
+}
+
+// spawn runs fn in a new goroutine unless the budget is used up; deferrable
+// work such as auto compaction is skipped then.
+func (r *resources) spawn(fn func()) bool {
+	if r == nil {
+		go fn()
+		return true
+	}
+
+	if n := r.goroutines.Add(1); r.maxGoroutines > 0 && n > int64(r.maxGoroutines) {
+		r.goroutines.Add(-1)
+		return false
+	}
+	go func() {
+		defer r.goroutines.Add(-1)
+		fn()
+	}()
+	return true
+}
+
+// start runs fn in a new goroutine the store can't work without, counting
+// it even over the budget.
+func (r *resources) start(fn func()) {
+	if r == nil {
+		go fn()
+		return
+	}
+
+	r.goroutines.Add(1)
+	go func() {
+		defer r.goroutines.Add(-1)
+		fn()
+	}()
+}
+
+// pool returns the file pool for namespace decoders.
+func (r *resources) pool() *fsutil.FilePool {
+	if r == nil {
+		return nil
+	}
+	return r.files
+}
+
+func (r *resources) usage() ResourceUsage {
+	if r == nil {
+		return ResourceUsage{}
+	}
+
+	open, idle := r.files.Stats()
+	return ResourceUsage{
+		OpenFiles:     open,
+		IdleFiles:     idle,
+		MaxOpenFiles:  r.maxFiles,
+		Goroutines:    int(r.goroutines.Load()),
+		MaxGoroutines: r.maxGoroutines,
+	}
+}
+
+// Resources reports the store's current file handles and background
+// goroutines.
+func (s *store) Resources() ResourceUsage {
+	usage := s.resources.usage()
+	if s.blobCache != nil {
+		stats := s.blobCache.Stats()
+		usage.BlobCache = BlobCacheStats{
+			Hits:      stats.Hits,
+			Misses:    stats.Misses,
+			Corrupt:   stats.Corrupt,
+			Evictions: stats.Evictions,
+			Bytes:     stats.Bytes,
+			MaxBytes:  stats.MaxBytes,
+		}
+	}
+	return usage
+}
@@ -0,0 +1,187 @@
+// Package spec describes the on-disk record format of stow, so that other
+// tools and languages can write files stow reads, and checks lines against
+// it.
+//
+// A key's history is a JSONL file: one record per line, oldest first, each
+// line terminated by "\n". A record is a JSON object with two fields:
+//
+//	{"_meta":{"k":"user:1","v":2,"op":"put","ts":"2025-12-14T18:09:00Z"},"data":{"name":"Alice"}}
+//
+// _meta requires k (the key, non-empty), v (the version, an integer from 1,
+// ascending within a file), op ("put" or "delete") and ts (RFC 3339). A put
+// carries its value as a data object; a delete has null data. The optional
+// meta fields are listed in OptionalMetaFields. Unknown fields are invalid
+// so that format changes are noticed rather than silently dropped.
+//
+// Values inside data may use these markers, each an object:
+//
+//   - {"$blob":true,"loc":...,"hash":...,"size":...} references a file in
+//     the blob directory (see BlobRef)
+//   - {"$sealed":"b:<base64>"} is the whole data of an encrypted record,
+//     sealed with "<v>:<k>" as associated data; without the b: prefix the
+//     data was sealed with none (format 2 and earlier)
+//   - {"$record":{"$blob":true,...}} is the whole data of a record spilled
+//     to a blob
+//   - {"$raw":"<json>"} holds a json.RawMessage verbatim
+//   - {"$float":"NaN"|"+Inf"|"-Inf"} holds a non-finite float
+//   - {"$value":...} wraps a value that is not an object
+//
+// The canonical encoding, which Encode produces and stow writes, has no
+// insignificant whitespace, _meta before data, meta fields in the order of
+// RequiredMetaFields then OptionalMetaFields with empty optional fields
+// left out, timestamps in UTC, object keys within data sorted, and <, > and
+// & escaped as \u003c, \u003e and \u0026. Numbers are written as given.
+// Readers must accept any valid encoding.
+package spec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Version is the version of the record format described here. Version 2
+// added the optional seq meta field, version 3 bound sealed data to its
+// record's key and version.
+const Version = 3
+
+// Record fields.
+const (
+	FieldMeta = "_meta"
+	FieldData = "data"
+)
+
+// Operations.
+const (
+	OpPut    = "put"
+	OpDelete = "delete"
+)
+
+// Markers of special values inside data.
+const (
+	MarkerBlob   = "$blob"
+	MarkerSealed = "$sealed"
+	MarkerRecord = "$record"
+	MarkerRaw    = "$raw"
+	MarkerFloat  = "$float"
+	MarkerValue  = "$value"
+)
+
+// SealedBoundPrefix starts $sealed data bound to its record's key and
+// version.
+const SealedBoundPrefix = "b:"
+
+// RequiredMetaFields are the fields every _meta object has, in canonical order.
+var RequiredMetaFields = []string{"k", "v", "op", "ts"}
+
+// OptionalMetaFields are the fields a _meta object may have, in canonical order.
+var OptionalMetaFields = []string{"seq", "reason", "visible_at", "renamed_from", "renamed_to", "prev", "sig"}
+
+// Record is one line of a history file.
+type Record struct {
+	Meta Meta                   `json:"_meta"`
+	Data map[string]interface{} `json:"data"`
+}
+
+// Meta is the _meta object of a record.
+type Meta struct {
+	// Key is the record's key
+	Key string `json:"k"`
+
+	// Version is the record's version, from 1
+	Version int `json:"v"`
+
+	// Op is OpPut or OpDelete
+	Op string `json:"op"`
+
+	// Timestamp is when the record was written
+	Timestamp time.Time `json:"ts"`
+
+	// Seq is the namespace's write sequence number, from 1; it orders
+	// writes when clocks disagree. 0 (omitted) when unknown.
+	Seq uint64 `json:"seq,omitempty"`
+
+	// Reason optionally explains a delete
+	Reason string `json:"reason,omitempty"`
+
+	// VisibleAt hides a put from readers until this time
+	VisibleAt time.Time `json:"visible_at,omitzero"`
+
+	// RenamedFrom marks the first record of a renamed key with its old key
+	RenamedFrom string `json:"renamed_from,omitempty"`
+
+	// RenamedTo marks the delete closing a renamed key with its new key
+	RenamedTo string `json:"renamed_to,omitempty"`
+
+	// Prev is the hex SHA-256 digest of the key's previous record in
+	// hash-chained namespaces
+	Prev string `json:"prev,omitempty"`
+
+	// Sig is the base64 signature of the record in signed namespaces
+	Sig string `json:"sig,omitempty"`
+}
+
+// BlobRef is the object referencing a blob file.
+type BlobRef struct {
+	// Blob is always true
+	Blob bool `json:"$blob"`
+
+	// Location is the file's path relative to the namespace (e.g.
+	// "_blobs/avatar_abc123.jpg")
+	Location string `json:"loc"`
+
+	// Hash is the hex digest of the file's content
+	Hash string `json:"hash"`
+
+	// Algo is the hash algorithm; empty means "sha256"
+	Algo string `json:"algo,omitempty"`
+
+	// Size is the file's size in bytes
+	Size int64 `json:"size"`
+
+	// MimeType and Name describe the original file
+	MimeType string `json:"mime,omitempty"`
+	Name     string `json:"name,omitempty"`
+
+	// Filters is the filter chain the file was written with, first first
+	Filters []string `json:"filters,omitempty"`
+
+	// Kind is empty for raw bytes, "json" for a spilled JSON value and
+	// "rawjson" for a json.RawMessage
+	Kind string `json:"kind,omitempty"`
+}
+
+// Encode returns the canonical line of r, terminated by "\n". The record
+// must be valid.
+func Encode(r Record) ([]byte, error) {
+	r.Meta.Timestamp = r.Meta.Timestamp.UTC()
+	if !r.Meta.VisibleAt.IsZero() {
+		r.Meta.VisibleAt = r.Meta.VisibleAt.UTC()
+	}
+
+	line, err := json.Marshal(r)
+	if err != nil {
+		return nil, fmt.Errorf("spec: failed to encode record: %w", err)
+	}
+	if err := Validate(line); err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+// Canonical returns the canonical encoding of a valid line.
+func Canonical(line []byte) ([]byte, error) {
+	if err := Validate(line); err != nil {
+		return nil, err
+	}
+
+	// Numbers are kept as written, not rounded through float64
+	var r Record
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	if err := decoder.Decode(&r); err != nil {
+		return nil, fmt.Errorf("spec: failed to decode record: %w", err)
+	}
+	return Encode(r)
+}
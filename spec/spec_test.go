@@ -0,0 +1,135 @@
+package spec_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aigotowork/stow"
+	"github.com/aigotowork/stow/spec"
+)
+
+// conformanceCase is one entry of testdata/conformance.json, the suite other
+// implementations can run against their own writers and validators.
+type conformanceCase struct {
+	Name      string `json:"name"`
+	Line      string `json:"line"`
+	Valid     bool   `json:"valid"`
+	Canonical string `json:"canonical"`
+}
+
+func TestConformance(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "conformance.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases []conformanceCase
+	if err := json.Unmarshal(data, &cases); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			err := spec.Validate([]byte(c.Line))
+			if !c.Valid {
+				if !errors.Is(err, spec.ErrInvalidRecord) {
+					t.Fatalf("expected ErrInvalidRecord, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate failed: %v", err)
+			}
+
+			canonical, err := spec.Canonical([]byte(c.Line))
+			if err != nil {
+				t.Fatalf("Canonical failed: %v", err)
+			}
+			if string(canonical) != c.Canonical+"\n" {
+				t.Errorf("canonical form\n got %s want %s", canonical, c.Canonical)
+			}
+		})
+	}
+}
+
+// TestStowWritesCanonicalRecords fails when stow's writer drifts from the
+// spec: every line it writes must be valid and already canonical.
+func TestStowWritesCanonicalRecords(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.HashChain = true
+	config.NonFiniteFloats = stow.FloatSentinel
+	config.Signing = stow.SigningConfig{Algorithm: stow.SigningHMACSHA256, Key: []byte("secret")}
+	ns, err := store.CreateNamespace("plain", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	ns.MustPut("user:1", map[string]interface{}{"name": "Alice <a&b>", "id": int64(1) << 60})
+	ns.MustPut("user:1", map[string]interface{}{
+		"avatar":  []byte(strings.Repeat("x", 8192)),
+		"payload": json.RawMessage(`{"b":1,"a":2.50}`),
+		"reading": math.NaN(),
+	})
+	if err := ns.Put("post:1", map[string]interface{}{"title": "Later"},
+		stow.WithVisibleAt(time.Now().Add(time.Hour).In(time.FixedZone("CEST", 2*3600)))); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	ns.MustDelete("user:1", stow.WithReason("user requested erasure"))
+	ns.MustPut("draft:1", map[string]interface{}{"title": "Draft"})
+	if err := ns.Rename("draft:1", "page:1"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+
+	sealed, err := store.CreateNamespace("sealed", stow.DefaultNamespaceConfig().WithKey(bytes.Repeat([]byte{7}, 32)))
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+	sealed.MustPut("secret", map[string]interface{}{"pin": 1234})
+
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*.jsonl"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no key files: %v", err)
+	}
+	for _, file := range files {
+		checkFile(t, file)
+	}
+}
+
+func checkFile(t *testing.T, path string) {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if err := spec.Validate(line); err != nil {
+			t.Errorf("%s: %v\n%s", filepath.Base(path), err, line)
+			continue
+		}
+		canonical, err := spec.Canonical(line)
+		if err != nil {
+			t.Errorf("%s: %v", filepath.Base(path), err)
+			continue
+		}
+		if !bytes.Equal(canonical, append(line, '\n')) {
+			t.Errorf("%s: not canonical\n got  %s want %s", filepath.Base(path), line, canonical)
+		}
+	}
+}
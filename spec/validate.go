@@ -0,0 +1,285 @@
+package spec
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidRecord is matched (with errors.Is) by every error of Validate.
+var ErrInvalidRecord = errors.New("spec: invalid record")
+
+// Validate checks that line (with or without its trailing newline) is a
+// valid record. The error names the first offending field, e.g.
+// "spec: invalid record: _meta.v: must be an integer from 1".
+func Validate(line []byte) error {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	if bytes.ContainsAny(line, "\n") {
+		return invalid("", "record spans several lines")
+	}
+
+	fields, err := decodeObject(line)
+	if err != nil {
+		return invalid("", "not a JSON object: %v", err)
+	}
+	for _, name := range sortedNames(fields) {
+		if name != FieldMeta && name != FieldData {
+			return invalid(name, "unknown field")
+		}
+	}
+
+	rawMeta, ok := fields[FieldMeta]
+	if !ok {
+		return invalid(FieldMeta, "missing")
+	}
+	op, err := validateMeta(rawMeta)
+	if err != nil {
+		return err
+	}
+
+	rawData := fields[FieldData]
+	switch op {
+	case OpPut:
+		data, err := decodeObject(rawData)
+		if err != nil {
+			return invalid(FieldData, "a put needs an object")
+		}
+		return validateData(data)
+	default:
+		if rawData != nil && !isNull(rawData) {
+			return invalid(FieldData, "a delete has null data")
+		}
+	}
+	return nil
+}
+
+// validateMeta checks a _meta object and returns its operation.
+func validateMeta(raw json.RawMessage) (string, error) {
+	meta, err := decodeObject(raw)
+	if err != nil {
+		return "", invalid(FieldMeta, "not an object")
+	}
+
+	known := make(map[string]bool)
+	for _, name := range RequiredMetaFields {
+		if _, ok := meta[name]; !ok {
+			return "", invalid(FieldMeta+"."+name, "missing")
+		}
+		known[name] = true
+	}
+	for _, name := range OptionalMetaFields {
+		known[name] = true
+	}
+	for _, name := range sortedNames(meta) {
+		if !known[name] {
+			return "", invalid(FieldMeta+"."+name, "unknown field")
+		}
+	}
+
+	var key string
+	if json.Unmarshal(meta["k"], &key) != nil || key == "" {
+		return "", invalid(FieldMeta+".k", "must be a non-empty string")
+	}
+
+	var version json.Number
+	if json.Unmarshal(meta["v"], &version) != nil {
+		return "", invalid(FieldMeta+".v", "must be an integer from 1")
+	}
+	if v, err := strconv.ParseInt(version.String(), 10, 0); err != nil || v < 1 {
+		return "", invalid(FieldMeta+".v", "must be an integer from 1")
+	}
+
+	if raw, ok := meta["seq"]; ok {
+		var seq json.Number
+		if json.Unmarshal(raw, &seq) != nil {
+			return "", invalid(FieldMeta+".seq", "must be an integer from 1")
+		}
+		if s, err := strconv.ParseUint(seq.String(), 10, 64); err != nil || s < 1 {
+			return "", invalid(FieldMeta+".seq", "must be an integer from 1")
+		}
+	}
+
+	var op string
+	if json.Unmarshal(meta["op"], &op) != nil || (op != OpPut && op != OpDelete) {
+		return "", invalid(FieldMeta+".op", "must be %q or %q", OpPut, OpDelete)
+	}
+
+	for _, name := range []string{"ts", "visible_at"} {
+		raw, ok := meta[name]
+		if !ok {
+			continue
+		}
+		var s string
+		if json.Unmarshal(raw, &s) != nil {
+			return "", invalid(FieldMeta+"."+name, "must be an RFC 3339 timestamp")
+		}
+		if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+			return "", invalid(FieldMeta+"."+name, "must be an RFC 3339 timestamp")
+		}
+	}
+
+	for _, name := range []string{"reason", "renamed_from", "renamed_to", "prev", "sig"} {
+		raw, ok := meta[name]
+		if !ok {
+			continue
+		}
+		var s string
+		if json.Unmarshal(raw, &s) != nil {
+			return "", invalid(FieldMeta+"."+name, "must be a string")
+		}
+	}
+
+	return op, nil
+}
+
+// validateData checks the markers of a put's data.
+func validateData(data map[string]json.RawMessage) error {
+	if raw, ok := data[MarkerSealed]; ok {
+		var s string
+		if len(data) != 1 || json.Unmarshal(raw, &s) != nil {
+			return invalid(FieldData+"."+MarkerSealed, "must be the only field, a base64 string")
+		}
+		if _, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(s, SealedBoundPrefix)); err != nil {
+			return invalid(FieldData+"."+MarkerSealed, "must be the only field, a base64 string")
+		}
+		return nil
+	}
+
+	if raw, ok := data[MarkerRecord]; ok {
+		ref, err := decodeObject(raw)
+		if len(data) != 1 || err != nil {
+			return invalid(FieldData+"."+MarkerRecord, "must be the only field, a blob reference")
+		}
+		return validateValue(FieldData+"."+MarkerRecord, ref)
+	}
+
+	return validateValue(FieldData, data)
+}
+
+// validateValue checks the markers inside an object value.
+func validateValue(path string, obj map[string]json.RawMessage) error {
+	if raw, ok := obj[MarkerBlob]; ok {
+		return validateBlob(path, raw, obj)
+	}
+
+	if raw, ok := obj[MarkerRaw]; ok && len(obj) == 1 {
+		var s string
+		if json.Unmarshal(raw, &s) != nil || !json.Valid([]byte(s)) {
+			return invalid(path+"."+MarkerRaw, "must be a string holding JSON")
+		}
+		return nil
+	}
+
+	if raw, ok := obj[MarkerFloat]; ok && len(obj) == 1 {
+		var s string
+		if json.Unmarshal(raw, &s) != nil || (s != "NaN" && s != "+Inf" && s != "-Inf") {
+			return invalid(path+"."+MarkerFloat, `must be "NaN", "+Inf" or "-Inf"`)
+		}
+		return nil
+	}
+
+	for _, name := range sortedNames(obj) {
+		if err := validateNested(path+"."+name, obj[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateNested checks the markers inside any JSON value.
+func validateNested(path string, raw json.RawMessage) error {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return nil
+	}
+
+	switch raw[0] {
+	case '{':
+		obj, err := decodeObject(raw)
+		if err != nil {
+			return invalid(path, "%v", err)
+		}
+		return validateValue(path, obj)
+	case '[':
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return invalid(path, "%v", err)
+		}
+		for i, item := range items {
+			if err := validateNested(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateBlob checks a blob reference.
+func validateBlob(path string, marker json.RawMessage, obj map[string]json.RawMessage) error {
+	encoded, err := json.Marshal(obj)
+	if err != nil {
+		return invalid(path, "blob reference: %v", err)
+	}
+
+	var ref BlobRef
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&ref); err != nil {
+		return invalid(path, "blob reference: %v", err)
+	}
+
+	switch {
+	case !ref.Blob || !bytes.Equal(bytes.TrimSpace(marker), []byte("true")):
+		return invalid(path+"."+MarkerBlob, "must be true")
+	case ref.Location == "":
+		return invalid(path+".loc", "must be a non-empty string")
+	case ref.Hash == "":
+		return invalid(path+".hash", "must be a non-empty string")
+	case obj["size"] == nil || ref.Size < 0:
+		return invalid(path+".size", "must be an integer from 0")
+	}
+	return nil
+}
+
+// decodeObject decodes a JSON object into its raw fields.
+func decodeObject(raw json.RawMessage) (map[string]json.RawMessage, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || raw[0] != '{' {
+		return nil, errors.New("not an object")
+	}
+
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// sortedNames returns the field names of obj in ascending order, so the
+// first offending field reported doesn't depend on map order.
+func sortedNames(obj map[string]json.RawMessage) []string {
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isNull(raw json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+}
+
+func invalid(field, format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	if field != "" {
+		msg = field + ": " + msg
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidRecord, msg)
+}
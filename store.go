@@ -1,19 +1,72 @@
 package stow
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"path/filepath"
 	"sync"
+	"time"
 
+	"github.com/aigotowork/stow/internal/blob"
 	"github.com/aigotowork/stow/internal/fsutil"
+	"github.com/aigotowork/stow/internal/seal"
 )
 
 // store implements the Store interface.
 type store struct {
-	basePath   string
-	namespaces map[string]*namespace
-	mu         sync.RWMutex
+	basePath string
+	handles  *handleCache
+	mu       sync.RWMutex
+
+	// Namespaces being opened by GetNamespace, so concurrent first opens
+	// share one handle
+	opening map[string]*openCall
+
 	logger     Logger
+	disk       *diskMonitor
+	processors *blobProcessors
+	models     *modelRegistry
+	resources  *resources
+	timer      *opTimer
+
+	// Encryption and signing keys by namespace name
+	keys        map[string][]byte
+	signingKeys map[string][]byte
+
+	// Normalizers by namespace name
+	normalizers map[string][]func(map[string]interface{}) error
+
+	// Access control (nil allows everything)
+	authorizer Authorizer
+
+	// Multi-process access: the writer holds writerLock and records changes;
+	// read-only replicas follow them
+	readOnly   bool
+	networkFS  bool
+	writerLock *fsutil.FileLock
+	changes    *changeLog
+	leases     *leaseKeeper
+	follower   *changeFollower
+	closeOnce  sync.Once
+
+	// Change watchers, closed with the store
+	watchMu  sync.Mutex
+	watchers []*ChangeWatcher
+
+	// Stats history: the recorder of a writer, or the _stats namespace
+	// opened read-only by StatsHistory
+	stats   *statsRecorder
+	statsMu sync.Mutex
+	statsNS *namespace
+
+	// Key index scans on namespace open
+	scanWorkers  int
+	openProgress func(OpenProgress)
+
+	// Local copies of blob files (see WithStoreBlobCache), nil without one
+	blobCache *blob.Cache
 }
 
 // openStore opens or creates a store.
@@ -33,15 +86,97 @@ func openStore(basePath string, opts ...StoreOption) (Store, error) {
 		return nil, fmt.Errorf("invalid base path: %w", err)
 	}
 
-	// Ensure base directory exists
-	if err := fsutil.EnsureDir(absPath, 0755); err != nil {
+	// Ensure base directory exists (replicas never create anything)
+	if options.readOnly {
+		if !fsutil.DirExists(absPath) {
+			return nil, fmt.Errorf("store not found: %s", absPath)
+		}
+	} else if err := fsutil.EnsureDir(absPath, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create base directory: %w", err)
 	}
 
 	s := &store{
-		basePath:   absPath,
-		namespaces: make(map[string]*namespace),
-		logger:     options.logger,
+		basePath:    absPath,
+		handles:     newHandleCache(options.maxOpenNamespaces),
+		opening:     make(map[string]*openCall),
+		logger:      options.logger,
+		disk:        newDiskMonitor(absPath, options),
+		processors:  &blobProcessors{},
+		models:      &modelRegistry{},
+		resources:   newResources(options),
+		timer:       newOpTimer(options, options.logger),
+		keys:        make(map[string][]byte),
+		signingKeys: make(map[string][]byte),
+		normalizers: make(map[string][]func(map[string]interface{}) error),
+		authorizer:  options.authorizer,
+		readOnly:    options.readOnly,
+		networkFS:   options.networkFS,
+
+		scanWorkers:  options.scanWorkers,
+		openProgress: options.openProgress,
+	}
+
+	if options.blobCacheDir != "" {
+		if s.blobCache, err = blob.NewCache(options.blobCacheDir, options.blobCacheMaxBytes); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+		}
+	}
+
+	for name, key := range options.namespaceKeys {
+		s.keys[name] = key
+	}
+	for name, key := range options.signingKeys {
+		s.signingKeys[name] = key
+	}
+	for name, fns := range options.normalizers {
+		s.normalizers[name] = fns
+	}
+
+	if s.readOnly {
+		interval := options.replicaPoll
+		if interval <= 0 {
+			interval = DefaultReplicaPollInterval
+		}
+		if options.openReport != nil {
+			if *options.openReport, err = s.openReport(); err != nil {
+				return nil, err
+			}
+		}
+		s.follower = startChangeFollower(s, interval)
+		return s, nil
+	}
+
+	// Single writer: hold the lock for the lifetime of the store
+	s.writerLock, err = lockWriter(filepath.Join(absPath, writerLockName), s.networkFS)
+	if errors.Is(err, fsutil.ErrLocked) {
+		return nil, fmt.Errorf("%w: %s", ErrStoreLocked, absPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.changes, err = openChangeLog(absPath)
+	if err != nil {
+		s.writerLock.Unlock()
+		return nil, err
+	}
+	s.leases = newLeaseKeeper(options.ownerID, s.networkFS, s.resources, s.logger)
+
+	if options.openReport != nil {
+		if *options.openReport, err = s.openReport(); err != nil {
+			s.changes.close()
+			s.writerLock.Unlock()
+			return nil, err
+		}
+	}
+
+	if options.statsHistory {
+		s.stats, err = startStatsRecorder(s, options.statsInterval, options.statsRetention)
+		if err != nil {
+			s.changes.close()
+			s.writerLock.Unlock()
+			return nil, err
+		}
 	}
 
 	return s, nil
@@ -49,11 +184,28 @@ func openStore(basePath string, opts ...StoreOption) (Store, error) {
 
 // CreateNamespace creates a new namespace.
 func (s *store) CreateNamespace(name string, config NamespaceConfig) (Namespace, error) {
-	s.mu.Lock()
+	return s.createNamespace(context.Background(), name, config)
+}
+
+func (s *store) createNamespace(ctx context.Context, name string, config NamespaceConfig) (Namespace, error) {
+	if !fsutil.IsSafeName(name) {
+		return nil, fmt.Errorf("%w: namespace %q", ErrUnsafePath, name)
+	}
+	if isReservedNamespace(name) {
+		return nil, fmt.Errorf("%w: %s", ErrNamespaceReserved, name)
+	}
+	if err := s.authorizer.authorize(ctx, OpCreateNamespace, name, ""); err != nil {
+		return nil, err
+	}
+	if s.readOnly {
+		return nil, ErrReadOnly
+	}
+
+	s.lockSettled(name)
 	defer s.mu.Unlock()
 
 	// Check if already exists in memory
-	if _, exists := s.namespaces[name]; exists {
+	if _, exists := s.handles.lookup(name); exists {
 		return nil, ErrNamespaceExists
 	}
 
@@ -63,57 +215,180 @@ func (s *store) CreateNamespace(name string, config NamespaceConfig) (Namespace,
 		return nil, ErrNamespaceExists
 	}
 
+	// Fall back to a key given when opening the store
+	if config.key == nil {
+		config.key = s.keys[name]
+	}
+	if config.Signing.Key == nil {
+		config.Signing.Key = s.signingKeys[name]
+	}
+	if config.Normalizers == nil {
+		config.Normalizers = s.normalizers[name]
+	}
+
 	// Validate config
 	if err := config.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 
 	// Create namespace
-	ns, err := openNamespace(nsPath, name, config, s.logger)
+	ns, err := openNamespace(nsPath, name, config, s.logger, false, s.networkFS, s.scanOptions())
 	if err != nil {
 		return nil, fmt.Errorf("failed to create namespace: %w", err)
 	}
+	ns.changes = s.changes
+	ns.disk = s.disk
+	ns.processors = s.processors
+	ns.blobManager.SetCache(s.blobCache, name)
+	ns.models = s.models
+	ns.resources = s.resources
+	ns.decoder.Files = s.resources.pool()
+	ns.timer = s.timer
+	ns.authorizer = s.authorizer
+	ns.leases = s.leases
+	ns.recoverIntents()
+	ns.rebuildKeyManifest()
+
+	// Remember the key for reopening
+	if config.key != nil {
+		s.keys[name] = config.key
+	}
+	if config.Signing.Key != nil {
+		s.signingKeys[name] = config.Signing.Key
+	}
+	if config.Normalizers != nil {
+		s.normalizers[name] = config.Normalizers
+	}
 
 	// Cache it
-	s.namespaces[name] = ns
+	s.handles.add(name, ns)
 
-	return ns, nil
+	return ns.WithContext(ctx), nil
 }
 
 // GetNamespace returns an existing namespace or creates it with default config.
 func (s *store) GetNamespace(name string) (Namespace, error) {
+	return s.getNamespace(context.Background(), name)
+}
+
+func (s *store) getNamespace(ctx context.Context, name string) (Namespace, error) {
+	if !fsutil.IsSafeName(name) {
+		return nil, fmt.Errorf("%w: namespace %q", ErrUnsafePath, name)
+	}
+	if isReservedNamespace(name) {
+		return nil, fmt.Errorf("%w: %s", ErrNamespaceReserved, name)
+	}
+	if err := s.authorizer.authorize(ctx, OpOpenNamespace, name, ""); err != nil {
+		return nil, err
+	}
+
 	s.mu.RLock()
 	// Check cache first
-	if ns, exists := s.namespaces[name]; exists {
+	if ns, exists := s.handles.get(name); exists {
 		s.mu.RUnlock()
-		return ns, nil
+		return ns.WithContext(ctx), nil
 	}
 	s.mu.RUnlock()
 
-	// Not in cache, need write lock
 	s.mu.Lock()
-	defer s.mu.Unlock()
 
-	// Double-check after acquiring write lock
-	if ns, exists := s.namespaces[name]; exists {
-		return ns, nil
+	// Double-check after acquiring write lock (an evicted handle still in
+	// use is adopted again)
+	if ns, exists := s.handles.lookup(name); exists {
+		s.mu.Unlock()
+		return ns.WithContext(ctx), nil
+	}
+
+	// Join an open already in flight
+	if call, ok := s.opening[name]; ok {
+		s.mu.Unlock()
+		<-call.done
+		if call.err != nil {
+			return nil, call.err
+		}
+		return call.ns.WithContext(ctx), nil
 	}
 
-	// Try to open or create namespace
+	// Open outside the lock so other namespaces stay available
+	call := &openCall{done: make(chan struct{})}
+	s.opening[name] = call
+	config := s.namespaceConfig(name)
+	s.mu.Unlock()
+
+	call.ns, call.err = s.openExisting(name, config)
+
+	s.mu.Lock()
+	if call.err == nil {
+		s.handles.add(name, call.ns)
+	}
+	delete(s.opening, name)
+	s.mu.Unlock()
+	close(call.done)
+
+	if call.err != nil {
+		return nil, call.err
+	}
+	return call.ns.WithContext(ctx), nil
+}
+
+// openCall is a namespace open in flight. ns and err are set before done is closed.
+type openCall struct {
+	done chan struct{}
+	ns   *namespace
+	err  error
+}
+
+// namespaceConfig returns the config GetNamespace opens a namespace with.
+func (s *store) namespaceConfig(name string) NamespaceConfig {
+	config := DefaultNamespaceConfig().WithKey(s.keys[name])
+	config.Signing.Key = s.signingKeys[name]
+	config.Normalizers = s.normalizers[name]
+	return config
+}
+
+// openExisting opens or creates a namespace for GetNamespace.
+func (s *store) openExisting(name string, config NamespaceConfig) (*namespace, error) {
 	nsPath := filepath.Join(s.basePath, name)
-	config := DefaultNamespaceConfig()
 
-	ns, err := openNamespace(nsPath, name, config, s.logger)
+	// Replicas can only open namespaces the writer created
+	if s.readOnly && !fsutil.DirExists(nsPath) {
+		return nil, fmt.Errorf("%w: %s", ErrNamespaceNotFound, name)
+	}
+
+	ns, err := openNamespace(nsPath, name, config, s.logger, s.readOnly, s.networkFS, s.scanOptions())
 	if err != nil {
 		return nil, fmt.Errorf("failed to open namespace: %w", err)
 	}
-
-	// Cache it
-	s.namespaces[name] = ns
+	ns.changes = s.changes
+	ns.disk = s.disk
+	ns.processors = s.processors
+	ns.blobManager.SetCache(s.blobCache, name)
+	ns.models = s.models
+	ns.resources = s.resources
+	ns.decoder.Files = s.resources.pool()
+	ns.timer = s.timer
+	ns.authorizer = s.authorizer
+	ns.leases = s.leases
+	ns.recoverIntents()
+	ns.rebuildKeyManifest()
+	ns.preload()
 
 	return ns, nil
 }
 
+// lockSettled acquires the store lock once no open of name is in flight.
+func (s *store) lockSettled(name string) {
+	for {
+		s.mu.Lock()
+		call, ok := s.opening[name]
+		if !ok {
+			return
+		}
+		s.mu.Unlock()
+		<-call.done
+	}
+}
+
 // MustGetNamespace is like GetNamespace but panics on error.
 func (s *store) MustGetNamespace(name string) Namespace {
 	ns, err := s.GetNamespace(name)
@@ -125,55 +400,246 @@ func (s *store) MustGetNamespace(name string) Namespace {
 
 // ListNamespaces returns all namespace names.
 func (s *store) ListNamespaces() ([]string, error) {
-	dirs, err := fsutil.ListDirs(s.basePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to list namespaces: %w", err)
-	}
+	return s.listNamespaces(context.Background())
+}
 
-	var names []string
-	for _, dir := range dirs {
-		name := filepath.Base(dir)
-		// Skip hidden directories
-		if fsutil.IsHidden(name) {
-			continue
-		}
-		names = append(names, name)
+func (s *store) listNamespaces(ctx context.Context) ([]string, error) {
+	if err := s.authorizer.authorize(ctx, OpListNamespaces, "", ""); err != nil {
+		return nil, err
 	}
 
-	return names, nil
+	return s.namespaceNames()
 }
 
 // DeleteNamespace deletes a namespace and all its data.
 func (s *store) DeleteNamespace(name string) error {
-	s.mu.Lock()
+	return s.deleteNamespace(context.Background(), name)
+}
+
+func (s *store) deleteNamespace(ctx context.Context, name string) error {
+	if !fsutil.IsSafeName(name) {
+		return fmt.Errorf("%w: namespace %q", ErrUnsafePath, name)
+	}
+	if isReservedNamespace(name) {
+		return fmt.Errorf("%w: %s", ErrNamespaceReserved, name)
+	}
+	if err := s.authorizer.authorize(ctx, OpDeleteNamespace, name, ""); err != nil {
+		return err
+	}
+	if s.readOnly {
+		return ErrReadOnly
+	}
+
+	s.lockSettled(name)
 	defer s.mu.Unlock()
 
 	// Remove from cache
-	delete(s.namespaces, name)
+	if ns := s.handles.remove(name); ns != nil {
+		ns.close()
+	}
 
-	// Delete directory
+	// Delete directory, and the blob directory if it is elsewhere
 	nsPath := filepath.Join(s.basePath, name)
+	s.leases.drop(nsPath, false)
+	blobDir := namespaceBlobDir(nsPath)
+	s.resources.pool().CloseIdle(nsPath)
 	if err := fsutil.RemoveAll(nsPath); err != nil {
 		return fmt.Errorf("failed to delete namespace: %w", err)
 	}
+	if err := fsutil.RemoveAll(blobDir); err != nil {
+		return fmt.Errorf("failed to delete blob directory: %w", err)
+	}
+	s.uncacheBlobs(name)
+
+	s.disk.rescan()
+	if err := s.changes.record(changeEntry{Namespace: name, Op: changeDropNamespace}); err != nil {
+		s.logger.Warn("failed to record change", Field{"namespace", name}, Field{"error", err})
+	}
 
 	return nil
 }
 
+// CloseNamespace evicts a namespace handle from the store. The next
+// GetNamespace opens it afresh; reads and writes through handles obtained
+// before fail with ErrClosed.
+// Closing a namespace that isn't open is a no-op.
+func (s *store) CloseNamespace(name string) error {
+	return s.closeNamespace(context.Background(), name)
+}
+
+func (s *store) closeNamespace(ctx context.Context, name string) error {
+	if err := s.authorizer.authorize(ctx, OpOpenNamespace, name, ""); err != nil {
+		return err
+	}
+
+	s.lockSettled(name)
+	defer s.mu.Unlock()
+
+	if ns := s.handles.remove(name); ns != nil {
+		ns.close()
+	}
+	s.resources.pool().CloseIdle(filepath.Join(s.basePath, name))
+
+	return nil
+}
+
+// uncacheBlobs drops the cached blob copies of a deleted namespace.
+func (s *store) uncacheBlobs(name string) {
+	if s.blobCache != nil {
+		s.blobCache.RemovePrefix(name)
+	}
+}
+
+// SetNamespaceKey provides the encryption key of a namespace.
+func (s *store) SetNamespaceKey(name string, key []byte) error {
+	return s.setNamespaceKey(context.Background(), name, key)
+}
+
+func (s *store) setNamespaceKey(ctx context.Context, name string, key []byte) error {
+	if !fsutil.IsSafeName(name) {
+		return fmt.Errorf("%w: namespace %q", ErrUnsafePath, name)
+	}
+	if len(key) != seal.KeySize {
+		return fmt.Errorf("%w: key of namespace %q must be %d bytes", ErrInvalidConfig, name, seal.KeySize)
+	}
+	if err := s.authorizer.authorize(ctx, OpAdmin, name, ""); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[name] = key
+
+	return nil
+}
+
+// ArchiveNamespace writes a namespace to w as an archive (see OpenArchive).
+func (s *store) ArchiveNamespace(name string, w io.Writer) error {
+	return s.archiveNamespace(context.Background(), name, w)
+}
+
+func (s *store) archiveNamespace(ctx context.Context, name string, w io.Writer) error {
+	if !fsutil.IsSafeName(name) {
+		return fmt.Errorf("%w: namespace %q", ErrUnsafePath, name)
+	}
+	if !fsutil.DirExists(filepath.Join(s.basePath, name)) {
+		return fmt.Errorf("%w: %s", ErrNamespaceNotFound, name)
+	}
+	if err := s.authorizer.authorize(ctx, OpAdmin, name, ""); err != nil {
+		return err
+	}
+
+	handle, err := s.getNamespace(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	return unwrapNamespace(handle).writeArchive(w)
+}
+
+// RegisterBlobProcessor registers a processor for blobs whose MIME type matches
+// pattern (path.Match syntax, e.g. "image/*"). Applies to all namespaces.
+func (s *store) RegisterBlobProcessor(pattern string, fn BlobProcessor) error {
+	return s.processors.register(pattern, fn)
+}
+
+// RegisterModel registers per-type defaults applied to every Put and PutAuto
+// of the model's type. Applies to all namespaces.
+func (s *store) RegisterModel(model interface{}, opts ModelOptions) error {
+	return s.models.register(model, opts)
+}
+
+// WithContext returns a view of the store that authorizes calls with ctx.
+func (s *store) WithContext(ctx context.Context) Store {
+	return &storeContext{store: s, ctx: ctx}
+}
+
+// storeContext is a store view bound to a context for authorization.
+// Namespaces it returns are bound to the same context.
+type storeContext struct {
+	*store
+	ctx context.Context
+}
+
+func (v *storeContext) CreateNamespace(name string, config NamespaceConfig) (Namespace, error) {
+	return v.createNamespace(v.ctx, name, config)
+}
+
+func (v *storeContext) GetNamespace(name string) (Namespace, error) {
+	return v.getNamespace(v.ctx, name)
+}
+
+func (v *storeContext) MustGetNamespace(name string) Namespace {
+	ns, err := v.GetNamespace(name)
+	if err != nil {
+		panic(err)
+	}
+	return ns
+}
+
+func (v *storeContext) ListNamespaces() ([]string, error) {
+	return v.listNamespaces(v.ctx)
+}
+
+func (v *storeContext) DeleteNamespace(name string) error {
+	return v.deleteNamespace(v.ctx, name)
+}
+
+func (v *storeContext) CloseNamespace(name string) error {
+	return v.closeNamespace(v.ctx, name)
+}
+
+func (v *storeContext) SetNamespaceKey(name string, key []byte) error {
+	return v.setNamespaceKey(v.ctx, name, key)
+}
+
+func (v *storeContext) ArchiveNamespace(name string, w io.Writer) error {
+	return v.archiveNamespace(v.ctx, name, w)
+}
+
+func (v *storeContext) Backup(w io.Writer) error {
+	return v.backup(v.ctx, w)
+}
+
+func (v *storeContext) CopyKey(srcNS, srcKey, dstNS, dstKey string, opts ...CopyKeyOption) error {
+	return v.store.copyKey(v.ctx, srcNS, srcKey, dstNS, dstKey, opts...)
+}
+
+func (v *storeContext) StatsHistory(since time.Time) ([]StatsSnapshot, error) {
+	return v.statsHistory(v.ctx, since)
+}
+
+func (v *storeContext) WatchChanges(since uint64, opts ...WatchOption) (*ChangeWatcher, error) {
+	return v.watchChanges(v.ctx, since, opts...)
+}
+
+func (v *storeContext) WithContext(ctx context.Context) Store {
+	return &storeContext{store: v.store, ctx: ctx}
+}
+
 // Close closes the store and all open namespaces.
 func (s *store) Close() error {
+	// Stop following before taking the lock the follower needs
+	s.closeOnce.Do(func() {
+		s.closeChangeWatchers()
+		s.stats.close()
+		s.follower.close()
+		s.leases.close()
+		s.changes.close()
+		s.writerLock.Unlock()
+	})
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	// Close all namespaces
-	for _, ns := range s.namespaces {
-		// Namespace doesn't have Close method in current design
-		// If needed, add it to the interface
-		_ = ns
+	for _, ns := range s.handles.live() {
+		ns.closeWatchers()
 	}
 
 	// Clear cache
-	s.namespaces = make(map[string]*namespace)
+	s.handles = newHandleCache(s.handles.max)
+	s.resources.pool().CloseIdle("")
 
 	return nil
 }
@@ -0,0 +1,61 @@
+package stow
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aigotowork/stow/internal/fsutil"
+)
+
+// CompactStore compacts every key of every namespace of the store at path and
+// removes unreferenced blobs, for maintenance windows and test fixtures. It
+// runs under the store's writer lock, so it fails with ErrStoreLocked while
+// another process has the store open, and it never creates a store.
+//
+// opts are the options of Open; encrypted namespaces need their key
+// (WithStoreNamespaceKey) and signed ones their signing key.
+//
+// Example:
+//
+//	result, err := stow.CompactStore("/data/myapp")
+func CompactStore(path string, opts ...StoreOption) (CompactStoreResult, error) {
+	result := CompactStoreResult{}
+	start := time.Now()
+
+	if !fsutil.DirExists(path) {
+		return result, fmt.Errorf("store not found: %s", path)
+	}
+
+	s, err := Open(path, opts...)
+	if err != nil {
+		return result, err
+	}
+	defer s.Close()
+
+	names, err := s.ListNamespaces()
+	if err != nil {
+		return result, err
+	}
+
+	for _, name := range names {
+		ns, err := s.GetNamespace(name)
+		if err != nil {
+			return result, fmt.Errorf("namespace %s: %w", name, err)
+		}
+
+		if err := ns.CompactAll(); err != nil {
+			return result, fmt.Errorf("namespace %s: %w", name, err)
+		}
+
+		gc, err := ns.GC()
+		if err != nil {
+			return result, fmt.Errorf("namespace %s: %w", name, err)
+		}
+		result.GC.RemovedBlobs += gc.RemovedBlobs
+		result.GC.ReclaimedSize += gc.ReclaimedSize
+		result.Namespaces++
+	}
+
+	result.GC.Duration = time.Since(start)
+	return result, nil
+}
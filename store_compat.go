@@ -0,0 +1,154 @@
+package stow
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/aigotowork/stow/internal/core"
+	"github.com/aigotowork/stow/internal/fsutil"
+	"github.com/aigotowork/stow/spec"
+)
+
+// VerifyCompatibility checks that this version of stow reads every record
+// of the store at path, e.g. a copy of production data kept as a CI
+// fixture, so a library upgrade that can't read it fails the build. The
+// store is opened read-only and never changed.
+//
+// Every line of every key file must be a valid record of the current
+// format (see package spec), every version in each key's history must
+// decode, and the latest value of each key must decode with its blobs
+// intact. Versions whose blobs were collected by GC only need to be valid
+// records. opts are the options of Open; encrypted namespaces need their
+// key (WithStoreNamespaceKey).
+//
+// Example:
+//
+//	report, err := stow.VerifyCompatibility("testdata/prod-snapshot")
+//	if err != nil || !report.OK() {
+//		t.Fatalf("stored data no longer reads: %v %+v", err, report.Problems)
+//	}
+func VerifyCompatibility(path string, opts ...StoreOption) (CompatibilityReport, error) {
+	report := CompatibilityReport{FormatVersion: spec.Version}
+
+	if !fsutil.DirExists(path) {
+		return report, fmt.Errorf("store not found: %s", path)
+	}
+
+	s, err := Open(path, append(opts, WithStoreReadOnly())...)
+	if err != nil {
+		return report, err
+	}
+	defer s.Close()
+
+	names, err := s.ListNamespaces()
+	if err != nil {
+		return report, err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		handle, err := s.GetNamespace(name)
+		if err != nil {
+			report.problem(CompatibilityProblem{Namespace: name, Error: err.Error()})
+			continue
+		}
+		report.Namespaces++
+		if err := unwrapNamespace(handle).verifyCompatibility(&report); err != nil {
+			return report, fmt.Errorf("namespace %s: %w", name, err)
+		}
+	}
+
+	return report, nil
+}
+
+// verifyCompatibility checks the keys of the namespace into report.
+func (ns *namespace) verifyCompatibility(report *CompatibilityReport) error {
+	ns.mu.RLock()
+	keys := ns.keyMapper.ListAll()
+	ns.mu.RUnlock()
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		ns.mu.RLock()
+		filePath, err := ns.getFilePath(key, false)
+		ns.mu.RUnlock()
+		if err != nil {
+			continue
+		}
+
+		records, err := ns.verifyLines(filePath, report)
+		if err != nil {
+			return err
+		}
+		report.Keys++
+
+		history, err := ns.GetHistory(key)
+		if err != nil {
+			report.problem(CompatibilityProblem{Namespace: ns.name, Key: key, Error: err.Error()})
+			continue
+		}
+
+		for i, version := range history {
+			report.Versions++
+			record := records[version.Version]
+			isLatest := i == 0 // newest first
+
+			if version.Operation == core.OpPut {
+				var value interface{}
+				err := version.Decode(&value)
+				// Older versions may reference blobs collected by GC
+				if err != nil && (isLatest || record == nil || ns.blobsIntact(record)) {
+					report.problem(CompatibilityProblem{Namespace: ns.name, Key: key, Version: version.Version, Error: err.Error()})
+				}
+			}
+
+			if isLatest && record != nil && !record.Meta.IsDelete() && !ns.blobsIntact(record) {
+				report.problem(CompatibilityProblem{Namespace: ns.name, Key: key, Version: version.Version,
+					Error: "blob missing or changed"})
+			}
+		}
+	}
+
+	return nil
+}
+
+// verifyLines validates every line of a key file against the format spec
+// into report, and returns the records that decode by version.
+func (ns *namespace) verifyLines(filePath string, report *CompatibilityReport) (map[int]*core.Record, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer f.Close()
+
+	fileName := filepath.Base(filePath)
+	records := make(map[int]*core.Record)
+	reader := bufio.NewReader(f)
+	for lineNum := 1; ; lineNum++ {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return nil, fmt.Errorf("error reading file: %w", readErr)
+		}
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			report.Lines++
+			if err := spec.Validate(line); err != nil {
+				report.problem(CompatibilityProblem{Namespace: ns.name, File: fileName, Line: lineNum, Error: err.Error()})
+			} else if record, err := ns.decoder.Decode(line); err != nil {
+				report.problem(CompatibilityProblem{Namespace: ns.name, File: fileName, Line: lineNum, Error: err.Error()})
+			} else {
+				records[record.Meta.Version] = record
+			}
+		}
+
+		if errors.Is(readErr, io.EOF) {
+			return records, nil
+		}
+	}
+}
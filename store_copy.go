@@ -0,0 +1,106 @@
+package stow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/aigotowork/stow/internal/fsutil"
+)
+
+// CopyKeyOption configures Store.CopyKey.
+type CopyKeyOption func(*copyKeyOptions)
+
+type copyKeyOptions struct {
+	latestOnly bool
+	replace    bool
+}
+
+// WithCopyLatestOnly copies only the current value of the key, not its
+// history or pins.
+func WithCopyLatestOnly() CopyKeyOption {
+	return func(o *copyKeyOptions) {
+		o.latestOnly = true
+	}
+}
+
+// WithCopyReplace replaces the history of an existing destination key
+// instead of failing with ErrKeyConflict.
+func WithCopyReplace() CopyKeyOption {
+	return func(o *copyKeyOptions) {
+		o.replace = true
+	}
+}
+
+// CopyKey copies a key, with its history, pins and blobs, to another key
+// of the same or another namespace. An empty dstKey keeps the key's name.
+func (s *store) CopyKey(srcNS, srcKey, dstNS, dstKey string, opts ...CopyKeyOption) error {
+	return s.copyKey(context.Background(), srcNS, srcKey, dstNS, dstKey, opts...)
+}
+
+func (s *store) copyKey(ctx context.Context, srcNS, srcKey, dstNS, dstKey string, opts ...CopyKeyOption) error {
+	options := &copyKeyOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if dstKey == "" {
+		dstKey = srcKey
+	}
+
+	if !fsutil.IsSafeName(srcNS) {
+		return fmt.Errorf("%w: namespace %q", ErrUnsafePath, srcNS)
+	}
+	if !fsutil.DirExists(filepath.Join(s.basePath, srcNS)) {
+		return fmt.Errorf("%w: %s", ErrNamespaceNotFound, srcNS)
+	}
+	if err := s.authorizer.authorize(ctx, OpRead, srcNS, srcKey); err != nil {
+		return err
+	}
+	if err := s.authorizer.authorize(ctx, OpWrite, dstNS, dstKey); err != nil {
+		return err
+	}
+
+	srcHandle, err := s.getNamespace(ctx, srcNS)
+	if err != nil {
+		return err
+	}
+	dstHandle, err := s.getNamespace(ctx, dstNS)
+	if err != nil {
+		return err
+	}
+	src, dst := unwrapNamespace(srcHandle), unwrapNamespace(dstHandle)
+
+	loadOpts := []LoadOption{WithLoadAs(dstKey)}
+	if options.replace {
+		loadOpts = append(loadOpts, WithLoadReplace())
+	}
+
+	// Stream a dump of the key into the destination: blobs are stored (and
+	// deduplicated) in its blob directory and records re-referenced to them
+	pr, pw := io.Pipe()
+	dumped := make(chan error, 1)
+	go func() {
+		err := src.dumpKey(srcKey, pw, options.latestOnly)
+		pw.CloseWithError(err)
+		dumped <- err
+	}()
+
+	_, err = dst.LoadKey(pr, loadOpts...)
+	pr.CloseWithError(io.ErrClosedPipe)
+
+	// A failed dump (e.g. ErrNotFound) explains the failed load
+	if dumpErr := <-dumped; dumpErr != nil && !errors.Is(dumpErr, io.ErrClosedPipe) {
+		return dumpErr
+	}
+	return err
+}
+
+// unwrapNamespace returns the namespace behind a handle.
+func unwrapNamespace(handle Namespace) *namespace {
+	if ns, ok := handle.(*namespace); ok {
+		return ns
+	}
+	return handle.(*authorizedNamespace).namespace
+}
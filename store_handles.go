@@ -0,0 +1,151 @@
+package stow
+
+import (
+	"container/list"
+	"sync"
+	"weak"
+)
+
+// handleCache holds the store's open namespace handles. With a cap, the least
+// recently used handles beyond it are evicted: the store drops its reference
+// so their caches and index can be collected. An evicted handle that callers
+// still hold stays reachable through a weak pointer and is reused, so there is
+// never more than one live handle per namespace.
+//
+// The store lock guards open and evicted; mu guards the recency list, so
+// cache hits can be recorded under the store's read lock.
+type handleCache struct {
+	max     int
+	open    map[string]*namespace
+	evicted map[string]weak.Pointer[namespace]
+
+	mu     sync.Mutex
+	recent *list.List // names, most recently used first
+	elems  map[string]*list.Element
+}
+
+func newHandleCache(max int) *handleCache {
+	return &handleCache{
+		max:     max,
+		open:    make(map[string]*namespace),
+		evicted: make(map[string]weak.Pointer[namespace]),
+		recent:  list.New(),
+		elems:   make(map[string]*list.Element),
+	}
+}
+
+// get returns an open handle (caller holds the store lock, read or write).
+func (c *handleCache) get(name string) (*namespace, bool) {
+	ns, ok := c.open[name]
+	if ok {
+		c.touch(name)
+	}
+	return ns, ok
+}
+
+// peek returns a live handle, open or evicted, without marking it used
+// (caller holds the store lock, read or write).
+func (c *handleCache) peek(name string) (*namespace, bool) {
+	if ns, ok := c.open[name]; ok {
+		return ns, true
+	}
+	if ptr, ok := c.evicted[name]; ok {
+		if ns := ptr.Value(); ns != nil {
+			return ns, true
+		}
+	}
+	return nil, false
+}
+
+// lookup returns an open or still referenced evicted handle, re-adopting the
+// latter (caller holds the store write lock).
+func (c *handleCache) lookup(name string) (*namespace, bool) {
+	if ns, ok := c.get(name); ok {
+		return ns, true
+	}
+
+	ptr, ok := c.evicted[name]
+	if !ok {
+		return nil, false
+	}
+	ns := ptr.Value()
+	if ns == nil {
+		delete(c.evicted, name)
+		return nil, false
+	}
+
+	c.add(name, ns)
+	return ns, true
+}
+
+// add caches a handle and evicts beyond the cap (caller holds the store write lock).
+func (c *handleCache) add(name string, ns *namespace) {
+	c.open[name] = ns
+	delete(c.evicted, name)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.elems[name]; ok {
+		c.recent.MoveToFront(elem)
+	} else {
+		c.elems[name] = c.recent.PushFront(name)
+	}
+
+	for c.max > 0 && c.recent.Len() > c.max {
+		oldest := c.recent.Remove(c.recent.Back()).(string)
+		delete(c.elems, oldest)
+		c.evicted[oldest] = weak.Make(c.open[oldest])
+		delete(c.open, oldest)
+	}
+}
+
+// touch marks a handle as recently used.
+func (c *handleCache) touch(name string) {
+	if c.max <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.elems[name]; ok {
+		c.recent.MoveToFront(elem)
+	}
+}
+
+// remove drops a handle, open or evicted, and returns it if it is still live
+// (caller holds the store write lock).
+func (c *handleCache) remove(name string) *namespace {
+	ns := c.open[name]
+	if ns == nil {
+		if ptr, ok := c.evicted[name]; ok {
+			ns = ptr.Value()
+		}
+	}
+	delete(c.open, name)
+	delete(c.evicted, name)
+
+	c.mu.Lock()
+	if elem, ok := c.elems[name]; ok {
+		c.recent.Remove(elem)
+		delete(c.elems, name)
+	}
+	c.mu.Unlock()
+
+	return ns
+}
+
+// live returns every live handle, open or evicted (caller holds the store lock).
+func (c *handleCache) live() []*namespace {
+	handles := make([]*namespace, 0, len(c.open))
+	for _, ns := range c.open {
+		handles = append(handles, ns)
+	}
+	for _, ptr := range c.evicted {
+		if ns := ptr.Value(); ns != nil {
+			handles = append(handles, ns)
+		}
+	}
+	return handles
+}
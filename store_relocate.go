@@ -0,0 +1,254 @@
+package stow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aigotowork/stow/internal/blob"
+	"github.com/aigotowork/stow/internal/core"
+	"github.com/aigotowork/stow/internal/fsutil"
+	"github.com/aigotowork/stow/internal/index"
+)
+
+// RelocateStore moves the store at oldPath to newPath, which must not
+// exist, for stores no process has open. It runs under the store's writer
+// lock, so it fails with ErrStoreLocked while the store is in use.
+//
+// The store is renamed, or copied (every file read back) and then removed
+// when newPath is on another filesystem. Blob references record
+// "_blobs/<file>" locations that move with the store, but namespace configs
+// may hold absolute paths: a blob_dir or blob_search_paths entry inside the
+// store is rewritten to its new location, and a relative blob_dir leading
+// out of the store (which doesn't move) is made absolute. Afterwards, the
+// blobs of each key's latest version must resolve as they did before the
+// move; otherwise the move is undone and ErrCorruptedData is returned.
+//
+// Example:
+//
+//	err := stow.RelocateStore("/data/myapp", "/mnt/ssd/myapp")
+func RelocateStore(oldPath, newPath string) error {
+	oldPath, err := fsutil.AbsPath(oldPath)
+	if err != nil {
+		return fmt.Errorf("invalid store path: %w", err)
+	}
+	newPath, err = fsutil.AbsPath(newPath)
+	if err != nil {
+		return fmt.Errorf("invalid store path: %w", err)
+	}
+
+	if !fsutil.DirExists(oldPath) {
+		return fmt.Errorf("store not found: %s", oldPath)
+	}
+	if _, err := os.Lstat(newPath); err == nil {
+		return fmt.Errorf("cannot relocate store to %s: %w", newPath, os.ErrExist)
+	}
+	if rel, err := filepath.Rel(oldPath, newPath); err == nil && !outsideDir(rel) {
+		return fmt.Errorf("cannot relocate store %s into itself", oldPath)
+	}
+
+	lock, err := lockWriter(filepath.Join(oldPath, writerLockName), false)
+	if err != nil {
+		if errors.Is(err, fsutil.ErrLocked) {
+			return fmt.Errorf("%w: %s", ErrStoreLocked, oldPath)
+		}
+		return err
+	}
+	defer lock.Unlock()
+
+	fixups, err := planConfigFixups(oldPath, newPath)
+	if err != nil {
+		return err
+	}
+	before, err := unresolvedBlobs(oldPath)
+	if err != nil {
+		return err
+	}
+
+	if err := fsutil.EnsureDir(filepath.Dir(newPath), 0755); err != nil {
+		return err
+	}
+
+	// Windows can't rename a directory holding the open lock file, so
+	// stores are always copied there
+	copied := false
+	if err := os.Rename(oldPath, newPath); err != nil {
+		if _, statErr := os.Lstat(newPath); statErr == nil {
+			return fmt.Errorf("failed to move store: %w", err)
+		}
+		if err := fsutil.CopyTree(oldPath, newPath); err != nil {
+			fsutil.RemoveAll(newPath)
+			return fmt.Errorf("failed to copy store: %w", err)
+		}
+		copied = true
+	}
+
+	// undo puts the store back at oldPath, where it was left untouched if
+	// it was copied
+	undo := func(cause error) error {
+		if copied {
+			fsutil.RemoveAll(newPath)
+			return cause
+		}
+		for _, fixup := range fixups {
+			fsutil.AtomicWriteFile(filepath.Join(newPath, fixup.name, "_config.json"), fixup.original, 0644)
+		}
+		if err := os.Rename(newPath, oldPath); err != nil {
+			return fmt.Errorf("%w (moving the store back to %s failed: %v)", cause, oldPath, err)
+		}
+		return cause
+	}
+
+	for _, fixup := range fixups {
+		if err := fsutil.AtomicWriteFile(filepath.Join(newPath, fixup.name, "_config.json"), fixup.config, 0644); err != nil {
+			return undo(fmt.Errorf("namespace %s: failed to write config: %w", fixup.name, err))
+		}
+	}
+
+	after, err := unresolvedBlobs(newPath)
+	if err != nil {
+		return undo(err)
+	}
+	var broken []string
+	for ref := range after {
+		if !before[ref] {
+			broken = append(broken, ref)
+		}
+	}
+	if len(broken) > 0 {
+		sort.Strings(broken)
+		return undo(fmt.Errorf("%w: blobs no longer resolve after relocating the store: %s",
+			ErrCorruptedData, strings.Join(broken, ", ")))
+	}
+
+	if copied {
+		lock.Unlock()
+		if err := fsutil.RemoveAll(oldPath); err != nil {
+			return fmt.Errorf("store copied to %s, but removing %s failed: %w", newPath, oldPath, err)
+		}
+	}
+	return nil
+}
+
+// configFixup is the rewritten _config.json of a namespace.
+type configFixup struct {
+	name     string
+	config   []byte
+	original []byte
+}
+
+// planConfigFixups returns the namespace configs of the store at oldPath
+// whose blob paths change when the store moves to newPath.
+func planConfigFixups(oldPath, newPath string) ([]configFixup, error) {
+	dirs, err := fsutil.ListDirs(oldPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// within reports whether an absolute path moves with the store, and
+	// moved where it ends up
+	within := func(p string) bool {
+		rel, err := filepath.Rel(oldPath, p)
+		return err == nil && !outsideDir(rel)
+	}
+	moved := func(p string) string {
+		rel, _ := filepath.Rel(oldPath, p)
+		return filepath.Join(newPath, rel)
+	}
+
+	var fixups []configFixup
+	for _, dir := range dirs {
+		original, err := os.ReadFile(filepath.Join(dir, "_config.json"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var config NamespaceConfig
+		if err := json.Unmarshal(original, &config); err != nil {
+			return nil, fmt.Errorf("namespace %s: invalid config: %w", filepath.Base(dir), err)
+		}
+
+		changed := false
+		if config.BlobDir != "" {
+			if filepath.IsAbs(config.BlobDir) {
+				if within(config.BlobDir) {
+					config.BlobDir, changed = moved(config.BlobDir), true
+				}
+			} else if resolved := filepath.Join(dir, config.BlobDir); !within(resolved) {
+				// Relative to the new location, it would lead elsewhere
+				config.BlobDir, changed = resolved, true
+			}
+		}
+		for i, searchPath := range config.BlobSearchPaths {
+			if filepath.IsAbs(searchPath) && within(searchPath) {
+				config.BlobSearchPaths[i], changed = moved(searchPath), true
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		data, err := json.MarshalIndent(config, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		fixups = append(fixups, configFixup{name: filepath.Base(dir), config: data, original: original})
+	}
+
+	return fixups, nil
+}
+
+// unresolvedBlobs returns the blob references of the latest version of each
+// key in the store at path whose file is missing from the blob directory,
+// as "namespace/file.jsonl: location".
+func unresolvedBlobs(storePath string) (map[string]bool, error) {
+	dirs, err := fsutil.ListDirs(storePath)
+	if err != nil {
+		return nil, err
+	}
+
+	unresolved := make(map[string]bool)
+	decoder := core.NewDecoder()
+	for _, dir := range dirs {
+		if !fsutil.FileExists(filepath.Join(dir, "_config.json")) {
+			continue
+		}
+		blobDir := namespaceBlobDir(dir)
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() || !index.IsKeyFileName(entry.Name()) {
+				continue
+			}
+			record, err := decoder.ReadLastValid(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", filepath.Join(filepath.Base(dir), entry.Name()), err)
+			}
+			if record == nil || record.Meta.IsDelete() {
+				continue
+			}
+
+			walkBlobRefs(record.Data, func(ref *blob.Reference, _ bool) error {
+				// References resolve by file name, whatever their directory
+				name := path.Base(strings.ReplaceAll(ref.Location, "\\", "/"))
+				if p, err := fsutil.SafeJoin(blobDir, name); err != nil || !fsutil.FileExists(p) {
+					unresolved[filepath.Base(dir)+"/"+entry.Name()+": "+ref.Location] = true
+				}
+				return nil
+			})
+		}
+	}
+
+	return unresolved, nil
+}
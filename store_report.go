@@ -0,0 +1,207 @@
+package stow
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aigotowork/stow/internal/core"
+	"github.com/aigotowork/stow/internal/index"
+)
+
+// OpenReport describes the state of a store found by Open, filled in for
+// stores opened with WithStoreOpenReport.
+type OpenReport struct {
+	// Namespaces has one entry per namespace, in directory order
+	Namespaces []NamespaceReport
+
+	// Duration is the time spent checking and opening the namespaces
+	Duration time.Duration
+}
+
+// NamespaceReport describes one namespace of an OpenReport.
+type NamespaceReport struct {
+	// Name is the namespace name
+	Name string
+
+	// Keys is the number of keys indexed
+	Keys int
+
+	// CorruptLines counts lines that don't decode. Readers skip them, so
+	// the versions they held are missing from the key's history.
+	CorruptLines int
+
+	// UnreadableFiles lists key files without a single valid record.
+	// They are not indexed, so their keys are missing.
+	UnreadableFiles []string
+
+	// TornTails counts final lines cut short by a crash during a write.
+	// A writer truncates them so later appends start on a fresh line;
+	// a read-only store only counts them.
+	TornTails int
+
+	// IndexTime is the time spent opening the namespace and rebuilding its
+	// key index
+	IndexTime time.Duration
+
+	// Err is set when the namespace could not be checked or opened
+	Err error
+}
+
+// OpenProgress reports how far the scan of a namespace being opened has
+// got, see WithStoreOpenProgress.
+type OpenProgress struct {
+	// Namespace is the namespace being opened
+	Namespace string
+
+	// Files is the number of key files read so far
+	Files int
+
+	// Total is the number of key files in the namespace
+	Total int
+}
+
+// Degraded reports whether any namespace has corrupt data or failed to open.
+func (r *OpenReport) Degraded() bool {
+	for _, ns := range r.Namespaces {
+		if ns.CorruptLines > 0 || len(ns.UnreadableFiles) > 0 || ns.TornTails > 0 || ns.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// openReport checks and opens every namespace of the store. Must run before
+// the store is shared, since writers repair torn tails without key locks.
+func (s *store) openReport() (OpenReport, error) {
+	start := time.Now()
+
+	names, err := s.namespaceNames()
+	if err != nil {
+		return OpenReport{}, err
+	}
+
+	report := OpenReport{Namespaces: make([]NamespaceReport, 0, len(names))}
+	for _, name := range names {
+		report.Namespaces = append(report.Namespaces, s.reportNamespace(name))
+	}
+	report.Duration = time.Since(start)
+
+	return report, nil
+}
+
+// reportNamespace checks the key files of a namespace, then opens it.
+func (s *store) reportNamespace(name string) NamespaceReport {
+	report := NamespaceReport{Name: name}
+	nsPath := filepath.Join(s.basePath, name)
+
+	entries, err := os.ReadDir(nsPath)
+	if err != nil {
+		report.Err = err
+		return report
+	}
+
+	for _, entry := range entries {
+		// Never follow symlinks out of the namespace
+		if !entry.Type().IsRegular() || !index.IsKeyFileName(entry.Name()) {
+			continue
+		}
+
+		check, err := checkKeyFile(filepath.Join(nsPath, entry.Name()), !s.readOnly)
+		if err != nil {
+			report.Err = fmt.Errorf("%s: %w", entry.Name(), err)
+			return report
+		}
+
+		report.CorruptLines += check.corrupt
+		if check.valid == 0 {
+			report.UnreadableFiles = append(report.UnreadableFiles, entry.Name())
+		}
+		if check.tornTail {
+			report.TornTails++
+			if !s.readOnly {
+				s.logger.Warn("truncated torn record", Field{"namespace", name}, Field{"file", entry.Name()})
+			}
+		}
+	}
+
+	start := time.Now()
+	ns, err := s.openExisting(name, s.namespaceConfig(name))
+	report.IndexTime = time.Since(start)
+	if err != nil {
+		report.Err = err
+		return report
+	}
+
+	s.mu.Lock()
+	s.handles.add(name, ns)
+	s.mu.Unlock()
+
+	ns.mu.RLock()
+	report.Keys = ns.keyMapper.Count()
+	ns.mu.RUnlock()
+
+	return report
+}
+
+// keyFileCheck is the result of checkKeyFile.
+type keyFileCheck struct {
+	valid    int
+	corrupt  int
+	tornTail bool
+}
+
+// checkKeyFile counts the valid and corrupt lines of a key file. A final
+// line without newline that doesn't decode is a torn tail (not counted as
+// corrupt); with repair, the file is truncated before it.
+func checkKeyFile(path string, repair bool) (keyFileCheck, error) {
+	var check keyFileCheck
+
+	f, err := os.Open(path)
+	if err != nil {
+		return check, err
+	}
+	defer f.Close()
+
+	decoder := core.NewDecoder()
+	reader := bufio.NewReader(f)
+
+	// End of the last complete line
+	var complete int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return check, err
+		}
+
+		if len(bytes.TrimSpace(line)) > 0 {
+			_, decodeErr := decoder.Decode(line)
+			switch {
+			case decodeErr == nil:
+				check.valid++
+			case errors.Is(err, io.EOF):
+				check.tornTail = true
+			default:
+				check.corrupt++
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		complete += int64(len(line))
+	}
+
+	if check.tornTail && repair {
+		if err := os.Truncate(path, complete); err != nil {
+			return check, fmt.Errorf("failed to truncate torn tail: %w", err)
+		}
+	}
+
+	return check, nil
+}
@@ -0,0 +1,293 @@
+package stow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aigotowork/stow/internal/fsutil"
+	"github.com/aigotowork/stow/internal/index"
+)
+
+const (
+	// statsNamespace is the reserved namespace holding stats snapshots.
+	statsNamespace = "_stats"
+
+	// statsKey is the key whose versions are the snapshots, oldest first.
+	statsKey = "snapshots"
+
+	// DefaultStatsInterval is how often stats snapshots are taken.
+	DefaultStatsInterval = time.Hour
+
+	// DefaultStatsRetention is how long stats snapshots are kept.
+	DefaultStatsRetention = 30 * 24 * time.Hour
+)
+
+// statsRecorder periodically snapshots the stats of every namespace into the
+// reserved _stats namespace. Each snapshot is a new version of one key, so
+// retention is compaction keeping the last retention/interval versions.
+type statsRecorder struct {
+	store    *store
+	ns       *namespace
+	interval time.Duration
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// startStatsRecorder opens the _stats namespace and takes a snapshot now and
+// then every interval.
+func startStatsRecorder(s *store, interval, retention time.Duration) (*statsRecorder, error) {
+	if interval <= 0 {
+		interval = DefaultStatsInterval
+	}
+	if retention <= 0 {
+		retention = DefaultStatsRetention
+	}
+
+	ns, err := s.openStatsNamespace(false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep about retention worth of snapshots, compacting once twice that
+	keep := int((retention + interval - 1) / interval)
+	if keep < 1 {
+		keep = 1
+	}
+	config := ns.cfg()
+	if !config.AutoCompact || config.CompactStrategy != CompactStrategyLineCount ||
+		config.CompactKeepRecords != keep || config.CompactThreshold != 2*keep {
+		config.AutoCompact = true
+		config.CompactStrategy = CompactStrategyLineCount
+		config.CompactKeepRecords = keep
+		config.CompactThreshold = 2 * keep
+		if err := ns.SetConfig(config); err != nil {
+			return nil, fmt.Errorf("failed to configure stats namespace: %w", err)
+		}
+	}
+
+	r := &statsRecorder{
+		store:    s,
+		ns:       ns,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	s.resources.start(r.run)
+
+	return r, nil
+}
+
+func (r *statsRecorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.snapshot()
+
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// snapshot records the current stats of every namespace.
+func (r *statsRecorder) snapshot() {
+	names, err := r.store.namespaceNames()
+	if err != nil {
+		r.store.logger.Warn("failed to list namespaces for stats", Field{"error", err})
+		return
+	}
+
+	snapshot := StatsSnapshot{
+		Time:       time.Now().UTC(),
+		Namespaces: make(map[string]NamespaceStats, len(names)),
+	}
+	for _, name := range names {
+		snapshot.Namespaces[name] = r.store.namespaceStats(name)
+	}
+
+	if err := r.ns.Put(statsKey, snapshot); err != nil {
+		r.store.logger.Warn("failed to record stats snapshot", Field{"error", err})
+	}
+}
+
+// close stops recording (nil-safe).
+func (r *statsRecorder) close() {
+	if r == nil {
+		return
+	}
+
+	close(r.stop)
+	<-r.done
+}
+
+// openStatsNamespace opens the reserved _stats namespace outside the handle cache.
+func (s *store) openStatsNamespace(readOnly bool) (*namespace, error) {
+	nsPath := filepath.Join(s.basePath, statsNamespace)
+
+	ns, err := openNamespace(nsPath, statsNamespace, DefaultNamespaceConfig(), s.logger, readOnly, s.networkFS, s.scanOptions())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open stats namespace: %w", err)
+	}
+	ns.disk = s.disk
+
+	return ns, nil
+}
+
+// namespaceNames returns the names of all user namespaces.
+func (s *store) namespaceNames() ([]string, error) {
+	dirs, err := fsutil.ListDirs(s.basePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+
+	var names []string
+	for _, dir := range dirs {
+		name := filepath.Base(dir)
+		// Skip hidden directories and reserved namespaces
+		if fsutil.IsHidden(name) || isReservedNamespace(name) {
+			continue
+		}
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// namespaceStats returns the stats of an open namespace, or reads them from
+// disk without opening it (counting key files as keys).
+func (s *store) namespaceStats(name string) NamespaceStats {
+	s.mu.RLock()
+	ns, open := s.handles.peek(name)
+	s.mu.RUnlock()
+
+	if open {
+		if stats, err := ns.Stats(); err == nil {
+			return stats
+		}
+	}
+
+	nsPath := filepath.Join(s.basePath, name)
+	var stats NamespaceStats
+
+	if entries, err := os.ReadDir(nsPath); err == nil {
+		for _, entry := range entries {
+			if entry.Type().IsRegular() && index.IsKeyFileName(entry.Name()) {
+				stats.KeyCount++
+			}
+		}
+	}
+	if size, err := fsutil.DirSize(nsPath); err == nil {
+		stats.TotalSize = size
+	}
+
+	blobDir := namespaceBlobDir(nsPath)
+	if files, err := fsutil.ListFiles(blobDir); err == nil {
+		for _, file := range files {
+			// Skip temporary files, like the blob manager
+			if !strings.Contains(filepath.Base(file), "tmp_") {
+				stats.BlobCount++
+			}
+		}
+	}
+	if size, err := fsutil.DirSize(blobDir); err == nil {
+		stats.BlobSize = size
+	}
+
+	return stats
+}
+
+// StatsHistory returns the stats snapshots taken since the given time, oldest
+// first. Snapshots are recorded by stores opened with WithStoreStatsHistory.
+func (s *store) StatsHistory(since time.Time) ([]StatsSnapshot, error) {
+	return s.statsHistory(context.Background(), since)
+}
+
+func (s *store) statsHistory(ctx context.Context, since time.Time) ([]StatsSnapshot, error) {
+	if err := s.authorizer.authorize(ctx, OpListNamespaces, "", ""); err != nil {
+		return nil, err
+	}
+
+	ns, err := s.statsReader()
+	if err != nil || ns == nil {
+		return nil, err
+	}
+
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+
+	filePath, err := ns.getFilePath(statsKey, false)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := ns.decoder.ReadAll(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stats: %w", err)
+	}
+
+	var snapshots []StatsSnapshot
+	for _, record := range records {
+		// Snapshots are plain JSON, so a round trip decodes them
+		data, err := json.Marshal(record.Data)
+		if err != nil {
+			return nil, err
+		}
+		var snapshot StatsSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return nil, fmt.Errorf("%w: stats snapshot v%d: %v", ErrCorruptedData, record.Meta.Version, err)
+		}
+
+		if !snapshot.Time.Before(since) {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+
+	return snapshots, nil
+}
+
+// statsReader returns the _stats namespace for reading: the recorder's, or
+// one opened read-only. Returns nil if no snapshots were ever recorded.
+func (s *store) statsReader() (*namespace, error) {
+	if s.stats != nil {
+		return s.stats.ns, nil
+	}
+
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+
+	if s.statsNS == nil {
+		if !fsutil.DirExists(filepath.Join(s.basePath, statsNamespace)) {
+			return nil, nil
+		}
+		ns, err := s.openStatsNamespace(true)
+		if err != nil {
+			return nil, err
+		}
+		s.statsNS = ns
+	}
+
+	// Another process may have added snapshots since
+	s.statsNS.rescan()
+
+	return s.statsNS, nil
+}
+
+// isReservedNamespace reports names the store uses internally.
+func isReservedNamespace(name string) bool {
+	return name == statsNamespace
+}
@@ -0,0 +1,290 @@
+package stow
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ChangeOp is the kind of a Change.
+type ChangeOp string
+
+const (
+	// ChangePut means a key was written
+	ChangePut ChangeOp = changePut
+
+	// ChangeDelete means a key was deleted
+	ChangeDelete ChangeOp = changeDelete
+
+	// ChangeConfig means a namespace config changed
+	ChangeConfig ChangeOp = changeConfig
+
+	// ChangeDropNamespace means a namespace was deleted
+	ChangeDropNamespace ChangeOp = changeDropNamespace
+
+	// ChangeGap means the changes after the cursor up to and including Seq
+	// are no longer in the changes log. Resync from the namespaces, then
+	// keep going: the next event is Seq+1.
+	ChangeGap ChangeOp = "gap"
+)
+
+// Change is a write recorded in the store's changes log, reported by a
+// ChangeWatcher.
+type Change struct {
+	// Seq numbers the changes of a store in order. Persist the Seq of the
+	// last change handled to resume with WatchChanges after a restart.
+	Seq uint64
+
+	// Namespace is the namespace changed
+	Namespace string
+
+	// Key is the key changed (empty for config and namespace changes)
+	Key string
+
+	// Op is what happened
+	Op ChangeOp
+
+	// Version is the version written (0 when not applicable)
+	Version int
+}
+
+// ChangeWatcher reports the changes of a store from a cursor on, in order.
+// The changes log is its backlog: a consumer slower than the writer only
+// falls behind, and loses changes (reported as a ChangeGap) once the log
+// rotated past its cursor twice.
+type ChangeWatcher struct {
+	path     string
+	interval time.Duration
+	events   chan Change
+
+	// Seq of the last change sent
+	last uint64
+
+	file    *os.File
+	offset  int64
+	partial []byte
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	onClose   func(*ChangeWatcher)
+}
+
+// WatchChanges reports every change with a Seq greater than since, starting
+// with those still in the changes log, then new ones as they are written.
+func (s *store) WatchChanges(since uint64, opts ...WatchOption) (*ChangeWatcher, error) {
+	return s.watchChanges(context.Background(), since, opts...)
+}
+
+func (s *store) watchChanges(ctx context.Context, since uint64, opts ...WatchOption) (*ChangeWatcher, error) {
+	if err := s.authorizer.authorize(ctx, OpListNamespaces, "", ""); err != nil {
+		return nil, err
+	}
+
+	options := newWatchOptions(opts)
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+
+	w := &ChangeWatcher{
+		path:     filepath.Join(s.basePath, changeLogName),
+		interval: options.interval,
+		events:   make(chan Change, options.buffer),
+		last:     since,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		onClose:  s.forgetChangeWatcher,
+	}
+
+	s.watchMu.Lock()
+	s.watchers = append(s.watchers, w)
+	s.watchMu.Unlock()
+
+	if !s.resources.spawn(w.run) {
+		close(w.done)
+		w.Close()
+		return nil, ErrGoroutineLimit
+	}
+	return w, nil
+}
+
+// forgetChangeWatcher drops a closed watcher from the store.
+func (s *store) forgetChangeWatcher(w *ChangeWatcher) {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+
+	for i, other := range s.watchers {
+		if other == w {
+			s.watchers = append(s.watchers[:i], s.watchers[i+1:]...)
+			break
+		}
+	}
+}
+
+// closeChangeWatchers stops every change watcher of the store.
+func (s *store) closeChangeWatchers() {
+	s.watchMu.Lock()
+	watchers := append([]*ChangeWatcher(nil), s.watchers...)
+	s.watchMu.Unlock()
+
+	for _, w := range watchers {
+		w.Close()
+	}
+}
+
+// Events returns the channel of changes. It is closed by Close.
+func (w *ChangeWatcher) Events() <-chan Change {
+	return w.events
+}
+
+// Close stops watching and closes the Events channel.
+func (w *ChangeWatcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.stop)
+		<-w.done
+		close(w.events)
+
+		if w.file != nil {
+			w.file.Close()
+		}
+		w.onClose(w)
+	})
+	return nil
+}
+
+func (w *ChangeWatcher) run() {
+	defer close(w.done)
+
+	// Replay what the log held before its last rotation
+	if file, err := os.Open(filepath.Join(filepath.Dir(w.path), changeLogPrevious)); err == nil {
+		_, ok := w.drain(file, 0, nil)
+		file.Close()
+		if !ok {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		if !w.poll() {
+			return
+		}
+
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll sends the entries appended since the last poll. Returns false once
+// the watcher is closed.
+func (w *ChangeWatcher) poll() bool {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return true
+	}
+
+	if w.file == nil || !sameFile(w.file, info) {
+		// Finish the rotated log first; the open handle still reads it
+		if w.file != nil {
+			_, ok := w.drain(w.file, w.offset, w.partial)
+			w.file.Close()
+			w.file = nil
+			if !ok {
+				return false
+			}
+		}
+
+		file, err := os.Open(w.path)
+		if err != nil {
+			return true
+		}
+		w.file = file
+		w.offset = 0
+		w.partial = nil
+	}
+
+	if info.Size() <= w.offset {
+		return true
+	}
+
+	partial, ok := w.drain(w.file, w.offset, w.partial)
+	if offset, err := w.file.Seek(0, io.SeekCurrent); err == nil {
+		w.offset = offset
+	}
+	w.partial = partial
+	return ok
+}
+
+// drain sends the complete entries of file from offset on, after the
+// partial line left by the previous read. Returns the trailing partial line
+// and false once the watcher is closed.
+func (w *ChangeWatcher) drain(file *os.File, offset int64, partial []byte) ([]byte, bool) {
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return partial, true
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return partial, true
+	}
+
+	data = append(partial, data...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+
+		var entry changeEntry
+		if err := json.Unmarshal(data[:i], &entry); err == nil {
+			if !w.send(entry) {
+				return nil, false
+			}
+		}
+		data = data[i+1:]
+	}
+	return append([]byte(nil), data...), true
+}
+
+// send delivers an entry, preceded by a ChangeGap when entries between the
+// cursor and it are missing. Entries at or before the cursor, and those
+// logged before changes were numbered, are skipped. Blocks until the
+// consumer takes the change; returns false once the watcher is closed.
+func (w *ChangeWatcher) send(entry changeEntry) bool {
+	if entry.Seq == 0 || entry.Seq <= w.last {
+		return true
+	}
+
+	if entry.Seq > w.last+1 {
+		if !w.deliver(Change{Seq: entry.Seq - 1, Op: ChangeGap}) {
+			return false
+		}
+	}
+
+	return w.deliver(Change{
+		Seq:       entry.Seq,
+		Namespace: entry.Namespace,
+		Key:       entry.Key,
+		Op:        ChangeOp(entry.Op),
+		Version:   entry.Version,
+	})
+}
+
+func (w *ChangeWatcher) deliver(change Change) bool {
+	select {
+	case w.events <- change:
+		w.last = change.Seq
+		return true
+	case <-w.stop:
+		return false
+	}
+}
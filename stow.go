@@ -37,6 +37,12 @@ For more information, see the README and design documentation.
 */
 package stow
 
+import (
+	"context"
+	"io"
+	"time"
+)
+
 // Store is the main entry point for Stow.
 // It manages multiple namespaces, each in its own directory.
 //
@@ -51,6 +57,8 @@ type Store interface {
 
 	// GetNamespace returns an existing namespace.
 	// Creates it with default config if it doesn't exist.
+	// Handles are cached and shared: concurrent first calls open the
+	// namespace once.
 	GetNamespace(name string) (Namespace, error)
 
 	// MustGetNamespace is like GetNamespace but panics on error.
@@ -63,6 +71,66 @@ type Store interface {
 	// This is a destructive operation and cannot be undone.
 	DeleteNamespace(name string) error
 
+	// CloseNamespace evicts an open namespace handle, releasing its caches.
+	// The next GetNamespace reopens it; earlier handles return ErrClosed.
+	CloseNamespace(name string) error
+
+	// SetNamespaceKey provides the encryption key of a namespace once the
+	// store is open, like WithStoreNamespaceKey: GetNamespace creates the
+	// namespace encrypted with it or opens it with it. A namespace already
+	// open keeps its key until it is closed.
+	SetNamespaceKey(name string, key []byte) error
+
+	// ArchiveNamespace writes an existing namespace, with the full history,
+	// pins and blobs of every key, to w as a single read-only bundle that
+	// OpenArchive serves without extracting it. Data is written decrypted.
+	ArchiveNamespace(name string, w io.Writer) error
+
+	// Backup writes every namespace, as ArchiveNamespace does, to w as one
+	// read-only bundle. OpenBackup serves it without restoring it to disk.
+	Backup(w io.Writer) error
+
+	// CopyKey copies a key, with its history, pins and blobs, to a key of
+	// the same or another namespace (created if needed), for example to
+	// promote a record from staging to production. Blobs are stored in the
+	// destination's blob directory. An empty dstKey keeps the key's name.
+	CopyKey(srcNS, srcKey, dstNS, dstKey string, opts ...CopyKeyOption) error
+
+	// StatsHistory returns the stats snapshots taken since the given time,
+	// oldest first. Snapshots are recorded by stores opened with
+	// WithStoreStatsHistory; without any it returns none.
+	StatsHistory(since time.Time) ([]StatsSnapshot, error)
+
+	// RegisterBlobProcessor registers a processor run on blobs whose MIME type
+	// matches pattern (path.Match syntax, e.g. "image/*") when they are Put.
+	// Its artifacts are stored as blobs and read back with Namespace.GetDerived.
+	RegisterBlobProcessor(pattern string, fn BlobProcessor) error
+
+	// RegisterModel registers defaults for the type of model (a struct or a
+	// pointer to one), applied to every Put and PutAuto of that type: the
+	// PutAuto key, the blob threshold and default PutOptions. The model's
+	// stow tags are validated (see ValidateModel). Registering a type again
+	// replaces its options.
+	RegisterModel(model interface{}, opts ModelOptions) error
+
+	// WatchChanges reports the writes of every namespace with a Seq greater
+	// than since, in order: first those still in the changes log, then new
+	// ones as the writer makes them (also on read-only stores). Persist the
+	// Seq of the last change handled and pass it back to resume after a
+	// restart; 0 replays the whole log. A slow consumer is never skipped
+	// ahead silently: changes that rotated out of the log are reported as a
+	// ChangeGap. Store.Close closes all watchers.
+	WatchChanges(since uint64, opts ...WatchOption) (*ChangeWatcher, error)
+
+	// Resources reports the key file handles and background goroutines the
+	// store holds, with the ceilings set by WithStoreMaxOpenFiles and
+	// WithStoreMaxGoroutines.
+	Resources() ResourceUsage
+
+	// WithContext returns a view of the store whose calls, and those of the
+	// namespaces it returns, are checked by the Authorizer with ctx.
+	WithContext(ctx context.Context) Store
+
 	// Close closes the store and all open namespaces.
 	Close() error
 }
@@ -78,9 +146,27 @@ type Namespace interface {
 	// MustPut is like Put but panics on error.
 	MustPut(key string, value interface{}, opts ...PutOption)
 
+	// AppendVersions appends values to the history of key as consecutive
+	// versions, oldest first, in one write, e.g. to import an audit history.
+	AppendVersions(key string, values []interface{}) error
+
+	// Txn runs fn and commits the Puts and Deletes it stages on tx
+	// atomically: every key gets its new record or none does. Nothing is
+	// written if fn returns an error.
+	Txn(fn func(tx Txn) error) error
+
+	// PutAuto stores a value under a generated key and returns the key.
+	// Keys come from WithIDGenerator (default ULID) and sort by creation time.
+	PutAuto(value interface{}, opts ...PutOption) (string, error)
+
+	// PutWithUniqueKey stores a value under base, or the first of base-2,
+	// base-3... never written before, and returns the key (e.g. for slugs).
+	PutWithUniqueKey(base string, value interface{}, opts ...PutOption) (string, error)
+
 	// Get retrieves a value by key and deserializes it into target.
 	// Returns ErrNotFound if the key doesn't exist or has been deleted.
-	Get(key string, target interface{}) error
+	// WithFallbackToHistory serves an older version when the latest is corrupt.
+	Get(key string, target interface{}, opts ...GetOption) error
 
 	// MustGet is like Get but panics on error.
 	MustGet(key string, target interface{})
@@ -88,11 +174,30 @@ type Namespace interface {
 	// GetRaw returns the raw record without deserialization.
 	GetRaw(key string) (RawItem, error)
 
+	// GetDerived opens an artifact produced by a blob processor for a blob field
+	// of the latest record (e.g. GetDerived("photo", "image", "thumbnail")).
+	// Returns ErrNotFound if no such artifact exists.
+	GetDerived(key, field, name string) (IFileData, error)
+
+	// StreamBlobs writes the blob files of the latest version of each key
+	// to w as a zip or tar archive, one directory per key, using the files'
+	// original names. Returns ErrNotFound, before writing, for a missing key.
+	StreamBlobs(keys []string, w io.Writer, format ArchiveFormat) error
+
+	// GetCached is like Get, but concurrent cache misses of a key share a
+	// single file read. WithLoader loads and stores keys that don't exist.
+	GetCached(key string, target interface{}, opts ...GetOption) error
+
 	// Delete marks a key as deleted (soft delete).
-	Delete(key string) error
+	// WithReason records why, shown in GetHistory.
+	Delete(key string, opts ...DeleteOption) error
 
 	// MustDelete is like Delete but panics on error.
-	MustDelete(key string)
+	MustDelete(key string, opts ...DeleteOption)
+
+	// Rename moves a key to a new key with its full history, pins and tags,
+	// recording the rename in both keys' histories.
+	Rename(oldKey, newKey string) error
 
 	// Exists checks if a key exists (and is not deleted).
 	Exists(key string) bool
@@ -100,6 +205,41 @@ type Namespace interface {
 	// List returns all keys in the namespace (excluding deleted keys).
 	List() ([]string, error)
 
+	// KeysWhere returns the keys whose latest value holds each boolean field
+	// with the given value, in ascending order. Fields must be listed in
+	// NamespaceConfig.BoolIndexes, or it returns ErrNotIndexed.
+	KeysWhere(conditions map[string]bool) ([]string, error)
+
+	// KeysByIndex returns the keys whose latest value holds value in a
+	// top-level field tagged `stow:"index"`, in ascending order, without
+	// reading every key. Returns ErrNotIndexed for fields without an index.
+	KeysByIndex(field string, value interface{}) ([]string, error)
+
+	// ========== Path Operations ==========
+
+	// AppendPath appends value to the array field at path (e.g., "comments").
+	// A missing field is created as a new array. Returns ErrNotFound if the key doesn't exist.
+	AppendPath(key, path string, value interface{}) error
+
+	// InsertPath inserts value at the array position addressed by path (e.g., "comments[2]").
+	// Returns ErrIndexOutOfRange if the index is beyond the array length.
+	InsertPath(key, path string, value interface{}) error
+
+	// RemovePath removes the array element or field addressed by path (e.g., "comments[2]").
+	// Returns ErrIndexOutOfRange if the index is outside the array bounds.
+	RemovePath(key, path string) error
+
+	// ========== Search ==========
+
+	// SimilaritySearch returns the k keys whose vector field is most similar to query
+	// (cosine similarity, highest first). Records without a vector of matching
+	// dimension are skipped.
+	SimilaritySearch(field string, query []float32, k int) ([]SimilarityResult, error)
+
+	// Query returns the keys whose latest value matches every filter (see
+	// Eq, Gte, Contains...), in ascending key order, with their values.
+	Query(filters ...Filter) ([]QueryResult, error)
+
 	// ========== Version History ==========
 
 	// GetHistory returns all versions of a key.
@@ -108,6 +248,40 @@ type Namespace interface {
 	// GetVersion retrieves a specific version of a key.
 	GetVersion(key string, version int, target interface{}) error
 
+	// PinVersion protects a version from being removed by compaction.
+	PinVersion(key string, version int) error
+
+	// UnpinVersion removes the pin from a version.
+	UnpinVersion(key string, version int) error
+
+	// DumpKey writes the full history of a key, its pins and referenced blobs
+	// to w as a single stream (see LoadKey).
+	DumpKey(key string, w io.Writer) error
+
+	// LoadKey restores a key from a DumpKey stream and returns the loaded key.
+	LoadKey(r io.Reader, opts ...LoadOption) (string, error)
+
+	// AdoptFile moves a JSONL history file written outside stow into the
+	// namespace as the history of key, without rewriting it.
+	AdoptFile(key, path string, opts AdoptOptions) error
+
+	// Pins returns the pinned versions of a key in ascending order.
+	Pins(key string) ([]int, error)
+
+	// ========== Tags ==========
+
+	// Tag adds labels to a key without creating a version.
+	Tag(key string, tags ...string) error
+
+	// Untag removes labels from a key.
+	Untag(key string, tags ...string) error
+
+	// Tags returns the labels of a key in ascending order.
+	Tags(key string) ([]string, error)
+
+	// ListByTag returns the keys carrying a label in ascending order.
+	ListByTag(tag string) ([]string, error)
+
 	// ========== Maintenance ==========
 
 	// Compact compresses the specified keys by keeping only recent versions.
@@ -129,6 +303,55 @@ type Namespace interface {
 	// GC performs garbage collection, removing unreferenced blob files.
 	GC() (GCResult, error)
 
+	// Prune removes versions older than opts.OlderThan from every key in
+	// one pass, keeping at least opts.KeepAtLeast per key, and deletes the
+	// blob files only the removed versions referenced.
+	Prune(opts PruneOptions) (PruneResult, error)
+
+	// TakeOwnership acquires the owner lease of a namespace with
+	// NamespaceConfig.OwnerLease even while another writer holds it.
+	TakeOwnership() error
+
+	// BlobManifest writes an NDJSON line (BlobManifestEntry) for every blob
+	// file referenced by any version of any key, with its hash, size and
+	// referencing keys, for verifying backups outside stow.
+	BlobManifest(w io.Writer) error
+
+	// BlobReferrers returns the key versions referencing a blob by content
+	// hash (or a prefix of it), e.g. to investigate a large or suspicious
+	// file in _blobs/.
+	BlobReferrers(hash string) ([]KeyVersionRef, error)
+
+	// Sweep calls fn for every existing key, in ascending order, and deletes
+	// or compacts the keys it asks for, e.g. to expire drafts older than
+	// 90 days. Keys written after fn looked at them are not deleted.
+	Sweep(fn SweepFunc) (SweepResult, error)
+
+	// InferSchema reads the latest record of up to sample keys (all keys
+	// when sample is 0) and reports the fields they hold, with their types
+	// and inconsistencies, before validators or indexes are added.
+	InferSchema(sample int) (*SchemaReport, error)
+
+	// RelinkBlobs repairs references to blob files moved out of _blobs/,
+	// finding them by content hash in its subdirectories and BlobSearchPaths.
+	// Blobs that can't be found are reported in RelinkResult.Unresolved.
+	RelinkBlobs() (RelinkResult, error)
+
+	// RepairCollisions splits key files holding the records of more than
+	// one key (see ErrKeyCollision), moving every key but the first to a
+	// file of its own.
+	RepairCollisions() (RepairCollisionsResult, error)
+
+	// VerifyChain checks the hash chain of a key in a namespace configured
+	// with HashChain and returns the digest of its latest record. Returns
+	// ErrChainBroken if a record doesn't link to the one before it.
+	VerifyChain(key string) (string, error)
+
+	// Verify checks the signatures of all records in a namespace configured
+	// with Signing, reporting records changed since they were signed, and
+	// the blobs of each key's latest version in the blob directory.
+	Verify() (VerifyResult, error)
+
 	// Refresh invalidates cache for specified keys, forcing reload from disk.
 	// This allows detecting external file modifications.
 	Refresh(keys ...string) error
@@ -136,6 +359,14 @@ type Namespace interface {
 	// RefreshAll invalidates cache for all keys.
 	RefreshAll() error
 
+	// Prime reads keys into the cache ahead of their first Get.
+	// NamespaceConfig.Preload primes keys when the namespace is opened.
+	Prime(keys ...string) error
+
+	// WatchExternalChanges reports data files edited outside this namespace
+	// (by hand or by other tools) and invalidates their cached values.
+	WatchExternalChanges(opts ...WatchOption) (*ExternalWatcher, error)
+
 	// ========== Configuration ==========
 
 	// GetConfig returns the current namespace configuration.
@@ -147,6 +378,10 @@ type Namespace interface {
 
 	// ========== Fluent API ==========
 
+	// WithContext returns a handle whose calls are checked by the store's
+	// Authorizer with ctx (e.g. carrying the caller's principal).
+	WithContext(ctx context.Context) Namespace
+
 	// WithLogger sets a custom logger for this namespace (returns self for chaining).
 	WithLogger(logger Logger) Namespace
 
@@ -0,0 +1,381 @@
+// Package stowdebug serves a read-only debug UI for a store, for embedding
+// in services under /debug/stow next to net/http/pprof and expvar.
+//
+// The UI lists namespaces with their stats, pages through the keys of a
+// namespace, shows the latest record of a key with its history timeline,
+// decodes any version and previews its blobs. Every page is also served as
+// JSON with ?format=json (or an Accept: application/json header), for curl
+// during an incident. Nothing is ever written: namespaces that don't exist
+// are reported as not found instead of being created.
+//
+// The handler shows stored data as is, so mount it behind the same access
+// control as the service's other debug endpoints.
+//
+// Example:
+//
+//	http.Handle("/debug/stow/", http.StripPrefix("/debug/stow", stowdebug.Handler(store)))
+//	stowdebug.Publish("stow", store) // namespace stats under /debug/vars
+package stowdebug
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aigotowork/stow"
+)
+
+// DefaultPageSize is the number of keys listed per page.
+const DefaultPageSize = 100
+
+// MaxPageSize caps the limit parameter of key listings.
+const MaxPageSize = 1000
+
+// errBadRequest marks errors caused by missing or invalid parameters.
+var errBadRequest = errors.New("bad request")
+
+// Handler returns the debug UI of store. Its pages link to each other with
+// relative URLs, so mount it on a path ending in "/" with http.StripPrefix.
+//
+// Pages:
+//
+//	/                                namespaces and their stats
+//	/namespace?ns=N&after=K&limit=L  keys of N after K, in order
+//	/key?ns=N&key=K                  latest record of K and its history
+//	/version?ns=N&key=K&v=V          version V of K, decoded
+//	/blob?ns=N&key=K&v=V&field=F     blob field F of version V
+func Handler(store stow.Store) http.Handler {
+	h := &handler{store: store}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/{$}", h.serve(h.namespaces, "namespaces"))
+	mux.HandleFunc("/namespace", h.serve(h.keys, "keys"))
+	mux.HandleFunc("/key", h.serve(h.key, "key"))
+	mux.HandleFunc("/version", h.serve(h.version, "version"))
+	mux.HandleFunc("/blob", h.blob)
+	return mux
+}
+
+// Publish exposes the stats of every namespace of store, and its resource
+// usage, as the expvar variable name. Like expvar.Publish, it panics if
+// name is already in use.
+func Publish(name string, store stow.Store) {
+	expvar.Publish(name, expvar.Func(func() any {
+		page, err := (&handler{store: store}).namespaces(nil)
+		if err != nil {
+			return map[string]string{"error": err.Error()}
+		}
+		return page
+	}))
+}
+
+type handler struct {
+	store stow.Store
+}
+
+// namespacesPage is the root page.
+type namespacesPage struct {
+	Namespaces []namespaceSummary `json:"namespaces"`
+	Resources  stow.ResourceUsage `json:"resources"`
+}
+
+type namespaceSummary struct {
+	Name  string              `json:"name"`
+	Stats stow.NamespaceStats `json:"stats"`
+	Err   string              `json:"error,omitempty"`
+}
+
+// keysPage is one page of the keys of a namespace.
+type keysPage struct {
+	Namespace string   `json:"namespace"`
+	Keys      []string `json:"keys"`
+	Total     int      `json:"total"`
+
+	// Next is the after parameter of the next page ("" on the last page)
+	Next string `json:"next,omitempty"`
+}
+
+// keyPage is the latest record of a key and its history.
+type keyPage struct {
+	Namespace string                 `json:"namespace"`
+	Key       string                 `json:"key"`
+	Meta      stow.MetaInfo          `json:"meta"`
+	Data      map[string]interface{} `json:"data"`
+	History   []stow.Version         `json:"history"`
+	Pins      []int                  `json:"pins,omitempty"`
+	Tags      []string               `json:"tags,omitempty"`
+}
+
+// versionPage is a decoded version of a key. Blob fields are replaced by
+// blobSummary values linking to /blob.
+type versionPage struct {
+	Namespace string                 `json:"namespace"`
+	Key       string                 `json:"key"`
+	Version   stow.Version           `json:"version"`
+	Data      map[string]interface{} `json:"data"`
+	Blobs     []string               `json:"blobs,omitempty"`
+}
+
+type blobSummary struct {
+	Blob string `json:"$blob"`
+	Size int    `json:"size"`
+}
+
+// serve renders the page built by fn as JSON or with the named template.
+func (h *handler) serve(fn func(r *http.Request) (any, error), name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, err := fn(r)
+		if err != nil {
+			http.Error(w, err.Error(), statusOf(err))
+			return
+		}
+
+		if wantsJSON(r) {
+			w.Header().Set("Content-Type", "application/json")
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			enc.Encode(page)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := templates.ExecuteTemplate(w, name, page); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+func (h *handler) namespaces(*http.Request) (any, error) {
+	names, err := h.store.ListNamespaces()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	page := namespacesPage{Resources: h.store.Resources()}
+	for _, name := range names {
+		summary := namespaceSummary{Name: name}
+		ns, err := h.store.GetNamespace(name)
+		if err == nil {
+			summary.Stats, err = ns.Stats()
+		}
+		if err != nil {
+			summary.Err = err.Error()
+		}
+		page.Namespaces = append(page.Namespaces, summary)
+	}
+	return page, nil
+}
+
+func (h *handler) keys(r *http.Request) (any, error) {
+	ns, err := h.namespace(r)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := DefaultPageSize
+	if s := r.FormValue("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit <= 0 {
+			return nil, fmt.Errorf("%w: invalid limit %q", errBadRequest, s)
+		}
+		limit = min(limit, MaxPageSize)
+	}
+
+	keys, err := ns.List()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+
+	page := keysPage{Namespace: ns.Name(), Total: len(keys)}
+	start := 0
+	if after := r.FormValue("after"); after != "" {
+		start = sort.Search(len(keys), func(i int) bool { return keys[i] > after })
+	}
+	end := min(start+limit, len(keys))
+	page.Keys = keys[start:end]
+	if end < len(keys) {
+		page.Next = keys[end-1]
+	}
+	return page, nil
+}
+
+func (h *handler) key(r *http.Request) (any, error) {
+	ns, key, err := h.namespaceKey(r)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := ns.GetHistory(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(history) == 0 {
+		return nil, fmt.Errorf("%w: %s", stow.ErrNotFound, key)
+	}
+
+	page := keyPage{Namespace: ns.Name(), Key: key, History: history}
+
+	// A deleted key has history but no latest record
+	item, err := ns.GetRaw(key)
+	switch {
+	case err == nil:
+		page.Meta = item.Meta()
+		page.Data = item.RawData()
+	case !errors.Is(err, stow.ErrNotFound):
+		return nil, err
+	}
+
+	if page.Pins, err = ns.Pins(key); err != nil {
+		return nil, err
+	}
+	if page.Tags, err = ns.Tags(key); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func (h *handler) version(r *http.Request) (any, error) {
+	ns, key, err := h.namespaceKey(r)
+	if err != nil {
+		return nil, err
+	}
+	version, data, err := h.decodeVersion(ns, key, r.FormValue("v"))
+	if err != nil {
+		return nil, err
+	}
+
+	page := versionPage{Namespace: ns.Name(), Key: key, Version: version, Data: data}
+	for field, value := range data {
+		if b, ok := value.([]byte); ok {
+			data[field] = blobSummary{Blob: field, Size: len(b)}
+			page.Blobs = append(page.Blobs, field)
+		}
+	}
+	slices.Sort(page.Blobs)
+	return page, nil
+}
+
+// blob serves a blob field of a version with its sniffed content type, so
+// browsers show images and text inline.
+func (h *handler) blob(w http.ResponseWriter, r *http.Request) {
+	b, err := func() ([]byte, error) {
+		ns, key, err := h.namespaceKey(r)
+		if err != nil {
+			return nil, err
+		}
+		_, data, err := h.decodeVersion(ns, key, r.FormValue("v"))
+		if err != nil {
+			return nil, err
+		}
+
+		field := r.FormValue("field")
+		b, ok := data[field].([]byte)
+		if !ok {
+			return nil, fmt.Errorf("%w: no blob field %q", stow.ErrNotFound, field)
+		}
+		return b, nil
+	}()
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", http.DetectContentType(b))
+	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+	w.Header().Set("Content-Disposition", "inline")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(b)
+}
+
+// decodeVersion decodes version v of key, its blob fields loaded as []byte.
+func (h *handler) decodeVersion(ns stow.Namespace, key, v string) (stow.Version, map[string]interface{}, error) {
+	number, err := strconv.Atoi(v)
+	if err != nil {
+		return stow.Version{}, nil, fmt.Errorf("%w: invalid version %q", errBadRequest, v)
+	}
+
+	history, err := ns.GetHistory(key)
+	if err != nil {
+		return stow.Version{}, nil, err
+	}
+	for _, version := range history {
+		if version.Version != number {
+			continue
+		}
+		var data map[string]interface{}
+		if version.Operation == "put" {
+			if err := version.Decode(&data); err != nil {
+				return version, nil, err
+			}
+		}
+		return version, data, nil
+	}
+	return stow.Version{}, nil, fmt.Errorf("%w: %s version %d", stow.ErrNotFound, key, number)
+}
+
+// namespace returns the existing namespace named by the ns parameter.
+func (h *handler) namespace(r *http.Request) (stow.Namespace, error) {
+	name := r.FormValue("ns")
+	if name == "" {
+		return nil, fmt.Errorf("%w: missing ns", errBadRequest)
+	}
+
+	// GetNamespace creates missing namespaces
+	names, err := h.store.ListNamespaces()
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(names, name) {
+		return nil, fmt.Errorf("%w: %s", stow.ErrNamespaceNotFound, name)
+	}
+	return h.store.GetNamespace(name)
+}
+
+func (h *handler) namespaceKey(r *http.Request) (stow.Namespace, string, error) {
+	ns, err := h.namespace(r)
+	if err != nil {
+		return nil, "", err
+	}
+	key := r.FormValue("key")
+	if key == "" {
+		return nil, "", fmt.Errorf("%w: missing key", errBadRequest)
+	}
+	return ns, key, nil
+}
+
+func wantsJSON(r *http.Request) bool {
+	if format := r.FormValue("format"); format != "" {
+		return format == "json"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, errBadRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, stow.ErrNotFound), errors.Is(err, stow.ErrNamespaceNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, stow.ErrPermissionDenied):
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// templates renders the HTML pages. Keys and values are escaped by
+// html/template; query parameters go through urlquery.
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.MarshalIndent(v, "", "  ")
+		return string(b), err
+	},
+}).Parse(pages))
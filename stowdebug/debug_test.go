@@ -0,0 +1,161 @@
+package stowdebug
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func newTestServer(t *testing.T) (*httptest.Server, stow.Store) {
+	t.Helper()
+	store := stow.MustOpen(t.TempDir())
+	t.Cleanup(func() { store.Close() })
+
+	mux := http.NewServeMux()
+	mux.Handle("/debug/stow/", http.StripPrefix("/debug/stow", Handler(store)))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, store
+}
+
+func get(t *testing.T, server *httptest.Server, path string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(server.URL + "/debug/stow" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func getJSON(t *testing.T, server *httptest.Server, path string, target any) {
+	t.Helper()
+	status, body := get(t, server, path)
+	if status != http.StatusOK {
+		t.Fatalf("GET %s: %d %s", path, status, body)
+	}
+	if err := json.Unmarshal([]byte(body), target); err != nil {
+		t.Fatalf("GET %s: %v\n%s", path, err, body)
+	}
+}
+
+func TestHandlerPages(t *testing.T) {
+	server, store := newTestServer(t)
+	users := store.MustGetNamespace("users")
+	users.MustPut("user:1", map[string]interface{}{"name": "Alice <admin>"})
+	users.MustPut("user:1", map[string]interface{}{"name": "Alice", "avatar": append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 8184)...)})
+	users.MustPut("user/2", map[string]interface{}{"name": "Bob"})
+
+	status, body := get(t, server, "/")
+	if status != http.StatusOK || !strings.Contains(body, `href="namespace?ns=users"`) {
+		t.Errorf("index: %d\n%s", status, body)
+	}
+
+	status, body = get(t, server, "/key?ns=users&key=user%3A1")
+	if status != http.StatusOK || !strings.Contains(body, `version?ns=users&amp;key=user%3A1&amp;v=1`) {
+		t.Errorf("key page: %d\n%s", status, body)
+	}
+
+	status, body = get(t, server, "/version?ns=users&key=user%3A1&v=1")
+	if status != http.StatusOK || !strings.Contains(body, "Alice \\u003cadmin\\u003e") {
+		t.Errorf("version page is not escaped: %d\n%s", status, body)
+	}
+
+	var version versionPage
+	getJSON(t, server, "/version?ns=users&key=user%3A1&v=2&format=json", &version)
+	if len(version.Blobs) != 1 || version.Blobs[0] != "avatar" {
+		t.Errorf("blobs = %v, want [avatar]", version.Blobs)
+	}
+
+	resp, err := http.Get(server.URL + "/debug/stow/blob?ns=users&key=user%3A1&v=2&field=avatar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "image/png" || resp.ContentLength != 8192 {
+		t.Errorf("blob: %d %s %d bytes", resp.StatusCode, resp.Header.Get("Content-Type"), resp.ContentLength)
+	}
+}
+
+func TestHandlerKeyPaging(t *testing.T) {
+	server, store := newTestServer(t)
+	ns := store.MustGetNamespace("items")
+	for i := 0; i < 25; i++ {
+		ns.MustPut(fmt.Sprintf("item:%02d", i), map[string]interface{}{"n": i})
+	}
+
+	var keys []string
+	after := ""
+	for pages := 0; ; pages++ {
+		if pages > 3 {
+			t.Fatal("paging doesn't end")
+		}
+		var page keysPage
+		getJSON(t, server, "/namespace?ns=items&limit=10&after="+url.QueryEscape(after)+"&format=json", &page)
+		if page.Total != 25 {
+			t.Errorf("Total = %d, want 25", page.Total)
+		}
+		keys = append(keys, page.Keys...)
+		if page.Next == "" {
+			break
+		}
+		after = page.Next
+	}
+
+	if len(keys) != 25 || keys[0] != "item:00" || keys[24] != "item:24" {
+		t.Errorf("paged keys = %v", keys)
+	}
+}
+
+func TestHandlerNotFound(t *testing.T) {
+	server, store := newTestServer(t)
+	store.MustGetNamespace("users").MustPut("user:1", map[string]interface{}{"name": "Alice"})
+
+	for _, path := range []string{
+		"/namespace?ns=missing",
+		"/key?ns=users&key=user%3A9",
+		"/version?ns=users&key=user%3A1&v=7",
+		"/blob?ns=users&key=user%3A1&v=1&field=name",
+	} {
+		if status, _ := get(t, server, path); status != http.StatusNotFound {
+			t.Errorf("GET %s: %d, want 404", path, status)
+		}
+	}
+	if status, _ := get(t, server, "/version?ns=users&key=user%3A1&v=x"); status != http.StatusBadRequest {
+		t.Errorf("invalid version: %d, want 400", status)
+	}
+
+	// Browsing never creates namespaces
+	names, _ := store.ListNamespaces()
+	if len(names) != 1 {
+		t.Errorf("namespaces = %v, want [users]", names)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	store.MustGetNamespace("users").MustPut("user:1", map[string]interface{}{"name": "Alice"})
+
+	Publish("stow_test", store)
+
+	var page namespacesPage
+	if err := json.Unmarshal([]byte(expvar.Get("stow_test").String()), &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Namespaces) != 1 || page.Namespaces[0].Stats.KeyCount != 1 {
+		t.Errorf("published %+v", page)
+	}
+}
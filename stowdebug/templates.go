@@ -0,0 +1,82 @@
+package stowdebug
+
+// pages holds the templates of the HTML pages, one per page plus the shared
+// header and footer.
+const pages = `
+{{define "header"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>stow{{with .}} · {{.}}{{end}}</title>
+<style>
+body { font: 14px/1.4 system-ui, sans-serif; margin: 1.5em; color: #222; }
+table { border-collapse: collapse; }
+th, td { text-align: left; padding: 2px 12px 2px 0; vertical-align: top; }
+th { border-bottom: 1px solid #ccc; }
+pre { background: #f6f6f6; padding: 8px; overflow: auto; }
+.muted { color: #888; }
+</style>
+</head>
+<body>
+<p><a href="./">stow</a>{{with .}} · {{.}}{{end}}</p>
+{{end}}
+
+{{define "footer"}}</body>
+</html>
+{{end}}
+
+{{define "namespaces"}}{{template "header" ""}}
+<h1>Namespaces</h1>
+<table>
+<tr><th>Name</th><th>Keys</th><th>Blobs</th><th>Blob bytes</th><th>Total bytes</th><th>Last compact</th><th>Last GC</th></tr>
+{{range .Namespaces}}<tr>
+<td><a href="namespace?ns={{.Name | urlquery}}">{{.Name}}</a></td>
+{{if .Err}}<td colspan="6">{{.Err}}</td>{{else}}{{with .Stats}}
+<td>{{.KeyCount}}</td><td>{{.BlobCount}}</td><td>{{.BlobSize}}</td><td>{{.TotalSize}}</td>
+<td>{{if not .LastCompactAt.IsZero}}{{.LastCompactAt.Format "2006-01-02 15:04:05"}}{{end}}</td>
+<td>{{if not .LastGCAt.IsZero}}{{.LastGCAt.Format "2006-01-02 15:04:05"}}{{end}}</td>{{end}}{{end}}
+</tr>
+{{end}}</table>
+<h2>Resources</h2>
+{{with .Resources}}<p>Open files: {{.OpenFiles}} ({{.IdleFiles}} idle{{if .MaxOpenFiles}}, max {{.MaxOpenFiles}}{{end}}) ·
+Goroutines: {{.Goroutines}}{{if .MaxGoroutines}} (max {{.MaxGoroutines}}){{end}}</p>{{end}}
+{{template "footer"}}{{end}}
+
+{{define "keys"}}{{template "header" .Namespace}}
+<h1>{{.Namespace}}</h1>
+<p class="muted">{{.Total}} keys</p>
+<ul>
+{{$ns := .Namespace}}{{range .Keys}}<li><a href="key?ns={{$ns | urlquery}}&amp;key={{. | urlquery}}">{{.}}</a></li>
+{{end}}</ul>
+{{with .Next}}<p><a href="namespace?ns={{$ns | urlquery}}&amp;after={{. | urlquery}}">Next page</a></p>{{end}}
+{{template "footer"}}{{end}}
+
+{{define "key"}}{{template "header" .Namespace}}
+<h1>{{.Key}}</h1>
+{{if .Meta.Key}}<p>Version {{.Meta.Version}} · {{.Meta.Timestamp.Format "2006-01-02 15:04:05.000 MST"}}{{with .Meta.Seq}} · seq {{.}}{{end}}</p>
+<pre>{{json .Data}}</pre>{{else}}<p><em>Deleted</em></p>{{end}}
+{{with .Tags}}<p>Tags: {{range .}}{{.}} {{end}}</p>{{end}}
+<h2>History</h2>
+<table>
+<tr><th>Version</th><th>Time</th><th>Seq</th><th>Operation</th><th>Size</th><th></th></tr>
+{{$ns := .Namespace}}{{$key := .Key}}{{$pins := .Pins}}{{range .History}}<tr>
+<td><a href="version?ns={{$ns | urlquery}}&amp;key={{$key | urlquery}}&amp;v={{.Version}}">{{.Version}}</a></td>
+<td>{{.Timestamp.Format "2006-01-02 15:04:05.000 MST"}}</td>
+<td>{{with .Seq}}{{.}}{{end}}</td>
+<td>{{.Operation}}{{with .Reason}} ({{.}}){{end}}{{with .RenamedFrom}} from {{.}}{{end}}{{with .RenamedTo}} to {{.}}{{end}}</td>
+<td>{{.Size}}</td>
+<td>{{$v := .Version}}{{range $pins}}{{if eq . $v}}pinned{{end}}{{end}}{{if not .VisibleAt.IsZero}} visible at {{.VisibleAt.Format "2006-01-02 15:04:05 MST"}}{{end}}</td>
+</tr>
+{{end}}</table>
+{{template "footer"}}{{end}}
+
+{{define "version"}}{{template "header" .Namespace}}
+<h1><a href="key?ns={{.Namespace | urlquery}}&amp;key={{.Key | urlquery}}">{{.Key}}</a> · version {{.Version.Version}}</h1>
+<p>{{.Version.Operation}} · {{.Version.Timestamp.Format "2006-01-02 15:04:05.000 MST"}}</p>
+{{if .Data}}<pre>{{json .Data}}</pre>{{end}}
+{{$ns := .Namespace}}{{$key := .Key}}{{$v := .Version.Version}}{{range .Blobs}}
+<h2>{{.}}</h2>
+<p><a href="blob?ns={{$ns | urlquery}}&amp;key={{$key | urlquery}}&amp;v={{$v}}&amp;field={{. | urlquery}}">Open blob</a></p>
+{{end}}
+{{template "footer"}}{{end}}
+`
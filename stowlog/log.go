@@ -0,0 +1,305 @@
+// Package stowlog stores append-heavy event data, such as logs and
+// telemetry, in time-bucketed namespaces of a store.
+//
+// Each bucket is a namespace named after the log and the period it covers
+// (e.g. "events-2025-01-15" for daily buckets). Appends go to the bucket of
+// the current period under time-ordered keys, reads and scans walk the
+// buckets in order, and retention drops whole expired buckets with
+// Store.DeleteNamespace instead of deleting keys one by one.
+//
+// Example:
+//
+//	events, err := stowlog.New(store, "events", stowlog.Bucketing{By: stowlog.Day, Retention: 30})
+//	key, err := events.Append(map[string]interface{}{"type": "login", "user": "alice"})
+//
+//	err = events.Scan(time.Now().Add(-24*time.Hour), time.Now(), func(bucket, key string, item stow.RawItem) error {
+//		fmt.Println(bucket, key, item.RawData())
+//		return nil
+//	})
+package stowlog
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aigotowork/stow"
+)
+
+// ErrInvalidBucketing is returned by New for an unknown period or a
+// negative retention.
+var ErrInvalidBucketing = errors.New("stowlog: invalid bucketing")
+
+// Period is the time span covered by one bucket.
+type Period int
+
+const (
+	// Hour buckets are named <name>-2006-01-02T15
+	Hour Period = iota + 1
+
+	// Day buckets are named <name>-2006-01-02
+	Day
+
+	// Month buckets are named <name>-2006-01
+	Month
+)
+
+// layout returns the time layout of bucket names for the period.
+func (p Period) layout() string {
+	switch p {
+	case Hour:
+		return "2006-01-02T15"
+	case Day:
+		return "2006-01-02"
+	case Month:
+		return "2006-01"
+	}
+	return ""
+}
+
+// start returns the start of the period containing t, in UTC.
+func (p Period) start(t time.Time) time.Time {
+	t = t.UTC()
+	switch p {
+	case Hour:
+		return t.Truncate(time.Hour)
+	case Day:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// add moves t by n periods.
+func (p Period) add(t time.Time, n int) time.Time {
+	switch p {
+	case Hour:
+		return t.Add(time.Duration(n) * time.Hour)
+	case Day:
+		return t.AddDate(0, 0, n)
+	default:
+		return t.AddDate(0, n, 0)
+	}
+}
+
+// Bucketing configures how a Log splits its data.
+type Bucketing struct {
+	// By is the period each bucket covers
+	By Period
+
+	// Retention is the number of buckets kept: the current one and the
+	// Retention-1 before it. Older buckets are dropped when a write rolls
+	// over to a new bucket, or by Expire. 0 keeps every bucket.
+	Retention int
+}
+
+// Log is a time-bucketed event log over the namespaces of a store.
+// It is safe for concurrent use.
+type Log struct {
+	store     stow.Store
+	name      string
+	bucketing Bucketing
+
+	// now returns the current time (replaced by tests)
+	now func() time.Time
+
+	mu      sync.Mutex
+	current string
+}
+
+// New returns the log stored in the namespaces of store named after name.
+// Expired buckets are dropped right away.
+func New(store stow.Store, name string, bucketing Bucketing) (*Log, error) {
+	if bucketing.By.layout() == "" {
+		return nil, fmt.Errorf("%w: unknown period %d", ErrInvalidBucketing, bucketing.By)
+	}
+	if bucketing.Retention < 0 {
+		return nil, fmt.Errorf("%w: retention must be non-negative", ErrInvalidBucketing)
+	}
+	if name == "" {
+		return nil, fmt.Errorf("stowlog: empty log name")
+	}
+
+	l := &Log{store: store, name: name, bucketing: bucketing, now: time.Now}
+	l.current = l.Bucket(l.now())
+	if _, err := l.Expire(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Bucket returns the name of the bucket holding writes made at t.
+func (l *Log) Bucket(t time.Time) string {
+	return l.name + "-" + l.bucketing.By.start(t).Format(l.bucketing.By.layout())
+}
+
+// Append stores value in the current bucket under a generated key (ULID by
+// default, see stow.WithIDGenerator), so keys sort by write time.
+func (l *Log) Append(value interface{}, opts ...stow.PutOption) (string, error) {
+	ns, err := l.namespaceAt(l.now())
+	if err != nil {
+		return "", err
+	}
+	return ns.PutAuto(value, opts...)
+}
+
+// PutAt stores value under key in the bucket of t, e.g. for events that
+// carry their own timestamp. Writes to a bucket past the retention are
+// dropped with it by the next expiry.
+func (l *Log) PutAt(t time.Time, key string, value interface{}, opts ...stow.PutOption) error {
+	ns, err := l.namespaceAt(t)
+	if err != nil {
+		return err
+	}
+	return ns.Put(key, value, opts...)
+}
+
+// Get reads key into target from the newest bucket holding it.
+// Returns stow.ErrNotFound when no bucket does.
+func (l *Log) Get(key string, target interface{}) error {
+	buckets, err := l.Buckets()
+	if err != nil {
+		return err
+	}
+
+	for i := len(buckets) - 1; i >= 0; i-- {
+		ns, err := l.store.GetNamespace(buckets[i])
+		if err != nil {
+			return err
+		}
+		err = ns.Get(key, target)
+		if !errors.Is(err, stow.ErrNotFound) {
+			return err
+		}
+	}
+	return fmt.Errorf("%w: %s", stow.ErrNotFound, key)
+}
+
+// Scan calls fn for every key of the buckets overlapping [from, to),
+// oldest bucket first and keys in order within a bucket. Whole buckets are
+// visited: records are not filtered by their own time. Scan stops at the
+// first error returned by fn and returns it.
+func (l *Log) Scan(from, to time.Time, fn func(bucket, key string, item stow.RawItem) error) error {
+	buckets, err := l.Buckets()
+	if err != nil {
+		return err
+	}
+
+	first := l.Bucket(from)
+	for _, bucket := range buckets {
+		start, _ := l.bucketStart(bucket)
+		if bucket < first || !start.Before(to) {
+			continue
+		}
+
+		ns, err := l.store.GetNamespace(bucket)
+		if err != nil {
+			return err
+		}
+		keys, err := ns.List()
+		if err != nil {
+			return err
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			item, err := ns.GetRaw(key)
+			if errors.Is(err, stow.ErrNotFound) {
+				// Deleted since List
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if err := fn(bucket, key, item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Buckets returns the names of the log's buckets, oldest first.
+func (l *Log) Buckets() ([]string, error) {
+	names, err := l.store.ListNamespaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var buckets []string
+	for _, name := range names {
+		if _, ok := l.bucketStart(name); ok {
+			buckets = append(buckets, name)
+		}
+	}
+
+	// Names of one period sort by time
+	sort.Strings(buckets)
+	return buckets, nil
+}
+
+// Expire deletes the buckets older than the retention and returns their
+// names. It does nothing without a retention.
+func (l *Log) Expire() ([]string, error) {
+	if l.bucketing.Retention == 0 {
+		return nil, nil
+	}
+
+	buckets, err := l.Buckets()
+	if err != nil {
+		return nil, err
+	}
+
+	period := l.bucketing.By
+	oldest := period.add(period.start(l.now()), 1-l.bucketing.Retention)
+
+	var expired []string
+	for _, bucket := range buckets {
+		start, _ := l.bucketStart(bucket)
+		if !start.Before(oldest) {
+			break
+		}
+		if err := l.store.DeleteNamespace(bucket); err != nil {
+			return expired, fmt.Errorf("failed to drop bucket %s: %w", bucket, err)
+		}
+		expired = append(expired, bucket)
+	}
+	return expired, nil
+}
+
+// namespaceAt returns the bucket namespace for t. Rolling over to a new
+// current bucket expires old ones.
+func (l *Log) namespaceAt(t time.Time) (stow.Namespace, error) {
+	bucket := l.Bucket(t)
+
+	l.mu.Lock()
+	current := l.Bucket(l.now())
+	rolled := l.current != current
+	l.current = current
+	l.mu.Unlock()
+
+	if rolled {
+		if _, err := l.Expire(); err != nil {
+			return nil, err
+		}
+	}
+
+	return l.store.GetNamespace(bucket)
+}
+
+// bucketStart parses the period start out of a bucket name of this log.
+func (l *Log) bucketStart(name string) (time.Time, bool) {
+	suffix, ok := strings.CutPrefix(name, l.name+"-")
+	if !ok {
+		return time.Time{}, false
+	}
+
+	start, err := time.Parse(l.bucketing.By.layout(), suffix)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return start, true
+}
@@ -0,0 +1,147 @@
+package stowlog
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aigotowork/stow"
+)
+
+// clock is a settable time source for a Log.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func newTestLog(t *testing.T, bucketing Bucketing) (*Log, *clock, stow.Store) {
+	t.Helper()
+	store := stow.MustOpen(t.TempDir())
+	t.Cleanup(func() { store.Close() })
+
+	c := &clock{t: time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)}
+	l, err := New(store, "events", bucketing)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	l.now = c.now
+	l.current = l.Bucket(c.t)
+	return l, c, store
+}
+
+func TestBucketNames(t *testing.T) {
+	at := time.Date(2025, 1, 15, 10, 30, 0, 0, time.FixedZone("CET", 3600))
+	tests := []struct {
+		by   Period
+		want string
+	}{
+		{Hour, "events-2025-01-15T09"},
+		{Day, "events-2025-01-15"},
+		{Month, "events-2025-01"},
+	}
+	for _, tt := range tests {
+		l := &Log{name: "events", bucketing: Bucketing{By: tt.by}}
+		if got := l.Bucket(at); got != tt.want {
+			t.Errorf("Bucket(%d) = %q, want %q", tt.by, got, tt.want)
+		}
+	}
+}
+
+func TestAppendAndScan(t *testing.T) {
+	l, c, store := newTestLog(t, Bucketing{By: Day})
+
+	first, err := l.Append(map[string]interface{}{"n": 1})
+	if err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	c.t = c.t.Add(24 * time.Hour)
+	if _, err := l.Append(map[string]interface{}{"n": 2}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if err := l.PutAt(c.t.Add(24*time.Hour), "late", map[string]interface{}{"n": 3}); err != nil {
+		t.Fatalf("PutAt failed: %v", err)
+	}
+	store.MustGetNamespace("other").MustPut("k", 1)
+
+	buckets, err := l.Buckets()
+	if err != nil {
+		t.Fatalf("Buckets failed: %v", err)
+	}
+	want := []string{"events-2025-01-15", "events-2025-01-16", "events-2025-01-17"}
+	if !reflect.DeepEqual(buckets, want) {
+		t.Errorf("Buckets() = %v, want %v", buckets, want)
+	}
+
+	// Only the buckets overlapping the range are visited, oldest first
+	var seen []float64
+	from := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 1, 17, 0, 0, 0, 0, time.UTC)
+	err = l.Scan(from, to, func(bucket, key string, item stow.RawItem) error {
+		seen = append(seen, item.RawData()["n"].(float64))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if !reflect.DeepEqual(seen, []float64{1, 2}) {
+		t.Errorf("Scan saw %v, want [1 2]", seen)
+	}
+
+	var value map[string]interface{}
+	if err := l.Get(first, &value); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if fmt.Sprint(value["n"]) != "1" {
+		t.Errorf("Get = %v, want n=1", value)
+	}
+	if err := l.Get("missing", &value); !errors.Is(err, stow.ErrNotFound) {
+		t.Errorf("Get(missing) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	l, c, store := newTestLog(t, Bucketing{By: Day, Retention: 2})
+
+	for day := 0; day < 4; day++ {
+		if _, err := l.Append(map[string]interface{}{"day": day}); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+		c.t = c.t.Add(24 * time.Hour)
+	}
+
+	// Rolling over to the 4th day kept it and the day before
+	buckets, err := l.Buckets()
+	if err != nil {
+		t.Fatalf("Buckets failed: %v", err)
+	}
+	want := []string{"events-2025-01-17", "events-2025-01-18"}
+	if !reflect.DeepEqual(buckets, want) {
+		t.Errorf("Buckets() = %v, want %v", buckets, want)
+	}
+
+	// Expire without writes
+	c.t = c.t.Add(48 * time.Hour)
+	expired, err := l.Expire()
+	if err != nil {
+		t.Fatalf("Expire failed: %v", err)
+	}
+	if !reflect.DeepEqual(expired, want) {
+		t.Errorf("Expire() = %v, want %v", expired, want)
+	}
+	if names, _ := store.ListNamespaces(); len(names) != 0 {
+		t.Errorf("namespaces left: %v", names)
+	}
+}
+
+func TestNewValidates(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	if _, err := New(store, "events", Bucketing{}); !errors.Is(err, ErrInvalidBucketing) {
+		t.Errorf("New(no period) = %v, want ErrInvalidBucketing", err)
+	}
+	if _, err := New(store, "events", Bucketing{By: Day, Retention: -1}); !errors.Is(err, ErrInvalidBucketing) {
+		t.Errorf("New(negative retention) = %v, want ErrInvalidBucketing", err)
+	}
+}
@@ -0,0 +1,295 @@
+// Package stowmerge merges divergent JSONL histories of a key, e.g. when a
+// store versioned in git was written on two branches.
+//
+// Merge works like a three-way merge of records by version:
+//
+//   - Versions of the common ancestor (base) take whichever side changed
+//     them. A version removed on one side (compacted) and unchanged on the
+//     other is removed. A version changed differently on both sides is a
+//     Conflict; the result keeps ours.
+//   - Versions added since base are kept from both sides. When both sides
+//     added different records under the same version, the new records are
+//     interleaved by timestamp, each side keeping its own order, and
+//     renumbered after base, so the latest write becomes the current value
+//     and no history is lost.
+//
+// Record lines are copied verbatim unless renumbered, so encrypted records
+// merge without the key. Renumbering does not update pinned versions, and
+// renumbered or interleaved records of signed or hash-chained namespaces no
+// longer verify.
+package stowmerge
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+
+	"github.com/aigotowork/stow/internal/core"
+)
+
+// interleave merges the records each side added by timestamp. Each side
+// keeps its own version order, so a clock that stepped back on one side
+// can't reorder its history. Ours goes first among records written at the
+// same instant.
+func interleave(ours, theirs []*entry) []*entry {
+	merged := make([]*entry, 0, len(ours)+len(theirs))
+	for len(ours) > 0 && len(theirs) > 0 {
+		if theirs[0].meta.Timestamp.Before(ours[0].meta.Timestamp) {
+			merged = append(merged, theirs[0])
+			theirs = theirs[1:]
+		} else {
+			merged = append(merged, ours[0])
+			ours = ours[1:]
+		}
+	}
+	merged = append(merged, ours...)
+	return append(merged, theirs...)
+}
+
+// ErrKeyMismatch is returned when the histories belong to different keys.
+var ErrKeyMismatch = errors.New("stowmerge: histories belong to different keys")
+
+// Conflict is a version both sides changed differently since base.
+type Conflict struct {
+	// Version is the conflicting version
+	Version int
+
+	// Ours and Theirs are the record lines of each side (nil if removed)
+	Ours   []byte
+	Theirs []byte
+}
+
+// entry is one parsed record line.
+type entry struct {
+	meta  core.Meta
+	line  []byte
+	value interface{} // decoded line, for comparing records
+}
+
+// Merge merges the JSONL histories ours and theirs of one key, which share
+// the history base (empty if the key was added on both sides). It returns
+// the merged file contents and the conflicting versions; with conflicts the
+// result still holds a full history, keeping ours for each conflict.
+func Merge(base, ours, theirs io.Reader) ([]byte, []Conflict, error) {
+	baseEntries, err := parse(base, "base")
+	if err != nil {
+		return nil, nil, err
+	}
+	ourEntries, err := parse(ours, "ours")
+	if err != nil {
+		return nil, nil, err
+	}
+	theirEntries, err := parse(theirs, "theirs")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkKeys(baseEntries, ourEntries, theirEntries); err != nil {
+		return nil, nil, err
+	}
+
+	baseMax := 0
+	for _, e := range baseEntries {
+		baseMax = max(baseMax, e.meta.Version)
+	}
+
+	baseByVersion := byVersion(baseEntries)
+	ourByVersion := byVersion(ourEntries)
+	theirByVersion := byVersion(theirEntries)
+
+	var merged []*entry
+	var conflicts []Conflict
+
+	// Versions of the common history
+	for _, version := range versions(baseEntries, ourEntries, theirEntries) {
+		if version > baseMax {
+			continue
+		}
+
+		b, o, t := baseByVersion[version], ourByVersion[version], theirByVersion[version]
+		switch {
+		case same(o, b):
+			if t != nil {
+				merged = append(merged, t)
+			}
+		case same(t, b), same(o, t):
+			if o != nil {
+				merged = append(merged, o)
+			}
+		default:
+			conflicts = append(conflicts, Conflict{Version: version, Ours: line(o), Theirs: line(t)})
+			if o != nil {
+				merged = append(merged, o)
+			} else {
+				merged = append(merged, t)
+			}
+		}
+	}
+
+	// Versions added on either side, and the records each side added
+	var added, ourAdded, theirAdded []*entry
+	collision := false
+	for _, version := range versions(ourEntries, theirEntries) {
+		if version <= baseMax {
+			continue
+		}
+
+		o, t := ourByVersion[version], theirByVersion[version]
+		switch {
+		case o == nil:
+			added = append(added, t)
+			theirAdded = append(theirAdded, t)
+		case t == nil || same(o, t):
+			added = append(added, o)
+			ourAdded = append(ourAdded, o)
+		default:
+			added = append(added, o, t)
+			ourAdded = append(ourAdded, o)
+			theirAdded = append(theirAdded, t)
+			collision = true
+		}
+	}
+
+	if collision {
+		added = interleave(ourAdded, theirAdded)
+		for i, e := range added {
+			if err := e.renumber(baseMax + 1 + i); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	merged = append(merged, added...)
+
+	var buf bytes.Buffer
+	for _, e := range merged {
+		buf.Write(e.line)
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes(), conflicts, nil
+}
+
+// parse reads the records of a JSONL history. Blank lines are skipped;
+// lines that aren't records fail the merge rather than being dropped.
+func parse(r io.Reader, side string) ([]*entry, error) {
+	var entries []*entry
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var record struct {
+			Meta *core.Meta `json:"_meta"`
+		}
+		if err := json.Unmarshal(line, &record); err != nil || record.Meta == nil {
+			return nil, fmt.Errorf("stowmerge: %s line %d is not a record", side, lineNum)
+		}
+
+		var value interface{}
+		if err := json.Unmarshal(line, &value); err != nil {
+			return nil, fmt.Errorf("stowmerge: %s line %d is not a record", side, lineNum)
+		}
+
+		entries = append(entries, &entry{
+			meta:  *record.Meta,
+			line:  append([]byte(nil), line...),
+			value: value,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("stowmerge: failed to read %s: %w", side, err)
+	}
+
+	return entries, nil
+}
+
+// renumber rewrites the record's version, keeping its data as is.
+func (e *entry) renumber(version int) error {
+	if e.meta.Version == version {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(e.line, &fields); err != nil {
+		return err
+	}
+
+	e.meta.Version = version
+	meta, err := json.Marshal(e.meta)
+	if err != nil {
+		return err
+	}
+	fields["_meta"] = meta
+
+	line, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	e.line = line
+	return nil
+}
+
+// checkKeys ensures every record belongs to the same key.
+func checkKeys(histories ...[]*entry) error {
+	key, seen := "", false
+	for _, entries := range histories {
+		for _, e := range entries {
+			if !seen {
+				key, seen = e.meta.Key, true
+			} else if e.meta.Key != key {
+				return fmt.Errorf("%w: %q and %q", ErrKeyMismatch, key, e.meta.Key)
+			}
+		}
+	}
+	return nil
+}
+
+// byVersion indexes entries by version; a repeated version keeps the last.
+func byVersion(entries []*entry) map[int]*entry {
+	m := make(map[int]*entry, len(entries))
+	for _, e := range entries {
+		m[e.meta.Version] = e
+	}
+	return m
+}
+
+// versions returns the distinct versions of the histories in order.
+func versions(histories ...[]*entry) []int {
+	seen := make(map[int]bool)
+	var result []int
+	for _, entries := range histories {
+		for _, e := range entries {
+			if !seen[e.meta.Version] {
+				seen[e.meta.Version] = true
+				result = append(result, e.meta.Version)
+			}
+		}
+	}
+	sort.Ints(result)
+	return result
+}
+
+// same reports whether two records are equal as JSON (nil means absent).
+func same(a, b *entry) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return bytes.Equal(a.line, b.line) || reflect.DeepEqual(a.value, b.value)
+}
+
+func line(e *entry) []byte {
+	if e == nil {
+		return nil
+	}
+	return e.line
+}
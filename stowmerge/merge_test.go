@@ -0,0 +1,160 @@
+package stowmerge
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// rec builds a record line of key "k" written at the given minute.
+func rec(version int, minute int, value string) string {
+	return fmt.Sprintf(`{"_meta":{"k":"k","v":%d,"op":"put","ts":"2025-01-01T00:%02d:00Z"},"data":{"value":%q}}`,
+		version, minute, value)
+}
+
+func history(lines ...string) *strings.Reader {
+	if len(lines) == 0 {
+		return strings.NewReader("")
+	}
+	return strings.NewReader(strings.Join(lines, "\n") + "\n")
+}
+
+// mergedValues returns "version:value" for each merged record.
+func mergedValues(t *testing.T, merged []byte) []string {
+	t.Helper()
+
+	entries, err := parse(strings.NewReader(string(merged)), "merged")
+	if err != nil {
+		t.Fatalf("merged output does not parse: %v", err)
+	}
+
+	var values []string
+	for _, e := range entries {
+		data := e.value.(map[string]interface{})["data"].(map[string]interface{})
+		values = append(values, fmt.Sprintf("%d:%v", e.meta.Version, data["value"]))
+	}
+	return values
+}
+
+func TestMergeOneSideAppended(t *testing.T) {
+	base := []string{rec(1, 0, "a")}
+
+	merged, conflicts, err := Merge(history(base...), history(base...),
+		history(rec(1, 0, "a"), rec(2, 1, "b")))
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if len(conflicts) != 0 {
+		t.Errorf("Expected no conflicts, got %v", conflicts)
+	}
+
+	got := strings.Join(mergedValues(t, merged), ",")
+	if got != "1:a,2:b" {
+		t.Errorf("Merged = %s, want 1:a,2:b", got)
+	}
+}
+
+func TestMergeDivergentAppends(t *testing.T) {
+	base := []string{rec(1, 0, "a")}
+	ours := []string{rec(1, 0, "a"), rec(2, 3, "ours")}
+	theirs := []string{rec(1, 0, "a"), rec(2, 1, "theirs-1"), rec(3, 5, "theirs-2")}
+
+	merged, conflicts, err := Merge(history(base...), history(ours...), history(theirs...))
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if len(conflicts) != 0 {
+		t.Errorf("Expected no conflicts, got %v", conflicts)
+	}
+
+	got := strings.Join(mergedValues(t, merged), ",")
+	want := "1:a,2:theirs-1,3:ours,4:theirs-2"
+	if got != want {
+		t.Errorf("Merged = %s, want %s", got, want)
+	}
+}
+
+func TestMergeDivergentAppendsClockSkew(t *testing.T) {
+	// Their clock stepped back between their two writes
+	base := []string{rec(1, 0, "a")}
+	ours := []string{rec(1, 0, "a"), rec(2, 3, "ours")}
+	theirs := []string{rec(1, 0, "a"), rec(2, 5, "theirs-1"), rec(3, 1, "theirs-2")}
+
+	merged, _, err := Merge(history(base...), history(ours...), history(theirs...))
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+
+	got := strings.Join(mergedValues(t, merged), ",")
+	want := "1:a,2:ours,3:theirs-1,4:theirs-2"
+	if got != want {
+		t.Errorf("Merged = %s, want %s", got, want)
+	}
+}
+
+func TestMergeKeepsUnchangedLinesVerbatim(t *testing.T) {
+	// Unusual spacing must survive a merge that doesn't renumber
+	line := `{"_meta": {"k":"k","v":1,"op":"put","ts":"2025-01-01T00:00:00Z"}, "data": {"value":"a"}}`
+
+	merged, _, err := Merge(history(), history(line), history())
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if string(merged) != line+"\n" {
+		t.Errorf("Merged = %q, want %q", merged, line+"\n")
+	}
+}
+
+func TestMergeCompactedSide(t *testing.T) {
+	base := []string{rec(1, 0, "a"), rec(2, 1, "b"), rec(3, 2, "c")}
+	ours := []string{rec(3, 2, "c")} // compacted
+	theirs := []string{rec(1, 0, "a"), rec(2, 1, "b"), rec(3, 2, "c"), rec(4, 3, "d")}
+
+	merged, conflicts, err := Merge(history(base...), history(ours...), history(theirs...))
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if len(conflicts) != 0 {
+		t.Errorf("Expected no conflicts, got %v", conflicts)
+	}
+
+	got := strings.Join(mergedValues(t, merged), ",")
+	if got != "3:c,4:d" {
+		t.Errorf("Merged = %s, want 3:c,4:d", got)
+	}
+}
+
+func TestMergeConflict(t *testing.T) {
+	// Both sides rewrote version 1 in place (e.g. noversion fields)
+	base := []string{rec(1, 0, "a")}
+
+	merged, conflicts, err := Merge(history(base...), history(rec(1, 0, "ours")), history(rec(1, 0, "theirs")))
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if len(conflicts) != 1 || conflicts[0].Version != 1 {
+		t.Fatalf("Expected a conflict at version 1, got %v", conflicts)
+	}
+	if !strings.Contains(string(conflicts[0].Theirs), "theirs") {
+		t.Errorf("Conflict should carry theirs, got %s", conflicts[0].Theirs)
+	}
+
+	got := strings.Join(mergedValues(t, merged), ",")
+	if got != "1:ours" {
+		t.Errorf("Merged = %s, want 1:ours", got)
+	}
+}
+
+func TestMergeErrors(t *testing.T) {
+	other := strings.Replace(rec(1, 0, "a"), `"k":"k"`, `"k":"other"`, 1)
+	_, _, err := Merge(history(), history(rec(1, 0, "a")), history(other))
+	if !errors.Is(err, ErrKeyMismatch) {
+		t.Errorf("Expected ErrKeyMismatch, got %v", err)
+	}
+
+	_, _, err = Merge(history(), history("not json"), history())
+	if err == nil {
+		t.Error("Expected error for invalid line")
+	}
+}
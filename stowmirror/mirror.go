@@ -0,0 +1,420 @@
+// Package stowmirror mirrors the writes of a namespace to an external
+// database, for migrating off (or onto) stow gradually: both stores are
+// written until the other side is trusted, then reads move over and stow
+// is dropped, without a big-bang cutover.
+//
+// A Mirror is a stow.Namespace whose writes also go to a Sink. Writes that
+// the sink rejects are queued in a local namespace and retried by Flush, or
+// with WithRetryInterval, so an outage of the external database never fails
+// or loses a stow write.
+//
+// Example:
+//
+//	queue := store.MustGetNamespace("users-mirror-queue")
+//	users, err := stowmirror.New(store.MustGetNamespace("users"), pgSink,
+//		stowmirror.WithQueue(queue), stowmirror.WithRetryInterval(time.Minute))
+//	defer users.Close()
+//
+//	users.MustPut("user:1", user)        // stored in stow and in Postgres
+//	err = users.Resync()                 // backfill keys written before
+package stowmirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aigotowork/stow"
+)
+
+// ErrSink wraps the sink's error when a write can't be mirrored and no
+// queue is configured. The stow write itself succeeded.
+var ErrSink = errors.New("stowmirror: sink write failed")
+
+// Sink is the external database a Mirror writes to. Put receives the
+// latest record of a key as stored in stow: blob fields are blob references
+// (maps with "$blob"), not file contents. Writes of one key are never
+// concurrent, and each call carries the key's current state, so sinks can
+// upsert and ignore ordering.
+type Sink interface {
+	// Put stores the latest record of key
+	Put(ctx context.Context, key string, item stow.RawItem) error
+
+	// Delete removes key, which no longer exists in stow
+	Delete(ctx context.Context, key string) error
+}
+
+// Option configures a Mirror.
+type Option func(*Mirror)
+
+// WithQueue keeps the keys whose sink writes failed in queue, a namespace
+// of its own, until Flush mirrors them. Without a queue, writes return
+// ErrSink when the sink fails.
+func WithQueue(queue stow.Namespace) Option {
+	return func(m *Mirror) {
+		m.queue = queue
+	}
+}
+
+// WithRetryInterval flushes the queue every interval until Close.
+func WithRetryInterval(interval time.Duration) Option {
+	return func(m *Mirror) {
+		m.interval = interval
+	}
+}
+
+// WithSinkTimeout bounds every sink call.
+// Default: no timeout
+func WithSinkTimeout(timeout time.Duration) Option {
+	return func(m *Mirror) {
+		m.timeout = timeout
+	}
+}
+
+// queued is the queue entry of a key whose sink write failed. Only the key
+// is replayed: Flush sends its state at that time.
+type queued struct {
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error"`
+	QueuedAt  time.Time `json:"queued_at"`
+}
+
+// Mirror is a namespace whose key writes are mirrored to a Sink: Put,
+// MustPut, PutAuto, AppendVersions, Delete, MustDelete, Rename, AppendPath,
+// InsertPath, RemovePath, LoadKey, AdoptFile and Txn. Other writes (e.g. Sweep)
+// reach the sink through Resync. Reads go to stow. It is safe for
+// concurrent use.
+type Mirror struct {
+	stow.Namespace
+
+	sink     Sink
+	queue    stow.Namespace
+	interval time.Duration
+	timeout  time.Duration
+
+	keyLocks sync.Map // key → *sync.Mutex
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New returns a Mirror writing to ns and sink.
+func New(ns stow.Namespace, sink Sink, opts ...Option) (*Mirror, error) {
+	if ns == nil || sink == nil {
+		return nil, fmt.Errorf("stowmirror: nil namespace or sink")
+	}
+
+	m := &Mirror{Namespace: ns, sink: sink}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.interval < 0 || m.timeout < 0 {
+		return nil, fmt.Errorf("stowmirror: negative interval or timeout")
+	}
+	if m.interval > 0 && m.queue == nil {
+		return nil, fmt.Errorf("stowmirror: WithRetryInterval needs WithQueue")
+	}
+
+	if m.interval > 0 {
+		m.stop = make(chan struct{})
+		m.done = make(chan struct{})
+		go m.run()
+	}
+	return m, nil
+}
+
+// Close stops retrying the queue. The namespaces stay open.
+func (m *Mirror) Close() error {
+	if m.stop != nil {
+		close(m.stop)
+		<-m.done
+		m.stop = nil
+	}
+	return nil
+}
+
+// Put stores value in stow and mirrors the key.
+func (m *Mirror) Put(key string, value interface{}, opts ...stow.PutOption) error {
+	return m.write(func() error {
+		return m.Namespace.Put(key, value, opts...)
+	}, key)
+}
+
+// MustPut is like Put but panics on error.
+func (m *Mirror) MustPut(key string, value interface{}, opts ...stow.PutOption) {
+	if err := m.Put(key, value, opts...); err != nil {
+		panic(err)
+	}
+}
+
+// PutAuto stores value under a generated key and mirrors it.
+func (m *Mirror) PutAuto(value interface{}, opts ...stow.PutOption) (string, error) {
+	key, err := m.Namespace.PutAuto(value, opts...)
+	if err != nil {
+		return key, err
+	}
+	return key, m.Sync(key)
+}
+
+// PutWithUniqueKey stores value under the first unused key derived from
+// base and mirrors it.
+func (m *Mirror) PutWithUniqueKey(base string, value interface{}, opts ...stow.PutOption) (string, error) {
+	key, err := m.Namespace.PutWithUniqueKey(base, value, opts...)
+	if err != nil {
+		return key, err
+	}
+	return key, m.Sync(key)
+}
+
+// AppendVersions appends versions of key in stow and mirrors the latest.
+func (m *Mirror) AppendVersions(key string, values []interface{}) error {
+	return m.write(func() error {
+		return m.Namespace.AppendVersions(key, values)
+	}, key)
+}
+
+// Delete deletes key in stow and in the sink.
+func (m *Mirror) Delete(key string, opts ...stow.DeleteOption) error {
+	return m.write(func() error {
+		return m.Namespace.Delete(key, opts...)
+	}, key)
+}
+
+// MustDelete is like Delete but panics on error.
+func (m *Mirror) MustDelete(key string, opts ...stow.DeleteOption) {
+	if err := m.Delete(key, opts...); err != nil {
+		panic(err)
+	}
+}
+
+// Rename moves a key in stow, deleting oldKey and storing newKey in the sink.
+func (m *Mirror) Rename(oldKey, newKey string) error {
+	return m.write(func() error {
+		return m.Namespace.Rename(oldKey, newKey)
+	}, oldKey, newKey)
+}
+
+// AppendPath appends to an array field and mirrors the key.
+func (m *Mirror) AppendPath(key, path string, value interface{}) error {
+	return m.write(func() error {
+		return m.Namespace.AppendPath(key, path, value)
+	}, key)
+}
+
+// InsertPath inserts into an array field and mirrors the key.
+func (m *Mirror) InsertPath(key, path string, value interface{}) error {
+	return m.write(func() error {
+		return m.Namespace.InsertPath(key, path, value)
+	}, key)
+}
+
+// RemovePath removes an element or field and mirrors the key.
+func (m *Mirror) RemovePath(key, path string) error {
+	return m.write(func() error {
+		return m.Namespace.RemovePath(key, path)
+	}, key)
+}
+
+// LoadKey restores a key from a DumpKey stream and mirrors it.
+func (m *Mirror) LoadKey(r io.Reader, opts ...stow.LoadOption) (string, error) {
+	key, err := m.Namespace.LoadKey(r, opts...)
+	if err != nil {
+		return key, err
+	}
+	return key, m.Sync(key)
+}
+
+// AdoptFile adopts a history file as key in stow and mirrors its latest record.
+func (m *Mirror) AdoptFile(key, path string, opts stow.AdoptOptions) error {
+	return m.write(func() error {
+		return m.Namespace.AdoptFile(key, path, opts)
+	}, key)
+}
+
+// Txn commits a transaction and mirrors every key it wrote.
+func (m *Mirror) Txn(fn func(tx stow.Txn) error) error {
+	written := make(map[string]bool)
+	err := m.Namespace.Txn(func(tx stow.Txn) error {
+		return fn(&mirroredTxn{Txn: tx, written: written})
+	})
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(written))
+	for key := range written {
+		keys = append(keys, key)
+	}
+	return m.write(nil, keys...)
+}
+
+// mirroredTxn notes the keys a transaction writes.
+type mirroredTxn struct {
+	stow.Txn
+	written map[string]bool
+}
+
+func (t *mirroredTxn) Put(key string, value interface{}) error {
+	if err := t.Txn.Put(key, value); err != nil {
+		return err
+	}
+	t.written[key] = true
+	return nil
+}
+
+func (t *mirroredTxn) Delete(key string) error {
+	if err := t.Txn.Delete(key); err != nil {
+		return err
+	}
+	t.written[key] = true
+	return nil
+}
+
+// Sync sends the current state of key to the sink: its latest record, or
+// a delete if it doesn't exist.
+func (m *Mirror) Sync(key string) error {
+	return m.write(nil, key)
+}
+
+// Resync syncs keys, or every existing key when none are given, e.g. to
+// backfill the sink before switching writes over to a Mirror. Deleted keys
+// must be named to be deleted in the sink.
+func (m *Mirror) Resync(keys ...string) error {
+	if len(keys) == 0 {
+		var err error
+		if keys, err = m.Namespace.List(); err != nil {
+			return err
+		}
+	}
+
+	var errs []error
+	for _, key := range keys {
+		if err := m.Sync(key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Pending returns the queued keys in ascending order.
+func (m *Mirror) Pending() ([]string, error) {
+	if m.queue == nil {
+		return nil, nil
+	}
+	keys, err := m.queue.List()
+	sort.Strings(keys)
+	return keys, err
+}
+
+// Flush retries the queued keys and returns how many the sink accepted.
+// Keys the sink still rejects stay queued.
+func (m *Mirror) Flush() (int, error) {
+	keys, err := m.Pending()
+	if err != nil {
+		return 0, err
+	}
+
+	flushed := 0
+	for _, key := range keys {
+		if m.Sync(key) == nil && !m.queue.Exists(key) {
+			flushed++
+		}
+	}
+	return flushed, nil
+}
+
+// write runs fn, if any, and mirrors keys while holding their locks, so
+// the sink sees the writes of a key in order.
+func (m *Mirror) write(fn func() error, keys ...string) error {
+	sorted := append([]string(nil), keys...)
+	sort.Strings(sorted)
+	for _, key := range sorted {
+		lock := m.keyLock(key)
+		lock.Lock()
+		defer lock.Unlock()
+	}
+
+	if fn != nil {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+
+	var errs []error
+	for _, key := range keys {
+		if err := m.mirror(key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// mirror sends the state of key to the sink, queueing it on failure and
+// dequeuing it on success (caller holds the key's lock).
+func (m *Mirror) mirror(key string) error {
+	ctx := context.Background()
+	if m.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.timeout)
+		defer cancel()
+	}
+
+	item, err := m.Namespace.GetRaw(key)
+	switch {
+	case err == nil:
+		err = m.sink.Put(ctx, key, item)
+	case errors.Is(err, stow.ErrNotFound):
+		err = m.sink.Delete(ctx, key)
+	default:
+		return err
+	}
+
+	if m.queue == nil {
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrSink, key, err)
+		}
+		return nil
+	}
+
+	if err == nil {
+		if m.queue.Exists(key) {
+			return m.queue.Delete(key)
+		}
+		return nil
+	}
+
+	var entry queued
+	if m.queue.Get(key, &entry) != nil {
+		entry = queued{QueuedAt: time.Now().UTC()}
+	}
+	entry.Attempts++
+	entry.LastError = err.Error()
+	return m.queue.Put(key, entry)
+}
+
+// keyLock returns the mutex of key.
+func (m *Mirror) keyLock(key string) *sync.Mutex {
+	lock, _ := m.keyLocks.LoadOrStore(key, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+// run flushes the queue every interval until Close.
+func (m *Mirror) run() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.Flush()
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package stowmirror
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aigotowork/stow"
+)
+
+// memorySink is a Sink keeping the raw data of each key, failing while down.
+type memorySink struct {
+	mu   sync.Mutex
+	data map[string]map[string]interface{}
+	down bool
+}
+
+func (s *memorySink) Put(ctx context.Context, key string, item stow.RawItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return errors.New("connection refused")
+	}
+	s.data[key] = item.RawData()
+	return nil
+}
+
+func (s *memorySink) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return errors.New("connection refused")
+	}
+	delete(s.data, key)
+	return nil
+}
+
+func (s *memorySink) setDown(down bool) {
+	s.mu.Lock()
+	s.down = down
+	s.mu.Unlock()
+}
+
+func (s *memorySink) get(key string) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data[key]
+}
+
+func newMirror(t *testing.T, opts ...func(stow.Store) Option) (*Mirror, *memorySink) {
+	t.Helper()
+	store := stow.MustOpen(t.TempDir())
+	t.Cleanup(func() { store.Close() })
+
+	var options []Option
+	for _, opt := range opts {
+		options = append(options, opt(store))
+	}
+
+	sink := &memorySink{data: make(map[string]map[string]interface{})}
+	m, err := New(store.MustGetNamespace("users"), sink, options...)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return m, sink
+}
+
+func withQueue(store stow.Store) Option {
+	return WithQueue(store.MustGetNamespace("users-queue"))
+}
+
+func TestMirror(t *testing.T) {
+	m, sink := newMirror(t)
+
+	m.MustPut("user:1", map[string]interface{}{"name": "Alice"})
+	if got := sink.get("user:1"); got["name"] != "Alice" {
+		t.Fatalf("sink has %v", got)
+	}
+
+	if err := m.AppendPath("user:1", "roles", "admin"); err != nil {
+		t.Fatalf("AppendPath failed: %v", err)
+	}
+	if got := sink.get("user:1"); !reflect.DeepEqual(got["roles"], []interface{}{"admin"}) {
+		t.Errorf("sink has %v", got)
+	}
+
+	if err := m.Rename("user:1", "user:2"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if sink.get("user:1") != nil || sink.get("user:2")["name"] != "Alice" {
+		t.Errorf("sink has %v", sink.data)
+	}
+
+	m.MustDelete("user:2")
+	if len(sink.data) != 0 {
+		t.Errorf("sink has %v", sink.data)
+	}
+
+	// Without a queue, sink failures are returned but stow is written
+	sink.setDown(true)
+	if err := m.Put("user:3", map[string]interface{}{"name": "Carol"}); !errors.Is(err, ErrSink) {
+		t.Errorf("expected ErrSink, got %v", err)
+	}
+	if !m.Exists("user:3") {
+		t.Error("expected the stow write to succeed")
+	}
+
+	// Resync backfills
+	sink.setDown(false)
+	if err := m.Resync(); err != nil {
+		t.Fatalf("Resync failed: %v", err)
+	}
+	if sink.get("user:3")["name"] != "Carol" {
+		t.Errorf("sink has %v", sink.data)
+	}
+}
+
+func TestMirrorQueue(t *testing.T) {
+	m, sink := newMirror(t, withQueue)
+
+	sink.setDown(true)
+	m.MustPut("user:1", map[string]interface{}{"name": "Alice"})
+	m.MustPut("user:1", map[string]interface{}{"name": "Alicia"})
+	m.MustPut("user:2", map[string]interface{}{"name": "Bob"})
+	m.MustDelete("user:2")
+
+	pending, err := m.Pending()
+	if err != nil || !reflect.DeepEqual(pending, []string{"user:1", "user:2"}) {
+		t.Fatalf("Pending = %v, %v", pending, err)
+	}
+
+	// Still down: nothing is flushed
+	if n, err := m.Flush(); n != 0 || err != nil {
+		t.Errorf("Flush = %d, %v", n, err)
+	}
+
+	sink.data["user:2"] = map[string]interface{}{"name": "stale"}
+	sink.setDown(false)
+	if n, err := m.Flush(); n != 2 || err != nil {
+		t.Fatalf("Flush = %d, %v", n, err)
+	}
+	if sink.get("user:1")["name"] != "Alicia" || sink.get("user:2") != nil {
+		t.Errorf("sink has %v", sink.data)
+	}
+	if pending, _ := m.Pending(); len(pending) != 0 {
+		t.Errorf("Pending = %v after Flush", pending)
+	}
+}
+
+func TestMirrorRetryInterval(t *testing.T) {
+	m, sink := newMirror(t, withQueue, func(stow.Store) Option {
+		return WithRetryInterval(10 * time.Millisecond)
+	})
+
+	sink.setDown(true)
+	m.MustPut("user:1", map[string]interface{}{"name": "Alice"})
+	sink.setDown(false)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for sink.get("user:1") == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("queued write was never retried")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	ns := store.MustGetNamespace("users")
+	sink := &memorySink{}
+
+	if _, err := New(nil, sink); err == nil {
+		t.Error("expected an error for a nil namespace")
+	}
+	if _, err := New(ns, sink, WithRetryInterval(time.Second)); err == nil {
+		t.Error("expected an error for a retry interval without a queue")
+	}
+}
@@ -0,0 +1,55 @@
+package stowmt
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aigotowork/stow"
+)
+
+// checkConfig rejects the settings of config that would reach outside the
+// tenant's namespace directory: BlobDir and BlobSearchPaths name arbitrary
+// directories, so one tenant could read or overwrite another's blobs.
+func checkConfig(config stow.NamespaceConfig) error {
+	if config.BlobDir != "" {
+		return fmt.Errorf("%w: tenant namespaces can't set BlobDir", stow.ErrInvalidConfig)
+	}
+	if len(config.BlobSearchPaths) > 0 {
+		return fmt.Errorf("%w: tenant namespaces can't set BlobSearchPaths", stow.ErrInvalidConfig)
+	}
+	return nil
+}
+
+// tenantNamespace is a tenant's namespace whose SetConfig is checked with
+// checkConfig.
+type tenantNamespace struct {
+	stow.Namespace
+}
+
+func (n *tenantNamespace) SetConfig(config stow.NamespaceConfig) error {
+	if err := checkConfig(config); err != nil {
+		return err
+	}
+	return n.Namespace.SetConfig(config)
+}
+
+// Handles derived from the namespace keep the check
+
+func (n *tenantNamespace) WithContext(ctx context.Context) stow.Namespace {
+	return &tenantNamespace{Namespace: n.Namespace.WithContext(ctx)}
+}
+
+func (n *tenantNamespace) WithLogger(logger stow.Logger) stow.Namespace {
+	n.Namespace.WithLogger(logger)
+	return n
+}
+
+func (n *tenantNamespace) WithBlobThreshold(bytes int64) stow.Namespace {
+	n.Namespace.WithBlobThreshold(bytes)
+	return n
+}
+
+func (n *tenantNamespace) WithMaxFileSize(bytes int64) stow.Namespace {
+	n.Namespace.WithMaxFileSize(bytes)
+	return n
+}
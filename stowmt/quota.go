@@ -0,0 +1,139 @@
+package stowmt
+
+import (
+	"context"
+	"io"
+
+	"github.com/aigotowork/stow"
+)
+
+// quotaNamespace is a tenant's namespace whose writes of values are
+// checked against the tenant's quota: Put, MustPut, PutAuto,
+// PutWithUniqueKey, AppendVersions, Txn, AppendPath, InsertPath, LoadKey
+// and AdoptFile. A write that starts under the quota completes, so
+// concurrent writes can take a tenant past it.
+type quotaNamespace struct {
+	stow.Namespace
+	t *Tenant
+}
+
+// wrap returns ns with its config checked (see checkConfig) and checked
+// against the tenant's quota, if it has one.
+func (t *Tenant) wrap(ns stow.Namespace) stow.Namespace {
+	ns = &tenantNamespace{Namespace: ns}
+	if t.ts.opts.QuotaPerTenant == 0 {
+		return ns
+	}
+	return &quotaNamespace{Namespace: ns, t: t}
+}
+
+// put runs a Put-like write, adding the bytes it wrote to the usage.
+func (q *quotaNamespace) put(opts []stow.PutOption, fn func(opts []stow.PutOption) error) error {
+	if err := q.t.checkQuota(); err != nil {
+		return err
+	}
+
+	var stats stow.IOStats
+	err := fn(append(opts[:len(opts):len(opts)], stow.WithIOStats(&stats)))
+	q.t.wrote(stats)
+	return err
+}
+
+// write runs a write whose size isn't reported, counting the usage again
+// at the next check.
+func (q *quotaNamespace) write(fn func() error) error {
+	if err := q.t.checkQuota(); err != nil {
+		return err
+	}
+
+	err := fn()
+	q.t.recount()
+	return err
+}
+
+func (q *quotaNamespace) Put(key string, value interface{}, opts ...stow.PutOption) error {
+	return q.put(opts, func(opts []stow.PutOption) error {
+		return q.Namespace.Put(key, value, opts...)
+	})
+}
+
+func (q *quotaNamespace) MustPut(key string, value interface{}, opts ...stow.PutOption) {
+	if err := q.Put(key, value, opts...); err != nil {
+		panic(err)
+	}
+}
+
+func (q *quotaNamespace) PutAuto(value interface{}, opts ...stow.PutOption) (key string, err error) {
+	err = q.put(opts, func(opts []stow.PutOption) error {
+		key, err = q.Namespace.PutAuto(value, opts...)
+		return err
+	})
+	return key, err
+}
+
+func (q *quotaNamespace) PutWithUniqueKey(base string, value interface{}, opts ...stow.PutOption) (key string, err error) {
+	err = q.put(opts, func(opts []stow.PutOption) error {
+		key, err = q.Namespace.PutWithUniqueKey(base, value, opts...)
+		return err
+	})
+	return key, err
+}
+
+func (q *quotaNamespace) AppendVersions(key string, values []interface{}) error {
+	return q.write(func() error {
+		return q.Namespace.AppendVersions(key, values)
+	})
+}
+
+func (q *quotaNamespace) Txn(fn func(tx stow.Txn) error) error {
+	return q.write(func() error {
+		return q.Namespace.Txn(fn)
+	})
+}
+
+func (q *quotaNamespace) AppendPath(key, path string, value interface{}) error {
+	return q.write(func() error {
+		return q.Namespace.AppendPath(key, path, value)
+	})
+}
+
+func (q *quotaNamespace) InsertPath(key, path string, value interface{}) error {
+	return q.write(func() error {
+		return q.Namespace.InsertPath(key, path, value)
+	})
+}
+
+func (q *quotaNamespace) LoadKey(r io.Reader, opts ...stow.LoadOption) (key string, err error) {
+	err = q.write(func() error {
+		key, err = q.Namespace.LoadKey(r, opts...)
+		return err
+	})
+	return key, err
+}
+
+func (q *quotaNamespace) AdoptFile(key, path string, opts stow.AdoptOptions) error {
+	return q.write(func() error {
+		return q.Namespace.AdoptFile(key, path, opts)
+	})
+}
+
+// Handles derived from the namespace keep the quota
+
+func (q *quotaNamespace) WithContext(ctx context.Context) stow.Namespace {
+	return &quotaNamespace{Namespace: q.Namespace.WithContext(ctx), t: q.t}
+}
+
+func (q *quotaNamespace) WithLogger(logger stow.Logger) stow.Namespace {
+	q.Namespace.WithLogger(logger)
+	return q
+}
+
+func (q *quotaNamespace) WithBlobThreshold(bytes int64) stow.Namespace {
+	q.Namespace.WithBlobThreshold(bytes)
+	return q
+}
+
+func (q *quotaNamespace) WithMaxFileSize(bytes int64) stow.Namespace {
+	q.Namespace.WithMaxFileSize(bytes)
+	return q
+}
@@ -0,0 +1,333 @@
+// Package stowmt scopes a store to tenants, for SaaS apps keeping every
+// customer's data in one store: each tenant gets a handle whose namespaces
+// are its own, within an optional disk quota and with an optional
+// encryption key of its own.
+//
+// Tenants never share a namespace, so their blobs are stored and garbage
+// collected apart, and identical files uploaded by two tenants are stored
+// twice rather than revealing one to the other.
+//
+// Example:
+//
+//	tenants, err := stowmt.New(store, stowmt.Options{
+//		NamespacePrefix: true,
+//		QuotaPerTenant:  1 << 30,
+//		KeyPerTenant:    keyring.TenantKey,
+//	})
+//
+//	acme, err := tenants.ForTenant("acme")
+//	users := acme.MustGetNamespace("users") // stored as "acme.users"
+//	users.MustPut("user:1", user)
+package stowmt
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aigotowork/stow"
+	"github.com/aigotowork/stow/internal/fsutil"
+)
+
+var (
+	// ErrInvalidTenant is returned for tenant IDs that can't name a namespace.
+	ErrInvalidTenant = errors.New("stowmt: invalid tenant ID")
+
+	// ErrQuotaExceeded is returned by writes of a tenant whose namespaces
+	// take up QuotaPerTenant bytes or more.
+	ErrQuotaExceeded = errors.New("stowmt: tenant quota exceeded")
+)
+
+// separator joins a tenant ID and a namespace name with NamespacePrefix.
+const separator = "."
+
+// Options configures Tenants.
+type Options struct {
+	// NamespacePrefix gives each tenant any number of namespaces, stored
+	// as "<tenant>.<name>". Without it a tenant has a single namespace,
+	// named by its ID, which GetNamespace returns for an empty name.
+	NamespacePrefix bool
+
+	// QuotaPerTenant caps the bytes a tenant's namespaces take up on disk,
+	// blobs included. Writes that store values fail with ErrQuotaExceeded
+	// once the tenant is at its quota; deletes, compaction and GC still
+	// work, so it can free space. 0 disables the quota.
+	QuotaPerTenant int64
+
+	// KeyPerTenant returns the encryption key of a tenant (32 bytes,
+	// AES-256). Its namespaces are created encrypted with the key and
+	// opened with it, also after the store is reopened. nil leaves
+	// namespaces unencrypted.
+	KeyPerTenant func(tenant string) ([]byte, error)
+}
+
+// Tenants hands out the tenant-scoped handles of a store. It is safe for
+// concurrent use.
+type Tenants struct {
+	store stow.Store
+	opts  Options
+
+	mu      sync.Mutex
+	tenants map[string]*Tenant
+}
+
+// New returns the Tenants of store.
+func New(store stow.Store, opts Options) (*Tenants, error) {
+	if store == nil {
+		return nil, fmt.Errorf("stowmt: nil store")
+	}
+	if opts.QuotaPerTenant < 0 {
+		return nil, fmt.Errorf("stowmt: negative quota")
+	}
+
+	return &Tenants{store: store, opts: opts, tenants: make(map[string]*Tenant)}, nil
+}
+
+// ForTenant returns the handle of tenant id. Every call for the same ID
+// returns the same handle, so its quota is tracked once. IDs must be
+// usable as file names and may not contain "." or start with "_".
+func (ts *Tenants) ForTenant(id string) (*Tenant, error) {
+	if !fsutil.IsSafeName(id) || strings.Contains(id, separator) || strings.HasPrefix(id, "_") {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTenant, id)
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if t, ok := ts.tenants[id]; ok {
+		return t, nil
+	}
+
+	t := &Tenant{ts: ts, id: id}
+	if ts.opts.KeyPerTenant != nil {
+		key, err := ts.opts.KeyPerTenant(id)
+		if err != nil {
+			return nil, fmt.Errorf("stowmt: key of tenant %s: %w", id, err)
+		}
+		t.key = key
+	}
+	ts.tenants[id] = t
+	return t, nil
+}
+
+// Tenant is the handle of one tenant: a view of the store holding only the
+// tenant's namespaces. Namespace names are the tenant's own; the store
+// names them as set by Options.NamespacePrefix. It is safe for concurrent
+// use.
+type Tenant struct {
+	ts  *Tenants
+	id  string
+	key []byte
+
+	// Bytes the tenant's namespaces take up, counted from disk and then
+	// kept up to date by the Puts of its handles
+	mu      sync.Mutex
+	used    int64
+	counted bool
+}
+
+// ID returns the tenant's ID.
+func (t *Tenant) ID() string {
+	return t.id
+}
+
+// storeName returns the store's name for the tenant's namespace name.
+func (t *Tenant) storeName(name string) (string, error) {
+	if !t.ts.opts.NamespacePrefix {
+		if name != "" {
+			return "", fmt.Errorf("stowmt: tenant %s has a single namespace, named \"\"", t.id)
+		}
+		return t.id, nil
+	}
+	if name == "" {
+		return "", fmt.Errorf("stowmt: empty namespace name")
+	}
+	return t.id + separator + name, nil
+}
+
+// GetNamespace returns one of the tenant's namespaces, creating it with
+// the default config if it doesn't exist.
+func (t *Tenant) GetNamespace(name string) (stow.Namespace, error) {
+	storeName, err := t.storeName(name)
+	if err != nil {
+		return nil, err
+	}
+	if t.key != nil {
+		if err := t.ts.store.SetNamespaceKey(storeName, t.key); err != nil {
+			return nil, err
+		}
+	}
+
+	ns, err := t.ts.store.GetNamespace(storeName)
+	if err != nil {
+		return nil, err
+	}
+	return t.wrap(ns), nil
+}
+
+// MustGetNamespace is like GetNamespace but panics on error.
+func (t *Tenant) MustGetNamespace(name string) stow.Namespace {
+	ns, err := t.GetNamespace(name)
+	if err != nil {
+		panic(err)
+	}
+	return ns
+}
+
+// CreateNamespace creates one of the tenant's namespaces with config,
+// encrypted with the tenant's key if it has one. Configs setting BlobDir
+// or BlobSearchPaths are rejected with stow.ErrInvalidConfig, as is setting
+// them later through SetConfig.
+func (t *Tenant) CreateNamespace(name string, config stow.NamespaceConfig) (stow.Namespace, error) {
+	storeName, err := t.storeName(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkConfig(config); err != nil {
+		return nil, err
+	}
+	if t.key != nil {
+		config = config.WithKey(t.key)
+	}
+
+	ns, err := t.ts.store.CreateNamespace(storeName, config)
+	if err != nil {
+		return nil, err
+	}
+	return t.wrap(ns), nil
+}
+
+// ListNamespaces returns the names of the tenant's namespaces in
+// ascending order.
+func (t *Tenant) ListNamespaces() ([]string, error) {
+	all, err := t.ts.store.ListNamespaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, storeName := range all {
+		if !t.ts.opts.NamespacePrefix {
+			if storeName == t.id {
+				names = append(names, "")
+			}
+		} else if name, ok := strings.CutPrefix(storeName, t.id+separator); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// DeleteNamespace deletes one of the tenant's namespaces and all its data.
+func (t *Tenant) DeleteNamespace(name string) error {
+	storeName, err := t.storeName(name)
+	if err != nil {
+		return err
+	}
+	if err := t.ts.store.DeleteNamespace(storeName); err != nil {
+		return err
+	}
+	t.recount()
+	return nil
+}
+
+// CloseNamespace evicts the open handle of one of the tenant's namespaces.
+func (t *Tenant) CloseNamespace(name string) error {
+	storeName, err := t.storeName(name)
+	if err != nil {
+		return err
+	}
+	return t.ts.store.CloseNamespace(storeName)
+}
+
+// Delete deletes every namespace of the tenant. The handle stays usable.
+func (t *Tenant) Delete() error {
+	names, err := t.ListNamespaces()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, name := range names {
+		if err := t.DeleteNamespace(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Usage counts the bytes the tenant's namespaces take up on disk, blobs
+// included, opening them if needed.
+func (t *Tenant) Usage() (int64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.count()
+}
+
+// count counts the tenant's usage from disk (caller must hold t.mu).
+func (t *Tenant) count() (int64, error) {
+	names, err := t.ListNamespaces()
+	if err != nil {
+		return 0, err
+	}
+
+	var used int64
+	for _, name := range names {
+		ns, err := t.GetNamespace(name)
+		if err != nil {
+			return 0, err
+		}
+		stats, err := ns.Stats()
+		if err != nil {
+			return 0, err
+		}
+		used += stats.TotalSize
+	}
+
+	t.used, t.counted = used, true
+	return used, nil
+}
+
+// recount has the next quota check count the usage from disk, after
+// writes whose size isn't known.
+func (t *Tenant) recount() {
+	t.mu.Lock()
+	t.counted = false
+	t.mu.Unlock()
+}
+
+// wrote adds the bytes a Put wrote to the usage.
+func (t *Tenant) wrote(stats stow.IOStats) {
+	t.mu.Lock()
+	t.used += stats.BytesWritten
+	t.mu.Unlock()
+}
+
+// checkQuota fails with ErrQuotaExceeded if the tenant is at its quota.
+// The usage is counted again before a write is refused, so space freed
+// since (by GC, say) is taken into account.
+func (t *Tenant) checkQuota() error {
+	quota := t.ts.opts.QuotaPerTenant
+	if quota == 0 {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.counted && t.used < quota {
+		return nil
+	}
+	used, err := t.count()
+	if err != nil {
+		return err
+	}
+	if used >= quota {
+		return fmt.Errorf("%w: tenant %s uses %d of %d bytes", ErrQuotaExceeded, t.id, used, quota)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package stowmt
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func newTenants(t *testing.T, store stow.Store, opts Options) *Tenants {
+	t.Helper()
+
+	ts, err := New(store, opts)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return ts
+}
+
+func forTenant(t *testing.T, ts *Tenants, id string) *Tenant {
+	t.Helper()
+
+	tenant, err := ts.ForTenant(id)
+	if err != nil {
+		t.Fatalf("ForTenant(%s) failed: %v", id, err)
+	}
+	return tenant
+}
+
+func TestNamespacePrefix(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	defer store.Close()
+	ts := newTenants(t, store, Options{NamespacePrefix: true})
+
+	acme, globex := forTenant(t, ts, "acme"), forTenant(t, ts, "globex")
+	acme.MustGetNamespace("users").MustPut("user:1", map[string]interface{}{"name": "Alice"})
+	acme.MustGetNamespace("files").MustPut("logo", map[string]interface{}{"data": []byte("same logo")}, stow.WithForceFile())
+	globex.MustGetNamespace("files").MustPut("logo", map[string]interface{}{"data": []byte("same logo")}, stow.WithForceFile())
+
+	if names, _ := acme.ListNamespaces(); !reflect.DeepEqual(names, []string{"files", "users"}) {
+		t.Errorf("acme's namespaces = %v", names)
+	}
+	if globex.MustGetNamespace("users").Exists("user:1") {
+		t.Error("Expected globex not to see acme's keys")
+	}
+
+	// Identical blobs are stored once per tenant
+	for _, storeName := range []string{"acme.files", "globex.files"} {
+		blobs, _ := filepath.Glob(filepath.Join(dir, storeName, "_blobs", "*"))
+		if len(blobs) != 1 {
+			t.Errorf("Expected a blob in %s, got %v", storeName, blobs)
+		}
+	}
+
+	if err := acme.Delete(); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if names, _ := store.ListNamespaces(); !reflect.DeepEqual(names, []string{"globex.files", "globex.users"}) {
+		t.Errorf("Expected only globex's namespaces left, got %v", names)
+	}
+
+	for _, id := range []string{"", "a.b", "_stats", "a/b"} {
+		if _, err := ts.ForTenant(id); !errors.Is(err, ErrInvalidTenant) {
+			t.Errorf("ForTenant(%q) = %v, want ErrInvalidTenant", id, err)
+		}
+	}
+}
+
+func TestSingleNamespace(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	acme := forTenant(t, newTenants(t, store, Options{}), "acme")
+
+	acme.MustGetNamespace("").MustPut("user:1", map[string]interface{}{"name": "Alice"})
+	if _, err := acme.GetNamespace("users"); err == nil {
+		t.Error("Expected named namespaces to be refused")
+	}
+	if names, _ := store.ListNamespaces(); !reflect.DeepEqual(names, []string{"acme"}) {
+		t.Errorf("store's namespaces = %v", names)
+	}
+	if names, _ := acme.ListNamespaces(); !reflect.DeepEqual(names, []string{""}) {
+		t.Errorf("acme's namespaces = %q", names)
+	}
+}
+
+func TestQuotaPerTenant(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	ts := newTenants(t, store, Options{NamespacePrefix: true, QuotaPerTenant: 4096})
+	acme, globex := forTenant(t, ts, "acme"), forTenant(t, ts, "globex")
+
+	files := acme.MustGetNamespace("files")
+	big := map[string]interface{}{"data": bytes.Repeat([]byte("x"), 8192)}
+	if err := files.Put("big", big, stow.WithForceFile()); err != nil {
+		t.Fatalf("Expected the write under the quota to succeed: %v", err)
+	}
+	if err := files.Put("small", map[string]interface{}{"n": 1}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Expected ErrQuotaExceeded, got %v", err)
+	}
+	if err := files.Txn(func(tx stow.Txn) error { return tx.Put("small", map[string]interface{}{"n": 1}) }); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded for Txn, got %v", err)
+	}
+
+	// Other tenants are unaffected
+	if err := globex.MustGetNamespace("files").Put("small", map[string]interface{}{"n": 1}); err != nil {
+		t.Errorf("Expected globex's write to succeed: %v", err)
+	}
+
+	// Freed space is counted again
+	files.MustDelete("big")
+	if _, err := files.Prune(stow.PruneOptions{}); err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if _, err := files.GC(); err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	if err := files.Put("small", map[string]interface{}{"n": 1}); err != nil {
+		t.Errorf("Expected the write to succeed after GC: %v", err)
+	}
+	if used, err := acme.Usage(); err != nil || used == 0 || used >= 4096 {
+		t.Errorf("Usage() = %d, %v", used, err)
+	}
+}
+
+func TestKeyPerTenant(t *testing.T) {
+	dir := t.TempDir()
+	keys := map[string][]byte{"acme": bytes.Repeat([]byte{1}, 32)}
+	opts := Options{
+		NamespacePrefix: true,
+		KeyPerTenant: func(tenant string) ([]byte, error) {
+			return keys[tenant], nil
+		},
+	}
+
+	store := stow.MustOpen(dir)
+	acme := forTenant(t, newTenants(t, store, opts), "acme")
+	acme.MustGetNamespace("users").MustPut("user:1", map[string]interface{}{"name": "Alice"})
+	store.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "acme.users", "user*.jsonl"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one key file, got %v", files)
+	}
+	if data, _ := os.ReadFile(files[0]); bytes.Contains(data, []byte("Alice")) {
+		t.Error("Expected the key file to be encrypted")
+	}
+
+	// A new process opens the tenant's namespaces with its key
+	store = stow.MustOpen(dir)
+	defer store.Close()
+	acme = forTenant(t, newTenants(t, store, opts), "acme")
+
+	var user map[string]interface{}
+	if err := acme.MustGetNamespace("users").Get("user:1", &user); err != nil || user["name"] != "Alice" {
+		t.Errorf("Get = %v, %v", user, err)
+	}
+	if _, err := store.GetNamespace("acme.users"); err != nil {
+		t.Errorf("Expected the store to keep the key, got %v", err)
+	}
+}
+
+func TestTenantBlobPaths(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(filepath.Join(dir, "store"))
+	defer store.Close()
+	ts := newTenants(t, store, Options{NamespacePrefix: true})
+	acme := forTenant(t, ts, "acme")
+
+	outside := filepath.Join(dir, "shared")
+	withBlobDir := stow.DefaultNamespaceConfig()
+	withBlobDir.BlobDir = outside
+	withSearchPaths := stow.DefaultNamespaceConfig()
+	withSearchPaths.BlobSearchPaths = []string{outside}
+	for _, config := range []stow.NamespaceConfig{withBlobDir, withSearchPaths} {
+		if _, err := acme.CreateNamespace("files", config); !errors.Is(err, stow.ErrInvalidConfig) {
+			t.Errorf("Expected ErrInvalidConfig, got %v", err)
+		}
+	}
+
+	// Nor can they be set afterwards, through any handle
+	files := acme.MustGetNamespace("files")
+	config := files.GetConfig()
+	config.BlobSearchPaths = []string{outside}
+	if err := files.WithLogger(stow.NewNoopLogger()).SetConfig(config); !errors.Is(err, stow.ErrInvalidConfig) {
+		t.Errorf("Expected ErrInvalidConfig from SetConfig, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package stowtest
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+// dataset populates a fresh namespace and returns every version of every key.
+func dataset(t *testing.T, spec FixtureSpec) ([]string, map[string][]interface{}, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	t.Cleanup(func() { store.Close() })
+	ns := store.MustGetNamespace("fixtures")
+
+	keys, err := Populate(ns, spec)
+	if err != nil {
+		t.Fatalf("Populate failed: %v", err)
+	}
+
+	values := make(map[string][]interface{})
+	for _, key := range keys {
+		history, err := ns.GetHistory(key)
+		if err != nil {
+			t.Fatalf("GetHistory(%s) failed: %v", key, err)
+		}
+		for _, version := range history {
+			var data map[string]interface{}
+			if err := ns.GetVersion(key, version.Version, &data); err != nil {
+				t.Fatalf("GetVersion(%s, %d) failed: %v", key, version.Version, err)
+			}
+			if file, ok := data["attachment"].(stow.IFileData); ok {
+				// Compare blobs by content hash
+				data["attachment"] = file.Hash()
+				file.Close()
+			}
+			values[key] = append(values[key], data)
+		}
+	}
+
+	return keys, values, filepath.Join(dir, "fixtures", "_blobs")
+}
+
+func TestPopulate(t *testing.T) {
+	spec := FixtureSpec{Keys: 50, Versions: 2, BlobRatio: 0.2, BlobSize: 512, Seed: 42}
+
+	keys, first, blobDir := dataset(t, spec)
+	if len(keys) != 50 || keys[0] != "key:00" || keys[49] != "key:49" {
+		t.Fatalf("unexpected keys %v", keys)
+	}
+	for _, key := range keys {
+		if len(first[key]) != 2 {
+			t.Fatalf("%s has %d versions, want 2", key, len(first[key]))
+		}
+	}
+
+	blobs, _ := filepath.Glob(filepath.Join(blobDir, "*"))
+	if len(blobs) < 5 || len(blobs) > 40 {
+		t.Errorf("expected about 20 blobs, got %d", len(blobs))
+	}
+
+	// Same spec, same data
+	_, second, _ := dataset(t, spec)
+	if !reflect.DeepEqual(first, second) {
+		t.Error("equal specs wrote different data")
+	}
+
+	// Another seed, other data
+	spec.Seed = 7
+	_, other, _ := dataset(t, spec)
+	if reflect.DeepEqual(first, other) {
+		t.Error("different seeds wrote the same data")
+	}
+}
+
+func TestPopulateInvalidSpec(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	ns := store.MustGetNamespace("fixtures")
+
+	for _, spec := range []FixtureSpec{
+		{Keys: -1},
+		{Keys: 1, BlobRatio: 1.5},
+		{Keys: 1, ValueShape: []Field{{Name: "x", Kind: Kind(99)}}},
+		{Keys: 1, ValueShape: []Field{{Kind: String}}},
+	} {
+		if _, err := Populate(ns, spec); !errors.Is(err, ErrInvalidSpec) {
+			t.Errorf("Populate(%+v): expected ErrInvalidSpec, got %v", spec, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package stow_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestAdoptFile(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	ns := store.MustGetNamespace("orders")
+
+	src := filepath.Join(t.TempDir(), "order-42.jsonl")
+	lines := `{"_meta":{"k":"order:42","v":1,"op":"put","ts":"2025-12-14T18:09:00Z"},"data":{"state":"created"}}
+{"_meta":{"k":"order:42","v":2,"op":"put","ts":"2025-12-14T18:10:00Z"},"data":{"state":"paid"}}
+`
+	if err := os.WriteFile(src, []byte(lines), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ns.AdoptFile("order:42", src, stow.AdoptOptions{Validate: true}); err != nil {
+		t.Fatalf("AdoptFile failed: %v", err)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Errorf("source file still present: %v", err)
+	}
+
+	var order map[string]interface{}
+	ns.MustGet("order:42", &order)
+	if order["state"] != "paid" {
+		t.Errorf("latest is %v", order)
+	}
+
+	// Later writes append to the adopted history
+	ns.MustPut("order:42", map[string]interface{}{"state": "shipped"})
+	history, err := ns.GetHistory("order:42")
+	if err != nil || len(history) != 3 || history[0].Version != 3 {
+		t.Fatalf("unexpected history %+v, %v", history, err)
+	}
+
+	// Existing keys are not replaced
+	if err := os.WriteFile(src, []byte(lines), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ns.AdoptFile("order:42", src, stow.AdoptOptions{}); !errors.Is(err, stow.ErrKeyConflict) {
+		t.Errorf("expected ErrKeyConflict, got %v", err)
+	}
+}
+
+func TestAdoptFileValidate(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	ns := store.MustGetNamespace("orders")
+
+	cases := map[string]string{
+		"garbage":   "not json\n",
+		"other key": `{"_meta":{"k":"order:7","v":1,"op":"put","ts":"2025-12-14T18:09:00Z"},"data":{}}` + "\n",
+		"out of order": `{"_meta":{"k":"order:42","v":2,"op":"put","ts":"2025-12-14T18:09:00Z"},"data":{}}
+{"_meta":{"k":"order:42","v":1,"op":"put","ts":"2025-12-14T18:10:00Z"},"data":{}}
+`,
+		"missing blob": `{"_meta":{"k":"order:42","v":1,"op":"put","ts":"2025-12-14T18:09:00Z"},"data":{"receipt":{"$blob":true,"loc":"_blobs/receipt_0123abcd.pdf","hash":"0123abcd","size":10}}}` + "\n",
+		"empty":        "",
+	}
+
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			src := filepath.Join(t.TempDir(), "order.jsonl")
+			if err := os.WriteFile(src, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := ns.AdoptFile("order:42", src, stow.AdoptOptions{Validate: true})
+			if !errors.Is(err, stow.ErrCorruptedData) {
+				t.Fatalf("expected ErrCorruptedData, got %v", err)
+			}
+			if _, err := os.Stat(src); err != nil {
+				t.Errorf("rejected file was moved: %v", err)
+			}
+			if ns.Exists("order:42") {
+				t.Error("rejected file was registered")
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package stow_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestAppendVersions(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.HashChain = true
+	ns, err := store.CreateNamespace("orders", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	ns.MustPut("order:42", map[string]interface{}{"state": "draft"})
+	err = ns.AppendVersions("order:42", []interface{}{
+		map[string]interface{}{"state": "created"},
+		map[string]interface{}{"state": "paid", "receipt": []byte(strings.Repeat("r", 8192))},
+		map[string]interface{}{"state": "shipped"},
+	})
+	if err != nil {
+		t.Fatalf("AppendVersions failed: %v", err)
+	}
+
+	history, err := ns.GetHistory("order:42")
+	if err != nil || len(history) != 4 || history[0].Version != 4 {
+		t.Fatalf("unexpected history %+v, %v", history, err)
+	}
+
+	var order map[string]interface{}
+	ns.MustGet("order:42", &order)
+	if order["state"] != "shipped" {
+		t.Errorf("latest is %v", order)
+	}
+	var paid map[string]interface{}
+	if err := ns.GetVersion("order:42", 3, &paid); err != nil || paid["state"] != "paid" {
+		t.Errorf("version 3 is %v, %v", paid, err)
+	}
+	if _, err := ns.VerifyChain("order:42"); err != nil {
+		t.Errorf("VerifyChain failed: %v", err)
+	}
+
+	// New keys start at version 1
+	if err := ns.AppendVersions("order:43", []interface{}{"a", "b"}); err != nil {
+		t.Fatalf("AppendVersions failed: %v", err)
+	}
+	if history, _ := ns.GetHistory("order:43"); len(history) != 2 || history[1].Version != 1 {
+		t.Errorf("unexpected history %+v", history)
+	}
+
+	// A value that can't be stored writes nothing
+	err = ns.AppendVersions("order:44", []interface{}{"a", make(chan int)})
+	if err == nil {
+		t.Fatal("expected an error for an unsupported value")
+	}
+	if ns.Exists("order:44") {
+		t.Error("expected no version to be written")
+	}
+}
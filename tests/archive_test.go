@@ -0,0 +1,149 @@
+package stow_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+type archivedUser struct {
+	Name   string `json:"name"`
+	Avatar []byte `json:"avatar"`
+}
+
+func TestArchiveNamespace(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	ns := store.MustGetNamespace("tenant-42")
+	avatar := []byte(strings.Repeat("x", 8*1024))
+	ns.MustPut("user:1", archivedUser{Name: "Alice"})
+	ns.MustPut("user:1", archivedUser{Name: "Alice Smith", Avatar: avatar})
+	ns.MustPut("user:2", archivedUser{Name: "Bob"})
+	ns.MustDelete("user:2")
+	ns.MustPut("user:3", archivedUser{Name: "Carol"})
+	if err := ns.PinVersion("user:1", 1); err != nil {
+		t.Fatalf("PinVersion failed: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "tenant-42.stow")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.ArchiveNamespace("tenant-42", f); err != nil {
+		t.Fatalf("ArchiveNamespace failed: %v", err)
+	}
+	f.Close()
+
+	f, err = os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	archive, err := stow.OpenArchive(f)
+	if err != nil {
+		t.Fatalf("OpenArchive failed: %v", err)
+	}
+	if archive.Namespace() != "tenant-42" {
+		t.Errorf("Expected namespace tenant-42, got %q", archive.Namespace())
+	}
+
+	keys, err := archive.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(keys) != 2 || keys[0] != "user:1" || keys[1] != "user:3" {
+		t.Errorf("Expected [user:1 user:3], got %v", keys)
+	}
+
+	var user archivedUser
+	if err := archive.Get("user:1", &user); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if user.Name != "Alice Smith" || !bytes.Equal(user.Avatar, avatar) {
+		t.Errorf("Unexpected user: %s, %d avatar bytes", user.Name, len(user.Avatar))
+	}
+
+	if err := archive.Get("user:2", &user); !errors.Is(err, stow.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for deleted key, got %v", err)
+	}
+	if err := archive.Get("user:9", &user); !errors.Is(err, stow.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for missing key, got %v", err)
+	}
+
+	history, err := archive.GetHistory("user:2")
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if len(history) != 2 || history[0].Operation != "delete" {
+		t.Errorf("Unexpected history: %+v", history)
+	}
+	var previous archivedUser
+	if err := history[1].Decode(&previous); err != nil || previous.Name == "" {
+		t.Errorf("Decode history entry: %+v, %v", previous, err)
+	}
+
+	var old archivedUser
+	if err := archive.GetVersion("user:1", 1, &old); err != nil || old.Name != "Alice" {
+		t.Errorf("GetVersion: %+v, %v", old, err)
+	}
+
+	pins, _ := archive.Pins("user:1")
+	if len(pins) != 1 || pins[0] != 1 {
+		t.Errorf("Expected pin on version 1, got %v", pins)
+	}
+}
+
+func TestArchiveFileDataField(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	ns := store.MustGetNamespace("docs")
+	content := []byte(strings.Repeat("report ", 2000))
+	ns.MustPut("report", map[string]interface{}{"body": content}, stow.WithFileName("report.txt"))
+
+	var buf bytes.Buffer
+	if err := store.ArchiveNamespace("docs", &buf); err != nil {
+		t.Fatalf("ArchiveNamespace failed: %v", err)
+	}
+
+	archive, err := stow.OpenArchive(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("OpenArchive failed: %v", err)
+	}
+
+	var doc struct {
+		Body stow.IFileData `json:"body"`
+	}
+	if err := archive.Get("report", &doc); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	defer doc.Body.Close()
+
+	data, err := io.ReadAll(doc.Body)
+	if err != nil || !bytes.Equal(data, content) {
+		t.Errorf("Blob streamed from archive differs (%d bytes, %v)", len(data), err)
+	}
+}
+
+func TestArchiveNamespaceErrors(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	var buf bytes.Buffer
+	if err := store.ArchiveNamespace("missing", &buf); !errors.Is(err, stow.ErrNamespaceNotFound) {
+		t.Errorf("Expected ErrNamespaceNotFound, got %v", err)
+	}
+
+	if _, err := stow.OpenArchive(strings.NewReader("not an archive at all")); !errors.Is(err, stow.ErrCorruptedData) {
+		t.Errorf("Expected ErrCorruptedData, got %v", err)
+	}
+}
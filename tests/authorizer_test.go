@@ -0,0 +1,107 @@
+package stow_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+// tenantAuthorizer lets principals access their own "tenant-<name>" namespace,
+// and only "admin" run maintenance or manage namespaces.
+func tenantAuthorizer(ctx context.Context, op stow.Operation, namespace, key string) error {
+	user, ok := stow.PrincipalFromContext(ctx)
+	if !ok {
+		return errors.New("no principal")
+	}
+	if user == "admin" {
+		return nil
+	}
+
+	switch op {
+	case stow.OpAdmin, stow.OpCreateNamespace, stow.OpDeleteNamespace, stow.OpListNamespaces:
+		return fmt.Errorf("%s may not %s", user, op)
+	}
+	if namespace != "tenant-"+user {
+		return fmt.Errorf("%s may not access %s", user, namespace)
+	}
+	if op == stow.OpDelete && strings.HasPrefix(key, "locked:") {
+		return fmt.Errorf("%s may not delete %s", user, key)
+	}
+	return nil
+}
+
+func TestAuthorizer(t *testing.T) {
+	store := stow.MustOpen(t.TempDir(), stow.WithStoreAuthorizer(tenantAuthorizer))
+	defer store.Close()
+
+	admin := store.WithContext(stow.ContextWithPrincipal(context.Background(), "admin"))
+	for _, name := range []string{"tenant-alice", "tenant-bob"} {
+		if _, err := admin.CreateNamespace(name, stow.DefaultNamespaceConfig()); err != nil {
+			t.Fatalf("admin CreateNamespace failed: %v", err)
+		}
+	}
+
+	alice := store.WithContext(stow.ContextWithPrincipal(context.Background(), "alice"))
+
+	ns, err := alice.GetNamespace("tenant-alice")
+	if err != nil {
+		t.Fatalf("alice GetNamespace failed: %v", err)
+	}
+	ns.MustPut("profile", map[string]interface{}{"name": "Alice"})
+	ns.MustPut("locked:invoice", map[string]interface{}{"total": 10})
+
+	var profile map[string]interface{}
+	ns.MustGet("profile", &profile)
+
+	// Per-key and per-role checks on the bound handle
+	if err := ns.Delete("locked:invoice"); !errors.Is(err, stow.ErrPermissionDenied) {
+		t.Errorf("Delete locked key: expected ErrPermissionDenied, got %v", err)
+	}
+	if _, err := ns.GC(); !errors.Is(err, stow.ErrPermissionDenied) {
+		t.Errorf("GC: expected ErrPermissionDenied, got %v", err)
+	}
+	if err := ns.Delete("profile"); err != nil {
+		t.Errorf("Delete own key failed: %v", err)
+	}
+
+	// Other tenants and store management are off limits
+	if _, err := alice.GetNamespace("tenant-bob"); !errors.Is(err, stow.ErrPermissionDenied) {
+		t.Errorf("GetNamespace other tenant: expected ErrPermissionDenied, got %v", err)
+	}
+	if _, err := alice.ListNamespaces(); !errors.Is(err, stow.ErrPermissionDenied) {
+		t.Errorf("ListNamespaces: expected ErrPermissionDenied, got %v", err)
+	}
+	if err := alice.DeleteNamespace("tenant-alice"); !errors.Is(err, stow.ErrPermissionDenied) {
+		t.Errorf("DeleteNamespace: expected ErrPermissionDenied, got %v", err)
+	}
+
+	// Rebinding a handle switches the principal
+	bob := ns.WithContext(stow.ContextWithPrincipal(context.Background(), "bob"))
+	if err := bob.Get("locked:invoice", &profile); !errors.Is(err, stow.ErrPermissionDenied) {
+		t.Errorf("bob Get: expected ErrPermissionDenied, got %v", err)
+	}
+	if bob.Exists("locked:invoice") {
+		t.Error("Exists should report false when denied")
+	}
+
+	// Handles without a context carry no principal
+	if _, err := store.GetNamespace("tenant-alice"); !errors.Is(err, stow.ErrPermissionDenied) {
+		t.Errorf("unbound GetNamespace: expected ErrPermissionDenied, got %v", err)
+	}
+}
+
+func TestAuthorizerDisabled(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	// Without an authorizer, contexts are accepted and ignored
+	ns := store.WithContext(context.Background()).MustGetNamespace("data")
+	ns.MustPut("k", map[string]interface{}{"v": 1})
+	if !ns.WithContext(context.TODO()).Exists("k") {
+		t.Error("expected key to exist")
+	}
+}
@@ -0,0 +1,91 @@
+package stow_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestBackup(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	users := store.MustGetNamespace("users")
+	avatar := []byte(strings.Repeat("x", 8*1024))
+	users.MustPut("user:1", archivedUser{Name: "Alice"})
+	users.MustPut("user:1", archivedUser{Name: "Alice Smith", Avatar: avatar})
+	users.MustPut("user:2", archivedUser{Name: "Bob"})
+
+	orders := store.MustGetNamespace("orders")
+	orders.MustPut("order:1", map[string]interface{}{"total": 42})
+	store.MustGetNamespace("empty")
+
+	var buf bytes.Buffer
+	if err := store.Backup(&buf); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	backup, err := stow.OpenBackup(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("OpenBackup failed: %v", err)
+	}
+	if names := backup.Namespaces(); strings.Join(names, ",") != "empty,orders,users" {
+		t.Errorf("Namespaces = %v, want [empty orders users]", names)
+	}
+	if backup.Created().IsZero() {
+		t.Error("Created should be set")
+	}
+
+	archive, err := backup.Namespace("users")
+	if err != nil {
+		t.Fatalf("Namespace failed: %v", err)
+	}
+	var user archivedUser
+	if err := archive.Get("user:1", &user); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if user.Name != "Alice Smith" || !bytes.Equal(user.Avatar, avatar) {
+		t.Errorf("got %q with %d byte avatar", user.Name, len(user.Avatar))
+	}
+	history, err := archive.GetHistory("user:1")
+	if err != nil || len(history) != 2 {
+		t.Errorf("GetHistory = %d versions, %v; want 2", len(history), err)
+	}
+
+	archive, err = backup.Namespace("orders")
+	if err != nil {
+		t.Fatalf("Namespace failed: %v", err)
+	}
+	if keys, _ := archive.List(); len(keys) != 1 || keys[0] != "order:1" {
+		t.Errorf("orders keys = %v", keys)
+	}
+
+	archive, err = backup.Namespace("empty")
+	if err != nil {
+		t.Fatalf("Namespace failed: %v", err)
+	}
+	if keys, _ := archive.List(); len(keys) != 0 {
+		t.Errorf("empty keys = %v", keys)
+	}
+
+	if _, err := backup.Namespace("missing"); !errors.Is(err, stow.ErrNamespaceNotFound) {
+		t.Errorf("expected ErrNamespaceNotFound, got %v", err)
+	}
+}
+
+func TestOpenBackupRejectsArchive(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	store.MustGetNamespace("users").MustPut("a", 1)
+
+	var buf bytes.Buffer
+	if err := store.ArchiveNamespace("users", &buf); err != nil {
+		t.Fatalf("ArchiveNamespace failed: %v", err)
+	}
+	if _, err := stow.OpenBackup(bytes.NewReader(buf.Bytes())); !errors.Is(err, stow.ErrCorruptedData) {
+		t.Errorf("expected ErrCorruptedData, got %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package stow_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestBlobDir(t *testing.T) {
+	storeDir := t.TempDir()
+	hdd := filepath.Join(t.TempDir(), "hdd", "media")
+
+	store := stow.MustOpen(storeDir)
+	config := signedConfig(stow.SigningHMACSHA256, []byte("secret"))
+	config.BlobDir = hdd
+	ns, err := store.CreateNamespace("media", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	avatar := []byte(strings.Repeat("x", 8*1024))
+	ns.MustPut("user:1", archivedUser{Name: "Alice", Avatar: avatar})
+
+	files, _ := os.ReadDir(hdd)
+	if len(files) != 1 {
+		t.Fatalf("expected the blob in %s, got %d files", hdd, len(files))
+	}
+	if entries, _ := os.ReadDir(filepath.Join(storeDir, "media", "_blobs")); len(entries) != 0 {
+		t.Errorf("expected no blobs in _blobs/, got %d", len(entries))
+	}
+	store.Close()
+
+	// The persisted BlobDir wins over the default config on reopen
+	store = stow.MustOpen(storeDir, stow.WithStoreSigningKey("media", []byte("secret")))
+	defer store.Close()
+	ns = store.MustGetNamespace("media")
+
+	var user archivedUser
+	if err := ns.Get("user:1", &user); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !bytes.Equal(user.Avatar, avatar) {
+		t.Errorf("got %d byte avatar, want %d", len(user.Avatar), len(avatar))
+	}
+
+	result, err := ns.Verify()
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !result.OK() {
+		t.Errorf("Verify = %+v, want OK", result)
+	}
+
+	// A damaged blob on the other volume is reported
+	os.WriteFile(filepath.Join(hdd, files[0].Name()), []byte("damaged"), 0644)
+	result, err = ns.Verify()
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if result.OK() || len(result.Blobs["user:1"]) != 1 {
+		t.Errorf("Verify = %+v, want user:1 in Blobs", result)
+	}
+
+	// BlobDir can't change under an open namespace
+	changed := ns.GetConfig()
+	changed.BlobDir = filepath.Join(t.TempDir(), "elsewhere")
+	if err := ns.SetConfig(changed); !errors.Is(err, stow.ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+
+	if err := store.DeleteNamespace("media"); err != nil {
+		t.Fatalf("DeleteNamespace failed: %v", err)
+	}
+	if _, err := os.Stat(hdd); !os.IsNotExist(err) {
+		t.Errorf("expected the blob directory to be deleted, got %v", err)
+	}
+}
+
+func TestBlobDirRelative(t *testing.T) {
+	storeDir := t.TempDir()
+	store := stow.MustOpen(storeDir)
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.BlobDir = "../../blobs/docs"
+	ns, err := store.CreateNamespace("docs", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+	ns.MustPut("a", archivedUser{Name: "A", Avatar: []byte(strings.Repeat("y", 8*1024))})
+
+	want := filepath.Join(filepath.Dir(storeDir), "blobs", "docs")
+	if files, _ := os.ReadDir(want); len(files) != 1 {
+		t.Errorf("expected 1 blob in %s, got %d", want, len(files))
+	}
+
+	// Inside the namespace directory blob files would look like keys
+	config.BlobDir = "blobs"
+	if _, err := store.CreateNamespace("inside", config); !errors.Is(err, stow.ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+	config.BlobDir = filepath.Join(storeDir, "inside2", "blobs")
+	if _, err := store.CreateNamespace("inside2", config); !errors.Is(err, stow.ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for an absolute path inside, got %v", err)
+	}
+}
+
+func TestBlobCache(t *testing.T) {
+	storeDir := t.TempDir()
+	remote := filepath.Join(t.TempDir(), "nfs", "media")
+	cacheDir := t.TempDir()
+
+	store := stow.MustOpen(storeDir, stow.WithStoreBlobCache(cacheDir, 1<<20))
+	defer store.Close()
+	config := signedConfig(stow.SigningHMACSHA256, []byte("secret"))
+	config.BlobDir = remote
+	ns, err := store.CreateNamespace("media", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	avatar := []byte(strings.Repeat("x", 8*1024))
+	ns.MustPut("user:1", archivedUser{Name: "Alice", Avatar: avatar})
+	for i := 0; i < 2; i++ {
+		var user archivedUser
+		if err := ns.Get("user:1", &user); err != nil || !bytes.Equal(user.Avatar, avatar) {
+			t.Fatalf("Get = %d byte avatar, %v", len(user.Avatar), err)
+		}
+	}
+	if stats := store.Resources().BlobCache; stats.Misses != 1 || stats.Hits != 1 || stats.Bytes == 0 {
+		t.Errorf("BlobCache = %+v, want one miss and one hit", stats)
+	}
+
+	// Verify checks the remote file, not the copy
+	files, _ := os.ReadDir(remote)
+	os.WriteFile(filepath.Join(remote, files[0].Name()), []byte("damaged"), 0644)
+	if result, err := ns.Verify(); err != nil || result.OK() {
+		t.Errorf("Verify = %+v, %v, want the damaged blob reported", result, err)
+	}
+
+	if err := store.DeleteNamespace("media"); err != nil {
+		t.Fatalf("DeleteNamespace failed: %v", err)
+	}
+	if stats := store.Resources().BlobCache; stats.Bytes != 0 {
+		t.Errorf("expected the namespace's copies dropped, got %d bytes", stats.Bytes)
+	}
+
+	if _, err := stow.Open(t.TempDir(), stow.WithStoreBlobCache(cacheDir, 0)); !errors.Is(err, stow.ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for a zero size, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package stow_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestBlobFilters(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.BlobFilters = []string{stow.BlobFilterGzip, stow.BlobFilterCRC32}
+	ns, err := store.CreateNamespace("docs", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	type doc struct {
+		Body []byte `stow:"file"`
+	}
+	body := bytes.Repeat([]byte("stow "), 20000)
+	ns.MustPut("manual", doc{Body: body})
+
+	var got doc
+	ns.MustGet("manual", &got)
+	if !bytes.Equal(got.Body, body) {
+		t.Fatal("blob content changed")
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "docs", "_blobs", "*"))
+	if len(files) != 1 {
+		t.Fatalf("got blob files %v, want one", files)
+	}
+	info, _ := os.Stat(files[0])
+	if info.Size() >= int64(len(body))/10 {
+		t.Errorf("blob file is %d bytes, want it compressed", info.Size())
+	}
+
+	// Corruption is caught by the checksum
+	data, _ := os.ReadFile(files[0])
+	data[len(data)-1] ^= 0xff
+	os.WriteFile(files[0], data, 0644)
+	var stream struct {
+		Body stow.IFileData
+	}
+	ns.MustGet("manual", &stream)
+	defer stream.Body.Close()
+	if _, err := io.ReadAll(stream.Body); !errors.Is(err, stow.ErrBlobChecksum) {
+		t.Errorf("reading a corrupted blob = %v, want ErrBlobChecksum", err)
+	}
+}
+
+func TestBlobFiltersUnknown(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.BlobFilters = []string{"zstd"}
+	if _, err := store.CreateNamespace("docs", config); !errors.Is(err, stow.ErrInvalidConfig) {
+		t.Errorf("CreateNamespace with an unknown filter = %v, want ErrInvalidConfig", err)
+	}
+}
@@ -0,0 +1,73 @@
+package stow_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestBlobHashBLAKE3(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	defer store.Close()
+
+	ns := store.MustGetNamespace("files")
+
+	sha := bytes.Repeat([]byte("s"), 8*1024)
+	ns.MustPut("old", map[string]interface{}{"content": sha})
+
+	// Switch to BLAKE3 for new blobs
+	config := ns.GetConfig()
+	config.BlobHash = stow.BlobHashBLAKE3
+	if err := ns.SetConfig(config); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+
+	b3 := bytes.Repeat([]byte("b"), 8*1024)
+	ns.MustPut("new", map[string]interface{}{"content": b3})
+
+	content, err := os.ReadFile(filepath.Join(ns.Path(), "new.jsonl"))
+	if err != nil {
+		t.Fatalf("failed to read record: %v", err)
+	}
+	if !strings.Contains(string(content), `"algo":"blake3"`) {
+		t.Errorf("record should name the blob algorithm: %s", content)
+	}
+
+	// Both blobs stay readable after reopening the mixed namespace
+	store.Close()
+	store = stow.MustOpen(dir)
+	ns = store.MustGetNamespace("files")
+
+	if got := ns.GetConfig().BlobHash; got != stow.BlobHashBLAKE3 {
+		t.Errorf("persisted BlobHash = %q", got)
+	}
+
+	for key, want := range map[string][]byte{"old": sha, "new": b3} {
+		var result struct {
+			Content []byte `json:"content"`
+		}
+		ns.MustGet(key, &result)
+		if !bytes.Equal(result.Content, want) {
+			t.Errorf("%s: content mismatch", key)
+		}
+	}
+}
+
+func TestBlobHashValidation(t *testing.T) {
+	config := stow.DefaultNamespaceConfig()
+	config.BlobHash = "md5"
+	if err := config.Validate(); err == nil {
+		t.Error("unknown BlobHash should be invalid")
+	}
+
+	// Configs persisted before BlobHash existed default to SHA-256
+	config.BlobHash = ""
+	if err := config.Validate(); err != nil {
+		t.Errorf("empty BlobHash should be valid: %v", err)
+	}
+}
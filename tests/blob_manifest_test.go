@@ -0,0 +1,118 @@
+package stow_test
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestBlobManifest(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	defer store.Close()
+	ns := store.MustGetNamespace("users")
+
+	shared := []byte(strings.Repeat("s", 8*1024))
+	old := []byte(strings.Repeat("o", 8*1024))
+	ns.MustPut("user:1", archivedUser{Name: "Alice", Avatar: old})
+	ns.MustPut("user:1", archivedUser{Name: "Alice", Avatar: shared})
+	ns.MustPut("user:2", archivedUser{Name: "Bob", Avatar: shared})
+	ns.MustPut("user:3", archivedUser{Name: "Carol"})
+
+	var buf bytes.Buffer
+	if err := ns.BlobManifest(&buf); err != nil {
+		t.Fatalf("BlobManifest failed: %v", err)
+	}
+
+	var entries []stow.BlobManifestEntry
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var entry stow.BlobManifestEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+
+	byHash := make(map[string]stow.BlobManifestEntry)
+	for _, entry := range entries {
+		byHash[entry.Hash] = entry
+
+		// Entries check against the files on disk
+		content, err := os.ReadFile(filepath.Join(dir, "users", entry.Location))
+		if err != nil {
+			t.Fatalf("reading %s: %v", entry.Location, err)
+		}
+		sum := sha256.Sum256(content)
+		if entry.Algo != "sha256" || hex.EncodeToString(sum[:]) != entry.Hash || int64(len(content)) != entry.Size {
+			t.Errorf("entry %+v doesn't match its file", entry)
+		}
+	}
+
+	sharedSum := sha256.Sum256(shared)
+	if got := byHash[hex.EncodeToString(sharedSum[:])].ReferencedBy; !reflect.DeepEqual(got, []string{"user:1", "user:2"}) {
+		t.Errorf("shared blob referenced by %v", got)
+	}
+
+	// Old versions keep their blobs listed
+	oldSum := sha256.Sum256(old)
+	if got := byHash[hex.EncodeToString(oldSum[:])].ReferencedBy; !reflect.DeepEqual(got, []string{"user:1"}) {
+		t.Errorf("old blob referenced by %v", got)
+	}
+}
+
+func TestBlobReferrers(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	ns := store.MustGetNamespace("users")
+
+	shared := []byte(strings.Repeat("s", 8*1024))
+	ns.MustPut("user:2", archivedUser{Name: "Bob", Avatar: shared})
+	ns.MustPut("user:1", archivedUser{Name: "Alice", Avatar: []byte(strings.Repeat("o", 8*1024))})
+	ns.MustPut("user:1", archivedUser{Name: "Alice", Avatar: shared})
+	ns.MustPut("user:1", archivedUser{Name: "Alice", Avatar: shared})
+	ns.MustDelete("user:2")
+
+	sum := sha256.Sum256(shared)
+	hash := hex.EncodeToString(sum[:])
+
+	refs, err := ns.BlobReferrers(hash)
+	if err != nil {
+		t.Fatalf("BlobReferrers failed: %v", err)
+	}
+	var got []string
+	for _, ref := range refs {
+		if !strings.HasPrefix(ref.Location, "_blobs/") {
+			t.Errorf("unexpected location %q", ref.Location)
+		}
+		got = append(got, ref.Key+"@"+strconv.Itoa(ref.Version))
+	}
+	if want := []string{"user:1@2", "user:1@3", "user:2@1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// A short hash matches too
+	if short, _ := ns.BlobReferrers(hash[:12]); len(short) != 3 {
+		t.Errorf("short hash matched %v", short)
+	}
+	if none, err := ns.BlobReferrers("ffffffffffff"); err != nil || len(none) != 0 {
+		t.Errorf("got %v, %v", none, err)
+	}
+	if _, err := ns.BlobReferrers(""); !errors.Is(err, stow.ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+}
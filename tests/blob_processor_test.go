@@ -0,0 +1,112 @@
+package stow_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestBlobProcessorDerivedArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	defer store.Close()
+
+	var seen []string
+	err := store.RegisterBlobProcessor("image/*", func(in stow.BlobInput) ([]stow.DerivedBlob, error) {
+		content, err := io.ReadAll(in.Content)
+		if err != nil {
+			return nil, err
+		}
+		seen = append(seen, in.Field)
+		return []stow.DerivedBlob{{Name: "thumbnail", Data: content[:16], MimeType: "image/jpeg"}}, nil
+	})
+	if err != nil {
+		t.Fatalf("RegisterBlobProcessor failed: %v", err)
+	}
+
+	ns := store.MustGetNamespace("media")
+
+	image := bytes.Repeat([]byte("p"), 8*1024)
+	ns.MustPut("photo", map[string]interface{}{"title": "sunset", "image": image}, stow.WithMimeType("image/png"))
+
+	if len(seen) != 1 || seen[0] != "image" {
+		t.Fatalf("processor saw fields %v", seen)
+	}
+
+	thumb, err := ns.GetDerived("photo", "image", "thumbnail")
+	if err != nil {
+		t.Fatalf("GetDerived failed: %v", err)
+	}
+	got, err := io.ReadAll(thumb)
+	thumb.Close()
+	if err != nil {
+		t.Fatalf("failed to read thumbnail: %v", err)
+	}
+	if !bytes.Equal(got, image[:16]) {
+		t.Errorf("thumbnail = %q", got)
+	}
+	if thumb.MimeType() != "image/jpeg" {
+		t.Errorf("thumbnail MIME type = %q", thumb.MimeType())
+	}
+
+	// Derived artifacts stay out of the decoded value
+	var result map[string]interface{}
+	ns.MustGet("photo", &result)
+	if _, ok := result["$derived"]; ok {
+		t.Error("Get should not expose $derived")
+	}
+
+	// ...but remain referenced, so GC keeps them
+	if _, err := ns.GC(); err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	thumb, err = ns.GetDerived("photo", "image", "thumbnail")
+	if err != nil {
+		t.Fatalf("GetDerived after GC failed: %v", err)
+	}
+	thumb.Close()
+
+	if _, err := ns.GetDerived("photo", "image", "text"); !errors.Is(err, stow.ErrNotFound) {
+		t.Errorf("missing artifact: expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestBlobProcessorMatching(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	if err := store.RegisterBlobProcessor("image/[", func(stow.BlobInput) ([]stow.DerivedBlob, error) {
+		return nil, nil
+	}); err == nil {
+		t.Error("expected error for malformed pattern")
+	}
+
+	calls := 0
+	store.RegisterBlobProcessor("text/*", func(stow.BlobInput) ([]stow.DerivedBlob, error) {
+		calls++
+		return nil, errors.New("extraction failed")
+	})
+
+	ns := store.MustGetNamespace("docs")
+	data := bytes.Repeat([]byte("x"), 8*1024)
+
+	// Non-matching MIME types are not processed
+	ns.MustPut("pdf", map[string]interface{}{"body": data}, stow.WithMimeType("application/pdf"))
+	if calls != 0 {
+		t.Errorf("processor called %d times for non-matching type", calls)
+	}
+
+	// A failing processor does not fail the Put
+	if err := ns.Put("txt", map[string]interface{}{"body": data}, stow.WithMimeType("text/plain")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("processor called %d times, expected 1", calls)
+	}
+	if _, err := ns.GetDerived("txt", "body", "text"); !errors.Is(err, stow.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
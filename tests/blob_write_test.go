@@ -0,0 +1,48 @@
+package stow_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+type blobUpload struct {
+	Name string
+	Data io.Reader
+}
+
+func TestBlobWriteConcurrency(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789"), 300*1024) // 3MB
+
+	for _, concurrency := range []int{0, 1, 4} {
+		store := stow.MustOpen(t.TempDir())
+
+		config := stow.DefaultNamespaceConfig()
+		config.BlobWriteConcurrency = concurrency
+		ns, err := store.CreateNamespace("uploads", config)
+		if err != nil {
+			t.Fatalf("CreateNamespace failed: %v", err)
+		}
+
+		ns.MustPut("file", blobUpload{Name: "big", Data: bytes.NewReader(payload)})
+
+		var result struct {
+			Name string
+			Data []byte
+		}
+		ns.MustGet("file", &result)
+		if !bytes.Equal(result.Data, payload) {
+			t.Errorf("concurrency %d: payload mismatch", concurrency)
+		}
+
+		store.Close()
+	}
+
+	config := stow.DefaultNamespaceConfig()
+	config.BlobWriteConcurrency = -1
+	if err := config.Validate(); err == nil {
+		t.Error("negative BlobWriteConcurrency should be invalid")
+	}
+}
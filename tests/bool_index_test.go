@@ -0,0 +1,139 @@
+package stow_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aigotowork/stow"
+)
+
+type blogPost struct {
+	Title     string `json:"title"`
+	Published bool   `json:"published"`
+	Flags     struct {
+		Archived bool `json:"archived"`
+	} `json:"flags"`
+}
+
+func TestKeysWhere(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.BoolIndexes = []string{"published", "flags.archived"}
+	ns, err := store.CreateNamespace("posts", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	for i := 1; i <= 4; i++ {
+		p := blogPost{Title: fmt.Sprintf("post %d", i), Published: i%2 == 1}
+		p.Flags.Archived = i == 3
+		ns.MustPut(fmt.Sprintf("post:%d", i), p)
+	}
+	ns.MustPut("note", map[string]interface{}{"published": "yes"})
+
+	check := func(conditions map[string]bool, want []string) {
+		t.Helper()
+		got, err := ns.KeysWhere(conditions)
+		if err != nil {
+			t.Fatalf("KeysWhere(%v) failed: %v", conditions, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("KeysWhere(%v) = %v, want %v", conditions, got, want)
+		}
+	}
+
+	check(map[string]bool{"published": true}, []string{"post:1", "post:3"})
+	check(map[string]bool{"published": false}, []string{"post:2", "post:4"})
+	check(map[string]bool{"published": true, "flags.archived": false}, []string{"post:1"})
+	check(map[string]bool{}, []string{"note", "post:1", "post:2", "post:3", "post:4"})
+
+	// Writes and deletes after the index is built
+	ns.MustPut("post:2", blogPost{Title: "post 2", Published: true})
+	if err := ns.Delete("post:1"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	check(map[string]bool{"published": true}, []string{"post:2", "post:3"})
+
+	// Scheduled records count once visible
+	if err := ns.Put("post:4", blogPost{Title: "post 4", Published: true}, stow.WithVisibleAt(time.Now().Add(300*time.Millisecond))); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	check(map[string]bool{"published": true}, []string{"post:2", "post:3"})
+	time.Sleep(400 * time.Millisecond)
+	check(map[string]bool{"published": true}, []string{"post:2", "post:3", "post:4"})
+
+	if _, err := ns.KeysWhere(map[string]bool{"title": true}); !errors.Is(err, stow.ErrNotIndexed) {
+		t.Errorf("expected ErrNotIndexed, got %v", err)
+	}
+}
+
+func TestKeysWhereConfigChange(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+
+	ns := store.MustGetNamespace("posts")
+	ns.MustPut("post:1", blogPost{Published: true})
+
+	if _, err := ns.KeysWhere(map[string]bool{"published": true}); !errors.Is(err, stow.ErrNotIndexed) {
+		t.Fatalf("expected ErrNotIndexed, got %v", err)
+	}
+
+	config := ns.GetConfig()
+	config.BoolIndexes = []string{"published"}
+	if err := ns.SetConfig(config); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+	keys, err := ns.KeysWhere(map[string]bool{"published": true})
+	if err != nil || len(keys) != 1 {
+		t.Fatalf("KeysWhere = %v, %v", keys, err)
+	}
+	store.Close()
+
+	// The field list is persisted, the index rebuilt
+	store = stow.MustOpen(dir)
+	defer store.Close()
+	keys, err = store.MustGetNamespace("posts").KeysWhere(map[string]bool{"published": true})
+	if err != nil || len(keys) != 1 {
+		t.Errorf("after reopen: KeysWhere = %v, %v", keys, err)
+	}
+}
+
+func TestKeysWhereConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.BoolIndexes = []string{"published"}
+	ns, err := store.CreateNamespace("posts", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				ns.MustPut(fmt.Sprintf("post:%d:%d", w, i), blogPost{Published: true})
+				if _, err := ns.KeysWhere(map[string]bool{"published": true}); err != nil {
+					t.Errorf("KeysWhere failed: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	keys, err := ns.KeysWhere(map[string]bool{"published": true})
+	if err != nil || len(keys) != 80 {
+		t.Errorf("KeysWhere returned %d keys, %v", len(keys), err)
+	}
+}
@@ -0,0 +1,112 @@
+package stow_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestRecordSeq(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	ns := store.MustGetNamespace("users")
+
+	ns.MustPut("user:1", map[string]interface{}{"name": "Alice"})
+	ns.MustPut("user:2", map[string]interface{}{"name": "Bob"})
+	ns.MustPut("user:1", map[string]interface{}{"name": "Alicia"})
+	store.Close()
+
+	// The sequence carries on across keys and reopens
+	store = stow.MustOpen(dir)
+	defer store.Close()
+	ns = store.MustGetNamespace("users")
+	ns.MustDelete("user:1")
+
+	history, err := ns.GetHistory("user:1")
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	var seqs []uint64
+	for _, version := range history {
+		seqs = append(seqs, version.Seq)
+	}
+	if fmt.Sprint(seqs) != "[4 3 1]" {
+		t.Errorf("user:1 seqs = %v, want [4 3 1]", seqs)
+	}
+}
+
+func TestClockSkewWarning(t *testing.T) {
+	logger := &recordingLogger{}
+	store := stow.MustOpen(t.TempDir(), stow.WithStoreLogger(logger))
+	defer store.Close()
+	ns := store.MustGetNamespace("events")
+
+	// A history written while the clock was an hour ahead
+	ahead := time.Now().Add(time.Hour).UTC().Format(time.RFC3339Nano)
+	src := filepath.Join(t.TempDir(), "event.jsonl")
+	line := `{"_meta":{"k":"event:1","v":1,"op":"put","ts":"` + ahead + `","seq":7},"data":{"n":1}}` + "\n"
+	if err := os.WriteFile(src, []byte(line), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ns.AdoptFile("event:1", src, stow.AdoptOptions{}); err != nil {
+		t.Fatalf("AdoptFile failed: %v", err)
+	}
+
+	ns.MustPut("event:2", map[string]interface{}{"n": 2})
+	ns.MustPut("event:2", map[string]interface{}{"n": 3})
+
+	const msg = "clock skew detected: record timestamp is behind records already written"
+	if n := logger.count(msg); n != 1 {
+		t.Errorf("logged %d clock skew warnings, want 1", n)
+	}
+
+	history, err := ns.GetHistory("event:2")
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if history[0].Seq != 9 || history[1].Seq != 8 {
+		t.Errorf("event:2 seqs = %d, %d, want 9 and 8", history[0].Seq, history[1].Seq)
+	}
+}
+
+func TestPruneClockSkew(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	ns := store.MustGetNamespace("events")
+
+	// Version 1 is recent; the clock then stepped back two hours
+	now := time.Now().UTC()
+	ts := func(d time.Duration) string { return now.Add(d).Format(time.RFC3339Nano) }
+	src := filepath.Join(t.TempDir(), "event.jsonl")
+	lines := `{"_meta":{"k":"event:1","v":1,"op":"put","ts":"` + ts(0) + `","seq":1},"data":{"n":1}}
+{"_meta":{"k":"event:1","v":2,"op":"put","ts":"` + ts(-2*time.Hour) + `","seq":2},"data":{"n":2}}
+{"_meta":{"k":"event:1","v":3,"op":"put","ts":"` + ts(-2*time.Hour+time.Second) + `","seq":3},"data":{"n":3}}
+`
+	if err := os.WriteFile(src, []byte(lines), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ns.AdoptFile("event:1", src, stow.AdoptOptions{Validate: true}); err != nil {
+		t.Fatalf("AdoptFile failed: %v", err)
+	}
+
+	result, err := ns.Prune(stow.PruneOptions{OlderThan: time.Hour, KeepAtLeast: 1})
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+
+	// Version 2 looks old but came after a recent version, so it stays
+	if result.RemovedVersions != 0 {
+		t.Errorf("RemovedVersions = %d, want 0", result.RemovedVersions)
+	}
+	history, err := ns.GetHistory("event:1")
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if len(history) != 3 {
+		t.Errorf("history has %d versions, want 3", len(history))
+	}
+}
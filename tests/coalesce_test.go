@@ -0,0 +1,188 @@
+package stow_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aigotowork/stow"
+)
+
+type draft struct {
+	Text string `json:"text"`
+}
+
+func openCoalesced(t *testing.T, window time.Duration) stow.Namespace {
+	t.Helper()
+
+	store := stow.MustOpen(t.TempDir())
+	t.Cleanup(func() { store.Close() })
+
+	config := stow.DefaultNamespaceConfig().WithCoalesce(window)
+	config.HashChain = true
+	ns, err := store.CreateNamespace("drafts", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+	return ns
+}
+
+func TestCoalesceBurst(t *testing.T) {
+	ns := openCoalesced(t, time.Minute)
+
+	for _, text := range []string{"H", "He", "Hel", "Hell", "Hello"} {
+		ns.MustPut("doc", draft{Text: text})
+	}
+
+	history, err := ns.GetHistory("doc")
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if len(history) != 1 || history[0].Version != 1 {
+		t.Fatalf("Expected a single version 1, got %+v", history)
+	}
+
+	var got draft
+	ns.MustGet("doc", &got)
+	if got.Text != "Hello" {
+		t.Errorf("Expected last write, got %q", got.Text)
+	}
+
+	if _, err := ns.VerifyChain("doc"); err != nil {
+		t.Errorf("VerifyChain failed: %v", err)
+	}
+}
+
+func TestCoalesceWindowElapsed(t *testing.T) {
+	ns := openCoalesced(t, 50*time.Millisecond)
+
+	ns.MustPut("doc", draft{Text: "first"})
+	time.Sleep(100 * time.Millisecond)
+	ns.MustPut("doc", draft{Text: "second"})
+	ns.MustPut("doc", draft{Text: "third"})
+
+	history, _ := ns.GetHistory("doc")
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 versions, got %d", len(history))
+	}
+
+	var v1, v2 draft
+	ns.GetVersion("doc", 1, &v1)
+	ns.GetVersion("doc", 2, &v2)
+	if v1.Text != "first" || v2.Text != "third" {
+		t.Errorf("Unexpected versions: %q, %q", v1.Text, v2.Text)
+	}
+
+	if _, err := ns.VerifyChain("doc"); err != nil {
+		t.Errorf("VerifyChain failed: %v", err)
+	}
+}
+
+func TestCoalesceWindowFromFirstWrite(t *testing.T) {
+	ns := openCoalesced(t, 100*time.Millisecond)
+
+	// Saves every 20ms never pause for a whole window, yet still roll over
+	start := time.Now()
+	for i := 0; time.Since(start) < 350*time.Millisecond; i++ {
+		ns.MustPut("doc", draft{Text: fmt.Sprintf("edit %d", i)})
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	history, _ := ns.GetHistory("doc")
+	if len(history) < 3 {
+		t.Fatalf("Expected a version per window, got %d", len(history))
+	}
+	if _, err := ns.VerifyChain("doc"); err != nil {
+		t.Errorf("VerifyChain failed: %v", err)
+	}
+}
+
+func TestCoalesceKeepsPinnedAndDeleted(t *testing.T) {
+	ns := openCoalesced(t, time.Minute)
+
+	ns.MustPut("doc", draft{Text: "release"})
+	if err := ns.PinVersion("doc", 1); err != nil {
+		t.Fatalf("PinVersion failed: %v", err)
+	}
+	ns.MustPut("doc", draft{Text: "edit"})
+
+	var pinned draft
+	if err := ns.GetVersion("doc", 1, &pinned); err != nil || pinned.Text != "release" {
+		t.Errorf("Pinned version replaced: %+v, %v", pinned, err)
+	}
+
+	ns.MustDelete("doc")
+	ns.MustPut("doc", draft{Text: "restored"})
+
+	history, _ := ns.GetHistory("doc")
+	if len(history) != 4 {
+		t.Errorf("Expected 4 versions, got %d", len(history))
+	}
+}
+
+func TestCoalesceRewritesOnlyLatest(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	defer store.Close()
+	ns, err := store.CreateNamespace("drafts", stow.DefaultNamespaceConfig().WithCoalesce(time.Minute))
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	ns.MustPut("doc", draft{Text: "first"})
+	if err := ns.PinVersion("doc", 1); err != nil {
+		t.Fatalf("PinVersion failed: %v", err)
+	}
+	ns.MustPut("doc", draft{Text: "second"})
+
+	filePath := filepath.Join(dir, "drafts", "doc.jsonl")
+	before, _ := os.ReadFile(filePath)
+	first := before[:bytes.IndexByte(before, '\n')+1]
+
+	ns.MustPut("doc", draft{Text: "third"})
+	after, _ := os.ReadFile(filePath)
+	if !bytes.HasPrefix(after, first) || bytes.Count(after, []byte("\n")) != 2 {
+		t.Errorf("Expected the first record untouched and one record replaced, got:\n%s", after)
+	}
+
+	var got draft
+	ns.MustGet("doc", &got)
+	if got.Text != "third" {
+		t.Errorf("Expected last write, got %q", got.Text)
+	}
+}
+
+func TestCoalesceHardCap(t *testing.T) {
+	store, err := stow.Open(t.TempDir(), stow.WithStoreHardCap(20000))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+	ns, err := store.CreateNamespace("drafts", stow.DefaultNamespaceConfig().WithCoalesce(time.Minute))
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	// Replaced blobs stay on disk until GC, so coalesced writes fill the store
+	var full error
+	for i := 0; i < 10 && full == nil; i++ {
+		full = ns.Put("doc", map[string]interface{}{"data": bytes.Repeat([]byte{byte('a' + i)}, 5000)}, stow.WithForceFile())
+	}
+	if !errors.Is(full, stow.ErrStoreFull) {
+		t.Fatalf("expected ErrStoreFull, got %v", full)
+	}
+	if history, _ := ns.GetHistory("doc"); len(history) != 1 {
+		t.Errorf("Expected a single version, got %d", len(history))
+	}
+}
+
+func TestCoalesceInvalidWindow(t *testing.T) {
+	config := stow.DefaultNamespaceConfig().WithCoalesce(-time.Second)
+	if err := config.Validate(); err == nil {
+		t.Error("Expected negative window to be invalid")
+	}
+}
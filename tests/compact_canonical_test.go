@@ -0,0 +1,81 @@
+package stow_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aigotowork/stow"
+)
+
+type canonicalItem struct {
+	Zeta  string `json:"zeta"`
+	Alpha int    `json:"alpha"`
+}
+
+func TestCompactCanonicalOutput(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := stow.MustOpen(tmpDir)
+	defer store.Close()
+
+	ns := store.MustGetNamespace("test")
+
+	for i := 0; i < 3; i++ {
+		ns.MustPut("doc", map[string]interface{}{
+			"items": []canonicalItem{{Zeta: "z", Alpha: i}},
+			"name":  "doc",
+		})
+	}
+
+	filePath := filepath.Join(tmpDir, "test", "doc.jsonl")
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+
+	// Shift a record's timestamp to another offset, as a hand edit might
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	last := lines[len(lines)-1]
+	start := strings.Index(last, `"ts":"`) + len(`"ts":"`)
+	end := start + strings.Index(last[start:], `"`)
+	ts, err := time.Parse(time.RFC3339Nano, last[start:end])
+	if err != nil {
+		t.Fatalf("Parse timestamp failed: %v", err)
+	}
+	shifted := ts.In(time.FixedZone("", 2*60*60)).Format(time.RFC3339Nano)
+	lines[len(lines)-1] = last[:start] + shifted + last[end:]
+	if err := os.WriteFile(filePath, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	ns.Refresh("doc")
+
+	if err := ns.Compact("doc"); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	first, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+
+	if bytes.Contains(first, []byte("+02:00")) {
+		t.Errorf("Timestamp offset not normalized:\n%s", first)
+	}
+	if !bytes.Contains(first, []byte(`{"alpha":2,"zeta":"z"}`)) {
+		t.Errorf("Struct fields not sorted:\n%s", first)
+	}
+
+	// Compacting again must not change a byte
+	if err := ns.Compact("doc"); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	second, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Errorf("Compaction not stable:\nfirst:\n%s\nsecond:\n%s", first, second)
+	}
+}
@@ -0,0 +1,85 @@
+package stow_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestCompactStore(t *testing.T) {
+	dir := t.TempDir()
+
+	store := stow.MustOpen(dir)
+	config := stow.DefaultNamespaceConfig()
+	config.AutoCompact = false
+	for _, name := range []string{"users", "files"} {
+		ns, err := store.CreateNamespace(name, config)
+		if err != nil {
+			t.Fatalf("CreateNamespace failed: %v", err)
+		}
+		for i := 0; i < 10; i++ {
+			ns.MustPut("item", map[string]interface{}{"n": i})
+		}
+	}
+
+	// A blob only an old version references becomes garbage
+	files := store.MustGetNamespace("files")
+	for i := 0; i < 5; i++ {
+		files.MustPut("doc", map[string]interface{}{"body": []byte(strings.Repeat(string(rune('a'+i)), 8*1024))})
+	}
+	store.Close()
+
+	result, err := stow.CompactStore(dir)
+	if err != nil {
+		t.Fatalf("CompactStore failed: %v", err)
+	}
+	if result.Namespaces != 2 {
+		t.Errorf("Expected 2 namespaces, got %d", result.Namespaces)
+	}
+	if result.GC.RemovedBlobs == 0 {
+		t.Errorf("Expected blobs to be removed, got %+v", result.GC)
+	}
+
+	store = stow.MustOpen(dir)
+	defer store.Close()
+	for _, name := range []string{"users", "files"} {
+		history, err := store.MustGetNamespace(name).GetHistory("item")
+		if err != nil {
+			t.Fatalf("GetHistory failed: %v", err)
+		}
+		if len(history) != config.CompactKeepRecords {
+			t.Errorf("%s: expected %d versions, got %d", name, config.CompactKeepRecords, len(history))
+		}
+	}
+
+	var doc map[string]interface{}
+	if err := store.MustGetNamespace("files").Get("doc", &doc); err != nil {
+		t.Errorf("Latest blob lost: %v", err)
+	}
+}
+
+func TestCompactStoreLocked(t *testing.T) {
+	dir := t.TempDir()
+
+	store := stow.MustOpen(dir)
+	defer store.Close()
+
+	if _, err := stow.CompactStore(dir); !errors.Is(err, stow.ErrStoreLocked) {
+		t.Errorf("Expected ErrStoreLocked, got %v", err)
+	}
+}
+
+func TestCompactStoreMissing(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "missing")
+
+	if _, err := stow.CompactStore(dir); err == nil {
+		t.Error("Expected an error for a missing store")
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Error("CompactStore created the store")
+	}
+}
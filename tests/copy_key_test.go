@@ -0,0 +1,114 @@
+package stow_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+type promoted struct {
+	Title string         `json:"title"`
+	Image stow.IFileData `json:"image"`
+}
+
+func TestCopyKey(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	staging := store.MustGetNamespace("staging")
+	image := bytes.Repeat([]byte("i"), 8*1024)
+	staging.MustPut("banner", map[string]interface{}{"title": "v1", "image": image})
+	staging.MustPut("banner", map[string]interface{}{"title": "v2", "image": image})
+
+	if err := store.CopyKey("staging", "banner", "production", ""); err != nil {
+		t.Fatalf("CopyKey failed: %v", err)
+	}
+
+	production := store.MustGetNamespace("production")
+	var got promoted
+	production.MustGet("banner", &got)
+	if got.Title != "v2" {
+		t.Errorf("Expected latest value, got %q", got.Title)
+	}
+	content, err := io.ReadAll(got.Image)
+	got.Image.Close()
+	if err != nil || !bytes.Equal(content, image) {
+		t.Errorf("Blob not copied: %d bytes, %v", len(content), err)
+	}
+	if history, _ := production.GetHistory("banner"); len(history) != 2 {
+		t.Errorf("Expected full history, got %d versions", len(history))
+	}
+
+	// The source is untouched, and the copy survives GC of the source
+	staging.MustDelete("banner")
+	staging.Compact("banner")
+	if _, err := staging.GC(); err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	production.MustGet("banner", &got)
+	content, _ = io.ReadAll(got.Image)
+	got.Image.Close()
+	if !bytes.Equal(content, image) {
+		t.Error("Copied blob should live in the destination namespace")
+	}
+}
+
+func TestCopyKeyLatestOnly(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	ns := store.MustGetNamespace("staging")
+	ns.MustPut("config", draft{Text: "old"})
+	ns.MustPut("config", draft{Text: "new"})
+
+	if err := store.CopyKey("staging", "config", "staging", "config:release", stow.WithCopyLatestOnly()); err != nil {
+		t.Fatalf("CopyKey failed: %v", err)
+	}
+
+	history, err := ns.GetHistory("config:release")
+	if err != nil || len(history) != 1 {
+		t.Fatalf("Expected a single version, got %d (%v)", len(history), err)
+	}
+	var got draft
+	ns.MustGet("config:release", &got)
+	if got.Text != "new" {
+		t.Errorf("Expected latest value, got %q", got.Text)
+	}
+
+	// Existing keys need WithCopyReplace
+	ns.MustPut("config", draft{Text: "newer"})
+	err = store.CopyKey("staging", "config", "staging", "config:release", stow.WithCopyLatestOnly())
+	if !errors.Is(err, stow.ErrKeyConflict) {
+		t.Errorf("Expected ErrKeyConflict, got %v", err)
+	}
+	err = store.CopyKey("staging", "config", "staging", "config:release", stow.WithCopyLatestOnly(), stow.WithCopyReplace())
+	if err != nil {
+		t.Fatalf("CopyKey with replace failed: %v", err)
+	}
+	ns.MustGet("config:release", &got)
+	if got.Text != "newer" {
+		t.Errorf("Expected replaced value, got %q", got.Text)
+	}
+}
+
+func TestCopyKeyErrors(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	if err := store.CopyKey("missing", "k", "dst", ""); !errors.Is(err, stow.ErrNamespaceNotFound) {
+		t.Errorf("Expected ErrNamespaceNotFound, got %v", err)
+	}
+
+	ns := store.MustGetNamespace("src")
+	ns.MustPut("gone", draft{Text: "x"})
+	ns.MustDelete("gone")
+	if err := store.CopyKey("src", "nope", "dst", ""); !errors.Is(err, stow.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if err := store.CopyKey("src", "gone", "dst", "", stow.WithCopyLatestOnly()); !errors.Is(err, stow.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a deleted key, got %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+package stow_test
+
+import (
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestDeleteReason(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	ns := store.MustGetNamespace("users")
+	ns.MustPut("alice", map[string]interface{}{"name": "Alice"})
+	ns.MustDelete("alice", stow.WithReason("user requested erasure"))
+	ns.MustPut("alice", map[string]interface{}{"name": "Alice"})
+	ns.MustDelete("alice")
+
+	history, err := ns.GetHistory("alice")
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if len(history) != 4 {
+		t.Fatalf("history has %d versions, want 4", len(history))
+	}
+
+	// Newest first
+	want := []struct {
+		op     string
+		reason string
+	}{
+		{"delete", ""},
+		{"put", ""},
+		{"delete", "user requested erasure"},
+		{"put", ""},
+	}
+	for i, w := range want {
+		if history[i].Operation != w.op || history[i].Reason != w.reason {
+			t.Errorf("version %d: %s %q, want %s %q",
+				history[i].Version, history[i].Operation, history[i].Reason, w.op, w.reason)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package stow_test
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestDiskUsageWatermarks(t *testing.T) {
+	var mu sync.Mutex
+	var events []stow.DiskUsage
+
+	store, err := stow.Open(t.TempDir(),
+		stow.WithStoreDiskBudget(20*1024, 0.5, 0.9),
+		stow.WithStoreOnDiskUsage(func(u stow.DiskUsage) {
+			mu.Lock()
+			events = append(events, u)
+			mu.Unlock()
+		}))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.AutoCompact = false
+	ns, err := store.CreateNamespace("data", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	payload := strings.Repeat("x", 1024)
+	for i := 0; i < 25; i++ {
+		ns.MustPut("key", map[string]interface{}{"payload": payload, "i": i})
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 watermark events, got %d: %+v", len(events), events)
+	}
+	if events[0].Level != 0.5 || events[1].Level != 0.9 {
+		t.Errorf("unexpected levels: %+v", events)
+	}
+	if events[1].Used < 18*1024 || events[1].Budget != 20*1024 {
+		t.Errorf("unexpected usage in event: %+v", events[1])
+	}
+}
+
+func TestDiskUsageHardCap(t *testing.T) {
+	store, err := stow.Open(t.TempDir(), stow.WithStoreHardCap(8*1024))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.AutoCompact = false
+	config.CompactKeepRecords = 1
+	ns, err := store.CreateNamespace("data", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	payload := strings.Repeat("x", 1024)
+
+	var full error
+	for i := 0; i < 20 && full == nil; i++ {
+		full = ns.Put("key", map[string]interface{}{"payload": payload, "i": i})
+	}
+	if !errors.Is(full, stow.ErrStoreFull) {
+		t.Fatalf("expected ErrStoreFull, got %v", full)
+	}
+
+	// Compaction frees space and writes are accepted again
+	if err := ns.Compact("key"); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if err := ns.Put("key", map[string]interface{}{"payload": payload}); err != nil {
+		t.Errorf("Put after compaction failed: %v", err)
+	}
+}
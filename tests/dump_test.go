@@ -0,0 +1,137 @@
+package stow_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+type ticket struct {
+	Title      string `json:"title"`
+	Status     string `json:"status"`
+	Screenshot []byte `json:"screenshot"`
+}
+
+func TestDumpAndLoadKey(t *testing.T) {
+	source := stow.MustOpen(t.TempDir())
+	defer source.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.AutoCompact = false
+	// Small inline limit so later versions are spilled into JSON blobs
+	config.MaxInlineRecordSize = 4096
+	config.OversizePolicy = stow.OversizeAutoBlob
+	src, err := source.CreateNamespace("tickets", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	screenshot := bytes.Repeat([]byte("pixel"), 4096)
+	src.MustPut("ticket:42", ticket{Title: "crash on save", Status: "open", Screenshot: screenshot})
+	src.MustPut("ticket:42", ticket{Title: "crash on save", Status: "triaged", Screenshot: screenshot})
+	src.MustPut("ticket:42", map[string]interface{}{
+		"title":  "crash on save",
+		"status": "closed",
+		"log":    string(bytes.Repeat([]byte("x"), 8192)),
+	})
+	if err := src.PinVersion("ticket:42", 1); err != nil {
+		t.Fatalf("PinVersion failed: %v", err)
+	}
+
+	var dump bytes.Buffer
+	if err := src.DumpKey("ticket:42", &dump); err != nil {
+		t.Fatalf("DumpKey failed: %v", err)
+	}
+
+	// Load into an encrypted namespace of another store
+	target := stow.MustOpen(t.TempDir())
+	defer target.Close()
+
+	dst, err := target.CreateNamespace("support", stow.DefaultNamespaceConfig().WithKey(bytes.Repeat([]byte{7}, 32)))
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	key, err := dst.LoadKey(bytes.NewReader(dump.Bytes()))
+	if err != nil {
+		t.Fatalf("LoadKey failed: %v", err)
+	}
+	if key != "ticket:42" {
+		t.Errorf("loaded key = %q", key)
+	}
+
+	history, err := dst.GetHistory("ticket:42")
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("history has %d versions, want 3", len(history))
+	}
+
+	var first ticket
+	if err := dst.GetVersion("ticket:42", 1, &first); err != nil {
+		t.Fatalf("GetVersion failed: %v", err)
+	}
+	if first.Status != "open" || !bytes.Equal(first.Screenshot, screenshot) {
+		t.Errorf("version 1 = %q, %d screenshot bytes", first.Status, len(first.Screenshot))
+	}
+
+	var latest map[string]interface{}
+	dst.MustGet("ticket:42", &latest)
+	if latest["status"] != "closed" || len(latest["log"].(string)) != 8192 {
+		t.Errorf("latest status = %v", latest["status"])
+	}
+
+	if pins, _ := dst.Pins("ticket:42"); len(pins) != 1 || pins[0] != 1 {
+		t.Errorf("pins = %v, want [1]", pins)
+	}
+
+	// Loaded blobs are referenced, so GC keeps them
+	if _, err := dst.GC(); err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	dst.MustGet("ticket:42", &latest)
+
+	t.Run("existing key", func(t *testing.T) {
+		if _, err := dst.LoadKey(bytes.NewReader(dump.Bytes())); !errors.Is(err, stow.ErrKeyConflict) {
+			t.Errorf("expected ErrKeyConflict, got %v", err)
+		}
+		if _, err := dst.LoadKey(bytes.NewReader(dump.Bytes()), stow.WithLoadReplace()); err != nil {
+			t.Errorf("LoadKey with replace failed: %v", err)
+		}
+	})
+
+	t.Run("renamed", func(t *testing.T) {
+		key, err := dst.LoadKey(bytes.NewReader(dump.Bytes()), stow.WithLoadAs("ticket:42-copy"))
+		if err != nil {
+			t.Fatalf("LoadKey failed: %v", err)
+		}
+		if key != "ticket:42-copy" {
+			t.Errorf("loaded key = %q", key)
+		}
+
+		var copied ticket
+		if err := dst.GetVersion(key, 2, &copied); err != nil {
+			t.Fatalf("GetVersion failed: %v", err)
+		}
+		if copied.Status != "triaged" {
+			t.Errorf("copied status = %q", copied.Status)
+		}
+	})
+}
+
+func TestDumpKeyErrors(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	ns := store.MustGetNamespace("tickets")
+	if err := ns.DumpKey("missing", &bytes.Buffer{}); !errors.Is(err, stow.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if _, err := ns.LoadKey(bytes.NewReader([]byte("not a dump"))); !errors.Is(err, stow.ErrCorruptedData) {
+		t.Errorf("expected ErrCorruptedData, got %v", err)
+	}
+}
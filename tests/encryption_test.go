@@ -0,0 +1,129 @@
+package stow_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestNamespaceEncryption(t *testing.T) {
+	dir := t.TempDir()
+	keyA := bytes.Repeat([]byte{0xA}, 32)
+	keyB := bytes.Repeat([]byte{0xB}, 32)
+
+	store := stow.MustOpen(dir)
+
+	tenantA, err := store.CreateNamespace("tenant-a", stow.DefaultNamespaceConfig().WithKey(keyA))
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+	tenantB, err := store.CreateNamespace("tenant-b", stow.DefaultNamespaceConfig().WithKey(keyB))
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+	plain := store.MustGetNamespace("shared")
+
+	secret := bytes.Repeat([]byte("confidential "), 1024)
+	tenantA.MustPut("doc", map[string]interface{}{"title": "quarterly report", "body": secret})
+	tenantB.MustPut("doc", map[string]interface{}{"title": "tenant b"})
+	plain.MustPut("doc", map[string]interface{}{"title": "public"})
+	store.Close()
+
+	// Neither records nor blobs hold plaintext on disk
+	filepath.Walk(filepath.Join(dir, "tenant-a"), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		content, _ := os.ReadFile(path)
+		if bytes.Contains(content, []byte("quarterly")) || bytes.Contains(content, []byte("confidential")) {
+			t.Errorf("%s contains plaintext", path)
+		}
+		return nil
+	})
+
+	// Reopen with only tenant A's key
+	store = stow.MustOpen(dir, stow.WithStoreNamespaceKey("tenant-a", keyA))
+	defer store.Close()
+
+	ns, err := store.GetNamespace("tenant-a")
+	if err != nil {
+		t.Fatalf("GetNamespace with key failed: %v", err)
+	}
+	var doc struct {
+		Title string `json:"title"`
+		Body  []byte `json:"body"`
+	}
+	ns.MustGet("doc", &doc)
+	if doc.Title != "quarterly report" || !bytes.Equal(doc.Body, secret) {
+		t.Errorf("decrypted doc mismatch: title=%q body=%d bytes", doc.Title, len(doc.Body))
+	}
+
+	// Tenant B is unavailable without its key; the rest of the store works
+	if _, err := store.GetNamespace("tenant-b"); !errors.Is(err, stow.ErrNamespaceUnavailable) {
+		t.Errorf("tenant-b without key: expected ErrNamespaceUnavailable, got %v", err)
+	}
+	store.MustGetNamespace("shared").MustGet("doc", &doc)
+	if doc.Title != "public" {
+		t.Errorf("shared doc title = %q", doc.Title)
+	}
+
+	// Compaction and GC keep working on sealed records and blobs
+	ns.MustPut("doc", map[string]interface{}{"title": "v2", "body": secret})
+	if err := ns.CompactAll(); err != nil {
+		t.Fatalf("CompactAll failed: %v", err)
+	}
+	if _, err := ns.GC(); err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	ns.MustGet("doc", &doc)
+	if doc.Title != "v2" || !bytes.Equal(doc.Body, secret) {
+		t.Error("doc mismatch after compaction and GC")
+	}
+}
+
+func TestNamespaceEncryptionWrongKey(t *testing.T) {
+	dir := t.TempDir()
+
+	store := stow.MustOpen(dir)
+	ns, err := store.CreateNamespace("tenant", stow.DefaultNamespaceConfig().WithKey(bytes.Repeat([]byte{1}, 32)))
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+	ns.MustPut("k", map[string]interface{}{"v": 1})
+	store.Close()
+
+	// A destroyed (here: replaced) key leaves the data unreadable
+	store = stow.MustOpen(dir, stow.WithStoreNamespaceKey("tenant", bytes.Repeat([]byte{2}, 32)))
+	defer store.Close()
+
+	if _, err := store.GetNamespace("tenant"); !errors.Is(err, stow.ErrNamespaceUnavailable) {
+		t.Errorf("wrong key: expected ErrNamespaceUnavailable, got %v", err)
+	}
+}
+
+func TestNamespaceEncryptionInvalidKey(t *testing.T) {
+	dir := t.TempDir()
+	key := bytes.Repeat([]byte{1}, 32)
+
+	store := stow.MustOpen(dir)
+
+	_, err := store.CreateNamespace("tenant", stow.DefaultNamespaceConfig().WithKey([]byte("short")))
+	if !errors.Is(err, stow.ErrInvalidConfig) {
+		t.Errorf("short key: expected ErrInvalidConfig, got %v", err)
+	}
+
+	// Existing plain namespaces can't be encrypted in place
+	store.MustGetNamespace("plain").MustPut("k", map[string]interface{}{"v": 1})
+	store.Close()
+
+	reopened := stow.MustOpen(dir, stow.WithStoreNamespaceKey("plain", key))
+	defer reopened.Close()
+
+	if _, err := reopened.GetNamespace("plain"); !errors.Is(err, stow.ErrInvalidConfig) {
+		t.Errorf("encrypting plain namespace: expected ErrInvalidConfig, got %v", err)
+	}
+}
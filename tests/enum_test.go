@@ -0,0 +1,77 @@
+package stow_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+type postStatus string
+
+const (
+	statusDraft     postStatus = "draft"
+	statusPublished postStatus = "published"
+)
+
+func (postStatus) EnumValues() []string {
+	return []string{string(statusDraft), string(statusPublished), "archived"}
+}
+
+type post struct {
+	Title    string     `json:"title"`
+	Status   postStatus `json:"status"`
+	Priority string     `json:"priority" stow:"enum:low|high"`
+}
+
+var _ stow.Enum = postStatus("")
+
+func TestEnumFields(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	ns := store.MustGetNamespace("posts")
+
+	if err := ns.Put("post:1", post{Title: "Hello", Status: statusPublished, Priority: "high"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	var got post
+	if err := ns.Get("post:1", &got); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.Status != statusPublished || got.Priority != "high" {
+		t.Errorf("got %+v", got)
+	}
+
+	err := ns.Put("post:1", post{Title: "Hello", Status: "deleted", Priority: "high"})
+	if !errors.Is(err, stow.ErrInvalidEnum) {
+		t.Errorf("Put with unknown status = %v, want ErrInvalidEnum", err)
+	}
+	err = ns.Put("post:1", post{Title: "Hello", Status: statusDraft, Priority: "urgent"})
+	if !errors.Is(err, stow.ErrInvalidEnum) {
+		t.Errorf("Put with unknown priority = %v, want ErrInvalidEnum", err)
+	}
+
+	// The rejected Puts left the stored value alone
+	if err := ns.Get("post:1", &got); err != nil || got.Status != statusPublished {
+		t.Errorf("Get after rejected Put = %+v, %v", got, err)
+	}
+
+	// Values written without the type are checked when decoding into it
+	ns.MustPut("post:2", map[string]interface{}{"title": "Raw", "status": "deleted", "priority": "low"})
+	if err := ns.Get("post:2", &got); !errors.Is(err, stow.ErrInvalidEnum) {
+		t.Errorf("Get of unknown status = %v, want ErrInvalidEnum", err)
+	}
+}
+
+func TestValidateModelEnum(t *testing.T) {
+	type bad struct {
+		Level int `stow:"enum:low|high"`
+	}
+	if err := stow.ValidateModel[bad](); !errors.Is(err, stow.ErrInvalidTag) {
+		t.Errorf("ValidateModel() = %v, want ErrInvalidTag", err)
+	}
+	if err := stow.ValidateModel[post](); err != nil {
+		t.Errorf("ValidateModel() = %v, want nil", err)
+	}
+}
@@ -0,0 +1,78 @@
+package stow_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestGetFallbackToHistory(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.DisableCache = true
+	config.BlobThreshold = 1 << 20
+	config.MaxLineBytes = 2048
+	ns, err := store.CreateNamespace("docs", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	// An oversized latest version
+	ns.MustPut("doc", draft{Text: "v1"})
+	ns.MustPut("doc", draft{Text: strings.Repeat("y", 8192)})
+
+	var got draft
+	if err := ns.Get("doc", &got); !errors.Is(err, stow.ErrLimitExceeded) {
+		t.Fatalf("Get: expected ErrLimitExceeded, got %v", err)
+	}
+
+	err = ns.Get("doc", &got, stow.WithFallbackToHistory())
+	var degraded *stow.DegradedReadError
+	if !errors.As(err, &degraded) || !errors.Is(err, stow.ErrDegradedRead) {
+		t.Fatalf("expected DegradedReadError, got %v", err)
+	}
+	if got.Text != "v1" || degraded.Version != 1 || degraded.Skipped != 1 || !errors.Is(degraded.Err, stow.ErrLimitExceeded) {
+		t.Errorf("got %+v with %+v", got, degraded)
+	}
+
+	// A torn last line
+	ns.MustPut("note", draft{Text: "v1"})
+	ns.MustPut("note", draft{Text: "v2"})
+	files, _ := filepath.Glob(filepath.Join(dir, "docs", "note*.jsonl"))
+	if len(files) != 1 {
+		t.Fatalf("expected one key file, got %v", files)
+	}
+	f, err := os.OpenFile(files[0], os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"_meta":{"k":"note","v":3,"op":"put"`)
+	f.Close()
+
+	got = draft{}
+	err = ns.Get("note", &got, stow.WithFallbackToHistory())
+	if !errors.As(err, &degraded) || got.Text != "v2" || degraded.Version != 2 {
+		t.Errorf("got %+v, %v", got, err)
+	}
+
+	// Readable keys return no error
+	ns.MustPut("clean", draft{Text: "ok"})
+	if err := ns.Get("clean", &got, stow.WithFallbackToHistory()); err != nil || got.Text != "ok" {
+		t.Errorf("got %+v, %v", got, err)
+	}
+
+	// Deleted keys stay deleted
+	if err := ns.Delete("clean"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := ns.Get("clean", &got, stow.WithFallbackToHistory()); !errors.Is(err, stow.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package stow_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aigotowork/stow"
+)
+
+type indexedUser struct {
+	Name  string `json:"name"`
+	Email string `json:"email" stow:"index"`
+	Age   int    `json:"age" stow:"index"`
+}
+
+func keysByIndex(t *testing.T, ns stow.Namespace, field string, value interface{}) []string {
+	t.Helper()
+
+	keys, err := ns.KeysByIndex(field, value)
+	if err != nil {
+		t.Fatalf("KeysByIndex(%s, %v) failed: %v", field, value, err)
+	}
+	return keys
+}
+
+func TestKeysByIndex(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	ns := store.MustGetNamespace("users")
+
+	// Keys written before the index existed are indexed when it is created
+	ns.MustPut("carol", map[string]interface{}{"name": "Carol", "email": "carol@example.com", "age": 45})
+
+	ns.MustPut("alice", indexedUser{Name: "Alice", Email: "alice@example.com", Age: 31})
+	ns.MustPut("bob", indexedUser{Name: "Bob", Email: "bob@example.com", Age: 31})
+
+	if _, err := os.Stat(filepath.Join(dir, "users", "_indexes", "email.jsonl")); err != nil {
+		t.Fatalf("Expected an index file: %v", err)
+	}
+
+	if got := keysByIndex(t, ns, "email", "carol@example.com"); !reflect.DeepEqual(got, []string{"carol"}) {
+		t.Errorf("KeysByIndex(email) = %v, want [carol]", got)
+	}
+	if got := keysByIndex(t, ns, "age", 31.0); !reflect.DeepEqual(got, []string{"alice", "bob"}) {
+		t.Errorf("KeysByIndex(age) = %v, want [alice bob]", got)
+	}
+
+	// Updates and deletes move keys out of their old values
+	ns.MustPut("alice", indexedUser{Name: "Alice", Email: "alice@example.org", Age: 32})
+	ns.MustDelete("bob")
+	if got := keysByIndex(t, ns, "email", "alice@example.com"); len(got) != 0 {
+		t.Errorf("Expected the old email unindexed, got %v", got)
+	}
+	if got := keysByIndex(t, ns, "age", 31); len(got) != 0 {
+		t.Errorf("Expected bob unindexed, got %v", got)
+	}
+
+	if _, err := ns.KeysByIndex("name", "Alice"); !errors.Is(err, stow.ErrNotIndexed) {
+		t.Errorf("Expected ErrNotIndexed, got %v", err)
+	}
+	if _, err := ns.KeysByIndex("email", []string{"a"}); err == nil {
+		t.Error("Expected an error for a non-scalar value")
+	}
+	store.Close()
+
+	// Indexes are read back from disk
+	store = stow.MustOpen(dir)
+	defer store.Close()
+	ns = store.MustGetNamespace("users")
+	if got := keysByIndex(t, ns, "email", "alice@example.org"); !reflect.DeepEqual(got, []string{"alice"}) {
+		t.Errorf("KeysByIndex(email) after reopen = %v, want [alice]", got)
+	}
+	if keys, _ := ns.List(); len(keys) != 2 {
+		t.Errorf("Expected index files not listed as keys, got %v", keys)
+	}
+}
+
+func TestKeysByIndexScheduled(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	ns := store.MustGetNamespace("users")
+
+	ns.MustPut("alice", indexedUser{Name: "Alice", Email: "alice@example.com", Age: 31})
+	activation := time.Now().Add(200 * time.Millisecond)
+	ns.MustPut("alice", indexedUser{Name: "Alice", Email: "alice@example.org", Age: 32}, stow.WithVisibleAt(activation))
+	ns.MustPut("bob", indexedUser{Name: "Bob", Email: "bob@example.com", Age: 40}, stow.WithVisibleAt(activation))
+
+	// Lookups match what Get returns until the scheduled records are visible
+	if got := keysByIndex(t, ns, "email", "alice@example.com"); !reflect.DeepEqual(got, []string{"alice"}) {
+		t.Errorf("before activation KeysByIndex(email) = %v, want [alice]", got)
+	}
+	if got := keysByIndex(t, ns, "age", 40); len(got) != 0 {
+		t.Errorf("before activation KeysByIndex(age) = %v, want none", got)
+	}
+
+	// Also for a handle opened after the writes
+	store.Close()
+	store = stow.MustOpen(dir)
+	defer store.Close()
+	ns = store.MustGetNamespace("users")
+
+	time.Sleep(time.Until(activation) + 10*time.Millisecond)
+	if got := keysByIndex(t, ns, "email", "alice@example.org"); !reflect.DeepEqual(got, []string{"alice"}) {
+		t.Errorf("after activation KeysByIndex(email) = %v, want [alice]", got)
+	}
+	if got := keysByIndex(t, ns, "age", 40); !reflect.DeepEqual(got, []string{"bob"}) {
+		t.Errorf("after activation KeysByIndex(age) = %v, want [bob]", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "users", "_indexes", "_pending.json")); !os.IsNotExist(err) {
+		t.Errorf("Expected no pending keys left, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package stow_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aigotowork/stow"
+)
+
+type download struct {
+	Name string
+	File stow.IFileData
+}
+
+func TestGCDefersOpenBlobs(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	ns := store.MustGetNamespace("files")
+
+	content := bytes.Repeat([]byte("stream"), 4096)
+	ns.MustPut("iso", map[string]interface{}{"Name": "disk", "File": content})
+
+	var d download
+	ns.MustGet("iso", &d)
+	path := d.File.Path()
+
+	// Start the download, then drop the key and collect
+	buf := make([]byte, 1024)
+	if _, err := io.ReadFull(d.File, buf); err != nil {
+		t.Fatal(err)
+	}
+	ns.MustDelete("iso")
+	result, err := ns.GC()
+	if err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	if result.RemovedBlobs != 0 || result.DeferredBlobs != 1 {
+		t.Errorf("got %+v, want one deferred blob", result)
+	}
+
+	rest, err := io.ReadAll(d.File)
+	if err != nil || len(buf)+len(rest) != len(content) {
+		t.Fatalf("download broke after %d bytes: %v", len(buf)+len(rest), err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected the blob to survive while open, got %v", err)
+	}
+
+	// Closing the last reader removes it
+	d.File.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the blob to be removed on close, got %v", err)
+	}
+}
+
+func TestGCIgnoresIdleReaders(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.BlobReadLease = 20 * time.Millisecond
+	ns, err := store.CreateNamespace("files", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	ns.MustPut("iso", map[string]interface{}{"Name": "disk", "File": bytes.Repeat([]byte("x"), 8192)})
+	var d download
+	ns.MustGet("iso", &d)
+	defer d.File.Close()
+
+	// A handle left open and unread doesn't hold the blob forever
+	time.Sleep(50 * time.Millisecond)
+	ns.MustDelete("iso")
+	result, err := ns.GC()
+	if err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	if result.RemovedBlobs != 1 || result.DeferredBlobs != 0 {
+		t.Errorf("got %+v, want one removed blob", result)
+	}
+}
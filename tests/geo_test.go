@@ -0,0 +1,83 @@
+package stow_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+type geoPlace struct {
+	Name     string        `json:"name"`
+	Location stow.GeoPoint `json:"location"`
+}
+
+func TestGeoPointRoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := stow.MustOpen(tmpDir)
+	defer store.Close()
+
+	ns := store.MustGetNamespace("places")
+
+	original := geoPlace{
+		Name:     "Brandenburg Gate",
+		Location: stow.GeoPoint{Lat: 52.516275, Lng: 13.377704},
+	}
+	ns.MustPut("gate", original)
+
+	// Read from disk, not cache
+	ns.RefreshAll()
+
+	var result geoPlace
+	ns.MustGet("gate", &result)
+
+	if result.Location != original.Location {
+		t.Errorf("location mismatch: got %+v, want %+v", result.Location, original.Location)
+	}
+}
+
+func TestGeoPointDistance(t *testing.T) {
+	berlin := stow.GeoPoint{Lat: 52.5200, Lng: 13.4050}
+	paris := stow.GeoPoint{Lat: 48.8566, Lng: 2.3522}
+
+	// Berlin-Paris is roughly 878 km
+	distance := berlin.DistanceKm(paris)
+	if math.Abs(distance-878) > 5 {
+		t.Errorf("unexpected distance: %.1f km", distance)
+	}
+
+	if berlin.DistanceKm(berlin) != 0 {
+		t.Error("distance to self should be zero")
+	}
+
+	if !berlin.WithinKm(paris, 900) {
+		t.Error("Paris should be within 900 km of Berlin")
+	}
+	if berlin.WithinKm(paris, 500) {
+		t.Error("Paris should not be within 500 km of Berlin")
+	}
+}
+
+func TestGeoPointGeohash(t *testing.T) {
+	// Reference value from the geohash specification
+	p := stow.GeoPoint{Lat: 42.605, Lng: -5.603}
+	if got := p.Geohash(5); got != "ezs42" {
+		t.Errorf("Geohash(5) = %q, want %q", got, "ezs42")
+	}
+
+	if got := p.Geohash(0); len(got) != 1 {
+		t.Errorf("precision should be clamped to 1, got %q", got)
+	}
+}
+
+func TestGeoPointValid(t *testing.T) {
+	if !(stow.GeoPoint{Lat: 0, Lng: 0}).Valid() {
+		t.Error("origin should be valid")
+	}
+	if (stow.GeoPoint{Lat: 91, Lng: 0}).Valid() {
+		t.Error("latitude 91 should be invalid")
+	}
+	if (stow.GeoPoint{Lat: 0, Lng: -181}).Valid() {
+		t.Error("longitude -181 should be invalid")
+	}
+}
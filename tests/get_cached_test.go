@@ -0,0 +1,185 @@
+package stow_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aigotowork/stow"
+)
+
+type cachedProfile struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func TestGetCachedExistingKey(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	ns := store.MustGetNamespace("profiles")
+
+	ns.MustPut("alice", cachedProfile{Name: "Alice", Age: 30})
+
+	var calls atomic.Int32
+	loader := stow.WithLoader(func() (interface{}, error) {
+		calls.Add(1)
+		return cachedProfile{Name: "Other"}, nil
+	})
+
+	var got cachedProfile
+	if err := ns.GetCached("alice", &got, loader); err != nil {
+		t.Fatalf("GetCached failed: %v", err)
+	}
+	if got.Name != "Alice" || got.Age != 30 {
+		t.Errorf("Expected Alice, got %+v", got)
+	}
+	if calls.Load() != 0 {
+		t.Errorf("Loader called for an existing key")
+	}
+
+	// Without a loader, missing keys are not found
+	if err := ns.GetCached("bob", &got); !errors.Is(err, stow.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestGetCachedLoaderSingleflight(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	ns := store.MustGetNamespace("profiles")
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	loader := stow.WithLoader(func() (interface{}, error) {
+		calls.Add(1)
+		<-release
+		return cachedProfile{Name: "Bob", Age: 41}, nil
+	})
+
+	const readers = 20
+	var wg sync.WaitGroup
+	errs := make([]error, readers)
+	results := make([]cachedProfile, readers)
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = ns.GetCached("bob", &results[i], loader)
+		}(i)
+	}
+
+	// Let the readers pile up behind the first load
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("Expected 1 loader call, got %d", n)
+	}
+	for i := range results {
+		if errs[i] != nil {
+			t.Fatalf("Reader %d failed: %v", i, errs[i])
+		}
+		if results[i].Name != "Bob" || results[i].Age != 41 {
+			t.Errorf("Reader %d got %+v", i, results[i])
+		}
+	}
+
+	// The loaded value was stored
+	var stored cachedProfile
+	if err := ns.Get("bob", &stored); err != nil || stored.Name != "Bob" {
+		t.Errorf("Loaded value not stored: %+v, %v", stored, err)
+	}
+}
+
+func TestGetCachedLoaderError(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	ns := store.MustGetNamespace("profiles")
+
+	errBackend := errors.New("backend down")
+	var got cachedProfile
+	err := ns.GetCached("carol", &got, stow.WithLoader(func() (interface{}, error) {
+		return nil, errBackend
+	}))
+	if !errors.Is(err, errBackend) {
+		t.Errorf("Expected loader error, got %v", err)
+	}
+	if ns.Exists("carol") {
+		t.Errorf("Failed load must not store the key")
+	}
+}
+
+func TestGetCachedLoaderPanic(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	ns := store.MustGetNamespace("profiles")
+
+	release := make(chan struct{})
+	panicking := stow.WithLoader(func() (interface{}, error) {
+		<-release
+		panic("backend exploded")
+	})
+
+	const readers = 5
+	var wg sync.WaitGroup
+	errs := make([]error, readers)
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var got cachedProfile
+			errs[i] = ns.GetCached("dave", &got, panicking)
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err == nil {
+			t.Errorf("Reader %d: expected the panic as an error", i)
+		}
+	}
+
+	// The key isn't stuck behind the failed load
+	var got cachedProfile
+	err := ns.GetCached("dave", &got, stow.WithLoader(func() (interface{}, error) {
+		return cachedProfile{Name: "Dave"}, nil
+	}))
+	if err != nil || got.Name != "Dave" {
+		t.Errorf("Load after panic: %+v, %v", got, err)
+	}
+}
+
+func TestGetCachedWithoutLoaderDuringLoad(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	ns := store.MustGetNamespace("profiles")
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		var got cachedProfile
+		done <- ns.GetCached("erin", &got, stow.WithLoader(func() (interface{}, error) {
+			close(started)
+			<-release
+			return cachedProfile{Name: "Erin"}, nil
+		}))
+	}()
+	<-started
+
+	// A plain miss doesn't wait for the loader, nor get its value
+	var got cachedProfile
+	if err := ns.GetCached("erin", &got); !errors.Is(err, stow.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("GetCached with loader failed: %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package stow_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func chainedNamespace(t *testing.T, store stow.Store, config stow.NamespaceConfig) stow.Namespace {
+	t.Helper()
+
+	config.HashChain = true
+	ns, err := store.CreateNamespace("ledger", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+	return ns
+}
+
+func TestHashChainVerify(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	ns := chainedNamespace(t, store, stow.DefaultNamespaceConfig())
+
+	var heads []string
+	for i := 0; i < 3; i++ {
+		ns.MustPut("account", map[string]interface{}{"balance": i * 10})
+
+		head, err := ns.VerifyChain("account")
+		if err != nil {
+			t.Fatalf("VerifyChain failed: %v", err)
+		}
+		heads = append(heads, head)
+	}
+	ns.MustDelete("account")
+
+	head, err := ns.VerifyChain("account")
+	if err != nil {
+		t.Fatalf("VerifyChain after delete failed: %v", err)
+	}
+	heads = append(heads, head)
+
+	seen := make(map[string]bool)
+	for _, h := range heads {
+		if h == "" || seen[h] {
+			t.Errorf("Expected distinct heads, got %v", heads)
+		}
+		seen[h] = true
+	}
+
+	if _, err := ns.VerifyChain("missing"); !errors.Is(err, stow.ErrNotFound) {
+		t.Errorf("VerifyChain of missing key = %v, want ErrNotFound", err)
+	}
+}
+
+func TestHashChainDetectsEdits(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := stow.MustOpen(tmpDir)
+	defer store.Close()
+
+	ns := chainedNamespace(t, store, stow.DefaultNamespaceConfig())
+	for i := 1; i <= 3; i++ {
+		ns.MustPut("account", map[string]interface{}{"balance": i * 100})
+	}
+
+	filePath := filepath.Join(tmpDir, "ledger", "account.jsonl")
+	original, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+
+	t.Run("edited record", func(t *testing.T) {
+		edited := bytes.Replace(original, []byte(`"balance":200`), []byte(`"balance":999`), 1)
+		if err := os.WriteFile(filePath, edited, 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		if _, err := ns.VerifyChain("account"); !errors.Is(err, stow.ErrChainBroken) {
+			t.Errorf("VerifyChain = %v, want ErrChainBroken", err)
+		}
+	})
+
+	t.Run("removed record", func(t *testing.T) {
+		lines := strings.SplitAfter(string(original), "\n")
+		removed := lines[0] + lines[2]
+		if err := os.WriteFile(filePath, []byte(removed), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		if _, err := ns.VerifyChain("account"); !errors.Is(err, stow.ErrChainBroken) {
+			t.Errorf("VerifyChain = %v, want ErrChainBroken", err)
+		}
+	})
+}
+
+func TestHashChainSurvivesCompaction(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig().WithKey(bytes.Repeat([]byte{9}, 32))
+	config.AutoCompact = false
+	ns := chainedNamespace(t, store, config)
+
+	for i := 0; i < 8; i++ {
+		ns.MustPut("account", map[string]interface{}{"balance": i})
+	}
+	if err := ns.PinVersion("account", 2); err != nil {
+		t.Fatalf("PinVersion failed: %v", err)
+	}
+
+	before, err := ns.VerifyChain("account")
+	if err != nil {
+		t.Fatalf("VerifyChain failed: %v", err)
+	}
+
+	if err := ns.Compact("account"); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+
+	// The pinned version is kept with everything after it
+	history, err := ns.GetHistory("account")
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if len(history) != 7 {
+		t.Errorf("Expected 7 versions after compaction, got %d", len(history))
+	}
+
+	after, err := ns.VerifyChain("account")
+	if err != nil {
+		t.Fatalf("VerifyChain after compaction failed: %v", err)
+	}
+	if after != before {
+		t.Errorf("Head changed by compaction: %s != %s", after, before)
+	}
+}
+
+func TestHashChainDisabled(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	ns := store.MustGetNamespace("plain")
+	ns.MustPut("k", map[string]interface{}{"v": 1})
+
+	if _, err := ns.VerifyChain("k"); !errors.Is(err, stow.ErrInvalidConfig) {
+		t.Errorf("VerifyChain = %v, want ErrInvalidConfig", err)
+	}
+}
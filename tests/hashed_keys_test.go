@@ -0,0 +1,72 @@
+package stow_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestHashedKeys(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+
+	config := stow.DefaultNamespaceConfig()
+	config.HashedKeys = true
+	ns, err := store.CreateNamespace("raw", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	// Sanitized alike, too long or path-like keys all get their own file
+	keys := []string{"a/b", "a:b", "a_b", "../etc/passwd", "/abs", "nul\x00key", strings.Repeat("long", 500)}
+	for i, key := range keys {
+		if err := ns.Put(key, map[string]interface{}{"i": i}); err != nil {
+			t.Fatalf("Put(%q) failed: %v", key, err)
+		}
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "raw", "*.jsonl"))
+	if len(files) != len(keys) {
+		t.Errorf("Expected a file per key, got %v", files)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "etc")); !os.IsNotExist(err) {
+		t.Error("Expected no file outside the namespace")
+	}
+
+	if err := ns.Put("bad\xffutf8", map[string]interface{}{}); err == nil {
+		t.Error("Expected invalid UTF-8 key to be rejected")
+	}
+
+	config.HashedKeys = false
+	if err := ns.SetConfig(config); !errors.Is(err, stow.ErrInvalidConfig) {
+		t.Errorf("Expected HashedKeys to be fixed, got %v", err)
+	}
+	store.Close()
+
+	// The mode is persisted with the namespace
+	store = stow.MustOpen(dir)
+	defer store.Close()
+	ns, err = store.GetNamespace("raw")
+	if err != nil {
+		t.Fatalf("GetNamespace failed: %v", err)
+	}
+	for i, key := range keys {
+		var got map[string]interface{}
+		if err := ns.Get(key, &got); err != nil {
+			t.Fatalf("Get(%q) failed: %v", key, err)
+		}
+		if got["i"] != float64(i) {
+			t.Errorf("Get(%q) = %v, want %d", key, got, i)
+		}
+	}
+
+	ns.MustPut("a/b/c", map[string]interface{}{"new": true})
+	listed, _ := ns.List()
+	if len(listed) != len(keys)+1 {
+		t.Errorf("Expected %d keys, got %d", len(keys)+1, len(listed))
+	}
+}
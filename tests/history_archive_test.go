@@ -0,0 +1,169 @@
+package stow_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestArchiveHistory(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.AutoCompact = false
+	config.CompactKeepRecords = 2
+	config.ArchiveHistory = true
+	ns, err := store.CreateNamespace("audit", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	for i := 1; i <= 5; i++ {
+		ns.MustPut("order", map[string]interface{}{"step": i})
+	}
+	if err := ns.Compact("order"); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	for i := 6; i <= 8; i++ {
+		ns.MustPut("order", map[string]interface{}{"step": i})
+	}
+	if err := ns.Compact("order"); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+
+	// The key file keeps the last two records, the archive the rest
+	data, err := os.ReadFile(filepath.Join(dir, "audit", "order.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Errorf("key file has %d records, want 2", lines)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "audit", "order.history.gz")); err != nil {
+		t.Fatalf("history archive missing: %v", err)
+	}
+
+	history, err := ns.GetHistory("order")
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if len(history) != 8 {
+		t.Fatalf("history has %d versions, want 8", len(history))
+	}
+	for i, version := range history {
+		if version.Version != 8-i {
+			t.Errorf("history[%d] is version %d, want %d", i, version.Version, 8-i)
+		}
+	}
+
+	var step map[string]interface{}
+	if err := history[7].Decode(&step); err != nil {
+		t.Fatalf("Decode of archived version failed: %v", err)
+	}
+	if step["step"] != float64(1) {
+		t.Errorf("version 1 = %v", step)
+	}
+	if err := ns.GetVersion("order", 3, &step); err != nil || step["step"] != float64(3) {
+		t.Errorf("GetVersion(3) = %v, %v", step, err)
+	}
+
+	var latest map[string]interface{}
+	ns.MustGet("order", &latest)
+	if latest["step"] != float64(8) {
+		t.Errorf("latest = %v", latest)
+	}
+}
+
+func TestArchiveHistoryDisabled(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.AutoCompact = false
+	config.CompactKeepRecords = 2
+	ns, err := store.CreateNamespace("plain", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	for i := 1; i <= 5; i++ {
+		ns.MustPut("order", map[string]interface{}{"step": i})
+	}
+	if err := ns.Compact("order"); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "plain", "order.history.gz")); !os.IsNotExist(err) {
+		t.Errorf("unexpected history archive: %v", err)
+	}
+	history, _ := ns.GetHistory("order")
+	if len(history) != 2 {
+		t.Errorf("history has %d versions, want 2", len(history))
+	}
+}
+
+func TestArchiveHistoryKeepsBlobs(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.AutoCompact = false
+	config.CompactKeepRecords = 1
+	config.ArchiveHistory = true
+	ns, err := store.CreateNamespace("files", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	first := strings.Repeat("a", 10000)
+	ns.MustPut("doc", map[string]interface{}{"data": []byte(first)}, stow.WithForceFile())
+	ns.MustPut("doc", map[string]interface{}{"data": []byte("second")}, stow.WithForceFile())
+	if err := ns.Compact("doc"); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+
+	version1 := func(ns stow.Namespace, key string) string {
+		var value struct {
+			Data []byte `json:"data"`
+		}
+		if err := ns.GetVersion(key, 1, &value); err != nil {
+			return err.Error()
+		}
+		return string(value.Data)
+	}
+
+	// GC keeps the blob of the archived version
+	if _, err := ns.GC(); err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	if got := version1(ns, "doc"); got != first {
+		t.Errorf("after GC version 1 has %d bytes, want %d", len(got), len(first))
+	}
+
+	// Renamed and copied keys take archived versions along
+	if err := ns.Rename("doc", "renamed"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if got := version1(ns, "renamed"); got != first {
+		t.Errorf("renamed version 1 has %d bytes, want %d", len(got), len(first))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "files", "renamed.history.gz")); err != nil {
+		t.Errorf("expected the archive to move with the key: %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "files", "renamed.jsonl")); strings.Count(string(content), "\n") != 2 {
+		t.Errorf("expected the kept version and the rename marker in the key file, got:\n%s", content)
+	}
+	if err := store.CopyKey("files", "renamed", "backup", "doc"); err != nil {
+		t.Fatalf("CopyKey failed: %v", err)
+	}
+	if got := version1(store.MustGetNamespace("backup"), "doc"); got != first {
+		t.Errorf("copied version 1 has %d bytes, want %d", len(got), len(first))
+	}
+}
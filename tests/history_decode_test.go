@@ -0,0 +1,51 @@
+package stow_test
+
+import (
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestHistoryDecode(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	ns, err := store.GetNamespace("timeline")
+	if err != nil {
+		t.Fatalf("GetNamespace failed: %v", err)
+	}
+
+	for _, text := range []string{"one", "two", "three"} {
+		ns.MustPut("doc", draft{Text: text})
+	}
+	if err := ns.Delete("doc"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	history, err := ns.GetHistory("doc")
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if len(history) != 4 {
+		t.Fatalf("Expected 4 versions, got %d", len(history))
+	}
+
+	// Newest first: the delete can't be decoded
+	var got draft
+	if err := history[0].Decode(&got); err == nil {
+		t.Error("Expected an error decoding a delete")
+	}
+	for i, want := range []string{"three", "two", "one"} {
+		if err := history[i+1].Decode(&got); err != nil {
+			t.Fatalf("Decode version %d failed: %v", history[i+1].Version, err)
+		}
+		if got.Text != want {
+			t.Errorf("Version %d: expected %q, got %q", history[i+1].Version, want, got.Text)
+		}
+	}
+
+	// A zero Version has nothing to decode
+	if err := (stow.Version{Version: 1}).Decode(&got); err == nil {
+		t.Error("Expected an error decoding a zero Version")
+	}
+}
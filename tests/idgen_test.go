@@ -0,0 +1,203 @@
+package stow_test
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestBuiltinIDGenerators(t *testing.T) {
+	generators := []struct {
+		name    string
+		gen     stow.IDGenerator
+		pattern *regexp.Regexp
+	}{
+		{"ULID", stow.ULID, regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{26}$`)},
+		{"UUIDv7", stow.UUIDv7, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)},
+		{"Snowflake", stow.Snowflake(7), regexp.MustCompile(`^\d{19}$`)},
+	}
+
+	for _, tt := range generators {
+		t.Run(tt.name, func(t *testing.T) {
+			seen := make(map[string]bool)
+			var ids []string
+
+			for i := 0; i < 1000; i++ {
+				id, err := tt.gen.NewID()
+				if err != nil {
+					t.Fatalf("NewID failed: %v", err)
+				}
+				if !tt.pattern.MatchString(id) {
+					t.Fatalf("id %q does not match expected format", id)
+				}
+				if seen[id] {
+					t.Fatalf("duplicate id %q", id)
+				}
+				seen[id] = true
+				ids = append(ids, id)
+			}
+
+			// ULID and Snowflake are strictly monotonic within a process
+			if tt.name != "UUIDv7" && !sort.StringsAreSorted(ids) {
+				t.Error("ids should be generated in sorted order")
+			}
+		})
+	}
+}
+
+func TestPutAuto(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := stow.MustOpen(tmpDir)
+	defer store.Close()
+
+	ns := store.MustGetNamespace("events")
+
+	key, err := ns.PutAuto(map[string]interface{}{"type": "login"})
+	if err != nil {
+		t.Fatalf("PutAuto failed: %v", err)
+	}
+	if len(key) != 26 {
+		t.Errorf("default generator should be ULID, got key %q", key)
+	}
+
+	var event map[string]interface{}
+	ns.MustGet(key, &event)
+	if event["type"] != "login" {
+		t.Errorf("unexpected value: %v", event)
+	}
+
+	key, err = ns.PutAuto("payload", stow.WithIDGenerator(stow.UUIDv7))
+	if err != nil {
+		t.Fatalf("PutAuto with UUIDv7 failed: %v", err)
+	}
+	if !ns.Exists(key) {
+		t.Errorf("key %q should exist", key)
+	}
+}
+
+func TestPutAutoCustomGenerator(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := stow.MustOpen(tmpDir)
+	defer store.Close()
+
+	ns := store.MustGetNamespace("orders")
+
+	var mu sync.Mutex
+	next := 0
+	gen := stow.IDGeneratorFunc(func() (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		next++
+		return fmt.Sprintf("order-%d", next), nil
+	})
+
+	key, err := ns.PutAuto("first", stow.WithIDGenerator(gen))
+	if err != nil || key != "order-1" {
+		t.Fatalf("PutAuto = %q, %v", key, err)
+	}
+
+	// Existing keys are skipped
+	ns.MustPut("order-2", "taken")
+	key, err = ns.PutAuto("third", stow.WithIDGenerator(gen))
+	if err != nil || key != "order-3" {
+		t.Fatalf("PutAuto should skip taken key, got %q, %v", key, err)
+	}
+
+	// A generator that always collides gives up
+	stuck := stow.IDGeneratorFunc(func() (string, error) { return "order-1", nil })
+	if _, err := ns.PutAuto("x", stow.WithIDGenerator(stuck)); err == nil {
+		t.Error("expected error for generator that only returns existing keys")
+	}
+	// Concurrent calls handed the same key never overwrite each other
+	const writers = 20
+	keys := make([]string, writers)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var calls int
+			shared := stow.IDGeneratorFunc(func() (string, error) {
+				calls++
+				if calls == 1 {
+					return "shared", nil
+				}
+				return fmt.Sprintf("retry-%d", i), nil
+			})
+			var err error
+			if keys[i], err = ns.PutAuto(i, stow.WithIDGenerator(shared)); err != nil {
+				t.Errorf("PutAuto failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i, key := range keys {
+		var got int
+		if err := ns.Get(key, &got); err != nil || got != i {
+			t.Errorf("key %s = %d, %v, want %d", key, got, err, i)
+		}
+		if history, _ := ns.GetHistory(key); len(history) != 1 {
+			t.Errorf("key %s has %d versions, want 1", key, len(history))
+		}
+	}
+}
+
+func TestPutWithUniqueKey(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := stow.MustOpen(tmpDir)
+	defer store.Close()
+
+	ns := store.MustGetNamespace("posts")
+
+	for i, want := range []string{"hello", "hello-2", "hello-3"} {
+		key, err := ns.PutWithUniqueKey("hello", map[string]interface{}{"n": i})
+		if err != nil || key != want {
+			t.Fatalf("PutWithUniqueKey = %q, %v, want %q", key, err, want)
+		}
+	}
+
+	// Deleted keys are not reused
+	ns.MustDelete("hello-3")
+	ns.MustPut("hello-4", "taken")
+	key, err := ns.PutWithUniqueKey("hello", "fifth")
+	if err != nil || key != "hello-5" {
+		t.Fatalf("PutWithUniqueKey = %q, %v, want hello-5", key, err)
+	}
+
+	var first map[string]interface{}
+	ns.MustGet("hello", &first)
+	if fmt.Sprint(first["n"]) != "0" {
+		t.Errorf("hello was overwritten: %v", first)
+	}
+
+	// Concurrent calls get distinct keys
+	var wg sync.WaitGroup
+	keys := make([]string, 20)
+	for i := range keys {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key, err := ns.PutWithUniqueKey("race", i)
+			if err != nil {
+				t.Errorf("PutWithUniqueKey failed: %v", err)
+			}
+			keys[i] = key
+		}(i)
+	}
+	wg.Wait()
+
+	sort.Strings(keys)
+	for i := 1; i < len(keys); i++ {
+		if keys[i] == keys[i-1] {
+			t.Fatalf("key %q handed out twice", keys[i])
+		}
+	}
+	if history, _ := ns.GetHistory("race"); len(history) != 1 {
+		t.Errorf("race has %d versions, want 1", len(history))
+	}
+}
@@ -0,0 +1,91 @@
+package stow_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestInferSchema(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	ns := store.MustGetNamespace("products")
+
+	ns.MustPut("p:1", map[string]interface{}{
+		"name":  "Lamp",
+		"price": 19.5,
+		"tags":  []interface{}{"home", "light"},
+		"dims":  map[string]interface{}{"w": 10, "h": 30},
+		"photo": []byte(strings.Repeat("x", 8192)),
+	})
+	ns.MustPut("p:2", map[string]interface{}{
+		"name":  "Chair",
+		"price": "49.00",
+		"tags":  []interface{}{"home"},
+		"dims":  map[string]interface{}{"w": 40},
+		"spec":  json.RawMessage(`{"legs":4}`),
+	})
+	ns.MustPut("p:3", map[string]interface{}{
+		"name":  "Desk",
+		"price": nil,
+	})
+	ns.MustPut("p:4", map[string]interface{}{"name": "Gone"})
+	ns.MustDelete("p:4")
+
+	report, err := ns.InferSchema(0)
+	if err != nil {
+		t.Fatalf("InferSchema failed: %v", err)
+	}
+	if report.Keys != 4 || report.Sampled != 3 {
+		t.Errorf("Keys = %d, Sampled = %d, want 4 and 3", report.Keys, report.Sampled)
+	}
+
+	fields := make(map[string]stow.FieldSchema)
+	var paths []string
+	for _, field := range report.Fields {
+		fields[field.Path] = field
+		paths = append(paths, field.Path)
+	}
+	want := []string{"dims", "dims.h", "dims.w", "name", "photo", "price", "spec", "spec.legs", "tags", "tags[]"}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("paths = %v, want %v", paths, want)
+	}
+
+	if f := fields["name"]; f.Count != 3 || f.Optional || f.Types[stow.SchemaString] != 3 {
+		t.Errorf("name = %+v", f)
+	}
+	if f := fields["price"]; !f.Mixed() || !f.Nullable() || f.Optional {
+		t.Errorf("price = %+v, want mixed and nullable", f)
+	}
+	if f := fields["dims.h"]; !f.Optional || f.Count != 1 {
+		t.Errorf("dims.h = %+v, want optional", f)
+	}
+	if f := fields["dims.w"]; f.Optional {
+		t.Errorf("dims.w = %+v, want required", f)
+	}
+	if f := fields["tags[]"]; f.Count != 3 || f.Types[stow.SchemaString] != 3 {
+		t.Errorf("tags[] = %+v", f)
+	}
+	if f := fields["photo"]; f.Types[stow.SchemaBlob] != 1 || !f.Optional {
+		t.Errorf("photo = %+v, want an optional blob", f)
+	}
+	if f := fields["spec.legs"]; f.Types[stow.SchemaNumber] != 1 {
+		t.Errorf("spec.legs = %+v", f)
+	}
+
+	inconsistent := report.Inconsistent()
+	if len(inconsistent) != 1 || inconsistent[0].Path != "price" {
+		t.Errorf("Inconsistent = %+v, want price", inconsistent)
+	}
+
+	sampled, err := ns.InferSchema(2)
+	if err != nil {
+		t.Fatalf("InferSchema failed: %v", err)
+	}
+	if sampled.Keys != 4 || sampled.Sampled > 2 {
+		t.Errorf("Keys = %d, Sampled = %d, want 4 and at most 2", sampled.Keys, sampled.Sampled)
+	}
+}
@@ -0,0 +1,257 @@
+package stow_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+// writeIntents pretends a writer crashed with entries unfinished.
+func writeIntents(t *testing.T, path string, entries ...map[string]interface{}) {
+	t.Helper()
+	var data []byte
+	for _, entry := range entries {
+		line, _ := json.Marshal(entry)
+		data = append(append(data, line...), '\n')
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func onlyFile(t *testing.T, pattern string) string {
+	t.Helper()
+	files, _ := filepath.Glob(pattern)
+	if len(files) != 1 {
+		t.Fatalf("expected one file for %s, got %v", pattern, files)
+	}
+	return files[0]
+}
+
+func TestIntentLogRollsBackIncompleteBlobs(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	ns := store.MustGetNamespace("files")
+
+	ns.MustPut("doc", map[string]interface{}{"title": "v1"})
+	ns.MustPut("doc", map[string]interface{}{"title": "v2", "body": []byte("0123456789")}, stow.WithForceFile())
+	store.Close()
+
+	logPath := filepath.Join(dir, "files", "_intents.log")
+	if _, err := os.Stat(logPath); !os.IsNotExist(err) {
+		t.Fatalf("expected no intent log after a clean close, got %v", err)
+	}
+
+	// The crash hit after the append but before the blob reached the disk
+	blobPath := onlyFile(t, filepath.Join(dir, "files", "_blobs", "*"))
+	if err := os.Truncate(blobPath, 4); err != nil {
+		t.Fatal(err)
+	}
+	writeIntents(t, logPath, map[string]interface{}{
+		"id": 1, "op": "put", "key": "doc", "file": filepath.Base(onlyFile(t, filepath.Join(dir, "files", "doc*.jsonl"))),
+		"version": 2, "blobs": []map[string]interface{}{{"name": filepath.Base(blobPath), "size": 10}},
+	})
+
+	store = stow.MustOpen(dir)
+	defer store.Close()
+	ns = store.MustGetNamespace("files")
+
+	var doc map[string]interface{}
+	ns.MustGet("doc", &doc)
+	if doc["title"] != "v1" {
+		t.Errorf("expected the put to be rolled back, got %v", doc)
+	}
+	if _, err := os.Stat(blobPath); !os.IsNotExist(err) {
+		t.Errorf("expected the blob to be removed, got %v", err)
+	}
+	if _, err := os.Stat(logPath); !os.IsNotExist(err) {
+		t.Errorf("expected the intent log to be removed, got %v", err)
+	}
+}
+
+func TestIntentLogKeepsCompletedPuts(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	ns := store.MustGetNamespace("files")
+	ns.MustPut("doc", map[string]interface{}{"body": []byte("0123456789")}, stow.WithForceFile())
+	store.Close()
+
+	// The done mark was lost, but the record and blob are intact
+	blobPath := onlyFile(t, filepath.Join(dir, "files", "_blobs", "*"))
+	writeIntents(t, filepath.Join(dir, "files", "_intents.log"), map[string]interface{}{
+		"id": 1, "op": "put", "key": "doc", "file": filepath.Base(onlyFile(t, filepath.Join(dir, "files", "doc*.jsonl"))),
+		"version": 1, "blobs": []map[string]interface{}{{"name": filepath.Base(blobPath), "size": 10}},
+	})
+
+	store = stow.MustOpen(dir)
+	defer store.Close()
+	ns = store.MustGetNamespace("files")
+
+	if !ns.Exists("doc") {
+		t.Fatal("expected the completed put to be kept")
+	}
+	if _, err := os.Stat(blobPath); err != nil {
+		t.Errorf("expected the blob to be kept, got %v", err)
+	}
+}
+
+func TestIntentLogRemovesOrphans(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	ns := store.MustGetNamespace("files")
+	ns.MustPut("kept", map[string]interface{}{"body": []byte("shared")}, stow.WithForceFile())
+	ns.MustPut("doc", map[string]interface{}{"title": "v1"})
+	store.Close()
+
+	nsDir := filepath.Join(dir, "files")
+	shared := onlyFile(t, filepath.Join(nsDir, "_blobs", "*"))
+	orphan := filepath.Join(nsDir, "_blobs", "orphan_0123.bin")
+	os.WriteFile(orphan, []byte("half"), 0644)
+	docFile := filepath.Base(onlyFile(t, filepath.Join(nsDir, "doc*.jsonl")))
+	os.WriteFile(filepath.Join(nsDir, docFile+".tmp"), []byte("partial"), 0644)
+
+	// A put that never landed, sharing a blob with another key, and an
+	// interrupted compaction
+	writeIntents(t, filepath.Join(nsDir, "_intents.log"),
+		map[string]interface{}{
+			"id": 1, "op": "put", "key": "doc", "file": docFile, "version": 2,
+			"blobs": []map[string]interface{}{{"name": "orphan_0123.bin", "size": 10}, {"name": filepath.Base(shared), "size": 6}},
+		},
+		map[string]interface{}{"id": 2, "op": "compact", "file": docFile},
+	)
+
+	store = stow.MustOpen(dir)
+	defer store.Close()
+	ns = store.MustGetNamespace("files")
+
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Errorf("expected the orphan to be removed, got %v", err)
+	}
+	if _, err := os.Stat(shared); err != nil {
+		t.Errorf("expected the shared blob to be kept, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(nsDir, docFile+".tmp")); !os.IsNotExist(err) {
+		t.Errorf("expected the temporary file to be removed, got %v", err)
+	}
+
+	var kept map[string]interface{}
+	if err := ns.Get("kept", &kept); err != nil {
+		t.Errorf("Get failed: %v", err)
+	}
+}
+
+func TestIntentLogFinishesReplace(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		blobSize  int
+		wantTitle string
+	}{
+		{"blobs intact", 0, "v3"},
+		{"blobs incomplete", 10, "v2"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			store := stow.MustOpen(dir)
+			ns := store.MustGetNamespace("files")
+			ns.MustPut("doc", map[string]interface{}{"title": "v1"})
+			ns.MustPut("doc", map[string]interface{}{"title": "v2"})
+			store.Close()
+
+			// The crash hit after the latest record was cut off
+			filePath := onlyFile(t, filepath.Join(dir, "files", "doc*.jsonl"))
+			content, _ := os.ReadFile(filePath)
+			offset := bytes.IndexByte(content, '\n') + 1
+			replaced := bytes.TrimSpace(content[offset:])
+			record := bytes.Replace(replaced, []byte(`"v2"`), []byte(`"v3"`), 1)
+			if err := os.Truncate(filePath, int64(offset)); err != nil {
+				t.Fatal(err)
+			}
+
+			entry := map[string]interface{}{
+				"id": 1, "op": "replace", "key": "doc", "file": filepath.Base(filePath), "version": 2,
+				"offset": offset, "record": json.RawMessage(record), "replaced": json.RawMessage(replaced),
+			}
+			if tc.blobSize > 0 {
+				entry["blobs"] = []map[string]interface{}{{"name": "missing_0123.bin", "size": tc.blobSize}}
+			}
+			writeIntents(t, filepath.Join(dir, "files", "_intents.log"), entry)
+
+			store = stow.MustOpen(dir)
+			defer store.Close()
+			ns = store.MustGetNamespace("files")
+
+			var doc map[string]interface{}
+			ns.MustGet("doc", &doc)
+			if doc["title"] != tc.wantTitle {
+				t.Errorf("expected %s, got %v", tc.wantTitle, doc)
+			}
+			if history, _ := ns.GetHistory("doc"); len(history) != 2 {
+				t.Errorf("expected 2 versions, got %d", len(history))
+			}
+		})
+	}
+}
+
+func TestIntentLogFinishesRename(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	ns := store.MustGetNamespace("files")
+	ns.MustPut("draft", map[string]interface{}{"title": "v1"})
+	ns.MustPut("draft", map[string]interface{}{"title": "v2"})
+	if err := ns.PinVersion("draft", 1); err != nil {
+		t.Fatalf("PinVersion failed: %v", err)
+	}
+	ns.MustPut("other", map[string]interface{}{"title": "v1"})
+	nsDir := filepath.Join(dir, "files")
+	oldFile := onlyFile(t, filepath.Join(nsDir, "draft*.jsonl"))
+	before, _ := os.ReadFile(oldFile)
+	pins, _ := os.ReadFile(filepath.Join(nsDir, "_pins.json"))
+	if err := ns.Rename("draft", "doc"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	store.Close()
+
+	// The crash hit after the new key was written, before the old one was
+	// closed
+	os.WriteFile(oldFile, before, 0644)
+	os.WriteFile(filepath.Join(nsDir, "_pins.json"), pins, 0644)
+	orphan := filepath.Join(nsDir, "gone.history.gz")
+	os.WriteFile(orphan, []byte("half"), 0644)
+	writeIntents(t, filepath.Join(nsDir, "_intents.log"),
+		map[string]interface{}{
+			"id": 1, "op": "rename", "key": "draft", "file": filepath.Base(oldFile),
+			"writes": []map[string]interface{}{{"key": "doc", "file": filepath.Base(onlyFile(t, filepath.Join(nsDir, "doc*.jsonl"))), "version": 3}},
+		},
+		// One that never wrote its new key file
+		map[string]interface{}{
+			"id": 2, "op": "rename", "key": "other", "file": "other.jsonl",
+			"writes": []map[string]interface{}{{"key": "gone", "file": "gone.jsonl", "version": 2}},
+		},
+	)
+
+	store = stow.MustOpen(dir)
+	defer store.Close()
+	ns = store.MustGetNamespace("files")
+
+	if ns.Exists("draft") {
+		t.Error("expected the old key to be closed")
+	}
+	history, err := ns.GetHistory("draft")
+	if err != nil || len(history) != 3 || history[0].RenamedTo != "doc" {
+		t.Errorf("expected the old key to end with the rename, got %+v (%v)", history, err)
+	}
+	if pins, _ := ns.Pins("doc"); len(pins) != 1 || pins[0] != 1 {
+		t.Errorf("expected the pin to move, got %v", pins)
+	}
+
+	if !ns.Exists("other") || ns.Exists("gone") {
+		t.Error("expected the rename that never landed to leave the keys as they were")
+	}
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Errorf("expected the orphaned archive to be removed, got %v", err)
+	}
+}
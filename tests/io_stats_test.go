@@ -0,0 +1,140 @@
+package stow_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aigotowork/stow"
+)
+
+func openIOStats(t *testing.T, config stow.NamespaceConfig) (stow.Namespace, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	t.Cleanup(func() { store.Close() })
+
+	config.AutoCompact = false
+	ns, err := store.CreateNamespace("docs", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+	return ns, filepath.Join(dir, "docs")
+}
+
+func keyFileSize(t *testing.T, nsDir, key string) int64 {
+	t.Helper()
+
+	matches, _ := filepath.Glob(filepath.Join(nsDir, key+"*.jsonl"))
+	if len(matches) != 1 {
+		t.Fatalf("Expected one key file for %s, got %v", key, matches)
+	}
+	info, err := os.Stat(matches[0])
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	return info.Size()
+}
+
+func TestIOStatsPut(t *testing.T) {
+	ns, nsDir := openIOStats(t, stow.DefaultNamespaceConfig())
+
+	var first stow.IOStats
+	if err := ns.Put("doc", draft{Text: "one"}, stow.WithIOStats(&first)); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	size := keyFileSize(t, nsDir, "doc")
+	if first.BytesRead != 0 || first.BytesWritten != size || first.LogicalBytes != size || first.Syncs != 1 {
+		t.Errorf("Expected %d bytes appended and one sync, got %+v", size, first)
+	}
+	if first.WriteAmplification() != 1 {
+		t.Errorf("Expected no amplification for an append, got %v", first.WriteAmplification())
+	}
+
+	// The next version is found by reading the whole file
+	var second stow.IOStats
+	ns.MustPut("doc", draft{Text: "two"}, stow.WithIOStats(&second))
+	if second.BytesRead != size {
+		t.Errorf("Expected %d bytes read, got %+v", size, second)
+	}
+
+	// Blobs are written and synced too
+	var withBlob stow.IOStats
+	payload := bytes.Repeat([]byte("x"), 8192)
+	ns.MustPut("file", map[string]interface{}{"data": payload}, stow.WithIOStats(&withBlob))
+	if withBlob.BytesWritten != keyFileSize(t, nsDir, "file")+int64(len(payload)) || withBlob.Syncs != 2 {
+		t.Errorf("Expected the record and the blob counted, got %+v", withBlob)
+	}
+
+	stats, err := ns.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	var total stow.IOStats
+	for _, s := range []stow.IOStats{first, second, withBlob} {
+		total.BytesRead += s.BytesRead
+		total.BytesWritten += s.BytesWritten
+		total.Syncs += s.Syncs
+		total.LogicalBytes += s.LogicalBytes
+	}
+	if stats.IO.Put != total {
+		t.Errorf("Expected namespace totals %+v, got %+v", total, stats.IO.Put)
+	}
+}
+
+func TestIOStatsRewrite(t *testing.T) {
+	ns, nsDir := openIOStats(t, stow.DefaultNamespaceConfig().WithCoalesce(time.Minute))
+
+	ns.MustPut("doc", draft{Text: "H"})
+	ns.MustPut("doc", draft{Text: "He"})
+
+	// Each coalesced write rewrites the key file
+	var stats stow.IOStats
+	ns.MustPut("doc", draft{Text: "Hel"}, stow.WithIOStats(&stats))
+	size := keyFileSize(t, nsDir, "doc")
+	if stats.BytesWritten != size || stats.Syncs != 1 || stats.LogicalBytes == 0 {
+		t.Errorf("Expected a %d byte rewrite, got %+v", size, stats)
+	}
+}
+
+func TestIOStatsCompactAndGC(t *testing.T) {
+	ns, nsDir := openIOStats(t, stow.DefaultNamespaceConfig())
+
+	for i := 0; i < 10; i++ {
+		ns.MustPut("doc", map[string]interface{}{"n": i, "data": bytes.Repeat([]byte{byte(i)}, 8192)})
+	}
+	before := keyFileSize(t, nsDir, "doc")
+
+	if err := ns.Compact("doc"); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	after := keyFileSize(t, nsDir, "doc")
+
+	stats, err := ns.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	want := stow.IOStats{BytesRead: before, BytesWritten: after, Syncs: 1}
+	if stats.IO.Compact != want {
+		t.Errorf("Expected compaction IO %+v, got %+v", want, stats.IO.Compact)
+	}
+	if stats.IO.WriteAmplification() <= stats.IO.Put.WriteAmplification() {
+		t.Errorf("Expected compaction to add to write amplification, got %+v", stats.IO)
+	}
+
+	result, err := ns.GC()
+	if err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	if result.IO.BytesRead != after || result.IO.BytesWritten != 0 {
+		t.Errorf("Expected GC to read the key file, got %+v", result.IO)
+	}
+
+	stats, _ = ns.Stats()
+	if stats.IO.GC != result.IO {
+		t.Errorf("Expected namespace GC totals %+v, got %+v", result.IO, stats.IO.GC)
+	}
+}
@@ -0,0 +1,124 @@
+package stow_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+type joinUser struct {
+	Name string `json:"name"`
+}
+
+type joinTag struct {
+	Label string `json:"label"`
+}
+
+type joinPost struct {
+	Title    string    `json:"title"`
+	AuthorID string    `json:"author_id"`
+	Author   joinUser  `json:"author"`
+	Editor   *joinUser `json:"editor"`
+	TagIDs   []int     `json:"tag_ids"`
+	Tags     []joinTag `json:"tags"`
+}
+
+func setupJoin(t *testing.T) (stow.Namespace, stow.Namespace, stow.Namespace) {
+	t.Helper()
+
+	store := stow.MustOpen(t.TempDir())
+	t.Cleanup(func() { store.Close() })
+
+	posts := store.MustGetNamespace("posts")
+	users := store.MustGetNamespace("users")
+	tags := store.MustGetNamespace("tags")
+
+	users.MustPut("alice", joinUser{Name: "Alice"})
+	users.MustPut("bob", joinUser{Name: "Bob"})
+	for i := 1; i <= 3; i++ {
+		tags.MustPut(fmt.Sprint(i), joinTag{Label: fmt.Sprintf("tag-%d", i)})
+	}
+
+	posts.MustPut("post:1", map[string]interface{}{
+		"title":     "Hello",
+		"author_id": "alice",
+		"editor_id": "bob",
+		"tag_ids":   []int{3, 1},
+	})
+
+	return posts, users, tags
+}
+
+func TestJoinDecodeStruct(t *testing.T) {
+	posts, users, tags := setupJoin(t)
+
+	var post joinPost
+	err := stow.Join(posts, "post:1").
+		With("author", users, stow.KeyFromField("author_id")).
+		With("editor", users, stow.KeyFromField("editor_id")).
+		With("tags", tags, stow.KeyFromField("tag_ids")).
+		Decode(&post)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	if post.Title != "Hello" || post.AuthorID != "alice" {
+		t.Errorf("Root record not decoded: %+v", post)
+	}
+	if post.Author.Name != "Alice" {
+		t.Errorf("Expected author Alice, got %+v", post.Author)
+	}
+	if post.Editor == nil || post.Editor.Name != "Bob" {
+		t.Errorf("Expected editor Bob, got %+v", post.Editor)
+	}
+	if len(post.Tags) != 2 || post.Tags[0].Label != "tag-3" || post.Tags[1].Label != "tag-1" {
+		t.Errorf("Expected tags in key order, got %+v", post.Tags)
+	}
+}
+
+func TestJoinDecodeMap(t *testing.T) {
+	posts, users, tags := setupJoin(t)
+
+	var post map[string]interface{}
+	err := stow.Join(posts, "post:1").
+		With("author", users, stow.KeyFromField("author_id")).
+		With("tags", tags, stow.KeyFromField("tag_ids")).
+		Decode(&post)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+
+	author, _ := post["author"].(map[string]interface{})
+	if author["name"] != "Alice" {
+		t.Errorf("Expected author Alice, got %v", post["author"])
+	}
+	if list, _ := post["tags"].([]interface{}); len(list) != 2 {
+		t.Errorf("Expected 2 tags, got %v", post["tags"])
+	}
+}
+
+func TestJoinMissingReference(t *testing.T) {
+	posts, users, _ := setupJoin(t)
+
+	posts.MustPut("post:2", map[string]interface{}{"title": "Orphan", "author_id": "carol"})
+
+	var post joinPost
+	err := stow.Join(posts, "post:2").
+		With("author", users, stow.KeyFromField("author_id")).
+		Decode(&post)
+	if !errors.Is(err, stow.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	// No reference leaves the field unset
+	posts.MustPut("post:3", map[string]interface{}{"title": "Anonymous"})
+	post = joinPost{}
+	err = stow.Join(posts, "post:3").
+		With("author", users, stow.KeyFromField("author_id")).
+		Decode(&post)
+	if err != nil || post.Author.Name != "" {
+		t.Errorf("Expected unset author, got %+v, %v", post.Author, err)
+	}
+}
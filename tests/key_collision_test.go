@@ -0,0 +1,151 @@
+package stow_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+// mergeKeyFiles appends the records of src to dst and removes src, as a
+// case-insensitive filesystem would have written them.
+func mergeKeyFiles(t *testing.T, dst, src string) {
+	t.Helper()
+
+	a, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	b, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if err := os.WriteFile(dst, append(a, b...), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	os.Remove(src)
+}
+
+func TestKeyCollision(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	ns := store.MustGetNamespace("users")
+	ns.MustPut("Bob", map[string]interface{}{"name": "Bob"})
+	ns.MustPut("bob", map[string]interface{}{"name": "bob", "n": 1})
+	ns.MustPut("bob", map[string]interface{}{"name": "bob", "n": 2})
+	store.Close()
+
+	nsDir := filepath.Join(dir, "users")
+	shared := filepath.Join(nsDir, "bob.jsonl")
+	upper := filepath.Join(nsDir, "Bob.jsonl")
+	mergeKeyFiles(t, upper, shared)
+	if err := os.Rename(upper, shared); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+
+	store = stow.MustOpen(dir)
+	defer store.Close()
+	ns = store.MustGetNamespace("users")
+
+	// bob's file is Bob's
+	err := ns.Put("bob", map[string]interface{}{"name": "bob", "n": 3})
+	if !errors.Is(err, stow.ErrKeyCollision) {
+		t.Fatalf("Expected ErrKeyCollision, got %v", err)
+	}
+
+	result, err := ns.RepairCollisions()
+	if err != nil {
+		t.Fatalf("RepairCollisions failed: %v", err)
+	}
+	if result.Files != 1 || len(result.Moved) != 1 || result.Moved["bob"] == "" {
+		t.Fatalf("Expected bob moved out of one file, got %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(nsDir, result.Moved["bob"])); err != nil {
+		t.Errorf("Expected bob's new file: %v", err)
+	}
+
+	var got map[string]interface{}
+	if err := ns.Get("Bob", &got); err != nil || got["name"] != "Bob" {
+		t.Errorf("Get(Bob) = %v, %v", got, err)
+	}
+	history, err := ns.GetHistory("bob")
+	if err != nil || len(history) != 2 {
+		t.Fatalf("Expected bob's two versions, got %v, %v", history, err)
+	}
+	if history, _ := ns.GetHistory("Bob"); len(history) != 1 {
+		t.Errorf("Expected Bob's one version, got %d", len(history))
+	}
+
+	ns.MustPut("bob", map[string]interface{}{"name": "bob", "n": 3})
+	var bob struct {
+		N int `json:"n"`
+	}
+	if err := ns.Get("bob", &bob); err != nil || bob.N != 3 {
+		t.Errorf("Get(bob) = %+v, %v", bob, err)
+	}
+
+	// Nothing left to split
+	if result, err := ns.RepairCollisions(); err != nil || result.Files != 0 {
+		t.Errorf("Expected no collisions left, got %+v, %v", result, err)
+	}
+}
+
+func TestRepairCollisionsConcurrentPuts(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	defer store.Close()
+	config := stow.DefaultNamespaceConfig()
+	config.AutoCompact = false
+	ns, err := store.CreateNamespace("users", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+	ns.MustPut("owner", map[string]interface{}{"n": 0})
+	shared := filepath.Join(dir, "users", "owner.jsonl")
+
+	// The owner of the shared file keeps being written during the repairs
+	var puts atomic.Int64
+	stop := make(chan struct{})
+	var writer sync.WaitGroup
+	writer.Add(1)
+	go func() {
+		defer writer.Done()
+		for n := 1; ; n++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if err := ns.Put("owner", map[string]interface{}{"n": n}); err != nil {
+				t.Errorf("Put failed: %v", err)
+				return
+			}
+			puts.Add(1)
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		line := fmt.Sprintf(`{"_meta":{"k":"guest%d","v":1,"op":"put","ts":"2024-01-01T00:00:00Z"},"data":{"n":0}}`+"\n", i)
+		f, err := os.OpenFile(shared, os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			t.Fatalf("OpenFile failed: %v", err)
+		}
+		f.WriteString(line)
+		f.Close()
+
+		if _, err := ns.RepairCollisions(); err != nil {
+			t.Fatalf("RepairCollisions failed: %v", err)
+		}
+	}
+	close(stop)
+	writer.Wait()
+
+	if history, err := ns.GetHistory("owner"); err != nil || int64(len(history)) != puts.Load()+1 {
+		t.Errorf("expected %d versions of owner, got %d (%v)", puts.Load()+1, len(history), err)
+	}
+}
@@ -0,0 +1,105 @@
+package stow_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+type manifestLine struct {
+	Key     string                   `json:"key"`
+	File    string                   `json:"file"`
+	Version int                      `json:"version"`
+	Deleted bool                     `json:"deleted"`
+	Blobs   []map[string]interface{} `json:"blobs"`
+}
+
+func readKeyManifest(t *testing.T, nsPath string) []manifestLine {
+	t.Helper()
+
+	f, err := os.Open(filepath.Join(nsPath, "_keys.jsonl"))
+	if err != nil {
+		t.Fatalf("failed to open manifest: %v", err)
+	}
+	defer f.Close()
+
+	var lines []manifestLine
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var line manifestLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("invalid manifest line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestKeyManifest(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+
+	config := stow.DefaultNamespaceConfig()
+	config.KeyManifest = true
+	ns, err := store.CreateNamespace("users", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	ns.MustPut("user:2", map[string]interface{}{"name": "Bob"})
+	ns.MustPut("user:1", map[string]interface{}{"name": "Alice"})
+	ns.MustPut("user:1", map[string]interface{}{
+		"name":   "Alice",
+		"avatar": []byte(strings.Repeat("a", 8192)),
+	})
+	ns.MustDelete("user:2")
+
+	lines := readKeyManifest(t, ns.Path())
+	if len(lines) != 2 || lines[0].Key != "user:1" || lines[1].Key != "user:2" {
+		t.Fatalf("unexpected manifest %+v", lines)
+	}
+	if lines[0].Version != 2 || lines[0].Deleted || len(lines[0].Blobs) != 1 {
+		t.Errorf("unexpected entry %+v", lines[0])
+	}
+	if lines[1].Version != 2 || !lines[1].Deleted {
+		t.Errorf("unexpected entry %+v", lines[1])
+	}
+	if _, err := os.Stat(filepath.Join(ns.Path(), lines[0].File)); err != nil {
+		t.Errorf("manifest file of user:1 missing: %v", err)
+	}
+
+	// The manifest is not mistaken for a key file
+	keys, err := ns.List()
+	if err != nil || len(keys) != 1 {
+		t.Errorf("List = %v, %v", keys, err)
+	}
+	store.Close()
+
+	// Reopening rebuilds it after the key files changed offline
+	if err := os.Remove(filepath.Join(dir, "users", lines[0].File)); err != nil {
+		t.Fatal(err)
+	}
+	store = stow.MustOpen(dir)
+	defer store.Close()
+	ns = store.MustGetNamespace("users")
+
+	lines = readKeyManifest(t, ns.Path())
+	if len(lines) != 1 || lines[0].Key != "user:2" {
+		t.Fatalf("manifest not rebuilt: %+v", lines)
+	}
+
+	// Turning it off removes it
+	config = ns.GetConfig()
+	config.KeyManifest = false
+	if err := ns.SetConfig(config); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(ns.Path(), "_keys.jsonl")); !os.IsNotExist(err) {
+		t.Errorf("manifest not removed: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package stow_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+type product struct {
+	SKU   string
+	Image []byte
+}
+
+func TestRegisterModel(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	err := store.RegisterModel(product{}, stow.ModelOptions{
+		Key: func(v interface{}) (string, error) {
+			switch p := v.(type) {
+			case product:
+				return "product:" + p.SKU, nil
+			case *product:
+				return "product:" + p.SKU, nil
+			}
+			return "", errors.New("not a product")
+		},
+		BlobThreshold: 16,
+		PutOptions:    []stow.PutOption{stow.WithMimeType("image/png")},
+	})
+	if err != nil {
+		t.Fatalf("RegisterModel failed: %v", err)
+	}
+
+	ns := store.MustGetNamespace("catalog")
+
+	key, err := ns.PutAuto(&product{SKU: "A-1", Image: []byte(strings.Repeat("x", 32))})
+	if err != nil {
+		t.Fatalf("PutAuto failed: %v", err)
+	}
+	if key != "product:A-1" {
+		t.Errorf("PutAuto key = %q, want product:A-1", key)
+	}
+
+	raw, err := ns.GetRaw(key)
+	if err != nil {
+		t.Fatalf("GetRaw failed: %v", err)
+	}
+	image, ok := raw.RawData()["Image"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Image was stored inline, want a blob: %v", raw.RawData()["Image"])
+	}
+	if image["mime"] != "image/png" {
+		t.Errorf("blob mime type = %v, want image/png", image["mime"])
+	}
+
+	// Call options win over model defaults
+	if err := ns.Put("small", product{SKU: "B-2", Image: []byte(strings.Repeat("y", 32))}, stow.WithForceInline()); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	raw, err = ns.GetRaw("small")
+	if err != nil {
+		t.Fatalf("GetRaw failed: %v", err)
+	}
+	if _, isBlob := raw.RawData()["Image"].(map[string]interface{}); isBlob {
+		t.Error("WithForceInline was overridden by model defaults")
+	}
+
+	// An explicit generator replaces the model key
+	key, err = ns.PutAuto(product{SKU: "C-3"}, stow.WithIDGenerator(stow.UUIDv7))
+	if err != nil {
+		t.Fatalf("PutAuto failed: %v", err)
+	}
+	if strings.HasPrefix(key, "product:") {
+		t.Errorf("PutAuto key = %q, want a generated key", key)
+	}
+}
+
+func TestRegisterModelValidates(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	type broken struct {
+		Body string `stow:"file"`
+	}
+	if err := store.RegisterModel(broken{}, stow.ModelOptions{}); !errors.Is(err, stow.ErrInvalidTag) {
+		t.Errorf("RegisterModel(broken) = %v, want ErrInvalidTag", err)
+	}
+	if err := store.RegisterModel("not a struct", stow.ModelOptions{}); err == nil {
+		t.Error("RegisterModel(string) succeeded, want an error")
+	}
+}
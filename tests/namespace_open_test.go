@@ -0,0 +1,96 @@
+package stow_test
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestGetNamespaceSharedHandle(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	const workers = 32
+	handles := make([]stow.Namespace, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ns, err := store.GetNamespace("shared")
+			if err != nil {
+				t.Errorf("GetNamespace failed: %v", err)
+				return
+			}
+			handles[i] = ns
+		}(i)
+	}
+	wg.Wait()
+
+	for i, ns := range handles {
+		if ns != handles[0] {
+			t.Fatalf("handle %d differs from handle 0", i)
+		}
+	}
+
+	handles[0].MustPut("k", map[string]interface{}{"v": 1})
+
+	// Evicting drops the handle; the next open sees the data on disk
+	if err := store.CloseNamespace("shared"); err != nil {
+		t.Fatalf("CloseNamespace failed: %v", err)
+	}
+	if err := store.CloseNamespace("never-opened"); err != nil {
+		t.Errorf("CloseNamespace of a closed namespace failed: %v", err)
+	}
+
+	reopened := store.MustGetNamespace("shared")
+	if reopened == handles[0] {
+		t.Error("GetNamespace after CloseNamespace returned the evicted handle")
+	}
+	if !reopened.Exists("k") {
+		t.Error("reopened namespace lost its data")
+	}
+	// The evicted handle is closed
+	var got map[string]interface{}
+	if err := handles[0].Get("k", &got); !errors.Is(err, stow.ErrClosed) {
+		t.Errorf("Get through the evicted handle: expected ErrClosed, got %v", err)
+	}
+	if err := handles[0].Put("k", map[string]interface{}{"v": 2}); !errors.Is(err, stow.ErrClosed) {
+		t.Errorf("Put through the evicted handle: expected ErrClosed, got %v", err)
+	}
+	if err := reopened.Get("k", &got); err != nil || got["v"] != float64(1) {
+		t.Errorf("reopened Get = %v, %v", got, err)
+	}
+}
+
+func TestMaxOpenNamespaces(t *testing.T) {
+	store := stow.MustOpen(t.TempDir(), stow.WithStoreMaxOpenNamespaces(2))
+	defer store.Close()
+
+	held := store.MustGetNamespace("tenant-0")
+	held.MustPut("k", map[string]interface{}{"v": 0})
+
+	for i := 1; i <= 5; i++ {
+		ns := store.MustGetNamespace(fmt.Sprintf("tenant-%d", i))
+		ns.MustPut("k", map[string]interface{}{"v": i})
+	}
+	runtime.GC()
+
+	// tenant-0 was evicted but is still held, so it is reused
+	if again := store.MustGetNamespace("tenant-0"); again != held {
+		t.Error("GetNamespace returned a second handle for a held namespace")
+	}
+
+	// Released namespaces reopen from disk
+	for i := 0; i <= 5; i++ {
+		var value map[string]interface{}
+		store.MustGetNamespace(fmt.Sprintf("tenant-%d", i)).MustGet("k", &value)
+		if fmt.Sprint(value["v"]) != fmt.Sprint(i) {
+			t.Errorf("tenant-%d value = %v", i, value["v"])
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package stow_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+type spillProduct struct {
+	Name     string                 `json:"name"`
+	Metadata map[string]interface{} `json:"metadata"`
+	Reviews  []string               `json:"reviews"`
+}
+
+func TestNestedFieldSpill(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := stow.MustOpen(tmpDir)
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.NestedBlobThreshold = 512
+	ns, err := store.CreateNamespace("products", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	metadata := make(map[string]interface{})
+	for i := 0; i < 100; i++ {
+		metadata[fmt.Sprintf("attr_%03d", i)] = fmt.Sprintf("value %d", i)
+	}
+
+	ns.MustPut("widget", spillProduct{
+		Name:     "Widget",
+		Metadata: metadata,
+		Reviews:  []string{"great"},
+	})
+
+	blobs, _ := os.ReadDir(filepath.Join(ns.Path(), "_blobs"))
+	if len(blobs) != 1 {
+		t.Fatalf("expected metadata spilled to 1 blob, got %d", len(blobs))
+	}
+
+	for _, refresh := range []bool{false, true} {
+		if refresh {
+			ns.RefreshAll()
+		}
+
+		var result spillProduct
+		ns.MustGet("widget", &result)
+		if len(result.Metadata) != 100 || result.Metadata["attr_042"] != "value 42" {
+			t.Errorf("metadata not rehydrated (refresh=%v)", refresh)
+		}
+		if len(result.Reviews) != 1 {
+			t.Errorf("inline reviews lost (refresh=%v)", refresh)
+		}
+	}
+
+	// Generic targets see decoded values as well
+	var generic interface{}
+	ns.MustGet("widget", &generic)
+	if m, ok := generic.(map[string]interface{})["metadata"].(map[string]interface{}); !ok || len(m) != 100 {
+		t.Error("interface{} target should receive rehydrated metadata")
+	}
+
+	// Spilled blobs are referenced and survive GC
+	if result, err := ns.GC(); err != nil || result.RemovedBlobs != 0 {
+		t.Errorf("GC removed referenced blobs: %+v, %v", result, err)
+	}
+}
+
+func TestNestedFieldSpillWithPathOps(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := stow.MustOpen(tmpDir)
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.NestedBlobThreshold = 64
+	ns, err := store.CreateNamespace("posts", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	ns.MustPut("post", spillProduct{Name: "post", Reviews: []string{"short"}})
+
+	// Grows past the threshold and gets spilled on the way
+	for i := 0; i < 10; i++ {
+		if err := ns.AppendPath("post", "reviews", fmt.Sprintf("review number %d", i)); err != nil {
+			t.Fatalf("AppendPath failed: %v", err)
+		}
+	}
+
+	ns.RefreshAll()
+
+	var result spillProduct
+	ns.MustGet("post", &result)
+	if len(result.Reviews) != 11 || result.Reviews[10] != "review number 9" {
+		t.Errorf("unexpected reviews: %v", result.Reviews)
+	}
+}
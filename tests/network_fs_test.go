@@ -0,0 +1,60 @@
+package stow_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestNetworkFSMode(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir, stow.WithNetworkFSMode())
+
+	config := stow.DefaultNamespaceConfig()
+	config.CompactThreshold = 5
+	config.CompactKeepRecords = 2
+	ns, err := store.CreateNamespace("shared", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	for i := 0; i < 12; i++ {
+		ns.MustPut("doc", draft{Text: strings.Repeat("v", i+1)})
+	}
+	if err := ns.Tag("doc", "shared"); err != nil {
+		t.Fatalf("Tag failed: %v", err)
+	}
+	if err := ns.Compact("doc"); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	store.Close()
+
+	// No temp files are left behind by rewrites
+	filepath.Walk(filepath.Join(dir, "shared"), func(path string, info os.FileInfo, err error) error {
+		if err == nil && strings.HasSuffix(path, ".tmp") {
+			t.Errorf("Leftover temp file %s", path)
+		}
+		return nil
+	})
+
+	store = stow.MustOpen(dir, stow.WithNetworkFSMode())
+	defer store.Close()
+
+	ns = store.MustGetNamespace("shared")
+	var got draft
+	ns.MustGet("doc", &got)
+	if got.Text != strings.Repeat("v", 12) {
+		t.Errorf("Expected latest write after reopen, got %q", got.Text)
+	}
+	if tags, _ := ns.Tags("doc"); len(tags) != 1 || tags[0] != "shared" {
+		t.Errorf("Expected tag to survive reopen, got %v", tags)
+	}
+
+	history, err := ns.GetHistory("doc")
+	if err != nil || len(history) > 5 {
+		t.Errorf("Expected compacted history, got %d versions (%v)", len(history), err)
+	}
+}
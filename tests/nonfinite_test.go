@@ -0,0 +1,85 @@
+package stow_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+type sample struct {
+	Sensor   string    `json:"sensor"`
+	Reading  float64   `json:"reading"`
+	Readings []float64 `json:"readings"`
+}
+
+func TestNonFiniteFloatsRejected(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	ns := store.MustGetNamespace("samples")
+
+	err := ns.Put("s:1", sample{Sensor: "a", Readings: []float64{1, math.Inf(1)}})
+	if !errors.Is(err, stow.ErrNonFiniteFloat) {
+		t.Fatalf("Put = %v, want ErrNonFiniteFloat", err)
+	}
+	if ns.Exists("s:1") {
+		t.Error("rejected Put created the key")
+	}
+}
+
+func TestNonFiniteFloatsSentinel(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+
+	config := stow.DefaultNamespaceConfig()
+	config.NonFiniteFloats = stow.FloatSentinel
+	ns, err := store.CreateNamespace("samples", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	in := sample{Sensor: "a", Reading: math.NaN(), Readings: []float64{0.25, math.Inf(-1)}}
+	if err := ns.Put("s:1", in); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	store.Close()
+
+	// The policy is persisted with the namespace
+	store = stow.MustOpen(dir)
+	defer store.Close()
+	ns = store.MustGetNamespace("samples")
+
+	var out sample
+	if err := ns.Get("s:1", &out); err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if !math.IsNaN(out.Reading) || len(out.Readings) != 2 || out.Readings[0] != 0.25 || !math.IsInf(out.Readings[1], -1) {
+		t.Errorf("got %+v", out)
+	}
+
+	var generic map[string]interface{}
+	if err := ns.Get("s:1", &generic); err != nil {
+		t.Fatalf("Get into map failed: %v", err)
+	}
+	if v, ok := generic["reading"].(float64); !ok || !math.IsNaN(v) {
+		t.Errorf("reading = %v", generic["reading"])
+	}
+
+	// Equal values with NaN still compare through their stored form
+	var version int
+	if err := ns.Put("s:1", in, stow.WithSkipIfUnchanged(), stow.WithWrittenVersion(&version)); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if version != 1 {
+		t.Errorf("unchanged Put wrote version %d, want 1", version)
+	}
+}
+
+func TestNonFiniteFloatsInvalidPolicy(t *testing.T) {
+	config := stow.DefaultNamespaceConfig()
+	config.NonFiniteFloats = "zero"
+	if err := config.Validate(); !errors.Is(err, stow.ErrInvalidConfig) {
+		t.Errorf("Validate() = %v, want ErrInvalidConfig", err)
+	}
+}
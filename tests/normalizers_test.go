@@ -0,0 +1,94 @@
+package stow_test
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+type contact struct {
+	Name    string  `json:"name"`
+	Email   string  `json:"email"`
+	Balance float64 `json:"balance"`
+}
+
+func lowerEmail(data map[string]interface{}) error {
+	if email, ok := data["email"].(string); ok {
+		data["email"] = strings.ToLower(strings.TrimSpace(email))
+	}
+	return nil
+}
+
+func roundBalance(data map[string]interface{}) error {
+	if balance, ok := data["balance"].(float64); ok {
+		data["balance"] = math.Round(balance*100) / 100
+	}
+	return nil
+}
+
+func TestNormalizers(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+
+	config := stow.DefaultNamespaceConfig()
+	calls := 0
+	count := func(map[string]interface{}) error {
+		calls++
+		return nil
+	}
+	config.Normalizers = []func(map[string]interface{}) error{lowerEmail, roundBalance, count}
+	ns, err := store.CreateNamespace("contacts", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	ns.MustPut("c:1", contact{Name: "Alice", Email: "  Alice@Example.COM ", Balance: 10.456})
+
+	var got contact
+	ns.MustGet("c:1", &got)
+	if got.Email != "alice@example.com" || got.Balance != 10.46 {
+		t.Errorf("got %+v", got)
+	}
+
+	// Path updates are normalized too
+	if err := ns.AppendPath("c:1", "notes", "called"); err != nil {
+		t.Fatalf("AppendPath failed: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("normalizers ran %d times, want 2", calls)
+	}
+	store.Close()
+
+	// Normalizers aren't persisted: reopening takes them from the store options
+	store = stow.MustOpen(dir, stow.WithStoreNormalizers("contacts", lowerEmail))
+	defer store.Close()
+	ns = store.MustGetNamespace("contacts")
+
+	ns.MustPut("c:2", contact{Name: "Carol", Email: "CAROL@EXAMPLE.COM"})
+	ns.MustGet("c:2", &got)
+	if got.Email != "carol@example.com" {
+		t.Errorf("email after reopen = %q", got.Email)
+	}
+}
+
+func TestNormalizerError(t *testing.T) {
+	errNoEmail := errors.New("email required")
+	store := stow.MustOpen(t.TempDir(), stow.WithStoreNormalizers("contacts", func(data map[string]interface{}) error {
+		if data["email"] == "" {
+			return errNoEmail
+		}
+		return nil
+	}))
+	defer store.Close()
+	ns := store.MustGetNamespace("contacts")
+
+	if err := ns.Put("c:1", contact{Name: "Dave"}); !errors.Is(err, errNoEmail) {
+		t.Fatalf("Put = %v, want the normalizer error", err)
+	}
+	if ns.Exists("c:1") {
+		t.Error("rejected Put created the key")
+	}
+}
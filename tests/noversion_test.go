@@ -0,0 +1,134 @@
+package stow_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+type trackedArticle struct {
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+	ViewCount int    `json:"view_count" stow:"noversion"`
+}
+
+func TestNoVersionFieldUpdatesInPlace(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	ns := store.MustGetNamespace("articles")
+
+	ns.MustPut("post", trackedArticle{Title: "Hello", Body: "v1"})
+	for i := 1; i <= 5; i++ {
+		ns.MustPut("post", trackedArticle{Title: "Hello", Body: "v1", ViewCount: i})
+	}
+
+	history, err := ns.GetHistory("post")
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if len(history) != 1 {
+		t.Fatalf("view count updates should not add versions, got %d", len(history))
+	}
+
+	// The latest value is visible from cache and from disk
+	for _, refresh := range []bool{false, true} {
+		if refresh {
+			ns.RefreshAll()
+		}
+
+		var got trackedArticle
+		ns.MustGet("post", &got)
+		if got.ViewCount != 5 {
+			t.Errorf("ViewCount = %d, want 5 (refresh=%v)", got.ViewCount, refresh)
+		}
+	}
+
+	// Versioned field changes still create a version, carrying the current count
+	ns.MustPut("post", trackedArticle{Title: "Hello", Body: "v2", ViewCount: 6})
+
+	history, _ = ns.GetHistory("post")
+	if len(history) != 2 {
+		t.Fatalf("expected 2 versions, got %d", len(history))
+	}
+
+	var first trackedArticle
+	if err := ns.GetVersion("post", 1, &first); err != nil {
+		t.Fatalf("GetVersion failed: %v", err)
+	}
+	if first.Body != "v1" || first.ViewCount != 5 {
+		t.Errorf("version 1 = %+v, want body v1 with 5 views", first)
+	}
+}
+
+func TestNoVersionAfterDelete(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	ns := store.MustGetNamespace("articles")
+
+	ns.MustPut("post", trackedArticle{Title: "Hello", ViewCount: 1})
+	if err := ns.Delete("post"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	// A deleted key is recreated as a new version
+	ns.MustPut("post", trackedArticle{Title: "Hello", ViewCount: 2})
+
+	var got trackedArticle
+	ns.MustGet("post", &got)
+	if got.ViewCount != 2 {
+		t.Errorf("ViewCount = %d, want 2", got.ViewCount)
+	}
+}
+
+func TestNoVersionRewritesLatestOnly(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	defer store.Close()
+
+	ns := store.MustGetNamespace("articles")
+	ns.MustPut("post", trackedArticle{Title: "Hello", Body: "v1"})
+	ns.MustPut("post", trackedArticle{Title: "Hello", Body: "v2"})
+
+	filePath := filepath.Join(dir, "articles", "post.jsonl")
+	before, _ := os.ReadFile(filePath)
+	first := before[:bytes.IndexByte(before, '\n')+1]
+
+	ns.MustPut("post", trackedArticle{Title: "Hello", Body: "v2", ViewCount: 7})
+	after, _ := os.ReadFile(filePath)
+	if !bytes.HasPrefix(after, first) || bytes.Count(after, []byte("\n")) != 2 {
+		t.Errorf("Expected the first record untouched and the latest replaced, got:\n%s", after)
+	}
+}
+
+type notedArticle struct {
+	Title string `json:"title"`
+	Notes string `json:"notes" stow:"noversion"`
+}
+
+func TestNoVersionHardCap(t *testing.T) {
+	store, err := stow.Open(t.TempDir(), stow.WithStoreHardCap(20000))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+	ns := store.MustGetNamespace("articles")
+
+	// In-place updates that grow the record count against the cap
+	var full error
+	for i := 1; i <= 10 && full == nil; i++ {
+		full = ns.Put("post", notedArticle{Title: "Hello", Notes: strings.Repeat("n", 5000*i)})
+	}
+	if !errors.Is(full, stow.ErrStoreFull) {
+		t.Fatalf("expected ErrStoreFull, got %v", full)
+	}
+	if history, _ := ns.GetHistory("post"); len(history) != 1 {
+		t.Errorf("Expected a single version, got %d", len(history))
+	}
+}
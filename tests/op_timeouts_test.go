@@ -0,0 +1,103 @@
+package stow_test
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aigotowork/stow"
+)
+
+// recordingLogger keeps the messages of warnings and errors.
+type recordingLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *recordingLogger) Debug(msg string, fields ...stow.Field) {}
+func (l *recordingLogger) Info(msg string, fields ...stow.Field)  {}
+
+func (l *recordingLogger) Warn(msg string, fields ...stow.Field) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, msg)
+}
+
+func (l *recordingLogger) Error(msg string, fields ...stow.Field) {
+	l.Warn(msg, fields...)
+}
+
+func (l *recordingLogger) count(msg string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for _, m := range l.messages {
+		if m == msg {
+			n++
+		}
+	}
+	return n
+}
+
+func TestOperationTimeouts(t *testing.T) {
+	logger := &recordingLogger{}
+	store := stow.MustOpen(t.TempDir(),
+		stow.WithStoreLogger(logger),
+		stow.WithStoreSlowOpThreshold(10*time.Millisecond),
+		stow.WithStoreOperationTimeouts(stow.OperationTimeouts{Put: 50 * time.Millisecond}))
+	defer store.Close()
+
+	// A processor stuck like a hung network write
+	release := make(chan struct{})
+	err := store.RegisterBlobProcessor("image/*", func(stow.BlobInput) ([]stow.DerivedBlob, error) {
+		<-release
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("RegisterBlobProcessor failed: %v", err)
+	}
+
+	ns := store.MustGetNamespace("media")
+	image := bytes.Repeat([]byte("p"), 8*1024)
+
+	start := time.Now()
+	err = ns.Put("photo", map[string]interface{}{"image": image}, stow.WithMimeType("image/png"))
+	if !errors.Is(err, stow.ErrOperationTimeout) {
+		t.Fatalf("Expected ErrOperationTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Put blocked for %v", elapsed)
+	}
+	if logger.count("operation timed out") != 1 {
+		t.Error("Expected a timeout log")
+	}
+
+	// The Put completes in the background once unblocked, and is logged as slow
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for !ns.Exists("photo") {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out Put never completed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for logger.count("slow operation") == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("slow Put was not logged")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Operations without a timeout run as usual
+	ns.MustPut("note", map[string]interface{}{"text": "hi"})
+	var note map[string]interface{}
+	ns.MustGet("note", &note)
+	if note["text"] != "hi" {
+		t.Errorf("Unexpected value %v", note)
+	}
+	if _, err := ns.GC(); err != nil {
+		t.Errorf("GC failed: %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package stow_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestOpenProgress(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	ns := store.MustGetNamespace("users")
+	for i := 0; i < 200; i++ {
+		ns.MustPut(fmt.Sprintf("user:%d", i), map[string]interface{}{"n": i})
+	}
+	store.Close()
+
+	var (
+		mu       sync.Mutex
+		progress []stow.OpenProgress
+	)
+	store = stow.MustOpen(dir,
+		stow.WithStoreScanWorkers(16),
+		stow.WithStoreOpenProgress(func(p stow.OpenProgress) {
+			mu.Lock()
+			defer mu.Unlock()
+			progress = append(progress, p)
+		}))
+	defer store.Close()
+
+	ns = store.MustGetNamespace("users")
+	keys, err := ns.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(keys) != 200 {
+		t.Fatalf("indexed %d keys, want 200", len(keys))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(progress) != 200 {
+		t.Fatalf("got %d progress calls, want 200", len(progress))
+	}
+	for i, p := range progress {
+		if p.Namespace != "users" || p.Files != i+1 || p.Total != 200 {
+			t.Fatalf("progress[%d] = %+v", i, p)
+		}
+	}
+}
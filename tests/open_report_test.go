@@ -0,0 +1,87 @@
+package stow_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestOpenReport(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	store := stow.MustOpen(tmpDir)
+	ns := store.MustGetNamespace("orders")
+	ns.MustPut("a", map[string]interface{}{"n": 1})
+	ns.MustPut("a", map[string]interface{}{"n": 2})
+	ns.MustPut("b", map[string]interface{}{"n": 1})
+	store.MustGetNamespace("empty")
+	store.Close()
+
+	// A corrupt line in the middle and a torn write at the end
+	filePath := filepath.Join(tmpDir, "orders", "a.jsonl")
+	f, err := os.OpenFile(filePath, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	f.WriteString("not json\n")
+	f.WriteString(`{"_meta":{"k":"a","v":3,"op":"pu`)
+	f.Close()
+
+	var report stow.OpenReport
+	store, err = stow.Open(tmpDir, stow.WithStoreOpenReport(&report))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	if len(report.Namespaces) != 2 {
+		t.Fatalf("got %d namespace reports, want 2: %+v", len(report.Namespaces), report.Namespaces)
+	}
+	var orders stow.NamespaceReport
+	for _, nsReport := range report.Namespaces {
+		if nsReport.Name == "orders" {
+			orders = nsReport
+		}
+	}
+	if orders.Keys != 2 || orders.CorruptLines != 1 || orders.TornTails != 1 || orders.Err != nil {
+		t.Errorf("orders report = %+v, want 2 keys, 1 corrupt line, 1 torn tail", orders)
+	}
+	if !report.Degraded() {
+		t.Error("Degraded() = false, want true")
+	}
+
+	// The torn tail is gone, so the next write lands on its own line
+	ns = store.MustGetNamespace("orders")
+	ns.MustPut("a", map[string]interface{}{"n": 3})
+	history, err := ns.GetHistory("a")
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if len(history) != 3 {
+		t.Errorf("got %d versions, want 3", len(history))
+	}
+}
+
+func TestOpenReportHealthy(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	store := stow.MustOpen(tmpDir)
+	store.MustGetNamespace("orders").MustPut("a", map[string]interface{}{"n": 1})
+	store.Close()
+
+	var report stow.OpenReport
+	store, err := stow.Open(tmpDir, stow.WithStoreOpenReport(&report))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	if report.Degraded() {
+		t.Errorf("Degraded() = true for a healthy store: %+v", report)
+	}
+	if len(report.Namespaces) != 1 || report.Namespaces[0].Keys != 1 {
+		t.Errorf("report = %+v, want one namespace with one key", report)
+	}
+}
@@ -0,0 +1,160 @@
+package stow_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aigotowork/stow"
+)
+
+// writeLease pretends another writer holds a namespace until expires.
+func writeLease(t *testing.T, path, owner string, expires time.Time) {
+	t.Helper()
+	data, _ := json.Marshal(map[string]interface{}{
+		"owner":      owner,
+		"renewed_at": time.Now().UTC(),
+		"expires_at": expires.UTC(),
+	})
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func leaseOwner(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	var lease struct {
+		Owner string `json:"owner"`
+	}
+	json.Unmarshal(data, &lease)
+	return lease.Owner
+}
+
+func TestOwnerLease(t *testing.T) {
+	dir := t.TempDir()
+	store, err := stow.Open(dir, stow.WithStoreOwnerID("web-1"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	config := stow.DefaultNamespaceConfig()
+	config.OwnerLease = 90 * time.Millisecond
+	ns, err := store.CreateNamespace("jobs", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+	leasePath := filepath.Join(dir, "jobs", "_owner.json")
+
+	ns.MustPut("job:1", map[string]interface{}{"state": "queued"})
+	if owner := leaseOwner(t, leasePath); owner != "web-1" {
+		t.Fatalf("lease owner = %q, want web-1", owner)
+	}
+
+	// The lease is renewed
+	time.Sleep(150 * time.Millisecond)
+	ns.MustPut("job:1", map[string]interface{}{"state": "running"})
+
+	// Another writer takes over: writes fail once the lease is renewed
+	writeLease(t, leasePath, "web-2", time.Now().Add(time.Hour))
+	time.Sleep(60 * time.Millisecond)
+	err = ns.Put("job:1", map[string]interface{}{"state": "done"})
+	if !errors.Is(err, stow.ErrNotOwner) {
+		t.Fatalf("expected ErrNotOwner, got %v", err)
+	}
+	if err := ns.Delete("job:1"); !errors.Is(err, stow.ErrNotOwner) {
+		t.Errorf("Delete: expected ErrNotOwner, got %v", err)
+	}
+
+	// Reads still work
+	var job map[string]interface{}
+	ns.MustGet("job:1", &job)
+	if job["state"] != "running" {
+		t.Errorf("got %v", job)
+	}
+
+	// Forcing ownership back
+	if err := ns.TakeOwnership(); err != nil {
+		t.Fatalf("TakeOwnership failed: %v", err)
+	}
+	ns.MustPut("job:1", map[string]interface{}{"state": "done"})
+	if owner := leaseOwner(t, leasePath); owner != "web-1" {
+		t.Errorf("lease owner = %q after TakeOwnership", owner)
+	}
+
+	// Closing the store gives the namespace up
+	store.Close()
+	if _, err := os.Stat(leasePath); !os.IsNotExist(err) {
+		t.Errorf("expected the lease file to be removed, got %v", err)
+	}
+}
+
+func TestOwnerLeaseExpired(t *testing.T) {
+	dir := t.TempDir()
+	store, err := stow.Open(dir, stow.WithStoreOwnerID("web-1"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.OwnerLease = time.Hour
+	ns, err := store.CreateNamespace("jobs", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+	leasePath := filepath.Join(dir, "jobs", "_owner.json")
+
+	// A crashed writer's lease doesn't block once expired
+	writeLease(t, leasePath, "web-2", time.Now().Add(-time.Minute))
+	ns.MustPut("job:1", map[string]interface{}{"state": "queued"})
+	if owner := leaseOwner(t, leasePath); owner != "web-1" {
+		t.Errorf("lease owner = %q, want web-1", owner)
+	}
+
+	// Disabling the lease releases it
+	config = ns.GetConfig()
+	config.OwnerLease = 0
+	if err := ns.SetConfig(config); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+	if _, err := os.Stat(leasePath); !os.IsNotExist(err) {
+		t.Errorf("expected the lease file to be removed, got %v", err)
+	}
+
+	// Namespaces without a lease can't be taken
+	if err := ns.TakeOwnership(); !errors.Is(err, stow.ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+}
+
+func TestOwnerLeaseHeldElsewhere(t *testing.T) {
+	dir := t.TempDir()
+	store, err := stow.Open(dir)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	config := stow.DefaultNamespaceConfig()
+	config.OwnerLease = time.Hour
+	ns, err := store.CreateNamespace("jobs", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+	store.Close()
+
+	// The persisted config makes every writer check the lease
+	writeLease(t, filepath.Join(dir, "jobs", "_owner.json"), "web-2", time.Now().Add(time.Hour))
+	store = stow.MustOpen(dir)
+	defer store.Close()
+	ns = store.MustGetNamespace("jobs")
+	if err := ns.Put("job:1", map[string]interface{}{}); !errors.Is(err, stow.ErrNotOwner) {
+		t.Errorf("expected ErrNotOwner, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package stow_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+type pathComment struct {
+	Author string `json:"author"`
+	Text   string `json:"text"`
+}
+
+type pathPost struct {
+	Title    string        `json:"title"`
+	Comments []pathComment `json:"comments"`
+}
+
+func TestPathArrayOperations(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := stow.MustOpen(tmpDir)
+	defer store.Close()
+
+	ns := store.MustGetNamespace("posts")
+	ns.MustPut("hello", pathPost{Title: "Hello"})
+
+	t.Run("Append", func(t *testing.T) {
+		if err := ns.AppendPath("hello", "comments", pathComment{Author: "alice", Text: "first"}); err != nil {
+			t.Fatalf("AppendPath failed: %v", err)
+		}
+		if err := ns.AppendPath("hello", "comments", pathComment{Author: "bob", Text: "third"}); err != nil {
+			t.Fatalf("AppendPath failed: %v", err)
+		}
+
+		var post pathPost
+		ns.MustGet("hello", &post)
+		if len(post.Comments) != 2 || post.Comments[1].Author != "bob" {
+			t.Errorf("unexpected comments: %+v", post.Comments)
+		}
+	})
+
+	t.Run("Insert", func(t *testing.T) {
+		if err := ns.InsertPath("hello", "comments[1]", pathComment{Author: "carol", Text: "second"}); err != nil {
+			t.Fatalf("InsertPath failed: %v", err)
+		}
+
+		var post pathPost
+		ns.MustGet("hello", &post)
+		if len(post.Comments) != 3 || post.Comments[1].Author != "carol" {
+			t.Errorf("unexpected comments: %+v", post.Comments)
+		}
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		if err := ns.RemovePath("hello", "comments[0]"); err != nil {
+			t.Fatalf("RemovePath failed: %v", err)
+		}
+
+		var post pathPost
+		ns.MustGet("hello", &post)
+		if len(post.Comments) != 2 || post.Comments[0].Author != "carol" {
+			t.Errorf("unexpected comments: %+v", post.Comments)
+		}
+	})
+
+	t.Run("BoundsChecking", func(t *testing.T) {
+		if err := ns.RemovePath("hello", "comments[10]"); !errors.Is(err, stow.ErrIndexOutOfRange) {
+			t.Errorf("expected ErrIndexOutOfRange, got %v", err)
+		}
+		if err := ns.InsertPath("hello", "comments[3]", pathComment{}); !errors.Is(err, stow.ErrIndexOutOfRange) {
+			t.Errorf("expected ErrIndexOutOfRange, got %v", err)
+		}
+		if err := ns.AppendPath("hello", "title", "x"); !errors.Is(err, stow.ErrInvalidPath) {
+			t.Errorf("expected ErrInvalidPath, got %v", err)
+		}
+	})
+
+	t.Run("MissingKey", func(t *testing.T) {
+		if err := ns.AppendPath("missing", "comments", "x"); !errors.Is(err, stow.ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("VersionsRecorded", func(t *testing.T) {
+		history, err := ns.GetHistory("hello")
+		if err != nil {
+			t.Fatalf("GetHistory failed: %v", err)
+		}
+		// 1 put + 2 appends + 1 insert + 1 remove
+		if len(history) != 5 {
+			t.Errorf("expected 5 versions, got %d", len(history))
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package stow_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestUnsafeKeysRejected(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	ns := store.MustGetNamespace("users")
+
+	for _, key := range []string{"../escape", "a/../../b", "/etc/passwd", `C:\Windows\win.ini`, "nul\x00key"} {
+		if err := ns.Put(key, map[string]interface{}{"x": 1}); err == nil {
+			t.Errorf("Put(%q) should be rejected", key)
+		}
+	}
+
+	// Separators are still fine in keys without traversal
+	if err := ns.Put("user/alice:v1", map[string]interface{}{"x": 1}); err != nil {
+		t.Errorf("Put with sanitized key failed: %v", err)
+	}
+}
+
+func TestUnsafeNamespaceNamesRejected(t *testing.T) {
+	base := t.TempDir()
+	store := stow.MustOpen(filepath.Join(base, "store"))
+	defer store.Close()
+
+	for _, name := range []string{"..", "../outside", "a/b", ""} {
+		if _, err := store.GetNamespace(name); !errors.Is(err, stow.ErrUnsafePath) {
+			t.Errorf("GetNamespace(%q) = %v, want ErrUnsafePath", name, err)
+		}
+		if err := store.DeleteNamespace(name); !errors.Is(err, stow.ErrUnsafePath) {
+			t.Errorf("DeleteNamespace(%q) = %v, want ErrUnsafePath", name, err)
+		}
+	}
+
+	if _, err := os.Stat(base); err != nil {
+		t.Fatalf("base directory damaged: %v", err)
+	}
+}
+
+func TestSymlinkedRecordNotFollowed(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+
+	// A record file outside the store
+	store := stow.MustOpen(outside)
+	store.MustGetNamespace("secrets").MustPut("token", map[string]interface{}{"value": "s3cret"})
+	store.Close()
+
+	store = stow.MustOpen(dir)
+	defer store.Close()
+	ns := store.MustGetNamespace("users")
+
+	target := filepath.Join(outside, "secrets", "token.jsonl")
+	if err := os.Symlink(target, filepath.Join(ns.Path(), "token.jsonl")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	// Neither a rescan nor a direct write follows the link
+	store.Close()
+	store = stow.MustOpen(dir)
+	ns = store.MustGetNamespace("users")
+
+	var result map[string]interface{}
+	if err := ns.Get("token", &result); err == nil {
+		t.Errorf("Get should not read through a symlink, got %v", result)
+	}
+	if err := ns.Put("token", map[string]interface{}{"value": "overwritten"}); !errors.Is(err, stow.ErrUnsafePath) {
+		t.Errorf("Put through symlink = %v, want ErrUnsafePath", err)
+	}
+
+	content, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatalf("failed to read outside file: %v", err)
+	}
+	if strings.Contains(string(content), "overwritten") {
+		t.Error("write escaped the store through a symlink")
+	}
+}
@@ -0,0 +1,113 @@
+package stow_test
+
+import (
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestPinVersionSurvivesCompaction(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.AutoCompact = false
+	config.CompactKeepRecords = 2
+	ns, err := store.CreateNamespace("releases", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	for i := 1; i <= 6; i++ {
+		ns.MustPut("config", map[string]interface{}{"release": i})
+	}
+
+	if err := ns.PinVersion("config", 3); err != nil {
+		t.Fatalf("PinVersion failed: %v", err)
+	}
+	// Pinning twice is idempotent
+	if err := ns.PinVersion("config", 3); err != nil {
+		t.Fatalf("PinVersion failed: %v", err)
+	}
+
+	pins, err := ns.Pins("config")
+	if err != nil {
+		t.Fatalf("Pins failed: %v", err)
+	}
+	if len(pins) != 1 || pins[0] != 3 {
+		t.Fatalf("Pins = %v, want [3]", pins)
+	}
+
+	if err := ns.Compact("config"); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+
+	history, _ := ns.GetHistory("config")
+	if len(history) != 3 {
+		t.Fatalf("expected pinned version plus 2 recent ones, got %d versions", len(history))
+	}
+
+	var pinned map[string]interface{}
+	if err := ns.GetVersion("config", 3, &pinned); err != nil {
+		t.Fatalf("pinned version lost: %v", err)
+	}
+	if pinned["release"] != float64(3) {
+		t.Errorf("pinned version data = %v", pinned)
+	}
+
+	// After unpinning, compaction may remove it
+	if err := ns.UnpinVersion("config", 3); err != nil {
+		t.Fatalf("UnpinVersion failed: %v", err)
+	}
+	pins, _ = ns.Pins("config")
+	if len(pins) != 0 {
+		t.Errorf("Pins after unpin = %v, want none", pins)
+	}
+
+	ns.Compact("config")
+	history, _ = ns.GetHistory("config")
+	if len(history) != 2 {
+		t.Errorf("expected 2 versions after unpinned compaction, got %d", len(history))
+	}
+}
+
+func TestPinVersionErrors(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	ns := store.MustGetNamespace("releases")
+	ns.MustPut("config", map[string]interface{}{"release": 1})
+
+	if err := ns.PinVersion("missing", 1); err == nil {
+		t.Error("pinning a missing key should fail")
+	}
+	if err := ns.PinVersion("config", 42); err == nil {
+		t.Error("pinning a missing version should fail")
+	}
+	if err := ns.UnpinVersion("config", 42); err != nil {
+		t.Errorf("unpinning an unpinned version should be a no-op, got %v", err)
+	}
+}
+
+func TestPinVersionKeepsBlobs(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	ns := store.MustGetNamespace("releases")
+	ns.MustPut("bundle", map[string]interface{}{"data": []byte("release 1")}, stow.WithForceFile())
+	if err := ns.PinVersion("bundle", 1); err != nil {
+		t.Fatalf("PinVersion failed: %v", err)
+	}
+	ns.MustPut("bundle", map[string]interface{}{"data": []byte("release 2")}, stow.WithForceFile())
+
+	if _, err := ns.GC(); err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+
+	var value struct {
+		Data []byte `json:"data"`
+	}
+	if err := ns.GetVersion("bundle", 1, &value); err != nil || string(value.Data) != "release 1" {
+		t.Errorf("GetVersion(1) after GC = %q, %v", value.Data, err)
+	}
+}
@@ -0,0 +1,56 @@
+package stow_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestPreload(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+
+	config := stow.DefaultNamespaceConfig()
+	config.Preload = []string{"config:features", "config:missing"}
+	ns, err := store.CreateNamespace("settings", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+	ns.MustPut("config:features", map[string]interface{}{"beta": true})
+	ns.MustPut("config:limits", map[string]interface{}{"rate": 10})
+	ns.MustPut("config:other", map[string]interface{}{"x": 1})
+	store.Close()
+
+	store = stow.MustOpen(dir)
+	defer store.Close()
+	ns = store.MustGetNamespace("settings")
+
+	// Primed keys are served from the cache once their files are gone
+	if err := ns.Prime("config:limits"); err != nil {
+		t.Fatalf("Prime failed: %v", err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "settings", "config*.jsonl"))
+	for _, file := range files {
+		os.Remove(file)
+	}
+
+	var value map[string]interface{}
+	for _, key := range []string{"config:features", "config:limits"} {
+		if err := ns.Get(key, &value); err != nil {
+			t.Errorf("Get(%s): expected a cached value, got %v", key, err)
+		}
+	}
+	if err := ns.Get("config:other", &value); err == nil {
+		t.Error("expected config:other not to be cached")
+	}
+}
+
+func TestPreloadInvalid(t *testing.T) {
+	config := stow.DefaultNamespaceConfig()
+	config.Preload = []string{""}
+	if err := config.Validate(); err == nil {
+		t.Error("expected an empty preload key to be invalid")
+	}
+}
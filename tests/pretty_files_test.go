@@ -0,0 +1,98 @@
+package stow_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestPrettyFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := stow.MustOpen(tmpDir)
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.Layout = stow.LayoutPrettyFiles
+	ns, err := store.CreateNamespace("test", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	prettyPath := filepath.Join(tmpDir, "test", "server.json")
+
+	ns.MustPut("server", map[string]interface{}{"port": 8080, "host": "localhost"})
+	ns.MustPut("server", map[string]interface{}{"port": 9090, "host": "localhost"})
+
+	content, err := os.ReadFile(prettyPath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	want := "{\n  \"host\": \"localhost\",\n  \"port\": 9090\n}\n"
+	if string(content) != want {
+		t.Errorf("Pretty file = %q, want %q", content, want)
+	}
+
+	// The copy is not a key of its own
+	keys, err := ns.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(keys) != 1 {
+		t.Errorf("Expected 1 key, got %v", keys)
+	}
+
+	ns.MustDelete("server")
+	if _, err := os.Stat(prettyPath); !os.IsNotExist(err) {
+		t.Errorf("Pretty file should be removed after delete, got %v", err)
+	}
+}
+
+func TestPrettyFilesLayoutSwitch(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := stow.MustOpen(tmpDir)
+	defer store.Close()
+
+	ns := store.MustGetNamespace("test")
+	ns.MustPut("a", map[string]interface{}{"v": 1})
+	ns.MustPut("b", map[string]interface{}{"v": 2})
+
+	prettyPath := filepath.Join(tmpDir, "test", "a.json")
+	if _, err := os.Stat(prettyPath); !os.IsNotExist(err) {
+		t.Fatalf("Default layout should not write pretty files, got %v", err)
+	}
+
+	config := ns.GetConfig()
+	config.Layout = stow.LayoutPrettyFiles
+	if err := ns.SetConfig(config); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+
+	for _, name := range []string{"a.json", "b.json"} {
+		content, err := os.ReadFile(filepath.Join(tmpDir, "test", name))
+		if err != nil {
+			t.Fatalf("Existing key not materialized: %v", err)
+		}
+		if !strings.Contains(string(content), `"v": `) {
+			t.Errorf("Unexpected pretty file %s: %s", name, content)
+		}
+	}
+
+	config.Layout = stow.LayoutJSONL
+	if err := ns.SetConfig(config); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+	if _, err := os.Stat(prettyPath); !os.IsNotExist(err) {
+		t.Errorf("Pretty files should be removed when turned off, got %v", err)
+	}
+}
+
+func TestPrettyFilesInvalidLayout(t *testing.T) {
+	config := stow.DefaultNamespaceConfig()
+	config.Layout = "tree"
+	if err := config.Validate(); err == nil {
+		t.Error("Expected error for unknown layout")
+	}
+}
@@ -0,0 +1,201 @@
+package stow_test
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	defer store.Close()
+	ns := store.MustGetNamespace("users")
+
+	shared := []byte(strings.Repeat("s", 8*1024))
+	for i := 0; i < 4; i++ {
+		avatar := []byte(strings.Repeat(string(rune('a'+i)), 8*1024))
+		ns.MustPut("user:1", archivedUser{Name: "Alice", Avatar: avatar})
+		ns.MustPut("user:2", archivedUser{Name: "Bob", Avatar: shared})
+	}
+	time.Sleep(50 * time.Millisecond)
+	recent := []byte(strings.Repeat("r", 8*1024))
+	ns.MustPut("user:1", archivedUser{Name: "Alice", Avatar: recent})
+
+	blobs := func() int {
+		files, _ := filepath.Glob(filepath.Join(dir, "users", "_blobs", "*"))
+		return len(files)
+	}
+	if n := blobs(); n != 6 {
+		t.Fatalf("expected 6 blobs before prune, got %d", n)
+	}
+
+	result, err := ns.Prune(stow.PruneOptions{OlderThan: 25 * time.Millisecond, KeepAtLeast: 2})
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+
+	// user:1 keeps the recent version and one old one, user:2 its last two
+	if result.Keys != 2 || result.RemovedVersions != 5 {
+		t.Errorf("Keys = %d, RemovedVersions = %d, want 2 and 5", result.Keys, result.RemovedVersions)
+	}
+	if result.RemovedBlobs != 3 || blobs() != 3 {
+		t.Errorf("RemovedBlobs = %d with %d left, want 3 and 3", result.RemovedBlobs, blobs())
+	}
+	if result.ReclaimedSize < 3*8*1024 {
+		t.Errorf("ReclaimedSize = %d, want at least the removed blobs", result.ReclaimedSize)
+	}
+
+	history, err := ns.GetHistory("user:1")
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if len(history) != 2 || history[0].Version != 5 || history[1].Version != 4 {
+		t.Errorf("user:1 history = %+v, want versions 4 and 5", history)
+	}
+
+	var user archivedUser
+	if err := ns.Get("user:2", &user); err != nil || string(user.Avatar) != string(shared) {
+		t.Errorf("shared blob lost: %v", err)
+	}
+	if err := ns.GetVersion("user:1", 4, &user); err != nil || user.Avatar[0] != 'd' {
+		t.Errorf("kept version lost its blob: %v", err)
+	}
+
+	// Nothing left to prune
+	result, err = ns.Prune(stow.PruneOptions{OlderThan: 25 * time.Millisecond, KeepAtLeast: 2})
+	if err != nil || result.RemovedVersions != 0 || result.RemovedBlobs != 0 {
+		t.Errorf("second Prune = %+v, %v", result, err)
+	}
+}
+
+func TestPruneKeepsCurrentAndPinned(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	ns := store.MustGetNamespace("cfg")
+
+	for i := 0; i < 3; i++ {
+		ns.MustPut("flags", map[string]interface{}{"rev": i})
+	}
+	if err := ns.PinVersion("flags", 1); err != nil {
+		t.Fatalf("PinVersion failed: %v", err)
+	}
+	ns.MustPut("gone", map[string]interface{}{"rev": 0})
+	ns.MustDelete("gone")
+
+	if _, err := ns.Prune(stow.PruneOptions{}); err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+
+	history, _ := ns.GetHistory("flags")
+	if len(history) != 2 || history[0].Version != 3 || history[1].Version != 1 {
+		t.Errorf("history = %+v, want versions 3 and 1 (pinned)", history)
+	}
+	if ns.Exists("gone") {
+		t.Error("deleted key came back after Prune")
+	}
+}
+
+func TestPruneKeepsArchivedBlobs(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.AutoCompact = false
+	config.CompactKeepRecords = 1
+	config.ArchiveHistory = true
+	ns, err := store.CreateNamespace("users", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	first := []byte(strings.Repeat("a", 8*1024))
+	ns.MustPut("user:1", archivedUser{Name: "Alice", Avatar: first})
+	ns.MustPut("user:1", archivedUser{Name: "Alice", Avatar: []byte(strings.Repeat("b", 8*1024))})
+	if err := ns.Compact("user:1"); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+
+	// Version 3 shares its blob with the archived version 1
+	ns.MustPut("user:1", archivedUser{Name: "Alice", Avatar: first})
+	ns.MustPut("user:1", archivedUser{Name: "Alice", Avatar: []byte(strings.Repeat("d", 8*1024))})
+
+	result, err := ns.Prune(stow.PruneOptions{KeepAtLeast: 1})
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if result.RemovedVersions != 2 || result.RemovedBlobs != 1 {
+		t.Errorf("RemovedVersions = %d, RemovedBlobs = %d, want 2 and 1", result.RemovedVersions, result.RemovedBlobs)
+	}
+
+	var user archivedUser
+	if err := ns.GetVersion("user:1", 1, &user); err != nil || string(user.Avatar) != string(first) {
+		t.Errorf("archived version lost its blob: %v", err)
+	}
+}
+
+func TestPruneConcurrentPuts(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	// Gets read the key files, not what the Puts cached
+	config := stow.DefaultNamespaceConfig()
+	config.DisableCache = true
+	config.AutoCompact = false
+	ns, err := store.CreateNamespace("cfg", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	var writers sync.WaitGroup
+	done := make(chan struct{})
+	for w := 0; w < 8; w++ {
+		writers.Add(1)
+		go func(w int) {
+			defer writers.Done()
+			key := fmt.Sprintf("flags:%d", w)
+			for i := 0; i < 200; i++ {
+				if err := ns.Put(key, map[string]interface{}{"rev": i}); err != nil {
+					t.Errorf("Put failed: %v", err)
+					return
+				}
+				var got map[string]interface{}
+				if err := ns.Get(key, &got); err != nil || fmt.Sprint(got["rev"]) != fmt.Sprint(i) {
+					t.Errorf("%s: Put of rev %d lost, got %v (%v)", key, i, got, err)
+					return
+				}
+			}
+		}(w)
+	}
+	go func() {
+		writers.Wait()
+		close(done)
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if _, err := ns.Prune(stow.PruneOptions{KeepAtLeast: 30}); err != nil {
+			t.Fatalf("Prune failed: %v", err)
+		}
+	}
+}
+
+func TestPruneInvalidOptions(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	ns := store.MustGetNamespace("cfg")
+
+	if _, err := ns.Prune(stow.PruneOptions{KeepAtLeast: -1}); !errors.Is(err, stow.ErrInvalidConfig) {
+		t.Errorf("Prune = %v, want ErrInvalidConfig", err)
+	}
+}
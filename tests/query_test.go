@@ -0,0 +1,135 @@
+package stow_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aigotowork/stow"
+)
+
+type queryUser struct {
+	Name    string   `json:"name"`
+	Age     int      `json:"age"`
+	Roles   []string `json:"roles"`
+	Address struct {
+		City string `json:"city"`
+	} `json:"address"`
+	Joined time.Time `json:"joined"`
+}
+
+func openQueryUsers(t *testing.T) stow.Namespace {
+	t.Helper()
+
+	store := stow.MustOpen(t.TempDir())
+	t.Cleanup(func() { store.Close() })
+	ns := store.MustGetNamespace("users")
+
+	users := map[string]queryUser{
+		"alice": {Name: "Alice", Age: 31, Roles: []string{"admin", "editor"}},
+		"bob":   {Name: "Bob", Age: 17, Roles: []string{"viewer"}},
+		"carol": {Name: "Carol", Age: 45},
+		"dave":  {Name: "Dave", Age: 18, Roles: []string{"editor"}},
+	}
+	users["alice"] = withCity(users["alice"], "Lisbon", 2020)
+	users["bob"] = withCity(users["bob"], "Porto", 2022)
+	users["carol"] = withCity(users["carol"], "Lisbon", 2019)
+	users["dave"] = withCity(users["dave"], "Braga", 2024)
+	for key, user := range users {
+		ns.MustPut(key, user)
+	}
+	ns.MustPut("erin", queryUser{Name: "Erin", Age: 50})
+	ns.MustDelete("erin")
+	return ns
+}
+
+func withCity(u queryUser, city string, year int) queryUser {
+	u.Address.City = city
+	u.Joined = time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	return u
+}
+
+func queryKeys(t *testing.T, ns stow.Namespace, filters ...stow.Filter) []string {
+	t.Helper()
+
+	results, err := ns.Query(filters...)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	keys := []string{}
+	for _, r := range results {
+		keys = append(keys, r.Key)
+	}
+	return keys
+}
+
+func TestQuery(t *testing.T) {
+	ns := openQueryUsers(t)
+
+	tests := []struct {
+		name    string
+		filters []stow.Filter
+		want    []string
+	}{
+		{"all", nil, []string{"alice", "bob", "carol", "dave"}},
+		{"eq", []stow.Filter{stow.Eq("name", "Bob")}, []string{"bob"}},
+		{"eq int", []stow.Filter{stow.Eq("age", 45)}, []string{"carol"}},
+		{"ne", []stow.Filter{stow.Ne("address.city", "Lisbon")}, []string{"bob", "dave"}},
+		{"range", []stow.Filter{stow.Gte("age", 18), stow.Lt("age", 40)}, []string{"alice", "dave"}},
+		{"string range", []stow.Filter{stow.Gt("name", "B"), stow.Lte("name", "Carol")}, []string{"bob", "carol"}},
+		{"time range", []stow.Filter{stow.Gte("joined", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))}, []string{"alice", "bob", "dave"}},
+		{"contains element", []stow.Filter{stow.Contains("roles", "editor")}, []string{"alice", "dave"}},
+		{"contains substring", []stow.Filter{stow.Contains("address.city", "is")}, []string{"alice", "carol"}},
+		{"index", []stow.Filter{stow.Eq("roles[0]", "admin")}, []string{"alice"}},
+		{"has field", []stow.Filter{stow.HasField("roles[0]")}, []string{"alice", "bob", "dave"}},
+		{"no match", []stow.Filter{stow.Eq("name", "Erin")}, []string{}},
+		{"mismatched types", []stow.Filter{stow.Gt("name", 3)}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := queryKeys(t, ns, tt.filters...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Cached values match like values read from disk
+	if err := ns.RefreshAll(); err != nil {
+		t.Fatalf("RefreshAll failed: %v", err)
+	}
+	if got := queryKeys(t, ns, stow.Eq("address.city", "Lisbon")); !reflect.DeepEqual(got, []string{"alice", "carol"}) {
+		t.Errorf("Query() after refresh = %v", got)
+	}
+}
+
+func TestQueryDecode(t *testing.T) {
+	ns := openQueryUsers(t)
+
+	results, err := ns.Query(stow.Eq("name", "Alice"))
+	if err != nil || len(results) != 1 {
+		t.Fatalf("Query failed: %v %v", results, err)
+	}
+	var user queryUser
+	if err := results[0].Decode(&user); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if user.Age != 31 || user.Address.City != "Lisbon" {
+		t.Errorf("Decode() = %+v", user)
+	}
+}
+
+func TestQueryInvalidFilter(t *testing.T) {
+	ns := openQueryUsers(t)
+
+	if _, err := ns.Query(stow.Gt("age", []int{1})); !errors.Is(err, stow.ErrInvalidFilter) {
+		t.Errorf("Expected ErrInvalidFilter for a range over an array, got %v", err)
+	}
+	if _, err := ns.Query(stow.Filter{Path: "age", Op: "like"}); !errors.Is(err, stow.ErrInvalidFilter) {
+		t.Errorf("Expected ErrInvalidFilter for an unknown operation, got %v", err)
+	}
+	if _, err := ns.Query(stow.Eq("roles[", "x")); !errors.Is(err, stow.ErrInvalidPath) {
+		t.Errorf("Expected ErrInvalidPath, got %v", err)
+	}
+}
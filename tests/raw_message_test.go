@@ -0,0 +1,64 @@
+package stow_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+type webhookEvent struct {
+	Source  string          `json:"source"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+func TestRawMessageVerbatim(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.BlobThreshold = 256
+	ns, err := store.CreateNamespace("events", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	// Key order, spacing and number formatting must survive
+	small := json.RawMessage(`{"z": 1, "a": 2.50, "big": 12345678901234567890}`)
+	large := json.RawMessage(`{"items": [` + strings.Repeat(`{"b": 1.0, "a": 2}, `, 50) + `null]}`)
+
+	ns.MustPut("small", webhookEvent{Source: "stripe", Payload: small})
+	ns.MustPut("large", webhookEvent{Source: "github", Payload: large})
+
+	blobs, _ := os.ReadDir(filepath.Join(ns.Path(), "_blobs"))
+	if len(blobs) != 1 {
+		t.Errorf("expected the large payload in 1 blob, got %d", len(blobs))
+	}
+
+	for _, refresh := range []bool{false, true} {
+		if refresh {
+			ns.RefreshAll()
+		}
+
+		for key, want := range map[string]json.RawMessage{"small": small, "large": large} {
+			var event webhookEvent
+			ns.MustGet(key, &event)
+			if string(event.Payload) != string(want) {
+				t.Errorf("%s payload (refresh=%v) = %s", key, refresh, event.Payload)
+			}
+
+			var generic map[string]interface{}
+			ns.MustGet(key, &generic)
+			if raw, ok := generic["payload"].(json.RawMessage); !ok || string(raw) != string(want) {
+				t.Errorf("%s map payload (refresh=%v) = %T", key, refresh, generic["payload"])
+			}
+		}
+	}
+
+	if err := ns.Put("bad", webhookEvent{Payload: json.RawMessage(`{"unterminated"`)}); err == nil {
+		t.Error("Put with invalid raw JSON should fail")
+	}
+}
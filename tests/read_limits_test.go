@@ -0,0 +1,68 @@
+package stow_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestReadLimits(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.DisableCache = true
+	config.BlobThreshold = 1 << 20
+	config.MaxRecordDataBytes = 1024
+	ns, err := store.CreateNamespace("limits", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	ns.MustPut("small", draft{Text: "ok"})
+	ns.MustPut("big", draft{Text: "ok"})
+	ns.MustPut("big", draft{Text: strings.Repeat("x", 4096)})
+
+	var got draft
+	ns.MustGet("small", &got)
+	if got.Text != "ok" {
+		t.Errorf("Expected small value, got %q", got.Text)
+	}
+
+	// The oversized latest version fails instead of exposing version 1
+	if err := ns.Get("big", &got); !errors.Is(err, stow.ErrLimitExceeded) {
+		t.Errorf("Get: expected ErrLimitExceeded, got %v", err)
+	}
+	if _, err := ns.GetHistory("big"); !errors.Is(err, stow.ErrLimitExceeded) {
+		t.Errorf("GetHistory: expected ErrLimitExceeded, got %v", err)
+	}
+
+	// Limits are validated
+	config.MaxLineBytes = -1
+	if err := config.Validate(); !errors.Is(err, stow.ErrInvalidConfig) {
+		t.Errorf("Expected ErrInvalidConfig for negative MaxLineBytes, got %v", err)
+	}
+}
+
+func TestReadLimitsLine(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.DisableCache = true
+	config.BlobThreshold = 1 << 20
+	config.MaxLineBytes = 2048
+	ns, err := store.CreateNamespace("lines", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	ns.MustPut("doc", draft{Text: strings.Repeat("y", 8192)})
+
+	var got draft
+	if err := ns.Get("doc", &got); !errors.Is(err, stow.ErrLimitExceeded) {
+		t.Errorf("Get: expected ErrLimitExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package stow_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func newSizeLimitedNamespace(t *testing.T, policy stow.OversizePolicy) stow.Namespace {
+	t.Helper()
+
+	store := stow.MustOpen(t.TempDir())
+	t.Cleanup(func() { store.Close() })
+
+	config := stow.DefaultNamespaceConfig()
+	config.MaxInlineRecordSize = 512
+	config.OversizePolicy = policy
+
+	ns, err := store.CreateNamespace("limited", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+	return ns
+}
+
+func largeMetadata() map[string]interface{} {
+	return map[string]interface{}{
+		"title": "big record",
+		"notes": strings.Repeat("x", 2000),
+	}
+}
+
+func TestRecordSizeReject(t *testing.T) {
+	ns := newSizeLimitedNamespace(t, stow.OversizeReject)
+
+	err := ns.Put("big", largeMetadata())
+	if !errors.Is(err, stow.ErrRecordTooLarge) {
+		t.Fatalf("expected ErrRecordTooLarge, got %v", err)
+	}
+	if ns.Exists("big") {
+		t.Error("rejected record should not be stored")
+	}
+
+	// Small records are unaffected
+	if err := ns.Put("small", map[string]interface{}{"title": "ok"}); err != nil {
+		t.Errorf("small Put failed: %v", err)
+	}
+}
+
+func TestRecordSizeAutoBlob(t *testing.T) {
+	ns := newSizeLimitedNamespace(t, stow.OversizeAutoBlob)
+
+	if err := ns.Put("big", largeMetadata()); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	// The JSONL line holds only a stub
+	content, err := os.ReadFile(filepath.Join(ns.Path(), "big.jsonl"))
+	if err != nil {
+		t.Fatalf("failed to read record file: %v", err)
+	}
+	if len(content) > 512 {
+		t.Errorf("record line should be a small stub, got %d bytes", len(content))
+	}
+
+	// Reads see the full payload, from cache and from disk
+	for _, refresh := range []bool{false, true} {
+		if refresh {
+			ns.RefreshAll()
+		}
+
+		var result map[string]interface{}
+		ns.MustGet("big", &result)
+		if result["notes"] != strings.Repeat("x", 2000) {
+			t.Errorf("payload not rehydrated (refresh=%v)", refresh)
+		}
+	}
+
+	raw, err := ns.GetRaw("big")
+	if err != nil {
+		t.Fatalf("GetRaw failed: %v", err)
+	}
+	if raw.RawData()["title"] != "big record" {
+		t.Errorf("GetRaw should expose expanded data, got %v", raw.RawData())
+	}
+
+	// The payload blob survives GC while referenced
+	result, err := ns.GC()
+	if err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	if result.RemovedBlobs != 0 {
+		t.Errorf("GC removed %d referenced blobs", result.RemovedBlobs)
+	}
+	ns.RefreshAll()
+	var after map[string]interface{}
+	ns.MustGet("big", &after)
+	if after["title"] != "big record" {
+		t.Error("record unreadable after GC")
+	}
+}
+
+func TestRecordSizeTruncate(t *testing.T) {
+	ns := newSizeLimitedNamespace(t, stow.OversizeTruncate)
+
+	err := ns.Put("big", largeMetadata())
+	if !errors.Is(err, stow.ErrRecordTooLarge) || !strings.Contains(err.Error(), "notes") {
+		t.Fatalf("expected truncation error naming 'notes', got %v", err)
+	}
+
+	ns.RefreshAll()
+
+	var result map[string]interface{}
+	ns.MustGet("big", &result)
+	if _, exists := result["notes"]; exists {
+		t.Error("oversized field should have been dropped")
+	}
+	if result["title"] != "big record" {
+		t.Error("remaining fields should be stored")
+	}
+}
+
+func TestRecordSizeConfigValidation(t *testing.T) {
+	config := stow.DefaultNamespaceConfig()
+
+	config.MaxInlineRecordSize = -1
+	if err := config.Validate(); err == nil {
+		t.Error("negative MaxInlineRecordSize should be invalid")
+	}
+
+	config.MaxInlineRecordSize = 0
+	config.OversizePolicy = "explode"
+	if err := config.Validate(); err == nil {
+		t.Error("unknown OversizePolicy should be invalid")
+	}
+}
@@ -0,0 +1,169 @@
+package stow_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aigotowork/stow"
+)
+
+type profile struct {
+	Name   string `json:"name"`
+	Avatar []byte `json:"avatar"`
+}
+
+func TestRelinkBlobs(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	backup := t.TempDir()
+	config := stow.DefaultNamespaceConfig()
+	config.BlobThreshold = 16
+	config.AutoCompact = false
+	config.BlobSearchPaths = []string{backup}
+	ns, err := store.CreateNamespace("users", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	alice := bytes.Repeat([]byte("alice"), 10)
+	bob := bytes.Repeat([]byte("bob"), 10)
+	carol := bytes.Repeat([]byte("carol"), 10)
+	ns.MustPut("alice", profile{Name: "Alice", Avatar: alice})
+	ns.MustPut("alice", profile{Name: "Alice B.", Avatar: alice})
+	ns.MustPut("bob", profile{Name: "Bob", Avatar: bob})
+	ns.MustPut("carol", profile{Name: "Carol", Avatar: carol})
+
+	location := func(key string) string {
+		item, err := ns.GetRaw(key)
+		if err != nil {
+			t.Fatalf("GetRaw failed: %v", err)
+		}
+		return item.RawData()["avatar"].(map[string]interface{})["loc"].(string)
+	}
+	blobPath := func(key string) string {
+		return filepath.Join(ns.Path(), location(key))
+	}
+
+	// alice's blob is moved into a subdirectory, bob's only survives in the
+	// backup directory, carol's is gone
+	archive := filepath.Join(ns.Path(), "_blobs", "2024")
+	os.MkdirAll(archive, 0755)
+	if err := os.Rename(blobPath("alice"), filepath.Join(archive, "renamed.bin")); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if err := os.Rename(blobPath("bob"), filepath.Join(backup, "bob.bin")); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	carolLocation := location("carol")
+	if err := os.Remove(blobPath("carol")); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+
+	result, err := ns.RelinkBlobs()
+	if err != nil {
+		t.Fatalf("RelinkBlobs failed: %v", err)
+	}
+	// alice's blob (shared by both versions) and bob's
+	if result.Relinked != 2 {
+		t.Errorf("Relinked = %d, want 2", result.Relinked)
+	}
+	if got := result.Unresolved["carol"]; len(got) != 1 || got[0] != carolLocation {
+		t.Errorf("Unresolved = %v", result.Unresolved)
+	}
+
+	// Relinked blobs are referenced again, so GC keeps them
+	if _, err := ns.GC(); err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	ns.RefreshAll()
+
+	for key, want := range map[string][]byte{"alice": alice, "bob": bob} {
+		var p profile
+		ns.MustGet(key, &p)
+		if !bytes.Equal(p.Avatar, want) {
+			t.Errorf("%s avatar = %d bytes after relink", key, len(p.Avatar))
+		}
+	}
+	var first profile
+	if err := ns.GetVersion("alice", 1, &first); err != nil || !bytes.Equal(first.Avatar, alice) {
+		t.Errorf("alice v1 avatar not relinked: %v", err)
+	}
+
+	// The backup copy is left in place
+	if _, err := os.Stat(filepath.Join(backup, "bob.bin")); err != nil {
+		t.Errorf("backup file should be kept: %v", err)
+	}
+
+	// A second run has nothing left to do
+	again, err := ns.RelinkBlobs()
+	if err != nil || again.Relinked != 0 {
+		t.Errorf("second RelinkBlobs = %+v, %v", again, err)
+	}
+}
+
+func TestRelinkBlobsConcurrentPuts(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.BlobThreshold = 16
+	config.AutoCompact = false
+	ns, err := store.CreateNamespace("users", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+	ns.MustPut("alice", profile{Name: "Alice", Avatar: bytes.Repeat([]byte("alice"), 10)})
+
+	// A copy in a subdirectory lets every round relink alice's first
+	// version to the blob in place
+	blobs, _ := filepath.Glob(filepath.Join(ns.Path(), "_blobs", "*.bin"))
+	if len(blobs) != 1 {
+		t.Fatalf("expected one blob, got %v", blobs)
+	}
+	content, _ := os.ReadFile(blobs[0])
+	os.MkdirAll(filepath.Join(ns.Path(), "_blobs", "copies"), 0755)
+	os.WriteFile(filepath.Join(ns.Path(), "_blobs", "copies", "avatar.bin"), content, 0644)
+	location := []byte(`"loc":"_blobs/` + filepath.Base(blobs[0]) + `"`)
+	keyFile := filepath.Join(ns.Path(), "alice.jsonl")
+
+	// Every round breaks the reference, then relinks it while alice is
+	// being written; long records keep the writes in flight
+	name := strings.Repeat("Alice ", 1000)
+	puts := 0
+	for i := 0; i < 30; i++ {
+		data, _ := os.ReadFile(keyFile)
+		missing := []byte(fmt.Sprintf(`"loc":"_blobs/missing%d.bin"`, i))
+		os.WriteFile(keyFile, bytes.Replace(data, location, missing, 1), 0644)
+
+		var writers sync.WaitGroup
+		for w := 0; w < 2; w++ {
+			writers.Add(1)
+			go func() {
+				defer writers.Done()
+				for n := 0; n < 5; n++ {
+					if err := ns.Put("alice", profile{Name: name}); err != nil {
+						t.Errorf("Put failed: %v", err)
+					}
+				}
+			}()
+		}
+		time.Sleep(200 * time.Microsecond)
+		result, err := ns.RelinkBlobs()
+		writers.Wait()
+		if err != nil || result.Relinked != 1 {
+			t.Fatalf("RelinkBlobs = %+v, %v", result, err)
+		}
+		puts += 10
+	}
+
+	if history, err := ns.GetHistory("alice"); err != nil || len(history) != puts+1 {
+		t.Errorf("expected %d versions of alice, got %d (%v)", puts+1, len(history), err)
+	}
+}
@@ -0,0 +1,116 @@
+package stow_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestRelocateStore(t *testing.T) {
+	root := t.TempDir()
+	oldDir := filepath.Join(root, "old", "app")
+	newDir := filepath.Join(root, "new", "app")
+
+	store := stow.MustOpen(oldDir)
+
+	// Blobs in an absolute directory inside the store
+	config := stow.DefaultNamespaceConfig()
+	config.BlobDir = filepath.Join(oldDir, ".blobs", "media")
+	config.BlobSearchPaths = []string{filepath.Join(oldDir, ".blobs", "sorted")}
+	media, err := store.CreateNamespace("media", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+	photo := []byte(strings.Repeat("p", 8*1024))
+	media.MustPut("photo", map[string]interface{}{"data": photo})
+
+	// Blobs next to the store, by a relative path
+	config = stow.DefaultNamespaceConfig()
+	config.BlobDir = filepath.Join("..", "..", "shared", "files")
+	files, err := store.CreateNamespace("files", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+	doc := []byte(strings.Repeat("d", 8*1024))
+	files.MustPut("doc", map[string]interface{}{"data": doc})
+
+	store.MustGetNamespace("users").MustPut("user:1", map[string]interface{}{"name": "Alice"})
+
+	// Not while the store is open
+	if err := stow.RelocateStore(oldDir, newDir); !errors.Is(err, stow.ErrStoreLocked) {
+		t.Fatalf("RelocateStore of an open store: %v, want ErrStoreLocked", err)
+	}
+	store.Close()
+
+	if err := stow.RelocateStore(oldDir, newDir); err != nil {
+		t.Fatalf("RelocateStore failed: %v", err)
+	}
+	if _, err := os.Stat(oldDir); !os.IsNotExist(err) {
+		t.Errorf("old store still exists: %v", err)
+	}
+
+	store = stow.MustOpen(newDir)
+	defer store.Close()
+
+	for _, tc := range []struct {
+		ns, key string
+		want    []byte
+	}{
+		{"media", "photo", photo},
+		{"files", "doc", doc},
+	} {
+		var got map[string]interface{}
+		if err := store.MustGetNamespace(tc.ns).Get(tc.key, &got); err != nil {
+			t.Fatalf("%s/%s: Get failed: %v", tc.ns, tc.key, err)
+		}
+		if data, ok := got["data"].([]byte); !ok || len(data) != len(tc.want) {
+			t.Errorf("%s/%s: got %T with %d bytes", tc.ns, tc.key, got["data"], len(data))
+		}
+	}
+
+	mediaConfig := store.MustGetNamespace("media").GetConfig()
+	if want := filepath.Join(newDir, ".blobs", "media"); mediaConfig.BlobDir != want {
+		t.Errorf("media BlobDir = %s, want %s", mediaConfig.BlobDir, want)
+	}
+	if want := filepath.Join(newDir, ".blobs", "sorted"); len(mediaConfig.BlobSearchPaths) != 1 || mediaConfig.BlobSearchPaths[0] != want {
+		t.Errorf("media BlobSearchPaths = %v, want [%s]", mediaConfig.BlobSearchPaths, want)
+	}
+
+	// The shared directory didn't move, so its path is pinned
+	if got, want := store.MustGetNamespace("files").GetConfig().BlobDir, filepath.Join(root, "old", "shared", "files"); got != want {
+		t.Errorf("files BlobDir = %s, want %s", got, want)
+	}
+}
+
+func TestRelocateStoreErrors(t *testing.T) {
+	root := t.TempDir()
+	oldDir := filepath.Join(root, "app")
+	store := stow.MustOpen(oldDir)
+	store.MustGetNamespace("users").MustPut("user:1", map[string]interface{}{"name": "Alice"})
+	store.Close()
+
+	if err := stow.RelocateStore(filepath.Join(root, "missing"), filepath.Join(root, "other")); err == nil {
+		t.Error("RelocateStore of a missing store succeeded")
+	}
+
+	existing := filepath.Join(root, "existing")
+	os.Mkdir(existing, 0755)
+	if err := stow.RelocateStore(oldDir, existing); !errors.Is(err, os.ErrExist) {
+		t.Errorf("RelocateStore onto an existing directory: %v, want os.ErrExist", err)
+	}
+
+	if err := stow.RelocateStore(oldDir, filepath.Join(oldDir, "nested")); err == nil {
+		t.Error("RelocateStore into itself succeeded")
+	}
+
+	// Nothing moved
+	store = stow.MustOpen(oldDir)
+	defer store.Close()
+	if !store.MustGetNamespace("users").Exists("user:1") {
+		t.Error("user:1 is gone")
+	}
+}
@@ -0,0 +1,128 @@
+package stow_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestRename(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.HashChain = true
+	ns, err := store.CreateNamespace("docs", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	ns.MustPut("draft:1", draft{Text: "first"})
+	ns.MustPut("draft:1", draft{Text: "second"})
+	if err := ns.PinVersion("draft:1", 1); err != nil {
+		t.Fatalf("PinVersion failed: %v", err)
+	}
+	if err := ns.Tag("draft:1", "review"); err != nil {
+		t.Fatalf("Tag failed: %v", err)
+	}
+
+	if err := ns.Rename("draft:1", "doc:1"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+
+	var got draft
+	ns.MustGet("doc:1", &got)
+	if got.Text != "second" {
+		t.Errorf("Expected current value under the new key, got %q", got.Text)
+	}
+	if ns.Exists("draft:1") {
+		t.Error("Old key should be deleted")
+	}
+
+	// The new key carries the history and a rename marker
+	history, err := ns.GetHistory("doc:1")
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if len(history) != 3 || history[0].Version != 3 || history[0].RenamedFrom != "draft:1" {
+		t.Fatalf("Unexpected history: %+v", history)
+	}
+	if err := history[2].Decode(&got); err != nil || got.Text != "first" {
+		t.Errorf("Expected version 1 to be carried over, got %q (%v)", got.Text, err)
+	}
+	if _, err := ns.VerifyChain("doc:1"); err != nil {
+		t.Errorf("VerifyChain of renamed key failed: %v", err)
+	}
+
+	// The old key's history ends with the rename
+	old, err := ns.GetHistory("draft:1")
+	if err != nil {
+		t.Fatalf("GetHistory of old key failed: %v", err)
+	}
+	if len(old) != 3 || old[0].Operation != "delete" || old[0].RenamedTo != "doc:1" || old[0].Reason != "rename" {
+		t.Errorf("Unexpected old history: %+v", old)
+	}
+
+	if pins, _ := ns.Pins("doc:1"); len(pins) != 1 || pins[0] != 1 {
+		t.Errorf("Expected pin to move, got %v", pins)
+	}
+	if pins, _ := ns.Pins("draft:1"); len(pins) != 0 {
+		t.Errorf("Expected no pins on old key, got %v", pins)
+	}
+	if tags, _ := ns.Tags("doc:1"); len(tags) != 1 || tags[0] != "review" {
+		t.Errorf("Expected tag to move, got %v", tags)
+	}
+}
+
+func TestRenameBlobs(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	ns := store.MustGetNamespace("files")
+	content := bytes.Repeat([]byte("b"), 8*1024)
+	ns.MustPut("upload", map[string]interface{}{"file": content})
+
+	if err := ns.Rename("upload", "archive/upload"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if _, err := ns.GC(); err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+
+	var got struct {
+		File stow.IFileData `json:"file"`
+	}
+	ns.MustGet("archive/upload", &got)
+	defer got.File.Close()
+	data, err := io.ReadAll(got.File)
+	if err != nil || !bytes.Equal(data, content) {
+		t.Errorf("Blob not carried over: %d bytes, %v", len(data), err)
+	}
+}
+
+func TestRenameErrors(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	ns := store.MustGetNamespace("docs")
+	ns.MustPut("a", draft{Text: "a"})
+	ns.MustPut("b", draft{Text: "b"})
+	ns.MustPut("gone", draft{Text: "gone"})
+	ns.MustDelete("gone")
+
+	if err := ns.Rename("a", "b"); !errors.Is(err, stow.ErrKeyConflict) {
+		t.Errorf("Expected ErrKeyConflict, got %v", err)
+	}
+	if err := ns.Rename("missing", "c"); !errors.Is(err, stow.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing key, got %v", err)
+	}
+	if err := ns.Rename("gone", "c"); !errors.Is(err, stow.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a deleted key, got %v", err)
+	}
+	if err := ns.Rename("a", "a"); !errors.Is(err, stow.ErrKeyConflict) {
+		t.Errorf("Expected ErrKeyConflict renaming to itself, got %v", err)
+	}
+}
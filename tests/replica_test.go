@@ -0,0 +1,121 @@
+package stow_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aigotowork/stow"
+)
+
+// eventually retries cond until it holds or the deadline passes.
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestReadReplica(t *testing.T) {
+	dir := t.TempDir()
+
+	writer := stow.MustOpen(dir)
+	defer writer.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.AutoCompact = false
+	wns, err := writer.CreateNamespace("users", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+	wns.MustPut("alice", map[string]interface{}{"age": 30})
+
+	replica := stow.MustOpen(dir, stow.WithStoreReadOnly(), stow.WithStoreReplicaPollInterval(5*time.Millisecond))
+	defer replica.Close()
+
+	rns := replica.MustGetNamespace("users")
+
+	age := func(key string) float64 {
+		var user map[string]interface{}
+		if err := rns.Get(key, &user); err != nil {
+			return -1
+		}
+		return user["age"].(float64)
+	}
+
+	// Cached value is invalidated by the writer's update
+	if got := age("alice"); got != 30 {
+		t.Fatalf("alice age = %v, want 30", got)
+	}
+	wns.MustPut("alice", map[string]interface{}{"age": 31})
+	eventually(t, "updated value", func() bool { return age("alice") == 31 })
+
+	// Keys created and deleted by the writer show up
+	wns.MustPut("bob", map[string]interface{}{"age": 40})
+	eventually(t, "new key", func() bool { return age("bob") == 40 })
+
+	wns.MustDelete("alice")
+	eventually(t, "deleted key", func() bool { return !rns.Exists("alice") })
+
+	// Config changes are reloaded
+	config.CompactKeepRecords = 7
+	if err := wns.SetConfig(config); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+	eventually(t, "config reload", func() bool { return rns.GetConfig().CompactKeepRecords == 7 })
+
+	// Replicas never write
+	if err := rns.Put("carol", map[string]interface{}{"age": 1}); !errors.Is(err, stow.ErrReadOnly) {
+		t.Errorf("Put: expected ErrReadOnly, got %v", err)
+	}
+	if err := rns.Delete("bob"); !errors.Is(err, stow.ErrReadOnly) {
+		t.Errorf("Delete: expected ErrReadOnly, got %v", err)
+	}
+	if _, err := rns.GC(); !errors.Is(err, stow.ErrReadOnly) {
+		t.Errorf("GC: expected ErrReadOnly, got %v", err)
+	}
+	if _, err := replica.CreateNamespace("other", stow.DefaultNamespaceConfig()); !errors.Is(err, stow.ErrReadOnly) {
+		t.Errorf("CreateNamespace: expected ErrReadOnly, got %v", err)
+	}
+	if _, err := replica.GetNamespace("missing"); !errors.Is(err, stow.ErrNamespaceNotFound) {
+		t.Errorf("GetNamespace: expected ErrNamespaceNotFound, got %v", err)
+	}
+
+	// Dropped namespaces disappear from the replica
+	if err := writer.DeleteNamespace("users"); err != nil {
+		t.Fatalf("DeleteNamespace failed: %v", err)
+	}
+	eventually(t, "dropped namespace", func() bool {
+		_, err := replica.GetNamespace("users")
+		return errors.Is(err, stow.ErrNamespaceNotFound)
+	})
+}
+
+func TestSingleWriter(t *testing.T) {
+	dir := t.TempDir()
+
+	writer := stow.MustOpen(dir)
+
+	if _, err := stow.Open(dir); !errors.Is(err, stow.ErrStoreLocked) {
+		t.Errorf("second writer: expected ErrStoreLocked, got %v", err)
+	}
+
+	// Any number of replicas may open alongside the writer
+	replica, err := stow.Open(dir, stow.WithStoreReadOnly())
+	if err != nil {
+		t.Fatalf("replica Open failed: %v", err)
+	}
+	replica.Close()
+
+	writer.Close()
+
+	reopened, err := stow.Open(dir)
+	if err != nil {
+		t.Fatalf("Open after Close failed: %v", err)
+	}
+	reopened.Close()
+}
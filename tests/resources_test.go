@@ -0,0 +1,94 @@
+package stow_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestResourcesFileLimit(t *testing.T) {
+	store, err := stow.Open(t.TempDir(), stow.WithStoreMaxOpenFiles(4))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	ns := store.MustGetNamespace("items")
+	for i := 0; i < 20; i++ {
+		ns.MustPut(fmt.Sprintf("k%d", i), map[string]interface{}{"n": i})
+	}
+
+	// Bypass the cache so every Get reads its key file
+	store.CloseNamespace("items")
+	ns = store.MustGetNamespace("items")
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				var v map[string]interface{}
+				if err := ns.Get(fmt.Sprintf("k%d", i), &v); err != nil {
+					t.Errorf("Get failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	usage := store.Resources()
+	if usage.MaxOpenFiles != 4 {
+		t.Errorf("MaxOpenFiles = %d, want 4", usage.MaxOpenFiles)
+	}
+	if usage.OpenFiles > 4 {
+		t.Errorf("OpenFiles = %d, over the limit of 4", usage.OpenFiles)
+	}
+	if usage.IdleFiles != usage.OpenFiles {
+		t.Errorf("IdleFiles = %d, want all %d open files idle", usage.IdleFiles, usage.OpenFiles)
+	}
+
+	store.CloseNamespace("items")
+	if usage := store.Resources(); usage.OpenFiles != 0 {
+		t.Errorf("OpenFiles = %d after CloseNamespace, want 0", usage.OpenFiles)
+	}
+}
+
+func TestResourcesGoroutineLimit(t *testing.T) {
+	store, err := stow.Open(t.TempDir(), stow.WithStoreMaxGoroutines(1))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer store.Close()
+
+	w, err := store.WatchChanges(0)
+	if err != nil {
+		t.Fatalf("WatchChanges failed: %v", err)
+	}
+	if usage := store.Resources(); usage.Goroutines != 1 || usage.MaxGoroutines != 1 {
+		t.Errorf("usage = %+v, want 1 of 1 goroutines", usage)
+	}
+
+	ns := store.MustGetNamespace("items")
+	if _, err := ns.WatchExternalChanges(); !errors.Is(err, stow.ErrGoroutineLimit) {
+		t.Errorf("expected ErrGoroutineLimit, got %v", err)
+	}
+
+	// Writes still work; auto compaction just waits for a free slot
+	ns.MustPut("a", 1)
+
+	w.Close()
+	if usage := store.Resources(); usage.Goroutines != 0 {
+		t.Errorf("Goroutines = %d after Close, want 0", usage.Goroutines)
+	}
+
+	w2, err := ns.WatchExternalChanges()
+	if err != nil {
+		t.Fatalf("WatchExternalChanges after a slot freed up failed: %v", err)
+	}
+	w2.Close()
+}
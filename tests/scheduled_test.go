@@ -0,0 +1,126 @@
+package stow_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestScheduledPut(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.AutoCompact = false
+	config.CompactKeepRecords = 1
+	ns, err := store.CreateNamespace("config", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	ns.MustPut("pricing", map[string]interface{}{"plan": "old"})
+
+	activation := time.Now().Add(200 * time.Millisecond)
+	ns.MustPut("pricing", map[string]interface{}{"plan": "new"}, stow.WithVisibleAt(activation))
+	ns.MustPut("launch", map[string]interface{}{"title": "embargoed"}, stow.WithVisibleAt(activation))
+
+	plan := func() string {
+		var value map[string]interface{}
+		if err := ns.Get("pricing", &value); err != nil {
+			return err.Error()
+		}
+		return value["plan"].(string)
+	}
+
+	// Compaction keeps the record readers still see
+	if err := ns.Compact("pricing"); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+
+	if got := plan(); got != "old" {
+		t.Errorf("before activation plan = %q, want old", got)
+	}
+	if err := ns.Get("launch", new(map[string]interface{})); !errors.Is(err, stow.ErrNotFound) {
+		t.Errorf("scheduled new key: expected ErrNotFound, got %v", err)
+	}
+	keys, _ := ns.List()
+	if len(keys) != 1 || keys[0] != "pricing" {
+		t.Errorf("List before activation = %v", keys)
+	}
+
+	history, err := ns.GetHistory("pricing")
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if history[0].VisibleAt.IsZero() || !history[1].VisibleAt.IsZero() {
+		t.Errorf("history VisibleAt = %v, %v", history[0].VisibleAt, history[1].VisibleAt)
+	}
+
+	time.Sleep(time.Until(activation) + 10*time.Millisecond)
+
+	if got := plan(); got != "new" {
+		t.Errorf("after activation plan = %q, want new", got)
+	}
+	if !ns.Exists("launch") {
+		t.Error("launch should exist after activation")
+	}
+}
+
+func TestScheduledPutSuperseded(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	ns := store.MustGetNamespace("config")
+	ns.MustPut("flag", map[string]interface{}{"on": false})
+	ns.MustPut("flag", map[string]interface{}{"on": true}, stow.WithVisibleAt(time.Now().Add(50*time.Millisecond)))
+
+	// A later immediate write wins, before and after the scheduled time
+	ns.MustPut("flag", map[string]interface{}{"on": false, "note": "rollback"})
+	time.Sleep(60 * time.Millisecond)
+
+	var value map[string]interface{}
+	ns.MustGet("flag", &value)
+	if value["note"] != "rollback" {
+		t.Errorf("flag = %v, want the rollback", value)
+	}
+}
+
+func TestScheduledPutKeepsBlobs(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	ns := store.MustGetNamespace("files")
+	ns.MustPut("logo", map[string]interface{}{"data": []byte("current logo")}, stow.WithForceFile())
+
+	activation := time.Now().Add(200 * time.Millisecond)
+	ns.MustPut("logo", map[string]interface{}{"data": []byte("next logo")}, stow.WithForceFile(), stow.WithVisibleAt(activation))
+
+	// GC keeps the blobs readers see now and the scheduled ones
+	result, err := ns.GC()
+	if err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	if result.RemovedBlobs != 0 {
+		t.Errorf("GC removed %d blobs, want 0", result.RemovedBlobs)
+	}
+
+	data := func(key string) string {
+		var value struct {
+			Data []byte `json:"data"`
+		}
+		if err := ns.Get(key, &value); err != nil {
+			return err.Error()
+		}
+		return string(value.Data)
+	}
+	if got := data("logo"); got != "current logo" {
+		t.Errorf("before activation logo = %q", got)
+	}
+
+	time.Sleep(time.Until(activation) + 10*time.Millisecond)
+	if got := data("logo"); got != "next logo" {
+		t.Errorf("after activation logo = %q", got)
+	}
+}
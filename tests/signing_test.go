@@ -0,0 +1,212 @@
+package stow_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func signedConfig(algo stow.SigningAlgorithm, key []byte) stow.NamespaceConfig {
+	config := stow.DefaultNamespaceConfig()
+	config.Signing = stow.SigningConfig{Algorithm: algo, Key: key}
+	return config
+}
+
+func TestSigningDetectsTampering(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := stow.MustOpen(tmpDir)
+	defer store.Close()
+
+	ns, err := store.CreateNamespace("audit", signedConfig(stow.SigningHMACSHA256, []byte("secret")))
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	ns.MustPut("entry", map[string]interface{}{"user": "alice", "amount": 100})
+	ns.MustPut("entry", map[string]interface{}{"user": "alice", "amount": 200})
+	ns.MustPut("other", map[string]interface{}{"user": "bob", "amount": 5})
+
+	result, err := ns.Verify()
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !result.OK() || result.Verified != 3 {
+		t.Fatalf("Expected 3 verified records, got %+v", result)
+	}
+
+	// Compaction keeps the signatures valid
+	if err := ns.Compact("entry"); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if result, _ := ns.Verify(); !result.OK() {
+		t.Errorf("Expected records to verify after compaction, got %+v", result)
+	}
+
+	// Edit the first record by hand
+	filePath := filepath.Join(tmpDir, "audit", "entry.jsonl")
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	content = bytes.Replace(content, []byte(`"amount":100`), []byte(`"amount":1`), 1)
+	if err := os.WriteFile(filePath, content, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	result, err = ns.Verify()
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if got := result.Invalid["entry"]; len(got) != 1 || got[0] != 1 {
+		t.Errorf("Expected entry v1 to be invalid, got %+v", result)
+	}
+	if result.Verified != 2 {
+		t.Errorf("Expected 2 verified records, got %d", result.Verified)
+	}
+}
+
+func TestSigningRewritesKeepTampering(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := stow.MustOpen(tmpDir)
+	defer store.Close()
+
+	ns, err := store.CreateNamespace("audit", signedConfig(stow.SigningHMACSHA256, []byte("secret")))
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+	ns.MustPut("entry", map[string]interface{}{"user": "alice", "amount": 100})
+	ns.MustPut("entry", map[string]interface{}{"user": "alice", "amount": 200})
+
+	// Edit the first record by hand and strip its signature
+	filePath := filepath.Join(tmpDir, "audit", "entry.jsonl")
+	content, _ := os.ReadFile(filePath)
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	lines[0] = regexp.MustCompile(`,"sig":"[^"]*"`).ReplaceAll(lines[0], nil)
+	lines[0] = bytes.Replace(lines[0], []byte(`"amount":100`), []byte(`"amount":999999`), 1)
+	if err := os.WriteFile(filePath, bytes.Join(lines, nil), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	// Rewriting the file doesn't sign the edited record
+	if err := ns.Compact("entry"); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+	if result, _ := ns.Verify(); len(result.Unsigned["entry"]) != 1 || result.Verified != 1 {
+		t.Errorf("Expected entry v1 unsigned after compaction, got %+v", result)
+	}
+
+	// Neither does renaming it, which copies every record to the new key
+	if err := ns.Rename("entry", "moved"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if result, _ := ns.Verify(); len(result.Unsigned["moved"]) != 1 || len(result.Unsigned["entry"]) != 1 {
+		t.Errorf("Expected moved v1 unsigned after rename, got %+v", result)
+	}
+}
+
+func TestSigningReportsUnsignedRecords(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	ns := store.MustGetNamespace("audit")
+	ns.MustPut("before", map[string]interface{}{"v": 1})
+
+	if _, err := ns.Verify(); !errors.Is(err, stow.ErrInvalidConfig) {
+		t.Errorf("Verify of unsigned namespace = %v, want ErrInvalidConfig", err)
+	}
+
+	config := ns.GetConfig()
+	config.Signing = stow.SigningConfig{Algorithm: stow.SigningHMACSHA256, Key: []byte("secret")}
+	if err := ns.SetConfig(config); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+	ns.MustPut("after", map[string]interface{}{"v": 2})
+
+	result, err := ns.Verify()
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if len(result.Unsigned["before"]) != 1 || result.Verified != 1 {
+		t.Errorf("Expected before to be unsigned and after verified, got %+v", result)
+	}
+}
+
+func TestSigningEd25519Reopen(t *testing.T) {
+	tmpDir := t.TempDir()
+	seed := bytes.Repeat([]byte{3}, 32)
+
+	store := stow.MustOpen(tmpDir)
+	ns, err := store.CreateNamespace("audit", signedConfig(stow.SigningEd25519, seed))
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+	ns.MustPut("entry", map[string]interface{}{"v": 1})
+	store.Close()
+
+	// Only the public key is persisted
+	configData, err := os.ReadFile(filepath.Join(tmpDir, "audit", "_config.json"))
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if bytes.Contains(configData, []byte(base64.StdEncoding.EncodeToString(seed))) {
+		t.Error("signing key was persisted")
+	}
+	if !bytes.Contains(configData, []byte(`"public_key"`)) {
+		t.Errorf("public key was not persisted: %s", configData)
+	}
+
+	// Without the private key records still verify, but can't be written
+	store = stow.MustOpen(tmpDir)
+	ns = store.MustGetNamespace("audit")
+	if result, err := ns.Verify(); err != nil || !result.OK() || result.Verified != 1 {
+		t.Errorf("Verify without private key = %+v, %v", result, err)
+	}
+	if err := ns.Put("entry", map[string]interface{}{"v": 2}); !errors.Is(err, stow.ErrSigningKeyRequired) {
+		t.Errorf("Put without signing key = %v, want ErrSigningKeyRequired", err)
+	}
+	store.Close()
+
+	// A different key is refused
+	store = stow.MustOpen(tmpDir, stow.WithStoreSigningKey("audit", bytes.Repeat([]byte{4}, 32)))
+	if _, err := store.GetNamespace("audit"); !errors.Is(err, stow.ErrInvalidConfig) {
+		t.Errorf("GetNamespace with wrong key = %v, want ErrInvalidConfig", err)
+	}
+	store.Close()
+
+	store = stow.MustOpen(tmpDir, stow.WithStoreSigningKey("audit", seed))
+	defer store.Close()
+	ns = store.MustGetNamespace("audit")
+	ns.MustPut("entry", map[string]interface{}{"v": 2})
+	if result, err := ns.Verify(); err != nil || !result.OK() || result.Verified != 2 {
+		t.Errorf("Verify = %+v, %v", result, err)
+	}
+}
+
+func TestSigningEncryptedNamespace(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	config := signedConfig(stow.SigningHMACSHA256, []byte("secret")).WithKey(bytes.Repeat([]byte{1}, 32))
+	ns, err := store.CreateNamespace("audit", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		ns.MustPut("entry", map[string]interface{}{"v": i})
+	}
+	// Compaction re-seals the data with fresh nonces
+	if err := ns.Compact("entry"); err != nil {
+		t.Fatalf("Compact failed: %v", err)
+	}
+
+	if result, err := ns.Verify(); err != nil || !result.OK() || result.Verified != 3 {
+		t.Errorf("Verify = %+v, %v", result, err)
+	}
+}
@@ -0,0 +1,94 @@
+package stow_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aigotowork/stow"
+)
+
+type syncedUser struct {
+	Name   string `json:"name"`
+	Age    int    `json:"age"`
+	Avatar []byte `json:"avatar,omitempty"`
+}
+
+func TestSkipIfUnchanged(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	ns := store.MustGetNamespace("users")
+
+	avatar := []byte(strings.Repeat("x", 8*1024))
+	user := syncedUser{Name: "Alice", Age: 30, Avatar: avatar}
+
+	var version int
+	if err := ns.Put("user:1", user, stow.WithSkipIfUnchanged(), stow.WithWrittenVersion(&version)); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if version != 1 {
+		t.Errorf("first Put wrote version %d, want 1", version)
+	}
+
+	// Re-writing the same value keeps the existing version
+	for i := 0; i < 3; i++ {
+		if err := ns.Put("user:1", user, stow.WithSkipIfUnchanged(), stow.WithWrittenVersion(&version)); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if version != 1 {
+		t.Errorf("unchanged Put reported version %d, want 1", version)
+	}
+	history, _ := ns.GetHistory("user:1")
+	if len(history) != 1 {
+		t.Errorf("got %d versions, want 1", len(history))
+	}
+
+	// A change appends as usual
+	user.Age = 31
+	ns.MustPut("user:1", user, stow.WithSkipIfUnchanged(), stow.WithWrittenVersion(&version))
+	if version != 2 {
+		t.Errorf("changed Put wrote version %d, want 2", version)
+	}
+
+	// Without the option every Put appends
+	ns.MustPut("user:1", user)
+	if history, _ := ns.GetHistory("user:1"); len(history) != 3 {
+		t.Errorf("got %d versions, want 3", len(history))
+	}
+
+	// A deleted key is written again
+	ns.MustDelete("user:1")
+	ns.MustPut("user:1", user, stow.WithSkipIfUnchanged(), stow.WithWrittenVersion(&version))
+	if version != 5 {
+		t.Errorf("Put after delete wrote version %d, want 5", version)
+	}
+}
+
+func TestSkipUnchangedConfig(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.SkipUnchanged = true
+	ns, err := store.CreateNamespace("sync", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	// Decoded numbers compare equal to the ints written
+	for i := 0; i < 5; i++ {
+		ns.MustPut("row", map[string]interface{}{"id": 7, "tags": []string{"a", "b"}})
+	}
+	if history, _ := ns.GetHistory("row"); len(history) != 1 {
+		t.Errorf("got %d versions, want 1", len(history))
+	}
+
+	// Scheduled Puts always append, and later Puts don't skip past them
+	ns.MustPut("row", map[string]interface{}{"id": 7, "tags": []string{"a", "b"}},
+		stow.WithVisibleAt(time.Now().Add(time.Hour)))
+	ns.MustPut("row", map[string]interface{}{"id": 7, "tags": []string{"a", "b"}})
+	if history, _ := ns.GetHistory("row"); len(history) != 3 {
+		t.Errorf("got %d versions, want 3", len(history))
+	}
+}
@@ -0,0 +1,72 @@
+package stow_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestStatsHistory(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now()
+
+	// Keep about 5 snapshots
+	store := stow.MustOpen(dir, stow.WithStoreStatsHistory(10*time.Millisecond, 50*time.Millisecond))
+	ns := store.MustGetNamespace("orders")
+	ns.MustPut("order-1", map[string]interface{}{"total": 10})
+	ns.MustPut("order-2", map[string]interface{}{"total": 20})
+	time.Sleep(250 * time.Millisecond)
+
+	names, err := store.ListNamespaces()
+	if err != nil {
+		t.Fatalf("ListNamespaces failed: %v", err)
+	}
+	if len(names) != 1 || names[0] != "orders" {
+		t.Errorf("ListNamespaces = %v, want [orders]", names)
+	}
+	if _, err := store.GetNamespace("_stats"); !errors.Is(err, stow.ErrNamespaceReserved) {
+		t.Errorf("GetNamespace(_stats): expected ErrNamespaceReserved, got %v", err)
+	}
+	store.Close()
+
+	// History survives reopening without recording
+	store = stow.MustOpen(dir)
+	defer store.Close()
+
+	history, err := store.StatsHistory(start)
+	if err != nil {
+		t.Fatalf("StatsHistory failed: %v", err)
+	}
+	if len(history) == 0 || len(history) > 10 {
+		t.Fatalf("history has %d snapshots, want 1 to 10 after retention", len(history))
+	}
+	for i := 1; i < len(history); i++ {
+		if history[i].Time.Before(history[i-1].Time) {
+			t.Fatalf("snapshots out of order: %v before %v", history[i-1].Time, history[i].Time)
+		}
+	}
+
+	latest := history[len(history)-1]
+	if got := latest.Namespaces["orders"].KeyCount; got != 2 {
+		t.Errorf("latest orders key count = %d, want 2", got)
+	}
+	if _, ok := latest.Namespaces["_stats"]; ok {
+		t.Error("snapshots should not include the _stats namespace")
+	}
+
+	if recent, _ := store.StatsHistory(time.Now()); len(recent) != 0 {
+		t.Errorf("StatsHistory(now) = %d snapshots, want 0", len(recent))
+	}
+}
+
+func TestStatsHistoryWithoutRecording(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	history, err := store.StatsHistory(time.Time{})
+	if err != nil || len(history) != 0 {
+		t.Errorf("StatsHistory = %v, %v; want none", history, err)
+	}
+}
@@ -0,0 +1,97 @@
+package stow_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+type supportTicket struct {
+	Title      string `json:"title"`
+	Screenshot []byte `json:"screenshot" stow:"file,name:screen.png"`
+	Log        []byte `json:"log" stow:"file,name:log.txt"`
+}
+
+func TestStreamBlobs(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	ns := store.MustGetNamespace("tickets")
+
+	png1, log1, png2 := strings.Repeat("1", 5000), "short log", strings.Repeat("2", 5000)
+	// Tagged files are blobs whatever their size
+	ns.MustPut("ticket:1", supportTicket{Title: "Crash", Screenshot: []byte(png1), Log: []byte(log1)})
+	ns.MustPut("ticket:2", supportTicket{Title: "Typo", Screenshot: []byte(png2)})
+
+	var buf bytes.Buffer
+	if err := ns.StreamBlobs([]string{"ticket:1", "ticket:2"}, &buf, stow.ArchiveZip); err != nil {
+		t.Fatalf("StreamBlobs failed: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("invalid zip: %v", err)
+	}
+	got := make(map[string]string)
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		got[f.Name] = string(content)
+	}
+	want := map[string]string{
+		"ticket_1/log.txt":    log1,
+		"ticket_1/screen.png": png1,
+		"ticket_2/screen.png": png2,
+	}
+	if len(got) != len(want) {
+		var names []string
+		for name := range got {
+			names = append(names, name)
+		}
+		t.Fatalf("zip entries = %v, want %d", names, len(want))
+	}
+	for name, content := range want {
+		if got[name] != content {
+			t.Errorf("%s has %d bytes, want %d", name, len(got[name]), len(content))
+		}
+	}
+
+	buf.Reset()
+	if err := ns.StreamBlobs([]string{"ticket:2"}, &buf, stow.ArchiveTar); err != nil {
+		t.Fatalf("StreamBlobs failed: %v", err)
+	}
+	tr := tar.NewReader(&buf)
+	header, err := tr.Next()
+	if err != nil {
+		t.Fatalf("invalid tar: %v", err)
+	}
+	content, _ := io.ReadAll(tr)
+	if header.Name != "ticket_2/screen.png" || string(content) != png2 {
+		t.Errorf("tar entry %s has %d bytes", header.Name, len(content))
+	}
+	if _, err := tr.Next(); err != io.EOF {
+		t.Errorf("expected one tar entry, got %v", err)
+	}
+}
+
+func TestStreamBlobsMissingKey(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	ns := store.MustGetNamespace("tickets")
+	ns.MustPut("ticket:1", supportTicket{Title: "Crash", Screenshot: []byte(strings.Repeat("x", 16))})
+
+	var buf bytes.Buffer
+	err := ns.StreamBlobs([]string{"ticket:1", "ticket:9"}, &buf, stow.ArchiveZip)
+	if !errors.Is(err, stow.ErrNotFound) {
+		t.Fatalf("StreamBlobs = %v, want ErrNotFound", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("StreamBlobs wrote %d bytes before failing", buf.Len())
+	}
+}
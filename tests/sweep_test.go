@@ -0,0 +1,106 @@
+package stow_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aigotowork/stow"
+)
+
+type sweepDoc struct {
+	Status string `json:"status"`
+}
+
+func TestSweep(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.AutoCompact = false
+	ns, err := store.CreateNamespace("docs", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	ns.MustPut("draft:1", sweepDoc{Status: "draft"})
+	ns.MustPut("draft:2", sweepDoc{Status: "abandoned"})
+	for i := 0; i < 6; i++ {
+		ns.MustPut("post:1", sweepDoc{Status: "published"})
+	}
+	ns.MustPut("post:2", sweepDoc{Status: "published"})
+	ns.MustDelete("post:2")
+
+	var seen []string
+	result, err := ns.Sweep(func(key string, meta stow.KeyMeta, peek func(interface{}) error) stow.SweepAction {
+		seen = append(seen, key)
+		if meta.Updated.IsZero() || meta.Created.After(meta.Updated) {
+			t.Errorf("%s: unexpected meta %+v", key, meta)
+		}
+
+		if strings.HasPrefix(key, "draft:") {
+			var doc sweepDoc
+			if err := peek(&doc); err != nil {
+				t.Errorf("peek failed: %v", err)
+			}
+			if doc.Status == "abandoned" {
+				return stow.SweepDelete
+			}
+			return stow.SweepKeep
+		}
+
+		if meta.Versions > 3 {
+			return stow.SweepCompact
+		}
+		return stow.SweepKeep
+	})
+	if err != nil {
+		t.Fatalf("Sweep failed: %v", err)
+	}
+
+	if strings.Join(seen, ",") != "draft:1,draft:2,post:1" {
+		t.Errorf("Unexpected keys swept: %v", seen)
+	}
+	if result.Scanned != 3 || result.Deleted != 1 || result.Compacted != 1 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	if ns.Exists("draft:2") || !ns.Exists("draft:1") {
+		t.Error("Wrong draft deleted")
+	}
+	history, _ := ns.GetHistory("draft:2")
+	if len(history) == 0 || history[0].Reason != "sweep" {
+		t.Errorf("Expected delete reason sweep, got %+v", history)
+	}
+	history, _ = ns.GetHistory("post:1")
+	if len(history) != config.CompactKeepRecords {
+		t.Errorf("Expected compacted history, got %d versions", len(history))
+	}
+}
+
+func TestSweepSkipsKeysWrittenMeanwhile(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	ns := store.MustGetNamespace("docs")
+
+	ns.MustPut("draft", sweepDoc{Status: "old"})
+
+	result, err := ns.Sweep(func(key string, meta stow.KeyMeta, peek func(interface{}) error) stow.SweepAction {
+		// A writer updates the key while the callback decides
+		ns.MustPut(key, sweepDoc{Status: "fresh"})
+		if time.Since(meta.Updated) < time.Hour {
+			return stow.SweepDelete
+		}
+		return stow.SweepKeep
+	})
+	if err != nil {
+		t.Fatalf("Sweep failed: %v", err)
+	}
+
+	if result.Deleted != 0 || result.Skipped != 1 {
+		t.Errorf("Expected the delete to be skipped, got %+v", result)
+	}
+	if !ns.Exists("draft") {
+		t.Error("Key written during the sweep was deleted")
+	}
+}
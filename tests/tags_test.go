@@ -0,0 +1,72 @@
+package stow_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestTags(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	ns := store.MustGetNamespace("articles")
+	ns.MustPut("draft-1", map[string]interface{}{"title": "First"})
+	ns.MustPut("draft-2", map[string]interface{}{"title": "Second"})
+
+	if err := ns.Tag("draft-1", "needs-review", "urgent"); err != nil {
+		t.Fatalf("Tag failed: %v", err)
+	}
+	// Tagging twice is idempotent
+	if err := ns.Tag("draft-2", "needs-review"); err != nil {
+		t.Fatalf("Tag failed: %v", err)
+	}
+	if err := ns.Tag("draft-2", "needs-review"); err != nil {
+		t.Fatalf("Tag failed: %v", err)
+	}
+
+	keys, err := ns.ListByTag("needs-review")
+	if err != nil {
+		t.Fatalf("ListByTag failed: %v", err)
+	}
+	if !reflect.DeepEqual(keys, []string{"draft-1", "draft-2"}) {
+		t.Errorf("ListByTag = %v", keys)
+	}
+
+	tags, err := ns.Tags("draft-1")
+	if err != nil {
+		t.Fatalf("Tags failed: %v", err)
+	}
+	if !reflect.DeepEqual(tags, []string{"needs-review", "urgent"}) {
+		t.Errorf("Tags = %v", tags)
+	}
+
+	// Tags don't create versions
+	history, err := ns.GetHistory("draft-1")
+	if err != nil {
+		t.Fatalf("GetHistory failed: %v", err)
+	}
+	if len(history) != 1 {
+		t.Errorf("history has %d versions, want 1", len(history))
+	}
+
+	if err := ns.Untag("draft-1", "needs-review", "not-set"); err != nil {
+		t.Fatalf("Untag failed: %v", err)
+	}
+	keys, _ = ns.ListByTag("needs-review")
+	if !reflect.DeepEqual(keys, []string{"draft-2"}) {
+		t.Errorf("ListByTag after Untag = %v", keys)
+	}
+	if keys, _ := ns.ListByTag("missing"); len(keys) != 0 {
+		t.Errorf("ListByTag(missing) = %v", keys)
+	}
+
+	if err := ns.Tag("nope", "x"); !errors.Is(err, stow.ErrNotFound) {
+		t.Errorf("Tag missing key: expected ErrNotFound, got %v", err)
+	}
+	if err := ns.Tag("draft-1", ""); err == nil {
+		t.Error("Tag with empty tag should fail")
+	}
+}
@@ -0,0 +1,127 @@
+package stow_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+type account struct {
+	Balance int `json:"balance"`
+}
+
+func TestTxn(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	ns := store.MustGetNamespace("accounts")
+	ns.MustPut("alice", map[string]interface{}{"balance": 100})
+	ns.MustPut("hold", map[string]interface{}{"amount": 10})
+
+	err := ns.Txn(func(tx stow.Txn) error {
+		var alice account
+		if err := tx.Get("alice", &alice); err != nil {
+			return err
+		}
+		if err := tx.Put("alice", map[string]interface{}{"balance": alice.Balance - 10}); err != nil {
+			return err
+		}
+		if err := tx.Put("bob", map[string]interface{}{"balance": 10}); err != nil {
+			return err
+		}
+
+		// The transaction sees its own writes
+		var bob account
+		if err := tx.Get("bob", &bob); err != nil || bob.Balance != 10 {
+			t.Errorf("Get(bob) in txn = %v, %v", bob, err)
+		}
+		return tx.Delete("hold")
+	})
+	if err != nil {
+		t.Fatalf("Txn failed: %v", err)
+	}
+
+	var got account
+	ns.MustGet("alice", &got)
+	if got.Balance != 90 {
+		t.Errorf("alice = %v", got)
+	}
+	if !ns.Exists("bob") || ns.Exists("hold") {
+		t.Errorf("Expected bob written and hold deleted")
+	}
+}
+
+func TestTxnWritesNothingOnError(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+	ns := store.MustGetNamespace("accounts")
+	ns.MustPut("alice", map[string]interface{}{"balance": 100})
+
+	failed := errors.New("insufficient funds")
+	err := ns.Txn(func(tx stow.Txn) error {
+		tx.Put("alice", map[string]interface{}{"balance": 0})
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("Expected the function's error, got %v", err)
+	}
+
+	// Deleting a missing key fails the whole transaction
+	err = ns.Txn(func(tx stow.Txn) error {
+		tx.Put("alice", map[string]interface{}{"balance": 0})
+		return tx.Delete("nobody")
+	})
+	if !errors.Is(err, stow.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+
+	var got account
+	ns.MustGet("alice", &got)
+	if got.Balance != 100 {
+		t.Errorf("Expected alice unchanged, got %v", got)
+	}
+	if history, _ := ns.GetHistory("alice"); len(history) != 1 {
+		t.Errorf("Expected one version, got %d", len(history))
+	}
+}
+
+func TestTxnRecovery(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	ns := store.MustGetNamespace("accounts")
+	ns.MustPut("alice", map[string]interface{}{"balance": 100})
+	ns.MustPut("alice", map[string]interface{}{"balance": 90})
+	ns.MustPut("carol", map[string]interface{}{"balance": 5})
+	store.Close()
+
+	// The crash hit after alice's record but before bob's; carol's
+	// transaction landed whole
+	nsDir := filepath.Join(dir, "accounts")
+	writeIntents(t, filepath.Join(nsDir, "_intents.log"),
+		map[string]interface{}{
+			"id": 1, "op": "txn", "writes": []map[string]interface{}{
+				{"key": "alice", "file": "alice.jsonl", "version": 2},
+				{"key": "bob", "file": "bob.jsonl", "version": 1},
+			},
+		},
+		map[string]interface{}{
+			"id": 2, "op": "txn", "writes": []map[string]interface{}{
+				{"key": "carol", "file": "carol.jsonl", "version": 1},
+			},
+		},
+	)
+
+	store = stow.MustOpen(dir)
+	defer store.Close()
+	ns = store.MustGetNamespace("accounts")
+
+	var got account
+	ns.MustGet("alice", &got)
+	if got.Balance != 100 {
+		t.Errorf("Expected alice rolled back, got %v", got)
+	}
+	if !ns.Exists("carol") {
+		t.Error("Expected the committed transaction to be kept")
+	}
+}
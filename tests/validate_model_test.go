@@ -0,0 +1,27 @@
+package stow_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestValidateModel(t *testing.T) {
+	type document struct {
+		Title    string
+		FileName string
+		Content  []byte `stow:"file,name_field:FileName"`
+	}
+	if err := stow.ValidateModel[document](); err != nil {
+		t.Fatalf("ValidateModel(document) = %v, want nil", err)
+	}
+
+	type broken struct {
+		Content string `stow:"file,name_field:FileName"`
+	}
+	err := stow.ValidateModel[broken]()
+	if !errors.Is(err, stow.ErrInvalidTag) {
+		t.Fatalf("ValidateModel(broken) = %v, want ErrInvalidTag", err)
+	}
+}
@@ -0,0 +1,113 @@
+package stow_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+type vectorDoc struct {
+	Title     string    `json:"title"`
+	Embedding []float32 `json:"embedding" stow:"vector,dim:4"`
+}
+
+func TestVectorFieldRoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := stow.MustOpen(tmpDir)
+	defer store.Close()
+
+	ns := store.MustGetNamespace("docs")
+
+	doc := vectorDoc{Title: "a", Embedding: []float32{0.1, 0.2, 0.3, 0.4}}
+	ns.MustPut("a", doc)
+	ns.RefreshAll()
+
+	var result vectorDoc
+	ns.MustGet("a", &result)
+	if len(result.Embedding) != 4 || result.Embedding[3] != 0.4 {
+		t.Errorf("unexpected embedding: %v", result.Embedding)
+	}
+}
+
+func TestVectorFieldDimensionValidation(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := stow.MustOpen(tmpDir)
+	defer store.Close()
+
+	ns := store.MustGetNamespace("docs")
+
+	err := ns.Put("bad", vectorDoc{Title: "bad", Embedding: []float32{1, 2}})
+	if err == nil || !strings.Contains(err.Error(), "dimension") {
+		t.Errorf("expected dimension error, got %v", err)
+	}
+}
+
+func TestVectorFieldStoredAsBlob(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := stow.MustOpen(tmpDir)
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.BlobThreshold = 8 // 4 floats = 16 bytes, above threshold
+	ns, err := store.CreateNamespace("docs", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	ns.MustPut("a", vectorDoc{Title: "a", Embedding: []float32{1, 2, 3, 4}})
+
+	blobs, _ := os.ReadDir(filepath.Join(ns.Path(), "_blobs"))
+	if len(blobs) != 1 {
+		t.Fatalf("expected 1 vector blob, got %d", len(blobs))
+	}
+
+	ns.RefreshAll()
+
+	var result vectorDoc
+	ns.MustGet("a", &result)
+	if len(result.Embedding) != 4 || result.Embedding[2] != 3 {
+		t.Errorf("unexpected embedding after blob load: %v", result.Embedding)
+	}
+}
+
+func TestSimilaritySearch(t *testing.T) {
+	tmpDir := t.TempDir()
+	store := stow.MustOpen(tmpDir)
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.BlobThreshold = 8
+	ns, err := store.CreateNamespace("docs", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+
+	ns.MustPut("north", vectorDoc{Title: "north", Embedding: []float32{1, 0, 0, 0}})
+	ns.MustPut("north-east", vectorDoc{Title: "north-east", Embedding: []float32{1, 1, 0, 0}})
+	ns.MustPut("east", vectorDoc{Title: "east", Embedding: []float32{0, 1, 0, 0}})
+	ns.MustPut("south", vectorDoc{Title: "south", Embedding: []float32{-1, 0, 0, 0}})
+	ns.MustPut("no-vector", map[string]interface{}{"title": "plain"})
+	ns.MustDelete("east")
+
+	results, err := ns.SimilaritySearch("embedding", []float32{1, 0.1, 0, 0}, 2)
+	if err != nil {
+		t.Fatalf("SimilaritySearch failed: %v", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].Key != "north" || results[1].Key != "north-east" {
+		t.Errorf("unexpected ranking: %+v", results)
+	}
+	if results[0].Score < results[1].Score {
+		t.Error("results should be sorted by descending score")
+	}
+
+	if _, err := ns.SimilaritySearch("embedding", nil, 2); err == nil {
+		t.Error("empty query should return an error")
+	}
+}
@@ -0,0 +1,77 @@
+package stow_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aigotowork/stow"
+)
+
+func TestVerifyCompatibility(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	files := store.MustGetNamespace("files")
+	files.MustPut("doc", map[string]interface{}{"body": []byte(strings.Repeat("a", 8*1024))})
+	files.MustPut("doc", map[string]interface{}{"body": []byte(strings.Repeat("b", 8*1024))})
+	users := store.MustGetNamespace("users")
+	users.MustPut("user:1", map[string]interface{}{"name": "Alice"})
+	users.MustDelete("user:1")
+	users.MustPut("user:2", 42)
+
+	// Blobs of older versions may be collected
+	if _, err := files.GC(); err != nil {
+		t.Fatalf("GC failed: %v", err)
+	}
+	store.Close()
+
+	report, err := stow.VerifyCompatibility(dir)
+	if err != nil {
+		t.Fatalf("VerifyCompatibility failed: %v", err)
+	}
+	if !report.OK() {
+		t.Fatalf("problems in an intact store: %+v", report.Problems)
+	}
+	if report.Namespaces != 2 || report.Keys != 3 || report.Versions != 5 || report.Lines != 5 {
+		t.Errorf("report = %+v", report)
+	}
+
+	// A line with a field this version doesn't know, and a missing blob
+	matches, _ := filepath.Glob(filepath.Join(dir, "users", "user_2*.jsonl"))
+	if len(matches) != 1 {
+		t.Fatalf("user:2 files = %v", matches)
+	}
+	f, _ := os.OpenFile(matches[0], os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"_meta":{"k":"user:2","v":2,"op":"put","ts":"2025-01-01T00:00:00Z","ttl":60},"data":{"$value":43}}` + "\n")
+	f.Close()
+
+	blobs, _ := os.ReadDir(filepath.Join(dir, "files", "_blobs"))
+	for _, blob := range blobs {
+		os.Remove(filepath.Join(dir, "files", "_blobs", blob.Name()))
+	}
+
+	report, err = stow.VerifyCompatibility(dir)
+	if err != nil {
+		t.Fatalf("VerifyCompatibility failed: %v", err)
+	}
+	if len(report.Problems) < 2 {
+		t.Fatalf("problems = %+v, want the unknown field and the missing blob", report.Problems)
+	}
+	var lineProblem, blobProblem bool
+	for _, p := range report.Problems {
+		if p.Namespace == "users" && p.Line == 2 && strings.Contains(p.Error, "ttl") {
+			lineProblem = true
+		}
+		if p.Namespace == "files" && p.Key == "doc" && p.Version == 2 {
+			blobProblem = true
+		}
+	}
+	if !lineProblem || !blobProblem {
+		t.Errorf("problems = %+v", report.Problems)
+	}
+
+	if _, err := stow.VerifyCompatibility(filepath.Join(dir, "missing")); err == nil {
+		t.Error("VerifyCompatibility of a missing store succeeded")
+	}
+}
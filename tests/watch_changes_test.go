@@ -0,0 +1,161 @@
+package stow_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aigotowork/stow"
+)
+
+func nextStoreChange(t *testing.T, w *stow.ChangeWatcher) stow.Change {
+	t.Helper()
+	select {
+	case change := <-w.Events():
+		return change
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for change")
+		return stow.Change{}
+	}
+}
+
+func TestWatchChangesReplayAndResume(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	ns := store.MustGetNamespace("orders")
+	ns.MustPut("a", map[string]interface{}{"n": 1})
+	ns.MustPut("b", map[string]interface{}{"n": 1})
+	ns.MustPut("a", map[string]interface{}{"n": 2})
+
+	w, err := store.WatchChanges(0, stow.WithWatchInterval(5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("WatchChanges failed: %v", err)
+	}
+
+	want := []stow.Change{
+		{Seq: 1, Namespace: "orders", Key: "a", Op: stow.ChangePut, Version: 1},
+		{Seq: 2, Namespace: "orders", Key: "b", Op: stow.ChangePut, Version: 1},
+		{Seq: 3, Namespace: "orders", Key: "a", Op: stow.ChangePut, Version: 2},
+	}
+	for _, expected := range want {
+		if got := nextStoreChange(t, w); got != expected {
+			t.Errorf("change = %+v, want %+v", got, expected)
+		}
+	}
+
+	// New writes follow the replay
+	ns.MustDelete("b")
+	if got := nextStoreChange(t, w); got.Seq != 4 || got.Op != stow.ChangeDelete || got.Key != "b" {
+		t.Errorf("change = %+v, want delete of b as #4", got)
+	}
+	w.Close()
+	store.Close()
+
+	// Numbering continues after a restart, and a cursor resumes after it
+	store = stow.MustOpen(dir)
+	defer store.Close()
+	store.MustGetNamespace("orders").MustPut("c", map[string]interface{}{"n": 1})
+
+	w, err = store.WatchChanges(3, stow.WithWatchInterval(5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("WatchChanges failed: %v", err)
+	}
+	defer w.Close()
+
+	if got := nextStoreChange(t, w); got.Seq != 4 {
+		t.Errorf("first change = %+v, want #4", got)
+	}
+	if got := nextStoreChange(t, w); got.Seq != 5 || got.Key != "c" {
+		t.Errorf("second change = %+v, want put of c as #5", got)
+	}
+}
+
+func TestWatchChangesGap(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	ns := store.MustGetNamespace("orders")
+	for i := 0; i < 3; i++ {
+		ns.MustPut("a", map[string]interface{}{"n": i})
+	}
+	store.Close()
+
+	// Rotate the log by hand, then lose the rotated entries
+	logPath := filepath.Join(dir, "_changes.log")
+	if err := os.Rename(logPath, logPath+".1"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	store = stow.MustOpen(dir)
+	defer store.Close()
+	store.MustGetNamespace("orders").MustPut("a", map[string]interface{}{"n": 3})
+	if err := os.Remove(logPath + ".1"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+
+	w, err := store.WatchChanges(1, stow.WithWatchInterval(5*time.Millisecond))
+	if err != nil {
+		t.Fatalf("WatchChanges failed: %v", err)
+	}
+	defer w.Close()
+
+	if got := nextStoreChange(t, w); got.Op != stow.ChangeGap || got.Seq != 3 {
+		t.Errorf("first change = %+v, want a gap up to #3", got)
+	}
+	if got := nextStoreChange(t, w); got.Seq != 4 || got.Version != 4 {
+		t.Errorf("second change = %+v, want #4 writing version 4", got)
+	}
+}
+
+func TestWatchChangesSlowConsumer(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	w, err := store.WatchChanges(0, stow.WithWatchInterval(time.Millisecond), stow.WithWatchBuffer(1))
+	if err != nil {
+		t.Fatalf("WatchChanges failed: %v", err)
+	}
+	defer w.Close()
+
+	ns := store.MustGetNamespace("orders")
+	const writes = 50
+	for i := 0; i < writes; i++ {
+		ns.MustPut(fmt.Sprintf("k%d", i), map[string]interface{}{"n": i})
+	}
+
+	// Nothing is dropped however far behind the consumer is
+	for seq := uint64(1); seq <= writes; seq++ {
+		if got := nextStoreChange(t, w); got.Seq != seq || got.Op != stow.ChangePut {
+			t.Fatalf("change = %+v, want put #%d", got, seq)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWatchExternalChangesOverflow(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	defer store.Close()
+
+	ns := store.MustGetNamespace("docs")
+	w, err := ns.WatchExternalChanges(stow.WithWatchInterval(5*time.Millisecond), stow.WithWatchBuffer(1))
+	if err != nil {
+		t.Fatalf("WatchExternalChanges failed: %v", err)
+	}
+	defer w.Close()
+
+	for _, key := range []string{"a", "b", "c"} {
+		line := fmt.Sprintf(`{"_meta":{"k":%q,"v":1,"op":"put","ts":"2025-01-01T00:00:00Z"},"data":{"n":1}}`+"\n", key)
+		if err := os.WriteFile(filepath.Join(dir, "docs", key+".jsonl"), []byte(line), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if got := nextChange(t, w); got.Type != stow.ExternalCreated {
+		t.Errorf("first event = %+v, want a created file", got)
+	}
+	if got := nextChange(t, w); got.Type != stow.ExternalOverflow {
+		t.Errorf("second event = %+v, want ExternalOverflow", got)
+	}
+}
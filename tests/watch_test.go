@@ -0,0 +1,192 @@
+package stow_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aigotowork/stow"
+)
+
+func nextChange(t *testing.T, w *stow.ExternalWatcher) stow.ExternalChange {
+	t.Helper()
+	select {
+	case change := <-w.Events():
+		return change
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for external change")
+		return stow.ExternalChange{}
+	}
+}
+
+func TestWatchExternalChanges(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	defer store.Close()
+
+	config := stow.DefaultNamespaceConfig()
+	config.AutoCompact = false
+	ns, err := store.CreateNamespace("docs", config)
+	if err != nil {
+		t.Fatalf("CreateNamespace failed: %v", err)
+	}
+	ns.MustPut("alice", map[string]interface{}{"age": 30})
+
+	w, err := ns.WatchExternalChanges(stow.WithWatchInterval(5 * time.Millisecond))
+	if err != nil {
+		t.Fatalf("WatchExternalChanges failed: %v", err)
+	}
+	defer w.Close()
+
+	// Cached values keep their Go types, decoded ones are float64
+	age := func(key string) string {
+		var user map[string]interface{}
+		if err := ns.Get(key, &user); err != nil {
+			return err.Error()
+		}
+		return fmt.Sprint(user["age"])
+	}
+	if got := age("alice"); got != "30" {
+		t.Fatalf("alice age = %v, want 30", got)
+	}
+
+	// Own writes are not reported
+	ns.MustPut("alice", map[string]interface{}{"age": 31})
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case change := <-w.Events():
+		t.Fatalf("unexpected event for own write: %+v", change)
+	default:
+	}
+
+	alicePath := filepath.Join(dir, "docs", "alice.jsonl")
+	content, err := os.ReadFile(alicePath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	last := lines[len(lines)-1]
+
+	t.Run("modified", func(t *testing.T) {
+		edited := strings.Replace(string(content), `"age":31`, `"age":99`, 1)
+		if err := os.WriteFile(alicePath, []byte(edited), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+
+		change := nextChange(t, w)
+		if change.Type != stow.ExternalModified || change.Key != "alice" || change.Err != nil {
+			t.Fatalf("change = %+v", change)
+		}
+		if got := age("alice"); got != "99" {
+			t.Errorf("alice age = %v, want 99", got)
+		}
+	})
+
+	bobPath := filepath.Join(dir, "docs", "bob.jsonl")
+
+	t.Run("created", func(t *testing.T) {
+		line := strings.Replace(last, `"alice"`, `"bob"`, 1) + "\n"
+		if err := os.WriteFile(bobPath, []byte(line), 0644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+
+		change := nextChange(t, w)
+		if change.Type != stow.ExternalCreated || change.Key != "bob" {
+			t.Fatalf("change = %+v", change)
+		}
+		if !ns.Exists("bob") {
+			t.Error("bob should exist after the file was created")
+		}
+	})
+
+	t.Run("removed", func(t *testing.T) {
+		if err := os.Remove(bobPath); err != nil {
+			t.Fatalf("Remove failed: %v", err)
+		}
+
+		change := nextChange(t, w)
+		if change.Type != stow.ExternalRemoved || change.Key != "bob" {
+			t.Fatalf("change = %+v", change)
+		}
+		if ns.Exists("bob") {
+			t.Error("bob should not exist after the file was removed")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		f, err := os.OpenFile(alicePath, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatalf("OpenFile failed: %v", err)
+		}
+		f.WriteString("{not json\n")
+		f.Close()
+
+		change := nextChange(t, w)
+		if change.Type != stow.ExternalModified || !errors.Is(change.Err, stow.ErrCorruptedData) {
+			t.Fatalf("change = %+v", change)
+		}
+		if got := age("alice"); got != "99" {
+			t.Errorf("alice age = %v, want 99 (invalid line skipped)", got)
+		}
+	})
+
+	// Close ends the event stream
+	w.Close()
+	if _, ok := <-w.Events(); ok {
+		t.Error("Events should be closed after Close")
+	}
+}
+
+func TestWatchExternalChangesSaveInPlace(t *testing.T) {
+	dir := t.TempDir()
+	store := stow.MustOpen(dir)
+	defer store.Close()
+
+	ns := store.MustGetNamespace("docs")
+	ns.MustPut("alice", map[string]interface{}{"age": 30})
+	alicePath := filepath.Join(dir, "docs", "alice.jsonl")
+	content, err := os.ReadFile(alicePath)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+
+	interval := 50 * time.Millisecond
+	w, err := ns.WatchExternalChanges(stow.WithWatchInterval(interval))
+	if err != nil {
+		t.Fatalf("WatchExternalChanges failed: %v", err)
+	}
+	defer w.Close()
+
+	// An editor truncates the file, then writes it: a poll in between sees
+	// an empty file, which is not reported. Events arrive right after a
+	// poll, so the next poll lands between the two.
+	for age := 31; age < 36; age++ {
+		time.Sleep(interval / 2)
+		f, err := os.OpenFile(alicePath, os.O_WRONLY|os.O_TRUNC, 0644)
+		if err != nil {
+			t.Fatalf("OpenFile failed: %v", err)
+		}
+		time.Sleep(interval * 3 / 4)
+		f.WriteString(strings.Replace(string(content), `"age":30`, fmt.Sprintf(`"age":%d`, age), 1))
+		f.Close()
+
+		change := nextChange(t, w)
+		if change.Type != stow.ExternalModified || change.Key != "alice" || change.Err != nil {
+			t.Fatalf("change = %+v", change)
+		}
+	}
+}
+
+func TestWatchExternalChangesInvalidInterval(t *testing.T) {
+	store := stow.MustOpen(t.TempDir())
+	defer store.Close()
+
+	ns := store.MustGetNamespace("docs")
+	if _, err := ns.WatchExternalChanges(stow.WithWatchInterval(0)); !errors.Is(err, stow.ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig, got %v", err)
+	}
+}
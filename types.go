@@ -1,6 +1,13 @@
 package stow
 
-import "time"
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aigotowork/stow/internal/blob"
+	"github.com/aigotowork/stow/internal/sign"
+)
 
 // Version represents a single version record of a key.
 type Version struct {
@@ -10,11 +17,51 @@ type Version struct {
 	// Timestamp of this version
 	Timestamp time.Time `json:"timestamp"`
 
+	// Seq is the namespace's write sequence number of this version, which
+	// orders writes even when Timestamp doesn't (0 for old records)
+	Seq uint64 `json:"seq,omitempty"`
+
 	// Operation type: "put" or "delete"
 	Operation string `json:"operation"`
 
 	// Size of the data in bytes (0 for delete operations)
 	Size int64 `json:"size"`
+
+	// Reason given for a delete (see WithReason)
+	Reason string `json:"reason,omitempty"`
+
+	// VisibleAt is when a scheduled put becomes visible (see WithVisibleAt)
+	VisibleAt time.Time `json:"visible_at,omitzero"`
+
+	// RenamedFrom and RenamedTo mark the records written by Rename
+	RenamedFrom string `json:"renamed_from,omitempty"`
+	RenamedTo   string `json:"renamed_to,omitempty"`
+
+	// decode reads the value of this version on demand
+	decode func(target interface{}) error
+}
+
+// Decode decodes the value of this version into target, like GetVersion.
+// Values aren't read until Decode is called, so listing a long history
+// doesn't decode or resolve the blobs of versions nobody looks at. Each
+// call reads the version again; it fails if compaction removed it since.
+func (v Version) Decode(target interface{}) error {
+	if v.decode == nil {
+		return fmt.Errorf("version %d has no source to decode from", v.Version)
+	}
+	return v.decode(target)
+}
+
+// OperationTimeouts bounds how long each namespace operation may block its
+// caller (see WithStoreOperationTimeouts); 0 waits indefinitely. A call over
+// its limit returns ErrOperationTimeout, but file I/O can't be interrupted:
+// the operation keeps running in the background, holding the key's lock,
+// and may still complete. Don't modify a value passed to a timed-out Put.
+type OperationTimeouts struct {
+	Put     time.Duration
+	Get     time.Duration
+	Compact time.Duration
+	GC      time.Duration
 }
 
 // MetaInfo contains metadata for a record.
@@ -30,6 +77,15 @@ type MetaInfo struct {
 
 	// Timestamp when this record was created
 	Timestamp time.Time `json:"ts"`
+
+	// Seq is the namespace's write sequence number (0 for old records)
+	Seq uint64 `json:"seq,omitempty"`
+
+	// Reason given for a delete (see WithReason)
+	Reason string `json:"reason,omitempty"`
+
+	// VisibleAt is when a scheduled put becomes visible (see WithVisibleAt)
+	VisibleAt time.Time `json:"visible_at,omitzero"`
 }
 
 // NamespaceStats contains statistics about a namespace.
@@ -51,6 +107,64 @@ type NamespaceStats struct {
 
 	// Last garbage collection time
 	LastGCAt time.Time `json:"last_gc_at,omitempty"`
+
+	// File IO of the namespace's operations since it was opened
+	IO NamespaceIO `json:"io"`
+}
+
+// IOStats counts the file IO of operations: key files read in full,
+// bytes written to key, history archive and blob files, and files synced
+// to disk. Small reads of a key file's last record, sidecar files and
+// directory syncs aren't counted.
+type IOStats struct {
+	// Bytes read from key files
+	BytesRead int64 `json:"bytes_read"`
+
+	// Bytes written to key, history archive and blob files
+	BytesWritten int64 `json:"bytes_written"`
+
+	// Number of files synced to disk
+	Syncs int64 `json:"syncs"`
+
+	// Bytes of the records and blobs the caller asked to write; zero for
+	// operations that only rewrite existing data, like compaction
+	LogicalBytes int64 `json:"logical_bytes"`
+}
+
+// WriteAmplification returns BytesWritten / LogicalBytes, or 0 when
+// nothing was written on the caller's behalf.
+func (s IOStats) WriteAmplification() float64 {
+	if s.LogicalBytes == 0 {
+		return 0
+	}
+	return float64(s.BytesWritten) / float64(s.LogicalBytes)
+}
+
+// NamespaceIO is the file IO of a namespace's operations, by operation.
+// Put counts every write that stores values (Put, PutAuto, PutBatch and
+// the like), including in-place rewrites of coalesced and noversion
+// updates; Compact counts compactions, automatic ones included.
+type NamespaceIO struct {
+	Put     IOStats `json:"put"`
+	Compact IOStats `json:"compact"`
+	GC      IOStats `json:"gc"`
+}
+
+// WriteAmplification returns the bytes written by puts, compactions and
+// GC per byte the callers asked to write, or 0 before the first put.
+func (n NamespaceIO) WriteAmplification() float64 {
+	if n.Put.LogicalBytes == 0 {
+		return 0
+	}
+	written := n.Put.BytesWritten + n.Compact.BytesWritten + n.GC.BytesWritten
+	return float64(written) / float64(n.Put.LogicalBytes)
+}
+
+// StatsSnapshot is the stats of every namespace at one point in time,
+// as recorded by WithStoreStatsHistory.
+type StatsSnapshot struct {
+	Time       time.Time                 `json:"time"`
+	Namespaces map[string]NamespaceStats `json:"namespaces"`
 }
 
 // GCResult contains the result of a garbage collection operation.
@@ -58,11 +172,308 @@ type GCResult struct {
 	// Number of blob files removed
 	RemovedBlobs int `json:"removed_blobs"`
 
+	// Number of unreferenced blob files still open for reading, removed
+	// once their readers close (see NamespaceConfig.BlobReadLease)
+	DeferredBlobs int `json:"deferred_blobs,omitempty"`
+
 	// Total size reclaimed in bytes
 	ReclaimedSize int64 `json:"reclaimed_size"`
 
 	// Duration of the GC operation
 	Duration time.Duration `json:"duration"`
+
+	// File IO of the GC operation
+	IO IOStats `json:"io"`
+}
+
+// AdoptOptions configures Namespace.AdoptFile.
+type AdoptOptions struct {
+	// Validate decodes every record of the file before adopting it,
+	// checking its key, version order and blob references.
+	Validate bool
+}
+
+// PruneOptions selects the versions Namespace.Prune removes.
+type PruneOptions struct {
+	// OlderThan removes versions written longer ago than this. 0 removes
+	// every version but those kept below.
+	OlderThan time.Duration
+
+	// KeepAtLeast keeps the last KeepAtLeast versions of each key whatever
+	// their age. The version readers see, pinned versions and scheduled
+	// writes are always kept.
+	KeepAtLeast int
+}
+
+// PruneResult contains the result of a Prune run.
+type PruneResult struct {
+	// Number of keys that lost versions
+	Keys int `json:"keys"`
+
+	// Number of versions removed
+	RemovedVersions int `json:"removed_versions"`
+
+	// Number of blob files removed because only pruned versions referenced them
+	RemovedBlobs int `json:"removed_blobs"`
+
+	// Total size reclaimed in bytes, key files and blob files together
+	ReclaimedSize int64 `json:"reclaimed_size"`
+
+	// Duration of the prune
+	Duration time.Duration `json:"duration"`
+}
+
+// BlobManifestEntry is one line of a BlobManifest: a blob file and the keys
+// whose versions reference it. Hash and Size describe the content as put;
+// files written through blob filters or in an encrypted namespace hold it
+// transformed.
+type BlobManifestEntry struct {
+	// Location of the file, as stored in blob references
+	Location string `json:"location"`
+
+	// Hex-encoded hash of the content
+	Hash string `json:"hash"`
+
+	// Hash algorithm of Hash (sha256 or blake3)
+	Algo string `json:"algo"`
+
+	// Content size in bytes
+	Size int64 `json:"size"`
+
+	// Blob filters the file was written with, first filter first
+	Filters []string `json:"filters,omitempty"`
+
+	// Keys referencing the file in any version, in ascending order
+	ReferencedBy []string `json:"referenced_by"`
+}
+
+// KeyVersionRef is a version of a key referencing a blob, returned by
+// Namespace.BlobReferrers.
+type KeyVersionRef struct {
+	Key     string `json:"key"`
+	Version int    `json:"version"`
+
+	// Location of the referenced file, as stored in the blob reference
+	Location string `json:"location"`
+}
+
+// CompactStoreResult contains the result of a CompactStore run.
+type CompactStoreResult struct {
+	// Number of namespaces compacted
+	Namespaces int `json:"namespaces"`
+
+	// Blobs removed across all namespaces
+	GC GCResult `json:"gc"`
+}
+
+// CompatibilityReport contains the result of a VerifyCompatibility run.
+type CompatibilityReport struct {
+	// FormatVersion is the record format version of this library (see
+	// spec.Version)
+	FormatVersion int `json:"format_version"`
+
+	// Numbers of namespaces, keys, key file lines and versions checked
+	Namespaces int `json:"namespaces"`
+	Keys       int `json:"keys"`
+	Lines      int `json:"lines"`
+	Versions   int `json:"versions"`
+
+	// Problems found, in namespace and key order
+	Problems []CompatibilityProblem `json:"problems,omitempty"`
+}
+
+// OK reports whether everything checked reads.
+func (r CompatibilityReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+func (r *CompatibilityReport) problem(p CompatibilityProblem) {
+	r.Problems = append(r.Problems, p)
+}
+
+// CompatibilityProblem is something VerifyCompatibility couldn't read.
+type CompatibilityProblem struct {
+	Namespace string `json:"namespace"`
+
+	// Key and Version, or File and Line for lines that aren't valid records
+	Key     string `json:"key,omitempty"`
+	Version int    `json:"version,omitempty"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+
+	Error string `json:"error"`
+}
+
+// RelinkResult contains the result of a RelinkBlobs run.
+type RelinkResult struct {
+	// Number of missing blob files found and restored
+	Relinked int `json:"relinked"`
+
+	// Locations of blobs still missing, by key
+	Unresolved map[string][]string `json:"unresolved,omitempty"`
+}
+
+// RepairCollisionsResult contains the result of a RepairCollisions run.
+type RepairCollisionsResult struct {
+	// Number of shared key files split
+	Files int `json:"files"`
+
+	// File each moved key was written to, by key
+	Moved map[string]string `json:"moved,omitempty"`
+}
+
+// VerifyResult contains the result of a Verify run.
+type VerifyResult struct {
+	// Number of records whose signature matched
+	Verified int `json:"verified"`
+
+	// Versions whose content doesn't match their signature, by key
+	Invalid map[string][]int `json:"invalid,omitempty"`
+
+	// Versions without a signature, by key
+	Unsigned map[string][]int `json:"unsigned,omitempty"`
+
+	// Line numbers that aren't records at all, by file name
+	Unreadable map[string][]int `json:"unreadable,omitempty"`
+
+	// Latest versions referencing a blob file that is missing from the blob
+	// directory or doesn't match its hash, by key
+	Blobs map[string][]int `json:"blobs,omitempty"`
+}
+
+// OK reports whether every record is signed and matches its signature, and
+// every blob of the latest versions is intact.
+func (r VerifyResult) OK() bool {
+	return len(r.Invalid) == 0 && len(r.Unsigned) == 0 && len(r.Unreadable) == 0 && len(r.Blobs) == 0
+}
+
+// KeyMeta describes a key for a Sweep callback.
+type KeyMeta struct {
+	// Latest visible version
+	Version int `json:"version"`
+
+	// Number of records kept for the key (compaction removes old ones)
+	Versions int `json:"versions"`
+
+	// Timestamp of the oldest record kept
+	Created time.Time `json:"created"`
+
+	// Timestamp of the latest visible version
+	Updated time.Time `json:"updated"`
+
+	// Size of the key's data file in bytes
+	FileSize int64 `json:"file_size"`
+}
+
+// SweepAction is what Sweep does with a key.
+type SweepAction int
+
+const (
+	// SweepKeep leaves the key as it is
+	SweepKeep SweepAction = iota
+
+	// SweepDelete deletes the key (with reason "sweep")
+	SweepDelete
+
+	// SweepCompact compacts the key's history
+	SweepCompact
+)
+
+// SweepFunc decides what Sweep does with a key. peek decodes the key's
+// latest value into its argument, for decisions that depend on the data.
+type SweepFunc func(key string, meta KeyMeta, peek func(target interface{}) error) SweepAction
+
+// SweepResult contains the result of a Sweep run.
+type SweepResult struct {
+	// Number of keys passed to the callback
+	Scanned int `json:"scanned"`
+
+	// Number of keys deleted
+	Deleted int `json:"deleted"`
+
+	// Number of keys compacted
+	Compacted int `json:"compacted"`
+
+	// Number of deletes skipped because the key was written meanwhile
+	Skipped int `json:"skipped"`
+}
+
+// SchemaReport describes the fields found by InferSchema.
+type SchemaReport struct {
+	// Number of keys in the namespace
+	Keys int `json:"keys"`
+
+	// Number of records read (deleted keys have none)
+	Sampled int `json:"sampled"`
+
+	// Fields found, ordered by path
+	Fields []FieldSchema `json:"fields"`
+}
+
+// Inconsistent returns the fields holding values of more than one type,
+// nulls aside, e.g. a string in some records and a number in others.
+func (r *SchemaReport) Inconsistent() []FieldSchema {
+	var fields []FieldSchema
+	for _, field := range r.Fields {
+		if field.Mixed() {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// FieldSchema describes one field of a SchemaReport.
+type FieldSchema struct {
+	// Path of the field: nested fields are joined with ".", and "[]"
+	// stands for the elements of an array (e.g. "items[].sku"). "" is
+	// the value itself, for keys holding a scalar or a list.
+	Path string `json:"path"`
+
+	// Number of values found. Array elements count one value each.
+	Count int `json:"count"`
+
+	// Number of values of each type: "string", "number", "bool", "object",
+	// "array", "blob" or "null"
+	Types map[string]int `json:"types"`
+
+	// Optional is set when some objects holding the field's siblings
+	// lack it
+	Optional bool `json:"optional,omitempty"`
+}
+
+// Nullable reports whether the field was null in some records.
+func (f FieldSchema) Nullable() bool {
+	return f.Types[SchemaNull] > 0
+}
+
+// Mixed reports whether the field holds values of more than one type,
+// nulls aside.
+func (f FieldSchema) Mixed() bool {
+	types := len(f.Types)
+	if f.Nullable() {
+		types--
+	}
+	return types > 1
+}
+
+// Value types reported by InferSchema.
+const (
+	SchemaString = "string"
+	SchemaNumber = "number"
+	SchemaBool   = "bool"
+	SchemaObject = "object"
+	SchemaArray  = "array"
+	SchemaBlob   = "blob"
+	SchemaNull   = "null"
+)
+
+// SimilarityResult is a single match returned by SimilaritySearch.
+type SimilarityResult struct {
+	// Key of the matching record
+	Key string `json:"key"`
+
+	// Cosine similarity between the record's vector and the query (-1 to 1)
+	Score float64 `json:"score"`
 }
 
 // CompactStrategy defines when to trigger compaction.
@@ -79,6 +490,155 @@ const (
 	CompactStrategyManual CompactStrategy = "manual"
 )
 
+// OversizePolicy defines what happens when a record exceeds MaxInlineRecordSize.
+type OversizePolicy string
+
+const (
+	// OversizeReject fails the write with ErrRecordTooLarge
+	OversizeReject OversizePolicy = "reject"
+
+	// OversizeAutoBlob stores the whole data payload as a blob and writes a stub record
+	OversizeAutoBlob OversizePolicy = "auto_blob"
+
+	// OversizeTruncate drops the largest top-level fields until the record fits,
+	// writes it, and returns ErrRecordTooLarge naming the dropped fields
+	OversizeTruncate OversizePolicy = "truncate"
+)
+
+// FloatPolicy defines how NaN and ±Inf floats, which JSON can't represent,
+// are written.
+type FloatPolicy string
+
+const (
+	// FloatReject fails the write with ErrNonFiniteFloat naming the field
+	FloatReject FloatPolicy = "reject"
+
+	// FloatSentinel stores them as {"$float": "NaN"}, {"$float": "+Inf"} or
+	// {"$float": "-Inf"}, read back as the float in float and interface{}
+	// fields and maps
+	FloatSentinel FloatPolicy = "sentinel"
+)
+
+// SigningAlgorithm selects how records are signed (see SigningConfig).
+type SigningAlgorithm string
+
+const (
+	// SigningHMACSHA256 signs with a shared secret: whoever verifies can also sign
+	SigningHMACSHA256 SigningAlgorithm = sign.HMACSHA256
+
+	// SigningEd25519 signs with a private key; the public key verifies
+	SigningEd25519 SigningAlgorithm = sign.Ed25519
+)
+
+// Layout determines how a namespace lays out its files.
+type Layout string
+
+const (
+	// LayoutJSONL stores each key as its JSONL history only
+	LayoutJSONL Layout = "jsonl"
+
+	// LayoutPrettyFiles additionally keeps the latest value of each key in
+	// key.json, indented with sorted keys, for reviewable diffs in git
+	LayoutPrettyFiles Layout = "pretty_files"
+)
+
+// BlobHash selects the content hash used for new blob files.
+// The algorithm is recorded in each blob reference, so namespaces can switch
+// algorithms without losing access to existing blobs.
+type BlobHash string
+
+const (
+	// BlobHashSHA256 hashes blobs with SHA-256
+	BlobHashSHA256 BlobHash = "sha256"
+
+	// BlobHashBLAKE3 hashes blobs with BLAKE3
+	BlobHashBLAKE3 BlobHash = "blake3"
+)
+
+// Built-in blob filters for NamespaceConfig.BlobFilters.
+const (
+	// BlobFilterGzip compresses blob files with gzip
+	BlobFilterGzip = blob.FilterGzip
+
+	// BlobFilterCRC32 appends a CRC-32C checksum to blob files, checked
+	// when a blob is read to the end (failing with ErrBlobChecksum)
+	BlobFilterCRC32 = blob.FilterCRC32
+)
+
+// BlobFilter transforms blob content on its way to and from disk.
+// Filters run in the order of NamespaceConfig.BlobFilters on write, and in
+// reverse on read; the first one sees the original content. Encryption of
+// encrypted namespaces runs after all filters, on their output.
+type BlobFilter interface {
+	// Name identifies the filter in configs and blob references: lowercase
+	// letters and digits only.
+	Name() string
+
+	// Encode returns a writer transforming what is written to it into w.
+	// Close flushes it and must not close w.
+	Encode(w io.Writer) (io.WriteCloser, error)
+
+	// Decode returns a reader reversing Encode over r.
+	Decode(r io.Reader) (io.ReadCloser, error)
+}
+
+// RegisterBlobFilter makes a filter available to NamespaceConfig.BlobFilters
+// and to reading the blobs it wrote. Register filters before opening the
+// namespaces that use them; the built-in names can't be taken.
+func RegisterBlobFilter(f BlobFilter) error {
+	return blob.RegisterFilter(f)
+}
+
+// ExternalChangeType describes what happened to an externally changed data file.
+type ExternalChangeType string
+
+const (
+	// ExternalCreated means a new data file appeared
+	ExternalCreated ExternalChangeType = "created"
+
+	// ExternalModified means an existing data file was edited or replaced
+	ExternalModified ExternalChangeType = "modified"
+
+	// ExternalRemoved means a data file was deleted
+	ExternalRemoved ExternalChangeType = "removed"
+
+	// ExternalOverflow means events were dropped because Events was full.
+	// Key and File are empty; rescan the namespace to catch up.
+	ExternalOverflow ExternalChangeType = "overflow"
+)
+
+// Operation classifies an API call for an Authorizer.
+type Operation string
+
+const (
+	// OpRead covers Get, GetRaw, GetDerived, Exists, history, pins, tags and search
+	OpRead Operation = "read"
+
+	// OpWrite covers Put, PutAuto, path operations, pinning and tagging
+	OpWrite Operation = "write"
+
+	// OpDelete covers Delete
+	OpDelete Operation = "delete"
+
+	// OpList covers List, ListByTag and Stats
+	OpList Operation = "list"
+
+	// OpAdmin covers compaction, sweeps, GC, archiving, refresh and configuration changes
+	OpAdmin Operation = "admin"
+
+	// OpOpenNamespace covers GetNamespace and CloseNamespace (namespace is set, key is empty)
+	OpOpenNamespace Operation = "open_namespace"
+
+	// OpCreateNamespace covers CreateNamespace
+	OpCreateNamespace Operation = "create_namespace"
+
+	// OpDeleteNamespace covers DeleteNamespace
+	OpDeleteNamespace Operation = "delete_namespace"
+
+	// OpListNamespaces covers ListNamespaces, StatsHistory and WatchChanges (namespace and key are empty)
+	OpListNamespaces Operation = "list_namespaces"
+)
+
 // Field represents a structured logging field.
 type Field struct {
 	Key   string
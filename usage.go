@@ -0,0 +1,148 @@
+package stow
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/aigotowork/stow/internal/fsutil"
+)
+
+// DefaultDiskWatermarks are the budget fractions that trigger OnDiskUsage
+// callbacks when WithStoreDiskBudget is given no explicit watermarks.
+var DefaultDiskWatermarks = []float64{0.80, 0.95}
+
+// DiskUsage describes a watermark crossing reported to an OnDiskUsage callback.
+type DiskUsage struct {
+	// Level is the watermark that was crossed (e.g. 0.8 for 80% of Budget)
+	Level float64
+
+	// Used is the store size in bytes when the watermark was crossed
+	Used int64
+
+	// Budget is the configured byte budget
+	Budget int64
+}
+
+// diskMonitor tracks the on-disk size of a store, fires watermark callbacks and
+// enforces the hard cap. A nil *diskMonitor disables all checks.
+//
+// Usage is measured from disk at open and after maintenance operations that can
+// shrink the store; in between, writes add their approximate size.
+type diskMonitor struct {
+	mu         sync.Mutex
+	basePath   string
+	budget     int64
+	watermarks []float64
+	hardCap    int64
+	onUsage    func(DiskUsage)
+	used       int64
+	crossed    int // Number of watermarks at or below the current usage
+}
+
+// newDiskMonitor creates a monitor for the store, or returns nil if neither a
+// budget nor a hard cap is configured.
+func newDiskMonitor(basePath string, options *storeOptions) *diskMonitor {
+	if options.diskBudget <= 0 && options.diskHardCap <= 0 {
+		return nil
+	}
+
+	watermarks := options.diskWatermarks
+	if len(watermarks) == 0 {
+		watermarks = DefaultDiskWatermarks
+	}
+	watermarks = append([]float64(nil), watermarks...)
+	sort.Float64s(watermarks)
+
+	m := &diskMonitor{
+		basePath:   basePath,
+		budget:     options.diskBudget,
+		watermarks: watermarks,
+		hardCap:    options.diskHardCap,
+		onUsage:    options.onDiskUsage,
+	}
+	m.rescan()
+
+	return m
+}
+
+// check returns ErrStoreFull if writing size more bytes would exceed the hard cap.
+func (m *diskMonitor) check(size int64) error {
+	if m == nil || m.hardCap <= 0 {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.used+size > m.hardCap {
+		return ErrStoreFull
+	}
+	return nil
+}
+
+// add records size bytes written to the store.
+func (m *diskMonitor) add(size int64) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	m.used += size
+	events := m.updateLocked()
+	m.mu.Unlock()
+
+	m.fire(events)
+}
+
+// rescan measures the store size from disk.
+func (m *diskMonitor) rescan() {
+	if m == nil {
+		return
+	}
+
+	used, err := fsutil.DirSize(m.basePath)
+	if err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	m.used = used
+	events := m.updateLocked()
+	m.mu.Unlock()
+
+	m.fire(events)
+}
+
+// updateLocked returns the watermarks newly crossed upward. Watermarks that
+// usage has dropped below are re-armed silently. Caller must hold mu.
+func (m *diskMonitor) updateLocked() []DiskUsage {
+	if m.budget <= 0 {
+		return nil
+	}
+
+	crossed := 0
+	for _, level := range m.watermarks {
+		if float64(m.used) >= level*float64(m.budget) {
+			crossed++
+		}
+	}
+
+	var events []DiskUsage
+	for i := m.crossed; i < crossed; i++ {
+		events = append(events, DiskUsage{Level: m.watermarks[i], Used: m.used, Budget: m.budget})
+	}
+	m.crossed = crossed
+
+	return events
+}
+
+// fire invokes the callback for each event. Called without holding mu so the
+// callback may use the store.
+func (m *diskMonitor) fire(events []DiskUsage) {
+	if m.onUsage == nil {
+		return
+	}
+	for _, event := range events {
+		m.onUsage(event)
+	}
+}